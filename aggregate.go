@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// aggregateError is the error message prefix for aggregates that cannot be parsed.
+const aggregateError = "invalid aggregate"
+
+// Aggregation is the outcome of aggregating records with Option.GroupBy and Option.Aggregates.
+type Aggregation struct {
+	Columns []string   `json:"columns"` // labels of GroupBy followed by the aggregates as specified
+	Rows    [][]string `json:"rows"`    // values of the groups in order of their first appearance, or sorted by their labels with Option.Concurrency
+}
+
+// aggregator accumulates the records of a parse into groups. It is shared by the zip entries parsed
+// concurrently, so it is guarded by a mutex. The groups are charged to the memory meter of the parse, if set, so
+// that they count towards Option.MemoryLimit and Result.PeakMemory; they are kept whatever the limit, since
+// dropping them would make the aggregates wrong.
+type aggregator struct {
+	mu    sync.Mutex
+	q     *sqlQuery
+	meter *memoryMeter
+}
+
+// newAggregator returns an aggregator grouping by the labels with the aggregate functions count, count(*),
+// count(label), sum(label), avg(label), min(label) and max(label), each optionally followed by AS and an alias
+// as in Query. Without aggregates, the records of each group are counted.
+func newAggregator(groupBy, aggregates []string) (*aggregator, error) {
+	q := &sqlQuery{limit: -1, groups: map[string]*queryGroup{}, groupBy: groupBy}
+	for _, label := range groupBy {
+		q.items = append(q.items, queryItem{arg: label})
+	}
+	if len(aggregates) == 0 {
+		aggregates = []string{"count"}
+	}
+	for _, spec := range aggregates {
+		it, err := parseAggregate(spec)
+		if err != nil {
+			return nil, err
+		}
+		q.items = append(q.items, it)
+	}
+	return &aggregator{q: q}, nil
+}
+
+// parseAggregate parses an aggregate, where a bare count is count(*).
+func parseAggregate(spec string) (queryItem, error) {
+	if strings.EqualFold(strings.TrimSpace(spec), "count") {
+		return queryItem{agg: "count", arg: "*", alias: "count"}, nil
+	}
+	tokens, err := tokenizeQuery(spec)
+	if err != nil {
+		return queryItem{}, fmt.Errorf("%s: %q: %w", aggregateError, spec, err)
+	}
+	p := &queryParser{tokens: tokens}
+	it, err := p.item()
+	if err != nil {
+		return queryItem{}, fmt.Errorf("%s: %q: %w", aggregateError, spec, err)
+	}
+	if p.keyword("as") {
+		if it.alias, err = p.ident(); err != nil {
+			return queryItem{}, fmt.Errorf("%s: %q: %w", aggregateError, spec, err)
+		}
+	}
+	if it.agg == "" || !p.done() {
+		return queryItem{}, fmt.Errorf("%s: %q is not an aggregate function", aggregateError, spec)
+	}
+	return it, nil
+}
+
+// sortGroups sorts the groups by their labels instead of the order of their first appearance, which is not
+// deterministic when zip entries are parsed concurrently.
+func (a *aggregator) sortGroups() {
+	for i := range a.q.groupBy {
+		a.q.orderBy = append(a.q.orderBy, queryOrder{key: strconv.Itoa(i + 1)})
+	}
+}
+
+// observe adds the record to its group.
+func (a *aggregator) observe(labels, values []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	mem := a.q.mem
+	a.q.observe(Record{Labels: labels, Values: values})
+	if a.meter != nil && a.q.mem > mem {
+		a.meter.grow(a.q.mem - mem)
+	}
+}
+
+// write writes the rows of the groups to the output converted with the handler, and returns them.
+func (a *aggregator) write(output io.Writer, handler LineHandler) (*Aggregation, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	rows, err := a.q.finish()
+	if err != nil {
+		return nil, err
+	}
+	agg := &Aggregation{Columns: a.q.columns(), Rows: rows}
+	for i, row := range rows {
+		line, err := handler(agg.Columns, row, i == 0)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fmt.Fprintln(output, line); err != nil {
+			return nil, err
+		}
+	}
+	return agg, nil
+}
@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOption_GroupBy(t *testing.T) {
+	input := strings.Join([]string{
+		"status:200\tbytes_sent:100",
+		"status:404\tbytes_sent:-",
+		"status:200\tbytes_sent:50",
+		"status:500\tbytes_sent:10",
+		"status:404\tbytes_sent:20",
+	}, "\n")
+	tests := []struct {
+		name    string
+		opt     Option
+		want    string
+		wantAgg *Aggregation
+		wantErr bool
+	}{
+		{
+			name: "group by",
+			opt:  Option{GroupBy: []string{"status"}, Aggregates: []string{"count", "sum(bytes_sent)"}, Filters: []string{"status != 500"}},
+			want: "status\tcount\tsum(bytes_sent)\n200\t2\t150\n404\t2\t20\n",
+			wantAgg: &Aggregation{
+				Columns: []string{"status", "count", "sum(bytes_sent)"},
+				Rows:    [][]string{{"200", "2", "150"}, {"404", "2", "20"}},
+			},
+		},
+		{
+			name: "default count",
+			opt:  Option{GroupBy: []string{"status"}},
+			want: "status\tcount\n200\t2\n404\t2\n500\t1\n",
+			wantAgg: &Aggregation{
+				Columns: []string{"status", "count"},
+				Rows:    [][]string{{"200", "2"}, {"404", "2"}, {"500", "1"}},
+			},
+		},
+		{
+			name: "without group by",
+			opt:  Option{Aggregates: []string{"count(bytes_sent) AS n", "avg(bytes_sent)", "max(status)"}},
+			want: "n\tavg(bytes_sent)\tmax(status)\n4\t45\t500\n",
+			wantAgg: &Aggregation{
+				Columns: []string{"n", "avg(bytes_sent)", "max(status)"},
+				Rows:    [][]string{{"4", "45", "500"}},
+			},
+		},
+		{
+			name:    "not an aggregate",
+			opt:     Option{GroupBy: []string{"status"}, Aggregates: []string{"bytes_sent"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			tt.opt.LineHandler = TSVLineHandler
+			r, err := NewLTSVParser(context.Background(), buf, tt.opt).ParseString(input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if buf.String() != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", buf.String(), tt.want)
+			}
+			if !reflect.DeepEqual(r.Aggregation, tt.wantAgg) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", r.Aggregation, tt.wantAgg)
+			}
+			if r.Matched != 5-len(tt.opt.Filters) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", r.Matched, 5-len(tt.opt.Filters))
+			}
+			if r.PeakMemory == 0 {
+				t.Errorf("groups not counted in PeakMemory")
+			}
+		})
+	}
+}
+
+func TestOption_GroupBy_zip(t *testing.T) {
+	zipPath := writeZip(t, []string{"a.log", "b.log", "c.log"}, []string{
+		"status:200\nstatus:404\n",
+		"status:200\n",
+		"status:500\nstatus:404\nstatus:200\n",
+	})
+	for _, concurrency := range []int{0, 3} {
+		buf := &bytes.Buffer{}
+		opt := Option{GroupBy: []string{"status"}, LineHandler: TSVLineHandler, Concurrency: concurrency}
+		r, err := NewLTSVParser(context.Background(), buf, opt).ParseZipEntries(zipPath, "*.log")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "status\tcount\n200\t3\n404\t2\n500\t1\n"; buf.String() != want {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", buf.String(), want)
+		}
+		if r.Aggregation == nil || len(r.Aggregation.Rows) != 3 || r.Matched != 6 {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", r.Aggregation, "3 groups of 6 records")
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package parser
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaults of AnomalyConfig
+const (
+	defaultAnomalyInterval = time.Minute
+	defaultAnomalyWindow   = 10
+	defaultAnomalySigma    = 3
+	minAnomalyHistory      = 3
+)
+
+// floors of the standard deviations, so that flat histories do not flag trivial changes
+const (
+	minCountStdDev = 1
+	minRatioStdDev = 0.01
+)
+
+// metrics evaluated by AnomalyDetector
+const (
+	AnomalyCount      = "count"
+	AnomalyErrorRatio = "error_ratio"
+)
+
+// defaultStatusFields are the fields holding response status codes, covering the labels used by the built-in parsers.
+var defaultStatusFields = []string{"status", "elb_status_code", "http_status", "sc_status"}
+
+// AnomalyConfig defines the time buckets and the thresholds of AnomalyDetector.
+type AnomalyConfig struct {
+	Interval     time.Duration // width of time buckets (zero means 1 minute)
+	Window       int           // number of trailing buckets compared with (zero means 10)
+	Sigma        float64       // number of standard deviations beyond which a bucket is flagged (zero means 3)
+	TimeFields   []string      // fields holding the request time in one of the layouts recognized by Schema (nil means time and datetime)
+	StatusFields []string      // fields holding status codes, where 5xx counts as an error (nil means the labels of the built-in parsers)
+}
+
+// Anomaly is a time bucket whose metric deviates from the trailing window by more than AnomalyConfig.Sigma.
+type Anomaly struct {
+	Start  time.Time `json:"start"`  // Start of the bucket.
+	End    time.Time `json:"end"`    // End of the bucket.
+	Metric string    `json:"metric"` // Metric evaluated, "count" or "error_ratio".
+	Value  float64   `json:"value"`  // Value of the metric in the bucket.
+	Mean   float64   `json:"mean"`   // Mean of the metric in the trailing window.
+	StdDev float64   `json:"stddev"` // Standard deviation of the metric in the trailing window.
+	Score  float64   `json:"score"`  // Deviation in standard deviations, negative for drops.
+}
+
+// timeBucket holds the counts of a time bucket.
+type timeBucket struct {
+	start  time.Time
+	count  int
+	errors int
+}
+
+// AnomalyDetector counts records and errors in time buckets, and writes an Anomaly to w as NDJSON for each bucket
+// whose count or error ratio deviates from the trailing window by more than the threshold, as soon as the bucket is
+// over. Time is taken from the records, which should be roughly in time order; records older than the current
+// bucket are counted in it. Feed it with Observe, or wrap a LineHandler with AnomalyDetector.LineHandler, then call
+// Close to evaluate the last bucket. When Option.Labels is set, it must include the fields read.
+type AnomalyDetector struct {
+	mu      sync.Mutex
+	w       io.Writer
+	cfg     AnomalyConfig
+	current *timeBucket
+	history []timeBucket
+}
+
+// NewAnomalyDetector creates an AnomalyDetector writing anomalies to w.
+func NewAnomalyDetector(w io.Writer, cfg AnomalyConfig) *AnomalyDetector {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultAnomalyInterval
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = defaultAnomalyWindow
+	}
+	if cfg.Sigma <= 0 {
+		cfg.Sigma = defaultAnomalySigma
+	}
+	if cfg.TimeFields == nil {
+		cfg.TimeFields = defaultTimeFields
+	}
+	if cfg.StatusFields == nil {
+		cfg.StatusFields = defaultStatusFields
+	}
+	return &AnomalyDetector{w: w, cfg: cfg}
+}
+
+// Observe counts a record in its time bucket, and writes the anomalies of the buckets closed meanwhile.
+// Records without a valid time are ignored.
+func (d *AnomalyDetector) Observe(labels, values []string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r := Record{Labels: labels, Values: values}
+	tm, ok := parseTime(firstValue(r, d.cfg.TimeFields))
+	if !ok {
+		return nil
+	}
+	start := tm.Truncate(d.cfg.Interval)
+	if d.current == nil {
+		d.current = &timeBucket{start: start}
+	}
+	// Empty buckets in between are closed as well, as a drop to zero is a change, up to the size of the window.
+	for n := 0; d.current.start.Before(start); n++ {
+		if err := d.close(); err != nil {
+			return err
+		}
+		next := d.current.start.Add(d.cfg.Interval)
+		if n >= d.cfg.Window {
+			next = start
+		}
+		d.current = &timeBucket{start: next}
+	}
+	d.current.count++
+	if status, err := strconv.Atoi(firstValue(r, d.cfg.StatusFields)); err == nil && status >= 500 && status < 600 {
+		d.current.errors++
+	}
+	return nil
+}
+
+// LineHandler returns a LineHandler that observes each record before passing it to next.
+func (d *AnomalyDetector) LineHandler(next LineHandler) LineHandler {
+	return func(labels, values []string, isFirst bool) (string, error) {
+		if err := d.Observe(labels, values); err != nil {
+			return "", err
+		}
+		return next(labels, values, isFirst)
+	}
+}
+
+// Close evaluates the last bucket.
+func (d *AnomalyDetector) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.current == nil {
+		return nil
+	}
+	err := d.close()
+	d.current = nil
+	return err
+}
+
+// close evaluates the current bucket against the trailing window, writes the anomalies found,
+// and moves the bucket into the window.
+func (d *AnomalyDetector) close() error {
+	b := *d.current
+	var anomalies []Anomaly
+	if len(d.history) >= minAnomalyHistory {
+		counts := make([]float64, 0, len(d.history))
+		ratios := make([]float64, 0, len(d.history))
+		for _, h := range d.history {
+			counts = append(counts, float64(h.count))
+			if h.count > 0 {
+				ratios = append(ratios, float64(h.errors)/float64(h.count))
+			}
+		}
+		if a, ok := d.evaluate(b, AnomalyCount, float64(b.count), counts, minCountStdDev); ok {
+			anomalies = append(anomalies, a)
+		}
+		if b.count > 0 && len(ratios) >= minAnomalyHistory {
+			if a, ok := d.evaluate(b, AnomalyErrorRatio, float64(b.errors)/float64(b.count), ratios, minRatioStdDev); ok {
+				anomalies = append(anomalies, a)
+			}
+		}
+	}
+	d.history = append(d.history, b)
+	if len(d.history) > d.cfg.Window {
+		d.history = d.history[len(d.history)-d.cfg.Window:]
+	}
+	enc := json.NewEncoder(d.w)
+	for _, a := range anomalies {
+		if err := enc.Encode(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evaluate returns the anomaly of the value, if it deviates from the samples by more than the threshold.
+func (d *AnomalyDetector) evaluate(b timeBucket, metric string, value float64, samples []float64, floor float64) (Anomaly, bool) {
+	var sum, sq float64
+	for _, v := range samples {
+		sum += v
+	}
+	mean := sum / float64(len(samples))
+	for _, v := range samples {
+		sq += (v - mean) * (v - mean)
+	}
+	stddev := math.Sqrt(sq / float64(len(samples)))
+	score := (value - mean) / math.Max(stddev, floor)
+	if math.Abs(score) <= d.cfg.Sigma {
+		return Anomaly{}, false
+	}
+	return Anomaly{
+		Start:  b.start,
+		End:    b.start.Add(d.cfg.Interval),
+		Metric: metric,
+		Value:  value,
+		Mean:   mean,
+		StdDev: stddev,
+		Score:  score,
+	}, true
+}
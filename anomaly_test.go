@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestAnomalyDetector(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	type bucket struct {
+		requests int
+		errors   int
+	}
+	tests := []struct {
+		name    string
+		buckets []bucket
+		want    []string
+	}{
+		{
+			name:    "steady",
+			buckets: []bucket{{10, 0}, {11, 0}, {9, 1}, {10, 0}, {12, 0}},
+			want:    nil,
+		},
+		{
+			name:    "count spike",
+			buckets: []bucket{{10, 0}, {10, 0}, {10, 0}, {40, 0}},
+			want:    []string{"00:03 count 40"},
+		},
+		{
+			name:    "error ratio spike",
+			buckets: []bucket{{10, 0}, {10, 0}, {10, 0}, {10, 5}},
+			want:    []string{"00:03 error_ratio 0.5"},
+		},
+		{
+			name:    "drop to zero",
+			buckets: []bucket{{10, 0}, {11, 0}, {10, 0}, {0, 0}, {10, 0}},
+			want:    []string{"00:03 count 0"},
+		},
+		{
+			name:    "not enough history",
+			buckets: []bucket{{10, 0}, {100, 50}},
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			d := NewAnomalyDetector(buf, AnomalyConfig{})
+			for i, b := range tt.buckets {
+				for j := 0; j < b.requests; j++ {
+					status := "200"
+					if j < b.errors {
+						status = "503"
+					}
+					tm := base.Add(time.Duration(i)*time.Minute + time.Duration(j)*time.Second)
+					if err := d.Observe([]string{"time", "status"}, []string{tm.Format(time.RFC3339), status}); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if err := d.Close(); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			dec := json.NewDecoder(buf)
+			for dec.More() {
+				var a Anomaly
+				if err := dec.Decode(&a); err != nil {
+					t.Fatal(err)
+				}
+				if !a.End.Equal(a.Start.Add(time.Minute)) {
+					t.Errorf("\ngot:\n%v\nwant:\n%v\n", a.End, a.Start.Add(time.Minute))
+				}
+				got = append(got, a.Start.Format("15:04")+" "+a.Metric+" "+formatFloat(a.Value))
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("\ngot:\n%v\nwant:\n%v\n", got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func formatFloat(f float64) string {
+	b, _ := json.Marshal(f)
+	return string(b)
+}
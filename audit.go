@@ -0,0 +1,158 @@
+package parser
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"time"
+)
+
+// Transform describes a step applied to the records in a run, recorded in Result.Transforms in the order
+// of the pipeline, so that compliance reviews can verify exactly how an output was derived from its input.
+type Transform struct {
+	Step   string `json:"step"`   // Name of the step.
+	Config string `json:"config"` // Configuration of the step in canonical JSON.
+	Hash   string `json:"hash"`   // SHA-256 of the step name and configuration, to compare runs at a glance.
+}
+
+// newTransform returns a Transform of the step with the configuration encoded as canonical JSON.
+func newTransform(step string, config any) Transform {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(config); err != nil {
+		buf.Reset()
+		buf.WriteString("null")
+	}
+	b := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	sum := sha256.Sum256(append([]byte(step+"\x00"), b...))
+	return Transform{Step: step, Config: string(b), Hash: hex.EncodeToString(sum[:])}
+}
+
+// auditTrail returns the transforms applied by parsing with the patterns and the option, in the order applied.
+// Steps affecting only performance, such as LazyDecode and MergePatterns, are not recorded.
+func auditTrail(patterns []string, keywords []string, opt Option) []Transform {
+	var trail []Transform
+	if cp := opt.resume; cp != nil {
+		trail = append(trail, newTransform("resume", map[string]int64{"offset": cp.Offset, "line": int64(cp.Line)}))
+	}
+	if len(opt.SkipLines) > 0 {
+		lines := slices.Clone(opt.SkipLines)
+		slices.Sort(lines)
+		trail = append(trail, newTransform("skip_lines", lines))
+	}
+	if len(opt.SkipRanges) > 0 {
+		trail = append(trail, newTransform("skip_ranges", opt.SkipRanges))
+	}
+	for _, v := range []struct {
+		kind string
+		n    int
+	}{
+		{"head", opt.Head},
+		{"tail", opt.Tail},
+		{"offset", opt.Offset},
+		{"limit", opt.Limit},
+	} {
+		if v.n > 0 {
+			trail = append(trail, newTransform(v.kind, v.n))
+		}
+	}
+	if len(keywords) > 0 {
+		trail = append(trail, newTransform("pushdown", keywords))
+	}
+	if len(opt.LineFilters) > 0 {
+		trail = append(trail, newTransform("line_filter", opt.LineFilters))
+	}
+	if len(patterns) > 0 {
+		trail = append(trail, newTransform("decode", patterns))
+	}
+	if len(opt.Normalize) > 0 {
+		trail = append(trail, newTransform("normalize", opt.Normalize))
+	}
+	if len(opt.Filters) > 0 {
+		trail = append(trail, newTransform("filter", opt.Filters))
+	}
+	if w := opt.window; w != nil {
+		trail = append(trail, newTransform("time_range", map[string]string{
+			"label": w.label,
+			"from":  w.from.Format(time.RFC3339Nano),
+			"to":    w.to.Format(time.RFC3339Nano),
+		}))
+	}
+	for _, enrich := range opt.Enrichers {
+		trail = append(trail, newTransform("enrich", funcName(enrich)))
+	}
+	if len(opt.PostFilters) > 0 {
+		trail = append(trail, newTransform("post_filter", opt.PostFilters))
+	}
+	if opt.SeenFilter != nil {
+		trail = append(trail, newTransform("dedup", opt.SeenFilter.label))
+	}
+	for _, rule := range opt.Routes {
+		trail = append(trail, newTransform("route", rule.filters))
+	}
+	if len(opt.Labels) > 0 {
+		trail = append(trail, newTransform("select", opt.Labels))
+	}
+	if len(opt.EmitWhen) > 0 {
+		trail = append(trail, newTransform("emit_when", opt.EmitWhen))
+	}
+	if len(opt.NumberFormats) > 0 {
+		trail = append(trail, newTransform("number_format", opt.NumberFormats))
+	}
+	if len(opt.TimeFields) > 0 {
+		trail = append(trail, newTransform("time_format", opt.TimeFields))
+	}
+	if opt.OutputTimezone != "" {
+		trail = append(trail, newTransform("output_timezone", opt.OutputTimezone))
+	}
+	if len(opt.Converters) > 0 {
+		converters := make(map[string]string, len(opt.Converters))
+		for label, convert := range opt.Converters {
+			converters[label] = funcName(convert)
+		}
+		trail = append(trail, newTransform("convert", converters))
+	}
+	if opt.RawField != "" {
+		trail = append(trail, newTransform("raw_field", map[string]any{"label": opt.RawField, "filters": opt.RawFilters}))
+	}
+	if opt.FieldPositions {
+		trail = append(trail, newTransform("field_positions", true))
+	}
+	if opt.ByteOffset {
+		trail = append(trail, newTransform("byte_offset", true))
+	}
+	if opt.LineNumber {
+		trail = append(trail, newTransform("line_number", true))
+	}
+	if opt.HeaderOnce && opt.ReconcileLabels {
+		trail = append(trail, newTransform("reconcile_labels", true))
+	}
+	if opt.LineHandler != nil {
+		trail = append(trail, newTransform("handler", funcName(opt.LineHandler)))
+	}
+	return trail
+}
+
+// patternStrings returns the source texts of the patterns.
+func patternStrings(patterns []*regexp.Regexp) []string {
+	s := make([]string, len(patterns))
+	for i, p := range patterns {
+		s[i] = p.String()
+	}
+	return s
+}
+
+// funcName returns the qualified name of the function, such as "github.com/nekrassov01/access-log-parser.JSONLineHandler".
+// Closures are named after the enclosing function, such as "...WithFieldEncryption.func1".
+func funcName(fn any) string {
+	if f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()); f != nil {
+		return f.Name()
+	}
+	return ""
+}
@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func Test_auditTrail(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		keywords []string
+		opt      Option
+		want     []string
+	}{
+		{
+			name: "empty",
+			opt:  Option{},
+			want: nil,
+		},
+		{
+			name:     "all",
+			patterns: []string{`^(?P<a>\S+)$`},
+			keywords: []string{"GET"},
+			opt: Option{
+				SkipLines:   []int{3, 1},
+				LineFilters: []string{"line !~ health"},
+				Filters:     []string{"method == GET"},
+				PostFilters: []string{"size > 0"},
+				Labels:      []string{"method"},
+				RawField:    "raw",
+				ByteOffset:  true,
+				LineNumber:  true,
+				LineHandler: JSONLineHandler,
+			},
+			want: []string{
+				`skip_lines:[1,3]`,
+				`pushdown:["GET"]`,
+				`line_filter:["line !~ health"]`,
+				`decode:["^(?P<a>\\S+)$"]`,
+				`filter:["method == GET"]`,
+				`post_filter:["size > 0"]`,
+				`select:["method"]`,
+				`raw_field:{"filters":null,"label":"raw"}`,
+				`byte_offset:true`,
+				`line_number:true`,
+				`handler:"github.com/nekrassov01/access-log-parser.JSONLineHandler"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, x := range auditTrail(tt.patterns, tt.keywords, tt.opt) {
+				got = append(got, x.Step+":"+x.Config)
+				if len(x.Hash) != 64 {
+					t.Errorf("invalid hash: %s", x.Hash)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newTransform(t *testing.T) {
+	a := newTransform("filter", []string{"status == 200"})
+	b := newTransform("filter", []string{"status == 200"})
+	c := newTransform("filter", []string{"status == 404"})
+	if a.Hash != b.Hash {
+		t.Errorf("same configurations must have the same hash: %s, %s", a.Hash, b.Hash)
+	}
+	if a.Hash == c.Hash {
+		t.Errorf("different configurations must have different hashes: %s", a.Hash)
+	}
+}
+
+func TestResult_Transforms(t *testing.T) {
+	p := NewRegexParser(context.Background(), &bytes.Buffer{}, Option{
+		Filters:    []string{"a == x"},
+		Labels:     []string{"a"},
+		LazyDecode: true,
+	})
+	if err := p.AddPattern(`^(?P<a>\S+) (?P<b>\S+)$`); err != nil {
+		t.Fatal(err)
+	}
+	r, err := p.ParseString("x y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, x := range r.Transforms {
+		got = append(got, x.Step+":"+x.Config)
+	}
+	want := []string{
+		`decode:["^(?P<a>\\S+) (?P<b>\\S+)$"]`,
+		`filter:["a == x"]`,
+		`select:["a"]`,
+		`handler:"github.com/nekrassov01/access-log-parser.JSONLineHandler"`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
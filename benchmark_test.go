@@ -3,9 +3,14 @@ package parser_test
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"io"
+	"strings"
 	"testing"
+	"time"
 
 	parser "github.com/nekrassov01/access-log-parser"
+	"github.com/nekrassov01/access-log-parser/loggen"
 )
 
 func Benchmark(b *testing.B) {
@@ -19,3 +24,97 @@ func Benchmark(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkPushdown(b *testing.B) {
+	buf := &bytes.Buffer{}
+	if _, err := loggen.New(loggen.Config{Format: loggen.FormatS3, Seed: 1}).Write(context.Background(), buf, 10000); err != nil {
+		b.Fatal(err)
+	}
+	input := buf.String()
+	for _, pushdown := range []bool{false, true} {
+		b.Run(fmt.Sprintf("pushdown=%t", pushdown), func(b *testing.B) {
+			p := parser.NewS3RegexParser(context.Background(), io.Discard, parser.Option{
+				Filters:  []string{"error_code == SlowDown"},
+				Pushdown: pushdown,
+			})
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := p.ParseString(input); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkLazyDecode(b *testing.B) {
+	buf := &bytes.Buffer{}
+	if _, err := loggen.New(loggen.Config{Format: loggen.FormatS3, Seed: 1}).Write(context.Background(), buf, 10000); err != nil {
+		b.Fatal(err)
+	}
+	input := buf.String()
+	for _, lazy := range []bool{false, true} {
+		b.Run(fmt.Sprintf("lazy=%t", lazy), func(b *testing.B) {
+			p := parser.NewS3RegexParser(context.Background(), io.Discard, parser.Option{
+				Labels:     []string{"bucket", "time"},
+				LazyDecode: lazy,
+			})
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := p.ParseString(input); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMergePatterns(b *testing.B) {
+	buf := &bytes.Buffer{}
+	if _, err := loggen.New(loggen.Config{Format: loggen.FormatS3, Seed: 1}).Write(context.Background(), buf, 10000); err != nil {
+		b.Fatal(err)
+	}
+	// Mix lines of older format versions, which lack trailing fields, and lines of other formats.
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	for i, line := range lines {
+		fields := strings.Split(line, " ")
+		switch n := i % 6; n {
+		case 5:
+			lines[i] = strings.Join(fields[:4], "\t")
+		default:
+			lines[i] = strings.Join(fields[:len(fields)-n], " ")
+		}
+	}
+	input := strings.Join(lines, "\n")
+	for _, merge := range []bool{false, true} {
+		b.Run(fmt.Sprintf("merge=%t", merge), func(b *testing.B) {
+			p := parser.NewS3RegexParser(context.Background(), io.Discard, parser.Option{MergePatterns: merge})
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := p.ParseString(input); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkReformatCLFTime(b *testing.B) {
+	v := "[16/Feb/2019:11:23:45 +0000]"
+	b.Run("fast", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, ok := parser.ReformatCLFTime(v); !ok {
+				b.Fatal(v)
+			}
+		}
+	})
+	b.Run("time.Parse", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			t, err := time.Parse("[02/Jan/2006:15:04:05 -0700]", v)
+			if err != nil {
+				b.Fatal(err)
+			}
+			_ = t.Format(time.RFC3339)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Checkpoint is the position up to which a source has been parsed.
+type Checkpoint struct {
+	ID     string    `json:"id"`     // Identity of the file, which is the device and inode number where available.
+	Offset int64     `json:"offset"` // Byte offset just after the last line parsed.
+	Line   int       `json:"line"`   // Number of lines up to Offset.
+	Saved  time.Time `json:"saved"`  // Time the checkpoint was saved.
+}
+
+// Checkpointer stores the checkpoints of sources set to Option.Checkpoint, so that a later run resumes each
+// source from where the previous one left off. Sources are keyed by their absolute paths.
+type Checkpointer interface {
+	Load(source string) (Checkpoint, bool, error) // returns the checkpoint of the source, and whether there is one
+	Save(source string, cp Checkpoint) error      // records the checkpoint of the source
+}
+
+// FileCheckpointer is a Checkpointer keeping the checkpoints in a JSON file, which is replaced atomically on
+// each save. It is safe for concurrent use by parsers in the same process.
+type FileCheckpointer struct {
+	mu   sync.Mutex
+	path string
+	cps  map[string]Checkpoint
+}
+
+// NewFileCheckpointer creates a FileCheckpointer with the checkpoints saved at path, if it exists.
+func NewFileCheckpointer(path string) (*FileCheckpointer, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%s: empty checkpoint file path", checkpointError)
+	}
+	c := &FileCheckpointer{path: path, cps: map[string]Checkpoint{}}
+	b, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", checkpointError, err)
+	}
+	if err := json.Unmarshal(b, &c.cps); err != nil {
+		return nil, fmt.Errorf("%s: invalid checkpoint file: %w", checkpointError, err)
+	}
+	return c, nil
+}
+
+// Load returns the checkpoint of the source, and whether there is one.
+func (c *FileCheckpointer) Load(source string) (Checkpoint, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cp, ok := c.cps[source]
+	return cp, ok, nil
+}
+
+// Save records the checkpoint of the source and writes all the checkpoints to the file.
+func (c *FileCheckpointer) Save(source string, cp Checkpoint) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cps[source] = cp
+	if err := saveStateFile(c.path, c.cps); err != nil {
+		return fmt.Errorf("%s: %w", checkpointError, err)
+	}
+	return nil
+}
+
+// resumeFile returns the key of the file in the checkpointer, the checkpoint to start from, and a reader of the
+// complete lines from there. The file is parsed from the beginning if it has no checkpoint, or if it has been
+// replaced or truncated since. A last line without a newline is left for the next run, as it may still be
+// being written.
+func resumeFile(f *os.File, filePath string, c Checkpointer) (string, *Checkpoint, io.Reader, error) {
+	key, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("%s: %w", checkpointError, err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("%s: %w", checkpointError, err)
+	}
+	start := &Checkpoint{ID: fileID(fi, filepath.Base(filePath))}
+	cp, ok, err := c.Load(key)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if ok && cp.ID == start.ID && cp.Offset <= fi.Size() {
+		start.Offset, start.Line = cp.Offset, cp.Line
+	}
+	end, err := lastLineEnd(f, start.Offset, fi.Size())
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("%s: %w", checkpointError, err)
+	}
+	return key, start, io.NewSectionReader(f, start.Offset, end-start.Offset), nil
+}
+
+// saveCheckpoint records the position reached by a parse of the file resumed from start. Parses that failed
+// or were cancelled are not recorded, so that the lines are parsed again on the next run.
+func saveCheckpoint(c Checkpointer, key string, start *Checkpoint, r *Result) error {
+	if r.Cancelled {
+		return nil
+	}
+	return c.Save(key, Checkpoint{ID: start.ID, Offset: r.offset, Line: start.Line + r.Total, Saved: time.Now()})
+}
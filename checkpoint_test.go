@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOption_Checkpoint(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	state := filepath.Join(dir, "checkpoint.json")
+	write := func(flag int, content string) {
+		f, err := os.OpenFile(path, flag|os.O_WRONLY|os.O_CREATE, 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	replace := func(content string) {
+		tmp := path + ".new"
+		if err := os.WriteFile(tmp, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name       string
+		change     func()
+		want       string
+		wantTotal  int
+		wantErrors []int
+	}{
+		{
+			name:      "first run leaves the partial line",
+			change:    func() { write(os.O_TRUNC, "a:1\na:2\na:") },
+			want:      `{"no":"1","a":"1"}` + "\n" + `{"no":"2","a":"2"}` + "\n",
+			wantTotal: 2,
+		},
+		{
+			name:       "resumed run continues the line numbers",
+			change:     func() { write(os.O_APPEND, "3\nfoo\na:4\n") },
+			want:       `{"no":"3","a":"3"}` + "\n" + `{"no":"5","a":"4"}` + "\n",
+			wantTotal:  3,
+			wantErrors: []int{4},
+		},
+		{
+			name:   "no new lines",
+			change: func() {},
+		},
+		{
+			name:      "truncated",
+			change:    func() { write(os.O_TRUNC, "a:5\n") },
+			want:      `{"no":"1","a":"5"}` + "\n",
+			wantTotal: 1,
+		},
+		{
+			name:      "replaced",
+			change:    func() { replace("a:6\na:7\na:8\na:9\n") },
+			want:      `{"no":"1","a":"6"}` + "\n" + `{"no":"2","a":"7"}` + "\n" + `{"no":"3","a":"8"}` + "\n" + `{"no":"4","a":"9"}` + "\n",
+			wantTotal: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.change()
+			// Each run loads the checkpoints saved by the previous one, as a separate process would.
+			c, err := NewFileCheckpointer(state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf := &bytes.Buffer{}
+			r, err := NewLTSVParser(context.Background(), buf, Option{LineNumber: true, Checkpoint: c}).ParseFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", buf.String(), tt.want)
+			}
+			if r.Total != tt.wantTotal {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", r.Total, tt.wantTotal)
+			}
+			var lines []int
+			for _, e := range r.Errors {
+				lines = append(lines, e.LineNumber)
+			}
+			if len(lines) != len(tt.wantErrors) || (len(lines) > 0 && lines[0] != tt.wantErrors[0]) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", lines, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func TestNewFileCheckpointer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	if _, err := NewFileCheckpointer(""); err == nil {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "empty path error")
+	}
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileCheckpointer(path); err == nil {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "invalid checkpoint file error")
+	}
+}
@@ -0,0 +1,52 @@
+package parser
+
+import (
+	"context"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// runOrdered runs fn for n tasks with up to limit of them in flight, and calls emit with their results in order.
+// A task stays in flight from its start until its result is emitted, which also bounds the results buffered ahead
+// of a slow task. The first error from emit cancels the context passed to the tasks and stops starting new ones.
+// It returns after all started tasks have finished, so that no goroutine outlives the call.
+func runOrdered[T any](ctx context.Context, n, limit int, fn func(ctx context.Context, i int) T, emit func(i int, v T) error) error {
+	g, ctx := errgroup.WithContext(ctx)
+	results := make([]chan T, n)
+	for i := range results {
+		results[i] = make(chan T, 1)
+	}
+	window := make(chan struct{}, max(limit, 1))
+	g.Go(func() error {
+		for i := 0; i < n; i++ {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				for ; i < n; i++ {
+					close(results[i])
+				}
+				return nil
+			}
+			i := i
+			g.Go(func() error {
+				results[i] <- fn(ctx, i)
+				return nil
+			})
+		}
+		return nil
+	})
+	g.Go(func() error {
+		for i := 0; i < n; i++ {
+			v, ok := <-results[i]
+			if !ok {
+				return ctx.Err()
+			}
+			if err := emit(i, v); err != nil {
+				return err
+			}
+			<-window
+		}
+		return nil
+	})
+	return g.Wait()
+}
@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_runOrdered(t *testing.T) {
+	tests := []struct {
+		name    string
+		n       int
+		limit   int
+		failAt  int
+		want    []int
+		wantErr bool
+	}{
+		{
+			name:  "ordered",
+			n:     10,
+			limit: 3,
+			want:  []int{0, 1, 4, 9, 16, 25, 36, 49, 64, 81},
+		},
+		{
+			name:  "sequential",
+			n:     3,
+			limit: 0,
+			want:  []int{0, 1, 4},
+		},
+		{
+			name:    "stop on error",
+			n:       10,
+			limit:   3,
+			failAt:  2,
+			want:    []int{0, 1},
+			wantErr: true,
+		},
+		{
+			name:  "empty",
+			n:     0,
+			limit: 3,
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inflight, peak, started int32
+			var got []int
+			err := runOrdered(context.Background(), tt.n, tt.limit, func(ctx context.Context, i int) int {
+				atomic.AddInt32(&started, 1)
+				n := atomic.AddInt32(&inflight, 1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+				// Later tasks finish first to exercise reordering.
+				time.Sleep(time.Duration(tt.n-i) * time.Millisecond)
+				return i * i
+			}, func(i, v int) error {
+				atomic.AddInt32(&inflight, -1)
+				if tt.failAt > 0 && i == tt.failAt {
+					return errors.New("error")
+				}
+				got = append(got, v)
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+			if limit := int32(max(tt.limit, 1)); peak > limit {
+				t.Errorf("\ngot:\n%v\nwant:\n<= %v\n", peak, limit)
+			}
+			if tt.wantErr && started == int32(tt.n) {
+				t.Errorf("all %d tasks started despite the error", started)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Converter is a function type that converts the value of a field, set to Option.Converters. It is an alias,
+// so that maps of plain functions such as map[string]func(string) (string, error) can be set as well.
+type Converter = func(v string) (string, error)
+
+// convertFields returns the values with those of the fields in converters converted. Converters run after
+// NumberFormats and TimeFields, right before the records are serialized by the line handler.
+func convertFields(labels, values []string, converters map[string]Converter) ([]string, error) {
+	var vs []string
+	for i, label := range labels {
+		convert, ok := converters[label]
+		if !ok {
+			continue
+		}
+		if vs == nil {
+			vs = slices.Clone(values)
+		}
+		v, err := convert(vs[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s: field %q: %w", convertError, label, err)
+		}
+		vs[i] = v
+	}
+	if vs == nil {
+		return values, nil
+	}
+	return vs, nil
+}
+
+// DecodePercent is a converter for Option.Converters that decodes valid percent-encoded bytes in the value,
+// such as those of request_uri, leaving invalid sequences and "+" as is.
+func DecodePercent(v string) (string, error) {
+	return percentDecode(v), nil
+}
+
+// NullToEmpty is a converter for Option.Converters that replaces "-", which logs write for missing values,
+// with an empty string.
+func NullToEmpty(v string) (string, error) {
+	if v == "-" {
+		return "", nil
+	}
+	return v, nil
+}
+
+// StatusClass is a converter for Option.Converters that maps an HTTP status code to its class, such as "404"
+// to "4xx". Values other than three digits are left as is.
+func StatusClass(v string) (string, error) {
+	if len(v) != 3 || v[0] < '1' || v[0] > '5' {
+		return v, nil
+	}
+	for _, c := range v[1:] {
+		if c < '0' || c > '9' {
+			return v, nil
+		}
+	}
+	return v[:1] + "xx", nil
+}
@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestOption_Converters(t *testing.T) {
+	input := "request_uri:/a%20b?q=%E3%81%82+c\treferer:-\tstatus:404\tsize:1024\n"
+	tests := []struct {
+		name    string
+		opt     Option
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "as is",
+			opt:  Option{},
+			want: `{"request_uri":"/a%20b?q=%E3%81%82+c","referer":"-","status":"404","size":"1024"}` + "\n",
+		},
+		{
+			name: "built-in converters",
+			opt: Option{Converters: map[string]Converter{
+				"request_uri": DecodePercent,
+				"referer":     NullToEmpty,
+				"status":      StatusClass,
+				"missing":     NullToEmpty,
+			}},
+			want: `{"request_uri":"/a b?q=あ+c","referer":"","status":"4xx","size":"1024"}` + "\n",
+		},
+		{
+			name: "plain functions after number formats",
+			opt: Option{
+				NumberFormats: map[string]NumberFormat{"size": {Scale: 1.0 / 1024}},
+				Converters: map[string]func(string) (string, error){
+					"size": func(v string) (string, error) { return v + "KiB", nil },
+				},
+			},
+			want: `{"request_uri":"/a%20b?q=%E3%81%82+c","referer":"-","status":"404","size":"1KiB"}` + "\n",
+		},
+		{
+			name: "not selected",
+			opt:  Option{Labels: []string{"status"}, Converters: map[string]Converter{"referer": NullToEmpty}},
+			want: `{"status":"404"}` + "\n",
+		},
+		{
+			name: "error",
+			opt: Option{Converters: map[string]Converter{
+				"status": func(string) (string, error) { return "", errors.New("bad status") },
+			}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			_, err := NewLTSVParser(context.Background(), buf, tt.opt).ParseString(input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	for v, want := range map[string]string{"200": "2xx", "503": "5xx", "-": "-", "600": "600", "20x": "20x", "2000": "2000"} {
+		if got, _ := StatusClass(v); got != want {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+		}
+	}
+}
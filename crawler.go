@@ -0,0 +1,207 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clientClassLabel is the label of the field added by WithClientClass.
+const clientClassLabel = "client_class"
+
+// client classes output by WithClientClass
+const (
+	ClientHuman      = "human"
+	ClientKnownBot   = "known-bot"
+	ClientUnknownBot = "unknown-bot"
+)
+
+// defaultDNSTimeout is the default timeout of reverse DNS verification.
+const defaultDNSTimeout = 2 * time.Second
+
+// defaultBotAgent matches user agents of automated clients not listed as known bots.
+var defaultBotAgent = regexp.MustCompile(`(?i)bot\b|bot/|crawl|spider|slurp|scrap|curl/|wget/|python-requests|python-urllib|go-http-client|java/|libwww|httpclient|okhttp|headless|^-?$`)
+
+// defaultRobotsFields are the fields holding request paths, checked for requests to /robots.txt.
+var defaultRobotsFields = []string{"request_uri", "cs_uri_stem"}
+
+// KnownBot is a well-known crawler identified by its user agent.
+type KnownBot struct {
+	Name    string         // name of the crawler, such as "Googlebot"
+	Agent   *regexp.Regexp // pattern matching the user agent
+	Domains []string       // domains its reverse DNS names end with, used for verification (empty means not verifiable)
+}
+
+// DefaultKnownBots returns the built-in list of well-known crawlers. Googlebot and Bingbot have domains for
+// reverse DNS verification.
+func DefaultKnownBots() []KnownBot {
+	return []KnownBot{
+		{Name: "Googlebot", Agent: regexp.MustCompile(`(?i)googlebot|google-inspectiontool|storebot-google|adsbot-google`), Domains: []string{"googlebot.com", "google.com", "googleusercontent.com"}},
+		{Name: "Bingbot", Agent: regexp.MustCompile(`(?i)bingbot|adidxbot|bingpreview`), Domains: []string{"search.msn.com"}},
+		{Name: "DuckDuckBot", Agent: regexp.MustCompile(`(?i)duckduckbot`)},
+		{Name: "Applebot", Agent: regexp.MustCompile(`(?i)applebot`)},
+		{Name: "YandexBot", Agent: regexp.MustCompile(`(?i)yandex(?:bot|images|mobilebot)`)},
+		{Name: "Baiduspider", Agent: regexp.MustCompile(`(?i)baiduspider`)},
+		{Name: "facebookexternalhit", Agent: regexp.MustCompile(`(?i)facebookexternalhit|meta-externalagent`)},
+		{Name: "Twitterbot", Agent: regexp.MustCompile(`(?i)twitterbot`)},
+		{Name: "Slackbot", Agent: regexp.MustCompile(`(?i)slackbot`)},
+	}
+}
+
+// Resolver performs the DNS lookups of reverse DNS verification. *net.Resolver satisfies it.
+type Resolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// ClientClassConfig defines how ClientClassifier classifies requests.
+type ClientClassConfig struct {
+	Bots       []KnownBot     // well-known crawlers (nil means DefaultKnownBots)
+	BotAgent   *regexp.Regexp // pattern matching user agents of other automated clients (nil means the built-in pattern)
+	VerifyDNS  bool           // whether to verify crawlers with domains by reverse and forward DNS lookups or not
+	Resolver   Resolver       // resolver for verification (nil means net.DefaultResolver)
+	DNSTimeout time.Duration  // timeout of the lookups of an address (zero means 2 seconds)
+	IPFields   []string       // fields holding client addresses (nil means the labels of the built-in parsers)
+	UAFields   []string       // fields holding user agents (nil means user_agent and cs_user_agent)
+}
+
+// ClientClassifier classifies requests into humans, well-known crawlers and other automated clients.
+type ClientClassifier struct {
+	cfg      ClientClassConfig
+	verified sync.Map // verification results by bot name and address
+}
+
+// NewClientClassifier creates a ClientClassifier.
+func NewClientClassifier(cfg ClientClassConfig) (*ClientClassifier, error) {
+	if cfg.Bots == nil {
+		cfg.Bots = DefaultKnownBots()
+	}
+	for _, bot := range cfg.Bots {
+		if bot.Name == "" || bot.Agent == nil {
+			return nil, fmt.Errorf("invalid known bot: %q: name and agent pattern are required", bot.Name)
+		}
+	}
+	if cfg.BotAgent == nil {
+		cfg.BotAgent = defaultBotAgent
+	}
+	if cfg.Resolver == nil {
+		cfg.Resolver = net.DefaultResolver
+	}
+	if cfg.DNSTimeout <= 0 {
+		cfg.DNSTimeout = defaultDNSTimeout
+	}
+	if cfg.IPFields == nil {
+		cfg.IPFields = defaultClientIPFields
+	}
+	if cfg.UAFields == nil {
+		cfg.UAFields = defaultUserAgentFields
+	}
+	return &ClientClassifier{cfg: cfg}, nil
+}
+
+// Classify returns the class of the client that sent the request: ClientKnownBot for a well-known crawler,
+// ClientUnknownBot for other automated clients, including clients fetching /robots.txt and crawlers that
+// fail verification, and ClientHuman otherwise.
+func (c *ClientClassifier) Classify(r Record) string {
+	agent, hasAgent := c.agent(r)
+	for _, bot := range c.cfg.Bots {
+		if !bot.Agent.MatchString(agent) {
+			continue
+		}
+		if c.cfg.VerifyDNS && len(bot.Domains) > 0 && !c.verify(bot, r) {
+			return ClientUnknownBot
+		}
+		return ClientKnownBot
+	}
+	if hasAgent && c.cfg.BotAgent.MatchString(agent) || c.robots(r) {
+		return ClientUnknownBot
+	}
+	return ClientHuman
+}
+
+// agent returns the user agent of the request, and whether the record has one.
+func (c *ClientClassifier) agent(r Record) (string, bool) {
+	for _, field := range c.cfg.UAFields {
+		if v, ok := r.Get(field); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// robots reports whether the request is for /robots.txt.
+func (c *ClientClassifier) robots(r Record) bool {
+	for _, field := range defaultRobotsFields {
+		if v, ok := r.Get(field); ok {
+			path, _, _ := strings.Cut(v, "?")
+			return path == "/robots.txt"
+		}
+	}
+	return false
+}
+
+// verify reports whether the client address resolves to a name in the domains of the bot, and the name
+// resolves back to the address. Results are cached by bot and address.
+func (c *ClientClassifier) verify(bot KnownBot, r Record) bool {
+	var addr netip.Addr
+	for _, field := range c.cfg.IPFields {
+		if v, ok := r.Get(field); ok {
+			if a, ok := parseClientAddr(v); ok {
+				addr = a
+				break
+			}
+		}
+	}
+	if !addr.IsValid() {
+		return false
+	}
+	key := bot.Name + "\x00" + addr.String()
+	if v, ok := c.verified.Load(key); ok {
+		return v.(bool)
+	}
+	ok := c.lookup(bot, addr)
+	c.verified.Store(key, ok)
+	return ok
+}
+
+// lookup performs the reverse and forward DNS lookups of the address.
+func (c *ClientClassifier) lookup(bot KnownBot, addr netip.Addr) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.DNSTimeout)
+	defer cancel()
+	names, err := c.cfg.Resolver.LookupAddr(ctx, addr.String())
+	if err != nil {
+		return false
+	}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		if !slices.ContainsFunc(bot.Domains, func(domain string) bool {
+			return name == domain || strings.HasSuffix(name, "."+domain)
+		}) {
+			continue
+		}
+		hosts, err := c.cfg.Resolver.LookupHost(ctx, name)
+		if err != nil {
+			continue
+		}
+		for _, host := range hosts {
+			if a, err := netip.ParseAddr(host); err == nil && a.Unmap() == addr {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WithClientClass returns an Enricher that adds the field "client_class", one of "human", "known-bot" and
+// "unknown-bot", so that analytics can separate crawler traffic, e.g. with the PostFilter "client_class == human".
+func WithClientClass(c *ClientClassifier) Enricher {
+	return func(r Record) (Record, error) {
+		return r.With(clientClassLabel, c.Classify(r)), nil
+	}
+}
@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+type testResolver struct {
+	names   map[string][]string
+	hosts   map[string][]string
+	lookups int
+}
+
+func (r *testResolver) LookupAddr(_ context.Context, addr string) ([]string, error) {
+	r.lookups++
+	if names, ok := r.names[addr]; ok {
+		return names, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func (r *testResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	if hosts, ok := r.hosts[host]; ok {
+		return hosts, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func TestClientClassifier_Classify(t *testing.T) {
+	const googlebot = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+	resolver := &testResolver{
+		names: map[string][]string{
+			"66.249.66.1": {"crawl-66-249-66-1.googlebot.com."},
+			"192.0.2.1":   {"crawl-192-0-2-1.googlebot.com."},
+			"192.0.2.2":   {"host.example.com."},
+		},
+		hosts: map[string][]string{
+			"crawl-66-249-66-1.googlebot.com": {"66.249.66.1"},
+			"crawl-192-0-2-1.googlebot.com":   {"198.51.100.1"},
+		},
+	}
+	c, err := NewClientClassifier(ClientClassConfig{VerifyDNS: true, Resolver: resolver})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		labels []string
+		values []string
+		want   string
+	}{
+		{
+			name:   "human",
+			labels: []string{"remote_host", "user_agent", "request_uri"},
+			values: []string{"192.0.2.9", "Mozilla/5.0 (Windows NT 10.0; Win64; x64)", "/index.html"},
+			want:   ClientHuman,
+		},
+		{
+			name:   "verified googlebot",
+			labels: []string{"remote_host", "user_agent"},
+			values: []string{"66.249.66.1", googlebot},
+			want:   ClientKnownBot,
+		},
+		{
+			name:   "forward lookup mismatch",
+			labels: []string{"remote_host", "user_agent"},
+			values: []string{"192.0.2.1", googlebot},
+			want:   ClientUnknownBot,
+		},
+		{
+			name:   "reverse lookup outside domains",
+			labels: []string{"remote_host", "user_agent"},
+			values: []string{"192.0.2.2", googlebot},
+			want:   ClientUnknownBot,
+		},
+		{
+			name:   "unverifiable known bot",
+			labels: []string{"remote_host", "user_agent"},
+			values: []string{"192.0.2.3", "DuckDuckBot/1.1; (+http://duckduckgo.com/duckduckbot.html)"},
+			want:   ClientKnownBot,
+		},
+		{
+			name:   "unknown bot",
+			labels: []string{"remote_host", "user_agent"},
+			values: []string{"192.0.2.4", "python-requests/2.31.0"},
+			want:   ClientUnknownBot,
+		},
+		{
+			name:   "empty user agent",
+			labels: []string{"remote_host", "user_agent"},
+			values: []string{"192.0.2.5", "-"},
+			want:   ClientUnknownBot,
+		},
+		{
+			name:   "robots.txt",
+			labels: []string{"remote_host", "user_agent", "request_uri"},
+			values: []string{"192.0.2.6", "Mozilla/5.0", "/robots.txt"},
+			want:   ClientUnknownBot,
+		},
+		{
+			name:   "no user agent field",
+			labels: []string{"remote_host"},
+			values: []string{"192.0.2.7"},
+			want:   ClientHuman,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Classify(Record{Labels: tt.labels, Values: tt.values}); got != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+	lookups := resolver.lookups
+	c.Classify(Record{Labels: []string{"remote_host", "user_agent"}, Values: []string{"66.249.66.1", googlebot}})
+	if resolver.lookups != lookups {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", resolver.lookups, lookups)
+	}
+}
+
+func TestNewClientClassifier_error(t *testing.T) {
+	_, err := NewClientClassifier(ClientClassConfig{Bots: []KnownBot{{Name: "", Agent: regexp.MustCompile("x")}}})
+	if err == nil {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "error")
+	}
+}
+
+func TestWithClientClass(t *testing.T) {
+	c, err := NewClientClassifier(ClientClassConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := strings.Join([]string{
+		`192.0.2.1 - - [10/Oct/2000:13:55:36 -0700] "GET /index.html HTTP/1.1" 200 2326 "-" "Mozilla/5.0"`,
+		`192.0.2.2 - - [10/Oct/2000:13:55:37 -0700] "GET / HTTP/1.1" 200 2326 "-" "bingbot/2.0"`,
+		`192.0.2.3 - - [10/Oct/2000:13:55:38 -0700] "GET /robots.txt HTTP/1.1" 404 0 "-" "Mozilla/5.0"`,
+	}, "\n")
+	buf := &bytes.Buffer{}
+	p := NewApacheCLFRegexParser(context.Background(), buf, Option{
+		Labels:    []string{"remote_host", "client_class"},
+		Enrichers: []Enricher{WithClientClass(c)},
+	})
+	if _, err := p.ParseString(input); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"remote_host":"192.0.2.1","client_class":"human"}` + "\n" +
+		`{"remote_host":"192.0.2.2","client_class":"known-bot"}` + "\n" +
+		`{"remote_host":"192.0.2.3","client_class":"unknown-bot"}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
@@ -0,0 +1,171 @@
+package parser
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// encryptedPrefix marks a field value encrypted by WithFieldEncryption.
+const encryptedPrefix = "enc:"
+
+// KeyProvider provides the keys for field encryption. Keys are identified by ids embedded in encrypted
+// values, so that keys can be rotated while values encrypted with previous keys remain decryptable.
+type KeyProvider interface {
+	CurrentKey() (id string, key []byte, err error) // returns the key to encrypt with and its id
+	Key(id string) ([]byte, error)                  // returns the key of the id to decrypt with
+}
+
+// staticKeyProvider is a KeyProvider holding keys in memory.
+type staticKeyProvider struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewStaticKeyProvider returns a KeyProvider encrypting with the key of current among keys, whose lengths
+// must be 16, 24 or 32 bytes to select AES-128, AES-192 or AES-256. Key ids must not contain colons.
+func NewStaticKeyProvider(current string, keys map[string][]byte) (KeyProvider, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("%s: current key %q not found", encryptionError, current)
+	}
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("%s: invalid key id %q", encryptionError, id)
+		}
+		if _, err := aes.NewCipher(key); err != nil {
+			return nil, fmt.Errorf("%s: key %q: %w", encryptionError, id, err)
+		}
+	}
+	return &staticKeyProvider{current: current, keys: keys}, nil
+}
+
+// CurrentKey returns the current key.
+func (p *staticKeyProvider) CurrentKey() (string, []byte, error) {
+	return p.current, p.keys[p.current], nil
+}
+
+// Key returns the key of the id.
+func (p *staticKeyProvider) Key(id string) ([]byte, error) {
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", id)
+	}
+	return key, nil
+}
+
+// WithFieldEncryption returns a LineHandler that encrypts the values of labels with AES-GCM before passing
+// the record to handler, so that logs containing personal data such as client IPs can be stored centrally
+// while only the holders of the keys can read them. An encrypted value has the form "enc:<key id>:<data>",
+// where data is the base64url-encoded nonce and ciphertext, authenticated together with the label and
+// the key id. Use DecryptField to restore the value. Values missing in a record are left as they are.
+func WithFieldEncryption(handler LineHandler, labels []string, keys KeyProvider) LineHandler {
+	if handler == nil {
+		handler = JSONLineHandler
+	}
+	cache := &aeadCache{}
+	return func(ls, vs []string, isFirst bool) (string, error) {
+		var encrypted []string
+		for i, label := range ls {
+			if i >= len(vs) || !slices.Contains(labels, label) {
+				continue
+			}
+			if encrypted == nil {
+				encrypted = slices.Clone(vs)
+			}
+			v, err := encryptField(cache, keys, label, vs[i])
+			if err != nil {
+				return "", err
+			}
+			encrypted[i] = v
+		}
+		if encrypted == nil {
+			encrypted = vs
+		}
+		return handler(ls, encrypted, isFirst)
+	}
+}
+
+// DecryptField restores the value of label encrypted by WithFieldEncryption, with the key of the id
+// embedded in the value. Values not encrypted are returned as they are.
+func DecryptField(label, value string, keys KeyProvider) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+	id, data, ok := strings.Cut(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if !ok {
+		return "", fmt.Errorf("%s: missing key id", decryptionError)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", decryptionError, err)
+	}
+	key, err := keys.Key(id)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", decryptionError, err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", decryptionError, err)
+	}
+	n := aead.NonceSize()
+	if len(b) < n {
+		return "", fmt.Errorf("%s: ciphertext too short", decryptionError)
+	}
+	plain, err := aead.Open(nil, b[:n], b[n:], fieldAAD(label, id))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", decryptionError, err)
+	}
+	return string(plain), nil
+}
+
+// aeadCache caches AEADs by key id, as creating one per value is costly. A key id must therefore
+// always refer to the same key.
+type aeadCache struct {
+	m sync.Map
+}
+
+// encryptField encrypts the value of label with the current key.
+func encryptField(cache *aeadCache, keys KeyProvider, label, value string) (string, error) {
+	id, key, err := keys.CurrentKey()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", encryptionError, err)
+	}
+	if id == "" || strings.Contains(id, ":") {
+		return "", fmt.Errorf("%s: invalid key id %q", encryptionError, id)
+	}
+	var aead cipher.AEAD
+	if v, ok := cache.m.Load(id); ok {
+		aead = v.(cipher.AEAD)
+	} else {
+		if aead, err = newAEAD(key); err != nil {
+			return "", fmt.Errorf("%s: %w", encryptionError, err)
+		}
+		cache.m.Store(id, aead)
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(value)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("%s: %w", encryptionError, err)
+	}
+	b := aead.Seal(nonce, nonce, []byte(value), fieldAAD(label, id))
+	return encryptedPrefix + id + ":" + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// newAEAD creates an AES-GCM AEAD with the key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// fieldAAD returns the additional data binding an encrypted value to its label and key id,
+// so that a value cannot be moved to another field unnoticed.
+func fieldAAD(label, id string) []byte {
+	return []byte(label + "\x00" + id)
+}
@@ -0,0 +1,138 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNewStaticKeyProvider(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	tests := []struct {
+		name    string
+		current string
+		keys    map[string][]byte
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			current: "k1",
+			keys:    map[string][]byte{"k1": key, "k0": key[:16]},
+			wantErr: false,
+		},
+		{
+			name:    "missing current key",
+			current: "k2",
+			keys:    map[string][]byte{"k1": key},
+			wantErr: true,
+		},
+		{
+			name:    "invalid key length",
+			current: "k1",
+			keys:    map[string][]byte{"k1": key[:10]},
+			wantErr: true,
+		},
+		{
+			name:    "colon in key id",
+			current: "k:1",
+			keys:    map[string][]byte{"k:1": key},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewStaticKeyProvider(tt.current, tt.keys); (err != nil) != tt.wantErr {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithFieldEncryption(t *testing.T) {
+	key0, key1 := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 16)
+	old, err := NewStaticKeyProvider("k0", map[string][]byte{"k0": key0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := NewStaticKeyProvider("k1", map[string][]byte{"k0": key0, "k1": key1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := []string{"remote_host", "method", "user"}
+	values := []string{"192.0.2.1", "GET", ""}
+
+	h := WithFieldEncryption(KeyValuePairLineHandler, []string{"remote_host", "user"}, keys)
+	got, err := h(labels, values, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(got, "192.0.2.1") || !strings.Contains(got, `method="GET"`) {
+		t.Errorf("only the selected fields must be encrypted: %s", got)
+	}
+	if values[0] != "192.0.2.1" {
+		t.Errorf("values of the caller must not be modified: %v", values)
+	}
+
+	// values encrypted with a rotated key remain decryptable
+	enc := map[string]string{}
+	var values0 []string
+	h0 := WithFieldEncryption(func(ls, vs []string, _ bool) (string, error) {
+		values0 = vs
+		return "", nil
+	}, []string{"remote_host", "user"}, old)
+	if _, err := h0(labels, values, false); err != nil {
+		t.Fatal(err)
+	}
+	enc["remote_host"], enc["user"] = values0[0], values0[2]
+	if !strings.HasPrefix(enc["remote_host"], "enc:k0:") {
+		t.Errorf("key id must be embedded: %s", enc["remote_host"])
+	}
+	for i, label := range labels {
+		v, ok := enc[label]
+		if !ok {
+			v = values[i]
+		}
+		got, err := DecryptField(label, v, keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != values[i] {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, values[i])
+		}
+	}
+
+	// a value moved to another field is rejected
+	if _, err := DecryptField("user", enc["remote_host"], keys); err == nil {
+		t.Error("value of another field must not be decrypted")
+	}
+	// a value with an unknown key id is rejected
+	if _, err := DecryptField("remote_host", strings.Replace(enc["remote_host"], "k0", "k9", 1), keys); err == nil {
+		t.Error("value with an unknown key id must not be decrypted")
+	}
+}
+
+func TestWithFieldEncryption_parser(t *testing.T) {
+	keys, err := NewStaticKeyProvider("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	p := NewLTSVParser(context.Background(), buf, Option{
+		LineHandler: WithFieldEncryption(LTSVLineHandler, []string{"host"}, keys),
+	})
+	if _, err := p.ParseString("host:192.0.2.1\tstatus:200"); err != nil {
+		t.Fatal(err)
+	}
+	labels, values, err := ltsvLineDecoder(strings.TrimSpace(buf.String()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecryptField(labels[0], values[0], keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "192.0.2.1" || values[1] != "200" {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", []string{got, values[1]}, []string{"192.0.2.1", "200"})
+	}
+}
@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// labels of the fields added by WithScorer
+const (
+	scoreLabel = "score"
+	tagsLabel  = "tags"
+)
+
+// Record is a decoded log record passed to enrichers, with labels and values in the same order.
+type Record struct {
+	Labels []string
+	Values []string
+}
+
+// Get returns the value of the label, and whether the record has the label.
+func (r Record) Get(label string) (string, bool) {
+	if i := slices.Index(r.Labels, label); i >= 0 && i < len(r.Values) {
+		return r.Values[i], true
+	}
+	return "", false
+}
+
+// With returns the record with the value of the label replaced, or added at the end if the record lacks the label.
+// The receiver is not modified, as records may share their labels and values with others.
+func (r Record) With(label, value string) Record {
+	if i := slices.Index(r.Labels, label); i >= 0 && i < len(r.Values) {
+		values := slices.Clone(r.Values)
+		values[i] = value
+		return Record{Labels: r.Labels, Values: values}
+	}
+	return Record{
+		Labels: append(r.Labels[:len(r.Labels):len(r.Labels)], label),
+		Values: append(r.Values[:len(r.Values):len(r.Values)], value),
+	}
+}
+
+// Enricher is a function type that adds or converts fields of a record, set to Option.Enrichers. Enrichers run
+// in order after Filters and before PostFilters, so that the fields they add can be used by PostFilters and
+// Routes. Fields added are output only if selected when Option.Labels is set. Enrichers may refer to any field,
+// so LazyDecode and projection are disabled while enrichers are set.
+type Enricher func(r Record) (Record, error)
+
+// WithScorer returns an Enricher that adds the severity score and tags computed by scorer as the fields "score"
+// and "tags" (comma-separated), giving rule engines such as IDS integrations a standard place to attach to.
+// The fields can be used in PostFilters and Routes, e.g. When("score >= 50").To(alertSink).
+func WithScorer(scorer func(r Record) (score int, tags []string)) Enricher {
+	return func(r Record) (Record, error) {
+		score, tags := scorer(r)
+		return r.With(scoreLabel, strconv.Itoa(score)).With(tagsLabel, strings.Join(tags, ",")), nil
+	}
+}
@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecord_With(t *testing.T) {
+	tests := []struct {
+		name  string
+		label string
+		value string
+		want  Record
+	}{
+		{
+			name:  "add",
+			label: "c",
+			value: "3",
+			want:  Record{Labels: []string{"a", "b", "c"}, Values: []string{"1", "2", "3"}},
+		},
+		{
+			name:  "replace",
+			label: "a",
+			value: "x",
+			want:  Record{Labels: []string{"a", "b"}, Values: []string{"x", "2"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels := make([]string, 2, 4)
+			values := make([]string, 2, 4)
+			copy(labels, []string{"a", "b"})
+			copy(values, []string{"1", "2"})
+			r := Record{Labels: labels, Values: values}
+			if got := r.With(tt.label, tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+			// neither the values nor the spare capacity of the backing arrays may be written
+			if want := []string{"1", "2", ""}; !reflect.DeepEqual(values[:3], want) || labels[:3][2] != "" {
+				t.Errorf("receiver must not be modified: %v", values[:3])
+			}
+		})
+	}
+}
+
+func TestWithScorer(t *testing.T) {
+	scorer := func(r Record) (int, []string) {
+		uri, _ := r.Get("uri")
+		switch {
+		case strings.Contains(uri, "../"):
+			return 80, []string{"path-traversal", "lfi"}
+		case strings.Contains(uri, "admin"):
+			return 30, []string{"admin"}
+		default:
+			return 0, nil
+		}
+	}
+	input := strings.Join([]string{
+		"host:a\turi:/index.html",
+		"host:b\turi:/../../etc/passwd",
+		"host:c\turi:/admin",
+	}, "\n")
+	main, alert := &bytes.Buffer{}, &bytes.Buffer{}
+	p := NewLTSVParser(context.Background(), main, Option{
+		Labels:      []string{"host", "score", "tags"},
+		Enrichers:   []Enricher{WithScorer(scorer)},
+		PostFilters: []string{"score > 0"},
+		Routes:      []*Rule{When("score >= 50").To(alert)},
+		LazyDecode:  true,
+	})
+	r, err := p.ParseString(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		`{"host":"b","score":"80","tags":"path-traversal,lfi"}`,
+		`{"host":"c","score":"30","tags":"admin"}`,
+	}, "\n") + "\n"
+	if got := main.String(); got != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+	if got, want := alert.String(), `{"host":"b","score":"80","tags":"path-traversal,lfi"}`+"\n"; got != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+	if r.Excluded != 1 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", r.Excluded, 1)
+	}
+}
+
+func TestEnricher_error(t *testing.T) {
+	p := NewLTSVParser(context.Background(), &bytes.Buffer{}, Option{
+		Enrichers: []Enricher{func(r Record) (Record, error) { return r, errors.New("enrichment failed") }},
+	})
+	if _, err := p.ParseString("a:1"); err == nil {
+		t.Error("enricher error must stop parsing")
+	}
+}
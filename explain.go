@@ -0,0 +1,185 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"strings"
+)
+
+// Explanation describes how a single line goes through the pipeline configured for a parser, returned by Explain
+// to debug the patterns, filters and conversions of a configuration interactively.
+type Explanation struct {
+	Line       string          // line explained
+	Patterns   []PatternResult // result of each pattern in order, empty for parsers without patterns such as LTSVParser
+	Labels     []string        // labels decoded from the line
+	Values     []string        // values decoded from the line
+	Filters    []FilterResult  // result of each filter expression, evaluated separately
+	Stages     []string        // stages of the pipeline the line went through, as reported to Option.Trace
+	Excluded   string          // reason the line was excluded, such as "filter" or "post filter" ("" means not excluded)
+	Changes    []FieldChange   // fields whose values were changed between decoding and serialization
+	Transforms []Transform     // transforms configured, as in Result.Transforms
+	Output     []string        // lines written for the line, more than one for records expanded by parsers
+	Err        error           // error of the decoder if the line is unmatched, or of the pipeline
+}
+
+// PatternResult is the result of matching a line against a pattern.
+type PatternResult struct {
+	Pattern string // pattern matched against
+	Matched bool   // whether the line matched the pattern
+	Reason  string // where matching stopped, if the line did not match
+}
+
+// FilterResult is the result of a filter expression evaluated on its own.
+type FilterResult struct {
+	Kind       string // option of the expression, "line filter", "filter" or "post filter"
+	Expression string // expression evaluated
+	Satisfied  bool   // whether the line or record satisfied the expression
+	Err        error  // error of the evaluation, such as a value that cannot be compared
+}
+
+// FieldChange is a field whose value was changed between decoding and serialization, such as by NumberFormats,
+// TimeFields, Converters or enrichers.
+type FieldChange struct {
+	Label  string // label of the field
+	Before string // value decoded
+	After  string // value serialized
+}
+
+// explain runs the line through the pipeline of the option and returns what happened to it. The line is line 1
+// of a source of its own, so that SkipLines, SkipRanges and Offset are ignored, and options with side effects
+// beyond the result, namely Routes, SeenFilter, Index, Reloader, Checkpoint and HeaderOnce, are disabled.
+// This function is used as an internal process of the Explain method.
+func explain(ctx context.Context, line string, patterns []*regexp.Regexp, decoder lineDecoder, opt Option) Explanation {
+	e := Explanation{Line: line}
+	for _, pattern := range patterns {
+		pr := PatternResult{Pattern: pattern.String(), Matched: pattern.MatchString(line)}
+		if !pr.Matched {
+			pr.Reason = mismatch(pattern, line)
+		}
+		e.Patterns = append(e.Patterns, pr)
+	}
+	var enriched *Record
+	opt.Trace = WithTrace(func(stage string, rec RecordContext) {
+		e.Stages = append(e.Stages, stage)
+		switch stage {
+		case TraceDecode:
+			e.Labels, e.Values = slices.Clone(rec.Labels), slices.Clone(rec.Values)
+		case TraceEnrich:
+			if enriched == nil {
+				enriched = &Record{Labels: slices.Clone(rec.Labels), Values: slices.Clone(rec.Values)}
+			}
+		case TraceExclude:
+			e.Excluded = rec.Reason
+		case TraceUnmatch:
+			e.Err = rec.Err
+		case TraceWrite:
+			if e.Output == nil {
+				e.Changes = fieldChanges(e.Labels, e.Values, rec.Labels, rec.Values)
+			}
+			e.Output = append(e.Output, rec.Output)
+		}
+	})
+	opt.SkipLines, opt.SkipRanges, opt.Offset = nil, nil, 0
+	opt.Head, opt.Tail, opt.Limit = 1, 0, 0
+	opt.Routes, opt.SeenFilter, opt.Index, opt.Reloader, opt.Checkpoint = nil, nil, nil, nil, nil
+	opt.HeaderOnce, opt.header, opt.resume, opt.window = false, nil, nil, nil
+	opt.Prefix, opt.RateLimit, opt.Heartbeat, opt.Snapshot = false, 0, 0, 0
+	r, err := parser(ctx, strings.NewReader(line), io.Discard, patterns, decoder, opt)
+	if err != nil {
+		e.Err = err
+	}
+	e.Transforms = r.Transforms
+	e.Filters = explainFilters(e, enriched, opt)
+	return e
+}
+
+// explainFilters evaluates each expression of LineFilters, Filters and PostFilters on its own. Filters are
+// evaluated if the line was decoded, and PostFilters if the record was enriched or no enrichers are set.
+func explainFilters(e Explanation, enriched *Record, opt Option) []FilterResult {
+	var ret []FilterResult
+	for _, filter := range opt.LineFilters {
+		fr := FilterResult{Kind: traceLineFilter, Expression: filter}
+		if fs, err := getLineFilters([]string{filter}); err != nil {
+			fr.Err = err
+		} else {
+			fr.Satisfied, fr.Err = applyLineFilters(e.Line, fs)
+		}
+		ret = append(ret, fr)
+	}
+	numbers, filters := splitLineNumberFilters(opt.Filters, opt.LineNumber)
+	for _, filter := range numbers {
+		fr := FilterResult{Kind: traceFilter, Expression: filter}
+		if fs, err := getLineNumberFilters([]string{filter}); err != nil {
+			fr.Err = err
+		} else {
+			fr.Satisfied, fr.Err = applyLineFilters("1", fs)
+		}
+		ret = append(ret, fr)
+	}
+	if e.Labels == nil {
+		return ret
+	}
+	values := normalizeFields(e.Labels, e.Values, opt.Normalize)
+	for _, filter := range filters {
+		fr := FilterResult{Kind: traceFilter, Expression: filter}
+		fr.Satisfied, fr.Err = applyFilter(e.Labels, values, []string{filter})
+		ret = append(ret, fr)
+	}
+	rec := enriched
+	if rec == nil && len(opt.Enrichers) == 0 {
+		rec = &Record{Labels: e.Labels, Values: values}
+	}
+	if rec == nil {
+		return ret
+	}
+	for _, filter := range opt.PostFilters {
+		fr := FilterResult{Kind: tracePostFilter, Expression: filter}
+		fr.Satisfied, fr.Err = applyFilter(rec.Labels, rec.Values, []string{filter})
+		ret = append(ret, fr)
+	}
+	return ret
+}
+
+// fieldChanges returns the fields serialized with values different from those decoded.
+func fieldChanges(labels, values, outLabels, outValues []string) []FieldChange {
+	var ret []FieldChange
+	for i, label := range outLabels {
+		j := slices.Index(labels, label)
+		if j < 0 || j >= len(values) || i >= len(outValues) || values[j] == outValues[i] {
+			continue
+		}
+		ret = append(ret, FieldChange{Label: label, Before: values[j], After: outValues[i]})
+	}
+	return ret
+}
+
+// mismatch returns where matching the line against the pattern stopped, found as the longest run of the leading
+// sub-expressions of the pattern that the line matches. Patterns that are not a concatenation are not analyzed.
+func mismatch(pattern *regexp.Regexp, line string) string {
+	re, err := syntax.Parse(pattern.String(), syntax.Perl)
+	if err != nil || re.Op != syntax.OpConcat {
+		return "no match"
+	}
+	end, n := 0, 0
+	for k := 1; k < len(re.Sub); k++ {
+		prefix := &syntax.Regexp{Op: syntax.OpConcat, Flags: re.Flags, Sub: re.Sub[:k]}
+		p, err := regexp.Compile(stripCaptures(prefix).String())
+		if err != nil {
+			break
+		}
+		loc := p.FindStringIndex(line)
+		if loc == nil {
+			break
+		}
+		end, n = loc[1], k
+	}
+	rest := line[end:]
+	if len(rest) > 32 {
+		rest = rest[:32] + "..."
+	}
+	return fmt.Sprintf("matched up to byte %d, where %s does not match %q", end, re.Sub[n], rest)
+}
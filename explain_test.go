@@ -0,0 +1,116 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestRegexParser_Explain(t *testing.T) {
+	patterns := []string{
+		`^(?P<method>[A-Z]+) (?P<path>\S+) (?P<status>\d{3}) (?P<size>\d+)$`,
+		`^(?P<method>[A-Z]+) (?P<path>\S+) (?P<status>\d{3})$`,
+	}
+	tests := []struct {
+		name        string
+		line        string
+		opt         Option
+		wantMatched []bool
+		wantReasons []string
+		wantFilters []FilterResult
+		wantStages  []string
+		wantExclude string
+		wantChanges []FieldChange
+		wantOutput  []string
+		wantErr     bool
+	}{
+		{
+			name: "excluded",
+			line: "GET /a%20b 404 2048",
+			opt: Option{
+				Filters:       []string{"status >= 400", "method == POST"},
+				NumberFormats: map[string]NumberFormat{"size": {Scale: 1.0 / 1024}},
+				Converters:    map[string]Converter{"path": DecodePercent},
+				Labels:        []string{"path", "size"},
+				LineNumber:    true,
+			},
+			wantMatched: []bool{true, false},
+			wantReasons: []string{"", `matched up to byte 14, where (?-m:$) does not match " 2048"`},
+			wantFilters: []FilterResult{
+				{Kind: "filter", Expression: "status >= 400", Satisfied: true},
+				{Kind: "filter", Expression: "method == POST", Satisfied: false},
+			},
+			wantStages:  []string{TraceRead, TraceDecode, TraceExclude},
+			wantExclude: "filter",
+		},
+		{
+			name: "converted",
+			line: "GET /a%20b 404 2048",
+			opt: Option{
+				LineFilters:   []string{"line =~ GET"},
+				Filters:       []string{"no == 1", "status >= 400"},
+				NumberFormats: map[string]NumberFormat{"size": {Scale: 1.0 / 1024}},
+				Converters:    map[string]Converter{"path": DecodePercent},
+				Labels:        []string{"path", "size"},
+				SkipLines:     []int{1},
+				LineNumber:    true,
+			},
+			wantMatched: []bool{true, false},
+			wantReasons: []string{"", `matched up to byte 14, where (?-m:$) does not match " 2048"`},
+			wantFilters: []FilterResult{
+				{Kind: "line filter", Expression: "line =~ GET", Satisfied: true},
+				{Kind: "filter", Expression: "no == 1", Satisfied: true},
+				{Kind: "filter", Expression: "status >= 400", Satisfied: true},
+			},
+			wantStages:  []string{TraceRead, TraceDecode, TraceWrite},
+			wantChanges: []FieldChange{{Label: "path", Before: "/a%20b", After: "/a b"}, {Label: "size", Before: "2048", After: "2"}},
+			wantOutput:  []string{`{"no":"1","path":"/a b","size":"2"}`},
+		},
+		{
+			name:        "unmatched",
+			line:        "GET /a 40x",
+			opt:         Option{},
+			wantMatched: []bool{false, false},
+			wantReasons: []string{
+				`matched up to byte 7, where (?P<status>[0-9]{3}) does not match "40x"`,
+				`matched up to byte 7, where (?P<status>[0-9]{3}) does not match "40x"`,
+			},
+			wantStages: []string{TraceRead, TraceUnmatch},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			p := NewRegexParser(context.Background(), buf, tt.opt)
+			if err := p.AddPatterns(patterns); err != nil {
+				t.Fatal(err)
+			}
+			got := p.Explain(tt.line)
+			var matched []bool
+			var reasons []string
+			for _, pr := range got.Patterns {
+				matched, reasons = append(matched, pr.Matched), append(reasons, pr.Reason)
+			}
+			if !reflect.DeepEqual(matched, tt.wantMatched) || !reflect.DeepEqual(reasons, tt.wantReasons) {
+				t.Errorf("\ngot:\n%v %q\nwant:\n%v %q\n", matched, reasons, tt.wantMatched, tt.wantReasons)
+			}
+			if !reflect.DeepEqual(got.Filters, tt.wantFilters) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Filters, tt.wantFilters)
+			}
+			if !reflect.DeepEqual(got.Stages, tt.wantStages) || got.Excluded != tt.wantExclude {
+				t.Errorf("\ngot:\n%v %q\nwant:\n%v %q\n", got.Stages, got.Excluded, tt.wantStages, tt.wantExclude)
+			}
+			if !reflect.DeepEqual(got.Changes, tt.wantChanges) || !reflect.DeepEqual(got.Output, tt.wantOutput) {
+				t.Errorf("\ngot:\n%v %v\nwant:\n%v %v\n", got.Changes, got.Output, tt.wantChanges, tt.wantOutput)
+			}
+			if (got.Err != nil) != tt.wantErr {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Err, tt.wantErr)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", buf.String(), "")
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Transition is the number of times clients requested a path right after another in a session.
+type Transition struct {
+	From  string `json:"from"`  // Path of the previous request.
+	To    string `json:"to"`    // Path of the next request.
+	Count int    `json:"count"` // Number of transitions.
+}
+
+// TransitionMatrix holds the transition counts between the most frequent paths,
+// where Counts[i][j] is the number of transitions from Paths[i] to Paths[j].
+type TransitionMatrix struct {
+	Paths  []string `json:"paths"`
+	Counts [][]int  `json:"counts"`
+}
+
+// Transitions counts transitions between consecutive request paths in each session, for "where do users go next"
+// analysis. Sessions are reconstructed as by Sessionizer, and query strings are removed from paths. Feed it with
+// Observe, or wrap a LineHandler with Transitions.LineHandler. When Option.Labels is set, it must include the
+// fields read.
+type Transitions struct {
+	mu      sync.Mutex
+	tracker *sessionTracker
+	counts  map[[2]string]int
+}
+
+// NewTransitions creates a Transitions grouping requests into sessions by the configuration.
+func NewTransitions(cfg SessionConfig) *Transitions {
+	return &Transitions{tracker: newSessionTracker(cfg), counts: map[[2]string]int{}}
+}
+
+// Observe adds a record to its session, and counts the transition from the previous request in the session.
+func (t *Transitions) Observe(labels, values []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, prev, _ := t.tracker.observe(Record{Labels: labels, Values: values})
+	if s == nil || prev == "" {
+		return
+	}
+	t.counts[[2]string{trimQuery(prev), trimQuery(s.ExitPage)}]++
+}
+
+// LineHandler returns a LineHandler that observes each record before passing it to next.
+func (t *Transitions) LineHandler(next LineHandler) LineHandler {
+	return func(labels, values []string, isFirst bool) (string, error) {
+		t.Observe(labels, values)
+		return next(labels, values, isFirst)
+	}
+}
+
+// Top returns the n most frequent transitions, in descending order of count.
+// An n less than 1 means all transitions.
+func (t *Transitions) Top(n int) []Transition {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	transitions := make([]Transition, 0, len(t.counts))
+	for k, v := range t.counts {
+		transitions = append(transitions, Transition{From: k[0], To: k[1], Count: v})
+	}
+	sort.Slice(transitions, func(i, j int) bool {
+		a, b := transitions[i], transitions[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+	if n > 0 && n < len(transitions) {
+		transitions = transitions[:n]
+	}
+	return transitions
+}
+
+// Matrix returns the transition counts between the n paths involved in the most transitions.
+// An n less than 1 means all paths.
+func (t *Transitions) Matrix(n int) TransitionMatrix {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	totals := map[string]int{}
+	for k, v := range t.counts {
+		totals[k[0]] += v
+		if k[1] != k[0] {
+			totals[k[1]] += v
+		}
+	}
+	paths := make([]string, 0, len(totals))
+	for path := range totals {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if totals[paths[i]] != totals[paths[j]] {
+			return totals[paths[i]] > totals[paths[j]]
+		}
+		return paths[i] < paths[j]
+	})
+	if n > 0 && n < len(paths) {
+		paths = paths[:n]
+	}
+	counts := make([][]int, len(paths))
+	for i, from := range paths {
+		counts[i] = make([]int, len(paths))
+		for j, to := range paths {
+			counts[i][j] = t.counts[[2]string{from, to}]
+		}
+	}
+	return TransitionMatrix{Paths: paths, Counts: counts}
+}
+
+// trimQuery returns the path without the query string.
+func trimQuery(path string) string {
+	path, _, _ = strings.Cut(path, "?")
+	return path
+}
@@ -0,0 +1,50 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTransitions(t *testing.T) {
+	input := strings.Join([]string{
+		`192.0.2.1 - - [10/Oct/2000:13:00:00 -0700] "GET / HTTP/1.1" 200 100 "-" "Mozilla/5.0"`,
+		`192.0.2.2 - - [10/Oct/2000:13:00:10 -0700] "GET / HTTP/1.1" 200 100 "-" "Mozilla/5.0"`,
+		`192.0.2.1 - - [10/Oct/2000:13:01:00 -0700] "GET /products?page=1 HTTP/1.1" 200 100 "-" "Mozilla/5.0"`,
+		`192.0.2.2 - - [10/Oct/2000:13:01:10 -0700] "GET /products?page=2 HTTP/1.1" 200 100 "-" "Mozilla/5.0"`,
+		`192.0.2.1 - - [10/Oct/2000:13:02:00 -0700] "GET /cart HTTP/1.1" 200 100 "-" "Mozilla/5.0"`,
+		`192.0.2.2 - - [10/Oct/2000:13:02:10 -0700] "GET / HTTP/1.1" 200 100 "-" "Mozilla/5.0"`,
+		`192.0.2.1 - - [10/Oct/2000:15:00:00 -0700] "GET /products HTTP/1.1" 200 100 "-" "Mozilla/5.0"`,
+	}, "\n")
+	tr := NewTransitions(SessionConfig{})
+	p := NewApacheCLFRegexParser(context.Background(), &bytes.Buffer{}, Option{
+		LineHandler: tr.LineHandler(JSONLineHandler),
+	})
+	if _, err := p.ParseString(input); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("top", func(t *testing.T) {
+		want := []Transition{
+			{From: "/", To: "/products", Count: 2},
+			{From: "/products", To: "/", Count: 1},
+		}
+		if got := tr.Top(2); !reflect.DeepEqual(got, want) {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+		}
+	})
+	t.Run("matrix", func(t *testing.T) {
+		want := TransitionMatrix{
+			Paths: []string{"/products", "/", "/cart"},
+			Counts: [][]int{
+				{0, 1, 1},
+				{2, 0, 0},
+				{0, 0, 0},
+			},
+		}
+		if got := tr.Matrix(0); !reflect.DeepEqual(got, want) {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+		}
+	})
+}
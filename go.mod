@@ -3,13 +3,17 @@ module github.com/nekrassov01/access-log-parser
 go 1.21
 
 require (
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/google/go-cmp v0.6.0
 	github.com/mattn/go-isatty v0.0.20
 	github.com/nekrassov01/mintab v0.0.43
+	golang.org/x/crypto v0.17.0
+	golang.org/x/sync v0.11.0
+	golang.org/x/text v0.14.0
 )
 
 require (
 	github.com/mattn/go-runewidth v0.0.15 // indirect
 	github.com/rivo/uniseg v0.2.0 // indirect
-	golang.org/x/sys v0.6.0 // indirect
+	golang.org/x/sys v0.15.0 // indirect
 )
@@ -2,8 +2,12 @@ package parser
 
 import (
 	"bytes"
+	"math"
 	"os"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/mattn/go-isatty"
 )
@@ -13,50 +17,136 @@ const size = 2024
 // JSONLineHandler serializes log lines into JSON (NDJSON) format. It keywords the line number if specified.
 // Labels and values are combined into key-value pairs, and the result is a single JSON object.
 func JSONLineHandler(labels, values []string, _ bool) (string, error) {
+	return formatJSON(labels, values, nil, nil, false), nil
+}
+
+// PrettyJSONLineHandler enhances JSONLineHandler by formatting the output for readability. It uses indentation and new lines.
+func PrettyJSONLineHandler(labels, values []string, _ bool) (string, error) {
+	return formatJSON(labels, values, nil, nil, true), nil
+}
+
+// JSONOption configures the line handler created by NewJSONLineHandler.
+type JSONOption struct {
+	Pretty   bool                 // whether to indent the output as PrettyJSONLineHandler or not
+	Types    map[string]FieldType // types of the values written as JSON numbers, booleans or RFC 3339 timestamps instead of strings
+	Location *time.Location       // zone timestamps are written in (nil means as in the logs)
+}
+
+// NewJSONLineHandler creates a JSON line handler writing the values of the fields in opt.Types as typed JSON
+// values, such as "status":200, for systems like Elasticsearch and BigQuery that map fields by type. Empty and
+// "-" values of typed fields are written as null, and values that cannot be converted are written as strings.
+// Timestamps are recognized in the same layouts as Schema and written in RFC 3339.
+func NewJSONLineHandler(opt JSONOption) LineHandler {
+	return func(labels, values []string, _ bool) (string, error) {
+		return formatJSON(labels, values, opt.Types, opt.Location, opt.Pretty), nil
+	}
+}
+
+// formatJSON formats the values as a JSON object, converting the values of the fields in types.
+func formatJSON(labels, values []string, types map[string]FieldType, loc *time.Location, pretty bool) string {
 	buf := &bytes.Buffer{}
 	buf.Grow(size)
-	buf.WriteByte('{')
+	if pretty {
+		buf.WriteString("{\n")
+	} else {
+		buf.WriteByte('{')
+	}
 	for i, value := range values {
 		if i < len(labels) {
 			if i > 0 {
 				buf.WriteByte(',')
+				if pretty {
+					buf.WriteByte('\n')
+				}
+			}
+			if pretty {
+				buf.WriteString("  ")
 			}
 			buf.WriteByte('"')
 			buf.WriteString(labels[i])
 			buf.WriteString("\":")
+			if pretty {
+				buf.WriteByte(' ')
+			}
+			if t, ok := types[labels[i]]; ok && writeTypedJSON(buf, value, t, loc) {
+				continue
+			}
 			buf.WriteByte('"')
 			writeEscapedString(buf, value)
 			buf.WriteByte('"')
 		}
 	}
-	buf.WriteByte('}')
-	return buf.String(), nil
+	if pretty {
+		buf.WriteString("\n}")
+	} else {
+		buf.WriteByte('}')
+	}
+	return buf.String()
 }
 
-// PrettyJSONLineHandler enhances JSONLineHandler by formatting the output for readability. It uses indentation and new lines.
-func PrettyJSONLineHandler(labels, values []string, _ bool) (string, error) {
+// writeTypedJSON writes the value as a JSON value of the type, and reports whether it could be converted.
+// Timestamps are converted to loc unless it is nil.
+func writeTypedJSON(buf *bytes.Buffer, value string, t FieldType, loc *time.Location) bool {
+	if t != FieldTypeString && isNullValue(value) {
+		buf.WriteString("null")
+		return true
+	}
+	switch t {
+	case FieldTypeInt:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
+		}
+		buf.WriteString(strconv.FormatInt(n, 10))
+	case FieldTypeFloat:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return false
+		}
+		buf.WriteString(strconv.FormatFloat(f, 'f', -1, 64))
+	case FieldTypeBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return false
+		}
+		buf.WriteString(strconv.FormatBool(b))
+	case FieldTypeTime:
+		s, ok := formatTime(value, loc)
+		if !ok {
+			return false
+		}
+		buf.WriteByte('"')
+		buf.WriteString(s)
+		buf.WriteByte('"')
+	default:
+		return false
+	}
+	return true
+}
+
+// KeyValuePairLineHandler converts log lines into a space-separated string of key-value pairs.
+func KeyValuePairLineHandler(labels, values []string, _ bool) (string, error) {
 	buf := &bytes.Buffer{}
 	buf.Grow(size)
-	buf.WriteString("{\n")
 	for i, value := range values {
 		if i < len(labels) {
 			if i > 0 {
-				buf.WriteString(",\n")
+				buf.WriteByte(' ')
 			}
-			buf.WriteString("  \"")
 			buf.WriteString(labels[i])
-			buf.WriteString("\": ")
+			buf.WriteByte('=')
 			buf.WriteByte('"')
 			writeEscapedString(buf, value)
 			buf.WriteByte('"')
 		}
 	}
-	buf.WriteString("\n}")
 	return buf.String(), nil
 }
 
-// KeyValuePairLineHandler converts log lines into a space-separated string of key-value pairs.
-func KeyValuePairLineHandler(labels, values []string, _ bool) (string, error) {
+// LogfmtLineHandler formats log lines as logfmt, preferred by systems such as Grafana Loki and Grafana Agent.
+// Unlike KeyValuePairLineHandler, values are quoted only when needed: if empty or containing spaces, equal
+// signs, double quotes or control characters. Characters other than those allowed in keys are replaced with underscores.
+func LogfmtLineHandler(labels, values []string, _ bool) (string, error) {
 	buf := &bytes.Buffer{}
 	buf.Grow(size)
 	for i, value := range values {
@@ -64,17 +154,54 @@ func KeyValuePairLineHandler(labels, values []string, _ bool) (string, error) {
 			if i > 0 {
 				buf.WriteByte(' ')
 			}
-			buf.WriteString(labels[i])
+			writeLogfmtKey(buf, labels[i])
 			buf.WriteByte('=')
-			buf.WriteByte('"')
-			writeEscapedString(buf, value)
-			buf.WriteByte('"')
+			if needsLogfmtQuote(value) {
+				buf.WriteByte('"')
+				writeEscapedString(buf, value)
+				buf.WriteByte('"')
+			} else {
+				buf.WriteString(value)
+			}
 		}
 	}
 	return buf.String(), nil
 }
 
-// LTSVLineHandler formats log lines as LTSV (Labeled Tab-separated Values).
+// writeLogfmtKey writes the key to the given bytes.Buffer, replacing spaces, equal signs,
+// double quotes and control characters, which would break the key=value pairs.
+func writeLogfmtKey(buf *bytes.Buffer, key string) {
+	if key == "" {
+		buf.WriteByte('_')
+		return
+	}
+	for _, r := range key {
+		if r <= ' ' || r == '=' || r == '"' || r == 0x7f {
+			buf.WriteByte('_')
+			continue
+		}
+		buf.WriteRune(r)
+	}
+}
+
+// needsLogfmtQuote reports whether the logfmt value must be quoted.
+func needsLogfmtQuote(value string) bool {
+	if value == "" {
+		return true
+	}
+	for _, r := range value {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// LTSVLineHandler formats log lines as LTSV (Labeled Tab-separated Values), so that the output can be parsed
+// again with LTSVParser and lines read with LTSVParser are written back unchanged. Following the LTSV
+// specification, characters other than alphanumerics, underscores, dots and hyphens in labels are replaced with
+// underscores, and tabs, newlines and carriage returns in values are written as \t, \n and \r. Note that LTSV
+// has no escape sequences, so LTSVParser reads them back as is rather than as the original characters.
 func LTSVLineHandler(labels, values []string, _ bool) (string, error) {
 	buf := &bytes.Buffer{}
 	buf.Grow(size)
@@ -83,28 +210,91 @@ func LTSVLineHandler(labels, values []string, _ bool) (string, error) {
 			if i > 0 {
 				buf.WriteByte('\t')
 			}
-			buf.WriteString(labels[i])
+			writeLTSVLabel(buf, labels[i])
 			buf.WriteByte(':')
 			if value == "" {
 				buf.WriteByte('-')
 			} else {
-				buf.WriteString(value)
+				writeLTSVValue(buf, value)
 			}
 		}
 	}
 	return buf.String(), nil
 }
 
+// writeLTSVLabel writes the label to the given bytes.Buffer, replacing characters not allowed in LTSV labels.
+func writeLTSVLabel(buf *bytes.Buffer, label string) {
+	for _, r := range label {
+		switch {
+		case r >= '0' && r <= '9', r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r == '_', r == '.', r == '-':
+			buf.WriteRune(r)
+		default:
+			buf.WriteByte('_')
+		}
+	}
+}
+
+// writeLTSVValue writes the value to the given bytes.Buffer while replacing
+// the characters not allowed in LTSV values (tab, newline, carriage return).
+func writeLTSVValue(buf *bytes.Buffer, value string) {
+	if !strings.ContainsAny(value, "\t\n\r") {
+		buf.WriteString(value)
+		return
+	}
+	for _, r := range value {
+		switch r {
+		case '\t':
+			buf.WriteString("\\t")
+		case '\n':
+			buf.WriteString("\\n")
+		case '\r':
+			buf.WriteString("\\r")
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
+
 // TSVLineHandler formats log lines as TSV (Tab-separated Values).
+// The header is emitted with the first line of each source, and values are written as is.
 func TSVLineHandler(labels, values []string, isFirst bool) (string, error) {
+	return formatTSV(labels, values, isFirst, false), nil
+}
+
+// TSVOption configures the line handler created by NewTSVLineHandler.
+type TSVOption struct {
+	NoHeader   bool // whether to omit the header or not
+	HeaderOnce bool // whether to emit the header only once per handler, rather than for each source such as a zip entry, or not
+	Escape     bool // whether to escape backslashes, tabs, newlines and carriage returns in values to keep columns aligned or not
+}
+
+// NewTSVLineHandler creates a TSV line handler with the header and escaping controlled by opt.
+// With HeaderOnce, the header goes with the first line handled, so it is not suitable for concurrent
+// parsing of zip entries, where the first line handled may be written after others; use Option.HeaderOnce there.
+func NewTSVLineHandler(opt TSVOption) LineHandler {
+	var done atomic.Bool
+	return func(labels, values []string, isFirst bool) (string, error) {
+		header := isFirst
+		switch {
+		case opt.NoHeader:
+			header = false
+		case opt.HeaderOnce:
+			header = done.CompareAndSwap(false, true)
+		}
+		return formatTSV(labels, values, header, opt.Escape), nil
+	}
+}
+
+// formatTSV formats the values as a TSV line, preceded by a header line of the labels if header is true.
+func formatTSV(labels, values []string, header, escape bool) string {
 	buf := &bytes.Buffer{}
 	buf.Grow(size)
-	if isFirst {
-		header := strings.Join(labels, "\t")
+	if header {
+		h := strings.Join(labels, "\t")
 		if isatty.IsTerminal(os.Stdout.Fd()) {
-			header = "\033[1;37m" + header + "\033[0m"
+			h = "\033[1;37m" + h + "\033[0m"
 		}
-		buf.WriteString(header)
+		buf.WriteString(h)
 		buf.WriteByte('\n')
 	}
 	for i, value := range values {
@@ -112,14 +302,105 @@ func TSVLineHandler(labels, values []string, isFirst bool) (string, error) {
 			if i > 0 {
 				buf.WriteByte('\t')
 			}
-			if value == "" {
+			switch {
+			case value == "":
 				buf.WriteByte('-')
-			} else {
+			case escape:
+				writeEscapedTSV(buf, value)
+			default:
 				buf.WriteString(value)
 			}
 		}
 	}
-	return buf.String(), nil
+	return buf.String()
+}
+
+// writeEscapedTSV writes the string s to the given bytes.Buffer while escaping
+// the characters that break TSV columns and lines (backslash, tab, newline, carriage return).
+func writeEscapedTSV(buf *bytes.Buffer, s string) {
+	if !strings.ContainsAny(s, "\\\t\n\r") {
+		buf.WriteString(s)
+		return
+	}
+	for _, r := range s {
+		switch r {
+		case '\\':
+			buf.WriteString("\\\\")
+		case '\t':
+			buf.WriteString("\\t")
+		case '\n':
+			buf.WriteString("\\n")
+		case '\r':
+			buf.WriteString("\\r")
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
+
+// CSVLineHandler formats log lines as RFC 4180 CSV (Comma-separated Values). The header is emitted with
+// the first line of each source, and fields containing commas, double quotes, carriage returns or newlines
+// are enclosed in double quotes, with double quotes doubled.
+func CSVLineHandler(labels, values []string, isFirst bool) (string, error) {
+	return formatCSV(labels, values, isFirst), nil
+}
+
+// CSVOption configures the line handler created by NewCSVLineHandler.
+type CSVOption struct {
+	NoHeader   bool // whether to omit the header or not
+	HeaderOnce bool // whether to emit the header only once per handler, rather than for each source such as a zip entry, or not
+}
+
+// NewCSVLineHandler creates a CSV line handler with the header controlled by opt.
+// As with NewTSVLineHandler, HeaderOnce is not suitable for concurrent parsing of zip entries.
+func NewCSVLineHandler(opt CSVOption) LineHandler {
+	var done atomic.Bool
+	return func(labels, values []string, isFirst bool) (string, error) {
+		header := isFirst
+		switch {
+		case opt.NoHeader:
+			header = false
+		case opt.HeaderOnce:
+			header = done.CompareAndSwap(false, true)
+		}
+		return formatCSV(labels, values, header), nil
+	}
+}
+
+// formatCSV formats the values as a CSV record, preceded by a header record of the labels if header is true.
+func formatCSV(labels, values []string, header bool) string {
+	buf := &bytes.Buffer{}
+	buf.Grow(size)
+	if header {
+		for i, label := range labels {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCSVField(buf, label)
+		}
+		buf.WriteByte('\n')
+	}
+	for i, value := range values {
+		if i < len(labels) {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCSVField(buf, value)
+		}
+	}
+	return buf.String()
+}
+
+// writeCSVField writes the field to the given bytes.Buffer, quoting it if it contains
+// characters that break CSV fields and records (comma, double quote, carriage return, newline).
+func writeCSVField(buf *bytes.Buffer, s string) {
+	if !strings.ContainsAny(s, ",\"\r\n") {
+		buf.WriteString(s)
+		return
+	}
+	buf.WriteByte('"')
+	buf.WriteString(strings.ReplaceAll(s, `"`, `""`))
+	buf.WriteByte('"')
 }
 
 // EscapedString writes the string s to the given bytes.Buffer while properly escaping
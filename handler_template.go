@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs are the helpers available in the templates of TemplateLineHandler, named and ordered after
+// their counterparts in sprig, so that the value piped in comes last.
+var templateFuncs = template.FuncMap{
+	"upper":      strings.ToUpper,
+	"lower":      strings.ToLower,
+	"trim":       strings.TrimSpace,
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+	"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+	"repeat":     func(n int, s string) string { return strings.Repeat(s, max(n, 0)) },
+	"quote":      strconv.Quote,
+	"default": func(def, s string) string {
+		if isNullValue(s) {
+			return def
+		}
+		return s
+	},
+	"trunc": func(n int, s string) string {
+		if r := []rune(s); n >= 0 && n < len(r) {
+			return string(r[:n])
+		}
+		return s
+	},
+	"padLeft": func(n int, s string) string {
+		return fmt.Sprintf("%*s", n, s)
+	},
+	"padRight": func(n int, s string) string {
+		return fmt.Sprintf("%-*s", n, s)
+	},
+	"date": func(layout, s string) string {
+		if t, ok := parseTime(s); ok {
+			return t.Format(layout)
+		}
+		return s
+	},
+}
+
+// TemplateLineHandler creates a line handler formatting each line with a text/template, such as
+// "{{.time}} {{.remote_ip}} -> {{.status}}", for one-off layouts of reports. The fields are accessed by label,
+// or with index for labels that are not identifiers, such as {{index . "x-forwarded-for"}}, and fields missing
+// from a line are empty. Besides the builtins, the template may use helpers named after those of sprig:
+// upper, lower, trim, trimPrefix, trimSuffix, replace, contains, hasPrefix, hasSuffix, repeat, quote,
+// default (for empty and "-" values), trunc, padLeft, padRight and date (formatting timestamps with a Go layout).
+func TemplateLineHandler(tmpl string) (LineHandler, error) {
+	t, err := template.New("line").Funcs(templateFuncs).Option("missingkey=zero").Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", templateError, err)
+	}
+	return func(labels, values []string, _ bool) (string, error) {
+		data := make(map[string]string, len(labels))
+		for i, label := range labels {
+			data[label] = values[i]
+		}
+		b := &strings.Builder{}
+		if err := t.Execute(b, data); err != nil {
+			return "", fmt.Errorf("%s: %w", templateError, err)
+		}
+		return b.String(), nil
+	}, nil
+}
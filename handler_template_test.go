@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestTemplateLineHandler(t *testing.T) {
+	labels := []string{"time", "remote_ip", "status", "x-forwarded-for", "user"}
+	values := []string{"10/Oct/2000:13:55:36 -0700", "192.0.2.1", "200", "203.0.113.9", "-"}
+	tests := []struct {
+		name    string
+		tmpl    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "fields",
+			tmpl: "{{.time}} {{.remote_ip}} -> {{.status}}",
+			want: "10/Oct/2000:13:55:36 -0700 192.0.2.1 -> 200",
+		},
+		{
+			name: "index and missing",
+			tmpl: `{{index . "x-forwarded-for"}}[{{.missing}}]`,
+			want: "203.0.113.9[]",
+		},
+		{
+			name: "helpers",
+			tmpl: `{{date "2006-01-02T15:04:05Z07:00" .time}} {{.user | default "anonymous" | upper}} {{padLeft 5 .status}}|{{trunc 3 .remote_ip | quote}}{{if hasPrefix "2" .status}} ok{{end}}`,
+			want: `2000-10-10T13:55:36-07:00 ANONYMOUS   200|"192"` + " ok",
+		},
+		{
+			name:    "syntax error",
+			tmpl:    "{{.time",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, err := TemplateLineHandler(tt.tmpl)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got, err := handler(labels, values, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,11 @@
 package parser
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -378,6 +383,26 @@ func TestLTSVLineHandler(t *testing.T) {
 			want:    `label1:-	label2:value2`,
 			wantErr: false,
 		},
+		{
+			name: "tab and newline included",
+			args: args{
+				labels:  []string{"label1", "label2"},
+				values:  []string{"Mozilla\t5.0", "line1\r\nline2"},
+				isFirst: false,
+			},
+			want:    `label1:Mozilla\t5.0	label2:line1\r\nline2`,
+			wantErr: false,
+		},
+		{
+			name: "invalid label characters",
+			args: args{
+				labels:  []string{"request.headers.user-agent", "a:b c"},
+				values:  []string{"value1", "value2"},
+				isFirst: false,
+			},
+			want:    `request.headers.user-agent:value1	a_b_c:value2`,
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -510,3 +535,344 @@ value1	123`,
 		})
 	}
 }
+
+func TestNewTSVLineHandler(t *testing.T) {
+	labels := []string{"label1", "label2"}
+	tests := []struct {
+		name    string
+		opt     TSVOption
+		values  [][]string
+		isFirst []bool
+		want    []string
+	}{
+		{
+			name:    "default",
+			opt:     TSVOption{},
+			values:  [][]string{{"a", "b"}, {"c", "d"}, {"e", "f"}},
+			isFirst: []bool{true, false, true},
+			want:    []string{"label1\tlabel2\na\tb", "c\td", "label1\tlabel2\ne\tf"},
+		},
+		{
+			name:    "no header",
+			opt:     TSVOption{NoHeader: true},
+			values:  [][]string{{"a", "b"}, {"c", "d"}},
+			isFirst: []bool{true, true},
+			want:    []string{"a\tb", "c\td"},
+		},
+		{
+			name:    "header once",
+			opt:     TSVOption{HeaderOnce: true},
+			values:  [][]string{{"a", "b"}, {"c", "d"}, {"e", "f"}},
+			isFirst: []bool{true, false, true},
+			want:    []string{"label1\tlabel2\na\tb", "c\td", "e\tf"},
+		},
+		{
+			name:    "escape",
+			opt:     TSVOption{NoHeader: true, Escape: true},
+			values:  [][]string{{"Mozilla\t5.0", "line1\nline2\r"}, {`C:\logs`, ""}},
+			isFirst: []bool{false, false},
+			want:    []string{`Mozilla\t5.0` + "\t" + `line1\nline2\r`, `C:\\logs` + "\t-"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewTSVLineHandler(tt.opt)
+			for i, values := range tt.values {
+				got, err := h(labels, values, tt.isFirst[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != tt.want[i] {
+					t.Errorf("\ngot:\n%q\nwant:\n%q\n", got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewTSVLineHandler_zipEntries(t *testing.T) {
+	zipPath := writeZip(t, []string{"1.log", "2.log"}, []string{"a:1\tb:2\n", "a:3\tb:4\n"})
+	got := &bytes.Buffer{}
+	p := NewLTSVParser(context.Background(), got, Option{LineHandler: NewTSVLineHandler(TSVOption{HeaderOnce: true})})
+	if _, err := p.ParseZipEntries(zipPath, "*"); err != nil {
+		t.Fatal(err)
+	}
+	want := "a\tb\n1\t2\n3\t4\n"
+	if got.String() != want {
+		t.Errorf("\ngot:\n%q\nwant:\n%q\n", got.String(), want)
+	}
+}
+
+func TestLTSVLineHandler_ltsvInput(t *testing.T) {
+	input := "host:192.0.2.1\tpath:/a\\b\tstatus:200\tua:-\nhost:192.0.2.2\tpath:/c\tstatus:404\tua:curl/8.0\nhost:192.0.2.3\tpath:/d\tstatus:200\tua:Mozilla/5.0 (X11)"
+	got := &bytes.Buffer{}
+	p := NewLTSVParser(context.Background(), got, Option{Filters: []string{"status == 200"}, LineHandler: LTSVLineHandler})
+	if _, err := p.ParseString(input); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(input, "\n")
+	want := lines[0] + "\n" + lines[2] + "\n"
+	if got.String() != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.String(), want)
+	}
+}
+
+func TestLogfmtLineHandler(t *testing.T) {
+	type args struct {
+		labels  []string
+		values  []string
+		isFirst bool
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "basic",
+			args: args{
+				labels: []string{"method", "status", "path"},
+				values: []string{"GET", "200", "/index.html"},
+			},
+			want:    `method=GET status=200 path=/index.html`,
+			wantErr: false,
+		},
+		{
+			name: "quoted values",
+			args: args{
+				labels: []string{"ua", "query", "message", "empty"},
+				values: []string{"Mozilla/5.0 (X11)", "a=b", `say "hi"` + "\n", ""},
+			},
+			want:    `ua="Mozilla/5.0 (X11)" query="a=b" message="say \"hi\"\n" empty=""`,
+			wantErr: false,
+		},
+		{
+			name: "backslash included",
+			args: args{
+				labels: []string{"path"},
+				values: []string{`C:\logs`},
+			},
+			want:    `path="C:\\logs"`,
+			wantErr: false,
+		},
+		{
+			name: "invalid key characters",
+			args: args{
+				labels: []string{"user agent", "a=b", ""},
+				values: []string{"curl", "1", "2"},
+			},
+			want:    `user_agent=curl a_b=1 _=2`,
+			wantErr: false,
+		},
+		{
+			name: "more matches than fields",
+			args: args{
+				labels: []string{"label1"},
+				values: []string{"value1", "value2"},
+			},
+			want:    `label1=value1`,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LogfmtLineHandler(tt.args.labels, tt.args.values, tt.args.isFirst)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVLineHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  []string
+		values  []string
+		isFirst bool
+		want    string
+	}{
+		{
+			name:    "with header",
+			labels:  []string{"host", "status"},
+			values:  []string{"a", "200"},
+			isFirst: true,
+			want:    "host,status\na,200",
+		},
+		{
+			name:   "quoting",
+			labels: []string{"ua", "uri", "note", "empty"},
+			values: []string{"Mozilla/5.0 (X11, Linux)", `/search?q="x"`, "line1\nline2", ""},
+			want:   `"Mozilla/5.0 (X11, Linux)","/search?q=""x""","line1` + "\n" + `line2",`,
+		},
+		{
+			name:    "quoted header",
+			labels:  []string{"a,b"},
+			values:  []string{"1"},
+			isFirst: true,
+			want:    "\"a,b\"\n1",
+		},
+		{
+			name:   "more values than labels",
+			labels: []string{"a"},
+			values: []string{"1", "2"},
+			want:   "1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CSVLineHandler(tt.labels, tt.values, tt.isFirst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("\ngot:\n%q\nwant:\n%q\n", got, tt.want)
+			}
+			records, err := csv.NewReader(strings.NewReader(got)).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if last := records[len(records)-1]; !reflect.DeepEqual(last, tt.values[:len(last)]) {
+				t.Errorf("\ngot:\n%q\nwant:\n%q\n", last, tt.values)
+			}
+		})
+	}
+}
+
+func TestNewCSVLineHandler(t *testing.T) {
+	labels := []string{"label1", "label2"}
+	tests := []struct {
+		name    string
+		opt     CSVOption
+		isFirst []bool
+		want    []string
+	}{
+		{
+			name:    "default",
+			opt:     CSVOption{},
+			isFirst: []bool{true, false, true},
+			want:    []string{"label1,label2\na,b", "a,b", "label1,label2\na,b"},
+		},
+		{
+			name:    "no header",
+			opt:     CSVOption{NoHeader: true},
+			isFirst: []bool{true, true},
+			want:    []string{"a,b", "a,b"},
+		},
+		{
+			name:    "header once",
+			opt:     CSVOption{HeaderOnce: true},
+			isFirst: []bool{true, false, true},
+			want:    []string{"label1,label2\na,b", "a,b", "a,b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewCSVLineHandler(tt.opt)
+			for i, isFirst := range tt.isFirst {
+				got, err := h(labels, []string{"a", "b"}, isFirst)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != tt.want[i] {
+					t.Errorf("\ngot:\n%q\nwant:\n%q\n", got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewJSONLineHandler(t *testing.T) {
+	labels := []string{"status", "size", "ratio", "cached", "time", "method", "user"}
+	types := map[string]FieldType{
+		"status": FieldTypeInt,
+		"size":   FieldTypeInt,
+		"ratio":  FieldTypeFloat,
+		"cached": FieldTypeBool,
+		"time":   FieldTypeTime,
+		"user":   FieldTypeString,
+	}
+	tests := []struct {
+		name   string
+		opt    JSONOption
+		values []string
+		want   string
+	}{
+		{
+			name:   "typed",
+			opt:    JSONOption{Types: types},
+			values: []string{"200", "113", "0.25", "true", "10/Oct/2000:13:55:36 -0700", "GET", "-"},
+			want:   `{"status":200,"size":113,"ratio":0.25,"cached":true,"time":"2000-10-10T13:55:36-07:00","method":"GET","user":"-"}`,
+		},
+		{
+			name:   "null and unconvertible",
+			opt:    JSONOption{Types: types},
+			values: []string{"abc", "-", "NaN", "", "yesterday", "GET", ""},
+			want:   `{"status":"abc","size":null,"ratio":"NaN","cached":null,"time":"yesterday","method":"GET","user":""}`,
+		},
+		{
+			name:   "pretty",
+			opt:    JSONOption{Pretty: true, Types: map[string]FieldType{"status": FieldTypeInt}},
+			values: []string{"200", "113"},
+			want:   "{\n  \"status\": 200,\n  \"size\": \"113\"\n}",
+		},
+		{
+			name:   "untyped",
+			opt:    JSONOption{},
+			values: []string{"200", "113"},
+			want:   `{"status":"200","size":"113"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewJSONLineHandler(tt.opt)(labels, tt.values, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOption_Types(t *testing.T) {
+	input := "status:200\tsize:113\tpath:/\nstatus:404\tsize:-\tpath:/x\n"
+	tests := []struct {
+		name    string
+		handler LineHandler
+		want    string
+	}{
+		{
+			name:    "json",
+			handler: nil,
+			want:    "{\"no\":1,\"status\":200,\"size\":113,\"path\":\"/\"}\n{\"no\":2,\"status\":404,\"size\":null,\"path\":\"/x\"}\n",
+		},
+		{
+			name:    "ltsv",
+			handler: LTSVLineHandler,
+			want:    "no:1\tstatus:200\tsize:113\tpath:/\nno:2\tstatus:404\tsize:-\tpath:/x\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			opt := Option{
+				LineNumber:  true,
+				LineHandler: tt.handler,
+				Types:       map[string]FieldType{"no": FieldTypeInt, "status": FieldTypeInt, "size": FieldTypeInt},
+			}
+			if _, err := NewLTSVParser(context.Background(), buf, opt).ParseString(input); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", buf.String(), tt.want)
+			}
+		})
+	}
+}
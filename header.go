@@ -0,0 +1,155 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// headerState is the header shared by the sources written to the same output with Option.HeaderOnce, such as
+// files parsed in turn by a parser and zip entries, and the labels compared with Option.CheckLabels. It is set
+// by the constructors of parsers.
+type headerState struct {
+	mu      sync.Mutex
+	labels  []string // labels of the header, those of the first record handled
+	header  string   // header written by the line handler for the labels
+	written bool
+	set     chan struct{} // closed when the labels are set, for sources waiting for them
+	first   []string      // labels of the first source compared with Option.CheckLabels
+}
+
+// align returns the values of a record ordered as the labels of the header, setting the header with the first
+// record. Records whose labels differ from those of the header are an error unless reconcile is true, in which
+// case the values of the labels of the header are taken from the record, "-" if the record lacks them.
+// For zip entries parsed concurrently, turn is closed when the previous entries are written; an entry waits
+// for it before setting the header, so that the header is that of the first entry in the order of the output.
+func (h *headerState) align(ctx context.Context, turn <-chan struct{}, labels, values []string, handler LineHandler, reconcile bool) ([]string, []string, error) {
+	h.mu.Lock()
+	if h.labels == nil && turn != nil {
+		if h.set == nil {
+			h.set = make(chan struct{})
+		}
+		set := h.set
+		h.mu.Unlock()
+		select {
+		case <-set:
+		case <-turn:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		h.mu.Lock()
+	}
+	defer h.mu.Unlock()
+	if h.labels == nil {
+		withHeader, err := handler(labels, values, true)
+		if err != nil {
+			return nil, nil, err
+		}
+		line, err := handler(labels, values, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		h.labels, h.header = slices.Clone(labels), strings.TrimSuffix(withHeader, line)
+		if h.set != nil {
+			close(h.set)
+		}
+		return labels, values, nil
+	}
+	if slices.Equal(labels, h.labels) {
+		return h.labels, values, nil
+	}
+	if !reconcile {
+		return nil, nil, fmt.Errorf("%s: labels %v differ from the labels of the header %v", headerError, labels, h.labels)
+	}
+	vs := make([]string, len(h.labels))
+	for i, label := range h.labels {
+		vs[i] = "-"
+		if j := slices.Index(labels, label); j >= 0 && j < len(values) {
+			vs[i] = values[j]
+		}
+	}
+	return h.labels, vs, nil
+}
+
+// writer returns a writer that writes the header before the first bytes written to w through any writer of
+// the state.
+func (h *headerState) writer(w io.Writer) io.Writer {
+	return &headerWriter{Writer: w, h: h}
+}
+
+// reset forgets the header, so that it is written again to a new output.
+func (h *headerState) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.labels, h.header, h.written, h.set, h.first = nil, "", false, nil, nil
+}
+
+// headerWriter is the writer returned by headerState.writer.
+type headerWriter struct {
+	io.Writer
+	h *headerState
+}
+
+// Write writes the header first if it has not been written yet.
+func (w *headerWriter) Write(p []byte) (int, error) {
+	w.h.mu.Lock()
+	header := ""
+	if !w.h.written && w.h.labels != nil {
+		header, w.h.written = w.h.header, true
+	}
+	w.h.mu.Unlock()
+	if header != "" {
+		if _, err := io.WriteString(w.Writer, header); err != nil {
+			return 0, err
+		}
+	}
+	return w.Writer.Write(p)
+}
+
+// Flush flushes the underlying writer if it supports flushing.
+func (w *headerWriter) Flush() error {
+	return flushOutput(w.Writer)
+}
+
+// compare compares the labels of the first record of a source with those of the first source compared, and
+// returns the difference, or nil if they agree or the source has no records.
+func (h *headerState) compare(source string, labels []string) *LabelMismatch {
+	if labels == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.first == nil {
+		h.first = labels
+		return nil
+	}
+	if slices.Equal(labels, h.first) {
+		return nil
+	}
+	m := &LabelMismatch{Source: source, Labels: labels, Want: h.first}
+	for _, label := range h.first {
+		if !slices.Contains(labels, label) {
+			m.Missing = append(m.Missing, label)
+		}
+	}
+	for _, label := range labels {
+		if !slices.Contains(h.first, label) {
+			m.Extra = append(m.Extra, label)
+		}
+	}
+	return m
+}
+
+// compareLabels adds the difference of the labels of the first record of the source from those of the first
+// source written by the parser to the result, if Option.CheckLabels is set.
+func compareLabels(r *Result, source string, labels []string, opt Option) {
+	if !opt.CheckLabels || opt.header == nil {
+		return
+	}
+	if m := opt.header.compare(source, labels); m != nil {
+		r.LabelMismatches = append(r.LabelMismatches, *m)
+	}
+}
@@ -0,0 +1,133 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOption_HeaderOnce(t *testing.T) {
+	sources := []string{
+		"host:a\tstatus:200\nhost:b\tstatus:404\n",
+		"host:c\tstatus:500\n",
+		"status:302\thost:d\tsize:10\n",
+	}
+	tests := []struct {
+		name    string
+		opt     Option
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "per source",
+			opt:  Option{LineHandler: TSVLineHandler},
+			want: "host\tstatus\na\t200\nb\t404\nhost\tstatus\nc\t500\nstatus\thost\tsize\n302\td\t10\n",
+		},
+		{
+			name:    "once",
+			opt:     Option{LineHandler: TSVLineHandler, HeaderOnce: true},
+			want:    "host\tstatus\na\t200\nb\t404\nc\t500\n",
+			wantErr: true,
+		},
+		{
+			name: "reconciled",
+			opt:  Option{LineHandler: CSVLineHandler, HeaderOnce: true, ReconcileLabels: true},
+			want: "host,status\na,200\nb,404\nc,500\nd,302\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			p := NewLTSVParser(context.Background(), buf, tt.opt)
+			var err error
+			for _, s := range sources {
+				if _, err = p.ParseString(s); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestOption_HeaderOnce_zip(t *testing.T) {
+	var outputs []string
+	for _, concurrency := range []int{1, 4} {
+		buf := &bytes.Buffer{}
+		opt := Option{LineHandler: TSVLineHandler, HeaderOnce: true, ReconcileLabels: true, Concurrency: concurrency}
+		if _, err := NewLTSVParser(context.Background(), buf, opt).ParseZipEntries("testdata/sample_ltsv.zip", "*"); err != nil {
+			t.Fatal(err)
+		}
+		header, _, _ := strings.Cut(buf.String(), "\n")
+		if n := strings.Count(buf.String(), header+"\n"); n != 1 {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", n, 1)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+			if strings.Count(line, "\t") != strings.Count(header, "\t") {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", line, header)
+			}
+		}
+		outputs = append(outputs, buf.String())
+	}
+	if outputs[0] != outputs[1] {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", outputs[1], outputs[0])
+	}
+}
+
+func TestOption_CheckLabels(t *testing.T) {
+	p := NewLTSVParser(context.Background(), &bytes.Buffer{}, Option{CheckLabels: true})
+	var got []LabelMismatch
+	for _, s := range []string{
+		"host:a\tstatus:200\n",
+		"status:404\thost:b\n",
+		"foo\n",
+		"host:c\tsize:10\n",
+		"host:d\tstatus:500\n",
+	} {
+		r, err := p.ParseString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r.LabelMismatches...)
+	}
+	want := []LabelMismatch{
+		{Labels: []string{"status", "host"}, Want: []string{"host", "status"}},
+		{Labels: []string{"host", "size"}, Want: []string{"host", "status"}, Missing: []string{"status"}, Extra: []string{"size"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func TestOption_CheckLabels_zip(t *testing.T) {
+	path := writeZip(t, []string{"a.log", "b.log", "c.log", "d.log"}, []string{
+		"host:a\tstatus:200\n",
+		"host:b\tstatus:404\n",
+		"host:c\n",
+		"host:d\tstatus:500\tsize:10\n",
+	})
+	want := []LabelMismatch{
+		{Source: "c.log", Labels: []string{"host"}, Want: []string{"host", "status"}, Missing: []string{"status"}},
+		{Source: "d.log", Labels: []string{"host", "status", "size"}, Want: []string{"host", "status"}, Extra: []string{"size"}},
+	}
+	for _, concurrency := range []int{1, 4} {
+		opt := Option{CheckLabels: true, Concurrency: concurrency}
+		r, err := NewLTSVParser(context.Background(), &bytes.Buffer{}, opt).ParseZipEntries(path, "*")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(r.LabelMismatches, want) {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", r.LabelMismatches, want)
+		}
+		if !strings.Contains(r.String(), "Mismatch  : c.log") {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", r.String(), "c.log")
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+)
+
+// indexFileSuffix is the suffix of the index file path for a log file.
+const indexFileSuffix = ".idx"
+
+// Index is a lightweight index of a parsed input, recording the byte offsets of sampled lines together with
+// the values of key fields such as time and status. Once built over an immutable file, subsequent extractions
+// can seek directly to the relevant regions instead of scanning from the beginning. Offsets are relative to the
+// (decompressed) input, so an index should be built per file rather than for zip archives. Lines are recorded
+// regardless of filters, so Option.Pushdown has no effect while indexing.
+type Index struct {
+	Keys     []string     `json:"keys"`     // Labels of the key fields whose values are recorded.
+	Interval int          `json:"interval"` // Minimum number of lines between entries.
+	Entries  []IndexEntry `json:"entries"`  // Recorded entries in input order.
+	last     int          // line number of the last recorded entry
+}
+
+// IndexEntry is a sampled line in an Index.
+type IndexEntry struct {
+	Line   int      `json:"line"`   // Line number of the sampled line.
+	Offset int64    `json:"offset"` // Byte offset at which the sampled line starts.
+	Values []string `json:"values"` // Values of the key fields, in the order of Keys.
+}
+
+// NewIndex creates an empty index recording the values of keys for a line at least every interval lines.
+// An interval less than 1 means that every line is recorded.
+func NewIndex(keys []string, interval int) (*Index, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s: no keys provided", indexError)
+	}
+	return &Index{
+		Keys:     keys,
+		Interval: max(interval, 1),
+		Entries:  make([]IndexEntry, 0),
+	}, nil
+}
+
+// LoadIndex loads the index saved at path.
+func LoadIndex(path string) (*Index, error) {
+	if path == "" {
+		return nil, fmt.Errorf(emptyPathError)
+	}
+	b, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", openFileError, err)
+	}
+	x := &Index{}
+	if err := json.Unmarshal(b, x); err != nil {
+		return nil, fmt.Errorf("%s: %w", indexError, err)
+	}
+	if len(x.Keys) == 0 {
+		return nil, fmt.Errorf("%s: %w", indexError, errors.New("no keys recorded"))
+	}
+	for _, e := range x.Entries {
+		if len(e.Values) != len(x.Keys) {
+			return nil, fmt.Errorf("%s: entry at line %d has %d values for %d keys", indexError, e.Line, len(e.Values), len(x.Keys))
+		}
+	}
+	if n := len(x.Entries); n > 0 {
+		x.last = x.Entries[n-1].Line
+	}
+	return x, nil
+}
+
+// Save writes the index to path, so that it can be loaded with LoadIndex. The file is replaced atomically.
+func (x *Index) Save(path string) error {
+	if path == "" {
+		return fmt.Errorf(emptyPathError)
+	}
+	b, err := json.Marshal(x)
+	if err != nil {
+		return fmt.Errorf("%s: %w", indexError, err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(filepath.Clean(tmp), b, 0o600); err != nil {
+		return fmt.Errorf("%s: %w", openFileError, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// add records the line if at least Interval lines have passed since the last entry.
+// Lines missing some of the keys are not recorded.
+func (x *Index) add(line int, offset int64, labels, values []string) {
+	if len(x.Entries) > 0 && line-x.last < x.Interval {
+		return
+	}
+	vs := make([]string, len(x.Keys))
+	for i, key := range x.Keys {
+		j := slices.Index(labels, key)
+		if j < 0 {
+			return
+		}
+		vs[i] = values[j]
+	}
+	x.Entries = append(x.Entries, IndexEntry{Line: line, Offset: offset, Values: vs})
+	x.last = line
+}
+
+// IndexPath returns the path of the index file for the log file at path, which ParseTimeRangeIndexed
+// of each parser loads. Save an index built over the file to this path to enable time range extraction.
+func IndexPath(path string) string {
+	return path + indexFileSuffix
+}
+
+// timeWindow restricts parsing to the lines within a time range, starting from a position in the input
+// located with an index. It is set by ParseTimeRangeIndexed.
+type timeWindow struct {
+	label  string    // label of the time field
+	from   time.Time // inclusive start of the time range
+	to     time.Time // inclusive end of the time range
+	line   int       // number of lines before the start position
+	offset int64     // byte offset of the start position
+	size   int64     // number of bytes from the start position that may contain the range (-1 means until EOF)
+}
+
+// contains reports whether the time field of the record is within the time range.
+// Records without the time field, or with a value in an unknown layout, are not contained.
+func (w *timeWindow) contains(labels, values []string) bool {
+	i := slices.Index(labels, w.label)
+	if i < 0 {
+		return false
+	}
+	t, ok := parseTime(values[i])
+	return ok && !t.Before(w.from) && !t.After(w.to)
+}
+
+// window binary-searches the entries for the region of the input that may contain lines between from and to.
+// The first key is regarded as the time field, and the entries must be sorted by it as in time-ordered logs.
+func (x *Index) window(from, to time.Time) (*timeWindow, error) {
+	if to.Before(from) {
+		return nil, fmt.Errorf("%s: end of time range before start", indexError)
+	}
+	times := make([]time.Time, len(x.Entries))
+	for i, e := range x.Entries {
+		t, ok := parseTime(e.Values[0])
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown time layout at line %d: %q", indexError, e.Line, e.Values[0])
+		}
+		if i > 0 && t.Before(times[i-1]) {
+			return nil, fmt.Errorf("%s: entries not sorted by time at line %d", indexError, e.Line)
+		}
+		times[i] = t
+	}
+	w := &timeWindow{label: x.Keys[0], from: from, to: to, size: -1}
+	// Lines between the last entry before from and the first entry at or after from may be in the range.
+	if i := sort.Search(len(times), func(i int) bool { return !times[i].Before(from) }); i > 0 {
+		w.line = x.Entries[i-1].Line - 1
+		w.offset = x.Entries[i-1].Offset
+	}
+	// Lines from the first entry after to are out of the range.
+	if i := sort.Search(len(times), func(i int) bool { return times[i].After(to) }); i < len(times) {
+		w.size = x.Entries[i].Offset - w.offset
+	}
+	return w, nil
+}
+
+// parseTime parses the value in one of the layouts recognized when detecting FieldTypeTime.
+func parseTime(v string) (time.Time, bool) {
+	if c, ok := parseCLFTime(v); ok {
+		return c.time(), true
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
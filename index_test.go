@@ -0,0 +1,326 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewIndex(t *testing.T) {
+	tests := []struct {
+		name         string
+		keys         []string
+		interval     int
+		wantInterval int
+		wantErr      bool
+	}{
+		{
+			name:         "basic",
+			keys:         []string{"time"},
+			interval:     100,
+			wantInterval: 100,
+		},
+		{
+			name:         "every line",
+			keys:         []string{"time", "status"},
+			interval:     0,
+			wantInterval: 1,
+		},
+		{
+			name:    "no keys",
+			keys:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewIndex(tt.keys, tt.interval)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Interval != tt.wantInterval {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Interval, tt.wantInterval)
+			}
+		})
+	}
+}
+
+func TestIndex(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		interval int
+		opt      Option
+		want     []IndexEntry
+	}{
+		{
+			name:     "every line",
+			input:    "time:1\tstatus:200\ninvalid\ntime:2\tstatus:404\ntime:3\nstatus:500\ntime:4\tstatus:200\ntime:5\tstatus:503",
+			interval: 1,
+			want: []IndexEntry{
+				{Line: 1, Offset: 0, Values: []string{"1", "200"}},
+				{Line: 3, Offset: 26, Values: []string{"2", "404"}},
+				{Line: 6, Offset: 62, Values: []string{"4", "200"}},
+				{Line: 7, Offset: 80, Values: []string{"5", "503"}},
+			},
+		},
+		{
+			name:     "interval",
+			input:    "time:1\tstatus:200\ninvalid\ntime:2\tstatus:404\ntime:3\nstatus:500\ntime:4\tstatus:200\ntime:5\tstatus:503",
+			interval: 3,
+			want: []IndexEntry{
+				{Line: 1, Offset: 0, Values: []string{"1", "200"}},
+				{Line: 6, Offset: 62, Values: []string{"4", "200"}},
+			},
+		},
+		{
+			name:     "independent of filters and labels",
+			input:    "time:1\tstatus:200\ntime:2\tstatus:404\ntime:3\tstatus:200",
+			interval: 1,
+			opt:      Option{Labels: []string{"time"}, Filters: []string{"status == 200"}, Pushdown: true},
+			want: []IndexEntry{
+				{Line: 1, Offset: 0, Values: []string{"1", "200"}},
+				{Line: 2, Offset: 18, Values: []string{"2", "404"}},
+				{Line: 3, Offset: 36, Values: []string{"3", "200"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x, err := NewIndex([]string{"time", "status"}, tt.interval)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opt := tt.opt
+			opt.Index = x
+			if _, err := NewLTSVParser(context.Background(), &bytes.Buffer{}, opt).ParseString(tt.input); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(x.Entries, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", x.Entries, tt.want)
+			}
+			for _, e := range x.Entries {
+				if !strings.HasPrefix(tt.input[e.Offset:], "time:"+e.Values[0]+"\t") {
+					t.Errorf("offset %d does not point to line %d", e.Offset, e.Line)
+				}
+			}
+		})
+	}
+}
+
+func TestIndex_Save(t *testing.T) {
+	dir := t.TempDir()
+	x, err := NewIndex([]string{"time"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewLTSVParser(context.Background(), &bytes.Buffer{}, Option{Index: x}).ParseString("time:1\ntime:2"); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "index.json")
+	if err := x.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, x) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, x)
+	}
+	if err := x.Save(""); err == nil {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "error")
+	}
+}
+
+func TestLoadIndex(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			path:    write("valid.json", `{"keys":["time"],"interval":1,"entries":[{"line":1,"offset":0,"values":["1"]}]}`),
+			wantErr: false,
+		},
+		{
+			name:    "empty path",
+			path:    "",
+			wantErr: true,
+		},
+		{
+			name:    "not exist",
+			path:    filepath.Join(dir, "not_exist.json"),
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			path:    write("invalid.json", "invalid"),
+			wantErr: true,
+		},
+		{
+			name:    "no keys",
+			path:    write("no_keys.json", `{"keys":[],"interval":1,"entries":[]}`),
+			wantErr: true,
+		},
+		{
+			name:    "values mismatch",
+			path:    write("mismatch.json", `{"keys":["time","status"],"interval":1,"entries":[{"line":1,"offset":0,"values":["1"]}]}`),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadIndex(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseTimeRangeIndexed(t *testing.T) {
+	dir := t.TempDir()
+	b := &strings.Builder{}
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(b, "time:2024-01-01 00:00:%02d\tstatus:%d\n", i, 200+i)
+	}
+	plain := filepath.Join(dir, "access.log")
+	if err := os.WriteFile(plain, []byte(b.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	gz := filepath.Join(dir, "access.log.gz")
+	f, err := os.Create(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := gzip.NewWriter(f)
+	if _, err := g.Write([]byte(b.String())); err != nil {
+		t.Fatal(err)
+	}
+	g.Close()
+	f.Close()
+	for _, path := range []string{plain, gz} {
+		x, err := NewIndex([]string{"time"}, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := NewLTSVParser(context.Background(), io.Discard, Option{Index: x})
+		parse := p.ParseFile
+		if path == gz {
+			parse = p.ParseGzip
+		}
+		if _, err := parse(path); err != nil {
+			t.Fatal(err)
+		}
+		if err := x.Save(IndexPath(path)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ts := func(sec int) time.Time {
+		return time.Date(2024, 1, 1, 0, 0, sec, 0, time.UTC)
+	}
+	tests := []struct {
+		name      string
+		path      string
+		from      time.Time
+		to        time.Time
+		want      string
+		wantTotal int
+		wantErr   bool
+	}{
+		{
+			name:      "plain",
+			path:      plain,
+			from:      ts(4),
+			to:        ts(5),
+			want:      "{\"no\":\"5\",\"time\":\"2024-01-01 00:00:04\",\"status\":\"204\"}\n{\"no\":\"6\",\"time\":\"2024-01-01 00:00:05\",\"status\":\"205\"}\n",
+			wantTotal: 3,
+		},
+		{
+			name:      "gzip",
+			path:      gz,
+			from:      ts(4),
+			to:        ts(5),
+			want:      "{\"no\":\"5\",\"time\":\"2024-01-01 00:00:04\",\"status\":\"204\"}\n{\"no\":\"6\",\"time\":\"2024-01-01 00:00:05\",\"status\":\"205\"}\n",
+			wantTotal: 3,
+		},
+		{
+			name:      "head",
+			path:      plain,
+			from:      ts(0),
+			to:        ts(0),
+			want:      "{\"no\":\"1\",\"time\":\"2024-01-01 00:00:00\",\"status\":\"200\"}\n",
+			wantTotal: 3,
+		},
+		{
+			name:      "tail",
+			path:      plain,
+			from:      ts(9),
+			to:        ts(30),
+			want:      "{\"no\":\"10\",\"time\":\"2024-01-01 00:00:09\",\"status\":\"209\"}\n",
+			wantTotal: 4,
+		},
+		{
+			name:      "out of range",
+			path:      plain,
+			from:      ts(30),
+			to:        ts(40),
+			want:      "",
+			wantTotal: 1,
+		},
+		{
+			name:    "reversed range",
+			path:    plain,
+			from:    ts(5),
+			to:      ts(4),
+			wantErr: true,
+		},
+		{
+			name:    "no index",
+			path:    filepath.Join(dir, "not_indexed.log"),
+			from:    ts(0),
+			to:      ts(1),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := &bytes.Buffer{}
+			p := NewLTSVParser(context.Background(), got, Option{LineNumber: true})
+			r, err := p.ParseTimeRangeIndexed(tt.path, tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.String() != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.String(), tt.want)
+			}
+			if r.Total != tt.wantTotal {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", r.Total, tt.wantTotal)
+			}
+		})
+	}
+}
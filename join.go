@@ -0,0 +1,166 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// dimensionError is the error message prefix for dimension tables that cannot be loaded.
+const dimensionError = "invalid dimension table"
+
+// DimensionTable is a small in-memory table joined to records by WithJoin, such as bucket to cost center
+// or host to service.
+type DimensionTable struct {
+	key     string
+	columns []string
+	rows    map[string][]string
+}
+
+// NewDimensionTable creates a dimension table keyed by the column key from rows of values in the order of columns.
+func NewDimensionTable(key string, columns []string, rows [][]string) (*DimensionTable, error) {
+	k := slices.Index(columns, key)
+	if k < 0 {
+		return nil, fmt.Errorf("%s: key column %q not found", dimensionError, key)
+	}
+	t := &DimensionTable{key: key, rows: make(map[string][]string, len(rows))}
+	for i, column := range columns {
+		if i != k {
+			t.columns = append(t.columns, column)
+		}
+	}
+	for n, row := range rows {
+		if len(row) != len(columns) {
+			return nil, fmt.Errorf("%s: row %d has %d values for %d columns", dimensionError, n+1, len(row), len(columns))
+		}
+		if _, ok := t.rows[row[k]]; ok {
+			return nil, fmt.Errorf("%s: duplicate key %q", dimensionError, row[k])
+		}
+		t.rows[row[k]] = append(row[:k:k], row[k+1:]...)
+	}
+	return t, nil
+}
+
+// LoadDimensionCSV reads a dimension table keyed by the column key from CSV with a header row.
+func LoadDimensionCSV(r io.Reader, key string) (*DimensionTable, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dimensionError, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s: no header row", dimensionError)
+	}
+	return NewDimensionTable(key, records[0], records[1:])
+}
+
+// LoadDimensionJSON reads a dimension table keyed by the field key from a JSON array of objects, or a sequence of
+// objects such as NDJSON. Columns are the fields in order of first appearance. Strings are taken as is, null and
+// missing fields as empty, and other values as their JSON text.
+func LoadDimensionJSON(r io.Reader, key string) (*DimensionTable, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dimensionError, err)
+	}
+	b = bytes.TrimSpace(b)
+	dec := json.NewDecoder(bytes.NewReader(b))
+	array := bytes.HasPrefix(b, []byte("["))
+	if array {
+		if _, err := dec.Token(); err != nil {
+			return nil, fmt.Errorf("%s: %w", dimensionError, err)
+		}
+	}
+	var columns []string
+	var objects []map[string]string
+	for dec.More() {
+		keys, raw, err := decodeOrderedObject(dec)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", dimensionError, err)
+		}
+		obj := make(map[string]string, len(raw))
+		for _, k := range keys {
+			if !slices.Contains(columns, k) {
+				columns = append(columns, k)
+			}
+			if obj[k], err = jsonText(raw[k]); err != nil {
+				return nil, fmt.Errorf("%s: %w", dimensionError, err)
+			}
+		}
+		objects = append(objects, obj)
+	}
+	rows := make([][]string, len(objects))
+	for i, obj := range objects {
+		if _, ok := obj[key]; !ok {
+			return nil, fmt.Errorf("%s: object %d lacks key %q", dimensionError, i+1, key)
+		}
+		rows[i] = make([]string, len(columns))
+		for j, column := range columns {
+			rows[i][j] = obj[column]
+		}
+	}
+	return NewDimensionTable(key, columns, rows)
+}
+
+// decodeOrderedObject decodes the next object, and returns its keys in order of appearance along with the values.
+func decodeOrderedObject(dec *json.Decoder) ([]string, map[string]json.RawMessage, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return nil, nil, fmt.Errorf("expected object, got %v", tok)
+	}
+	m := map[string]json.RawMessage{}
+	var keys []string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		k, _ := tok.(string)
+		var v json.RawMessage
+		if err := dec.Decode(&v); err != nil {
+			return nil, nil, err
+		}
+		if _, ok := m[k]; !ok {
+			keys = append(keys, k)
+		}
+		m[k] = v
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, nil, err
+	}
+	return keys, m, nil
+}
+
+// Columns returns the columns added by joins, excluding the key.
+func (t *DimensionTable) Columns() []string {
+	return slices.Clone(t.columns)
+}
+
+// Lookup returns the values of the columns for the key, and whether the key is in the table.
+func (t *DimensionTable) Lookup(key string) ([]string, bool) {
+	values, ok := t.rows[key]
+	return values, ok
+}
+
+// WithJoin returns an Enricher that left-joins the table on the value of the field, adding the columns of the
+// table to each record, empty for records whose value is not in the table. Columns with the same label as a field
+// replace it. Records without a match can be dropped with a PostFilter on one of the columns, such as
+// "cost_center =~ .".
+func WithJoin(field string, table *DimensionTable) Enricher {
+	empty := make([]string, len(table.columns))
+	return func(r Record) (Record, error) {
+		v, _ := r.Get(field)
+		values, ok := table.Lookup(v)
+		if !ok {
+			values = empty
+		}
+		for i, column := range table.columns {
+			r = r.With(column, values[i])
+		}
+		return r, nil
+	}
+}
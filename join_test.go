@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadDimensionCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		key     string
+		columns []string
+		lookup  string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			input:   "service,host,owner\nweb,a.example.com,team-a\napi,b.example.com,team-b\n",
+			key:     "host",
+			columns: []string{"service", "owner"},
+			lookup:  "b.example.com",
+			want:    []string{"api", "team-b"},
+		},
+		{
+			name:    "key not found",
+			input:   "service,owner\nweb,team-a\n",
+			key:     "host",
+			wantErr: true,
+		},
+		{
+			name:    "duplicate key",
+			input:   "host,owner\na,team-a\na,team-b\n",
+			key:     "host",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			input:   "",
+			key:     "host",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, err := LoadDimensionCSV(strings.NewReader(tt.input), tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := table.Columns(); !reflect.DeepEqual(got, tt.columns) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.columns)
+			}
+			if got, _ := table.Lookup(tt.lookup); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadDimensionJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		columns []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "array",
+			input:   `[{"bucket":"logs","cost_center":"CC-1","shared":true},{"bucket":"assets","cost_center":"CC-2","budget":100,"note":null}]`,
+			columns: []string{"cost_center", "shared", "budget", "note"},
+			want:    []string{"CC-2", "", "100", ""},
+		},
+		{
+			name:    "ndjson",
+			input:   "{\"bucket\":\"logs\",\"cost_center\":\"CC-1\"}\n{\"bucket\":\"assets\",\"cost_center\":\"CC-2\"}\n",
+			columns: []string{"cost_center"},
+			want:    []string{"CC-2"},
+		},
+		{
+			name:    "missing key",
+			input:   `[{"cost_center":"CC-1"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "not an object",
+			input:   `[1]`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, err := LoadDimensionJSON(strings.NewReader(tt.input), "bucket")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := table.Columns(); !reflect.DeepEqual(got, tt.columns) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.columns)
+			}
+			if got, _ := table.Lookup("assets"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithJoin(t *testing.T) {
+	table, err := LoadDimensionCSV(strings.NewReader("host,service,owner\na,web,team-a\nb,api,team-b\n"), "host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := "host:a\tstatus:200\nhost:c\tstatus:500\nhost:b\tstatus:404"
+	buf := &bytes.Buffer{}
+	p := NewLTSVParser(context.Background(), buf, Option{Enrichers: []Enricher{WithJoin("host", table)}})
+	if _, err := p.ParseString(input); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		`{"host":"a","status":"200","service":"web","owner":"team-a"}`,
+		`{"host":"c","status":"500","service":"","owner":""}`,
+		`{"host":"b","status":"404","service":"api","owner":"team-b"}`,
+	}, "\n") + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
@@ -0,0 +1,214 @@
+// Package loggen provides a deterministic generator of realistic access log streams for load testing.
+// It synthesizes Amazon S3, AWS Application Load Balancer and Nginx access logs with a configurable
+// rate and cardinality, so that pipelines and the streaming mode of this module can be benchmarked
+// without production data. The same seed always produces the same stream.
+package loggen
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Format represents the log format to be generated.
+type Format int
+
+// Format defines the supported log formats.
+const (
+	FormatS3    Format = iota // Amazon S3 access log format
+	FormatALB                 // AWS Application Load Balancer access log format
+	FormatNginx               // Nginx combined log format
+)
+
+// defaultCardinality is the number of distinct clients and paths used when Config.Cardinality is not positive.
+const defaultCardinality = 100
+
+var (
+	methods    = []string{"GET", "GET", "GET", "GET", "POST", "PUT", "DELETE", "HEAD"}
+	statuses   = []int{200, 200, 200, 200, 200, 200, 200, 304, 403, 404, 500, 503}
+	userAgents = []string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148",
+		"curl/8.4.0",
+		"aws-sdk-go/1.48.0 (go1.21.4; linux; amd64)",
+		"Googlebot/2.1 (+http://www.google.com/bot.html)",
+	}
+	words = []string{"index", "images", "api", "v1", "users", "orders", "static", "assets", "logs", "reports", "search", "login"}
+	exts  = []string{".html", ".png", ".json", ".css", ".js", ""}
+)
+
+// Config defines the generator settings.
+type Config struct {
+	Format      Format    // log format to be generated
+	Seed        int64     // seed of the random source, the same seed produces the same stream
+	Rate        int       // maximum number of lines per second written by Write (0 means unlimited)
+	Cardinality int       // number of distinct clients and paths (0 means 100)
+	Start       time.Time // timestamp of the first line (zero means 2024-01-01T00:00:00Z)
+}
+
+// Generator synthesizes log lines according to Config.
+type Generator struct {
+	cfg     Config
+	rnd     *rand.Rand
+	now     time.Time
+	clients []string
+	paths   []string
+	owner   string
+}
+
+// New initializes a Generator with the given config.
+func New(cfg Config) *Generator {
+	if cfg.Cardinality <= 0 {
+		cfg.Cardinality = defaultCardinality
+	}
+	if cfg.Start.IsZero() {
+		cfg.Start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	g := &Generator{
+		cfg: cfg,
+		rnd: rand.New(rand.NewSource(cfg.Seed)),
+		now: cfg.Start,
+	}
+	g.owner = g.hex(64)
+	g.clients = make([]string, cfg.Cardinality)
+	g.paths = make([]string, cfg.Cardinality)
+	for i := 0; i < cfg.Cardinality; i++ {
+		g.clients[i] = fmt.Sprintf("192.0.%d.%d", g.rnd.Intn(256), g.rnd.Intn(254)+1)
+		g.paths[i] = g.path()
+	}
+	return g
+}
+
+// Line generates the next log line. The timestamp advances by up to one second for each line.
+func (g *Generator) Line() string {
+	g.now = g.now.Add(time.Duration(g.rnd.Intn(1000)) * time.Millisecond)
+	switch g.cfg.Format {
+	case FormatALB:
+		return g.alb()
+	case FormatNginx:
+		return g.nginx()
+	default:
+		return g.s3()
+	}
+}
+
+// Write writes n lines to w, or writes until the context is done if n is not positive.
+// Lines are paced according to Config.Rate. It returns the number of lines written.
+func (g *Generator) Write(ctx context.Context, w io.Writer, n int) (int, error) {
+	var interval time.Duration
+	if g.cfg.Rate > 0 {
+		interval = time.Second / time.Duration(g.cfg.Rate)
+	}
+	next := time.Now()
+	for i := 0; n <= 0 || i < n; i++ {
+		if interval > 0 {
+			if d := time.Until(next); d > 0 {
+				t := time.NewTimer(d)
+				select {
+				case <-ctx.Done():
+					t.Stop()
+					return i, ctx.Err()
+				case <-t.C:
+				}
+			}
+			next = next.Add(interval)
+		}
+		select {
+		case <-ctx.Done():
+			return i, ctx.Err()
+		default:
+		}
+		if _, err := fmt.Fprintln(w, g.Line()); err != nil {
+			return i, err
+		}
+	}
+	return n, nil
+}
+
+// Reader returns a reader that streams n lines, or streams until the context is done if n is not positive.
+func (g *Generator) Reader(ctx context.Context, n int) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := g.Write(ctx, pw, n)
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// s3 generates a line in the Amazon S3 access log format.
+func (g *Generator) s3() string {
+	status := g.status()
+	errorCode := "-"
+	switch status {
+	case 403:
+		errorCode = "AccessDenied"
+	case 404:
+		errorCode = "NoSuchKey"
+	case 500:
+		errorCode = "InternalError"
+	case 503:
+		errorCode = "SlowDown"
+	}
+	method := g.pick(methods)
+	bucket := fmt.Sprintf("bucket%d", g.rnd.Intn(max(g.cfg.Cardinality/10, 1)))
+	path := g.pick(g.paths)
+	key := strings.TrimPrefix(path, "/")
+	size := g.rnd.Intn(1 << 20)
+	total := g.rnd.Intn(500) + 1
+	return fmt.Sprintf(`%s %s [%s] %s %s %s REST.%s.OBJECT %s "%s /%s%s HTTP/1.1" %d %s %d %d %d %d "-" "%s" - %s SigV4 ECDHE-RSA-AES128-GCM-SHA256 AuthHeader %s.s3.us-west-1.amazonaws.com TLSv1.2 - -`,
+		g.owner, bucket, g.now.Format("02/Jan/2006:15:04:05 -0700"), g.pick(g.clients), g.owner, strings.ToUpper(g.hex(16)),
+		method, key, method, bucket, path, status, errorCode, size, size, total, total/2, g.pick(userAgents), g.hex(76), bucket)
+}
+
+// alb generates a line in the AWS Application Load Balancer access log format.
+func (g *Generator) alb() string {
+	status := g.status()
+	target := fmt.Sprintf("10.0.%d.%d:80", g.rnd.Intn(4), g.rnd.Intn(254)+1)
+	return fmt.Sprintf(`https %s app/my-loadbalancer/50dc6c495c0c9188 %s:%d %s %.3f %.3f %.3f %d %d %d %d "%s https://www.example.com:443%s HTTP/1.1" "%s" ECDHE-RSA-AES128-GCM-SHA256 TLSv1.2 arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/my-targets/73e2d6bc24d8a067 "Root=1-%08x-%s" "www.example.com" "arn:aws:acm:us-east-1:123456789012:certificate/12345678-1234-1234-1234-123456789012" 1 %s "forward" "-" "-" "%s" "%d" "-" "-"`,
+		g.now.Format("2006-01-02T15:04:05.000000Z"), g.pick(g.clients), g.rnd.Intn(64511)+1024, target,
+		g.rnd.Float64()/100, g.rnd.Float64(), g.rnd.Float64()/100, status, status, g.rnd.Intn(2048), g.rnd.Intn(1<<16),
+		g.pick(methods), g.pick(g.paths), g.pick(userAgents), g.now.Unix(), g.hex(24),
+		g.now.Add(-time.Millisecond).Format("2006-01-02T15:04:05.000000Z"), target, status)
+}
+
+// nginx generates a line in the Nginx combined log format.
+func (g *Generator) nginx() string {
+	return fmt.Sprintf(`%s - - [%s] "%s %s HTTP/1.1" %d %d "-" "%s"`,
+		g.pick(g.clients), g.now.Format("02/Jan/2006:15:04:05 -0700"), g.pick(methods), g.pick(g.paths),
+		g.status(), g.rnd.Intn(1<<16), g.pick(userAgents))
+}
+
+// status picks an HTTP status code, weighted to successful responses.
+func (g *Generator) status() int {
+	return statuses[g.rnd.Intn(len(statuses))]
+}
+
+// path generates a request path of one to three segments.
+func (g *Generator) path() string {
+	b := &strings.Builder{}
+	for i := 0; i < g.rnd.Intn(3)+1; i++ {
+		b.WriteString("/")
+		b.WriteString(g.pick(words))
+	}
+	b.WriteString(g.pick(exts))
+	return b.String()
+}
+
+// pick returns a random element of s.
+func (g *Generator) pick(s []string) string {
+	return s[g.rnd.Intn(len(s))]
+}
+
+// hex generates a random lowercase hexadecimal string of n characters.
+func (g *Generator) hex(n int) string {
+	const digits = "0123456789abcdef"
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = digits[g.rnd.Intn(len(digits))]
+	}
+	return string(b)
+}
@@ -0,0 +1,131 @@
+package loggen
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	parser "github.com/nekrassov01/access-log-parser"
+)
+
+func TestGenerator_Line(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    Format
+		newParser func(ctx context.Context, w io.Writer, opt parser.Option) *parser.RegexParser
+	}{
+		{
+			name:      "s3",
+			format:    FormatS3,
+			newParser: parser.NewS3RegexParser,
+		},
+		{
+			name:      "alb",
+			format:    FormatALB,
+			newParser: parser.NewALBRegexParser,
+		},
+		{
+			name:      "nginx",
+			format:    FormatNginx,
+			newParser: parser.NewApacheCLFRegexParser,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			b := &bytes.Buffer{}
+			if _, err := New(Config{Format: tt.format, Seed: 1}).Write(ctx, b, 1000); err != nil {
+				t.Fatal(err)
+			}
+			r, err := tt.newParser(ctx, io.Discard, parser.Option{}).Parse(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Matched != 1000 {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n%v\n", r.Matched, 1000, r.Errors)
+			}
+		})
+	}
+}
+
+func TestGenerator_deterministic(t *testing.T) {
+	lines := func(seed int64) []string {
+		g := New(Config{Format: FormatNginx, Seed: seed, Cardinality: 5})
+		s := make([]string, 10)
+		for i := range s {
+			s[i] = g.Line()
+		}
+		return s
+	}
+	if a, b := lines(42), lines(42); !reflect.DeepEqual(a, b) {
+		t.Errorf("same seed must produce the same lines:\n%v\n%v\n", a, b)
+	}
+	if a, b := lines(42), lines(43); reflect.DeepEqual(a, b) {
+		t.Errorf("different seeds must produce different lines:\n%v\n%v\n", a, b)
+	}
+}
+
+func TestGenerator_Write(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    int
+		n       int
+		timeout time.Duration
+		wantN   int
+		wantMin time.Duration
+		wantErr bool
+	}{
+		{
+			name:  "unlimited",
+			n:     100,
+			wantN: 100,
+		},
+		{
+			name:    "rate limited",
+			rate:    100,
+			n:       6,
+			wantN:   6,
+			wantMin: 50 * time.Millisecond,
+		},
+		{
+			name:    "until cancelled",
+			rate:    1000,
+			n:       0,
+			timeout: 20 * time.Millisecond,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.timeout > 0 {
+				ctx, cancel = context.WithTimeout(context.Background(), tt.timeout)
+			}
+			defer cancel()
+			start := time.Now()
+			n, err := New(Config{Rate: tt.rate}).Write(ctx, io.Discard, tt.n)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if tt.wantN > 0 && n != tt.wantN {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", n, tt.wantN)
+			}
+			if elapsed := time.Since(start); elapsed < tt.wantMin {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", elapsed, tt.wantMin)
+			}
+		})
+	}
+}
+
+func TestGenerator_Reader(t *testing.T) {
+	r, err := parser.NewS3RegexParser(context.Background(), io.Discard, parser.Option{}).Parse(New(Config{}).Reader(context.Background(), 50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Matched != 50 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", r.Matched, 50)
+	}
+}
@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync/atomic"
+)
+
+// errorOverhead is the estimated memory of an entry of Result.Errors besides the line itself.
+const errorOverhead = 64
+
+// memoryMeter tracks the estimated memory buffered by a parse against Option.MemoryLimit. It is shared by
+// the entries of a zip archive parsed concurrently.
+type memoryMeter struct {
+	limit int64
+	used  atomic.Int64
+	max   atomic.Int64
+}
+
+// newMemoryMeter returns a memoryMeter for the limit in bytes, which is unlimited if not positive.
+func newMemoryMeter(limit int64) *memoryMeter {
+	return &memoryMeter{limit: limit}
+}
+
+// grow adds n bytes to the memory in use, raising the peak as needed.
+func (m *memoryMeter) grow(n int64) {
+	used := m.used.Add(n)
+	for {
+		peak := m.max.Load()
+		if used <= peak || m.max.CompareAndSwap(peak, used) {
+			return
+		}
+	}
+}
+
+// release subtracts n bytes from the memory in use.
+func (m *memoryMeter) release(n int64) {
+	m.used.Add(-n)
+}
+
+// fits reports whether n more bytes stay within the limit.
+func (m *memoryMeter) fits(n int64) bool {
+	return m.limit <= 0 || m.used.Load()+n <= m.limit
+}
+
+// peak returns the highest memory in use so far.
+func (m *memoryMeter) peak() int64 {
+	return m.max.Load()
+}
+
+// keepError adds the unmatched line to r.Errors, or counts it in r.DroppedErrors if keeping it would exceed
+// the limit or r.Errors already holds maxErrors lines, so that a flood of unmatched lines does not exhaust memory.
+func (m *memoryMeter) keepError(r *Result, e Errors, maxErrors int) {
+	n := int64(len(e.Line) + errorOverhead)
+	if !m.fits(n) || (maxErrors > 0 && len(r.Errors) >= maxErrors) {
+		r.DroppedErrors++
+		return
+	}
+	m.grow(n)
+	r.Errors = append(r.Errors, e)
+}
+
+// orderedBuffer buffers the output of a zip entry parsed concurrently until the entry's turn to be written.
+// Once the buffered output of all entries exceeds the limit, it waits for its turn and then writes through to
+// the output, flushing what it buffered, which trades parallelism for bounded memory.
+type orderedBuffer struct {
+	ctx    context.Context
+	meter  *memoryMeter
+	turn   <-chan struct{}
+	output io.Writer
+	buf    bytes.Buffer
+	direct bool
+}
+
+// Write buffers p, or writes it to the output once the entry writes through.
+func (b *orderedBuffer) Write(p []byte) (int, error) {
+	if !b.direct && !b.meter.fits(int64(len(p))) {
+		select {
+		case <-b.turn:
+		case <-b.ctx.Done():
+			return 0, b.ctx.Err()
+		}
+		if err := b.flush(); err != nil {
+			return 0, err
+		}
+		b.direct = true
+	}
+	if b.direct {
+		return b.output.Write(p)
+	}
+	b.meter.grow(int64(len(p)))
+	return b.buf.Write(p)
+}
+
+// flush writes the buffered output and releases its memory.
+func (b *orderedBuffer) flush() error {
+	n := int64(b.buf.Len())
+	_, err := b.output.Write(b.buf.Bytes())
+	b.buf.Reset()
+	b.meter.release(n)
+	if err != nil {
+		return err
+	}
+	return flushOutput(b.output)
+}
@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"slices"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxPercentDecode is the maximum number of times values are percent-decoded, to undo multiple encoding.
+const maxPercentDecode = 3
+
+// normalizeValue percent-decodes the value repeatedly until it no longer changes, and applies NFKC
+// normalization, so that "%61dmin", "%2561dmin" and "ａｄｍｉｎ" all become "admin".
+func normalizeValue(v string) string {
+	for i := 0; i < maxPercentDecode; i++ {
+		d := percentDecode(v)
+		if d == v {
+			break
+		}
+		v = d
+	}
+	return norm.NFKC.String(v)
+}
+
+// percentDecode decodes valid percent-encoded bytes in s, leaving invalid sequences and "+" as is.
+func percentDecode(s string) string {
+	i := 0
+	for i < len(s) && !(s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2])) {
+		i++
+	}
+	if i == len(s) {
+		return s
+	}
+	b := make([]byte, 0, len(s))
+	b = append(b, s[:i]...)
+	for ; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			b = append(b, unhex(s[i+1])<<4|unhex(s[i+2]))
+			i += 2
+			continue
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
+
+// isHex reports whether c is a hexadecimal digit.
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// unhex returns the value of the hexadecimal digit.
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// normalizeFields returns the values with those of the labels to normalize normalized.
+// The values are copied only if some of them change.
+func normalizeFields(labels, values, normalize []string) []string {
+	if len(normalize) == 0 {
+		return values
+	}
+	ret := values
+	for i, label := range labels {
+		if i >= len(values) || !slices.Contains(normalize, label) {
+			continue
+		}
+		v := normalizeValue(values[i])
+		if v == values[i] {
+			continue
+		}
+		if &ret[0] == &values[0] {
+			ret = slices.Clone(values)
+		}
+		ret[i] = v
+	}
+	return ret
+}
+
+// normalizedDerived returns a function reporting whether values of the label may not appear literally in lines,
+// taking normalized labels into account, as their values may appear only encoded.
+func normalizedDerived(derived func(string) bool, normalize []string) func(string) bool {
+	if len(normalize) == 0 {
+		return derived
+	}
+	return func(label string) bool {
+		return slices.Contains(normalize, label) || derived != nil && derived(label)
+	}
+}
@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func Test_normalizeValue(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain", in: "/admin", want: "/admin"},
+		{name: "percent-encoded", in: "/%61dmin", want: "/admin"},
+		{name: "double-encoded", in: "/%2561dmin", want: "/admin"},
+		{name: "fullwidth", in: "/ａｄｍｉｎ", want: "/admin"},
+		{name: "encoded fullwidth", in: "/%EF%BD%81dmin", want: "/admin"},
+		{name: "invalid escape", in: "/100%/a%zz+b", want: "/100%/a%zz+b"},
+		{name: "trailing percent", in: "/a%6", want: "/a%6"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeValue(tt.in); got != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parser_normalize(t *testing.T) {
+	input := "uri:/%61dmin\tua:%61dmin\nuri:/index.html\tua:x\nuri:/ａｄｍｉｎ/users\tua:y"
+	for _, pushdown := range []bool{false, true} {
+		t.Run(fmt.Sprintf("pushdown=%t", pushdown), func(t *testing.T) {
+			output := &bytes.Buffer{}
+			opt := Option{
+				Normalize:   []string{"uri"},
+				Filters:     []string{"uri =~ ^/admin"},
+				Pushdown:    pushdown,
+				LineHandler: JSONLineHandler,
+			}
+			if pushdown {
+				opt.Filters = []string{"uri == /admin"}
+			}
+			got, err := parser(context.Background(), strings.NewReader(input), output, nil, ltsvLineDecoder, opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := `{"uri":"/admin","ua":"%61dmin"}` + "\n"
+			if !pushdown {
+				want += `{"uri":"/admin/users","ua":"y"}` + "\n"
+			}
+			if out := output.String(); out != want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", out, want)
+			}
+			if got.Excluded != 3-strings.Count(want, "\n") {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Excluded, 3-strings.Count(want, "\n"))
+			}
+		})
+	}
+}
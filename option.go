@@ -0,0 +1,206 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// DefaultOption returns the option used when fields are left zero, with the defaults made explicit:
+// JSON output and the default maximum line size. It is a starting point for programmatic builders of Option.
+func DefaultOption() Option {
+	return Option{
+		LineHandler: JSONLineHandler,
+		MaxLineSize: defaultMaxLineSize,
+	}
+}
+
+// Validate checks the option for invalid values and conflicting settings, so that config loaders and UIs
+// can report them before parsing starts. All problems found are joined into the returned error.
+func (opt Option) Validate() error {
+	var ps problems
+	opt.checkInput(&ps)
+	opt.checkFilters(&ps)
+	opt.checkOutput(&ps)
+	opt.checkFormats(&ps)
+	opt.checkLimits(&ps)
+	opt.checkHooks(&ps)
+	return errors.Join(ps...)
+}
+
+// problems collects the problems found in an option by Validate.
+type problems []error
+
+// add adds a problem described by the format and arguments.
+func (ps *problems) add(format string, a ...any) {
+	*ps = append(*ps, fmt.Errorf("%s: %s", optionError, fmt.Sprintf(format, a...)))
+}
+
+// wrap adds the error as a problem, if any.
+func (ps *problems) wrap(err error) {
+	if err != nil {
+		*ps = append(*ps, fmt.Errorf("%s: %w", optionError, err))
+	}
+}
+
+// checkInput checks the selection of the lines to parse.
+func (opt Option) checkInput(ps *problems) {
+	for _, n := range opt.SkipLines {
+		if n < 1 {
+			ps.add("skip line %d is not a line number", n)
+		}
+	}
+	for _, rg := range opt.SkipRanges {
+		if rg[0] < 1 || rg[0] > rg[1] {
+			ps.add("skip range %v is not a range of line numbers", rg)
+		}
+	}
+	if opt.Head > 0 && opt.Tail > 0 {
+		ps.add("Head and Tail cannot be used together")
+	}
+	if opt.Pushdown && opt.UnmatchLines {
+		ps.add("Pushdown has no effect with UnmatchLines, since every unmatched line must be output")
+	}
+}
+
+// checkFilters checks the filter expressions and the routing rules.
+func (opt Option) checkFilters(ps *problems) {
+	_, err := getLineFilters(opt.LineFilters)
+	ps.wrap(err)
+	ps.wrap(validateOptionFilters(opt.Filters, opt.LineNumber))
+	for _, filters := range [][]string{opt.PostFilters, opt.RawFilters, opt.EmitWhen} {
+		ps.wrap(validateFilters(filters))
+	}
+	for _, rule := range opt.Routes {
+		if err := rule.validate(); err != nil {
+			*ps = append(*ps, err)
+		}
+	}
+	if len(opt.RawFilters) > 0 && opt.RawField == "" {
+		ps.add("RawFilters without RawField have no effect")
+	}
+}
+
+// checkOutput checks the labels and the settings conflicting with the line handler.
+func (opt Option) checkOutput(ps *problems) {
+	seen := map[string]struct{}{}
+	for _, label := range opt.Labels {
+		if _, ok := seen[label]; ok {
+			ps.add("duplicate label %q", label)
+		}
+		seen[label] = struct{}{}
+	}
+	if opt.UnmatchLines && !opt.Prefix {
+		ps.add("UnmatchLines without Prefix mixes raw lines into the output indistinguishably")
+	}
+	if opt.Prefix && isLineHandler(opt.LineHandler, TSVLineHandler) {
+		ps.add("Prefix with TSVLineHandler breaks the TSV output")
+	}
+	if opt.Prefix && isLineHandler(opt.LineHandler, CSVLineHandler) {
+		ps.add("Prefix with CSVLineHandler breaks the CSV output")
+	}
+	if len(opt.EmitWhen) > 0 && (isLineHandler(opt.LineHandler, TSVLineHandler) || isLineHandler(opt.LineHandler, CSVLineHandler)) {
+		ps.add("EmitWhen with TSVLineHandler or CSVLineHandler misaligns the columns with the header")
+	}
+	if opt.ReconcileLabels && !opt.HeaderOnce {
+		ps.add("ReconcileLabels without HeaderOnce has no effect")
+	}
+	if len(opt.GroupBy) > 0 || len(opt.Aggregates) > 0 {
+		_, err := newAggregator(opt.GroupBy, opt.Aggregates)
+		ps.wrap(err)
+	}
+}
+
+// checkFormats checks the types and formats of the fields.
+func (opt Option) checkFormats(ps *problems) {
+	for label, t := range opt.Types {
+		if t < FieldTypeString || t > FieldTypeTime {
+			ps.add("unknown type %d of field %q", t, label)
+		}
+	}
+	if len(opt.Types) > 0 && opt.LineHandler != nil && !isLineHandler(opt.LineHandler, JSONLineHandler) && !isLineHandler(opt.LineHandler, PrettyJSONLineHandler) {
+		ps.add("Types have no effect with handlers other than JSONLineHandler and PrettyJSONLineHandler")
+	}
+	_, err := loadTimezone(opt.OutputTimezone)
+	ps.wrap(err)
+	if opt.OutputTimezone != "" && len(opt.TimeFields) == 0 && !hasFieldType(opt.Types, FieldTypeTime) {
+		ps.add("OutputTimezone without TimeFields or FieldTypeTime in Types has no effect")
+	}
+	for label, convert := range opt.Converters {
+		if convert == nil {
+			ps.add("converter of field %q is nil", label)
+		}
+	}
+}
+
+// checkLimits checks that the sizes, counts and durations are not negative.
+func (opt Option) checkLimits(ps *problems) {
+	for _, v := range []struct {
+		name string
+		n    int64
+	}{
+		{"MaxLineSize", int64(opt.MaxLineSize)},
+		{"RateLimit", int64(opt.RateLimit)},
+		{"Concurrency", int64(opt.Concurrency)},
+		{"SourceTimeout", int64(opt.SourceTimeout)},
+		{"Heartbeat", int64(opt.Heartbeat)},
+		{"MemoryLimit", opt.MemoryLimit},
+		{"MaxErrors", int64(opt.MaxErrors)},
+		{"Snapshot", int64(opt.Snapshot)},
+		{"Offset", int64(opt.Offset)},
+		{"Limit", int64(opt.Limit)},
+		{"Head", int64(opt.Head)},
+		{"Tail", int64(opt.Tail)},
+	} {
+		if v.n < 0 {
+			ps.add("%s must not be negative", v.name)
+		}
+	}
+}
+
+// checkHooks checks the hooks that would never be called.
+func (opt Option) checkHooks(ps *problems) {
+	if opt.OnPresetWarning != nil && opt.LazyDecode {
+		ps.add("OnPresetWarning has no effect with LazyDecode, which shortens the patterns")
+	}
+	if opt.OnHeartbeat != nil && opt.Heartbeat == 0 {
+		ps.add("OnHeartbeat without Heartbeat is never called")
+	}
+	if opt.OnSnapshot != nil && opt.Snapshot == 0 {
+		ps.add("OnSnapshot without Snapshot is never called")
+	}
+	if opt.Trace != nil && opt.Trace.fn == nil {
+		ps.add("Trace without a function is never called")
+	}
+}
+
+// isLineHandler reports whether the handler is the given function.
+func isLineHandler(handler, fn LineHandler) bool {
+	return handler != nil && reflect.ValueOf(handler).Pointer() == reflect.ValueOf(fn).Pointer()
+}
+
+// hasFieldType reports whether some of the fields are of the type.
+func hasFieldType(types map[string]FieldType, t FieldType) bool {
+	for _, ft := range types {
+		if ft == t {
+			return true
+		}
+	}
+	return false
+}
+
+// validateFilters reports whether the filter expressions are valid, checking them against their own labels.
+func validateFilters(filters []string) error {
+	_, err := getFilter(filterLabels(filters), filters)
+	return err
+}
+
+// filterLabels returns the labels the filter expressions refer to.
+func filterLabels(filters []string) []string {
+	labels := make([]string, 0, len(filters))
+	for _, filter := range filters {
+		labels = append(labels, strings.SplitN(filter, " ", 2)[0])
+	}
+	return labels
+}
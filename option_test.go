@@ -0,0 +1,173 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultOption(t *testing.T) {
+	opt := DefaultOption()
+	if err := opt.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !isLineHandler(opt.LineHandler, JSONLineHandler) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", "other handler", "JSONLineHandler")
+	}
+	if opt.MaxLineSize != defaultMaxLineSize {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", opt.MaxLineSize, defaultMaxLineSize)
+	}
+}
+
+func TestOption_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  Option
+		want []string
+	}{
+		{
+			name: "valid",
+			opt: Option{
+				Labels:       []string{"status", "method"},
+				Filters:      []string{"status >= 500", "method =~ ^(GET|POST)$"},
+				SkipLines:    []int{1},
+				Prefix:       true,
+				UnmatchLines: true,
+				LineHandler:  LTSVLineHandler,
+			},
+			want: nil,
+		},
+		{
+			name: "invalid values",
+			opt: Option{
+				Labels:      []string{"status", "status"},
+				Filters:     []string{"status", "size < abc", "method ~ GET"},
+				SkipLines:   []int{0},
+				RateLimit:   -1,
+				Heartbeat:   -time.Second,
+				Concurrency: -1,
+				MemoryLimit: -1,
+			},
+			want: []string{
+				`duplicate label "status"`,
+				`"status": invalid syntax`,
+				"skip line 0 is not a line number",
+				"RateLimit must not be negative",
+				"Concurrency must not be negative",
+				"Heartbeat must not be negative",
+				"MemoryLimit must not be negative",
+			},
+		},
+		{
+			name: "invalid filter phases",
+			opt: Option{
+				LineFilters: []string{"line =~ GET", "status == 200"},
+				PostFilters: []string{"country"},
+			},
+			want: []string{
+				`"status": invalid field name`,
+				`"country": invalid syntax`,
+			},
+		},
+		{
+			name: "conflicts",
+			opt: Option{
+				UnmatchLines: true,
+				Pushdown:     true,
+				RawFilters:   []string{"status == 500"},
+				OnHeartbeat:  func(time.Duration) (string, error) { return "", nil },
+			},
+			want: []string{
+				"UnmatchLines without Prefix",
+				"Pushdown has no effect with UnmatchLines",
+				"RawFilters without RawField",
+				"OnHeartbeat without Heartbeat",
+			},
+		},
+		{
+			name: "prefix with tsv",
+			opt:  Option{Prefix: true, LineHandler: TSVLineHandler},
+			want: []string{"Prefix with TSVLineHandler"},
+		},
+		{
+			name: "prefix with csv",
+			opt:  Option{Prefix: true, LineHandler: CSVLineHandler},
+			want: []string{"Prefix with CSVLineHandler"},
+		},
+		{
+			name: "types",
+			opt:  Option{Types: map[string]FieldType{"status": FieldTypeInt, "size": FieldType(9)}, LineHandler: LTSVLineHandler},
+			want: []string{
+				`unknown type 9 of field "size"`,
+				"Types have no effect with handlers other than JSONLineHandler",
+			},
+		},
+		{
+			name: "ranges",
+			opt:  Option{SkipRanges: [][2]int{{5, 3}, {0, 2}, {1, 1}}, Head: 10, Tail: 5, Limit: -1},
+			want: []string{
+				"skip range [5 3] is not a range of line numbers",
+				"skip range [0 2] is not a range of line numbers",
+				"Limit must not be negative",
+				"Head and Tail cannot be used together",
+			},
+		},
+		{
+			name: "emit when",
+			opt:  Option{EmitWhen: []string{"error_code != -", "size > x"}, LineHandler: TSVLineHandler},
+			want: []string{"cannot evaluate filter expressions", "EmitWhen with TSVLineHandler"},
+		},
+		{
+			name: "preset warning with lazy decode",
+			opt:  Option{OnPresetWarning: func(PresetWarning) {}, LazyDecode: true},
+			want: []string{"OnPresetWarning has no effect with LazyDecode"},
+		},
+		{
+			name: "output timezone",
+			opt:  Option{OutputTimezone: "Mars/Olympus_Mons"},
+			want: []string{`unknown timezone: "Mars/Olympus_Mons"`, "OutputTimezone without TimeFields"},
+		},
+		{
+			name: "snapshot",
+			opt:  Option{MaxErrors: -1, OnSnapshot: func(Snapshot) (string, error) { return "", nil }},
+			want: []string{"MaxErrors must not be negative", "OnSnapshot without Snapshot is never called"},
+		},
+		{
+			name: "trace",
+			opt:  Option{Trace: WithTrace(nil).Every(100)},
+			want: []string{"Trace without a function is never called"},
+		},
+		{
+			name: "converters",
+			opt:  Option{Converters: map[string]Converter{"status": StatusClass, "referer": nil}},
+			want: []string{`converter of field "referer" is nil`},
+		},
+		{
+			name: "aggregates",
+			opt:  Option{GroupBy: []string{"status"}, Aggregates: []string{"count", "median(size)"}},
+			want: []string{`unknown function "median"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opt.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, nil)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, want)
+				}
+			}
+			if got := strings.Count(err.Error(), optionError); got != len(tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, len(tt.want))
+			}
+		})
+	}
+}
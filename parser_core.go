@@ -3,20 +3,29 @@ package parser
 import (
 	"archive/zip"
 	"bufio"
+	"bytes"
+	"compress/flate"
 	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"regexp"
+	"regexp/syntax"
 	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
-	"github.com/mattn/go-isatty"
+	"golang.org/x/text/encoding"
 )
 
 type inputType int
@@ -40,50 +49,182 @@ const (
 	openFileError     = "cannot open file"
 	filterError       = "cannot evaluate filter expressions"
 	operatorError     = "unknown operator"
+	jsonPathError     = "invalid JSON path"
+	arrayPolicyError  = "invalid array policy"
+	entryNameError    = "cannot decode zip entry name"
+	seenFilterError   = "invalid seen filter"
+	indexError        = "invalid index"
+	optionError       = "invalid option"
+	encryptionError   = "cannot encrypt field"
+	decryptionError   = "cannot decrypt field"
+	logFormatError    = "invalid log format"
+	structSchemaError = "cannot generate schema from struct"
+	templateError     = "invalid line template"
+	timezoneError     = "unknown timezone"
+	headerError       = "incompatible labels"
+	reportError       = "cannot write report"
+	convertError      = "cannot convert field"
+	checkpointError   = "cannot checkpoint source"
+	profileError      = "cannot profile input"
 )
 
+// scanner buffer sizes. The buffer starts small and doubles as longer lines are read, up to the maximum line size.
+const (
+	initialBufferSize  = 4 * 1024
+	defaultMaxLineSize = 64 * 1024 * 1024
+)
+
+// binary detection settings. The first chunk of the input up to binarySniffSize is inspected.
+const (
+	binarySniffSize = 8 * 1024
+	binaryThreshold = 0.3
+)
+
+// ErrCorruptedInput is reported when compressed input cannot be read to the end, such as on a checksum mismatch.
+// Use errors.As with *CorruptedInputError to know how far the input was read.
+var ErrCorruptedInput = errors.New("corrupted input")
+
+// CorruptedInputError describes where the input was found to be corrupted.
+// The partial result returned along with it covers the lines read before the corruption.
+type CorruptedInputError struct {
+	Offset int64 // number of decompressed bytes read successfully before the corruption
+	Line   int   // number of lines read successfully before the corruption
+	Err    error // underlying error from the decompressor
+}
+
+// Error returns the error message with the offset and line number.
+func (e *CorruptedInputError) Error() string {
+	return fmt.Sprintf("%s at offset %d after line %d: %v", ErrCorruptedInput, e.Offset, e.Line, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *CorruptedInputError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the target is ErrCorruptedInput.
+func (e *CorruptedInputError) Is(target error) bool {
+	return target == ErrCorruptedInput
+}
+
+// ErrBinaryInput is reported when the beginning of the input looks binary rather than text, so that such
+// inputs stop early instead of producing unmatched errors for every line. Zip entries that look binary are
+// abandoned and listed in Result.
+var ErrBinaryInput = errors.New("binary input detected")
+
 // Parser interface defines methods for parsing log data from various sources.
-// Basically used internally to implement RegexParser and LTSVParser.
+// It is implemented by RegexParser, LTSVParser, CSVParser, JSONParser and FixedWidthParser, and is stable for
+// applications to depend on instead of a concrete parser. The parsermock package provides a mock for unit tests.
 type Parser interface {
 	Parse(reader io.Reader) (*Result, error)
 	ParseString(s string) (*Result, error)
 	ParseFile(filePath string) (*Result, error)
 	ParseGzip(gzipPath string) (*Result, error)
 	ParseZipEntries(zipPath, globPattern string) (*Result, error)
+	ParseTimeRangeIndexed(filePath string, from, to time.Time) (*Result, error)
+	SetLineHandler(handler LineHandler)
 }
 
 // Option defines the parser settings.
 // Each field is used to customize the output.
 type Option struct {
-	Labels       []string    // specify fields to output by label name
-	Filters      []string    // conditional expression for output log lines
-	SkipLines    []int       // line numbers to exclude from output (not index)
-	Prefix       bool        // whether to prefix the output lines or not
-	UnmatchLines bool        // whether to output unmatched lines as raw logs or not
-	LineNumber   bool        // whether to add line numbers or not
-	LineHandler  LineHandler // handler function to convert log lines
+	Labels          []string                // specify fields to output by label name
+	LineFilters     []string                // conditional expression for raw lines on the label "line", evaluated before decoding
+	Normalize       []string                // labels whose values are percent-decoded and NFKC-normalized before filters are evaluated
+	Filters         []string                // conditional expression for output log lines, evaluated after decoding, or before it on the line number "no" with LineNumber
+	PostFilters     []string                // conditional expression for records, evaluated after conversion and enrichment
+	EmitWhen        []string                // conditional expression for a field to be output, such as "error_code != -" (fields without one are always output)
+	SkipLines       []int                   // line numbers to exclude from output (not index)
+	SkipRanges      [][2]int                // ranges of line numbers to exclude from output, both ends inclusive
+	Offset          int                     // number of lines at the beginning to exclude from output (0 means none)
+	Limit           int                     // maximum number of matched lines of each source, parsing stops when reached (0 means unlimited)
+	Head            int                     // number of lines at the beginning of each source to parse, the rest are not read (0 means all)
+	Tail            int                     // number of lines at the end of each source to parse, the input is read to the end first (0 means all)
+	Prefix          bool                    // whether to prefix the output lines or not
+	UnmatchLines    bool                    // whether to output unmatched lines as raw logs or not
+	LineNumber      bool                    // whether to add line numbers or not
+	ByteOffset      bool                    // whether to add byte offsets of the lines in the (decompressed) input or not
+	FieldPositions  bool                    // whether to add the byte ranges of the fields within the lines as the field "positions" or not, with regex, LTSV and fixed-width parsers
+	RawField        string                  // label name to add the original line with (empty means not added)
+	RawFilters      []string                // conditional expression for lines to add the original line to (empty means all lines)
+	SourceTimeout   time.Duration           // maximum time to parse each source, a zip entry exceeding it is abandoned (0 means unlimited)
+	MaxLineSize     int                     // maximum length in bytes of a line, the buffer grows up to it as needed (0 means 64 MiB)
+	NormalizeCRLF   bool                    // whether to normalize CRLF line endings within records and count the normalized lines or not
+	DetectBinary    bool                    // whether to stop parsing inputs that look binary with ErrBinaryInput or not
+	ZipNameEncoding encoding.Encoding       // encoding of zip entry names not flagged as UTF-8, such as japanese.ShiftJIS (nil means as is)
+	Concurrency     int                     // maximum number of zip entries parsed at a time, with the output kept in order (0 or 1 means sequential)
+	MemoryLimit     int64                   // estimated bytes of unmatched lines kept in Result.Errors, of output buffered by concurrent parsing and of groups of GroupBy (0 means unlimited)
+	MaxErrors       int                     // maximum number of unmatched lines of each source kept in Result.Errors, the rest are counted in Result.DroppedErrors (0 means unlimited)
+	SeenFilter      *SeenFilter             // filter to exclude records whose key field value has been seen, in this or previous runs
+	Reloader        *Reloader               // source of configuration changes applied while parsing a stream (nil means disabled)
+	Index           *Index                  // index to record byte offsets and key field values of sampled lines into (nil means disabled)
+	Pushdown        bool                    // whether to skip lines not containing the values of "==" filters before decoding or not
+	LazyDecode      bool                    // whether to stop matching patterns once the groups needed for Labels and filters are captured or not
+	MergePatterns   bool                    // whether to skip the rest of the patterns for lines not matching the prefix shared by them or not
+	Enrichers       []Enricher              // functions to add or convert fields of records after filtering, in order
+	Routes          []*Rule                 // rules to route matching records to additional writers, such as alerting sinks
+	GroupBy         []string                // labels to group records by, writing a row per group at the end instead of the records (the groups are kept in memory and count towards MemoryLimit)
+	Aggregates      []string                // aggregates such as "count" and "sum(bytes_sent)" of each group, or of all records without GroupBy
+	LineHandler     LineHandler             // handler function to convert log lines
+	Types           map[string]FieldType    // types of fields written as typed values by JSONLineHandler and PrettyJSONLineHandler (nil means all strings)
+	NumberFormats   map[string]NumberFormat // formats of numeric fields, such as integers or scaled values with fixed precision (nil means as is)
+	TimeFields      []string                // labels of timestamps written in RFC 3339, such as the CLF time of S3 logs (nil means as is)
+	Converters      map[string]Converter    // functions converting the values of fields right before serialization, such as DecodePercent for request_uri (nil means as is)
+	OutputTimezone  string                  // IANA name of the zone such as "Asia/Tokyo" the timestamps of TimeFields and of Types are written in ("" means as in the logs)
+	HeaderOnce      bool                    // whether to write the header of TSV or CSV output once for all the sources written by the parser, failing when their labels differ, or not
+	ReconcileLabels bool                    // whether to align the records of sources whose labels differ from the header with it under HeaderOnce, instead of failing, or not
+	CheckLabels     bool                    // whether to compare the labels of the first record of each source with those of the first source and report the differences in Result.LabelMismatches or not
+	Checkpoint      Checkpointer            // store of the positions parsed up to in files, for ParseFile to resume from on the next run (nil means parsed from the beginning)
+	RateLimit       int                     // maximum number of output lines per second (0 means unlimited)
+	Heartbeat       time.Duration           // idle duration after which a heartbeat is emitted periodically (0 means disabled)
+	OnHeartbeat     HeartbeatFunc           // hook called on heartbeat, a heartbeat record is emitted if nil
+	Snapshot        time.Duration           // interval at which the counters are snapshotted as lines arrive, for monitoring long-running streams (0 means disabled)
+	OnSnapshot      SnapshotFunc            // hook called on snapshot, a summary record is emitted if nil
+	OnPresetWarning PresetWarningFunc       // hook called once when a line matches only an old version of the format of a versioned preset (nil means disabled)
+	Trace           *Tracer                 // function called at each stage of the pipeline for sampled lines, for debugging (nil means disabled)
+	split           bufio.SplitFunc         // split function for multi-line records, set by presets
+	explode         explodeFunc             // function to expand a decoded line into multiple records, set by parsers
+	derived         func(string) bool       // reports whether values of the label may not appear literally in lines, set by parsers
+	project         projectFunc             // function to create a decoder that materializes only the needed labels, set by parsers
+	locate          locateFunc              // function to return the byte ranges of the fields of a line, set by parsers without patterns
+	window          *timeWindow             // time range and start position to parse within, set by ParseTimeRangeIndexed
+	meter           *memoryMeter            // estimated memory shared by the sources of a parse, set by ParseZipEntries
+	aggregator      *aggregator             // groups shared by the sources of a parse, set by ParseZipEntries
+	header          *headerState            // header shared by the sources written by a parser, set by parsers
+	resume          *Checkpoint             // position to start at, set by ParseFile with Checkpoint
 }
 
 // LineHandler is a function type that processes each matched line.
 // It takes the matches, their corresponding fields, and the line number, and returns processed string data.
 type lineDecoder func(line string, patterns []*regexp.Regexp) ([]string, []string, error)
 
+// explodeFunc is a function type that expands the values of a decoded line into multiple records sharing the same labels.
+type explodeFunc func(labels, values []string) [][]string
+
+// projectFunc is a function type that returns a lineDecoder materializing only the needed labels.
+type projectFunc func(needed map[string]struct{}) lineDecoder
+
 // lineFilter is a function type that provides a filter function applied to log lines.
 type lineFilter func(v string) (bool, error)
 
 // LineHandler is a function type that processes each matched line.
 type LineHandler func(labels, values []string, isFirst bool) (string, error)
 
+// HeartbeatFunc is a function type called when no input has arrived for the heartbeat interval.
+// It receives the idle duration and returns a line to be written to the output, or an empty string to write nothing.
+type HeartbeatFunc func(idle time.Duration) (string, error)
+
 // parse orchestrates the parsing process, applying keyword filters and regular expression patterns to log data from an io.Reader.
 // It supports dynamic handling of line processing, error collection, and pattern matching for efficient log analysis.
 // This function is used as an internal process of the Parse method.
 func parse(ctx context.Context, input io.Reader, output io.Writer, patterns []*regexp.Regexp, decoder lineDecoder, opt Option) (*Result, error) {
 	r, err := parser(ctx, input, output, patterns, decoder, opt)
-	if err != nil {
+	if r == nil {
 		return nil, err
 	}
 	r.inputType = inputTypeStream
-	return r, nil
+	compareLabels(r, "", r.labels, opt)
+	return r, err
 }
 
 // parseString is a convenience method for parsing log data directly from a string.
@@ -91,11 +232,12 @@ func parse(ctx context.Context, input io.Reader, output io.Writer, patterns []*r
 // This function is used as an internal process of the ParseString method.
 func parseString(ctx context.Context, s string, output io.Writer, patterns []*regexp.Regexp, decoder lineDecoder, opt Option) (*Result, error) {
 	r, err := parser(ctx, strings.NewReader(s), output, patterns, decoder, opt)
-	if err != nil {
+	if r == nil {
 		return nil, err
 	}
 	r.inputType = inputTypeString
-	return r, nil
+	compareLabels(r, "", r.labels, opt)
+	return r, err
 }
 
 // parseFile opens and processes log data from a file, applying the specified patterns and handlers.
@@ -107,13 +249,24 @@ func parseFile(ctx context.Context, filePath string, output io.Writer, patterns
 		return nil, err
 	}
 	defer cleanup()
-	r, err := parser(ctx, f, output, patterns, decoder, opt)
-	if err != nil {
+	var input io.Reader = f
+	var key string
+	if opt.Checkpoint != nil {
+		if key, opt.resume, input, err = resumeFile(f, filePath, opt.Checkpoint); err != nil {
+			return nil, err
+		}
+	}
+	r, err := parser(ctx, input, output, patterns, decoder, opt)
+	if r == nil {
 		return nil, err
 	}
 	r.Source = filepath.Base(filePath)
 	r.inputType = inputTypeFile
-	return r, nil
+	compareLabels(r, r.Source, r.labels, opt)
+	if err == nil && opt.Checkpoint != nil {
+		err = saveCheckpoint(opt.Checkpoint, key, opt.resume, r)
+	}
+	return r, err
 }
 
 // parseGzip opens a gzip-compressed log file and processes its contents.
@@ -126,12 +279,13 @@ func parseGzip(ctx context.Context, gzipPath string, output io.Writer, patterns
 	}
 	defer cleanup()
 	r, err := parser(ctx, g, output, patterns, decoder, opt)
-	if err != nil {
+	if r == nil {
 		return nil, err
 	}
 	r.Source = filepath.Base(gzipPath)
 	r.inputType = inputTypeGzip
-	return r, nil
+	compareLabels(r, r.Source, r.labels, opt)
+	return r, err
 }
 
 // parseZipEntries processes log entries within a zip archive, filtering files based on a glob pattern.
@@ -139,18 +293,35 @@ func parseGzip(ctx context.Context, gzipPath string, output io.Writer, patterns
 // This function is used as an internal process of the ParseZipEntries method.
 func parseZipEntries(ctx context.Context, zipPath, globPattern string, output io.Writer, patterns []*regexp.Regexp, decoder lineDecoder, opt Option) (*Result, error) {
 	result := Result{Errors: make([]Errors, 0)}
-	err := handleZipEntries(zipPath, globPattern, func(f *zip.File) error {
-		e, err := f.Open()
-		if err != nil {
-			return fmt.Errorf("%s: %w", openFileError, err)
+	opt.meter = newMemoryMeter(opt.MemoryLimit)
+	if len(opt.GroupBy) > 0 || len(opt.Aggregates) > 0 {
+		var err error
+		if opt.aggregator, err = newAggregator(opt.GroupBy, opt.Aggregates); err != nil {
+			return nil, err
 		}
-		defer e.Close()
-		r, err := parser(ctx, e, output, patterns, decoder, opt)
-		if err != nil {
-			return err
+		opt.aggregator.meter = opt.meter
+		if opt.Concurrency > 1 {
+			opt.aggregator.sortGroups()
 		}
+	}
+	add := func(name string, r *Result, err error) error {
 		for i := range r.Errors {
-			r.Errors[i].Entry = f.Name
+			r.Errors[i].Entry = name
+		}
+		switch {
+		case opt.SourceTimeout > 0 && errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil:
+			r.Cancelled = false
+			result.Abandoned = append(result.Abandoned, Abandoned{
+				Entry:  name,
+				Reason: fmt.Sprintf("timed out after %s", opt.SourceTimeout),
+			})
+			err = nil
+		case errors.Is(err, ErrBinaryInput):
+			result.Abandoned = append(result.Abandoned, Abandoned{
+				Entry:  name,
+				Reason: ErrBinaryInput.Error(),
+			})
+			err = nil
 		}
 		result.Total += r.Total
 		result.Matched += r.Matched
@@ -159,15 +330,154 @@ func parseZipEntries(ctx context.Context, zipPath, globPattern string, output io
 		result.Skipped += r.Skipped
 		result.ElapsedTime += r.ElapsedTime
 		result.Source = filepath.Base(zipPath)
-		result.ZipEntries = append(result.ZipEntries, f.Name)
+		result.ZipEntries = append(result.ZipEntries, name)
 		result.Errors = append(result.Errors, r.Errors...)
-		return nil
+		result.DroppedErrors += r.DroppedErrors
+		result.Cancelled = r.Cancelled
+		result.MaxLineLen = max(result.MaxLineLen, r.MaxLineLen)
+		result.Normalized += r.Normalized
+		result.Transforms = r.Transforms
+		result.Reloads = append(result.Reloads, r.Reloads...)
+		compareLabels(&result, name, r.labels, opt)
+		return err
+	}
+	if opt.HeaderOnce && opt.header == nil {
+		opt.header = &headerState{}
+	}
+	var err error
+	if opt.Concurrency > 1 {
+		if opt.HeaderOnce {
+			output = opt.header.writer(output)
+		}
+		err = parseZipEntriesConcurrently(ctx, zipPath, globPattern, output, patterns, decoder, opt, add)
+	} else {
+		err = handleZipEntries(zipPath, globPattern, opt.ZipNameEncoding, func(f *zip.File) error {
+			e, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("%s: %w", openFileError, err)
+			}
+			defer e.Close()
+			r, err := parser(ctx, e, output, patterns, decoder, opt)
+			if r == nil {
+				return err
+			}
+			return add(f.Name, r, err)
+		})
+	}
+	if err != nil && len(result.ZipEntries) == 0 {
+		return nil, err
+	}
+	if opt.aggregator != nil && err == nil {
+		if result.Aggregation, err = opt.aggregator.write(output, opt.LineHandler); err == nil {
+			err = flushOutput(output)
+		}
+	}
+	result.PeakMemory = opt.meter.peak()
+	result.inputType = inputTypeZip
+	return &result, err
+}
+
+// zipEntryResult holds the outcome of parsing a zip entry, with the output buffered until it is written in order.
+type zipEntryResult struct {
+	r   *Result
+	err error
+	buf *orderedBuffer
+}
+
+// parseZipEntriesConcurrently parses up to opt.Concurrency zip entries at a time. The output of each entry is
+// buffered and written in the order of entries, and add is called with the results in the same order, so the
+// output and the result are identical to sequential parsing.
+func parseZipEntriesConcurrently(ctx context.Context, zipPath, globPattern string, output io.Writer, patterns []*regexp.Regexp, decoder lineDecoder, opt Option, add func(name string, r *Result, err error) error) error {
+	files, cleanup, err := openZipEntries(zipPath, globPattern, opt.ZipNameEncoding)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	turns := make([]chan struct{}, len(files))
+	for i := range turns {
+		turns[i] = make(chan struct{})
+	}
+	if len(turns) > 0 {
+		close(turns[0])
+	}
+	return runOrdered(ctx, len(files), opt.Concurrency, func(ctx context.Context, i int) zipEntryResult {
+		e, err := files[i].Open()
+		if err != nil {
+			return zipEntryResult{err: fmt.Errorf("%s: %w", openFileError, err)}
+		}
+		defer e.Close()
+		buf := &orderedBuffer{ctx: ctx, meter: opt.meter, turn: turns[i], output: output}
+		r, err := parser(ctx, e, buf, patterns, decoder, opt)
+		return zipEntryResult{r: r, err: err, buf: buf}
+	}, func(i int, v zipEntryResult) error {
+		if v.buf != nil {
+			if err := v.buf.flush(); err != nil {
+				return err
+			}
+		}
+		if i+1 < len(turns) {
+			close(turns[i+1])
+		}
+		if v.r == nil {
+			return v.err
+		}
+		return add(files[i].Name, v.r, v.err)
 	})
+}
+
+// parseTimeRangeIndexed processes the lines of a time-sorted file within a time range, using the index saved at
+// IndexPath to read only the byte range that may contain them. Gzip-compressed files are detected by the magic
+// number; since they cannot be seeked, the bytes before the range are decompressed but not decoded.
+// This function is used as an internal process of the ParseTimeRangeIndexed method.
+func parseTimeRangeIndexed(ctx context.Context, filePath string, from, to time.Time, output io.Writer, patterns []*regexp.Regexp, decoder lineDecoder, opt Option) (*Result, error) {
+	x, err := LoadIndex(IndexPath(filePath))
 	if err != nil {
 		return nil, err
 	}
-	result.inputType = inputTypeZip
-	return &result, nil
+	w, err := x.window(from, to)
+	if err != nil {
+		return nil, err
+	}
+	f, cleanup, err := handleFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	var input io.Reader = f
+	typ := inputTypeFile
+	magic := make([]byte, 2)
+	if n, _ := io.ReadFull(f, magic); n == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		typ = inputTypeGzip
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("%s: %w", openFileError, err)
+	}
+	if typ == inputTypeGzip {
+		g, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer g.Close()
+		if _, err := io.CopyN(io.Discard, g, w.offset); err != nil {
+			return nil, fmt.Errorf("%s: %w", indexError, err)
+		}
+		input = g
+	} else if _, err := f.Seek(w.offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("%s: %w", openFileError, err)
+	}
+	if w.size >= 0 {
+		input = io.LimitReader(input, w.size)
+	}
+	opt.window = w
+	opt.Index = nil
+	r, err := parser(ctx, input, output, patterns, decoder, opt)
+	if r == nil {
+		return nil, err
+	}
+	r.Source = filepath.Base(filePath)
+	r.inputType = typ
+	compareLabels(r, r.Source, r.labels, opt)
+	return r, err
 }
 
 // parser is the core logic of this module. It processes an input stream line by line against a set of regular expression patterns,
@@ -176,81 +486,354 @@ func parseZipEntries(ctx context.Context, zipPath, globPattern string, output io
 func parser(ctx context.Context, input io.Reader, output io.Writer, patterns []*regexp.Regexp, decoder lineDecoder, opt Option) (*Result, error) {
 	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
 	defer stop()
+	if opt.SourceTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opt.SourceTimeout)
+		defer cancel()
+	}
 	start := time.Now()
+	var turn <-chan struct{}
+	if opt.HeaderOnce {
+		if opt.header == nil {
+			opt.header = &headerState{}
+		}
+		output, turn = headerOutput(output, opt.header)
+	}
+	if opt.Heartbeat > 0 {
+		hb := newHeartbeat(input, output, opt)
+		input, output = hb, hb
+		defer hb.start(ctx)()
+	}
 	r := &Result{Errors: make([]Errors, 0)}
-	i := 0
-	m := applySkipLines(opt.SkipLines)
-	isFirst := true
-	mpref := "[ PROCESSED ] "
-	upref := "[ UNMATCHED ] "
-	if isatty.IsTerminal(os.Stdout.Fd()) {
-		mpref = "\033[1;32m" + mpref + "\033[0m"
-		upref = "\033[1;31m" + upref + "\033[0m"
+	if opt.meter == nil {
+		opt.meter = newMemoryMeter(opt.MemoryLimit)
 	}
-	scanner := bufio.NewScanner(input)
-	for scanner.Scan() {
+	defer func() { r.PeakMemory = opt.meter.peak() }()
+	if opt.DetectBinary {
+		var err error
+		if input, err = sniffBinary(input); err != nil {
+			return abort(r, output, 0, start, err)
+		}
+	}
+	p, err := newPipeline(ctx, output, patterns, decoder, opt, r, start, turn)
+	if err != nil {
+		return abort(r, output, 0, start, err)
+	}
+	defer p.snap.stop()
+	var offset, next int64
+	scanner, cr, base, err := scanLines(input, opt, r, &offset, &next)
+	if err != nil {
+		return abort(r, output, 0, start, err)
+	}
+	i := 0
+	for p.more(i) && scanner.Scan() {
 		select {
 		case <-ctx.Done():
-			return r, ctx.Err()
+			return drain(ctx, r, output, i, start)
 		default:
-			i++
-			if _, ok := m[i]; ok {
-				r.Skipped++
-				continue
-			}
-			raw := scanner.Text()
-			praw := raw
-			if opt.Prefix {
-				praw = upref + raw
-			}
-			ls, vs, err := decoder(raw, patterns)
-			if err != nil {
-				if strings.Contains(err.Error(), "no pattern provided") {
-					return nil, err
-				}
-				if opt.UnmatchLines {
-					if _, err := fmt.Fprintln(output, praw); err != nil {
-						return nil, err
-					}
-				}
-				r.Errors = append(r.Errors, Errors{LineNumber: i, Line: raw})
-				r.Unmatched++
-				continue
+			if err := p.snapshot(i); err != nil {
+				return abort(r, output, i, start, err)
 			}
-			f, err := applyFilter(ls, vs, opt.Filters)
-			if err != nil {
-				return nil, err
+			i++
+			no := base + i
+			l := &scannedLine{no: no, offset: offset, tr: p.opt.Trace.begin(no, offset, scanner.Bytes())}
+			if err := p.reload(l.no); err != nil {
+				return p.stop(i-1, err)
 			}
-			if !f {
-				r.Excluded++
+			if ok, err := p.gate(l, scanner); err != nil {
+				return abort(r, output, i-1, start, err)
+			} else if !ok {
 				continue
 			}
-			if len(opt.Labels) > 0 {
-				ls, vs = selectLabels(opt.Labels, ls, vs)
-			}
-			if opt.LineNumber {
-				ls, vs = addLineNumber(ls, vs, i)
-			}
-			line, err := opt.LineHandler(ls, vs, isFirst)
-			if err != nil {
-				return nil, err
+			if err := p.process(l); err != nil {
+				return p.stop(i-1, err)
 			}
-			if opt.Prefix {
-				line = applyPrefix(line, mpref)
-			}
-			if _, err := fmt.Fprintln(output, line); err != nil {
-				return nil, err
-			}
-			r.Matched++
-			isFirst = false
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return nil, err
+		if isCorrupted(err) {
+			err = &CorruptedInputError{Offset: cr.n, Line: i, Err: err}
+		}
+		return abort(r, output, i, start, err)
+	}
+	if p.ownAgg {
+		if r.Aggregation, err = p.agg.write(output, p.opt.LineHandler); err != nil {
+			return abort(r, output, i, start, err)
+		}
 	}
+	err = flushOutput(output)
 	r.Total = i
+	r.offset = next
 	r.ElapsedTime = time.Since(start)
-	return r, nil
+	return r, err
+}
+
+// headerOutput returns the output wrapped to write the header once, and the turn of the zip entry to wait for
+// before writing the header if the output is the buffer of a zip entry parsed concurrently. Such outputs are
+// wrapped by parseZipEntries instead, as the entries write into buffers in an order different from the output.
+func headerOutput(output io.Writer, header *headerState) (io.Writer, <-chan struct{}) {
+	if b, ok := output.(*orderedBuffer); ok {
+		return output, b.turn
+	}
+	return header.writer(output), nil
+}
+
+// scanLines returns a scanner of the records of the input split as configured, starting after the lines dropped
+// by Tail, along with the counter of the bytes read and the line number before the first record. The byte offsets
+// of the records are tracked into offset and next when needed.
+func scanLines(input io.Reader, opt Option, r *Result, offset, next *int64) (*bufio.Scanner, *countReader, int, error) {
+	split := bufio.ScanLines
+	if opt.split != nil {
+		split = opt.split
+	}
+	var base int
+	switch {
+	case opt.window != nil:
+		base, *next = opt.window.line, opt.window.offset
+	case opt.resume != nil:
+		base, *next = opt.resume.Line, opt.resume.Offset
+	}
+	if opt.Tail > 0 {
+		cr := &countReader{r: input}
+		tail, lines, size, err := tailInput(cr, split, opt.Tail, maxLineSize(opt.MaxLineSize))
+		if err != nil {
+			if isCorrupted(err) {
+				err = &CorruptedInputError{Offset: cr.n, Line: base + lines, Err: err}
+			}
+			return nil, nil, 0, err
+		}
+		input, base, *next = tail, base+lines, *next+size
+	}
+	cr := &countReader{r: input}
+	scanner := bufio.NewScanner(cr)
+	scanner.Buffer(make([]byte, 0, initialBufferSize), maxLineSize(opt.MaxLineSize))
+	if opt.ByteOffset || opt.Index != nil || opt.resume != nil || opt.Trace != nil {
+		split = trackOffset(split, offset, next)
+	}
+	if opt.NormalizeCRLF {
+		split = normalizeCRLF(split, &r.Normalized)
+	}
+	scanner.Split(split)
+	return scanner, cr, base, nil
+}
+
+// maxLineSize returns the maximum line size to be used, falling back to defaultMaxLineSize if n is not positive.
+func maxLineSize(n int) int {
+	if n <= 0 {
+		return defaultMaxLineSize
+	}
+	return n
+}
+
+// sniffBinary reads the first chunk of the input and reports ErrBinaryInput if it looks binary.
+// A single read is made so that streaming inputs are not blocked until a full buffer arrives.
+// The returned reader yields the whole input including the chunk already read.
+func sniffBinary(input io.Reader) (io.Reader, error) {
+	buf := make([]byte, binarySniffSize)
+	n, err := input.Read(buf)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if isBinary(buf[:n]) {
+		return nil, ErrBinaryInput
+	}
+	if err == io.EOF {
+		return bytes.NewReader(buf[:n]), nil
+	}
+	return io.MultiReader(bytes.NewReader(buf[:n]), input), nil
+}
+
+// isBinary reports whether the data looks binary, that is, it contains a NUL byte or
+// the ratio of invalid UTF-8 sequences and control characters other than whitespace
+// and escape sequences exceeds binaryThreshold.
+func isBinary(b []byte) bool {
+	if bytes.IndexByte(b, 0) >= 0 {
+		return true
+	}
+	total, bad := 0, 0
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if (r == utf8.RuneError && size == 1) || (unicode.IsControl(r) && !unicode.IsSpace(r) && r != '\x1b') {
+			bad++
+		}
+		total++
+		b = b[size:]
+	}
+	return total > 0 && float64(bad)/float64(total) > binaryThreshold
+}
+
+// countReader counts the bytes read from the underlying reader.
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read reads from the underlying reader and adds the number of bytes read to the count.
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// isCorrupted reports whether the error was caused by corrupted compressed input.
+func isCorrupted(err error) bool {
+	var cerr flate.CorruptInputError
+	return errors.Is(err, gzip.ErrChecksum) ||
+		errors.Is(err, gzip.ErrHeader) ||
+		errors.Is(err, zip.ErrChecksum) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &cerr)
+}
+
+// drain finalizes a partial result when the context is cancelled. It flushes buffered output, records
+// the number of lines processed so far, and returns the result along with the context error, so that
+// callers can tell a cancellation (errors.Is(err, context.Canceled)) from a failure.
+func drain(ctx context.Context, r *Result, output io.Writer, n int, start time.Time) (*Result, error) {
+	r.Cancelled = true
+	return abort(r, output, n, start, ctx.Err())
+}
+
+// abort finalizes a partial result when parsing stops on an error. It flushes buffered output and records
+// the number of lines processed so far, and returns the result along with the error, so that callers
+// can tell how far processing got.
+func abort(r *Result, output io.Writer, n int, start time.Time, err error) (*Result, error) {
+	r.Total = n
+	r.ElapsedTime = time.Since(start)
+	if ferr := flushOutput(output); ferr != nil {
+		return r, errors.Join(err, ferr)
+	}
+	return r, err
+}
+
+// flushOutput flushes the output if it buffers writes, such as *bufio.Writer.
+func flushOutput(output io.Writer) error {
+	if f, ok := output.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// rateLimiter paces output lines so that no more than a fixed number of lines are emitted per second.
+type rateLimiter struct {
+	interval time.Duration
+	next     time.Time
+}
+
+// newRateLimiter returns a rateLimiter for the given number of lines per second, or nil if n is not positive.
+func newRateLimiter(n int) *rateLimiter {
+	if n <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Second / time.Duration(n)}
+}
+
+// wait blocks until the next line may be emitted or the context is cancelled. A nil rateLimiter never blocks.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	now := time.Now()
+	if l.next.After(now) {
+		t := time.NewTimer(l.next.Sub(now))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		l.next = l.next.Add(l.interval)
+		return nil
+	}
+	l.next = now.Add(l.interval)
+	return nil
+}
+
+// heartbeat wraps the input and output of a parse to detect idle periods. It records the time of the
+// last read and serializes writes, since heartbeat lines are written from a separate goroutine.
+type heartbeat struct {
+	r        io.Reader
+	w        io.Writer
+	mu       sync.Mutex
+	last     atomic.Int64
+	interval time.Duration
+	fn       HeartbeatFunc
+}
+
+// newHeartbeat initializes a heartbeat with the interval and hook from the option. If no hook is set,
+// a record with the labels "heartbeat" and "idle" is emitted through the line handler.
+func newHeartbeat(input io.Reader, output io.Writer, opt Option) *heartbeat {
+	h := &heartbeat{
+		r:        input,
+		w:        output,
+		interval: opt.Heartbeat,
+		fn:       opt.OnHeartbeat,
+	}
+	if h.fn == nil {
+		h.fn = func(idle time.Duration) (string, error) {
+			return opt.LineHandler([]string{"heartbeat", "idle"}, []string{time.Now().Format(time.RFC3339), idle.String()}, false)
+		}
+	}
+	h.last.Store(time.Now().UnixNano())
+	return h
+}
+
+// Read reads from the underlying input and records the time when data arrived.
+func (h *heartbeat) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	if n > 0 {
+		h.last.Store(time.Now().UnixNano())
+	}
+	return n, err
+}
+
+// Write writes to the underlying output exclusively.
+func (h *heartbeat) Write(p []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.w.Write(p)
+}
+
+// Flush flushes the underlying output exclusively if it buffers writes.
+func (h *heartbeat) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return flushOutput(h.w)
+}
+
+// start launches the goroutine that calls the hook for every interval without input,
+// and returns a function that stops the goroutine and waits for it to exit.
+func (h *heartbeat) start(ctx context.Context) func() {
+	done := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				idle := time.Since(time.Unix(0, h.last.Load()))
+				if idle < h.interval {
+					continue
+				}
+				// errors are not fatal here, a broken output is reported by the parsing loop
+				line, err := h.fn(idle)
+				if err != nil || line == "" {
+					continue
+				}
+				fmt.Fprintln(h, line)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
 }
 
 // regexLineDecoder applies regular expression patterns to a given string and
@@ -287,6 +870,122 @@ func ltsvLineDecoder(line string, _ []*regexp.Regexp) ([]string, []string, error
 	return ls, vs, nil
 }
 
+// ltsvProjectedLineDecoder returns a lineDecoder for LTSV that materializes only the needed labels.
+// Every field is still validated, so that lines are matched or unmatched as with ltsvLineDecoder.
+func ltsvProjectedLineDecoder(needed map[string]struct{}) lineDecoder {
+	return func(line string, _ []*regexp.Regexp) ([]string, []string, error) {
+		ls := make([]string, 0, len(needed))
+		vs := make([]string, 0, len(needed))
+		for rest := line; ; {
+			field := rest
+			i := strings.IndexByte(rest, '\t')
+			if i >= 0 {
+				field, rest = rest[:i], rest[i+1:]
+			}
+			j := strings.IndexByte(field, ':')
+			if j < 0 {
+				return nil, nil, fmt.Errorf("%s: invalid field: \"%s\"", parseError, field)
+			}
+			if _, ok := needed[field[:j]]; ok {
+				ls = append(ls, field[:j])
+				vs = append(vs, field[j+1:])
+			}
+			if i < 0 {
+				break
+			}
+		}
+		return ls, vs, nil
+	}
+}
+
+// mysqlSlowSplit is a bufio.SplitFunc that tokenizes a MySQL slow query log into records.
+// A record starts at a "# Time:" line, or at a "# User@Host:" line not preceded by one,
+// and spans all following lines up to the next record header.
+func mysqlSlowSplit(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	timeHeader := []byte("# Time:")
+	userHeader := []byte("# User@Host:")
+	prevTime := bytes.HasPrefix(data, timeHeader)
+	i := bytes.IndexByte(data, '\n')
+	for i >= 0 {
+		rest := data[i+1:]
+		if !atEOF && len(rest) < len(userHeader) {
+			return 0, nil, nil
+		}
+		if bytes.HasPrefix(rest, timeHeader) || (bytes.HasPrefix(rest, userHeader) && !prevTime) {
+			return i + 1, bytes.TrimRight(data[:i], "\r\n"), nil
+		}
+		prevTime = bytes.HasPrefix(rest, timeHeader)
+		j := bytes.IndexByte(rest, '\n')
+		if j < 0 {
+			break
+		}
+		i += j + 1
+	}
+	if atEOF {
+		return len(data), bytes.TrimRight(data, "\r\n"), nil
+	}
+	return 0, nil, nil
+}
+
+// csvRecordSplit is a bufio.SplitFunc that tokenizes CSV input into records.
+// Newlines enclosed in double quotes are kept as part of the record.
+func csvRecordSplit(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	quoted := false
+	for i, b := range data {
+		switch b {
+		case '"':
+			quoted = !quoted
+		case '\n':
+			if !quoted {
+				return i + 1, bytes.TrimRight(data[:i], "\r"), nil
+			}
+		}
+	}
+	if atEOF {
+		return len(data), bytes.TrimRight(data, "\r\n"), nil
+	}
+	return 0, nil, nil
+}
+
+// mysqlSlowLineDecoder decodes a MySQL slow query log record with the given patterns
+// and appends the normalized form of the query as "query_digest".
+func mysqlSlowLineDecoder(line string, patterns []*regexp.Regexp) ([]string, []string, error) {
+	ls, vs, err := regexLineDecoder(line, patterns)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, l := range ls {
+		if l == "query" {
+			vs[i] = strings.TrimSpace(vs[i])
+			return append(ls, "query_digest"), append(vs, normalizeQuery(vs[i])), nil
+		}
+	}
+	return ls, vs, nil
+}
+
+var (
+	queryStringLiteral  = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'|"(?:[^"\\]|\\.|"")*"`)
+	queryNumericLiteral = regexp.MustCompile(`\b-?\d+(?:\.\d+)?\b`)
+	queryInList         = regexp.MustCompile(`(?i)\bin\s*\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	queryWhitespace     = regexp.MustCompile(`\s+`)
+)
+
+// normalizeQuery converts a SQL statement into a fingerprint by replacing literals with placeholders
+// and collapsing whitespace, so that statements differing only in their parameters can be grouped.
+func normalizeQuery(query string) string {
+	q := queryStringLiteral.ReplaceAllString(query, "?")
+	q = queryNumericLiteral.ReplaceAllString(q, "?")
+	q = queryInList.ReplaceAllString(q, "IN (?+)")
+	q = queryWhitespace.ReplaceAllString(q, " ")
+	return strings.TrimRight(strings.TrimSpace(q), ";")
+}
+
 // selectLabels filters the given labels and values based on a list of target labels.
 func selectLabels(targets, labels, values []string) ([]string, []string) {
 	m := make(map[string]struct{}, len(targets))
@@ -304,11 +1003,77 @@ func selectLabels(targets, labels, values []string) ([]string, []string) {
 	return ls, vs
 }
 
+// emitFields drops the fields whose emission conditions are not satisfied. Values that cannot be compared,
+// such as "-" with a numeric operator, are not emitted.
+func emitFields(labels, values []string, conditions map[string]lineFilter) ([]string, []string) {
+	ls := make([]string, 0, len(labels))
+	vs := make([]string, 0, len(values))
+	for i, label := range labels {
+		if cond, ok := conditions[label]; ok {
+			if f, err := cond(values[i]); err != nil || !f {
+				continue
+			}
+		}
+		ls = append(ls, label)
+		vs = append(vs, values[i])
+	}
+	return ls, vs
+}
+
+// formatNumbers returns the values with those of the fields in formats formatted as numbers.
+func formatNumbers(labels, values []string, formats map[string]NumberFormat) []string {
+	vs := slices.Clone(values)
+	for i, label := range labels {
+		if f, ok := formats[label]; ok {
+			vs[i] = f.format(vs[i])
+		}
+	}
+	return vs
+}
+
 // addLineNumber prepends the line number to labels and values.
 func addLineNumber(labels []string, values []string, lineNumber int) ([]string, []string) {
 	return append([]string{"no"}, labels...), append([]string{strconv.Itoa(lineNumber)}, values...)
 }
 
+// addByteOffset adds the byte offset at which the line starts to the beginning of the labels and values.
+// The offset can be used to seek back into the original input.
+func addByteOffset(labels []string, values []string, offset int64) ([]string, []string) {
+	return append([]string{"offset"}, labels...), append([]string{strconv.FormatInt(offset, 10)}, values...)
+}
+
+// trackOffset wraps a split function to record the byte offset of the token most recently returned.
+// next holds the number of bytes consumed so far, which is where the following token starts.
+func trackOffset(split bufio.SplitFunc, offset, next *int64) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := split(data, atEOF)
+		if token != nil {
+			*offset = *next
+		}
+		*next += int64(advance)
+		return advance, token, err
+	}
+}
+
+// normalizeCRLF wraps a split function to replace CRLF line endings in the token with LF, and to
+// drop a trailing CR. Since the split functions already drop the CR at the end of the token, the
+// consumed bytes are inspected to count how many tokens were written with CR line endings.
+func normalizeCRLF(split bufio.SplitFunc, n *int) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := split(data, atEOF)
+		if token == nil || bytes.IndexByte(data[:advance], '\r') < 0 {
+			return advance, token, err
+		}
+		consumed := data[:advance]
+		if !bytes.Contains(consumed, []byte("\r\n")) && !bytes.HasSuffix(consumed, []byte("\r")) {
+			return advance, token, err
+		}
+		*n++
+		token = bytes.TrimSuffix(bytes.ReplaceAll(token, []byte("\r\n"), []byte("\n")), []byte("\r"))
+		return advance, token, err
+	}
+}
+
 // applySkipLines generates a map indicating which line numbers should be skipped during parsing.
 // It takes a slice of line numbers to skip and returns a map with these line numbers as keys.
 func applySkipLines(skipLines []int) map[int]struct{} {
@@ -319,6 +1084,57 @@ func applySkipLines(skipLines []int) map[int]struct{} {
 	return m
 }
 
+// skipLine reports whether the line is skipped by Option.SkipLines, Option.SkipRanges or Option.Offset.
+func skipLine(no int, m map[int]struct{}, opt Option) bool {
+	if no <= opt.Offset {
+		return true
+	}
+	if _, ok := m[no]; ok {
+		return true
+	}
+	for _, rg := range opt.SkipRanges {
+		if rg[0] <= no && no <= rg[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// tailInput reads the input to the end and returns a reader of the last n records split by split, along with
+// the number of records and bytes before them, so that line numbers and byte offsets remain those of the input.
+// The bytes consumed for each record are kept as read, so that the reader splits into the same records again.
+func tailInput(input io.Reader, split bufio.SplitFunc, n, maxSize int) (io.Reader, int, int64, error) {
+	var ring [][]byte
+	var raw []byte
+	lines, size := 0, int64(0)
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 0, initialBufferSize), maxSize)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := split(data, atEOF)
+		if advance > 0 {
+			raw = append(raw, data[:advance]...)
+		}
+		return advance, token, err
+	})
+	for scanner.Scan() {
+		if len(ring) == n {
+			lines++
+			size += int64(len(ring[0]))
+			ring = ring[1:]
+		}
+		ring = append(ring, raw)
+		raw = nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, lines, size, err
+	}
+	readers := make([]io.Reader, len(ring))
+	for i, b := range ring {
+		readers[i] = bytes.NewReader(b)
+	}
+	return io.MultiReader(readers...), lines, size, nil
+}
+
 // applyPrefix sets the play fix for log lines.
 func applyPrefix(line, prefix string) string {
 	b := &strings.Builder{}
@@ -333,6 +1149,263 @@ func applyPrefix(line, prefix string) string {
 	return b.String()
 }
 
+// neededLabels returns the labels needed to output lines with the option: selected labels and
+// labels referred to by filters, routes, the seen filter, the index and the time range.
+func neededLabels(opt Option) map[string]struct{} {
+	m := map[string]struct{}{}
+	for _, label := range opt.Labels {
+		m[label] = struct{}{}
+	}
+	_, rest := splitLineNumberFilters(opt.Filters, opt.LineNumber)
+	for _, filters := range [][]string{rest, opt.PostFilters, opt.RawFilters} {
+		for _, filter := range filters {
+			m[strings.SplitN(filter, " ", 2)[0]] = struct{}{}
+		}
+	}
+	for _, rule := range opt.Routes {
+		for _, filter := range rule.filters {
+			m[strings.SplitN(filter, " ", 2)[0]] = struct{}{}
+		}
+	}
+	if opt.SeenFilter != nil {
+		m[opt.SeenFilter.Label()] = struct{}{}
+	}
+	if opt.Index != nil {
+		for _, key := range opt.Index.Keys {
+			m[key] = struct{}{}
+		}
+	}
+	if opt.window != nil {
+		m[opt.window.label] = struct{}{}
+	}
+	return m
+}
+
+// prefixPatterns returns the patterns truncated right after the last group of the needed labels, so
+// that matching stops once they are captured. Note that lines only need to match the truncated part.
+// Patterns that are not a simple concatenation, or lack some of the labels, are returned as is.
+func prefixPatterns(patterns []*regexp.Regexp, needed map[string]struct{}) []*regexp.Regexp {
+	ret := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		ret[i] = pattern
+		re, err := syntax.Parse(pattern.String(), syntax.Perl)
+		if err != nil || re.Op != syntax.OpConcat {
+			continue
+		}
+		found := map[string]struct{}{}
+		last := -1
+		for j, sub := range re.Sub {
+			for _, name := range captureNames(sub) {
+				if _, ok := needed[name]; ok {
+					found[name] = struct{}{}
+					last = j
+				}
+			}
+		}
+		if len(found) < len(needed) || last == len(re.Sub)-1 {
+			continue
+		}
+		prefix := &syntax.Regexp{Op: syntax.OpConcat, Flags: re.Flags, Sub: re.Sub[:last+1]}
+		if p, err := regexp.Compile(prefix.String()); err == nil {
+			ret[i] = p
+		}
+	}
+	return ret
+}
+
+// captureNames returns the names of the capture groups in the syntax tree.
+func captureNames(re *syntax.Regexp) []string {
+	var names []string
+	if re.Op == syntax.OpCapture && re.Name != "" {
+		names = append(names, re.Name)
+	}
+	for _, sub := range re.Sub {
+		names = append(names, captureNames(sub)...)
+	}
+	return names
+}
+
+// sharedPrefix returns a pattern without capture groups for the leading sub-expressions shared by all patterns,
+// which a line must match to match any of them. It returns nil if the patterns share nothing but anchors.
+// Note that a single alternation of the patterns turned out to be slower than trying them in turn, since the
+// combined program is too large for the backtracking matcher and falls back to the NFA.
+func sharedPrefix(patterns []*regexp.Regexp) *regexp.Regexp {
+	subs := make([][]*syntax.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		re, err := syntax.Parse(pattern.String(), syntax.Perl)
+		if err != nil {
+			return nil
+		}
+		if re.Op == syntax.OpConcat {
+			subs[i] = re.Sub
+		} else {
+			subs[i] = []*syntax.Regexp{re}
+		}
+	}
+	n := 0
+	literal := false
+shared:
+	for ; n < len(subs[0]); n++ {
+		for _, sub := range subs[1:] {
+			if n >= len(sub) || !sub[n].Equal(subs[0][n]) {
+				break shared
+			}
+		}
+		switch subs[0][n].Op {
+		case syntax.OpBeginLine, syntax.OpBeginText, syntax.OpEmptyMatch:
+		default:
+			literal = true
+		}
+	}
+	if !literal {
+		return nil
+	}
+	prefix := &syntax.Regexp{Op: syntax.OpConcat, Flags: subs[0][0].Flags, Sub: make([]*syntax.Regexp, n)}
+	for i, sub := range subs[0][:n] {
+		prefix.Sub[i] = stripCaptures(sub)
+	}
+	re, err := regexp.Compile(prefix.String())
+	if err != nil {
+		return nil
+	}
+	return re
+}
+
+// stripCaptures returns a copy of the syntax tree with capture groups replaced by their contents.
+func stripCaptures(re *syntax.Regexp) *syntax.Regexp {
+	if re.Op == syntax.OpCapture {
+		return stripCaptures(re.Sub[0])
+	}
+	cp := *re
+	cp.Sub = make([]*syntax.Regexp, len(re.Sub))
+	for i, sub := range re.Sub {
+		cp.Sub[i] = stripCaptures(sub)
+	}
+	return &cp
+}
+
+// guardedLineDecoder wraps the decoder to try the first pattern, and the rest only if the line matches the
+// guard. Lines of the latest format are decoded as before, and other lines not matching the shared prefix are
+// rejected after a single cheap match instead of trying every pattern.
+func guardedLineDecoder(decoder lineDecoder, guard *regexp.Regexp) lineDecoder {
+	return func(line string, patterns []*regexp.Regexp) ([]string, []string, error) {
+		ls, vs, err := decoder(line, patterns[:1])
+		if err == nil || len(patterns) == 1 || !guard.MatchString(line) {
+			return ls, vs, err
+		}
+		return decoder(line, patterns[1:])
+	}
+}
+
+// pushdownKeywords derives keywords that must appear in a line for the filters to be satisfied, from
+// "==" filters on labels whose values appear literally in lines. As with getFilter, the last filter
+// for a label takes effect. Values that may be escaped in lines, such as those with quotes, are skipped.
+func pushdownKeywords(filters []string, derived func(string) bool) []string {
+	m := map[string]string{}
+	for _, filter := range filters {
+		token := strings.SplitN(filter, " ", 3)
+		if len(token) < 3 {
+			continue
+		}
+		label, operator, value := token[0], token[1], token[2]
+		delete(m, label)
+		if operator != "==" || value == "" || strings.ContainsAny(value, "\"\\") {
+			continue
+		}
+		if derived != nil && derived(label) {
+			continue
+		}
+		m[label] = value
+	}
+	keywords := make([]string, 0, len(m))
+	for _, v := range m {
+		keywords = append(keywords, v)
+	}
+	sort.Strings(keywords)
+	return keywords
+}
+
+// containsAll reports whether the line contains all keywords.
+func containsAll(line string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if !strings.Contains(line, keyword) {
+			return false
+		}
+	}
+	return true
+}
+
+// lineLabel is the label of the raw line in Option.LineFilters.
+const lineLabel = "line"
+
+// lineNumberLabel is the label of the line number in Option.Filters, the same as the one added by Option.LineNumber.
+const lineNumberLabel = "no"
+
+// getLineFilters compiles the filter expressions for raw lines. Unlike getFilter, every expression is kept,
+// so that multiple conditions can be put on the line.
+func getLineFilters(filters []string) ([]lineFilter, error) {
+	return getLabelFilters(lineLabel, filters)
+}
+
+// getLineNumberFilters compiles the filter expressions for line numbers, keeping every expression as getLineFilters.
+func getLineNumberFilters(filters []string) ([]lineFilter, error) {
+	return getLabelFilters(lineNumberLabel, filters)
+}
+
+// getLabelFilters compiles the filter expressions on the label, keeping every expression.
+func getLabelFilters(label string, filters []string) ([]lineFilter, error) {
+	fs := make([]lineFilter, 0, len(filters))
+	for _, filter := range filters {
+		m, err := getFilter([]string{label}, []string{filter})
+		if err != nil {
+			return nil, err
+		}
+		fs = append(fs, m[label])
+	}
+	return fs, nil
+}
+
+// splitLineNumberFilters separates the expressions on the line number from the other filter expressions, so that
+// they are evaluated before decoding as a range-based alternative to Option.SkipLines. The label "no" is the line
+// number only with Option.LineNumber, which adds it to the output; otherwise it is a field like any other. An
+// expression on the line number may join several conditions on it with "&&", such as "no > 1000 && no <= 2000".
+func splitLineNumberFilters(filters []string, lineNumber bool) ([]string, []string) {
+	if !lineNumber {
+		return nil, filters
+	}
+	var numbers, rest []string
+	for _, filter := range filters {
+		if !strings.HasPrefix(filter, lineNumberLabel+" ") {
+			rest = append(rest, filter)
+			continue
+		}
+		for _, cond := range strings.Split(filter, "&&") {
+			numbers = append(numbers, strings.TrimSpace(cond))
+		}
+	}
+	return numbers, rest
+}
+
+// validateOptionFilters reports whether the expressions of Option.Filters are valid, including those on the line
+// number with Option.LineNumber.
+func validateOptionFilters(filters []string, lineNumber bool) error {
+	numbers, rest := splitLineNumberFilters(filters, lineNumber)
+	if _, err := getLineNumberFilters(numbers); err != nil {
+		return err
+	}
+	return validateFilters(rest)
+}
+
+// applyLineFilters reports whether the raw line satisfies all the filters.
+func applyLineFilters(line string, filters []lineFilter) (bool, error) {
+	for _, filter := range filters {
+		if ok, err := filter(line); err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
 // applyFilter evaluates a filter expression passed as a string and controls
 // whether or not log lines are output according to the result.
 func applyFilter(labels, values, filters []string) (bool, error) {
@@ -522,26 +1595,52 @@ func handleGzip(gzipPath string) (*gzip.Reader, func(), error) {
 
 // handleZipEntries iterates over entries in a zip file, applying a provided function to each matching entry.
 // It supports glob pattern matching for entry names, enabling selective processing of zip contents.
-func handleZipEntries(zipPath string, globPattern string, fn func(f *zip.File) error) error {
+// Entry names not flagged as UTF-8 are decoded with enc if given, before matching and reporting.
+func handleZipEntries(zipPath string, globPattern string, enc encoding.Encoding, fn func(f *zip.File) error) error {
+	files, cleanup, err := openZipEntries(zipPath, globPattern, enc)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	for _, f := range files {
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openZipEntries opens a zip file and returns the entries matching the glob pattern, with a cleanup function
+// to close the file. Entry names not flagged as UTF-8 are decoded with enc if given, before matching.
+func openZipEntries(zipPath string, globPattern string, enc encoding.Encoding) ([]*zip.File, func(), error) {
 	if zipPath == "" {
-		return fmt.Errorf(emptyPathError)
+		return nil, nil, fmt.Errorf(emptyPathError)
 	}
 	z, err := zip.OpenReader(zipPath)
 	if err != nil {
-		return fmt.Errorf("%s: %w", openFileError, err)
+		return nil, nil, fmt.Errorf("%s: %w", openFileError, err)
 	}
-	defer z.Close()
+	files := make([]*zip.File, 0, len(z.File))
 	for _, f := range z.File {
+		if f.NonUTF8 && enc != nil {
+			name, err := enc.NewDecoder().String(f.Name)
+			if err != nil {
+				z.Close()
+				return nil, nil, fmt.Errorf("%s: %w", entryNameError, err)
+			}
+			f.Name = name
+		}
 		matched, err := filepath.Match(globPattern, f.Name)
 		if err != nil {
-			return fmt.Errorf("%s: %w", globPatternError, err)
-		}
-		if !matched {
-			continue
+			z.Close()
+			return nil, nil, fmt.Errorf("%s: %w", globPatternError, err)
 		}
-		if err := fn(f); err != nil {
-			return err
+		if matched {
+			files = append(files, f)
 		}
 	}
-	return nil
+	cleanup := func() {
+		z.Close()
+	}
+	return files, cleanup, nil
 }
@@ -5,17 +5,26 @@ package parser
 
 import (
 	"archive/zip"
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"regexp/syntax"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
 )
 
 var (
@@ -2064,7 +2073,7 @@ func Test_handleZipEntries(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := handleZipEntries(tt.args.zipPath, tt.args.globPattern, tt.args.fn); (err != nil) != tt.wantErr {
+			if err := handleZipEntries(tt.args.zipPath, tt.args.globPattern, nil, tt.args.fn); (err != nil) != tt.wantErr {
 				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
 			}
 		})
@@ -2700,3 +2709,1234 @@ func Test_getRegexFilter(t *testing.T) {
 		})
 	}
 }
+
+func Test_normalizeQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "numeric literal",
+			query: "SELECT * FROM users WHERE id = 42;",
+			want:  "SELECT * FROM users WHERE id = ?",
+		},
+		{
+			name:  "string literal",
+			query: `UPDATE users SET name = 'o''brien', note = "x" WHERE id = 7`,
+			want:  "UPDATE users SET name = ?, note = ? WHERE id = ?",
+		},
+		{
+			name:  "in list and whitespace",
+			query: "SELECT *\n  FROM t1\n WHERE c IN (1, 2,\t3)",
+			want:  "SELECT * FROM t1 WHERE c IN (?+)",
+		},
+		{
+			name:  "identifier with digits",
+			query: "SELECT c1 FROM t2",
+			want:  "SELECT c1 FROM t2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeQuery(tt.query); got != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_rateLimiter(t *testing.T) {
+	tests := []struct {
+		name    string
+		n       int
+		lines   int
+		ctx     func() (context.Context, context.CancelFunc)
+		wantMin time.Duration
+		wantErr bool
+	}{
+		{
+			name:    "unlimited",
+			n:       0,
+			lines:   100,
+			ctx:     func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
+			wantMin: 0,
+		},
+		{
+			name:    "limited",
+			n:       100,
+			lines:   6,
+			ctx:     func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
+			wantMin: 50 * time.Millisecond,
+		},
+		{
+			name:  "cancelled",
+			n:     1,
+			lines: 3,
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 10*time.Millisecond)
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			l := newRateLimiter(tt.n)
+			start := time.Now()
+			var err error
+			for i := 0; i < tt.lines; i++ {
+				if err = l.wait(ctx); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+				return
+			}
+			if elapsed := time.Since(start); elapsed < tt.wantMin {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", elapsed, tt.wantMin)
+			}
+		})
+	}
+}
+
+func Test_parser_cancel(t *testing.T) {
+	tests := []struct {
+		name      string
+		ctx       func() (context.Context, context.CancelFunc)
+		opt       Option
+		wantTotal int
+		wantErr   error
+	}{
+		{
+			name: "cancelled before start",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+			opt:       Option{LineHandler: JSONLineHandler},
+			wantTotal: 0,
+			wantErr:   context.Canceled,
+		},
+		{
+			name: "deadline exceeded while rate limited",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
+			},
+			opt:       Option{LineHandler: JSONLineHandler, RateLimit: 1},
+			wantTotal: 1,
+			wantErr:   context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			output := &bytes.Buffer{}
+			w := bufio.NewWriter(output)
+			got, err := parseString(ctx, ltsvAllMatchInput, w, nil, ltsvLineDecoder, tt.opt)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if got == nil {
+				t.Fatal("result must be returned on cancellation")
+			}
+			if !got.Cancelled {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Cancelled, true)
+			}
+			if got.Total != tt.wantTotal {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Total, tt.wantTotal)
+			}
+			if lines := strings.Count(output.String(), "\n"); lines != got.Matched {
+				t.Errorf("output must be flushed:\n\ngot:\n%v\nwant:\n%v\n", lines, got.Matched)
+			}
+		})
+	}
+}
+
+func Test_parser_heartbeat(t *testing.T) {
+	tests := []struct {
+		name       string
+		hook       bool
+		wantOutput string
+	}{
+		{
+			name:       "hook",
+			hook:       true,
+			wantOutput: "idle",
+		},
+		{
+			name:       "record",
+			hook:       false,
+			wantOutput: `"heartbeat":`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr, pw := io.Pipe()
+			output := &bytes.Buffer{}
+			opt := Option{LineHandler: JSONLineHandler, Heartbeat: 10 * time.Millisecond}
+			if tt.hook {
+				opt.OnHeartbeat = func(idle time.Duration) (string, error) {
+					if idle < opt.Heartbeat {
+						t.Errorf("\ngot:\n%v\nwant:\n>= %v\n", idle, opt.Heartbeat)
+					}
+					return "idle", nil
+				}
+			}
+			go func() {
+				lines := strings.Split(ltsvAllMatchInput, "\n")
+				fmt.Fprintln(pw, lines[0])
+				time.Sleep(50 * time.Millisecond)
+				fmt.Fprintln(pw, strings.Join(lines[1:], "\n"))
+				pw.Close()
+			}()
+			got, err := parser(context.Background(), pr, output, nil, ltsvLineDecoder, opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Matched != len(ltsvAllMatchData) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Matched, len(ltsvAllMatchData))
+			}
+			if !strings.Contains(output.String(), tt.wantOutput) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func Test_parser_byteOffset(t *testing.T) {
+	input := "a:1\nb:2\r\nc:3\n\nd:4"
+	output := &bytes.Buffer{}
+	opt := Option{LineNumber: true, ByteOffset: true, LineHandler: JSONLineHandler}
+	got, err := parser(context.Background(), strings.NewReader(input), output, nil, ltsvLineDecoder, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		`{"no":"1","offset":"0","a":"1"}`,
+		`{"no":"2","offset":"4","b":"2"}`,
+		`{"no":"3","offset":"9","c":"3"}`,
+		`{"no":"5","offset":"14","d":"4"}`,
+	}, "\n") + "\n"
+	if out := output.String(); !reflect.DeepEqual(out, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", out, want)
+	}
+	if got.Matched != 4 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Matched, 4)
+	}
+}
+
+func Test_parser_rawField(t *testing.T) {
+	input := "a:1\tb:x\na:2\tb:y"
+	tests := []struct {
+		name       string
+		opt        Option
+		wantOutput string
+	}{
+		{
+			name: "all lines",
+			opt:  Option{RawField: "_raw", LineHandler: JSONLineHandler},
+			wantOutput: strings.Join([]string{
+				`{"a":"1","b":"x","_raw":"a:1\tb:x"}`,
+				`{"a":"2","b":"y","_raw":"a:2\tb:y"}`,
+			}, "\n") + "\n",
+		},
+		{
+			name: "filtered lines with labels",
+			opt:  Option{Labels: []string{"b"}, RawField: "_raw", RawFilters: []string{"a == 2"}, LineHandler: JSONLineHandler},
+			wantOutput: strings.Join([]string{
+				`{"b":"x"}`,
+				`{"b":"y","_raw":"a:2\tb:y"}`,
+			}, "\n") + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			if _, err := parser(context.Background(), strings.NewReader(input), output, nil, ltsvLineDecoder, tt.opt); err != nil {
+				t.Fatal(err)
+			}
+			if out := output.String(); !reflect.DeepEqual(out, tt.wantOutput) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", out, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func Test_parseGzip_corrupted(t *testing.T) {
+	buf := &bytes.Buffer{}
+	g := gzip.NewWriter(buf)
+	if _, err := g.Write([]byte(ltsvAllMatchInput)); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+	compressed := buf.Bytes()
+	checksum := slices.Clone(compressed)
+	checksum[len(checksum)-8] ^= 0xff
+	tests := []struct {
+		name        string
+		data        []byte
+		wantMatched int
+		wantOffset  int64
+	}{
+		{
+			name:        "checksum mismatch",
+			data:        checksum,
+			wantMatched: len(ltsvAllMatchData),
+			wantOffset:  int64(len(ltsvAllMatchInput)),
+		},
+		{
+			name:        "truncated",
+			data:        compressed[:len(compressed)-8],
+			wantMatched: len(ltsvAllMatchData),
+			wantOffset:  int64(len(ltsvAllMatchInput)),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gzipPath := filepath.Join(t.TempDir(), "corrupted.log.gz")
+			if err := os.WriteFile(gzipPath, tt.data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			output := &bytes.Buffer{}
+			got, err := parseGzip(context.Background(), gzipPath, output, nil, ltsvLineDecoder, Option{LineHandler: JSONLineHandler})
+			if !errors.Is(err, ErrCorruptedInput) {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, ErrCorruptedInput)
+			}
+			var cerr *CorruptedInputError
+			if !errors.As(err, &cerr) {
+				t.Fatalf("\ngot:\n%T\nwant:\n%T\n", err, cerr)
+			}
+			if cerr.Offset != tt.wantOffset {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", cerr.Offset, tt.wantOffset)
+			}
+			if got == nil {
+				t.Fatal("partial result must be returned on corruption")
+			}
+			if got.Matched != tt.wantMatched {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Matched, tt.wantMatched)
+			}
+			if got.Source != "corrupted.log.gz" {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Source, "corrupted.log.gz")
+			}
+		})
+	}
+}
+
+func Test_parser_partial(t *testing.T) {
+	handlerErr := errors.New("handler error")
+	writerErr := errors.New("writer error")
+	tests := []struct {
+		name        string
+		output      io.Writer
+		handler     LineHandler
+		wantTotal   int
+		wantMatched int
+		wantErr     error
+	}{
+		{
+			name:   "handler error",
+			output: &bytes.Buffer{},
+			handler: func(labels, values []string, isFirst bool) (string, error) {
+				if values[0] == "3" {
+					return "", handlerErr
+				}
+				return JSONLineHandler(labels, values, isFirst)
+			},
+			wantTotal:   2,
+			wantMatched: 2,
+			wantErr:     handlerErr,
+		},
+		{
+			name:        "writer error",
+			output:      &errWriter{err: writerErr},
+			handler:     JSONLineHandler,
+			wantTotal:   0,
+			wantMatched: 0,
+			wantErr:     writerErr,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := "a:1\na:2\na:3\na:4"
+			got, err := parser(context.Background(), strings.NewReader(input), tt.output, nil, ltsvLineDecoder, Option{LineHandler: tt.handler})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if got == nil {
+				t.Fatal("partial result must be returned on error")
+			}
+			if got.Total != tt.wantTotal {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Total, tt.wantTotal)
+			}
+			if got.Matched != tt.wantMatched {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Matched, tt.wantMatched)
+			}
+		})
+	}
+}
+
+func Test_parseZipEntries_sourceTimeout(t *testing.T) {
+	zipPath := writeZip(t, []string{"a.log", "b.log"}, []string{"a:1\na:2\na:3\n", "a:1\na:2\na:3\n"})
+	output := &bytes.Buffer{}
+	opt := Option{LineHandler: JSONLineHandler, RateLimit: 1, SourceTimeout: 20 * time.Millisecond}
+	got, err := parseZipEntries(context.Background(), zipPath, "*", output, nil, ltsvLineDecoder, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Abandoned{
+		{Entry: "a.log", Reason: "timed out after 20ms"},
+		{Entry: "b.log", Reason: "timed out after 20ms"},
+	}
+	if !reflect.DeepEqual(got.Abandoned, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Abandoned, want)
+	}
+	if got.Cancelled {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Cancelled, false)
+	}
+	if got.Matched != 2 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Matched, 2)
+	}
+}
+
+func Test_parser_maxLineSize(t *testing.T) {
+	long := "a:" + strings.Repeat("x", 200*1024)
+	tests := []struct {
+		name        string
+		input       string
+		maxLineSize int
+		wantMatched int
+		wantMaxLen  int
+		wantErr     error
+	}{
+		{
+			name:        "grow beyond the default token size",
+			input:       "a:1\n" + long + "\na:2",
+			maxLineSize: 0,
+			wantMatched: 3,
+			wantMaxLen:  len(long),
+		},
+		{
+			name:        "too long",
+			input:       "a:1\n" + long + "\na:2",
+			maxLineSize: 1024,
+			wantMatched: 1,
+			wantMaxLen:  3,
+			wantErr:     bufio.ErrTooLong,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt := Option{LineHandler: JSONLineHandler, MaxLineSize: tt.maxLineSize}
+			got, err := parser(context.Background(), strings.NewReader(tt.input), io.Discard, nil, ltsvLineDecoder, opt)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if got.Matched != tt.wantMatched {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Matched, tt.wantMatched)
+			}
+			if got.MaxLineLen != tt.wantMaxLen {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.MaxLineLen, tt.wantMaxLen)
+			}
+		})
+	}
+}
+
+func Test_normalizeCRLF(t *testing.T) {
+	tests := []struct {
+		name      string
+		split     bufio.SplitFunc
+		input     string
+		want      []string
+		wantCount int
+	}{
+		{
+			name:      "mixed line endings",
+			split:     bufio.ScanLines,
+			input:     "a:1\r\na:2\na:3\r\na:4\r",
+			want:      []string{"a:1", "a:2", "a:3", "a:4"},
+			wantCount: 3,
+		},
+		{
+			name:      "lf only",
+			split:     bufio.ScanLines,
+			input:     "a:1\na:2\n",
+			want:      []string{"a:1", "a:2"},
+			wantCount: 0,
+		},
+		{
+			name:      "multi-line records",
+			split:     csvRecordSplit,
+			input:     "1,\"x\r\ny\"\r\n2,z\n",
+			want:      []string{"1,\"x\ny\"", "2,z"},
+			wantCount: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := 0
+			scanner := bufio.NewScanner(strings.NewReader(tt.input))
+			scanner.Split(normalizeCRLF(tt.split, &n))
+			var got []string
+			for scanner.Scan() {
+				got = append(got, scanner.Text())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%q\nwant:\n%q\n", got, tt.want)
+			}
+			if n != tt.wantCount {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", n, tt.wantCount)
+			}
+		})
+	}
+}
+
+func Test_isBinary(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{
+			name: "empty",
+			data: []byte{},
+			want: false,
+		},
+		{
+			name: "ascii text",
+			data: []byte("a:1\tb:2\r\n"),
+			want: false,
+		},
+		{
+			name: "multibyte text with escape sequences",
+			data: []byte("\x1b[1;32mこんにちは\x1b[0m\n"),
+			want: false,
+		},
+		{
+			name: "nul byte",
+			data: []byte("a:1\x00b:2\n"),
+			want: true,
+		},
+		{
+			name: "non-printables",
+			data: []byte{0x1f, 0x8b, 0x08, 0x01, 0x02, 0xff, 0xfe, 'a'},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isBinary(tt.data); got != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parser_detectBinary(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		wantMatched int
+		wantErr     error
+	}{
+		{
+			name:        "text",
+			input:       ltsvAllMatchInput,
+			wantMatched: len(ltsvAllMatchData),
+		},
+		{
+			name:    "binary",
+			input:   "\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\x03",
+			wantErr: ErrBinaryInput,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt := Option{LineHandler: JSONLineHandler, DetectBinary: true}
+			got, err := parser(context.Background(), strings.NewReader(tt.input), io.Discard, nil, ltsvLineDecoder, opt)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if got.Matched != tt.wantMatched {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Matched, tt.wantMatched)
+			}
+			if got.Unmatched != 0 {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Unmatched, 0)
+			}
+		})
+	}
+}
+
+func Test_parseZipEntries_detectBinary(t *testing.T) {
+	zipPath := writeZip(t, []string{"a.log", "b.bin", "c.log"}, []string{"a:1\n", "\x00\x01\x02\x03", "a:2\n"})
+	opt := Option{LineHandler: JSONLineHandler, DetectBinary: true}
+	got, err := parseZipEntries(context.Background(), zipPath, "*", io.Discard, nil, ltsvLineDecoder, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Abandoned{{Entry: "b.bin", Reason: ErrBinaryInput.Error()}}
+	if !reflect.DeepEqual(got.Abandoned, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Abandoned, want)
+	}
+	if got.Matched != 2 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Matched, 2)
+	}
+}
+
+func writeZip(t *testing.T, names, contents []string) string {
+	t.Helper()
+	zipPath := filepath.Join(t.TempDir(), "test.zip")
+	f, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for i, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(contents[i])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return zipPath
+}
+
+func Test_parseZipEntries_zipNameEncoding(t *testing.T) {
+	name, err := japanese.ShiftJIS.NewEncoder().String("アクセスログ.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipPath := writeZip(t, []string{name, "other.log"}, []string{"a:1\n", "a:2\n"})
+	tests := []struct {
+		name           string
+		enc            encoding.Encoding
+		wantZipEntries []string
+	}{
+		{
+			name:           "decoded",
+			enc:            japanese.ShiftJIS,
+			wantZipEntries: []string{"アクセスログ.log"},
+		},
+		{
+			name:           "as is",
+			enc:            nil,
+			wantZipEntries: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt := Option{LineHandler: JSONLineHandler, ZipNameEncoding: tt.enc}
+			got, err := parseZipEntries(context.Background(), zipPath, "アクセス*", io.Discard, nil, ltsvLineDecoder, opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.ZipEntries, tt.wantZipEntries) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.ZipEntries, tt.wantZipEntries)
+			}
+		})
+	}
+}
+
+func Test_pushdownKeywords(t *testing.T) {
+	type args struct {
+		filters []string
+		derived func(string) bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want []string
+	}{
+		{
+			name: "equality",
+			args: args{filters: []string{"b == y", "a == x"}},
+			want: []string{"x", "y"},
+		},
+		{
+			name: "other operators",
+			args: args{filters: []string{"a != x", "b ==* y", "c =~ ^z", "d > 1"}},
+			want: []string{},
+		},
+		{
+			name: "last filter for a label takes effect",
+			args: args{filters: []string{"a == x", "a != y"}},
+			want: []string{},
+		},
+		{
+			name: "escaped values",
+			args: args{filters: []string{`a == "x"`, `b == x\y`}},
+			want: []string{},
+		},
+		{
+			name: "derived labels",
+			args: args{filters: []string{"a == x", "query_digest == y"}, derived: func(label string) bool { return label == "query_digest" }},
+			want: []string{"x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pushdownKeywords(tt.args.filters, tt.args.derived); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parser_pushdown(t *testing.T) {
+	input := "a:1\tb:x\na:2\tb:y\na:3\tb:x"
+	for _, pushdown := range []bool{false, true} {
+		t.Run(fmt.Sprintf("pushdown=%t", pushdown), func(t *testing.T) {
+			output := &bytes.Buffer{}
+			opt := Option{Filters: []string{"b == x"}, Pushdown: pushdown, LineHandler: JSONLineHandler}
+			got, err := parser(context.Background(), strings.NewReader(input), output, nil, ltsvLineDecoder, opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := `{"a":"1","b":"x"}` + "\n" + `{"a":"3","b":"x"}` + "\n"
+			if out := output.String(); out != want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", out, want)
+			}
+			if got.Matched != 2 || got.Excluded != 1 {
+				t.Errorf("\ngot:\n%v %v\nwant:\n%v %v\n", got.Matched, got.Excluded, 2, 1)
+			}
+		})
+	}
+}
+
+func Test_prefixPatterns(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		needed  []string
+		want    string
+	}{
+		{
+			name:    "truncated",
+			pattern: `^(?P<a>\w+) (?P<b>\w+) (?P<c>\w+)$`,
+			needed:  []string{"a"},
+			want:    `^(?P<a>\w+)`,
+		},
+		{
+			name:    "truncated after the last needed group",
+			pattern: `^(?P<a>\w+) (?P<b>\w+) (?P<c>\w+)$`,
+			needed:  []string{"b", "a"},
+			want:    `^(?P<a>\w+) (?P<b>\w+)`,
+		},
+		{
+			name:    "trailing anchor",
+			pattern: `^(?P<a>\w+) (?P<b>\w+)$`,
+			needed:  []string{"b"},
+			want:    `^(?P<a>\w+) (?P<b>\w+)`,
+		},
+		{
+			name:    "unknown label",
+			pattern: `^(?P<a>\w+) (?P<b>\w+)$`,
+			needed:  []string{"a", "x"},
+			want:    `^(?P<a>\w+) (?P<b>\w+)$`,
+		},
+		{
+			name:    "alternation",
+			pattern: `(?P<a>\w+)|(?P<b>\d+)`,
+			needed:  []string{"a"},
+			want:    `(?P<a>\w+)|(?P<b>\d+)`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			needed := map[string]struct{}{}
+			for _, label := range tt.needed {
+				needed[label] = struct{}{}
+			}
+			got := prefixPatterns([]*regexp.Regexp{regexp.MustCompile(tt.pattern)}, needed)
+			want := regexp.MustCompile(tt.want)
+			for _, s := range []string{"foo bar baz", "foo bar"} {
+				gm, wm := got[0].FindStringSubmatch(s), want.FindStringSubmatch(s)
+				if !reflect.DeepEqual(gm, wm) {
+					t.Errorf("%q:\ngot:\n%q (%s)\nwant:\n%q (%s)\n", s, gm, got[0], wm, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_parser_lazyDecode(t *testing.T) {
+	opt := Option{Labels: []string{"bucket", "http_status"}, Filters: []string{"method == GET"}, LineHandler: JSONLineHandler}
+	want := &bytes.Buffer{}
+	if _, err := NewS3RegexParser(context.Background(), want, opt).ParseString(regexAllMatchInput); err != nil {
+		t.Fatal(err)
+	}
+	opt.LazyDecode = true
+	got := &bytes.Buffer{}
+	if _, err := NewS3RegexParser(context.Background(), got, opt).ParseString(regexAllMatchInput); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.String(), want.String())
+	}
+}
+
+func Test_sharedPrefix(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		want     string
+	}{
+		{
+			name:     "shared fields",
+			patterns: []string{`^(?P<a>\S+) (?P<b>\S+) (?P<c>\S+)$`, `^(?P<a>\S+) (?P<b>\S+)$`},
+			want:     `^\S+ \S+`,
+		},
+		{
+			name:     "anchor only",
+			patterns: []string{`^(?P<a>\d+)`, `^(?P<b>[a-z]+)`},
+			want:     "",
+		},
+		{
+			name:     "nothing shared",
+			patterns: []string{`(?P<a>\d+)`, `(?P<b>[a-z]+)`},
+			want:     "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patterns := make([]*regexp.Regexp, len(tt.patterns))
+			for i, pattern := range tt.patterns {
+				patterns[i] = regexp.MustCompile(pattern)
+			}
+			got := sharedPrefix(patterns)
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, nil)
+				}
+				return
+			}
+			want, err := syntax.Parse(tt.want, syntax.Perl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got == nil || got.String() != want.String() {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parser_mergePatterns(t *testing.T) {
+	var mixed []string
+	for i, line := range strings.Split(regexAllMatchInput, "\n") {
+		fields := strings.Split(line, " ")
+		mixed = append(mixed, strings.Join(fields[:len(fields)-i], " "))
+	}
+	mixed = append(mixed, "garbage", strings.Repeat("x", 300))
+	clf := `123.45.67.89 - frank zappa [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08 [en] (Win98; I ;Nav)"
+123.45.67.89 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326
+123.45.67.89	-	frank	[10/Oct/2000:13:55:36 -0700]	"GET /apache_pb.gif HTTP/1.0"	200	2326
+123.45.67.89 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200`
+	tests := []struct {
+		name  string
+		new   func(ctx context.Context, w io.Writer, opt Option) *RegexParser
+		input string
+	}{
+		{
+			name:  "s3",
+			new:   NewS3RegexParser,
+			input: strings.Join(mixed, "\n"),
+		},
+		{
+			name:  "clf",
+			new:   NewApacheCLFRegexParser,
+			input: clf,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := &bytes.Buffer{}
+			wr, err := tt.new(context.Background(), want, Option{}).ParseString(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := &bytes.Buffer{}
+			gr, err := tt.new(context.Background(), got, Option{MergePatterns: true}).ParseString(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != want.String() {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.String(), want.String())
+			}
+			if gr.Matched != wr.Matched || gr.Unmatched != wr.Unmatched {
+				t.Errorf("\ngot:\n%d/%d\nwant:\n%d/%d\n", gr.Matched, gr.Unmatched, wr.Matched, wr.Unmatched)
+			}
+		})
+	}
+}
+
+func Test_parser_filterPhases(t *testing.T) {
+	input := strings.Join([]string{
+		"path:/health\tstatus:200\tsize:10",
+		"path:/a\tstatus:500\tsize:20",
+		"path:/b\tstatus:200\tsize:30",
+		"path:/c\tstatus:200\tsize:40",
+		"invalid",
+	}, "\n")
+	tests := []struct {
+		name         string
+		opt          Option
+		want         []string
+		wantExcluded int
+		wantErr      bool
+	}{
+		{
+			name: "line filters",
+			opt:  Option{LineFilters: []string{"line !~ /health", "line =~ status:200"}, Labels: []string{"path"}},
+			want: []string{
+				`{"path":"/b"}`,
+				`{"path":"/c"}`,
+			},
+			wantExcluded: 3,
+		},
+		{
+			name: "all phases",
+			opt: Option{
+				LineFilters: []string{"line !~ /health"},
+				Filters:     []string{"status == 200"},
+				PostFilters: []string{"size > 30"},
+				Labels:      []string{"path"},
+			},
+			want: []string{
+				`{"path":"/c"}`,
+			},
+			wantExcluded: 3,
+		},
+		{
+			name:    "invalid line filter",
+			opt:     Option{LineFilters: []string{"status == 200"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			r, err := NewLTSVParser(context.Background(), buf, tt.opt).ParseString(input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+			if r.Excluded != tt.wantExcluded {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", r.Excluded, tt.wantExcluded)
+			}
+		})
+	}
+}
+
+func Test_parseZipEntries_concurrency(t *testing.T) {
+	names := make([]string, 8)
+	contents := make([]string, 8)
+	for i := range names {
+		names[i] = fmt.Sprintf("%d.log", i)
+		contents[i] = strings.Repeat(fmt.Sprintf("a:%d\tb:x\n", i), 100*(8-i)) + "invalid\n"
+	}
+	zipPath := writeZip(t, names, contents)
+	want := &bytes.Buffer{}
+	wr, err := NewLTSVParser(context.Background(), want, Option{LineNumber: true}).ParseZipEntries(zipPath, "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &bytes.Buffer{}
+	gr, err := NewLTSVParser(context.Background(), got, Option{LineNumber: true, Concurrency: 4}).ParseZipEntries(zipPath, "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("output differs from sequential parsing")
+	}
+	gr.ElapsedTime, wr.ElapsedTime = 0, 0
+	gr.PeakMemory, wr.PeakMemory = 0, 0
+	if !reflect.DeepEqual(gr, wr) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", gr, wr)
+	}
+	if _, err := NewLTSVParser(context.Background(), got, Option{Concurrency: 4}).ParseZipEntries(zipPath, "["); err == nil {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "error")
+	}
+}
+
+func Test_parseZipEntries_memoryLimit(t *testing.T) {
+	names := make([]string, 8)
+	contents := make([]string, 8)
+	for i := range names {
+		names[i] = fmt.Sprintf("%d.log", i)
+		contents[i] = strings.Repeat(fmt.Sprintf("a:%d\tb:x\n", i), 500*(8-i)) + "invalid\n"
+	}
+	zipPath := writeZip(t, names, contents)
+	want := &bytes.Buffer{}
+	wr, err := NewLTSVParser(context.Background(), want, Option{LineNumber: true, Concurrency: 4}).ParseZipEntries(zipPath, "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &bytes.Buffer{}
+	const limit = 16 * 1024
+	gr, err := NewLTSVParser(context.Background(), got, Option{LineNumber: true, Concurrency: 4, MemoryLimit: limit}).ParseZipEntries(zipPath, "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("output differs from parsing without the limit")
+	}
+	if gr.PeakMemory > limit || gr.PeakMemory >= wr.PeakMemory {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", gr.PeakMemory, fmt.Sprintf("<= %d and < %d", limit, wr.PeakMemory))
+	}
+}
+
+func Test_parser_memoryLimit(t *testing.T) {
+	input := strings.Repeat("invalid line\n", 100)
+	r, err := NewLTSVParser(context.Background(), io.Discard, Option{}).ParseString(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Errors) != 100 || r.DroppedErrors != 0 || r.PeakMemory != 100*(12+errorOverhead) {
+		t.Errorf("\ngot:\n%v %v %v\nwant:\n%v %v %v\n", len(r.Errors), r.DroppedErrors, r.PeakMemory, 100, 0, 100*(12+errorOverhead))
+	}
+	r, err = NewLTSVParser(context.Background(), io.Discard, Option{MemoryLimit: 10 * (12 + errorOverhead)}).ParseString(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Errors) != 10 || r.DroppedErrors != 90 || r.Unmatched != 100 || r.Errors[9].LineNumber != 10 {
+		t.Errorf("\ngot:\n%v %v %v\nwant:\n%v %v %v\n", len(r.Errors), r.DroppedErrors, r.Unmatched, 10, 90, 100)
+	}
+	if !strings.Contains(r.String(), "DroppedErrors") {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", r.String(), "DroppedErrors in the summary")
+	}
+}
+
+func Test_parser_maxErrors(t *testing.T) {
+	input := strings.Repeat("invalid line\n", 100)
+	r, err := NewLTSVParser(context.Background(), io.Discard, Option{MaxErrors: 10}).ParseString(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Errors) != 10 || r.DroppedErrors != 90 || r.Unmatched != 100 || r.Errors[9].LineNumber != 10 {
+		t.Errorf("\ngot:\n%v %v %v\nwant:\n%v %v %v\n", len(r.Errors), r.DroppedErrors, r.Unmatched, 10, 90, 100)
+	}
+}
+
+func Test_parser_snapshot(t *testing.T) {
+	tests := []struct {
+		name       string
+		hook       bool
+		wantOutput string
+	}{
+		{
+			name:       "hook",
+			hook:       true,
+			wantOutput: "snapshot 1",
+		},
+		{
+			name:       "record",
+			hook:       false,
+			wantOutput: `"total":"1","matched":"1"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr, pw := io.Pipe()
+			output := &bytes.Buffer{}
+			opt := Option{LineHandler: JSONLineHandler, Snapshot: 10 * time.Millisecond}
+			if tt.hook {
+				opt.OnSnapshot = func(s Snapshot) (string, error) {
+					if s.Elapsed < opt.Snapshot || s.HeapAlloc == 0 {
+						t.Errorf("\ngot:\n%v %v\nwant:\n>= %v and heap\n", s.Elapsed, s.HeapAlloc, opt.Snapshot)
+					}
+					return fmt.Sprintf("snapshot %d", s.Total), nil
+				}
+			}
+			go func() {
+				lines := strings.Split(ltsvAllMatchInput, "\n")
+				fmt.Fprintln(pw, lines[0])
+				time.Sleep(50 * time.Millisecond)
+				fmt.Fprintln(pw, strings.Join(lines[1:], "\n"))
+				pw.Close()
+			}()
+			got, err := parser(context.Background(), pr, output, nil, ltsvLineDecoder, opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Matched != len(ltsvAllMatchData) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Matched, len(ltsvAllMatchData))
+			}
+			// A single snapshot is taken when the second line arrives, after the first one was parsed.
+			if strings.Count(output.String(), tt.wantOutput) != 1 {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", output.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func Test_parser_lineNumberFilter(t *testing.T) {
+	input := "host:a\nhost:b\nhost:c\nhost:d\nhost:e\nhost:f\nhost:g\n"
+	tests := []struct {
+		name     string
+		filters  []string
+		want     string
+		excluded int
+		wantErr  bool
+	}{
+		{
+			name:     "range",
+			filters:  []string{"no > 2 && no <= 5", "host != d"},
+			want:     "{\"no\":\"3\",\"host\":\"c\"}\n{\"no\":\"5\",\"host\":\"e\"}\n",
+			excluded: 5,
+		},
+		{
+			name:     "separate expressions",
+			filters:  []string{"no >= 6", "no < 7"},
+			want:     "{\"no\":\"6\",\"host\":\"f\"}\n",
+			excluded: 6,
+		},
+		{
+			name:    "invalid value",
+			filters: []string{"no > 2 && no < x"},
+			wantErr: true,
+		},
+		{
+			name:    "other label",
+			filters: []string{"no > 2 && host == a"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			opt := Option{Filters: tt.filters, LineNumber: true}
+			if err := opt.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			r, err := NewLTSVParser(context.Background(), buf, opt).ParseString(input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if buf.String() != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", buf.String(), tt.want)
+			}
+			if r.Excluded != tt.excluded {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", r.Excluded, tt.excluded)
+			}
+		})
+	}
+}
+
+func Test_parser_lineNumberFilter_field(t *testing.T) {
+	input := "no:10\thost:a\nno:20\thost:b\nno:30\thost:c\n"
+	opt := Option{Filters: []string{"no >= 20"}}
+	if err := opt.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	r, err := NewLTSVParser(context.Background(), buf, opt).ParseString(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{\"no\":\"20\",\"host\":\"b\"}\n{\"no\":\"30\",\"host\":\"c\"}\n"
+	if buf.String() != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", buf.String(), want)
+	}
+	if r.Excluded != 1 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", r.Excluded, 1)
+	}
+}
+
+func Test_parser_emitWhen(t *testing.T) {
+	input := "key:a.txt\terror_code:-\treferer:\tbytes_sent:-\nkey:b.txt\terror_code:NoSuchKey\treferer:https://example.com/\tbytes_sent:120\n"
+	buf := &bytes.Buffer{}
+	opt := Option{EmitWhen: []string{"error_code != -", "referer != ", "bytes_sent > 0"}}
+	if _, err := NewLTSVParser(context.Background(), buf, opt).ParseString(input); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"key":"a.txt"}` + "\n" + `{"key":"b.txt","error_code":"NoSuchKey","referer":"https://example.com/","bytes_sent":"120"}` + "\n"
+	if buf.String() != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", buf.String(), want)
+	}
+	if _, err := NewLTSVParser(context.Background(), buf, Option{EmitWhen: []string{"referer"}}).ParseString(input); err == nil {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "invalid syntax")
+	}
+}
+
+func Test_parser_ranges(t *testing.T) {
+	input := "host:a\nhost:b\nbroken\nhost:d\nhost:e\nhost:f\nhost:g\n"
+	tests := []struct {
+		name  string
+		opt   Option
+		want  []string
+		total int
+	}{
+		{
+			name:  "skip ranges",
+			opt:   Option{SkipRanges: [][2]int{{2, 3}, {5, 6}}},
+			want:  []string{"1:a", "4:d", "7:g"},
+			total: 7,
+		},
+		{
+			name:  "offset and limit",
+			opt:   Option{Offset: 1, Limit: 3},
+			want:  []string{"2:b", "4:d", "5:e"},
+			total: 5,
+		},
+		{
+			name:  "head",
+			opt:   Option{Head: 2},
+			want:  []string{"1:a", "2:b"},
+			total: 2,
+		},
+		{
+			name:  "tail",
+			opt:   Option{Tail: 3, ByteOffset: true},
+			want:  []string{"5:e", "6:f", "7:g"},
+			total: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opt.LineNumber = true
+			tt.opt.LineHandler = func(labels, values []string, _ bool) (string, error) {
+				if tt.opt.ByteOffset {
+					off, _ := strconv.Atoi(values[1])
+					if !strings.HasPrefix(input[off:], labels[2]+":"+values[2]+"\n") {
+						return "", fmt.Errorf("wrong offset %d", off)
+					}
+					values = append(values[:1], values[2:]...)
+				}
+				return values[0] + ":" + values[1], nil
+			}
+			buf := &bytes.Buffer{}
+			r, err := NewLTSVParser(context.Background(), buf, tt.opt).ParseString(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Fields(buf.String()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+			if r.Total != tt.total {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", r.Total, tt.total)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package parser
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var _ Parser = (*CSVParser)(nil)
+
+// CSVParser implements the Parser interface for parsing logs in CSV (Comma-separated Values) format.
+// Columns are mapped to the labels given at construction, and quoted values may span multiple lines.
+type CSVParser struct {
+	ctx         context.Context
+	w           io.Writer
+	lineDecoder lineDecoder
+	opt         Option
+}
+
+// NewCSVParser initializes a new CSVParser that assigns the given labels to the columns in order.
+// Records with fewer columns than labels are accepted, while records with more columns are treated as unmatched.
+func NewCSVParser(ctx context.Context, w io.Writer, labels []string, opt Option) *CSVParser {
+	p := &CSVParser{
+		ctx:         ctx,
+		w:           w,
+		lineDecoder: csvLineDecoder(labels),
+		opt:         opt,
+	}
+	p.opt.header = &headerState{}
+	p.opt.split = csvRecordSplit
+	p.opt.project = func(needed map[string]struct{}) lineDecoder {
+		return csvProjectedLineDecoder(labels, needed)
+	}
+	if opt.LineHandler == nil {
+		p.opt.LineHandler = JSONLineHandler
+	}
+	return p
+}
+
+// NewPostgresCSVParser initializes a new CSVParser for parsing PostgreSQL csvlog output.
+// In addition to the csvlog columns, it extracts "query_time" (in seconds), "statement", and "query_digest"
+// from the messages produced by log_min_duration_statement. Line numbers in the result refer to records.
+func NewPostgresCSVParser(ctx context.Context, w io.Writer, opt Option) *CSVParser {
+	p := NewCSVParser(ctx, w, postgresCSVLabels, opt)
+	p.lineDecoder = postgresCSVLineDecoder
+	p.opt.derived = func(label string) bool { return label == "query_time" || label == "query_digest" }
+	return p
+}
+
+// Parse processes log data from an io.Reader, applying the configured line handlers.
+// This method supports context cancellation, prefixing of lines, and exclusion of specific lines.
+func (p *CSVParser) Parse(reader io.Reader) (*Result, error) {
+	return parse(p.ctx, reader, p.w, nil, p.lineDecoder, p.opt)
+}
+
+// Records returns an iterator over the records decoded from reader, as label and value pairs after filters,
+// enrichers and label selection, instead of writing serialized lines to the output. Unmatched lines are skipped.
+func (p *CSVParser) Records(reader io.Reader) RecordSeq {
+	return records(p.ctx, reader, nil, p.lineDecoder, p.opt)
+}
+
+// Explain runs the line through the configured pipeline and returns the decoded fields, the result of each
+// filter expression, the conversions applied and the output, without writing to the output of the parser.
+func (p *CSVParser) Explain(line string) Explanation {
+	return explain(p.ctx, line, nil, p.lineDecoder, p.opt)
+}
+
+// ParseString processes a log string directly, applying configured skip lines and line number handling.
+// It's designed for quick parsing of a single CSV formatted log string.
+func (p *CSVParser) ParseString(s string) (*Result, error) {
+	return parseString(p.ctx, s, p.w, nil, p.lineDecoder, p.opt)
+}
+
+// ParseFile reads and parses log data from a file, leveraging the configured labels and handlers.
+// This method simplifies file-based CSV log parsing with automatic record processing.
+func (p *CSVParser) ParseFile(filePath string) (*Result, error) {
+	return parseFile(p.ctx, filePath, p.w, nil, p.lineDecoder, p.opt)
+}
+
+// ParseGzip processes gzip-compressed log data, extending the parser's capabilities to compressed CSV logs.
+// It applies skip lines and line number handling as configured for gzip-compressed files.
+func (p *CSVParser) ParseGzip(gzipPath string) (*Result, error) {
+	return parseGzip(p.ctx, gzipPath, p.w, nil, p.lineDecoder, p.opt)
+}
+
+// ParseZipEntries processes log data within zip archive entries, applying skip lines, line number handling,
+// and optional glob pattern matching. This method is ideal for batch processing of CSV logs in zip files.
+func (p *CSVParser) ParseZipEntries(zipPath, globPattern string) (*Result, error) {
+	return parseZipEntries(p.ctx, zipPath, globPattern, p.w, nil, p.lineDecoder, p.opt)
+}
+
+// ParseTimeRangeIndexed processes the lines of a time-sorted CSV log file between from and to inclusive, using the
+// index saved at IndexPath(filePath) to read only the byte range that may contain them. The first key of the index
+// is regarded as the time field. Plain and gzip-compressed files are supported.
+func (p *CSVParser) ParseTimeRangeIndexed(filePath string, from, to time.Time) (*Result, error) {
+	return parseTimeRangeIndexed(p.ctx, filePath, from, to, p.w, nil, p.lineDecoder, p.opt)
+}
+
+// SetWriters replaces the output with the writers, all of which the lines are written to, failing the parse if
+// any of them fails. Use a Tee from NewTee to add best-effort writers whose failures are tolerated instead.
+// The header written once with Option.HeaderOnce is written again to the new output.
+func (p *CSVParser) SetWriters(w ...io.Writer) {
+	p.w = tee(w)
+	p.opt.header.reset()
+}
+
+// SetLineHandler replaces the handler converting the decoded CSV lines, such as with TSVLineHandler.
+// A nil handler restores the default JSONLineHandler.
+func (p *CSVParser) SetLineHandler(handler LineHandler) {
+	if handler == nil {
+		handler = JSONLineHandler
+	}
+	p.opt.LineHandler = handler
+	p.opt.header.reset()
+}
+
+// withContext returns a copy of the parser running with the context returned by wrap for its context.
+func (p *CSVParser) withContext(wrap func(ctx context.Context) context.Context) Parser {
+	q := *p
+	q.ctx = wrap(p.ctx)
+	return &q
+}
+
+// postgresCSVLabels lists the csvlog columns in order. Older servers emit only a leading subset of them.
+var postgresCSVLabels = []string{
+	"log_time", "user_name", "database_name", "process_id", "connection_from", "session_id", "session_line_num",
+	"command_tag", "session_start_time", "virtual_transaction_id", "transaction_id", "error_severity",
+	"sql_state_code", "message", "detail", "hint", "internal_query", "internal_query_pos", "context", "query",
+	"query_pos", "location", "application_name", "backend_type", "leader_pid", "query_id",
+}
+
+// postgresDuration matches the message emitted by log_min_duration_statement.
+var postgresDuration = regexp.MustCompile(`(?s)^duration: ([\d.]+) ms(?:\s+(?:statement|execute [^:]*|bind [^:]*|parse [^:]*): (.*))?$`)
+
+// csvLineDecoder returns a lineDecoder that splits a CSV record into values and pairs them with the given labels.
+func csvLineDecoder(labels []string) lineDecoder {
+	return func(line string, _ []*regexp.Regexp) ([]string, []string, error) {
+		r := csv.NewReader(strings.NewReader(line))
+		r.FieldsPerRecord = -1
+		r.LazyQuotes = true
+		vs, err := r.Read()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", parseError, err)
+		}
+		if len(vs) > len(labels) {
+			return nil, nil, fmt.Errorf("%s: too many fields: %d", parseError, len(vs))
+		}
+		ls := make([]string, len(vs))
+		copy(ls, labels)
+		return ls, vs, nil
+	}
+}
+
+// csvProjectedLineDecoder returns a lineDecoder for CSV that materializes only the values of the needed labels.
+// The record reader is reused across lines, and the number of fields is checked as with csvLineDecoder.
+func csvProjectedLineDecoder(labels []string, needed map[string]struct{}) lineDecoder {
+	var index []int
+	for i, label := range labels {
+		if _, ok := needed[label]; ok {
+			index = append(index, i)
+		}
+	}
+	sr := &strings.Reader{}
+	r := csv.NewReader(sr)
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	r.ReuseRecord = true
+	return func(line string, _ []*regexp.Regexp) ([]string, []string, error) {
+		sr.Reset(line)
+		fields, err := r.Read()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", parseError, err)
+		}
+		if len(fields) > len(labels) {
+			return nil, nil, fmt.Errorf("%s: too many fields: %d", parseError, len(fields))
+		}
+		ls := make([]string, 0, len(index))
+		vs := make([]string, 0, len(index))
+		for _, i := range index {
+			if i >= len(fields) {
+				break
+			}
+			ls = append(ls, labels[i])
+			vs = append(vs, fields[i])
+		}
+		return ls, vs, nil
+	}
+}
+
+// postgresCSVLineDecoder decodes a PostgreSQL csvlog record and appends the slow query fields
+// extracted from the message column.
+func postgresCSVLineDecoder(line string, patterns []*regexp.Regexp) ([]string, []string, error) {
+	ls, vs, err := csvLineDecoder(postgresCSVLabels)(line, patterns)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(vs) < 23 {
+		return nil, nil, fmt.Errorf("%s: too few fields: %d", parseError, len(vs))
+	}
+	var queryTime, statement, digest string
+	if m := postgresDuration.FindStringSubmatch(vs[13]); m != nil {
+		if ms, err := strconv.ParseFloat(m[1], 64); err == nil {
+			queryTime = strconv.FormatFloat(ms/1000, 'f', -1, 64)
+		}
+		statement = strings.TrimSpace(m[2])
+		if statement != "" {
+			digest = normalizeQuery(statement)
+		}
+	}
+	ls = append(ls, "query_time", "statement", "query_digest")
+	vs = append(vs, queryTime, statement, digest)
+	return ls, vs, nil
+}
@@ -0,0 +1,167 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestNewCSVParser(t *testing.T) {
+	type parserArgs struct {
+		labels []string
+		input  string
+	}
+	tests := []struct {
+		name       string
+		parserArgs parserArgs
+		want       string
+	}{
+		{
+			name: "basic",
+			parserArgs: parserArgs{
+				labels: []string{"host", "status", "message"},
+				input:  "192.0.2.1,200,ok\n192.0.2.2,500,\"failed, retrying\"",
+			},
+			want: `{"host":"192.0.2.1","status":"200","message":"ok"}
+{"host":"192.0.2.2","status":"500","message":"failed, retrying"}
+`,
+		},
+		{
+			name: "quoted newline",
+			parserArgs: parserArgs{
+				labels: []string{"host", "message"},
+				input:  "192.0.2.1,\"first\nsecond\"\n192.0.2.2,third",
+			},
+			want: `{"host":"192.0.2.1","message":"first\nsecond"}
+{"host":"192.0.2.2","message":"third"}
+`,
+		},
+		{
+			name: "fewer fields than labels",
+			parserArgs: parserArgs{
+				labels: []string{"host", "status", "message"},
+				input:  "192.0.2.1,200",
+			},
+			want: `{"host":"192.0.2.1","status":"200"}
+`,
+		},
+		{
+			name: "more fields than labels",
+			parserArgs: parserArgs{
+				labels: []string{"host"},
+				input:  "192.0.2.1,200",
+			},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			p := NewCSVParser(context.Background(), w, tt.parserArgs.labels, Option{})
+			_, err := p.ParseString(tt.parserArgs.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := w.String(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPostgresCSVParser(t *testing.T) {
+	type parserArgs struct {
+		input string
+		opt   Option
+	}
+	tests := []struct {
+		name       string
+		parserArgs parserArgs
+		want       string
+	}{
+		{
+			name: "slow statement",
+			parserArgs: parserArgs{
+				input: `2024-03-10 12:00:00.123 UTC,"app","shop",1234,"192.0.2.1:5432",65edb2a0.4d2,3,"SELECT",2024-03-10 11:59:00 UTC,3/42,0,LOG,00000,"duration: 2001.500 ms  statement: SELECT *
+FROM orders WHERE id = 42",,,,,,,,,"psql","client backend",,0`,
+				opt: Option{
+					Labels: []string{"log_time", "user_name", "query_time", "statement", "query_digest"},
+				},
+			},
+			want: `{"log_time":"2024-03-10 12:00:00.123 UTC","user_name":"app","query_time":"2.0015","statement":"SELECT *\nFROM orders WHERE id = 42","query_digest":"SELECT * FROM orders WHERE id = ?"}
+`,
+		},
+		{
+			name: "other message",
+			parserArgs: parserArgs{
+				input: `2024-03-10 12:00:01.000 UTC,,,1200,,65edb2a0.4b0,1,,2024-03-10 11:00:00 UTC,,0,LOG,00000,"checkpoint starting: time",,,,,,,,,"","checkpointer",,0`,
+				opt: Option{
+					Labels: []string{"error_severity", "message", "query_time", "statement"},
+				},
+			},
+			want: `{"error_severity":"LOG","message":"checkpoint starting: time","query_time":"","statement":""}
+`,
+		},
+		{
+			name: "unmatch",
+			parserArgs: parserArgs{
+				input: `2024-03-10 12:00:01.000 UTC,,,1200`,
+			},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			p := NewPostgresCSVParser(context.Background(), w, tt.parserArgs.opt)
+			_, err := p.ParseString(tt.parserArgs.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := w.String(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_csvProjectedLineDecoder(t *testing.T) {
+	labels := []string{"host", "status", "message"}
+	input := "192.0.2.1,200,ok\n192.0.2.2,500,\"failed, retrying\"\n192.0.2.3,404\n192.0.2.4,200,ok,extra"
+	tests := []struct {
+		name string
+		opt  Option
+	}{
+		{
+			name: "labels",
+			opt:  Option{Labels: []string{"message", "host"}},
+		},
+		{
+			name: "labels with filters",
+			opt:  Option{Labels: []string{"status"}, Filters: []string{"host == 192.0.2.2"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := &bytes.Buffer{}
+			full := NewCSVParser(context.Background(), want, labels, tt.opt)
+			full.opt.project = nil
+			wr, err := full.ParseString(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := &bytes.Buffer{}
+			gr, err := NewCSVParser(context.Background(), got, labels, tt.opt).ParseString(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != want.String() {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.String(), want.String())
+			}
+			if !reflect.DeepEqual(gr.Errors, wr.Errors) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", gr.Errors, wr.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+)
+
+var _ Parser = (*FixedWidthParser)(nil)
+
+// FixedColumn is a column of fixed-width logs, the byte range [Start, End) of the lines with the label.
+type FixedColumn struct {
+	Label string `json:"label"` // label of the column
+	Start int    `json:"start"` // offset of the first byte of the column
+	End   int    `json:"end"`   // offset right after the last byte of the column (0 means up to the end of the line)
+}
+
+// FixedWidthParser implements the Parser interface for parsing logs with columns at fixed byte ranges, such as
+// reports of mainframes and some appliances. Values are trimmed of the spaces padding them.
+type FixedWidthParser struct {
+	ctx         context.Context
+	w           io.Writer
+	lineDecoder lineDecoder
+	opt         Option
+}
+
+// NewFixedWidthParser initializes a new FixedWidthParser that cuts the lines at the column ranges. Lines ending
+// before the last column starts are treated as unmatched, while the last column may be shorter than its range.
+// Columns can be laid out by hand or taken from Profile.FixedColumns.
+func NewFixedWidthParser(ctx context.Context, w io.Writer, columns []FixedColumn, opt Option) (*FixedWidthParser, error) {
+	if err := validateFixedColumns(columns); err != nil {
+		return nil, err
+	}
+	p := &FixedWidthParser{
+		ctx:         ctx,
+		w:           w,
+		lineDecoder: fixedWidthLineDecoder(columns),
+		opt:         opt,
+	}
+	p.opt.header = &headerState{}
+	p.opt.locate = fixedWidthLocate(columns)
+	if opt.LineHandler == nil {
+		p.opt.LineHandler = JSONLineHandler
+	}
+	return p, nil
+}
+
+// Parse processes log data from an io.Reader, applying the configured line handlers.
+// This method supports context cancellation, prefixing of lines, and exclusion of specific lines.
+func (p *FixedWidthParser) Parse(reader io.Reader) (*Result, error) {
+	return parse(p.ctx, reader, p.w, nil, p.lineDecoder, p.opt)
+}
+
+// Records returns an iterator over the records decoded from reader, as label and value pairs after filters,
+// enrichers and label selection, instead of writing serialized lines to the output. Unmatched lines are skipped.
+func (p *FixedWidthParser) Records(reader io.Reader) RecordSeq {
+	return records(p.ctx, reader, nil, p.lineDecoder, p.opt)
+}
+
+// Explain runs the line through the configured pipeline and returns the decoded fields, the result of each
+// filter expression, the conversions applied and the output, without writing to the output of the parser.
+func (p *FixedWidthParser) Explain(line string) Explanation {
+	return explain(p.ctx, line, nil, p.lineDecoder, p.opt)
+}
+
+// ParseString processes a log string directly, applying configured skip lines and line number handling.
+// It's designed for quick parsing of a single fixed-width log string.
+func (p *FixedWidthParser) ParseString(s string) (*Result, error) {
+	return parseString(p.ctx, s, p.w, nil, p.lineDecoder, p.opt)
+}
+
+// ParseFile reads and parses log data from a file, leveraging the configured columns and handlers.
+// This method simplifies file-based fixed-width log parsing with automatic line processing.
+func (p *FixedWidthParser) ParseFile(filePath string) (*Result, error) {
+	return parseFile(p.ctx, filePath, p.w, nil, p.lineDecoder, p.opt)
+}
+
+// ParseGzip processes gzip-compressed log data, extending the parser's capabilities to compressed fixed-width logs.
+// It applies skip lines and line number handling as configured for gzip-compressed files.
+func (p *FixedWidthParser) ParseGzip(gzipPath string) (*Result, error) {
+	return parseGzip(p.ctx, gzipPath, p.w, nil, p.lineDecoder, p.opt)
+}
+
+// ParseZipEntries processes log data within zip archive entries, applying skip lines, line number handling,
+// and optional glob pattern matching. This method is ideal for batch processing of fixed-width logs in zip files.
+func (p *FixedWidthParser) ParseZipEntries(zipPath, globPattern string) (*Result, error) {
+	return parseZipEntries(p.ctx, zipPath, globPattern, p.w, nil, p.lineDecoder, p.opt)
+}
+
+// ParseTimeRangeIndexed processes the lines of a time-sorted fixed-width log file between from and to inclusive,
+// using the index saved at IndexPath(filePath) to read only the byte range that may contain them. The first key of
+// the index is regarded as the time field. Plain and gzip-compressed files are supported.
+func (p *FixedWidthParser) ParseTimeRangeIndexed(filePath string, from, to time.Time) (*Result, error) {
+	return parseTimeRangeIndexed(p.ctx, filePath, from, to, p.w, nil, p.lineDecoder, p.opt)
+}
+
+// SetWriters replaces the output with the writers, all of which the lines are written to, failing the parse if
+// any of them fails. Use a Tee from NewTee to add best-effort writers whose failures are tolerated instead.
+// The header written once with Option.HeaderOnce is written again to the new output.
+func (p *FixedWidthParser) SetWriters(w ...io.Writer) {
+	p.w = tee(w)
+	p.opt.header.reset()
+}
+
+// SetLineHandler replaces the handler converting the decoded fixed-width lines, such as with TSVLineHandler.
+// A nil handler restores the default JSONLineHandler.
+func (p *FixedWidthParser) SetLineHandler(handler LineHandler) {
+	if handler == nil {
+		handler = JSONLineHandler
+	}
+	p.opt.LineHandler = handler
+	p.opt.header.reset()
+}
+
+// withContext returns a copy of the parser running with the context returned by wrap for its context.
+func (p *FixedWidthParser) withContext(wrap func(ctx context.Context) context.Context) Parser {
+	q := *p
+	q.ctx = wrap(p.ctx)
+	return &q
+}
+
+// validateFixedColumns reports columns without labels or with duplicate labels, with invalid ranges, or
+// overlapping the previous one. Only the last column may extend to the end of the line.
+func validateFixedColumns(columns []FixedColumn) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("%s: no fixed-width column provided", optionError)
+	}
+	prev := 0
+	seen := make(map[string]struct{}, len(columns))
+	for i, c := range columns {
+		_, dup := seen[c.Label]
+		seen[c.Label] = struct{}{}
+		switch {
+		case c.Label == "":
+			return fmt.Errorf("%s: fixed-width column %d: empty label", optionError, i+1)
+		case dup:
+			return fmt.Errorf("%s: fixed-width column %q: duplicate label", optionError, c.Label)
+		case c.Start < prev:
+			return fmt.Errorf("%s: fixed-width column %q: starts at %d before the end of the previous column %d", optionError, c.Label, c.Start, prev)
+		case c.End == 0 && i != len(columns)-1:
+			return fmt.Errorf("%s: fixed-width column %q: only the last column may extend to the end of the line", optionError, c.Label)
+		case c.End != 0 && c.End <= c.Start:
+			return fmt.Errorf("%s: fixed-width column %q: invalid range [%d, %d)", optionError, c.Label, c.Start, c.End)
+		}
+		prev = c.End
+	}
+	return nil
+}
+
+// fixedWidthSpans returns the byte ranges of the values of the columns within the line, trimmed of spaces, or
+// false if the line ends before the last column starts.
+func fixedWidthSpans(line string, columns []FixedColumn) ([][2]int, bool) {
+	if len(line) <= columns[len(columns)-1].Start {
+		return nil, false
+	}
+	spans := make([][2]int, len(columns))
+	for i, c := range columns {
+		end := c.End
+		if end == 0 || end > len(line) {
+			end = len(line)
+		}
+		start := c.Start
+		for start < end && line[start] == ' ' {
+			start++
+		}
+		for end > start && line[end-1] == ' ' {
+			end--
+		}
+		spans[i] = [2]int{start, end}
+	}
+	return spans, true
+}
+
+// fixedWidthLineDecoder returns a lineDecoder that cuts the line at the column ranges.
+func fixedWidthLineDecoder(columns []FixedColumn) lineDecoder {
+	labels := make([]string, len(columns))
+	for i, c := range columns {
+		labels[i] = c.Label
+	}
+	return func(line string, _ []*regexp.Regexp) ([]string, []string, error) {
+		spans, ok := fixedWidthSpans(line, columns)
+		if !ok {
+			return nil, nil, fmt.Errorf("%s: line too short for column %q: \"%s\"", parseError, labels[len(labels)-1], line)
+		}
+		vs := make([]string, len(spans))
+		for i, span := range spans {
+			vs[i] = line[span[0]:span[1]]
+		}
+		return labels, vs, nil
+	}
+}
+
+// fixedWidthLocate returns a locateFunc for the columns, for Option.FieldPositions.
+func fixedWidthLocate(columns []FixedColumn) locateFunc {
+	return func(line string, _ []*regexp.Regexp) map[string][2]int {
+		spans, ok := fixedWidthSpans(line, columns)
+		if !ok {
+			return nil
+		}
+		m := make(map[string][2]int, len(spans))
+		for i, span := range spans {
+			m[columns[i].Label] = span
+		}
+		return m
+	}
+}
@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+var fixedColumns = []FixedColumn{
+	{Label: "date", Start: 0, End: 11},
+	{Label: "user", Start: 11, End: 20},
+	{Label: "bytes", Start: 20, End: 27},
+	{Label: "path", Start: 27},
+}
+
+func TestFixedWidthParser_ParseString(t *testing.T) {
+	input := "2024-01-02 alice      1024 /index.html\n" +
+		"2024-01-02 bob          12 /a b\n" +
+		"2024-01-03 carol\n" +
+		"2024-01-03               0 -\n"
+	tests := []struct {
+		name          string
+		opt           Option
+		want          string
+		wantUnmatched int
+	}{
+		{
+			name: "basic",
+			opt:  Option{},
+			want: `{"date":"2024-01-02","user":"alice","bytes":"1024","path":"/index.html"}` + "\n" +
+				`{"date":"2024-01-02","user":"bob","bytes":"12","path":"/a b"}` + "\n" +
+				`{"date":"2024-01-03","user":"","bytes":"0","path":"-"}` + "\n",
+			wantUnmatched: 1,
+		},
+		{
+			name:          "filters and positions",
+			opt:           Option{Filters: []string{"bytes > 100"}, Labels: []string{"user", "path"}, FieldPositions: true},
+			want:          `{"user":"alice","path":"/index.html","positions":"user:11-16,path:27-38"}` + "\n",
+			wantUnmatched: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			p, err := NewFixedWidthParser(context.Background(), buf, fixedColumns, tt.opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := p.ParseString(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", buf.String(), tt.want)
+			}
+			if r.Unmatched != tt.wantUnmatched || len(r.Errors) != tt.wantUnmatched {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", r.Unmatched, tt.wantUnmatched)
+			}
+		})
+	}
+}
+
+func TestNewFixedWidthParser(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns []FixedColumn
+		wantErr bool
+	}{
+		{name: "valid", columns: fixedColumns},
+		{name: "no columns", columns: nil, wantErr: true},
+		{name: "empty label", columns: []FixedColumn{{Start: 0, End: 3}}, wantErr: true},
+		{name: "duplicate label", columns: []FixedColumn{{Label: "a", End: 3}, {Label: "a", Start: 3}}, wantErr: true},
+		{name: "overlap", columns: []FixedColumn{{Label: "a", End: 3}, {Label: "b", Start: 2}}, wantErr: true},
+		{name: "open column in the middle", columns: []FixedColumn{{Label: "a"}, {Label: "b", Start: 3}}, wantErr: true},
+		{name: "empty range", columns: []FixedColumn{{Label: "a", Start: 3, End: 3}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewFixedWidthParser(context.Background(), nil, tt.columns, Option{}); (err != nil) != tt.wantErr {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFixedWidthParser_withContext(t *testing.T) {
+	p, err := NewFixedWidthParser(context.Background(), &bytes.Buffer{}, fixedColumns, Option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp, ok := Parser(p).(contextParser)
+	if !ok {
+		t.Fatalf("\ngot:\n%v\nwant:\n%v\n", ok, true)
+	}
+	q := cp.withContext(func(ctx context.Context) context.Context {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		return ctx
+	})
+	r, err := q.ParseString("2024-01-02 alice      1024 /index.html\n")
+	if !errors.Is(err, context.Canceled) || !r.Cancelled {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, context.Canceled)
+	}
+	if r, err := p.ParseString("2024-01-02 alice      1024 /index.html\n"); err != nil || r.Matched != 1 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "the original context kept")
+	}
+}
@@ -0,0 +1,383 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var _ Parser = (*JSONParser)(nil)
+
+// JSONParser implements the Parser interface for parsing logs in JSON (NDJSON) format.
+// Nested objects are flattened into dotted labels, and specific fields can be selected and renamed by path.
+type JSONParser struct {
+	ctx         context.Context
+	w           io.Writer
+	lineDecoder lineDecoder
+	fields      []jsonField
+	policies    map[string]ArrayPolicy
+	opt         Option
+}
+
+// ArrayPolicy defines how arrays in JSON log lines are converted into values.
+type ArrayPolicy int
+
+const (
+	ArrayAsJSON  ArrayPolicy = iota // keeps the array as compact JSON text (default)
+	ArrayJoin                       // joins the elements with commas
+	ArrayIndex                      // emits each element under an index-suffixed label such as "tags.0"
+	ArrayExplode                    // emits one record per element, repeating the other values
+)
+
+// jsonField maps a flattened JSON path to the label used in the output.
+type jsonField struct {
+	path  string
+	label string
+}
+
+// NewJSONParser initializes a new JSONParser with default handlers for line decoding, line handling.
+// Without any fields added, every leaf value is emitted under its dotted path, e.g. "request.headers.host".
+func NewJSONParser(ctx context.Context, w io.Writer, opt Option) *JSONParser {
+	p := &JSONParser{
+		ctx:         ctx,
+		w:           w,
+		lineDecoder: jsonLineDecoder(nil, nil),
+		opt:         opt,
+	}
+	p.opt.header = &headerState{}
+	p.opt.derived = func(string) bool { return true }
+	if opt.LineHandler == nil {
+		p.opt.LineHandler = JSONLineHandler
+	}
+	return p
+}
+
+// Parse processes log data from an io.Reader, applying the configured line handlers.
+// This method supports context cancellation, prefixing of lines, and exclusion of specific lines.
+func (p *JSONParser) Parse(reader io.Reader) (*Result, error) {
+	return parse(p.ctx, reader, p.w, nil, p.lineDecoder, p.opt)
+}
+
+// Records returns an iterator over the records decoded from reader, as label and value pairs after filters,
+// enrichers and label selection, instead of writing serialized lines to the output. Unmatched lines are skipped.
+func (p *JSONParser) Records(reader io.Reader) RecordSeq {
+	return records(p.ctx, reader, nil, p.lineDecoder, p.opt)
+}
+
+// Explain runs the line through the configured pipeline and returns the decoded fields, the result of each
+// filter expression, the conversions applied and the output, without writing to the output of the parser.
+func (p *JSONParser) Explain(line string) Explanation {
+	return explain(p.ctx, line, nil, p.lineDecoder, p.opt)
+}
+
+// ParseString processes a log string directly, applying configured skip lines and line number handling.
+// It's designed for quick parsing of a single JSON formatted log string.
+func (p *JSONParser) ParseString(s string) (*Result, error) {
+	return parseString(p.ctx, s, p.w, nil, p.lineDecoder, p.opt)
+}
+
+// ParseFile reads and parses log data from a file, leveraging the configured fields and handlers.
+// This method simplifies file-based JSON log parsing with automatic line processing.
+func (p *JSONParser) ParseFile(filePath string) (*Result, error) {
+	return parseFile(p.ctx, filePath, p.w, nil, p.lineDecoder, p.opt)
+}
+
+// ParseGzip processes gzip-compressed log data, extending the parser's capabilities to compressed JSON logs.
+// It applies skip lines and line number handling as configured for gzip-compressed files.
+func (p *JSONParser) ParseGzip(gzipPath string) (*Result, error) {
+	return parseGzip(p.ctx, gzipPath, p.w, nil, p.lineDecoder, p.opt)
+}
+
+// ParseZipEntries processes log data within zip archive entries, applying skip lines, line number handling,
+// and optional glob pattern matching. This method is ideal for batch processing of JSON logs in zip files.
+func (p *JSONParser) ParseZipEntries(zipPath, globPattern string) (*Result, error) {
+	return parseZipEntries(p.ctx, zipPath, globPattern, p.w, nil, p.lineDecoder, p.opt)
+}
+
+// ParseTimeRangeIndexed processes the lines of a time-sorted JSON log file between from and to inclusive, using the
+// index saved at IndexPath(filePath) to read only the byte range that may contain them. The first key of the index
+// is regarded as the time field. Plain and gzip-compressed files are supported.
+func (p *JSONParser) ParseTimeRangeIndexed(filePath string, from, to time.Time) (*Result, error) {
+	return parseTimeRangeIndexed(p.ctx, filePath, from, to, p.w, nil, p.lineDecoder, p.opt)
+}
+
+// SetWriters replaces the output with the writers, all of which the lines are written to, failing the parse if
+// any of them fails. Use a Tee from NewTee to add best-effort writers whose failures are tolerated instead.
+// The header written once with Option.HeaderOnce is written again to the new output.
+func (p *JSONParser) SetWriters(w ...io.Writer) {
+	p.w = tee(w)
+	p.opt.header.reset()
+}
+
+// SetLineHandler replaces the handler converting the decoded JSON lines, such as with TSVLineHandler.
+// A nil handler restores the default JSONLineHandler.
+func (p *JSONParser) SetLineHandler(handler LineHandler) {
+	if handler == nil {
+		handler = JSONLineHandler
+	}
+	p.opt.LineHandler = handler
+	p.opt.header.reset()
+}
+
+// withContext returns a copy of the parser running with the context returned by wrap for its context.
+func (p *JSONParser) withContext(wrap func(ctx context.Context) context.Context) Parser {
+	q := *p
+	q.ctx = wrap(p.ctx)
+	return &q
+}
+
+// AddField selects a nested field by dotted path ("request.headers.user-agent") or JSON Pointer
+// ("/request/headers/user-agent") and emits it under the given label. If the label is empty,
+// it is derived from the last path segment with hyphens replaced by underscores ("user_agent").
+// Once any field is added, only the added fields are emitted, empty in the lines where the path is missing.
+func (p *JSONParser) AddField(path, label string) error {
+	path, err := normalizeJSONPath(path)
+	if err != nil {
+		return err
+	}
+	if label == "" {
+		label = strings.ReplaceAll(path[strings.LastIndex(path, ".")+1:], "-", "_")
+	}
+	p.fields = append(p.fields, jsonField{path: path, label: label})
+	p.refresh()
+	return nil
+}
+
+// SetArrayPolicy sets how the array at the given path is converted. The path accepts the same notation
+// as AddField, and "*" sets the policy for all arrays without their own policy. ArrayExplode requires
+// an explicit path, and exploding several paths emits the cartesian product of their elements.
+func (p *JSONParser) SetArrayPolicy(path string, policy ArrayPolicy) error {
+	if policy < ArrayAsJSON || policy > ArrayExplode {
+		return fmt.Errorf("%s: %d", arrayPolicyError, policy)
+	}
+	if path != "*" {
+		var err error
+		if path, err = normalizeJSONPath(path); err != nil {
+			return err
+		}
+	} else if policy == ArrayExplode {
+		return fmt.Errorf("%s: explode requires an explicit path", arrayPolicyError)
+	}
+	if p.policies == nil {
+		p.policies = make(map[string]ArrayPolicy)
+	}
+	p.policies[path] = policy
+	p.refresh()
+	return nil
+}
+
+// refresh rebuilds the line decoder and the record expansion from the configured fields and array policies.
+func (p *JSONParser) refresh() {
+	p.lineDecoder = jsonLineDecoder(p.fields, p.policies)
+	var targets []string
+	for path, policy := range p.policies {
+		if policy != ArrayExplode {
+			continue
+		}
+		if len(p.fields) == 0 {
+			targets = append(targets, path)
+			continue
+		}
+		for _, field := range p.fields {
+			if field.path == path {
+				targets = append(targets, field.label)
+			}
+		}
+	}
+	p.opt.explode = nil
+	if len(targets) > 0 {
+		slices.Sort(targets)
+		p.opt.explode = jsonExplode(targets)
+	}
+}
+
+// normalizeJSONPath converts a JSON Pointer into the dotted path notation used for flattened labels.
+func normalizeJSONPath(path string) (string, error) {
+	if !strings.HasPrefix(path, "/") {
+		if path == "" || strings.HasPrefix(path, ".") || strings.HasSuffix(path, ".") {
+			return "", fmt.Errorf("%s: \"%s\"", jsonPathError, path)
+		}
+		return path, nil
+	}
+	tokens := strings.Split(path[1:], "/")
+	for i, token := range tokens {
+		if token == "" {
+			return "", fmt.Errorf("%s: \"%s\"", jsonPathError, path)
+		}
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return strings.Join(tokens, "."), nil
+}
+
+// jsonLineDecoder returns a lineDecoder that flattens a JSON object into labels and values.
+// If fields are given, only the matching paths are returned, in the order of the fields. Paths missing in the
+// line are returned empty, as null values are, so that every record has the same labels.
+func jsonLineDecoder(fields []jsonField, policies map[string]ArrayPolicy) lineDecoder {
+	return func(line string, _ []*regexp.Regexp) ([]string, []string, error) {
+		ls, vs, err := flattenJSON("", []byte(line), nil, nil, policies)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", parseError, err)
+		}
+		if len(fields) == 0 {
+			return ls, vs, nil
+		}
+		sls := make([]string, 0, len(fields))
+		svs := make([]string, 0, len(fields))
+		for _, field := range fields {
+			v := ""
+			if i := slices.Index(ls, field.path); i >= 0 {
+				v = vs[i]
+			}
+			sls = append(sls, field.label)
+			svs = append(svs, v)
+		}
+		return sls, svs, nil
+	}
+}
+
+// flattenJSON walks a JSON object in key order and appends each leaf value to labels and values,
+// joining nested keys with dots. Arrays are converted according to the given policies.
+func flattenJSON(prefix string, data []byte, labels, values []string, policies map[string]ArrayPolicy) ([]string, []string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	t, err := dec.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	if d, ok := t.(json.Delim); !ok || d != '{' {
+		return nil, nil, fmt.Errorf("not a JSON object")
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key := t.(string)
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, nil, err
+		}
+		label := key
+		if prefix != "" {
+			label = prefix + "." + key
+		}
+		labels, values, err = appendJSONValue(label, raw, labels, values, policies)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, nil, err
+	}
+	if dec.More() {
+		return nil, nil, fmt.Errorf("unexpected data after JSON object")
+	}
+	return labels, values, nil
+}
+
+// appendJSONValue appends a single JSON value under the given label. Objects are flattened recursively,
+// strings are unquoted, null becomes empty, and other values are kept as JSON text.
+func appendJSONValue(label string, raw json.RawMessage, labels, values []string, policies map[string]ArrayPolicy) ([]string, []string, error) {
+	switch raw[0] {
+	case '{':
+		return flattenJSON(label, raw, labels, values, policies)
+	case '[':
+		policy, ok := policies[label]
+		if !ok {
+			policy = policies["*"]
+		}
+		switch policy {
+		case ArrayJoin, ArrayIndex:
+			var elems []json.RawMessage
+			if err := json.Unmarshal(raw, &elems); err != nil {
+				return nil, nil, err
+			}
+			if policy == ArrayIndex {
+				var err error
+				for i, elem := range elems {
+					labels, values, err = appendJSONValue(label+"."+strconv.Itoa(i), elem, labels, values, policies)
+					if err != nil {
+						return nil, nil, err
+					}
+				}
+				return labels, values, nil
+			}
+			ss := make([]string, len(elems))
+			for i, elem := range elems {
+				s, err := jsonText(elem)
+				if err != nil {
+					return nil, nil, err
+				}
+				ss[i] = s
+			}
+			return append(labels, label), append(values, strings.Join(ss, ",")), nil
+		}
+	}
+	s, err := jsonText(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(labels, label), append(values, s), nil
+}
+
+// jsonText converts a JSON value into its string representation.
+// Strings are unquoted, null becomes empty, and other values are compacted.
+func jsonText(raw json.RawMessage) (string, error) {
+	switch raw[0] {
+	case '"':
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return "", err
+		}
+		return s, nil
+	case 'n':
+		return "", nil
+	default:
+		b := &bytes.Buffer{}
+		if err := json.Compact(b, raw); err != nil {
+			return "", err
+		}
+		return b.String(), nil
+	}
+}
+
+// jsonExplode returns an explodeFunc that emits one record per element of the arrays under the target labels.
+// Values that are not JSON arrays are kept as they are, and empty arrays produce an empty value.
+func jsonExplode(targets []string) explodeFunc {
+	return func(labels, values []string) [][]string {
+		records := [][]string{values}
+		for _, target := range targets {
+			j := slices.Index(labels, target)
+			if j < 0 || !strings.HasPrefix(values[j], "[") {
+				continue
+			}
+			var elems []json.RawMessage
+			if err := json.Unmarshal([]byte(values[j]), &elems); err != nil {
+				continue
+			}
+			if len(elems) == 0 {
+				elems = []json.RawMessage{json.RawMessage("null")}
+			}
+			next := make([][]string, 0, len(records)*len(elems))
+			for _, record := range records {
+				for _, elem := range elems {
+					s, err := jsonText(elem)
+					if err != nil {
+						continue
+					}
+					vs := slices.Clone(record)
+					vs[j] = s
+					next = append(next, vs)
+				}
+			}
+			records = next
+		}
+		return records
+	}
+}
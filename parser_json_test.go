@@ -0,0 +1,285 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestNewJSONParser(t *testing.T) {
+	type parserArgs struct {
+		input string
+		opt   Option
+	}
+	tests := []struct {
+		name       string
+		parserArgs parserArgs
+		want       string
+	}{
+		{
+			name: "flat",
+			parserArgs: parserArgs{
+				input: `{"remote_host":"192.0.2.1","status":200,"cached":false,"referer":null}`,
+			},
+			want: `{"remote_host":"192.0.2.1","status":"200","cached":"false","referer":""}
+`,
+		},
+		{
+			name: "nested",
+			parserArgs: parserArgs{
+				input: `{"time":"2024-03-10T12:00:00Z","request":{"method":"GET","headers":{"user-agent":"curl/8.0"}},"status":404}`,
+			},
+			want: `{"time":"2024-03-10T12:00:00Z","request.method":"GET","request.headers.user-agent":"curl/8.0","status":"404"}
+`,
+		},
+		{
+			name: "filter on nested field",
+			parserArgs: parserArgs{
+				input: `{"request":{"method":"GET"},"status":200}
+{"request":{"method":"POST"},"status":201}`,
+				opt: Option{
+					Filters: []string{"request.method == POST"},
+				},
+			},
+			want: `{"request.method":"POST","status":"201"}
+`,
+		},
+		{
+			name: "unmatch",
+			parserArgs: parserArgs{
+				input: `{"status":200}{"status":201}
+[1,2,3]
+{"status":`,
+			},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			p := NewJSONParser(context.Background(), w, tt.parserArgs.opt)
+			r, err := p.ParseString(tt.parserArgs.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := w.String(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+			if tt.want == "" && r.Unmatched != r.Total {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", r.Unmatched, r.Total)
+			}
+		})
+	}
+}
+
+func TestJSONParser_AddField(t *testing.T) {
+	type field struct {
+		path  string
+		label string
+	}
+	tests := []struct {
+		name    string
+		fields  []field
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "dotted path",
+			fields: []field{
+				{path: "request.headers.user-agent"},
+				{path: "status", label: "code"},
+			},
+			input: `{"request":{"headers":{"user-agent":"curl/8.0"}},"status":200}`,
+			want: `{"user_agent":"curl/8.0","code":"200"}
+`,
+		},
+		{
+			name: "json pointer",
+			fields: []field{
+				{path: "/request/headers/user-agent"},
+				{path: "/a~1b/c~0d", label: "escaped"},
+			},
+			input: `{"request":{"headers":{"user-agent":"curl/8.0"}},"a/b":{"c~d":"x"}}`,
+			want: `{"user_agent":"curl/8.0","escaped":"x"}
+`,
+		},
+		{
+			name: "missing field",
+			fields: []field{
+				{path: "status"},
+				{path: "request.method"},
+			},
+			input: `{"status":200}`,
+			want: `{"status":"200","method":""}
+`,
+		},
+		{
+			name: "invalid pointer",
+			fields: []field{
+				{path: "/request//method"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid path",
+			fields: []field{
+				{path: "request."},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			p := NewJSONParser(context.Background(), w, Option{})
+			var err error
+			for _, f := range tt.fields {
+				if err = p.AddField(f.path, f.label); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if _, err := p.ParseString(tt.input); err != nil {
+				t.Fatal(err)
+			}
+			if got := w.String(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONParser_AddField_missing(t *testing.T) {
+	w := &bytes.Buffer{}
+	p := NewJSONParser(context.Background(), w, Option{LineHandler: TSVLineHandler})
+	for _, path := range []string{"status", "request.method", "request.path"} {
+		if err := p.AddField(path, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	input := `{"status":200,"request":{"method":"GET","path":"/a"}}` + "\n" +
+		`{"status":404,"request":{"path":"/b"}}` + "\n" +
+		`{"request":{"method":null,"path":"/c"}}` + "\n"
+	if _, err := p.ParseString(input); err != nil {
+		t.Fatal(err)
+	}
+	want := "status\tmethod\tpath\n200\tGET\t/a\n404\t-\t/b\n-\t-\t/c\n"
+	if got := w.String(); got != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func TestJSONParser_SetArrayPolicy(t *testing.T) {
+	type policy struct {
+		path   string
+		policy ArrayPolicy
+	}
+	tests := []struct {
+		name     string
+		fields   []string
+		policies []policy
+		input    string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:  "default",
+			input: `{"id":1,"tags":["a","b"]}`,
+			want: `{"id":"1","tags":"[\"a\",\"b\"]"}
+`,
+		},
+		{
+			name: "join",
+			policies: []policy{
+				{path: "tags", policy: ArrayJoin},
+			},
+			input: `{"id":1,"tags":["a","b",3]}`,
+			want: `{"id":"1","tags":"a,b,3"}
+`,
+		},
+		{
+			name: "index for all arrays",
+			policies: []policy{
+				{path: "*", policy: ArrayIndex},
+			},
+			input: `{"id":1,"tags":["a","b"],"targets":[{"ip":"10.0.0.1"},{"ip":"10.0.0.2"}]}`,
+			want: `{"id":"1","tags.0":"a","tags.1":"b","targets.0.ip":"10.0.0.1","targets.1.ip":"10.0.0.2"}
+`,
+		},
+		{
+			name: "explode",
+			policies: []policy{
+				{path: "/targets", policy: ArrayExplode},
+			},
+			input: `{"id":1,"targets":["10.0.0.1:80","10.0.0.2:80"]}
+{"id":2,"targets":[]}`,
+			want: `{"id":"1","targets":"10.0.0.1:80"}
+{"id":"1","targets":"10.0.0.2:80"}
+{"id":"2","targets":""}
+`,
+		},
+		{
+			name:   "explode selected field",
+			fields: []string{"id", "conn.targets"},
+			policies: []policy{
+				{path: "conn.targets", policy: ArrayExplode},
+			},
+			input: `{"id":1,"conn":{"targets":["10.0.0.1:80","10.0.0.2:80"]}}`,
+			want: `{"id":"1","targets":"10.0.0.1:80"}
+{"id":"1","targets":"10.0.0.2:80"}
+`,
+		},
+		{
+			name: "explode with wildcard",
+			policies: []policy{
+				{path: "*", policy: ArrayExplode},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown policy",
+			policies: []policy{
+				{path: "tags", policy: ArrayPolicy(9)},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			p := NewJSONParser(context.Background(), w, Option{})
+			for _, f := range tt.fields {
+				if err := p.AddField(f, ""); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var err error
+			for _, pl := range tt.policies {
+				if err = p.SetArrayPolicy(pl.path, pl.policy); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if _, err := p.ParseString(tt.input); err != nil {
+				t.Fatal(err)
+			}
+			if got := w.String(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
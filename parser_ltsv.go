@@ -3,6 +3,7 @@ package parser
 import (
 	"context"
 	"io"
+	"time"
 )
 
 var _ Parser = (*LTSVParser)(nil)
@@ -25,6 +26,9 @@ func NewLTSVParser(ctx context.Context, w io.Writer, opt Option) *LTSVParser {
 		lineDecoder: ltsvLineDecoder,
 		opt:         opt,
 	}
+	p.opt.header = &headerState{}
+	p.opt.project = ltsvProjectedLineDecoder
+	p.opt.locate = ltsvLocate
 	if opt.LineHandler == nil {
 		p.opt.LineHandler = JSONLineHandler
 	}
@@ -37,6 +41,18 @@ func (p *LTSVParser) Parse(reader io.Reader) (*Result, error) {
 	return parse(p.ctx, reader, p.w, nil, p.lineDecoder, p.opt)
 }
 
+// Records returns an iterator over the records decoded from reader, as label and value pairs after filters,
+// enrichers and label selection, instead of writing serialized lines to the output. Unmatched lines are skipped.
+func (p *LTSVParser) Records(reader io.Reader) RecordSeq {
+	return records(p.ctx, reader, nil, p.lineDecoder, p.opt)
+}
+
+// Explain runs the line through the configured pipeline and returns the decoded fields, the result of each
+// filter expression, the conversions applied and the output, without writing to the output of the parser.
+func (p *LTSVParser) Explain(line string) Explanation {
+	return explain(p.ctx, line, nil, p.lineDecoder, p.opt)
+}
+
 // ParseString processes a log string directly, applying configured skip lines and line number handling.
 // It's designed for quick parsing of a single LTSV formatted log string.
 func (p *LTSVParser) ParseString(s string) (*Result, error) {
@@ -60,3 +76,35 @@ func (p *LTSVParser) ParseGzip(gzipPath string) (*Result, error) {
 func (p *LTSVParser) ParseZipEntries(zipPath, globPattern string) (*Result, error) {
 	return parseZipEntries(p.ctx, zipPath, globPattern, p.w, nil, p.lineDecoder, p.opt)
 }
+
+// ParseTimeRangeIndexed processes the lines of a time-sorted LTSV log file between from and to inclusive, using the
+// index saved at IndexPath(filePath) to read only the byte range that may contain them. The first key of the index
+// is regarded as the time field. Plain and gzip-compressed files are supported.
+func (p *LTSVParser) ParseTimeRangeIndexed(filePath string, from, to time.Time) (*Result, error) {
+	return parseTimeRangeIndexed(p.ctx, filePath, from, to, p.w, nil, p.lineDecoder, p.opt)
+}
+
+// SetWriters replaces the output with the writers, all of which the lines are written to, failing the parse if
+// any of them fails. Use a Tee from NewTee to add best-effort writers whose failures are tolerated instead.
+// The header written once with Option.HeaderOnce is written again to the new output.
+func (p *LTSVParser) SetWriters(w ...io.Writer) {
+	p.w = tee(w)
+	p.opt.header.reset()
+}
+
+// SetLineHandler replaces the handler converting the decoded LTSV lines, such as with TSVLineHandler.
+// A nil handler restores the default JSONLineHandler.
+func (p *LTSVParser) SetLineHandler(handler LineHandler) {
+	if handler == nil {
+		handler = JSONLineHandler
+	}
+	p.opt.LineHandler = handler
+	p.opt.header.reset()
+}
+
+// withContext returns a copy of the parser running with the context returned by wrap for its context.
+func (p *LTSVParser) withContext(wrap func(ctx context.Context) context.Context) Parser {
+	q := *p
+	q.ctx = wrap(p.ctx)
+	return &q
+}
@@ -373,3 +373,59 @@ func TestLTSVParser_ParseZipEntries(t *testing.T) {
 		})
 	}
 }
+
+func Test_ltsvProjectedLineDecoder(t *testing.T) {
+	input := "a:1\tb:2\tc:3\na:4\tinvalid\tc:6\na:7\tc:9"
+	tests := []struct {
+		name string
+		opt  Option
+	}{
+		{
+			name: "labels",
+			opt:  Option{Labels: []string{"c", "a"}},
+		},
+		{
+			name: "labels with filters",
+			opt:  Option{Labels: []string{"a"}, Filters: []string{"c > 5"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := &bytes.Buffer{}
+			full := NewLTSVParser(context.Background(), want, tt.opt)
+			full.opt.project = nil
+			wr, err := full.ParseString(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := &bytes.Buffer{}
+			gr, err := NewLTSVParser(context.Background(), got, tt.opt).ParseString(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != want.String() {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.String(), want.String())
+			}
+			if !reflect.DeepEqual(gr.Errors, wr.Errors) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", gr.Errors, wr.Errors)
+			}
+		})
+	}
+}
+
+func TestLTSVParser_SetLineHandler(t *testing.T) {
+	got := &bytes.Buffer{}
+	p := NewLTSVParser(context.Background(), got, Option{})
+	p.SetLineHandler(LTSVLineHandler)
+	if _, err := p.ParseString("a:1\tb:2"); err != nil {
+		t.Fatal(err)
+	}
+	p.SetLineHandler(nil)
+	if _, err := p.ParseString("a:1\tb:2"); err != nil {
+		t.Fatal(err)
+	}
+	want := "a:1\tb:2\n{\"a\":\"1\",\"b\":\"2\"}\n"
+	if got.String() != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.String(), want)
+	}
+}
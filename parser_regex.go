@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"strings"
+	"time"
 )
 
 var _ Parser = (*RegexParser)(nil)
@@ -28,6 +30,7 @@ func NewRegexParser(ctx context.Context, w io.Writer, opt Option) *RegexParser {
 		lineDecoder: regexLineDecoder,
 		opt:         opt,
 	}
+	p.opt.header = &headerState{}
 	if opt.LineHandler == nil {
 		p.opt.LineHandler = JSONLineHandler
 	}
@@ -40,6 +43,18 @@ func (p *RegexParser) Parse(reader io.Reader) (*Result, error) {
 	return parse(p.ctx, reader, p.w, p.patterns, p.lineDecoder, p.opt)
 }
 
+// Records returns an iterator over the records decoded from reader, as label and value pairs after filters,
+// enrichers and label selection, instead of writing serialized lines to the output. Unmatched lines are skipped.
+func (p *RegexParser) Records(reader io.Reader) RecordSeq {
+	return records(p.ctx, reader, p.patterns, p.lineDecoder, p.opt)
+}
+
+// Explain runs the line through the configured pipeline and returns which pattern matched, the result of each
+// filter expression, the conversions applied and the output, without writing to the output of the parser.
+func (p *RegexParser) Explain(line string) Explanation {
+	return explain(p.ctx, line, p.patterns, p.lineDecoder, p.opt)
+}
+
 // ParseString processes a single log string, applying skip lines and line number handling.
 // It's a convenience method for quick string parsing with the configured parser instance.
 func (p *RegexParser) ParseString(s string) (*Result, error) {
@@ -64,6 +79,38 @@ func (p *RegexParser) ParseZipEntries(zipPath, globPattern string) (*Result, err
 	return parseZipEntries(p.ctx, zipPath, globPattern, p.w, p.patterns, p.lineDecoder, p.opt)
 }
 
+// ParseTimeRangeIndexed processes the lines of a time-sorted log file between from and to inclusive, using the
+// index saved at IndexPath(filePath) to read only the byte range that may contain them. The first key of the index
+// is regarded as the time field. Plain and gzip-compressed files are supported.
+func (p *RegexParser) ParseTimeRangeIndexed(filePath string, from, to time.Time) (*Result, error) {
+	return parseTimeRangeIndexed(p.ctx, filePath, from, to, p.w, p.patterns, p.lineDecoder, p.opt)
+}
+
+// SetWriters replaces the output with the writers, all of which the lines are written to, failing the parse if
+// any of them fails. Use a Tee from NewTee to add best-effort writers whose failures are tolerated instead.
+// The header written once with Option.HeaderOnce is written again to the new output.
+func (p *RegexParser) SetWriters(w ...io.Writer) {
+	p.w = tee(w)
+	p.opt.header.reset()
+}
+
+// SetLineHandler replaces the handler converting the decoded lines, such as with TSVLineHandler.
+// A nil handler restores the default JSONLineHandler.
+func (p *RegexParser) SetLineHandler(handler LineHandler) {
+	if handler == nil {
+		handler = JSONLineHandler
+	}
+	p.opt.LineHandler = handler
+	p.opt.header.reset()
+}
+
+// withContext returns a copy of the parser running with the context returned by wrap for its context.
+func (p *RegexParser) withContext(wrap func(ctx context.Context) context.Context) Parser {
+	q := *p
+	q.ctx = wrap(p.ctx)
+	return &q
+}
+
 // Patterns returns the list of regular expression patterns currently configured in the parser.
 func (p *RegexParser) Patterns() []*regexp.Regexp {
 	return p.patterns
@@ -72,20 +119,29 @@ func (p *RegexParser) Patterns() []*regexp.Regexp {
 // AddPattern adds a new regular expression pattern to the parser's pattern list.
 // It validates the pattern to ensure it has named capture groups for structured parsing.
 func (p *RegexParser) AddPattern(pattern string) error {
+	ptn, err := compilePattern(pattern)
+	if err != nil {
+		return err
+	}
+	p.patterns = append(p.patterns, ptn)
+	return nil
+}
+
+// compilePattern compiles the pattern, ensuring that all of its capture groups are named.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
 	ptn, err := regexp.Compile(pattern)
 	if err != nil {
-		return fmt.Errorf("%s: %w", regexPatternError, err)
+		return nil, fmt.Errorf("%s: %w", regexPatternError, err)
 	}
 	if len(ptn.SubexpNames()) <= 1 {
-		return fmt.Errorf("%s: capture group not found", regexPatternError)
+		return nil, fmt.Errorf("%s: capture group not found", regexPatternError)
 	}
 	for j, name := range ptn.SubexpNames() {
 		if j != 0 && name == "" {
-			return fmt.Errorf("%s: non-named capture group detected", regexPatternError)
+			return nil, fmt.Errorf("%s: non-named capture group detected", regexPatternError)
 		}
 	}
-	p.patterns = append(p.patterns, ptn)
-	return nil
+	return ptn, nil
 }
 
 // AddPatterns adds multiple regular expression patterns to the parser's list.
@@ -99,8 +155,102 @@ func (p *RegexParser) AddPatterns(patterns []string) error {
 	return nil
 }
 
+// SetFormat replaces the patterns with the one compiled from a format string in the style of nginx log_format,
+// such as `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent`. Each variable, written
+// as $label or ${label}, becomes a named capture group matching up to the first character of the literal text
+// following it, or to the end of the line for the last variable. The pattern matches the whole line.
+func (p *RegexParser) SetFormat(format string) error {
+	pattern, err := compileFormat(format)
+	if err != nil {
+		return err
+	}
+	ptn, err := compilePattern(pattern)
+	if err != nil {
+		return err
+	}
+	p.patterns = []*regexp.Regexp{ptn}
+	return nil
+}
+
+// compileFormat converts the format string into an anchored regular expression pattern.
+func compileFormat(format string) (string, error) {
+	b := &strings.Builder{}
+	b.WriteString("^")
+	seen := map[string]struct{}{}
+	pending := ""
+	for i := 0; i < len(format); {
+		if format[i] != '$' {
+			j := strings.IndexByte(format[i:], '$')
+			if j < 0 {
+				j = len(format) - i
+			}
+			literal := format[i : i+j]
+			if pending != "" {
+				fmt.Fprintf(b, "(?P<%s>[^%s]*)", pending, regexp.QuoteMeta(literal[:1]))
+				pending = ""
+			}
+			b.WriteString(regexp.QuoteMeta(literal))
+			i += j
+			continue
+		}
+		name, n := formatVariable(format[i+1:])
+		if name == "" {
+			return "", fmt.Errorf("%s: variable name not found at %d: %q", logFormatError, i, format)
+		}
+		if pending != "" {
+			return "", fmt.Errorf("%s: variables $%s and $%s are not separated: %q", logFormatError, pending, name, format)
+		}
+		if _, ok := seen[name]; ok {
+			return "", fmt.Errorf("%s: duplicate variable $%s: %q", logFormatError, name, format)
+		}
+		seen[name] = struct{}{}
+		pending = name
+		i += 1 + n
+	}
+	if pending != "" {
+		fmt.Fprintf(b, "(?P<%s>.*)", pending)
+	}
+	b.WriteString("$")
+	return b.String(), nil
+}
+
+// formatVariable returns the name of the variable at the beginning of s, without the leading "$",
+// and the number of bytes it spans.
+func formatVariable(s string) (string, int) {
+	if strings.HasPrefix(s, "{") {
+		j := strings.IndexByte(s, '}')
+		if j < 0 || !isFormatName(s[1:j]) {
+			return "", 0
+		}
+		return s[1:j], j + 1
+	}
+	j := 0
+	for j < len(s) && isFormatNameByte(s[j]) {
+		j++
+	}
+	return s[:j], j
+}
+
+// isFormatName reports whether s is a valid variable name.
+func isFormatName(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isFormatNameByte(s[i]) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// isFormatNameByte reports whether c may appear in a variable name, which is also a capture group name.
+func isFormatNameByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
 // NewApacheCLFRegexParser initializes a new RegexParser specifically for parsing Apache Common Log Format (CLF) logs.
 // It preconfigures the parser with regular expression patterns that match the Apache CLF log format.
+// Both the common and combined formats are covered, with quotes escaped by Apache in the referer and user agent.
+// A request line that is not a well-formed request, such as "-" logged for timeouts or TLS handshakes sent to a
+// plain HTTP port, is captured as a whole in the "request" field instead of method, request_uri and protocol.
 func NewApacheCLFRegexParser(ctx context.Context, w io.Writer, opt Option) *RegexParser {
 	p := &RegexParser{
 		ctx:         ctx,
@@ -108,10 +258,14 @@ func NewApacheCLFRegexParser(ctx context.Context, w io.Writer, opt Option) *Rege
 		lineDecoder: regexLineDecoder,
 		opt:         opt,
 		patterns: []*regexp.Regexp{
-			regexp.MustCompile(`^(?P<remote_host>\S+) (?P<remote_logname>\S+) (?P<remote_user>[\S ]+) (?P<datetime>\[[^\]]+\]) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\" (?P<status>[0-9]{3}) (?P<size>[0-9]+|-) "(?P<referer>[^\"]*)" "(?P<user_agent>[^\"]*)"`),
+			regexp.MustCompile(`^(?P<remote_host>\S+) (?P<remote_logname>\S+) (?P<remote_user>[\S ]+) (?P<datetime>\[[^\]]+\]) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\" (?P<status>[0-9]{3}) (?P<size>[0-9]+|-) "(?P<referer>(?:[^\"\\]|\\.)*)" "(?P<user_agent>(?:[^\"\\]|\\.)*)"`),
 			regexp.MustCompile(`^(?P<remote_host>\S+) (?P<remote_logname>\S+) (?P<remote_user>[\S ]+) (?P<datetime>\[[^\]]+\]) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\" (?P<status>[0-9]{3}) (?P<size>[0-9]+|-)`),
-			regexp.MustCompile(`^(?P<remote_host>\S+)\t(?P<remote_logname>\S+)\t(?P<remote_user>[\S ]+)\t(?P<datetime>\[[^\]]+\])\t\"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\"\t(?P<status>[0-9]{3})\t(?P<size>[0-9]+|-)\t"(?P<referer>[^\"]*)"\t"(?P<user_agent>[^\"]*)"`),
+			regexp.MustCompile(`^(?P<remote_host>\S+)\t(?P<remote_logname>\S+)\t(?P<remote_user>[\S ]+)\t(?P<datetime>\[[^\]]+\])\t\"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\"\t(?P<status>[0-9]{3})\t(?P<size>[0-9]+|-)\t"(?P<referer>(?:[^\"\\]|\\.)*)"\t"(?P<user_agent>(?:[^\"\\]|\\.)*)"`),
 			regexp.MustCompile(`^(?P<remote_host>\S+)\t(?P<remote_logname>\S+)\t(?P<remote_user>[\S ]+)\t(?P<datetime>\[[^\]]+\])\t\"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\"\t(?P<status>[0-9]{3})\t(?P<size>[0-9]+|-)`),
+			regexp.MustCompile(`^(?P<remote_host>\S+) (?P<remote_logname>\S+) (?P<remote_user>[\S ]+) (?P<datetime>\[[^\]]+\]) \"(?P<request>(?:[^\"\\]|\\.)*)\" (?P<status>[0-9]{3}) (?P<size>[0-9]+|-) "(?P<referer>(?:[^\"\\]|\\.)*)" "(?P<user_agent>(?:[^\"\\]|\\.)*)"`),
+			regexp.MustCompile(`^(?P<remote_host>\S+) (?P<remote_logname>\S+) (?P<remote_user>[\S ]+) (?P<datetime>\[[^\]]+\]) \"(?P<request>(?:[^\"\\]|\\.)*)\" (?P<status>[0-9]{3}) (?P<size>[0-9]+|-)`),
+			regexp.MustCompile(`^(?P<remote_host>\S+)\t(?P<remote_logname>\S+)\t(?P<remote_user>[\S ]+)\t(?P<datetime>\[[^\]]+\])\t\"(?P<request>(?:[^\"\\]|\\.)*)\"\t(?P<status>[0-9]{3})\t(?P<size>[0-9]+|-)\t"(?P<referer>(?:[^\"\\]|\\.)*)"\t"(?P<user_agent>(?:[^\"\\]|\\.)*)"`),
+			regexp.MustCompile(`^(?P<remote_host>\S+)\t(?P<remote_logname>\S+)\t(?P<remote_user>[\S ]+)\t(?P<datetime>\[[^\]]+\])\t\"(?P<request>(?:[^\"\\]|\\.)*)\"\t(?P<status>[0-9]{3})\t(?P<size>[0-9]+|-)`),
 		},
 	}
 	if opt.LineHandler == nil {
@@ -121,7 +275,8 @@ func NewApacheCLFRegexParser(ctx context.Context, w io.Writer, opt Option) *Rege
 }
 
 // NewApacheCLFWithVHostRegexParser initializes a new RegexParser for parsing Apache logs with Virtual Host information.
-// It extends the Apache CLF parser to include patterns that also capture the virtual host of each log entry.
+// It extends the Apache CLF parser to include patterns that also capture the virtual host of each log entry,
+// such as the vhost_combined format, with the same handling of escaped quotes and malformed request lines.
 func NewApacheCLFWithVHostRegexParser(ctx context.Context, w io.Writer, opt Option) *RegexParser {
 	p := &RegexParser{
 		ctx:         ctx,
@@ -129,10 +284,14 @@ func NewApacheCLFWithVHostRegexParser(ctx context.Context, w io.Writer, opt Opti
 		lineDecoder: regexLineDecoder,
 		opt:         opt,
 		patterns: []*regexp.Regexp{
-			regexp.MustCompile(`^(?P<virtual_host>\S+) (?P<remote_host>\S+) (?P<remote_logname>\S+) (?P<remote_user>[\S ]+) (?P<datetime>\[[^\]]+\]) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\" (?P<status>[0-9]{3}) (?P<size>[0-9]+|-) "(?P<referer>[^\"]*)" "(?P<user_agent>[^\"]*)"`),
+			regexp.MustCompile(`^(?P<virtual_host>\S+) (?P<remote_host>\S+) (?P<remote_logname>\S+) (?P<remote_user>[\S ]+) (?P<datetime>\[[^\]]+\]) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\" (?P<status>[0-9]{3}) (?P<size>[0-9]+|-) "(?P<referer>(?:[^\"\\]|\\.)*)" "(?P<user_agent>(?:[^\"\\]|\\.)*)"`),
 			regexp.MustCompile(`^(?P<virtual_host>\S+) (?P<remote_host>\S+) (?P<remote_logname>\S+) (?P<remote_user>[\S ]+) (?P<datetime>\[[^\]]+\]) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\" (?P<status>[0-9]{3}) (?P<size>[0-9]+|-)`),
-			regexp.MustCompile(`^(?P<virtual_host>\S+)\t(?P<remote_host>\S+)\t(?P<remote_logname>\S+)\t(?P<remote_user>[\S ]+)\t(?P<datetime>\[[^\]]+\])\t\"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\"\t(?P<status>[0-9]{3})\t(?P<size>[0-9]+|-)\t"(?P<referer>[^\"]*)"\t"(?P<user_agent>[^\"]*)"`),
+			regexp.MustCompile(`^(?P<virtual_host>\S+)\t(?P<remote_host>\S+)\t(?P<remote_logname>\S+)\t(?P<remote_user>[\S ]+)\t(?P<datetime>\[[^\]]+\])\t\"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\"\t(?P<status>[0-9]{3})\t(?P<size>[0-9]+|-)\t"(?P<referer>(?:[^\"\\]|\\.)*)"\t"(?P<user_agent>(?:[^\"\\]|\\.)*)"`),
 			regexp.MustCompile(`^(?P<virtual_host>\S+)\t(?P<remote_host>\S+)\t(?P<remote_logname>\S+)\t(?P<remote_user>[\S ]+)\t(?P<datetime>\[[^\]]+\])\t\"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\"\t(?P<status>[0-9]{3})\t(?P<size>[0-9]+|-)`),
+			regexp.MustCompile(`^(?P<virtual_host>\S+) (?P<remote_host>\S+) (?P<remote_logname>\S+) (?P<remote_user>[\S ]+) (?P<datetime>\[[^\]]+\]) \"(?P<request>(?:[^\"\\]|\\.)*)\" (?P<status>[0-9]{3}) (?P<size>[0-9]+|-) "(?P<referer>(?:[^\"\\]|\\.)*)" "(?P<user_agent>(?:[^\"\\]|\\.)*)"`),
+			regexp.MustCompile(`^(?P<virtual_host>\S+) (?P<remote_host>\S+) (?P<remote_logname>\S+) (?P<remote_user>[\S ]+) (?P<datetime>\[[^\]]+\]) \"(?P<request>(?:[^\"\\]|\\.)*)\" (?P<status>[0-9]{3}) (?P<size>[0-9]+|-)`),
+			regexp.MustCompile(`^(?P<virtual_host>\S+)\t(?P<remote_host>\S+)\t(?P<remote_logname>\S+)\t(?P<remote_user>[\S ]+)\t(?P<datetime>\[[^\]]+\])\t\"(?P<request>(?:[^\"\\]|\\.)*)\"\t(?P<status>[0-9]{3})\t(?P<size>[0-9]+|-)\t"(?P<referer>(?:[^\"\\]|\\.)*)"\t"(?P<user_agent>(?:[^\"\\]|\\.)*)"`),
+			regexp.MustCompile(`^(?P<virtual_host>\S+)\t(?P<remote_host>\S+)\t(?P<remote_logname>\S+)\t(?P<remote_user>[\S ]+)\t(?P<datetime>\[[^\]]+\])\t\"(?P<request>(?:[^\"\\]|\\.)*)\"\t(?P<status>[0-9]{3})\t(?P<size>[0-9]+|-)`),
 		},
 	}
 	if opt.LineHandler == nil {
@@ -144,18 +303,13 @@ func NewApacheCLFWithVHostRegexParser(ctx context.Context, w io.Writer, opt Opti
 // NewS3RegexParser initializes a new RegexParser for parsing Amazon S3 access logs.
 // It is preconfigured with patterns that match the S3 access log format, facilitating easy parsing of S3 logs.
 func NewS3RegexParser(ctx context.Context, w io.Writer, opt Option) *RegexParser {
+	preset := mustPreset("s3")
 	p := &RegexParser{
 		ctx:         ctx,
 		w:           w,
-		lineDecoder: regexLineDecoder,
+		lineDecoder: preset.decoder(regexLineDecoder, opt),
 		opt:         opt,
-		patterns: []*regexp.Regexp{
-			regexp.MustCompile(`^(?P<bucket_owner>[!-~]+) (?P<bucket>[!-~]+) (?P<time>\[[^\]]+\]) (?P<remote_ip>[!-~]+) (?P<requester>[!-~]+) (?P<request_id>[!-~]+) (?P<operation>[!-~]+) (?P<key>[!-~]+) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\" (?P<http_status>\d{1,3}) (?P<error_code>[!-~]+) (?P<bytes_sent>[\d\-.]+) (?P<object_size>[\d\-.]+) (?P<total_time>[\d\-.]+) (?P<turn_around_time>[\d\-.]+) "(?P<referer>[^\"]*)" "(?P<user_agent>[^\"]*)" (?P<version_id>[!-~]+) (?P<host_id>[!-~]+) (?P<signature_version>[!-~]+) (?P<cipher_suite>[!-~]+) (?P<authentication_type>[!-~]+) (?P<host_header>[!-~]+) (?P<tls_version>[!-~]+) (?P<access_point_arn>[!-~]+) (?P<acl_required>[!-~]+)`),
-			regexp.MustCompile(`^(?P<bucket_owner>[!-~]+) (?P<bucket>[!-~]+) (?P<time>\[[^\]]+\]) (?P<remote_ip>[!-~]+) (?P<requester>[!-~]+) (?P<request_id>[!-~]+) (?P<operation>[!-~]+) (?P<key>[!-~]+) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\" (?P<http_status>\d{1,3}) (?P<error_code>[!-~]+) (?P<bytes_sent>[\d\-.]+) (?P<object_size>[\d\-.]+) (?P<total_time>[\d\-.]+) (?P<turn_around_time>[\d\-.]+) "(?P<referer>[^\"]*)" "(?P<user_agent>[^\"]*)" (?P<version_id>[!-~]+) (?P<host_id>[!-~]+) (?P<signature_version>[!-~]+) (?P<cipher_suite>[!-~]+) (?P<authentication_type>[!-~]+) (?P<host_header>[!-~]+) (?P<tls_version>[!-~]+) (?P<access_point_arn>[!-~]+)`),
-			regexp.MustCompile(`^(?P<bucket_owner>[!-~]+) (?P<bucket>[!-~]+) (?P<time>\[[^\]]+\]) (?P<remote_ip>[!-~]+) (?P<requester>[!-~]+) (?P<request_id>[!-~]+) (?P<operation>[!-~]+) (?P<key>[!-~]+) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\" (?P<http_status>\d{1,3}) (?P<error_code>[!-~]+) (?P<bytes_sent>[\d\-.]+) (?P<object_size>[\d\-.]+) (?P<total_time>[\d\-.]+) (?P<turn_around_time>[\d\-.]+) "(?P<referer>[^\"]*)" "(?P<user_agent>[^\"]*)" (?P<version_id>[!-~]+) (?P<host_id>[!-~]+) (?P<signature_version>[!-~]+) (?P<cipher_suite>[!-~]+) (?P<authentication_type>[!-~]+) (?P<host_header>[!-~]+) (?P<tls_version>[!-~]+)`),
-			regexp.MustCompile(`^(?P<bucket_owner>[!-~]+) (?P<bucket>[!-~]+) (?P<time>\[[^\]]+\]) (?P<remote_ip>[!-~]+) (?P<requester>[!-~]+) (?P<request_id>[!-~]+) (?P<operation>[!-~]+) (?P<key>[!-~]+) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\" (?P<http_status>\d{1,3}) (?P<error_code>[!-~]+) (?P<bytes_sent>[\d\-.]+) (?P<object_size>[\d\-.]+) (?P<total_time>[\d\-.]+) (?P<turn_around_time>[\d\-.]+) "(?P<referer>[^\"]*)" "(?P<user_agent>[^\"]*)" (?P<version_id>[!-~]+) (?P<host_id>[!-~]+) (?P<signature_version>[!-~]+) (?P<cipher_suite>[!-~]+) (?P<authentication_type>[!-~]+) (?P<host_header>[!-~]+)`),
-			regexp.MustCompile(`^(?P<bucket_owner>[!-~]+) (?P<bucket>[!-~]+) (?P<time>\[[^\]]+\]) (?P<remote_ip>[!-~]+) (?P<requester>[!-~]+) (?P<request_id>[!-~]+) (?P<operation>[!-~]+) (?P<key>[!-~]+) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\" (?P<http_status>\d{1,3}) (?P<error_code>[!-~]+) (?P<bytes_sent>[\d\-.]+) (?P<object_size>[\d\-.]+) (?P<total_time>[\d\-.]+) (?P<turn_around_time>[\d\-.]+) "(?P<referer>[^\"]*)" "(?P<user_agent>[^\"]*)" (?P<version_id>[!-~]+)`),
-		},
+		patterns:    preset.patterns(),
 	}
 	if opt.LineHandler == nil {
 		p.opt.LineHandler = JSONLineHandler
@@ -166,14 +320,13 @@ func NewS3RegexParser(ctx context.Context, w io.Writer, opt Option) *RegexParser
 // NewCFRegexParser initializes a new RegexParser for parsing Amazon CloudFront logs.
 // It keywords patterns tailored to the CloudFront log format, simplifying the parsing of CloudFront access logs.
 func NewCFRegexParser(ctx context.Context, w io.Writer, opt Option) *RegexParser {
+	preset := mustPreset("cloudfront")
 	p := &RegexParser{
 		ctx:         ctx,
 		w:           w,
-		lineDecoder: regexLineDecoder,
+		lineDecoder: preset.decoder(regexLineDecoder, opt),
 		opt:         opt,
-		patterns: []*regexp.Regexp{
-			regexp.MustCompile(`^(?P<date>[\d\-.:]+)\t(?P<time>[\d\-.:]+)\t(?P<x_edge_location>[ -~]+)\t(?P<sc_bytes>[\d\-.]+)\t(?P<c_ip>[ -~]+)\t(?P<cs_method>[ -~]+)\t(?P<cs_host>[ -~]+)\t(?P<cs_uri_stem>[ -~]+)\t(?P<sc_status>\d{1,3}|-)\t(?P<cs_referer>[^\"]*)\t(?P<cs_user_agent>[^\"]*)\t(?P<cs_uri_query>[ -~]+)\t(?P<cs_cookie>\S+)\t(?P<x_edge_result_type>[ -~]+)\t(?P<x_edge_request_id>[ -~]+)\t(?P<x_host_header>[ -~]+)\t(?P<cs_protocol>[ -~]+)\t(?P<cs_bytes>[\d\-.]+)\t(?P<time_taken>[\d\-.]+)\t(?P<x_forwarded_for>[ -~]+)\t(?P<ssl_protocol>[ -~]+)\t(?P<ssl_cipher>[ -~]+)\t(?P<x_edge_response_result_type>[ -~]+)\t(?P<cs_protocol_version>[ -~]+)\t(?P<fle_status>[ -~]+)\t(?P<fle_encrypted_fields>\S+)\t(?P<c_port>[\d\-.]+)\t(?P<time_to_first_byte>[\d\-.]+)\t(?P<x_edge_detailed_result_type>[ -~]+)\t(?P<sc_content_type>[ -~]+)\t(?P<sc_content_len>[\d\-.]+)\t(?P<sc_range_start>[\d\-.]+)\t(?P<sc_range_end>[\d\-.]+)`),
-		},
+		patterns:    preset.patterns(),
 	}
 	if opt.LineHandler == nil {
 		p.opt.LineHandler = JSONLineHandler
@@ -183,15 +336,17 @@ func NewCFRegexParser(ctx context.Context, w io.Writer, opt Option) *RegexParser
 
 // NewALBRegexParser initializes a new RegexParser for parsing AWS Application Load Balancer (ALB) access logs.
 // It comes preconfigured with patterns designed to parse ALB logs, making it easier to extract useful data from ALB logs.
+// The patterns cover the format versions from the newest with conn_trace_id back to the original one ending at
+// trace_id, as well as the "- - - " request logged for malformed requests. The patterns of older versions must
+// match the whole line, so that a malformed line of a newer version is not taken for an older one.
 func NewALBRegexParser(ctx context.Context, w io.Writer, opt Option) *RegexParser {
+	preset := mustPreset("alb")
 	p := &RegexParser{
 		ctx:         ctx,
 		w:           w,
-		lineDecoder: regexLineDecoder,
+		lineDecoder: preset.decoder(regexLineDecoder, opt),
 		opt:         opt,
-		patterns: []*regexp.Regexp{
-			regexp.MustCompile(`^(?P<type>[!-~]+) (?P<time>[!-~]+) (?P<elb>[!-~]+) (?P<client_port>[!-~]+) (?P<target_port>[!-~]+) (?P<request_processing_time>[\d\-.]+) (?P<target_processing_time>[\d\-.]+) (?P<response_processing_time>[\d\-.]+) (?P<elb_status_code>\d{1,3}|-) (?P<target_status_code>\d{1,3}|-) (?P<received_bytes>[\d\-.]+) (?P<sent_bytes>[\d\-.]+) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-|-)\" "(?P<user_agent>[^\"]*)" (?P<ssl_cipher>[!-~]+) (?P<ssl_protocol>[!-~]+) (?P<target_group_arn>[!-~]+) "(?P<trace_id>[ -~]+)" "(?P<domain_name>[ -~]+)" "(?P<chosen_cert_arn>[ -~]+)" (?P<matched_rule_priority>[!-~]+) (?P<request_creation_time>[!-~]+) "(?P<actions_executed>[ -~]+)" "(?P<redirect_url>[ -~]+)" "(?P<error_reason>[ -~]+)" "(?P<target_port_list>[ -~]+)" "(?P<target_status_code_list>[ -~]+)" "(?P<classification>[ -~]+)" "(?P<classification_reason>[ -~]+)"`),
-		},
+		patterns:    preset.patterns(),
 	}
 	if opt.LineHandler == nil {
 		p.opt.LineHandler = JSONLineHandler
@@ -219,6 +374,7 @@ func NewNLBRegexParser(ctx context.Context, w io.Writer, opt Option) *RegexParse
 
 // NewCLBRegexParser initializes a new RegexParser for parsing AWS Classic Load Balancer (CLB) access logs.
 // It provides patterns that are tailored to the CLB log format, enabling efficient parsing of CLB logs.
+// Both HTTP(S) listener lines and TCP listener lines, whose request is logged as "- - - ", are covered.
 func NewCLBRegexParser(ctx context.Context, w io.Writer, opt Option) *RegexParser {
 	p := &RegexParser{
 		ctx:         ctx,
@@ -226,10 +382,72 @@ func NewCLBRegexParser(ctx context.Context, w io.Writer, opt Option) *RegexParse
 		lineDecoder: regexLineDecoder,
 		opt:         opt,
 		patterns: []*regexp.Regexp{
-			regexp.MustCompile(`^(?P<time>[!-~]+) (?P<elb>[!-~]+) (?P<client_port>[!-~]+) (?P<backend_port>[!-~]+) (?P<request_processing_time>[\d\-.]+) (?P<backend_processing_time>[\d\-.]+) (?P<response_processing_time>[\d\-.]+) (?P<elb_status_code>\d{1,3}|-) (?P<backend_status_code>\d{1,3}|-) (?P<received_bytes>[\d\-.]+) (?P<sent_bytes>[\d\-.]+) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\" "(?P<user_agent>[^\"]*)" (?P<ssl_cipher>[!-~]+) (?P<ssl_protocol>[!-~]+)`),
-			regexp.MustCompile(`^(?P<time>[!-~]+) (?P<elb>[!-~]+) (?P<client_port>[!-~]+) (?P<backend_port>[!-~]+) (?P<request_processing_time>[\d\-.]+) (?P<backend_processing_time>[\d\-.]+) (?P<response_processing_time>[\d\-.]+) (?P<elb_status_code>\d{1,3}|-) (?P<backend_status_code>\d{1,3}|-) (?P<received_bytes>[\d\-.]+) (?P<sent_bytes>[\d\-.]+) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\"`),
+			regexp.MustCompile(`^(?P<time>[!-~]+) (?P<elb>[!-~]+) (?P<client_port>[!-~]+) (?P<backend_port>[!-~]+) (?P<request_processing_time>[\d\-.]+) (?P<backend_processing_time>[\d\-.]+) (?P<response_processing_time>[\d\-.]+) (?P<elb_status_code>\d{1,3}|-) (?P<backend_status_code>\d{1,3}|-) (?P<received_bytes>[\d\-.]+) (?P<sent_bytes>[\d\-.]+) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-) ?\" "(?P<user_agent>[^\"]*)" (?P<ssl_cipher>[!-~]+) (?P<ssl_protocol>[!-~]+)`),
+			regexp.MustCompile(`^(?P<time>[!-~]+) (?P<elb>[!-~]+) (?P<client_port>[!-~]+) (?P<backend_port>[!-~]+) (?P<request_processing_time>[\d\-.]+) (?P<backend_processing_time>[\d\-.]+) (?P<response_processing_time>[\d\-.]+) (?P<elb_status_code>\d{1,3}|-) (?P<backend_status_code>\d{1,3}|-) (?P<received_bytes>[\d\-.]+) (?P<sent_bytes>[\d\-.]+) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-) ?\"`),
+		},
+	}
+	if opt.LineHandler == nil {
+		p.opt.LineHandler = JSONLineHandler
+	}
+	return p
+}
+
+// NewApacheErrorRegexParser initializes a new RegexParser for parsing Apache HTTP Server error logs.
+// It covers both the 2.4 and 2.2 layouts and exposes the severity as the "level" field for filtering.
+func NewApacheErrorRegexParser(ctx context.Context, w io.Writer, opt Option) *RegexParser {
+	p := &RegexParser{
+		ctx:         ctx,
+		w:           w,
+		lineDecoder: regexLineDecoder,
+		opt:         opt,
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\[(?P<time>[^\]]+)\] \[(?P<module>[^:\]]+):(?P<level>[a-z0-9]+)\] \[pid (?P<pid>\d+)(?::tid (?P<tid>\d+))?\] \[client (?P<client>[^\]]+)\] (?P<message>.*)`),
+			regexp.MustCompile(`^\[(?P<time>[^\]]+)\] \[(?P<module>[^:\]]+):(?P<level>[a-z0-9]+)\] \[pid (?P<pid>\d+)(?::tid (?P<tid>\d+))?\] (?P<message>.*)`),
+			regexp.MustCompile(`^\[(?P<time>[^\]]+)\] \[(?P<level>[a-z0-9]+)\] \[client (?P<client>[^\]]+)\] (?P<message>.*)`),
+			regexp.MustCompile(`^\[(?P<time>[^\]]+)\] \[(?P<level>[a-z0-9]+)\] (?P<message>.*)`),
+		},
+	}
+	if opt.LineHandler == nil {
+		p.opt.LineHandler = JSONLineHandler
+	}
+	return p
+}
+
+// NewNginxErrorRegexParser initializes a new RegexParser for parsing Nginx error logs.
+// It extracts the severity as the "level" field, along with the process information and the client address if present.
+func NewNginxErrorRegexParser(ctx context.Context, w io.Writer, opt Option) *RegexParser {
+	p := &RegexParser{
+		ctx:         ctx,
+		w:           w,
+		lineDecoder: regexLineDecoder,
+		opt:         opt,
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`^(?P<time>\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}) \[(?P<level>[a-z]+)\] (?P<pid>\d+)#(?P<tid>\d+): \*(?P<connection>\d+) (?P<message>.+?), client: (?P<client>[^,]+)(?:, (?P<context>.+))?$`),
+			regexp.MustCompile(`^(?P<time>\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}) \[(?P<level>[a-z]+)\] (?P<pid>\d+)#(?P<tid>\d+): (?:\*(?P<connection>\d+) )?(?P<message>.*)`),
+		},
+	}
+	if opt.LineHandler == nil {
+		p.opt.LineHandler = JSONLineHandler
+	}
+	return p
+}
+
+// NewMySQLSlowRegexParser initializes a new RegexParser for parsing MySQL slow query logs.
+// Each multi-line entry is treated as a single record, and the query is also emitted in normalized form as "query_digest".
+// Note that line numbers in the result refer to records rather than physical lines.
+func NewMySQLSlowRegexParser(ctx context.Context, w io.Writer, opt Option) *RegexParser {
+	p := &RegexParser{
+		ctx:         ctx,
+		w:           w,
+		lineDecoder: mysqlSlowLineDecoder,
+		opt:         opt,
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`^# Time: (?P<time>[^\n]+)\n# User@Host: (?P<user>[^\[\n]*)\[[^\]\n]*\] @ (?P<host>[^ \[\n]*) \[(?P<ip>[^\]\n]*)\](?:\s+Id:\s+(?P<thread_id>\d+))?\n# Query_time: (?P<query_time>[\d.]+)\s+Lock_time: (?P<lock_time>[\d.]+)\s+Rows_sent: (?P<rows_sent>\d+)\s+Rows_examined: (?P<rows_examined>\d+)[^\n]*\n(?:[#][^\n]*\n)*(?:use (?P<database>[^;\n]+);\n)?(?:SET timestamp=(?P<timestamp>\d+);\n)?(?P<query>(?s:.+))`),
+			regexp.MustCompile(`^# User@Host: (?P<user>[^\[\n]*)\[[^\]\n]*\] @ (?P<host>[^ \[\n]*) \[(?P<ip>[^\]\n]*)\](?:\s+Id:\s+(?P<thread_id>\d+))?\n# Query_time: (?P<query_time>[\d.]+)\s+Lock_time: (?P<lock_time>[\d.]+)\s+Rows_sent: (?P<rows_sent>\d+)\s+Rows_examined: (?P<rows_examined>\d+)[^\n]*\n(?:[#][^\n]*\n)*(?:use (?P<database>[^;\n]+);\n)?(?:SET timestamp=(?P<timestamp>\d+);\n)?(?P<query>(?s:.+))`),
 		},
 	}
+	p.opt.split = mysqlSlowSplit
+	p.opt.derived = func(label string) bool { return label == "query_digest" }
 	if opt.LineHandler == nil {
 		p.opt.LineHandler = JSONLineHandler
 	}
@@ -514,6 +514,82 @@ func TestRegexParser_AddPatterns(t *testing.T) {
 	}
 }
 
+func TestRegexParser_SetFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		input       string
+		wantPattern string
+		want        string
+		wantErr     bool
+	}{
+		{
+			name:        "nginx combined",
+			format:      `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent"`,
+			input:       `192.168.1.1 - - [10/Oct/2000:13:55:36 -0700] "GET /index.html HTTP/1.1" 200 2326 "-" "Mozilla/5.0 (X11; Linux x86_64)"`,
+			wantPattern: `^(?P<remote_addr>[^ ]*) - (?P<remote_user>[^ ]*) \[(?P<time_local>[^\]]*)\] "(?P<request>[^"]*)" (?P<status>[^ ]*) (?P<body_bytes_sent>[^ ]*) "(?P<http_referer>[^"]*)" "(?P<http_user_agent>[^"]*)"$`,
+			want:        `{"remote_addr":"192.168.1.1","remote_user":"-","time_local":"10/Oct/2000:13:55:36 -0700","request":"GET /index.html HTTP/1.1","status":"200","body_bytes_sent":"2326","http_referer":"-","http_user_agent":"Mozilla/5.0 (X11; Linux x86_64)"}` + "\n",
+		},
+		{
+			name:        "braced variable and last variable",
+			format:      `${host}:$port $message`,
+			input:       `example.com:443 hello world`,
+			wantPattern: `^(?P<host>[^:]*):(?P<port>[^ ]*) (?P<message>.*)$`,
+			want:        `{"host":"example.com","port":"443","message":"hello world"}` + "\n",
+		},
+		{
+			name:    "not separated",
+			format:  `$a$b`,
+			wantErr: true,
+		},
+		{
+			name:    "duplicate",
+			format:  `$a $a`,
+			wantErr: true,
+		},
+		{
+			name:    "empty name",
+			format:  `$ $a`,
+			wantErr: true,
+		},
+		{
+			name:    "unclosed brace",
+			format:  `${a $b`,
+			wantErr: true,
+		},
+		{
+			name:    "no variable",
+			format:  `plain text`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			p := NewRegexParser(context.Background(), buf, Option{})
+			if err := p.AddPattern(stringPattern); err != nil {
+				t.Fatal(err)
+			}
+			err := p.SetFormat(tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(p.Patterns()) != 1 || p.Patterns()[0].String() != tt.wantPattern {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", p.Patterns(), tt.wantPattern)
+			}
+			if _, err := p.ParseString(tt.input); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
 func TestRegexParser_Patterns(t *testing.T) {
 	type fields struct {
 		lineDecoder lineDecoder
@@ -585,6 +661,30 @@ func TestNewApacheCLFRegexParser(t *testing.T) {
 				input: `123.45.67.89	-	frank	[10/Oct/2000:13:55:36 -0700]	"GET /apache_pb.gif HTTP/1.0"	200	2326`,
 			},
 			want: `{"remote_host":"123.45.67.89","remote_logname":"-","remote_user":"frank","datetime":"[10/Oct/2000:13:55:36 -0700]","method":"GET","request_uri":"/apache_pb.gif","protocol":"HTTP/1.0","status":"200","size":"2326"}
+`,
+		},
+		{
+			name: "escaped quotes",
+			parserArgs: parserArgs{
+				input: `123.45.67.89 - - [10/Oct/2000:13:55:36 -0700] "GET /search HTTP/1.1" 200 2326 "http://www.example.com/?q=\"a\"" "Mozilla/5.0 \"quoted\""`,
+			},
+			want: `{"remote_host":"123.45.67.89","remote_logname":"-","remote_user":"-","datetime":"[10/Oct/2000:13:55:36 -0700]","method":"GET","request_uri":"/search","protocol":"HTTP/1.1","status":"200","size":"2326","referer":"http://www.example.com/?q=\\\"a\\\"","user_agent":"Mozilla/5.0 \\\"quoted\\\""}
+`,
+		},
+		{
+			name: "empty request",
+			parserArgs: parserArgs{
+				input: `123.45.67.89 - - [10/Oct/2000:13:55:36 -0700] "-" 408 -`,
+			},
+			want: `{"remote_host":"123.45.67.89","remote_logname":"-","remote_user":"-","datetime":"[10/Oct/2000:13:55:36 -0700]","request":"-","status":"408","size":"-"}
+`,
+		},
+		{
+			name: "malformed request",
+			parserArgs: parserArgs{
+				input: `123.45.67.89 - - [10/Oct/2000:13:55:36 -0700] "\x16\x03\x01\x02\x00\x01" 400 226 "-" "-"`,
+			},
+			want: `{"remote_host":"123.45.67.89","remote_logname":"-","remote_user":"-","datetime":"[10/Oct/2000:13:55:36 -0700]","request":"\\x16\\x03\\x01\\x02\\x00\\x01","status":"400","size":"226","referer":"-","user_agent":"-"}
 `,
 		},
 		{
@@ -627,6 +727,14 @@ func TestNewApacheCLFWithVHostRegexParser(t *testing.T) {
 		parserArgs parserArgs
 		want       string
 	}{
+		{
+			name: "vhost_combined with escaped quotes",
+			parserArgs: parserArgs{
+				input: `www.example.com:443 123.45.67.89 - - [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.1" 200 2326 "-" "Mozilla/5.0 \"quoted\""`,
+			},
+			want: `{"virtual_host":"www.example.com:443","remote_host":"123.45.67.89","remote_logname":"-","remote_user":"-","datetime":"[10/Oct/2000:13:55:36 -0700]","method":"GET","request_uri":"/","protocol":"HTTP/1.1","status":"200","size":"2326","referer":"-","user_agent":"Mozilla/5.0 \\\"quoted\\\""}
+`,
+		},
 		{
 			name: "combined",
 			parserArgs: parserArgs{
@@ -817,6 +925,38 @@ func TestNewALBRegexParser(t *testing.T) {
 				input: `http 2018-07-02T22:23:00.186641Z app/my-loadbalancer/50dc6c495c0c9188 192.168.131.39:2817 10.0.0.1:80 0.000 0.001 0.000 200 200 34 366 "GET http://www.example.com:80/ HTTP/1.1" "curl/7.46.0" - - arn:aws:elasticloadbalancing:us-east-2:123456789012:targetgroup/my-targets/73e2d6bc24d8a067 "Root=1-58337262-36d228ad5d99923122bbe354" "-" "-" 0 2018-07-02T22:22:48.364000Z "forward" "-" "-" "10.0.0.1:80" "200" "-" "-"`,
 			},
 			want: `{"type":"http","time":"2018-07-02T22:23:00.186641Z","elb":"app/my-loadbalancer/50dc6c495c0c9188","client_port":"192.168.131.39:2817","target_port":"10.0.0.1:80","request_processing_time":"0.000","target_processing_time":"0.001","response_processing_time":"0.000","elb_status_code":"200","target_status_code":"200","received_bytes":"34","sent_bytes":"366","method":"GET","request_uri":"http://www.example.com:80/","protocol":"HTTP/1.1","user_agent":"curl/7.46.0","ssl_cipher":"-","ssl_protocol":"-","target_group_arn":"arn:aws:elasticloadbalancing:us-east-2:123456789012:targetgroup/my-targets/73e2d6bc24d8a067","trace_id":"Root=1-58337262-36d228ad5d99923122bbe354","domain_name":"-","chosen_cert_arn":"-","matched_rule_priority":"0","request_creation_time":"2018-07-02T22:22:48.364000Z","actions_executed":"forward","redirect_url":"-","error_reason":"-","target_port_list":"10.0.0.1:80","target_status_code_list":"200","classification":"-","classification_reason":"-"}
+`,
+		},
+		{
+			name: "conn_trace_id",
+			parserArgs: parserArgs{
+				input: `h2 2024-03-01T10:00:00.123456Z app/my-loadbalancer/50dc6c495c0c9188 192.168.131.39:2817 10.0.0.1:80 0.000 0.001 0.000 200 200 34 366 "GET https://www.example.com:443/ HTTP/2.0" "curl/8.4.0" ECDHE-RSA-AES128-GCM-SHA256 TLSv1.2 arn:aws:elasticloadbalancing:us-east-2:123456789012:targetgroup/my-targets/73e2d6bc24d8a067 "Root=1-58337262-36d228ad5d99923122bbe354" "www.example.com" "arn:aws:acm:us-east-2:123456789012:certificate/12345678-1234-1234-1234-123456789012" 1 2024-03-01T10:00:00.120000Z "forward" "-" "-" "10.0.0.1:80" "200" "-" "-" TID_1234abcd`,
+			},
+			want: `{"type":"h2","time":"2024-03-01T10:00:00.123456Z","elb":"app/my-loadbalancer/50dc6c495c0c9188","client_port":"192.168.131.39:2817","target_port":"10.0.0.1:80","request_processing_time":"0.000","target_processing_time":"0.001","response_processing_time":"0.000","elb_status_code":"200","target_status_code":"200","received_bytes":"34","sent_bytes":"366","method":"GET","request_uri":"https://www.example.com:443/","protocol":"HTTP/2.0","user_agent":"curl/8.4.0","ssl_cipher":"ECDHE-RSA-AES128-GCM-SHA256","ssl_protocol":"TLSv1.2","target_group_arn":"arn:aws:elasticloadbalancing:us-east-2:123456789012:targetgroup/my-targets/73e2d6bc24d8a067","trace_id":"Root=1-58337262-36d228ad5d99923122bbe354","domain_name":"www.example.com","chosen_cert_arn":"arn:aws:acm:us-east-2:123456789012:certificate/12345678-1234-1234-1234-123456789012","matched_rule_priority":"1","request_creation_time":"2024-03-01T10:00:00.120000Z","actions_executed":"forward","redirect_url":"-","error_reason":"-","target_port_list":"10.0.0.1:80","target_status_code_list":"200","classification":"-","classification_reason":"-","conn_trace_id":"TID_1234abcd"}
+`,
+		},
+		{
+			name: "without target lists",
+			parserArgs: parserArgs{
+				input: `http 2018-07-02T22:23:00.186641Z app/my-loadbalancer/50dc6c495c0c9188 192.168.131.39:2817 10.0.0.1:80 0.000 0.001 0.000 200 200 34 366 "GET http://www.example.com:80/ HTTP/1.1" "curl/7.46.0" - - arn:aws:elasticloadbalancing:us-east-2:123456789012:targetgroup/my-targets/73e2d6bc24d8a067 "Root=1-58337262-36d228ad5d99923122bbe354" "-" "-" 0 2018-07-02T22:22:48.364000Z "forward" "-" "-"`,
+			},
+			want: `{"type":"http","time":"2018-07-02T22:23:00.186641Z","elb":"app/my-loadbalancer/50dc6c495c0c9188","client_port":"192.168.131.39:2817","target_port":"10.0.0.1:80","request_processing_time":"0.000","target_processing_time":"0.001","response_processing_time":"0.000","elb_status_code":"200","target_status_code":"200","received_bytes":"34","sent_bytes":"366","method":"GET","request_uri":"http://www.example.com:80/","protocol":"HTTP/1.1","user_agent":"curl/7.46.0","ssl_cipher":"-","ssl_protocol":"-","target_group_arn":"arn:aws:elasticloadbalancing:us-east-2:123456789012:targetgroup/my-targets/73e2d6bc24d8a067","trace_id":"Root=1-58337262-36d228ad5d99923122bbe354","domain_name":"-","chosen_cert_arn":"-","matched_rule_priority":"0","request_creation_time":"2018-07-02T22:22:48.364000Z","actions_executed":"forward","redirect_url":"-","error_reason":"-"}
+`,
+		},
+		{
+			name: "without domain name",
+			parserArgs: parserArgs{
+				input: `http 2016-08-10T22:08:42.945958Z app/my-loadbalancer/50dc6c495c0c9188 192.168.131.39:2817 10.0.0.1:80 0.000 0.001 0.000 200 200 34 366 "GET http://www.example.com:80/ HTTP/1.1" "curl/7.46.0" - - arn:aws:elasticloadbalancing:us-east-2:123456789012:targetgroup/my-targets/73e2d6bc24d8a067 "Root=1-58337262-36d228ad5d99923122bbe354"`,
+			},
+			want: `{"type":"http","time":"2016-08-10T22:08:42.945958Z","elb":"app/my-loadbalancer/50dc6c495c0c9188","client_port":"192.168.131.39:2817","target_port":"10.0.0.1:80","request_processing_time":"0.000","target_processing_time":"0.001","response_processing_time":"0.000","elb_status_code":"200","target_status_code":"200","received_bytes":"34","sent_bytes":"366","method":"GET","request_uri":"http://www.example.com:80/","protocol":"HTTP/1.1","user_agent":"curl/7.46.0","ssl_cipher":"-","ssl_protocol":"-","target_group_arn":"arn:aws:elasticloadbalancing:us-east-2:123456789012:targetgroup/my-targets/73e2d6bc24d8a067","trace_id":"Root=1-58337262-36d228ad5d99923122bbe354"}
+`,
+		},
+		{
+			name: "malformed request",
+			parserArgs: parserArgs{
+				input: `http 2018-07-02T22:23:00.186641Z app/my-loadbalancer/50dc6c495c0c9188 192.168.131.39:2817 - -1 -1 -1 400 - 0 0 "- - - " "-" - - - "-" "-" "-" - 2018-07-02T22:23:00.186000Z "-" "-" "-" "-" "-" "-" "-"`,
+			},
+			want: `{"type":"http","time":"2018-07-02T22:23:00.186641Z","elb":"app/my-loadbalancer/50dc6c495c0c9188","client_port":"192.168.131.39:2817","target_port":"-","request_processing_time":"-1","target_processing_time":"-1","response_processing_time":"-1","elb_status_code":"400","target_status_code":"-","received_bytes":"0","sent_bytes":"0","method":"-","request_uri":"-","protocol":"-","user_agent":"-","ssl_cipher":"-","ssl_protocol":"-","target_group_arn":"-","trace_id":"-","domain_name":"-","chosen_cert_arn":"-","matched_rule_priority":"-","request_creation_time":"2018-07-02T22:23:00.186000Z","actions_executed":"-","redirect_url":"-","error_reason":"-","target_port_list":"-","target_status_code_list":"-","classification":"-","classification_reason":"-"}
 `,
 		},
 		{
@@ -905,6 +1045,14 @@ func TestNewCLBRegexParser(t *testing.T) {
 				input: `2015-05-13T23:39:43.945958Z my-loadbalancer 192.168.131.39:2817 10.0.0.1:80 0.000073 0.001048 0.000057 200 200 0 29 "GET http://www.example.com:80/ HTTP/1.1"`,
 			},
 			want: `{"time":"2015-05-13T23:39:43.945958Z","elb":"my-loadbalancer","client_port":"192.168.131.39:2817","backend_port":"10.0.0.1:80","request_processing_time":"0.000073","backend_processing_time":"0.001048","response_processing_time":"0.000057","elb_status_code":"200","backend_status_code":"200","received_bytes":"0","sent_bytes":"29","method":"GET","request_uri":"http://www.example.com:80/","protocol":"HTTP/1.1"}
+`,
+		},
+		{
+			name: "tcp listener",
+			parserArgs: parserArgs{
+				input: `2015-05-13T23:39:43.945958Z my-loadbalancer 192.168.131.39:2817 10.0.0.1:80 -1 -1 -1 - - 0 0 "- - - " "-" - -`,
+			},
+			want: `{"time":"2015-05-13T23:39:43.945958Z","elb":"my-loadbalancer","client_port":"192.168.131.39:2817","backend_port":"10.0.0.1:80","request_processing_time":"-1","backend_processing_time":"-1","response_processing_time":"-1","elb_status_code":"-","backend_status_code":"-","received_bytes":"0","sent_bytes":"0","method":"-","request_uri":"-","protocol":"-","user_agent":"-","ssl_cipher":"-","ssl_protocol":"-"}
 `,
 		},
 		{
@@ -929,3 +1077,193 @@ func TestNewCLBRegexParser(t *testing.T) {
 		})
 	}
 }
+
+func TestNewApacheErrorRegexParser(t *testing.T) {
+	type parserArgs struct {
+		input string
+		opt   Option
+	}
+	tests := []struct {
+		name       string
+		parserArgs parserArgs
+		want       string
+	}{
+		{
+			name: "2.4 with client",
+			parserArgs: parserArgs{
+				input: `[Wed Oct 11 14:32:52.123456 2000] [core:error] [pid 1234:tid 140245] [client 192.0.2.1:56789] AH00126: Invalid URI in request GET /../../etc/passwd HTTP/1.1`,
+			},
+			want: `{"time":"Wed Oct 11 14:32:52.123456 2000","module":"core","level":"error","pid":"1234","tid":"140245","client":"192.0.2.1:56789","message":"AH00126: Invalid URI in request GET /../../etc/passwd HTTP/1.1"}
+`,
+		},
+		{
+			name: "2.4 without client",
+			parserArgs: parserArgs{
+				input: `[Wed Oct 11 14:32:52.123456 2000] [mpm_event:notice] [pid 1234] AH00489: Apache/2.4.58 (Unix) configured -- resuming normal operations`,
+			},
+			want: `{"time":"Wed Oct 11 14:32:52.123456 2000","module":"mpm_event","level":"notice","pid":"1234","tid":"","message":"AH00489: Apache/2.4.58 (Unix) configured -- resuming normal operations"}
+`,
+		},
+		{
+			name: "2.2 with client",
+			parserArgs: parserArgs{
+				input: `[Wed Oct 11 14:32:52 2000] [error] [client 192.0.2.1] client denied by server configuration: /export/home/live/ap/htdocs/test`,
+			},
+			want: `{"time":"Wed Oct 11 14:32:52 2000","level":"error","client":"192.0.2.1","message":"client denied by server configuration: /export/home/live/ap/htdocs/test"}
+`,
+		},
+		{
+			name: "2.2 without client",
+			parserArgs: parserArgs{
+				input: `[Wed Oct 11 14:32:52 2000] [notice] Apache/2.2.34 (Unix) configured -- resuming normal operations`,
+			},
+			want: `{"time":"Wed Oct 11 14:32:52 2000","level":"notice","message":"Apache/2.2.34 (Unix) configured -- resuming normal operations"}
+`,
+		},
+		{
+			name: "filter by level",
+			parserArgs: parserArgs{
+				input: `[Wed Oct 11 14:32:52 2000] [error] [client 192.0.2.1] File does not exist: /var/www/favicon.ico
+[Wed Oct 11 14:32:53 2000] [notice] caught SIGTERM, shutting down`,
+				opt: Option{
+					Filters: []string{"level == error"},
+				},
+			},
+			want: `{"time":"Wed Oct 11 14:32:52 2000","level":"error","client":"192.0.2.1","message":"File does not exist: /var/www/favicon.ico"}
+`,
+		},
+		{
+			name: "unmatch",
+			parserArgs: parserArgs{
+				input: `Wed Oct 11 14:32:52 2000 error client denied by server configuration`,
+			},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			p := NewApacheErrorRegexParser(context.Background(), w, tt.parserArgs.opt)
+			_, err := p.ParseString(tt.parserArgs.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := w.String(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewNginxErrorRegexParser(t *testing.T) {
+	type parserArgs struct {
+		input string
+	}
+	tests := []struct {
+		name       string
+		parserArgs parserArgs
+		want       string
+	}{
+		{
+			name: "with client",
+			parserArgs: parserArgs{
+				input: `2024/03/10 12:00:00 [error] 1234#1234: *5 open() "/usr/share/nginx/html/favicon.ico" failed (2: No such file or directory), client: 192.0.2.1, server: localhost, request: "GET /favicon.ico HTTP/1.1", host: "localhost"`,
+			},
+			want: `{"time":"2024/03/10 12:00:00","level":"error","pid":"1234","tid":"1234","connection":"5","message":"open() \"/usr/share/nginx/html/favicon.ico\" failed (2: No such file or directory)","client":"192.0.2.1","context":"server: localhost, request: \"GET /favicon.ico HTTP/1.1\", host: \"localhost\""}
+`,
+		},
+		{
+			name: "without client",
+			parserArgs: parserArgs{
+				input: `2024/03/10 12:00:00 [notice] 1#1: start worker processes`,
+			},
+			want: `{"time":"2024/03/10 12:00:00","level":"notice","pid":"1","tid":"1","connection":"","message":"start worker processes"}
+`,
+		},
+		{
+			name: "unmatch",
+			parserArgs: parserArgs{
+				input: `2024-03-10T12:00:00 error start worker processes`,
+			},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			p := NewNginxErrorRegexParser(context.Background(), w, Option{})
+			_, err := p.ParseString(tt.parserArgs.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := w.String(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewMySQLSlowRegexParser(t *testing.T) {
+	type parserArgs struct {
+		input string
+	}
+	tests := []struct {
+		name       string
+		parserArgs parserArgs
+		want       string
+		wantResult *Result
+	}{
+		{
+			name: "multi-line records",
+			parserArgs: parserArgs{
+				input: `# Time: 2024-03-10T12:00:00.123456Z
+# User@Host: app[app] @ localhost [127.0.0.1]  Id:     8
+# Query_time: 2.000123  Lock_time: 0.000100 Rows_sent: 1  Rows_examined: 1000000
+use shop;
+SET timestamp=1710072000;
+SELECT * FROM orders
+WHERE customer_id = 42 AND status IN ('paid', 'shipped');
+# User@Host: root[root] @  [192.0.2.10]  Id:     9
+# Query_time: 1.5  Lock_time: 0.0 Rows_sent: 0  Rows_examined: 20
+SET timestamp=1710072001;
+UPDATE users SET name = 'bob' WHERE id = 7;
+`,
+			},
+			want: `{"time":"2024-03-10T12:00:00.123456Z","user":"app","host":"localhost","ip":"127.0.0.1","thread_id":"8","query_time":"2.000123","lock_time":"0.000100","rows_sent":"1","rows_examined":"1000000","database":"shop","timestamp":"1710072000","query":"SELECT * FROM orders\nWHERE customer_id = 42 AND status IN ('paid', 'shipped');","query_digest":"SELECT * FROM orders WHERE customer_id = ? AND status IN (?+)"}
+{"user":"root","host":"","ip":"192.0.2.10","thread_id":"9","query_time":"1.5","lock_time":"0.0","rows_sent":"0","rows_examined":"20","database":"","timestamp":"1710072001","query":"UPDATE users SET name = 'bob' WHERE id = 7;","query_digest":"UPDATE users SET name = ? WHERE id = ?"}
+`,
+			wantResult: &Result{Total: 2, Matched: 2},
+		},
+		{
+			name: "server header",
+			parserArgs: parserArgs{
+				input: `/usr/sbin/mysqld, Version: 8.0.36 (MySQL Community Server - GPL). started with:
+Tcp port: 3306  Unix socket: /var/run/mysqld/mysqld.sock
+Time                 Id Command    Argument
+# Time: 2024-03-10T12:00:00.123456Z
+# User@Host: app[app] @ localhost [127.0.0.1]  Id:     8
+# Query_time: 2.000123  Lock_time: 0.000100 Rows_sent: 1  Rows_examined: 1000000
+SELECT 1;`,
+			},
+			want: `{"time":"2024-03-10T12:00:00.123456Z","user":"app","host":"localhost","ip":"127.0.0.1","thread_id":"8","query_time":"2.000123","lock_time":"0.000100","rows_sent":"1","rows_examined":"1000000","database":"","timestamp":"","query":"SELECT 1;","query_digest":"SELECT ?"}
+`,
+			wantResult: &Result{Total: 2, Matched: 1, Unmatched: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			p := NewMySQLSlowRegexParser(context.Background(), w, Option{})
+			r, err := p.ParseString(tt.parserArgs.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := w.String(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+			if r.Total != tt.wantResult.Total || r.Matched != tt.wantResult.Matched || r.Unmatched != tt.wantResult.Unmatched {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", r, tt.wantResult)
+			}
+		})
+	}
+}
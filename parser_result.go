@@ -14,16 +14,45 @@ import (
 // Result encapsulates the outcomes of parsing operations, detailing matched, unmatched, excluded,
 // and skipped line counts, along with processing time and source information.
 type Result struct {
-	Total       int           `json:"total"`                // Total number of processed lines.
-	Matched     int           `json:"matched"`              // Count of lines that matched the patterns.
-	Unmatched   int           `json:"unmatched"`            // Count of lines that did not match any patterns.
-	Excluded    int           `json:"excluded"`             // Count of lines excluded based on keyword search.
-	Skipped     int           `json:"skipped"`              // Count of lines skipped explicitly.
-	ElapsedTime time.Duration `json:"elapsedTime"`          // Processing time for the log data.
-	Source      string        `json:"source"`               // Source of the log data.
-	ZipEntries  []string      `json:"zipEntries,omitempty"` // List of processed zip entries, if applicable.
-	Errors      []Errors      `json:"errors"`               // Collection of errors encountered during parsing.
-	inputType   inputType     `json:"-"`                    // Type of input being processed.
+	Total           int             `json:"total"`                     // Total number of processed lines.
+	Matched         int             `json:"matched"`                   // Count of lines that matched the patterns.
+	Unmatched       int             `json:"unmatched"`                 // Count of lines that did not match any patterns.
+	Excluded        int             `json:"excluded"`                  // Count of lines excluded based on keyword search.
+	Skipped         int             `json:"skipped"`                   // Count of lines skipped explicitly.
+	ElapsedTime     time.Duration   `json:"elapsedTime"`               // Processing time for the log data.
+	Source          string          `json:"source"`                    // Source of the log data.
+	ZipEntries      []string        `json:"zipEntries,omitempty"`      // List of processed zip entries, if applicable.
+	Errors          []Errors        `json:"errors"`                    // Collection of errors encountered during parsing.
+	Cancelled       bool            `json:"cancelled"`                 // Whether parsing was cancelled before the end of input.
+	Abandoned       []Abandoned     `json:"abandoned,omitempty"`       // List of sources given up before the end, if any.
+	MaxLineLen      int             `json:"maxLineLength"`             // Length in bytes of the longest line seen.
+	Normalized      int             `json:"normalized"`                // Count of lines whose CRLF line endings were normalized.
+	Transforms      []Transform     `json:"transforms,omitempty"`      // Steps applied to the records, in the order applied.
+	Reloads         []Reload        `json:"reloads,omitempty"`         // Configurations reloaded while parsing, if any.
+	PeakMemory      int64           `json:"peakMemory"`                // Peak estimated bytes of kept unmatched lines and buffered output.
+	DroppedErrors   int             `json:"droppedErrors,omitempty"`   // Count of unmatched lines not kept in Errors to stay within Option.MemoryLimit.
+	Aggregation     *Aggregation    `json:"aggregation,omitempty"`     // Groups and their aggregates written instead of the records, if Option.GroupBy or Option.Aggregates is set.
+	LabelMismatches []LabelMismatch `json:"labelMismatches,omitempty"` // Sources whose labels differ from those of the first source, if Option.CheckLabels is set.
+	inputType       inputType       `json:"-"`                         // Type of input being processed.
+	labels          []string        `json:"-"`                         // Labels of the first record written, if Option.CheckLabels is set.
+	offset          int64           `json:"-"`                         // Byte offset just after the last line read, if tracked.
+}
+
+// LabelMismatch stores the labels of a source that differ from those of the first source written by the parser,
+// which would make the columns of TSV or CSV output combined from the sources ragged.
+type LabelMismatch struct {
+	Source  string   `json:"source"`            // Name of the source, such as the file or the zip entry, or empty for readers and strings.
+	Labels  []string `json:"labels"`            // Labels of the first record written from the source.
+	Want    []string `json:"want"`              // Labels of the first record written from the first source.
+	Missing []string `json:"missing,omitempty"` // Labels of the first source the source lacks.
+	Extra   []string `json:"extra,omitempty"`   // Labels of the source the first source lacks.
+}
+
+// Abandoned stores information about a source that was given up before the end in batch parsing,
+// such as a zip entry that exceeded the per-source timeout. Lines read until then are counted in Result.
+type Abandoned struct {
+	Entry  string `json:"entry"`  // Name of the abandoned source.
+	Reason string `json:"reason"` // Reason why the source was abandoned.
 }
 
 // Errors stores information about log lines that couldn't be parsed
@@ -68,6 +97,21 @@ Unmatched : Number of log line that did not match any pattern
 Excluded  : Number of log line that did not extract by filter expressions
 Skipped   : Number of log line that skipped by line number
 `
+	if r.Cancelled {
+		sumNotes += "Cancelled : Parsing was cancelled, and Total shows the number of log line processed until then\n"
+	}
+	if r.Normalized > 0 {
+		sumNotes += "Normalized: Number of log line that had CRLF line endings normalized\n"
+	}
+	for _, x := range r.Reloads {
+		sumNotes += fmt.Sprintf("Reloaded  : Configuration reloaded from line %d\n", x.LineNumber)
+	}
+	for _, a := range r.Abandoned {
+		sumNotes += fmt.Sprintf("Abandoned : %s (%s)\n", a.Entry, a.Reason)
+	}
+	for _, m := range r.LabelMismatches {
+		sumNotes += fmt.Sprintf("Mismatch  : %s has labels %v instead of %v\n", m.Source, m.Labels, m.Want)
+	}
 	errLabel := `
 /* UNMATCH LINES */
 
@@ -105,13 +149,25 @@ func (r *Result) newSummaryTable(w io.Writer) (*mintab.Table, error) {
 	var i []int
 	switch r.inputType {
 	case inputTypeStream, inputTypeString:
-		i = []int{6, 7, 8, 9}
+		i = []int{6, 7, 8}
 	case inputTypeFile, inputTypeGzip:
-		i = []int{7, 8, 9}
+		i = []int{7, 8}
 	case inputTypeZip:
-		i = []int{8, 9}
+		i = []int{8}
 	default:
 	}
+	if !r.Cancelled {
+		i = append(i, 9)
+	}
+	i = append(i, 10, 11)
+	if r.Normalized == 0 {
+		i = append(i, 12)
+	}
+	i = append(i, 13, 14, 15)
+	if r.DroppedErrors == 0 {
+		i = append(i, 16)
+	}
+	i = append(i, 17, 18)
 	table := mintab.New(w, mintab.WithFormat(mintab.FormatText), mintab.WithIgnoreFields(i))
 	r.Errors = []Errors{}
 	if err := table.Load(r); err != nil {
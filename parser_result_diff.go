@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"regexp"
+	"sort"
+)
+
+var (
+	signatureHex    = regexp.MustCompile(`\b[0-9a-fA-F]{8,}\b`)
+	signatureNumber = regexp.MustCompile(`\d+`)
+)
+
+// ResultDiff reports the changes between two parsing results, such as before and after
+// a change of patterns or an upstream log format. Count deltas are calculated as b - a.
+type ResultDiff struct {
+	Total           int              `json:"total"`           // Delta of the total number of processed lines.
+	Matched         int              `json:"matched"`         // Delta of the count of matched lines.
+	Unmatched       int              `json:"unmatched"`       // Delta of the count of unmatched lines.
+	Excluded        int              `json:"excluded"`        // Delta of the count of excluded lines.
+	Skipped         int              `json:"skipped"`         // Delta of the count of skipped lines.
+	MatchRateBefore float64          `json:"matchRateBefore"` // Ratio of matched lines to processed lines in a.
+	MatchRateAfter  float64          `json:"matchRateAfter"`  // Ratio of matched lines to processed lines in b.
+	NewErrors       []ErrorSignature `json:"newErrors"`       // Signatures of unmatched lines that appear only in b.
+	ResolvedErrors  []ErrorSignature `json:"resolvedErrors"`  // Signatures of unmatched lines that appear only in a.
+}
+
+// ErrorSignature groups unmatched lines that differ only in variable parts such as numbers and IDs.
+type ErrorSignature struct {
+	Signature string `json:"signature"` // Unmatched line with numbers and hexadecimal IDs masked.
+	Count     int    `json:"count"`     // Number of unmatched lines with the signature.
+	Example   string `json:"example"`   // First unmatched line with the signature.
+}
+
+// MatchRateDelta returns the change of the match rate from a to b.
+func (d ResultDiff) MatchRateDelta() float64 {
+	return d.MatchRateAfter - d.MatchRateBefore
+}
+
+// DiffResults compares two parsing results and reports the changes in match rate, error signatures
+// and counts. It enables regression checks when patterns or upstream log formats change between versions.
+// Note that only the errors kept in the results are compared.
+func DiffResults(a, b *Result) ResultDiff {
+	if a == nil {
+		a = &Result{}
+	}
+	if b == nil {
+		b = &Result{}
+	}
+	sa := errorSignatures(a.Errors)
+	sb := errorSignatures(b.Errors)
+	return ResultDiff{
+		Total:           b.Total - a.Total,
+		Matched:         b.Matched - a.Matched,
+		Unmatched:       b.Unmatched - a.Unmatched,
+		Excluded:        b.Excluded - a.Excluded,
+		Skipped:         b.Skipped - a.Skipped,
+		MatchRateBefore: matchRate(a),
+		MatchRateAfter:  matchRate(b),
+		NewErrors:       subtractSignatures(sb, sa),
+		ResolvedErrors:  subtractSignatures(sa, sb),
+	}
+}
+
+// matchRate returns the ratio of matched lines to processed lines, excluding skipped lines.
+func matchRate(r *Result) float64 {
+	n := r.Total - r.Skipped
+	if n <= 0 {
+		return 0
+	}
+	return float64(r.Matched) / float64(n)
+}
+
+// errorSignatures groups the errors by signature.
+func errorSignatures(errs []Errors) map[string]*ErrorSignature {
+	m := make(map[string]*ErrorSignature)
+	for _, e := range errs {
+		sig := errorSignature(e.Line)
+		if s, ok := m[sig]; ok {
+			s.Count++
+			continue
+		}
+		m[sig] = &ErrorSignature{Signature: sig, Count: 1, Example: e.Line}
+	}
+	return m
+}
+
+// errorSignature masks hexadecimal IDs and numbers in the line.
+func errorSignature(line string) string {
+	line = signatureHex.ReplaceAllString(line, "<hex>")
+	return signatureNumber.ReplaceAllString(line, "<n>")
+}
+
+// subtractSignatures returns the signatures in x that are not in y, sorted by count in descending order.
+func subtractSignatures(x, y map[string]*ErrorSignature) []ErrorSignature {
+	s := make([]ErrorSignature, 0)
+	for sig, e := range x {
+		if _, ok := y[sig]; !ok {
+			s = append(s, *e)
+		}
+	}
+	sort.Slice(s, func(i, j int) bool {
+		if s[i].Count != s[j].Count {
+			return s[i].Count > s[j].Count
+		}
+		return s[i].Signature < s[j].Signature
+	})
+	return s
+}
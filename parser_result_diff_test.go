@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffResults(t *testing.T) {
+	type args struct {
+		a *Result
+		b *Result
+	}
+	tests := []struct {
+		name              string
+		args              args
+		want              ResultDiff
+		wantMatchRateDiff float64
+	}{
+		{
+			name: "regression",
+			args: args{
+				a: &Result{
+					Total:   10,
+					Matched: 9,
+					Errors: []Errors{
+						{LineNumber: 3, Line: "broken line 1"},
+					},
+				},
+				b: &Result{
+					Total:     10,
+					Matched:   6,
+					Unmatched: 3,
+					Errors: []Errors{
+						{LineNumber: 2, Line: "new format 200 deadbeef01"},
+						{LineNumber: 5, Line: "new format 404 deadbeef02"},
+						{LineNumber: 7, Line: "unknown"},
+					},
+				},
+			},
+			want: ResultDiff{
+				Matched:         -3,
+				Unmatched:       3,
+				MatchRateBefore: 0.9,
+				MatchRateAfter:  0.6,
+				NewErrors: []ErrorSignature{
+					{Signature: "new format <n> <hex>", Count: 2, Example: "new format 200 deadbeef01"},
+					{Signature: "unknown", Count: 1, Example: "unknown"},
+				},
+				ResolvedErrors: []ErrorSignature{
+					{Signature: "broken line <n>", Count: 1, Example: "broken line 1"},
+				},
+			},
+			wantMatchRateDiff: -0.3,
+		},
+		{
+			name: "nil",
+			args: args{
+				a: nil,
+				b: &Result{Total: 4, Matched: 2, Skipped: 2},
+			},
+			want: ResultDiff{
+				Total:          4,
+				Matched:        2,
+				Skipped:        2,
+				MatchRateAfter: 1,
+				NewErrors:      []ErrorSignature{},
+				ResolvedErrors: []ErrorSignature{},
+			},
+			wantMatchRateDiff: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffResults(tt.args.a, tt.args.b)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%#v\nwant:\n%#v\n", got, tt.want)
+			}
+			if d := got.MatchRateDelta() - tt.wantMatchRateDiff; d > 1e-9 || d < -1e-9 {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.MatchRateDelta(), tt.wantMatchRateDiff)
+			}
+		})
+	}
+}
@@ -20,6 +20,8 @@ func TestResult_String(t *testing.T) {
 		Source      string
 		ZipEntries  []string
 		Errors      []Errors
+		Cancelled   bool
+		Abandoned   []Abandoned
 		inputType   inputType
 	}
 	tests := []struct {
@@ -85,6 +87,60 @@ func TestResult_String(t *testing.T) {
 				"Excluded  : Number of log line that did not extract by filter expressions\n" +
 				"Skipped   : Number of log line that skipped by line number\n",
 		},
+		{
+			name: "cancelled",
+			fields: fields{
+				Total:       3,
+				Matched:     3,
+				ElapsedTime: time.Hour,
+				Errors:      []Errors{},
+				Cancelled:   true,
+				inputType:   inputTypeString,
+			},
+			want: "\n" +
+				"/* SUMMARY */" +
+				"\n\n" +
+				"+-------+---------+-----------+----------+---------+-------------+-----------+\n" +
+				"| Total | Matched | Unmatched | Excluded | Skipped | ElapsedTime | Cancelled |\n" +
+				"+-------+---------+-----------+----------+---------+-------------+-----------+\n" +
+				"|     3 |       3 |         0 |        0 |       0 | 1h0m0s      | true      |\n" +
+				"+-------+---------+-----------+----------+---------+-------------+-----------+\n" +
+				"\n" +
+				"Total     : Total number of log line processed\n" +
+				"Matched   : Number of log line that successfully matched pattern\n" +
+				"Unmatched : Number of log line that did not match any pattern\n" +
+				"Excluded  : Number of log line that did not extract by filter expressions\n" +
+				"Skipped   : Number of log line that skipped by line number\n" +
+				"Cancelled : Parsing was cancelled, and Total shows the number of log line processed until then\n",
+		},
+		{
+			name: "abandoned",
+			fields: fields{
+				Total:       3,
+				Matched:     3,
+				ElapsedTime: time.Hour,
+				Source:      "test.zip",
+				ZipEntries:  []string{"a.log"},
+				Errors:      []Errors{},
+				Abandoned:   []Abandoned{{Entry: "a.log", Reason: "timed out after 1h0m0s"}},
+				inputType:   inputTypeZip,
+			},
+			want: "\n" +
+				"/* SUMMARY */" +
+				"\n\n" +
+				"+-------+---------+-----------+----------+---------+-------------+----------+------------+\n" +
+				"| Total | Matched | Unmatched | Excluded | Skipped | ElapsedTime | Source   | ZipEntries |\n" +
+				"+-------+---------+-----------+----------+---------+-------------+----------+------------+\n" +
+				"|     3 |       3 |         0 |        0 |       0 | 1h0m0s      | test.zip | a.log      |\n" +
+				"+-------+---------+-----------+----------+---------+-------------+----------+------------+\n" +
+				"\n" +
+				"Total     : Total number of log line processed\n" +
+				"Matched   : Number of log line that successfully matched pattern\n" +
+				"Unmatched : Number of log line that did not match any pattern\n" +
+				"Excluded  : Number of log line that did not extract by filter expressions\n" +
+				"Skipped   : Number of log line that skipped by line number\n" +
+				"Abandoned : a.log (timed out after 1h0m0s)\n",
+		},
 		{
 			name: "all",
 			fields: fields{
@@ -312,6 +368,8 @@ func TestResult_String(t *testing.T) {
 				Source:      tt.fields.Source,
 				ZipEntries:  tt.fields.ZipEntries,
 				Errors:      tt.fields.Errors,
+				Cancelled:   tt.fields.Cancelled,
+				Abandoned:   tt.fields.Abandoned,
 				inputType:   tt.fields.inputType,
 			}
 			if diff := cmp.Diff(r.String(), tt.want); diff != "" {
@@ -0,0 +1,139 @@
+// Package parsermock provides a mock implementation of parser.Parser, so that applications can unit-test
+// code depending on this module without real log files.
+package parsermock
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	parser "github.com/nekrassov01/access-log-parser"
+)
+
+var _ parser.Parser = (*Parser)(nil)
+
+// Call records a method call to the mock.
+type Call struct {
+	Method string // Name of the called method.
+	Args   []any  // Arguments of the call. An io.Reader is recorded as the content read from it.
+}
+
+// Parser is a mock of parser.Parser. Each method calls the function of the corresponding field if set, and
+// otherwise returns the Result and Err fields. All calls are recorded and can be inspected with Calls.
+type Parser struct {
+	Result                    *parser.Result                                                    // result returned by methods whose function is not set
+	Err                       error                                                             // error returned by methods whose function is not set
+	ParseFunc                 func(reader io.Reader) (*parser.Result, error)                    // implementation of Parse
+	ParseStringFunc           func(s string) (*parser.Result, error)                            // implementation of ParseString
+	ParseFileFunc             func(filePath string) (*parser.Result, error)                     // implementation of ParseFile
+	ParseGzipFunc             func(gzipPath string) (*parser.Result, error)                     // implementation of ParseGzip
+	ParseZipEntriesFunc       func(zipPath, globPattern string) (*parser.Result, error)         // implementation of ParseZipEntries
+	ParseTimeRangeIndexedFunc func(filePath string, from, to time.Time) (*parser.Result, error) // implementation of ParseTimeRangeIndexed
+
+	mu          sync.Mutex
+	calls       []Call
+	lineHandler parser.LineHandler
+}
+
+// New returns a mock whose methods return r and err.
+func New(r *parser.Result, err error) *Parser {
+	return &Parser{Result: r, Err: err}
+}
+
+// Parse records the call with the content read from reader, and calls ParseFunc with it.
+func (m *Parser) Parse(reader io.Reader) (*parser.Result, error) {
+	b, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	m.record("Parse", string(b))
+	if m.ParseFunc != nil {
+		return m.ParseFunc(strings.NewReader(string(b)))
+	}
+	return m.result()
+}
+
+// ParseString records the call and calls ParseStringFunc.
+func (m *Parser) ParseString(s string) (*parser.Result, error) {
+	m.record("ParseString", s)
+	if m.ParseStringFunc != nil {
+		return m.ParseStringFunc(s)
+	}
+	return m.result()
+}
+
+// ParseFile records the call and calls ParseFileFunc.
+func (m *Parser) ParseFile(filePath string) (*parser.Result, error) {
+	m.record("ParseFile", filePath)
+	if m.ParseFileFunc != nil {
+		return m.ParseFileFunc(filePath)
+	}
+	return m.result()
+}
+
+// ParseGzip records the call and calls ParseGzipFunc.
+func (m *Parser) ParseGzip(gzipPath string) (*parser.Result, error) {
+	m.record("ParseGzip", gzipPath)
+	if m.ParseGzipFunc != nil {
+		return m.ParseGzipFunc(gzipPath)
+	}
+	return m.result()
+}
+
+// ParseZipEntries records the call and calls ParseZipEntriesFunc.
+func (m *Parser) ParseZipEntries(zipPath, globPattern string) (*parser.Result, error) {
+	m.record("ParseZipEntries", zipPath, globPattern)
+	if m.ParseZipEntriesFunc != nil {
+		return m.ParseZipEntriesFunc(zipPath, globPattern)
+	}
+	return m.result()
+}
+
+// ParseTimeRangeIndexed records the call and calls ParseTimeRangeIndexedFunc.
+func (m *Parser) ParseTimeRangeIndexed(filePath string, from, to time.Time) (*parser.Result, error) {
+	m.record("ParseTimeRangeIndexed", filePath, from, to)
+	if m.ParseTimeRangeIndexedFunc != nil {
+		return m.ParseTimeRangeIndexedFunc(filePath, from, to)
+	}
+	return m.result()
+}
+
+// SetLineHandler records the call and keeps the handler, which can be retrieved with LineHandler.
+func (m *Parser) SetLineHandler(handler parser.LineHandler) {
+	m.record("SetLineHandler", handler)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lineHandler = handler
+}
+
+// LineHandler returns the handler most recently set with SetLineHandler.
+func (m *Parser) LineHandler() parser.LineHandler {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lineHandler
+}
+
+// Calls returns the recorded calls in order.
+func (m *Parser) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	calls := make([]Call, len(m.calls))
+	copy(calls, m.calls)
+	return calls
+}
+
+// record appends a call to the recorded calls.
+func (m *Parser) record(method string, args ...any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, Call{Method: method, Args: args})
+}
+
+// result returns the Result and Err fields, with an empty result if neither is set.
+func (m *Parser) result() (*parser.Result, error) {
+	if m.Result == nil && m.Err == nil {
+		return &parser.Result{Errors: []parser.Errors{}}, nil
+	}
+	return m.Result, m.Err
+}
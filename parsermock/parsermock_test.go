@@ -0,0 +1,97 @@
+package parsermock
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	parser "github.com/nekrassov01/access-log-parser"
+)
+
+// countLines is an example of application code depending on parser.Parser.
+func countLines(p parser.Parser, paths []string) (int, error) {
+	total := 0
+	for _, path := range paths {
+		r, err := p.ParseFile(path)
+		if err != nil {
+			return total, err
+		}
+		total += r.Matched
+	}
+	return total, nil
+}
+
+func TestParser(t *testing.T) {
+	m := New(&parser.Result{Matched: 3}, nil)
+	got, err := countLines(m, []string{"a.log", "b.log"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 6 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, 6)
+	}
+	want := []Call{
+		{Method: "ParseFile", Args: []any{"a.log"}},
+		{Method: "ParseFile", Args: []any{"b.log"}},
+	}
+	if !reflect.DeepEqual(m.Calls(), want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", m.Calls(), want)
+	}
+}
+
+func TestParser_Func(t *testing.T) {
+	m := &Parser{
+		ParseFileFunc: func(filePath string) (*parser.Result, error) {
+			if filePath == "broken.log" {
+				return nil, errors.New("broken")
+			}
+			return &parser.Result{Matched: 1}, nil
+		},
+		ParseFunc: func(reader io.Reader) (*parser.Result, error) {
+			b, err := io.ReadAll(reader)
+			if err != nil {
+				return nil, err
+			}
+			return &parser.Result{Total: strings.Count(string(b), "\n")}, nil
+		},
+	}
+	if _, err := countLines(m, []string{"a.log", "broken.log"}); err == nil {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "error")
+	}
+	r, err := m.Parse(strings.NewReader("a\nb\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Total != 2 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", r.Total, 2)
+	}
+	if calls := m.Calls(); calls[len(calls)-1].Args[0] != "a\nb\n" {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", calls[len(calls)-1].Args[0], "a\nb\n")
+	}
+}
+
+func TestParser_Default(t *testing.T) {
+	m := &Parser{}
+	from, to := time.Unix(0, 0), time.Unix(60, 0)
+	for _, fn := range []func() (*parser.Result, error){
+		func() (*parser.Result, error) { return m.ParseString("a") },
+		func() (*parser.Result, error) { return m.ParseGzip("a.gz") },
+		func() (*parser.Result, error) { return m.ParseZipEntries("a.zip", "*") },
+		func() (*parser.Result, error) { return m.ParseTimeRangeIndexed("a.log", from, to) },
+	} {
+		r, err := fn()
+		if err != nil || r == nil {
+			t.Errorf("\ngot:\n%v, %v\nwant:\n%v\n", r, err, "empty result")
+		}
+	}
+	m.SetLineHandler(parser.TSVLineHandler)
+	if m.LineHandler() == nil {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", nil, "handler")
+	}
+	if got := len(m.Calls()); got != 5 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, 5)
+	}
+}
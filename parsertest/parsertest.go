@@ -0,0 +1,140 @@
+// Package parsertest provides utilities for testing parsers over fixture files against golden NDJSON outputs,
+// so that applications using this module can lock in the parsing behavior in their own CI, and for checking
+// the versioned presets against example lines.
+package parsertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	parser "github.com/nekrassov01/access-log-parser"
+)
+
+// UpdateEnv is the environment variable to rewrite golden files with the current output instead of comparing.
+const UpdateEnv = "PARSERTEST_UPDATE"
+
+// maxDiffs is the maximum number of differences reported by Diff.
+const maxDiffs = 20
+
+// Factory is a function type that creates a parser writing to w.
+// The parser should output NDJSON, for example by using parser.JSONLineHandler.
+type Factory func(w io.Writer) parser.Parser
+
+// Run parses the fixture with a parser created by newParser and returns the output and the result.
+// The fixture is parsed as gzip or zip (all entries) by the extension, or as a plain file otherwise.
+func Run(newParser Factory, fixture string) ([]byte, *parser.Result, error) {
+	b := &bytes.Buffer{}
+	p := newParser(b)
+	var r *parser.Result
+	var err error
+	switch filepath.Ext(fixture) {
+	case ".gz":
+		r, err = p.ParseGzip(fixture)
+	case ".zip":
+		r, err = p.ParseZipEntries(fixture, "*")
+	default:
+		r, err = p.ParseFile(fixture)
+	}
+	return b.Bytes(), r, err
+}
+
+// Golden parses the fixture with a parser created by newParser and compares the output with the golden file.
+// If the environment variable PARSERTEST_UPDATE is set, the golden file is rewritten with the output instead.
+func Golden(t testing.TB, newParser Factory, fixture, golden string) {
+	t.Helper()
+	got, _, err := Run(newParser, fixture)
+	if err != nil {
+		t.Fatalf("cannot parse fixture %s: %v", fixture, err)
+	}
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.WriteFile(filepath.Clean(golden), got, 0o600); err != nil {
+			t.Fatalf("cannot update golden file %s: %v", golden, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(filepath.Clean(golden))
+	if err != nil {
+		t.Fatalf("cannot read golden file %s: %v (set %s=1 to create it)", golden, err, UpdateEnv)
+	}
+	if diff := Diff(got, want); diff != "" {
+		t.Errorf("output of %s differs from %s:\n%s", fixture, golden, diff)
+	}
+}
+
+// Diff compares two NDJSON outputs line by line and returns a human readable report of the differences,
+// or an empty string if they are equal. Lines that are JSON objects are compared field by field.
+func Diff(got, want []byte) string {
+	gs := splitLines(got)
+	ws := splitLines(want)
+	b := &strings.Builder{}
+	n := 0
+	for i := 0; i < max(len(gs), len(ws)); i++ {
+		if n >= maxDiffs {
+			fmt.Fprintf(b, "... more differences omitted\n")
+			break
+		}
+		switch {
+		case i >= len(gs):
+			fmt.Fprintf(b, "line %d: missing\n  want: %s\n", i+1, ws[i])
+		case i >= len(ws):
+			fmt.Fprintf(b, "line %d: unexpected\n  got:  %s\n", i+1, gs[i])
+		case gs[i] == ws[i]:
+			continue
+		default:
+			diffLine(b, i+1, gs[i], ws[i])
+		}
+		n++
+	}
+	return b.String()
+}
+
+// diffLine writes the differences of a line, field by field if both are JSON objects.
+func diffLine(b *strings.Builder, n int, got, want string) {
+	var g, w map[string]any
+	if json.Unmarshal([]byte(got), &g) != nil || json.Unmarshal([]byte(want), &w) != nil {
+		fmt.Fprintf(b, "line %d:\n  got:  %s\n  want: %s\n", n, got, want)
+		return
+	}
+	keys := make([]string, 0, len(g)+len(w))
+	for k := range g {
+		keys = append(keys, k)
+	}
+	for k := range w {
+		if _, ok := g[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(b, "line %d:\n", n)
+	for _, k := range keys {
+		gv, gok := g[k]
+		wv, wok := w[k]
+		switch {
+		case !gok:
+			fmt.Fprintf(b, "  %s: missing, want %v\n", k, wv)
+		case !wok:
+			fmt.Fprintf(b, "  %s: unexpected %v\n", k, gv)
+		case fmt.Sprint(gv) != fmt.Sprint(wv):
+			fmt.Fprintf(b, "  %s: got %v, want %v\n", k, gv, wv)
+		}
+	}
+	if fmt.Sprint(g) == fmt.Sprint(w) {
+		fmt.Fprintf(b, "  field order or formatting differs\n  got:  %s\n  want: %s\n", got, want)
+	}
+}
+
+// splitLines splits the output into lines without the trailing empty line.
+func splitLines(b []byte) []string {
+	s := strings.TrimSuffix(string(b), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
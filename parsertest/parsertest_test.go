@@ -0,0 +1,123 @@
+package parsertest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	parser "github.com/nekrassov01/access-log-parser"
+)
+
+func newLTSVParser(w io.Writer) parser.Parser {
+	return parser.NewLTSVParser(context.Background(), w, parser.Option{})
+}
+
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+	}{
+		{
+			name:    "file",
+			fixture: filepath.Join("..", "testdata", "sample_ltsv_all_match.log"),
+		},
+		{
+			name:    "gzip",
+			fixture: filepath.Join("..", "testdata", "sample_ltsv_all_match.log.gz"),
+		},
+		{
+			name:    "zip",
+			fixture: filepath.Join("..", "testdata", "sample_ltsv_all_match.log.zip"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Golden(t, newLTSVParser, tt.fixture, filepath.Join("testdata", "sample_ltsv_all_match.ndjson"))
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+		diff string
+	}{
+		{
+			name: "equal",
+			got:  "{\"a\":\"1\"}\n",
+			want: "{\"a\":\"1\"}\n",
+			diff: "",
+		},
+		{
+			name: "field changed",
+			got:  "{\"a\":\"1\",\"b\":\"3\",\"d\":\"4\"}\n",
+			want: "{\"a\":\"1\",\"b\":\"2\",\"c\":\"3\"}\n",
+			diff: "line 1:\n  b: got 3, want 2\n  c: missing, want 3\n  d: unexpected 4\n",
+		},
+		{
+			name: "field order",
+			got:  "{\"b\":\"2\",\"a\":\"1\"}\n",
+			want: "{\"a\":\"1\",\"b\":\"2\"}\n",
+			diff: "line 1:\n  field order or formatting differs\n  got:  {\"b\":\"2\",\"a\":\"1\"}\n  want: {\"a\":\"1\",\"b\":\"2\"}\n",
+		},
+		{
+			name: "not json",
+			got:  "x\n",
+			want: "y\n",
+			diff: "line 1:\n  got:  x\n  want: y\n",
+		},
+		{
+			name: "line count",
+			got:  "x\n",
+			want: "x\ny\n",
+			diff: "line 2: missing\n  want: y\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := Diff([]byte(tt.got), []byte(tt.want)); diff != tt.diff {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", diff, tt.diff)
+			}
+		})
+	}
+}
+
+func TestTestPreset(t *testing.T) {
+	for _, p := range parser.Presets() {
+		t.Run(p.Name, func(t *testing.T) {
+			TestPreset(t, p.Name)
+		})
+	}
+}
+
+// recorder records the errors reported to it instead of failing the test.
+type recorder struct {
+	testing.TB
+	errs []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func TestTestPreset_samples(t *testing.T) {
+	lines, err := Corpus("alb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &recorder{TB: t}
+	TestPreset(r, "alb", lines[0], "not an alb line")
+	if want := []string{`sample 2 does not match any version of preset "alb": not an alb line`}; !reflect.DeepEqual(r.errs, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", r.errs, want)
+	}
+	if _, err := Corpus("unknown"); err == nil {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "no corpus")
+	}
+}
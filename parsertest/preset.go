@@ -0,0 +1,67 @@
+package parsertest
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+	"testing"
+
+	parser "github.com/nekrassov01/access-log-parser"
+)
+
+// corpus holds example lines of every version of the format of each preset, named after the preset.
+//
+//go:embed corpus/*.log
+var corpus embed.FS
+
+// Corpus returns the example lines shipped for the preset registered with the name. Blank lines and
+// comments starting with "#" are skipped.
+func Corpus(preset string) ([]string, error) {
+	b, err := corpus.ReadFile("corpus/" + preset + ".log")
+	if err != nil {
+		return nil, fmt.Errorf("no corpus for preset %q: %w", preset, err)
+	}
+	var lines []string
+	for _, line := range strings.Split(string(b), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// TestPreset checks that the samples are matched by a version of the format of the preset registered with
+// the name. Without samples, the corpus shipped for the preset is checked instead, which must also cover
+// every version of the format, so that a preset gaining a version cannot go without examples of it.
+func TestPreset(t testing.TB, preset string, samples ...string) {
+	t.Helper()
+	p, ok := parser.LookupPreset(preset)
+	if !ok {
+		t.Fatalf("preset %q is not registered", preset)
+	}
+	lines := samples
+	if len(samples) == 0 {
+		var err error
+		if lines, err = Corpus(preset); err != nil {
+			t.Fatal(err)
+		}
+	}
+	covered := make(map[int]bool, len(p.Versions))
+	for i, line := range lines {
+		v, ok := p.Match(line)
+		if !ok {
+			t.Errorf("sample %d does not match any version of preset %q: %s", i+1, preset, line)
+			continue
+		}
+		covered[v.Version] = true
+	}
+	if len(samples) > 0 {
+		return
+	}
+	for _, v := range p.Versions {
+		if !covered[v.Version] {
+			t.Errorf("version %d of preset %q is not covered by the corpus", v.Version, preset)
+		}
+	}
+}
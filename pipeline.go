@@ -0,0 +1,418 @@
+package parser
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+// errCancelled is returned by the stages of a pipeline when the context is cancelled while waiting for the rate
+// limit, so that the partial result is drained instead of aborted.
+var errCancelled = errors.New("cancelled while waiting")
+
+// pipeline is the state of a parse shared by the stages each line goes through: the gates before decoding,
+// the transforms of the decoded records, and the emission of the output lines. A new stage is added as a step
+// of gate, transform or emit rather than to the loop of parser.
+type pipeline struct {
+	ctx           context.Context
+	output        io.Writer
+	opt           Option
+	r             *Result
+	start         time.Time
+	turn          <-chan struct{}
+	basePatterns  []*regexp.Regexp
+	baseDecoder   lineDecoder
+	patterns      []*regexp.Regexp
+	decoder       lineDecoder
+	skip          map[int]struct{}
+	keywords      []string
+	filters       []string
+	lineFilters   []lineFilter
+	numberFilters []lineFilter
+	emitters      map[string]lineFilter
+	loc           *time.Location
+	limiter       *rateLimiter
+	snap          *snapshotter
+	locate        locateFunc
+	agg           *aggregator
+	ownAgg        bool
+	mpref         string
+	upref         string
+	isFirst       bool
+}
+
+// scannedLine is a line read by the parser, with its position in the input and the byte ranges of its fields.
+type scannedLine struct {
+	no     int
+	offset int64
+	raw    string
+	spans  map[string][2]int
+	tr     *trace
+}
+
+// newPipeline prepares the stages of a parse from the option.
+func newPipeline(ctx context.Context, output io.Writer, patterns []*regexp.Regexp, decoder lineDecoder, opt Option, r *Result, start time.Time, turn <-chan struct{}) (*pipeline, error) {
+	p := &pipeline{
+		ctx:          ctx,
+		output:       output,
+		opt:          opt,
+		r:            r,
+		start:        start,
+		turn:         turn,
+		basePatterns: patterns,
+		baseDecoder:  decoder,
+		skip:         applySkipLines(opt.SkipLines),
+		limiter:      newRateLimiter(opt.RateLimit),
+		mpref:        "[ PROCESSED ] ",
+		upref:        "[ UNMATCHED ] ",
+		isFirst:      true,
+	}
+	if isatty.IsTerminal(os.Stdout.Fd()) {
+		p.mpref = "\033[1;32m" + p.mpref + "\033[0m"
+		p.upref = "\033[1;31m" + p.upref + "\033[0m"
+	}
+	var err error
+	if r.Transforms, err = p.prepare(); err != nil {
+		return nil, err
+	}
+	if p.lineFilters, err = getLineFilters(opt.LineFilters); err != nil {
+		return nil, err
+	}
+	if p.emitters, err = getFilter(filterLabels(opt.EmitWhen), opt.EmitWhen); err != nil {
+		return nil, err
+	}
+	p.agg = opt.aggregator
+	if p.agg == nil && (len(opt.GroupBy) > 0 || len(opt.Aggregates) > 0) {
+		if p.agg, err = newAggregator(opt.GroupBy, opt.Aggregates); err != nil {
+			return nil, err
+		}
+		p.agg.meter = opt.meter
+		p.ownAgg = true
+	}
+	for _, rule := range opt.Routes {
+		if err := rule.validate(); err != nil {
+			return nil, err
+		}
+	}
+	p.locate = opt.locate
+	if p.locate == nil && len(patterns) > 0 {
+		p.locate = regexLocate
+	}
+	p.snap = newSnapshotter(start, opt)
+	return p, nil
+}
+
+// prepare derives the patterns, decoder, keywords and filters used for matching from the current option,
+// and returns the transforms applied with them. It is called again when the option is reloaded.
+func (p *pipeline) prepare() ([]Transform, error) {
+	p.patterns, p.decoder = p.basePatterns, p.baseDecoder
+	var numbers []string
+	var err error
+	numbers, p.filters = splitLineNumberFilters(p.opt.Filters, p.opt.LineNumber)
+	p.numberFilters, err = getLineNumberFilters(numbers)
+	if err == nil {
+		p.loc, err = loadTimezone(p.opt.OutputTimezone)
+	}
+	if len(p.opt.Types) > 0 {
+		switch {
+		case isLineHandler(p.opt.LineHandler, JSONLineHandler):
+			p.opt.LineHandler = NewJSONLineHandler(JSONOption{Types: p.opt.Types, Location: p.loc})
+		case isLineHandler(p.opt.LineHandler, PrettyJSONLineHandler):
+			p.opt.LineHandler = NewJSONLineHandler(JSONOption{Pretty: true, Types: p.opt.Types, Location: p.loc})
+		}
+	}
+	if len(p.opt.Labels) > 0 && p.opt.derived == nil && len(p.opt.Enrichers) == 0 {
+		if p.opt.LazyDecode {
+			p.patterns = prefixPatterns(p.patterns, neededLabels(p.opt))
+		}
+		if p.opt.project != nil {
+			p.decoder = p.opt.project(neededLabels(p.opt))
+		}
+	}
+	if p.opt.MergePatterns && len(p.patterns) > 1 {
+		if guard := sharedPrefix(p.patterns); guard != nil {
+			p.decoder = guardedLineDecoder(p.decoder, guard)
+		}
+	}
+	p.keywords = nil
+	if p.opt.Pushdown && !p.opt.UnmatchLines && p.opt.Index == nil {
+		p.keywords = pushdownKeywords(p.filters, normalizedDerived(p.opt.derived, p.opt.Normalize))
+	}
+	return auditTrail(patternStrings(p.basePatterns), p.keywords, p.opt), err
+}
+
+// more reports whether lines are still to be read after n lines, under Head and Limit.
+func (p *pipeline) more(n int) bool {
+	return (p.opt.Head == 0 || n < p.opt.Head) && (p.opt.Limit == 0 || p.r.Matched < p.opt.Limit)
+}
+
+// snapshot writes the snapshot of the result with n lines read to the output, if one is due. Errors of the hook
+// are not fatal, as with heartbeats.
+func (p *pipeline) snapshot(n int) error {
+	line, err := p.snap.take(p.r, n)
+	if err != nil || line == "" {
+		return nil
+	}
+	_, err = fmt.Fprintln(p.output, line)
+	return err
+}
+
+// reload applies the configuration change requested to the Reloader, if any, from the line numbered no.
+func (p *pipeline) reload(no int) error {
+	req := p.opt.Reloader.take()
+	if req == nil {
+		return nil
+	}
+	p.opt, p.basePatterns = req.apply(p.opt, p.basePatterns)
+	transforms, err := p.prepare()
+	p.r.Reloads = append(p.r.Reloads, Reload{LineNumber: no, Transforms: transforms})
+	return err
+}
+
+// gate applies the stages before decoding to the line: skipped lines, line number filters, pushdown keywords
+// and line filters. It sets the text of the line and reports whether the line is to be decoded.
+func (p *pipeline) gate(l *scannedLine, scanner *bufio.Scanner) (bool, error) {
+	if skipLine(l.no, p.skip, p.opt) {
+		l.tr.emit(TraceSkip)
+		p.r.Skipped++
+		return false, nil
+	}
+	if ok, err := applyLineFilters(strconv.Itoa(l.no), p.numberFilters); err != nil || !ok {
+		return false, p.exclude(l, traceLineNumber, err)
+	}
+	l.raw = scanner.Text()
+	p.r.MaxLineLen = max(p.r.MaxLineLen, len(l.raw))
+	if !containsAll(l.raw, p.keywords) {
+		return false, p.exclude(l, tracePushdown, nil)
+	}
+	if ok, err := applyLineFilters(l.raw, p.lineFilters); err != nil || !ok {
+		return false, p.exclude(l, traceLineFilter, err)
+	}
+	return true, nil
+}
+
+// exclude counts the line as excluded by the gate, unless the gate failed with err, which is returned.
+func (p *pipeline) exclude(l *scannedLine, reason string, err error) error {
+	if err != nil {
+		return err
+	}
+	l.tr.exclude(reason, nil, nil)
+	p.r.Excluded++
+	return nil
+}
+
+// process decodes the line and emits its records, counting the line as matched, unmatched or excluded.
+func (p *pipeline) process(l *scannedLine) error {
+	ls, vs, ok, err := p.decode(l)
+	if err != nil || !ok {
+		return err
+	}
+	l.tr.record(TraceDecode, ls, vs)
+	if p.opt.FieldPositions && p.locate != nil {
+		l.spans = p.locate(l.raw, p.basePatterns)
+	}
+	if p.opt.Index != nil {
+		p.opt.Index.add(l.no, l.offset, ls, vs)
+	}
+	records := [][]string{vs}
+	if p.opt.explode != nil {
+		records = p.opt.explode(ls, vs)
+	}
+	matched := false
+	for _, vs := range records {
+		ls, vs, ok, err := p.transform(l, ls, vs)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if err := p.emit(l, ls, vs); err != nil {
+			return err
+		}
+		matched = true
+	}
+	if !matched {
+		p.r.Excluded++
+		return nil
+	}
+	p.r.Matched++
+	return nil
+}
+
+// decode decodes the line into labels and values. Unmatched lines are written as is with UnmatchLines and kept
+// in the errors of the result, and false is reported for them.
+func (p *pipeline) decode(l *scannedLine) ([]string, []string, bool, error) {
+	ls, vs, err := p.decoder(l.raw, p.patterns)
+	if err == nil {
+		return ls, vs, true, nil
+	}
+	if strings.Contains(err.Error(), "no pattern provided") {
+		return nil, nil, false, err
+	}
+	if p.opt.UnmatchLines {
+		line := l.raw
+		if p.opt.Prefix {
+			line = p.upref + line
+		}
+		if err := p.write(line); err != nil {
+			return nil, nil, false, err
+		}
+	}
+	l.tr.unmatch(err)
+	p.opt.meter.keepError(p.r, Errors{LineNumber: l.no, Line: l.raw}, p.opt.MaxErrors)
+	p.r.Unmatched++
+	return nil, nil, false, nil
+}
+
+// transform applies the stages to a decoded record: normalization, filters, the time range, enrichers,
+// post-filters and the seen filter. It returns the enriched record, reporting false if it is excluded.
+func (p *pipeline) transform(l *scannedLine, ls, vs []string) ([]string, []string, bool, error) {
+	vs = normalizeFields(ls, vs, p.opt.Normalize)
+	if ok, err := applyFilter(ls, vs, p.filters); err != nil || !ok {
+		return p.excludeRecord(l, traceFilter, ls, vs, err)
+	}
+	if p.opt.window != nil && !p.opt.window.contains(ls, vs) {
+		return p.excludeRecord(l, traceWindow, ls, vs, nil)
+	}
+	for _, enrich := range p.opt.Enrichers {
+		rec, err := enrich(Record{Labels: ls, Values: vs})
+		if err != nil {
+			return nil, nil, false, err
+		}
+		ls, vs = rec.Labels, rec.Values
+	}
+	if len(p.opt.Enrichers) > 0 {
+		l.tr.record(TraceEnrich, ls, vs)
+	}
+	if ok, err := applyFilter(ls, vs, p.opt.PostFilters); err != nil || !ok {
+		return p.excludeRecord(l, tracePostFilter, ls, vs, err)
+	}
+	if p.opt.SeenFilter != nil && p.opt.SeenFilter.seen(ls, vs) {
+		return p.excludeRecord(l, traceSeen, ls, vs, nil)
+	}
+	return ls, vs, true, nil
+}
+
+// excludeRecord reports the record as excluded by the transform, unless the transform failed with err.
+func (p *pipeline) excludeRecord(l *scannedLine, reason string, ls, vs []string, err error) ([]string, []string, bool, error) {
+	if err == nil {
+		l.tr.exclude(reason, ls, vs)
+	}
+	return nil, nil, false, err
+}
+
+// emit writes the record to the output and to the matching routes, or adds it to the groups with GroupBy and
+// Aggregates instead.
+func (p *pipeline) emit(l *scannedLine, ls, vs []string) error {
+	if p.agg != nil {
+		l.tr.record(TraceAggregate, ls, vs)
+		p.agg.observe(ls, vs)
+		return nil
+	}
+	routes, err := matchRoutes(p.opt.Routes, ls, vs)
+	if err != nil {
+		return err
+	}
+	if ls, vs, err = p.format(l, ls, vs); err != nil {
+		return err
+	}
+	if p.isFirst && p.opt.CheckLabels {
+		p.r.labels = slices.Clone(ls)
+	}
+	first := p.isFirst
+	if p.opt.HeaderOnce {
+		if ls, vs, err = p.opt.header.align(p.ctx, p.turn, ls, vs, p.opt.LineHandler, p.opt.ReconcileLabels); err != nil {
+			return err
+		}
+		first = false
+	}
+	line, err := p.opt.LineHandler(ls, vs, first)
+	if err != nil {
+		return err
+	}
+	for _, rule := range routes {
+		if err := rule.write(line); err != nil {
+			return err
+		}
+	}
+	if p.opt.Prefix {
+		line = applyPrefix(line, p.mpref)
+	}
+	if err := p.write(line); err != nil {
+		return err
+	}
+	l.tr.write(ls, vs, line)
+	p.isFirst = false
+	return nil
+}
+
+// format shapes the fields of the record for output: label selection, conditional fields, number and time
+// formats, converters, and the original line, field positions, byte offset and line number added as fields.
+func (p *pipeline) format(l *scannedLine, ls, vs []string) ([]string, []string, error) {
+	var keepRaw bool
+	var err error
+	if p.opt.RawField != "" {
+		if keepRaw, err = applyFilter(ls, vs, p.opt.RawFilters); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(p.opt.Labels) > 0 {
+		ls, vs = selectLabels(p.opt.Labels, ls, vs)
+	}
+	if len(p.emitters) > 0 {
+		ls, vs = emitFields(ls, vs, p.emitters)
+	}
+	if len(p.opt.NumberFormats) > 0 {
+		vs = formatNumbers(ls, vs, p.opt.NumberFormats)
+	}
+	if len(p.opt.TimeFields) > 0 {
+		vs = formatTimes(ls, vs, p.opt.TimeFields, p.loc)
+	}
+	if len(p.opt.Converters) > 0 {
+		if vs, err = convertFields(ls, vs, p.opt.Converters); err != nil {
+			return nil, nil, err
+		}
+	}
+	if keepRaw {
+		ls, vs = append(ls[:len(ls):len(ls)], p.opt.RawField), append(vs[:len(vs):len(vs)], l.raw)
+	}
+	if l.spans != nil {
+		ls, vs = addFieldPositions(ls, vs, l.spans)
+	}
+	if p.opt.ByteOffset {
+		ls, vs = addByteOffset(ls, vs, l.offset)
+	}
+	if p.opt.LineNumber {
+		ls, vs = addLineNumber(ls, vs, l.no)
+	}
+	return ls, vs, nil
+}
+
+// write writes the line to the output at the rate limit, returning errCancelled if the context is cancelled
+// while waiting.
+func (p *pipeline) write(line string) error {
+	if err := p.limiter.wait(p.ctx); err != nil {
+		return errCancelled
+	}
+	_, err := fmt.Fprintln(p.output, line)
+	return err
+}
+
+// stop finalizes the partial result when a stage fails after n lines, draining it if the context was cancelled.
+func (p *pipeline) stop(n int, err error) (*Result, error) {
+	if errors.Is(err, errCancelled) {
+		return drain(p.ctx, p.r, p.output, n, p.start)
+	}
+	return abort(p.r, p.output, n, p.start, err)
+}
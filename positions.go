@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// positionsLabel is the label of the field added with Option.FieldPositions.
+const positionsLabel = "positions"
+
+// locateFunc is a function type that returns the byte ranges of the fields of a line by label.
+type locateFunc func(line string, patterns []*regexp.Regexp) map[string][2]int
+
+// FieldPosition is the byte range of a field within the original line, as written in the field "positions"
+// with Option.FieldPositions, so that user interfaces can highlight the fields in the original text.
+type FieldPosition struct {
+	Label string // label of the field
+	Start int    // offset of the first byte of the value in the line
+	End   int    // offset right after the last byte of the value in the line
+}
+
+// regexLocate returns the byte ranges of the groups of the first pattern the line matches, as
+// regexLineDecoder decodes them. Groups that did not participate in the match are omitted.
+func regexLocate(line string, patterns []*regexp.Regexp) map[string][2]int {
+	for _, pattern := range patterns {
+		loc := pattern.FindStringSubmatchIndex(line)
+		if loc == nil {
+			continue
+		}
+		m := make(map[string][2]int, len(loc)/2-1)
+		for i, name := range pattern.SubexpNames()[1:] {
+			if start, end := loc[2*i+2], loc[2*i+3]; name != "" && start >= 0 {
+				if _, ok := m[name]; !ok {
+					m[name] = [2]int{start, end}
+				}
+			}
+		}
+		return m
+	}
+	return nil
+}
+
+// ltsvLocate returns the byte ranges of the values of the fields of an LTSV line.
+func ltsvLocate(line string, _ []*regexp.Regexp) map[string][2]int {
+	m := make(map[string][2]int)
+	for pos := 0; pos <= len(line); {
+		end := strings.IndexByte(line[pos:], '\t')
+		if end < 0 {
+			end = len(line)
+		} else {
+			end += pos
+		}
+		if j := strings.IndexByte(line[pos:end], ':'); j >= 0 {
+			if _, ok := m[line[pos:pos+j]]; !ok {
+				m[line[pos:pos+j]] = [2]int{pos + j + 1, end}
+			}
+		}
+		pos = end + 1
+	}
+	return m
+}
+
+// addFieldPositions adds the byte ranges of the fields with one in spans to the end of the labels and values,
+// formatted as "label:start-end" separated by commas. Fields added after decoding, such as by enrichers, have
+// no byte range and are omitted, while converted fields keep the range of the value they were converted from.
+func addFieldPositions(labels []string, values []string, spans map[string][2]int) ([]string, []string) {
+	var b strings.Builder
+	for _, label := range labels {
+		span, ok := spans[label]
+		if !ok {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(label)
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(span[0]))
+		b.WriteByte('-')
+		b.WriteString(strconv.Itoa(span[1]))
+	}
+	return append(labels[:len(labels):len(labels)], positionsLabel), append(values[:len(values):len(values)], b.String())
+}
+
+// ParseFieldPositions parses the value of the field "positions" added with Option.FieldPositions.
+func ParseFieldPositions(v string) ([]FieldPosition, error) {
+	if v == "" {
+		return nil, nil
+	}
+	entries := strings.Split(v, ",")
+	ret := make([]FieldPosition, 0, len(entries))
+	for _, entry := range entries {
+		i := strings.LastIndexByte(entry, ':')
+		start, end, ok := strings.Cut(entry[i+1:], "-")
+		if i < 0 || !ok {
+			return nil, fmt.Errorf("%s: invalid field position: %q", parseError, entry)
+		}
+		s, err := strconv.Atoi(start)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid field position: %q: %w", parseError, entry, err)
+		}
+		e, err := strconv.Atoi(end)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid field position: %q: %w", parseError, entry, err)
+		}
+		ret = append(ret, FieldPosition{Label: entry[:i], Start: s, End: e})
+	}
+	return ret, nil
+}
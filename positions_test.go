@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestOption_FieldPositions(t *testing.T) {
+	tests := []struct {
+		name   string
+		parser func(opt Option) Parser
+		line   string
+		opt    Option
+		want   []FieldPosition
+	}{
+		{
+			name: "regex",
+			parser: func(opt Option) Parser {
+				p := NewRegexParser(context.Background(), io.Discard, opt)
+				if err := p.AddPatterns([]string{`^(?P<method>[A-Z]+) (?P<path>\S+)(?: (?P<query>\?\S+))? (?P<status>\d{3})$`}); err != nil {
+					t.Fatal(err)
+				}
+				return p
+			},
+			line: "GET /a%20b 404",
+			opt:  Option{Converters: map[string]Converter{"path": DecodePercent}},
+			want: []FieldPosition{{Label: "method", Start: 0, End: 3}, {Label: "path", Start: 4, End: 10}, {Label: "status", Start: 11, End: 14}},
+		},
+		{
+			name: "regex with labels",
+			parser: func(opt Option) Parser {
+				p := NewRegexParser(context.Background(), io.Discard, opt)
+				if err := p.AddPatterns([]string{`^(?P<method>[A-Z]+) (?P<path>\S+) (?P<status>\d{3})$`}); err != nil {
+					t.Fatal(err)
+				}
+				return p
+			},
+			line: "GET /a 404",
+			opt:  Option{Labels: []string{"status", "method"}, LazyDecode: true, LineNumber: true},
+			want: []FieldPosition{{Label: "method", Start: 0, End: 3}, {Label: "status", Start: 7, End: 10}},
+		},
+		{
+			name:   "ltsv",
+			parser: func(opt Option) Parser { return NewLTSVParser(context.Background(), io.Discard, opt) },
+			line:   "host:192.0.2.1\tempty:\tua:curl/8.4.0",
+			opt:    Option{Enrichers: []Enricher{WithUserAgent()}, Labels: []string{"host", "empty", "ua", "ua_browser"}},
+			want:   []FieldPosition{{Label: "host", Start: 5, End: 14}, {Label: "empty", Start: 21, End: 21}, {Label: "ua", Start: 25, End: 35}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []FieldPosition
+			tt.opt.FieldPositions = true
+			tt.opt.LineHandler = func(labels, values []string, _ bool) (string, error) {
+				if labels[len(labels)-1] != "positions" {
+					t.Fatalf("\ngot:\n%v\nwant:\n%v\n", labels, "positions at the end")
+				}
+				var err error
+				got, err = ParseFieldPositions(values[len(values)-1])
+				return "", err
+			}
+			if _, err := tt.parser(tt.opt).ParseString(tt.line); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFieldPositions(t *testing.T) {
+	tests := []struct {
+		v       string
+		want    []FieldPosition
+		wantErr bool
+	}{
+		{v: "", want: nil},
+		{v: "a:0-3,b.c:4-4", want: []FieldPosition{{Label: "a", Start: 0, End: 3}, {Label: "b.c", Start: 4, End: 4}}},
+		{v: "a:0", wantErr: true},
+		{v: "a0-3", wantErr: true},
+		{v: "a:x-3", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.v, func(t *testing.T) {
+			got, err := ParseFieldPositions(tt.v)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// presetMu guards presetRegistry, which is updated by ApplyPresetManifest.
+var presetMu sync.RWMutex
+
+// presetRegistry holds the versioned formats of the presets, keyed by name.
+var presetRegistry = map[string]*Preset{
+	"s3": newPreset("s3", []PresetVersion{
+		{Pattern: `^(?P<bucket_owner>[!-~]+) (?P<bucket>[!-~]+) (?P<time>\[[^\]]+\]) (?P<remote_ip>[!-~]+) (?P<requester>[!-~]+) (?P<request_id>[!-~]+) (?P<operation>[!-~]+) (?P<key>[!-~]+) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\" (?P<http_status>\d{1,3}) (?P<error_code>[!-~]+) (?P<bytes_sent>[\d\-.]+) (?P<object_size>[\d\-.]+) (?P<total_time>[\d\-.]+) (?P<turn_around_time>[\d\-.]+) "(?P<referer>[^\"]*)" "(?P<user_agent>[^\"]*)" (?P<version_id>[!-~]+)`},
+		{Pattern: `^(?P<bucket_owner>[!-~]+) (?P<bucket>[!-~]+) (?P<time>\[[^\]]+\]) (?P<remote_ip>[!-~]+) (?P<requester>[!-~]+) (?P<request_id>[!-~]+) (?P<operation>[!-~]+) (?P<key>[!-~]+) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\" (?P<http_status>\d{1,3}) (?P<error_code>[!-~]+) (?P<bytes_sent>[\d\-.]+) (?P<object_size>[\d\-.]+) (?P<total_time>[\d\-.]+) (?P<turn_around_time>[\d\-.]+) "(?P<referer>[^\"]*)" "(?P<user_agent>[^\"]*)" (?P<version_id>[!-~]+) (?P<host_id>[!-~]+) (?P<signature_version>[!-~]+) (?P<cipher_suite>[!-~]+) (?P<authentication_type>[!-~]+) (?P<host_header>[!-~]+)`},
+		{Pattern: `^(?P<bucket_owner>[!-~]+) (?P<bucket>[!-~]+) (?P<time>\[[^\]]+\]) (?P<remote_ip>[!-~]+) (?P<requester>[!-~]+) (?P<request_id>[!-~]+) (?P<operation>[!-~]+) (?P<key>[!-~]+) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\" (?P<http_status>\d{1,3}) (?P<error_code>[!-~]+) (?P<bytes_sent>[\d\-.]+) (?P<object_size>[\d\-.]+) (?P<total_time>[\d\-.]+) (?P<turn_around_time>[\d\-.]+) "(?P<referer>[^\"]*)" "(?P<user_agent>[^\"]*)" (?P<version_id>[!-~]+) (?P<host_id>[!-~]+) (?P<signature_version>[!-~]+) (?P<cipher_suite>[!-~]+) (?P<authentication_type>[!-~]+) (?P<host_header>[!-~]+) (?P<tls_version>[!-~]+)`},
+		{DocDate: "2019-12", Pattern: `^(?P<bucket_owner>[!-~]+) (?P<bucket>[!-~]+) (?P<time>\[[^\]]+\]) (?P<remote_ip>[!-~]+) (?P<requester>[!-~]+) (?P<request_id>[!-~]+) (?P<operation>[!-~]+) (?P<key>[!-~]+) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\" (?P<http_status>\d{1,3}) (?P<error_code>[!-~]+) (?P<bytes_sent>[\d\-.]+) (?P<object_size>[\d\-.]+) (?P<total_time>[\d\-.]+) (?P<turn_around_time>[\d\-.]+) "(?P<referer>[^\"]*)" "(?P<user_agent>[^\"]*)" (?P<version_id>[!-~]+) (?P<host_id>[!-~]+) (?P<signature_version>[!-~]+) (?P<cipher_suite>[!-~]+) (?P<authentication_type>[!-~]+) (?P<host_header>[!-~]+) (?P<tls_version>[!-~]+) (?P<access_point_arn>[!-~]+)`},
+		{Pattern: `^(?P<bucket_owner>[!-~]+) (?P<bucket>[!-~]+) (?P<time>\[[^\]]+\]) (?P<remote_ip>[!-~]+) (?P<requester>[!-~]+) (?P<request_id>[!-~]+) (?P<operation>[!-~]+) (?P<key>[!-~]+) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-)\" (?P<http_status>\d{1,3}) (?P<error_code>[!-~]+) (?P<bytes_sent>[\d\-.]+) (?P<object_size>[\d\-.]+) (?P<total_time>[\d\-.]+) (?P<turn_around_time>[\d\-.]+) "(?P<referer>[^\"]*)" "(?P<user_agent>[^\"]*)" (?P<version_id>[!-~]+) (?P<host_id>[!-~]+) (?P<signature_version>[!-~]+) (?P<cipher_suite>[!-~]+) (?P<authentication_type>[!-~]+) (?P<host_header>[!-~]+) (?P<tls_version>[!-~]+) (?P<access_point_arn>[!-~]+) (?P<acl_required>[!-~]+)`},
+	}),
+	"alb": newPreset("alb", []PresetVersion{
+		{Pattern: `^(?P<type>[!-~]+) (?P<time>[!-~]+) (?P<elb>[!-~]+) (?P<client_port>[!-~]+) (?P<target_port>[!-~]+) (?P<request_processing_time>[\d\-.]+) (?P<target_processing_time>[\d\-.]+) (?P<response_processing_time>[\d\-.]+) (?P<elb_status_code>\d{1,3}|-) (?P<target_status_code>\d{1,3}|-) (?P<received_bytes>[\d\-.]+) (?P<sent_bytes>[\d\-.]+) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-) ?\" "(?P<user_agent>[^\"]*)" (?P<ssl_cipher>[!-~]+) (?P<ssl_protocol>[!-~]+) (?P<target_group_arn>[!-~]+) "(?P<trace_id>[^\"]+)"$`},
+		{Pattern: `^(?P<type>[!-~]+) (?P<time>[!-~]+) (?P<elb>[!-~]+) (?P<client_port>[!-~]+) (?P<target_port>[!-~]+) (?P<request_processing_time>[\d\-.]+) (?P<target_processing_time>[\d\-.]+) (?P<response_processing_time>[\d\-.]+) (?P<elb_status_code>\d{1,3}|-) (?P<target_status_code>\d{1,3}|-) (?P<received_bytes>[\d\-.]+) (?P<sent_bytes>[\d\-.]+) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-) ?\" "(?P<user_agent>[^\"]*)" (?P<ssl_cipher>[!-~]+) (?P<ssl_protocol>[!-~]+) (?P<target_group_arn>[!-~]+) "(?P<trace_id>[^\"]+)" "(?P<domain_name>[^\"]+)" "(?P<chosen_cert_arn>[^\"]+)"$`},
+		{Pattern: `^(?P<type>[!-~]+) (?P<time>[!-~]+) (?P<elb>[!-~]+) (?P<client_port>[!-~]+) (?P<target_port>[!-~]+) (?P<request_processing_time>[\d\-.]+) (?P<target_processing_time>[\d\-.]+) (?P<response_processing_time>[\d\-.]+) (?P<elb_status_code>\d{1,3}|-) (?P<target_status_code>\d{1,3}|-) (?P<received_bytes>[\d\-.]+) (?P<sent_bytes>[\d\-.]+) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-) ?\" "(?P<user_agent>[^\"]*)" (?P<ssl_cipher>[!-~]+) (?P<ssl_protocol>[!-~]+) (?P<target_group_arn>[!-~]+) "(?P<trace_id>[^\"]+)" "(?P<domain_name>[^\"]+)" "(?P<chosen_cert_arn>[^\"]+)" (?P<matched_rule_priority>[!-~]+) (?P<request_creation_time>[!-~]+) "(?P<actions_executed>[^\"]+)" "(?P<redirect_url>[^\"]+)" "(?P<error_reason>[^\"]+)"$`},
+		{Pattern: `^(?P<type>[!-~]+) (?P<time>[!-~]+) (?P<elb>[!-~]+) (?P<client_port>[!-~]+) (?P<target_port>[!-~]+) (?P<request_processing_time>[\d\-.]+) (?P<target_processing_time>[\d\-.]+) (?P<response_processing_time>[\d\-.]+) (?P<elb_status_code>\d{1,3}|-) (?P<target_status_code>\d{1,3}|-) (?P<received_bytes>[\d\-.]+) (?P<sent_bytes>[\d\-.]+) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-) ?\" "(?P<user_agent>[^\"]*)" (?P<ssl_cipher>[!-~]+) (?P<ssl_protocol>[!-~]+) (?P<target_group_arn>[!-~]+) "(?P<trace_id>[^\"]+)" "(?P<domain_name>[^\"]+)" "(?P<chosen_cert_arn>[^\"]+)" (?P<matched_rule_priority>[!-~]+) (?P<request_creation_time>[!-~]+) "(?P<actions_executed>[^\"]+)" "(?P<redirect_url>[^\"]+)" "(?P<error_reason>[^\"]+)" "(?P<target_port_list>[^\"]+)" "(?P<target_status_code_list>[^\"]+)"$`},
+		{DocDate: "2020-08", Pattern: `^(?P<type>[!-~]+) (?P<time>[!-~]+) (?P<elb>[!-~]+) (?P<client_port>[!-~]+) (?P<target_port>[!-~]+) (?P<request_processing_time>[\d\-.]+) (?P<target_processing_time>[\d\-.]+) (?P<response_processing_time>[\d\-.]+) (?P<elb_status_code>\d{1,3}|-) (?P<target_status_code>\d{1,3}|-) (?P<received_bytes>[\d\-.]+) (?P<sent_bytes>[\d\-.]+) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-) ?\" "(?P<user_agent>[^\"]*)" (?P<ssl_cipher>[!-~]+) (?P<ssl_protocol>[!-~]+) (?P<target_group_arn>[!-~]+) "(?P<trace_id>[ -~]+)" "(?P<domain_name>[ -~]+)" "(?P<chosen_cert_arn>[ -~]+)" (?P<matched_rule_priority>[!-~]+) (?P<request_creation_time>[!-~]+) "(?P<actions_executed>[ -~]+)" "(?P<redirect_url>[ -~]+)" "(?P<error_reason>[ -~]+)" "(?P<target_port_list>[ -~]+)" "(?P<target_status_code_list>[ -~]+)" "(?P<classification>[ -~]+)" "(?P<classification_reason>[ -~]+)"`},
+		{Pattern: `^(?P<type>[!-~]+) (?P<time>[!-~]+) (?P<elb>[!-~]+) (?P<client_port>[!-~]+) (?P<target_port>[!-~]+) (?P<request_processing_time>[\d\-.]+) (?P<target_processing_time>[\d\-.]+) (?P<response_processing_time>[\d\-.]+) (?P<elb_status_code>\d{1,3}|-) (?P<target_status_code>\d{1,3}|-) (?P<received_bytes>[\d\-.]+) (?P<sent_bytes>[\d\-.]+) \"(?P<method>[A-Z\-]+) (?P<request_uri>[^ \"]+) (?P<protocol>HTTP/[0-9.]+|-) ?\" "(?P<user_agent>[^\"]*)" (?P<ssl_cipher>[!-~]+) (?P<ssl_protocol>[!-~]+) (?P<target_group_arn>[!-~]+) "(?P<trace_id>[ -~]+)" "(?P<domain_name>[ -~]+)" "(?P<chosen_cert_arn>[ -~]+)" (?P<matched_rule_priority>[!-~]+) (?P<request_creation_time>[!-~]+) "(?P<actions_executed>[ -~]+)" "(?P<redirect_url>[ -~]+)" "(?P<error_reason>[ -~]+)" "(?P<target_port_list>[ -~]+)" "(?P<target_status_code_list>[ -~]+)" "(?P<classification>[ -~]+)" "(?P<classification_reason>[ -~]+)" (?P<conn_trace_id>[!-~]+)`},
+	}),
+	"cloudfront": newPreset("cloudfront", []PresetVersion{
+		{Pattern: `^(?P<date>[\d\-.:]+)\t(?P<time>[\d\-.:]+)\t(?P<x_edge_location>[ -~]+)\t(?P<sc_bytes>[\d\-.]+)\t(?P<c_ip>[ -~]+)\t(?P<cs_method>[ -~]+)\t(?P<cs_host>[ -~]+)\t(?P<cs_uri_stem>[ -~]+)\t(?P<sc_status>\d{1,3}|-)\t(?P<cs_referer>[^\"]*)\t(?P<cs_user_agent>[^\"]*)\t(?P<cs_uri_query>[ -~]+)\t(?P<cs_cookie>\S+)\t(?P<x_edge_result_type>[ -~]+)\t(?P<x_edge_request_id>[ -~]+)\t(?P<x_host_header>[ -~]+)\t(?P<cs_protocol>[ -~]+)\t(?P<cs_bytes>[\d\-.]+)\t(?P<time_taken>[\d\-.]+)\t(?P<x_forwarded_for>[ -~]+)\t(?P<ssl_protocol>[ -~]+)\t(?P<ssl_cipher>[ -~]+)\t(?P<x_edge_response_result_type>[ -~]+)\t(?P<cs_protocol_version>[ -~]+)\t(?P<fle_status>[ -~]+)\t(?P<fle_encrypted_fields>\S+)\t(?P<c_port>[\d\-.]+)\t(?P<time_to_first_byte>[\d\-.]+)\t(?P<x_edge_detailed_result_type>[ -~]+)\t(?P<sc_content_type>[ -~]+)\t(?P<sc_content_len>[\d\-.]+)\t(?P<sc_range_start>[\d\-.]+)\t(?P<sc_range_end>[\d\-.]+)`},
+	}),
+}
+
+// Preset is a log format of a well-known source with the versions it went through, as new fields were
+// appended to the lines over time. The preset constructors such as NewS3RegexParser match the versions
+// newest first, so that logs of every version can be parsed.
+type Preset struct {
+	Name     string          `json:"name"`     // name of the preset, such as "s3"
+	Versions []PresetVersion `json:"versions"` // versions of the format, oldest first
+}
+
+// PresetVersion is a version of the format of a preset.
+type PresetVersion struct {
+	Version int      `json:"version"`           // version number, 1 for the original format
+	Pattern string   `json:"pattern"`           // regular expression matching lines of the version
+	Fields  []string `json:"fields"`            // fields of the version in order
+	Added   []string `json:"added"`             // fields added since the previous version
+	DocDate string   `json:"docDate,omitempty"` // month of the AWS documentation update describing the added fields (empty if unknown)
+	re      *regexp.Regexp
+}
+
+// PresetWarning describes a line matched only by an old version of the format of a preset, so that operators
+// learn about the fields that the source can be configured or upgraded to log.
+type PresetWarning struct {
+	Preset  string   // name of the preset
+	Version int      // version of the format the line matched
+	Latest  int      // latest version of the format
+	Missing []string // fields added since the version matched
+	Line    string   // line that matched the old version
+}
+
+// PresetWarningFunc is a function type called with a PresetWarning.
+type PresetWarningFunc func(w PresetWarning)
+
+// newPreset builds the preset from valid patterns, panicking otherwise.
+func newPreset(name string, versions []PresetVersion) *Preset {
+	p, err := buildPreset(name, versions)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// buildPreset numbers the versions and derives their fields from the named capture groups of the patterns.
+func buildPreset(name string, versions []PresetVersion) (*Preset, error) {
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%s: preset %q has no versions", regexPatternError, name)
+	}
+	var prev []string
+	for i := range versions {
+		v := &versions[i]
+		v.Version = i + 1
+		v.Fields, v.Added = nil, nil
+		re, err := regexp.Compile(v.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: preset %q version %d: %w", regexPatternError, name, v.Version, err)
+		}
+		v.re = re
+		for _, field := range v.re.SubexpNames()[1:] {
+			v.Fields = append(v.Fields, field)
+			if !slices.Contains(prev, field) {
+				v.Added = append(v.Added, field)
+			}
+		}
+		prev = v.Fields
+	}
+	return &Preset{Name: name, Versions: versions}, nil
+}
+
+// LookupPreset returns the preset registered with the name.
+func LookupPreset(name string) (Preset, bool) {
+	presetMu.RLock()
+	defer presetMu.RUnlock()
+	p, ok := presetRegistry[name]
+	if !ok {
+		return Preset{}, false
+	}
+	return *p, true
+}
+
+// Presets returns the registered presets sorted by name.
+func Presets() []Preset {
+	presetMu.RLock()
+	defer presetMu.RUnlock()
+	presets := make([]Preset, 0, len(presetRegistry))
+	for _, p := range presetRegistry {
+		presets = append(presets, *p)
+	}
+	sort.Slice(presets, func(i, j int) bool {
+		return presets[i].Name < presets[j].Name
+	})
+	return presets
+}
+
+// Latest returns the latest version of the format.
+func (p Preset) Latest() PresetVersion {
+	return p.Versions[len(p.Versions)-1]
+}
+
+// Match returns the newest version of the format whose pattern matches the line, as the preset constructors do.
+func (p Preset) Match(line string) (PresetVersion, bool) {
+	for i := len(p.Versions) - 1; i >= 0; i-- {
+		if p.Versions[i].re.MatchString(line) {
+			return p.Versions[i], true
+		}
+	}
+	return PresetVersion{}, false
+}
+
+// patterns returns the patterns of the versions, newest first.
+func (p Preset) patterns() []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, len(p.Versions))
+	for i, v := range p.Versions {
+		patterns[len(p.Versions)-1-i] = v.re
+	}
+	return patterns
+}
+
+// mustPreset returns the preset registered with the name for the preset constructors.
+func mustPreset(name string) Preset {
+	p, ok := LookupPreset(name)
+	if !ok {
+		panic(fmt.Sprintf("preset %q is not registered", name))
+	}
+	return p
+}
+
+// decoder wraps the decoder to call opt.OnPresetWarning once for the first line matched only by an old version,
+// told apart by the last field of the line. It is not wrapped with LazyDecode, which shortens the patterns.
+func (p Preset) decoder(decoder lineDecoder, opt Option) lineDecoder {
+	if opt.OnPresetWarning == nil || opt.LazyDecode || len(p.Versions) < 2 {
+		return decoder
+	}
+	last := make(map[string]PresetVersion, len(p.Versions))
+	for _, v := range p.Versions {
+		last[v.Fields[len(v.Fields)-1]] = v
+	}
+	latest := p.Latest()
+	var once sync.Once
+	return func(line string, patterns []*regexp.Regexp) ([]string, []string, error) {
+		ls, vs, err := decoder(line, patterns)
+		if err != nil || len(ls) == 0 {
+			return ls, vs, err
+		}
+		if v, ok := last[ls[len(ls)-1]]; ok && v.Version < latest.Version {
+			once.Do(func() {
+				var missing []string
+				for _, w := range p.Versions[v.Version:] {
+					missing = append(missing, w.Added...)
+				}
+				opt.OnPresetWarning(PresetWarning{Preset: p.Name, Version: v.Version, Latest: latest.Version, Missing: missing, Line: line})
+			})
+		}
+		return ls, vs, err
+	}
+}
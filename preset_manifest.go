@@ -0,0 +1,184 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// manifestError is the error message prefix for invalid preset manifests.
+const manifestError = "invalid preset manifest"
+
+// maxManifestSize is the maximum size in bytes of a preset manifest fetched by UpdatePresets.
+const maxManifestSize = 4 << 20
+
+// presetSerial is the serial of the last manifest applied, guarded by presetMu.
+var presetSerial int64
+
+// PresetManifest is a published set of preset formats, with which operators pick up formats extended with new
+// trailing fields without waiting for a release of the library. It is distributed signed with an Ed25519 key,
+// as produced by SignPresetManifest.
+type PresetManifest struct {
+	Serial  int64    `json:"serial"`  // number increased by each publication, so that an older manifest is not applied over a newer one
+	Presets []Preset `json:"presets"` // presets with the patterns of their versions, oldest first
+}
+
+// signedManifest is the signed form of a PresetManifest. The signature is over the bytes of the manifest as is.
+type signedManifest struct {
+	Manifest  json.RawMessage `json:"manifest"`
+	Signature string          `json:"signature"` // Ed25519 signature of the manifest in standard base64
+}
+
+// PresetUpdateConfig defines where UpdatePresets fetches the manifest from and how it is verified.
+type PresetUpdateConfig struct {
+	URL       string            // URL of the signed manifest (empty means not fetched, which is the default)
+	PublicKey ed25519.PublicKey // key the manifest must be signed with
+	CacheFile string            // path to keep the last verified manifest at, applied when it cannot be fetched (empty means not kept)
+	Client    *http.Client      // client to send requests with (nil means http.DefaultClient)
+}
+
+// SignPresetManifest returns the manifest signed with the key, for publishers of manifests.
+func SignPresetManifest(m *PresetManifest, key ed25519.PrivateKey) ([]byte, error) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", manifestError, err)
+	}
+	return json.Marshal(signedManifest{
+		Manifest:  b,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, b)),
+	})
+}
+
+// VerifyPresetManifest verifies the signature of a signed manifest with the key and returns the manifest.
+func VerifyPresetManifest(b []byte, key ed25519.PublicKey) (*PresetManifest, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%s: invalid public key", manifestError)
+	}
+	var sm signedManifest
+	if err := json.Unmarshal(b, &sm); err != nil {
+		return nil, fmt.Errorf("%s: %w", manifestError, err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(sm.Signature)
+	if err != nil || !ed25519.Verify(key, sm.Manifest, sig) {
+		return nil, fmt.Errorf("%s: signature verification failed", manifestError)
+	}
+	m := &PresetManifest{}
+	if err := json.Unmarshal(sm.Manifest, m); err != nil {
+		return nil, fmt.Errorf("%s: %w", manifestError, err)
+	}
+	return m, nil
+}
+
+// ApplyPresetManifest replaces the formats of the registered presets with those of the manifest, for the
+// parsers created afterwards. The latest version of each preset must keep the fields of the registered one
+// in order, so that a manifest only appends fields. Presets unknown to this version of the library are
+// ignored. A manifest with the serial of the last one applied is skipped, and one with an older serial is
+// rejected. Nothing is applied if any preset is invalid.
+func ApplyPresetManifest(m *PresetManifest) error {
+	presetMu.Lock()
+	defer presetMu.Unlock()
+	if m.Serial == presetSerial {
+		return nil
+	}
+	if m.Serial < presetSerial {
+		return fmt.Errorf("%s: serial %d is older than %d", manifestError, m.Serial, presetSerial)
+	}
+	updated := make(map[string]*Preset, len(m.Presets))
+	for _, mp := range m.Presets {
+		cur, ok := presetRegistry[mp.Name]
+		if !ok {
+			continue
+		}
+		p, err := buildPreset(mp.Name, slices.Clone(mp.Versions))
+		if err != nil {
+			return fmt.Errorf("%s: %w", manifestError, err)
+		}
+		fields, latest := cur.Latest().Fields, p.Latest().Fields
+		if len(latest) < len(fields) || !slices.Equal(latest[:len(fields)], fields) {
+			return fmt.Errorf("%s: preset %q does not keep the fields %v", manifestError, mp.Name, fields)
+		}
+		updated[mp.Name] = p
+	}
+	for name, p := range updated {
+		presetRegistry[name] = p
+	}
+	presetSerial = m.Serial
+	return nil
+}
+
+// UpdatePresets fetches the signed manifest from PresetUpdateConfig.URL, verifies it and applies it with
+// ApplyPresetManifest, and returns it. Nothing is fetched without a URL, so presets stay as built in unless
+// operators opt in. With PresetUpdateConfig.CacheFile, the manifest verified is kept there, and the one kept
+// is applied instead when there is no URL or the manifest cannot be fetched, such as on hosts without network
+// access; the manifest kept is then returned together with the error of the fetch, if any.
+func UpdatePresets(ctx context.Context, cfg PresetUpdateConfig) (*PresetManifest, error) {
+	var b []byte
+	var fetchErr error
+	if cfg.URL != "" {
+		b, fetchErr = fetchManifest(ctx, cfg)
+	}
+	cached := b == nil
+	if cached {
+		if cfg.CacheFile == "" {
+			return nil, fetchErr
+		}
+		var err error
+		b, err = os.ReadFile(filepath.Clean(cfg.CacheFile))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fetchErr
+		}
+		if err != nil {
+			return nil, errors.Join(fetchErr, fmt.Errorf("%s: %w", manifestError, err))
+		}
+	}
+	m, err := VerifyPresetManifest(b, cfg.PublicKey)
+	if err != nil {
+		return nil, errors.Join(fetchErr, err)
+	}
+	if err := ApplyPresetManifest(m); err != nil {
+		return nil, errors.Join(fetchErr, err)
+	}
+	if !cached && cfg.CacheFile != "" {
+		if err := writeFileAtomic(cfg.CacheFile, b); err != nil {
+			return m, fmt.Errorf("%s: %w", manifestError, err)
+		}
+	}
+	return m, fetchErr
+}
+
+// fetchManifest fetches the signed manifest at the URL of the config.
+func fetchManifest(ctx context.Context, cfg PresetUpdateConfig) ([]byte, error) {
+	client := cfg.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", manifestError, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", manifestError, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: unexpected status %s", manifestError, resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", manifestError, err)
+	}
+	if len(b) > maxManifestSize {
+		return nil, fmt.Errorf("%s: larger than %d bytes", manifestError, maxManifestSize)
+	}
+	return bytes.TrimSpace(b), nil
+}
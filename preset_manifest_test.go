@@ -0,0 +1,160 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// restorePresets restores the registered presets after a test applying manifests.
+func restorePresets(t *testing.T) {
+	t.Helper()
+	presetMu.Lock()
+	registry, serial := make(map[string]*Preset, len(presetRegistry)), presetSerial
+	for name, p := range presetRegistry {
+		registry[name] = p
+	}
+	presetMu.Unlock()
+	t.Cleanup(func() {
+		presetMu.Lock()
+		presetRegistry, presetSerial = registry, serial
+		presetMu.Unlock()
+	})
+}
+
+// extendedALB returns a manifest with a version of the alb preset with a field appended to the latest version.
+func extendedALB(serial int64, field string) *PresetManifest {
+	alb, _ := LookupPreset("alb")
+	versions := make([]PresetVersion, len(alb.Versions), len(alb.Versions)+1)
+	for i, v := range alb.Versions {
+		versions[i] = PresetVersion{Pattern: v.Pattern, DocDate: v.DocDate}
+	}
+	versions = append(versions, PresetVersion{Pattern: alb.Latest().Pattern + ` (?P<` + field + `>[!-~]+)`})
+	return &PresetManifest{Serial: serial, Presets: []Preset{{Name: "alb", Versions: versions}, {Name: "unknown", Versions: versions}}}
+}
+
+func TestUpdatePresets(t *testing.T) {
+	restorePresets(t)
+	orig, _ := LookupPreset("alb")
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := SignPresetManifest(extendedALB(1, "new_field"), priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var down atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(signed)
+	}))
+	defer srv.Close()
+	cache := filepath.Join(t.TempDir(), "presets.json")
+
+	// Offline by default.
+	m, err := UpdatePresets(context.Background(), PresetUpdateConfig{PublicKey: pub})
+	if m != nil || err != nil {
+		t.Fatalf("\ngot:\n%v %v\nwant:\n%v %v\n", m, err, nil, nil)
+	}
+
+	// A manifest signed with another key is not applied.
+	other, _, _ := ed25519.GenerateKey(nil)
+	if _, err := UpdatePresets(context.Background(), PresetUpdateConfig{URL: srv.URL, PublicKey: other}); err == nil {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "signature verification error")
+	}
+	if alb, _ := LookupPreset("alb"); len(alb.Versions) != 6 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", len(alb.Versions), 6)
+	}
+
+	m, err = UpdatePresets(context.Background(), PresetUpdateConfig{URL: srv.URL, PublicKey: pub, CacheFile: cache})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alb, _ := LookupPreset("alb")
+	if latest := alb.Latest(); m.Serial != 1 || latest.Version != 7 || len(latest.Added) != 1 || latest.Added[0] != "new_field" {
+		t.Errorf("\ngot:\n%v %v\nwant:\n%v %v\n", latest.Version, latest.Added, 7, []string{"new_field"})
+	}
+	if _, ok := LookupPreset("unknown"); ok {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", ok, false)
+	}
+
+	// Parsers created afterwards match the new version.
+	line := `http 2018-07-02T22:23:00.186641Z app/my-loadbalancer/50dc6c495c0c9188 192.168.131.39:2817 10.0.0.1:80 0.000 0.001 0.000 200 200 34 366 "GET http://www.example.com:80/ HTTP/1.1" "curl/7.46.0" - - arn:aws:elasticloadbalancing:us-east-2:123456789012:targetgroup/my-targets/73e2d6bc24d8a067 "Root=1-58337262-36d228ad5d99923122bbe354" "-" "-" 0 2018-07-02T22:22:48.364000Z "forward" "-" "-" "10.0.0.1:80" "200" "-" "-" TID_1234abcd xyz`
+	buf := &bytes.Buffer{}
+	if _, err := NewALBRegexParser(context.Background(), buf, Option{Labels: []string{"conn_trace_id", "new_field"}}).ParseString(line); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"conn_trace_id":"TID_1234abcd","new_field":"xyz"}` + "\n"; buf.String() != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", buf.String(), want)
+	}
+
+	// The manifest kept is applied when the manifest cannot be fetched.
+	presetMu.Lock()
+	presetRegistry["alb"], presetSerial = &orig, 0
+	presetMu.Unlock()
+	down.Store(true)
+	m, err = UpdatePresets(context.Background(), PresetUpdateConfig{URL: srv.URL, PublicKey: pub, CacheFile: cache})
+	if m == nil || err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("\ngot:\n%v %v\nwant:\n%v\n", m, err, "manifest kept and fetch error")
+	}
+	if alb, _ := LookupPreset("alb"); len(alb.Versions) != 7 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", len(alb.Versions), 7)
+	}
+}
+
+func TestApplyPresetManifest(t *testing.T) {
+	restorePresets(t)
+	if err := ApplyPresetManifest(extendedALB(5, "a")); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		m       *PresetManifest
+		wantErr bool
+	}{
+		{
+			name: "same serial skipped",
+			m:    &PresetManifest{Serial: 5, Presets: []Preset{{Name: "alb", Versions: []PresetVersion{{Pattern: `(`}}}}},
+		},
+		{
+			name:    "older serial",
+			m:       extendedALB(4, "b"),
+			wantErr: true,
+		},
+		{
+			name:    "invalid pattern",
+			m:       &PresetManifest{Serial: 6, Presets: []Preset{{Name: "alb", Versions: []PresetVersion{{Pattern: `(`}}}}},
+			wantErr: true,
+		},
+		{
+			name:    "fields dropped",
+			m:       &PresetManifest{Serial: 6, Presets: []Preset{{Name: "alb", Versions: []PresetVersion{{Pattern: `^(?P<type>[!-~]+)`}}}}},
+			wantErr: true,
+		},
+		{
+			name:    "no versions",
+			m:       &PresetManifest{Serial: 6, Presets: []Preset{{Name: "alb"}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ApplyPresetManifest(tt.m); (err != nil) != tt.wantErr {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if alb, _ := LookupPreset("alb"); alb.Latest().Added[0] != "a" {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", alb.Latest().Added, []string{"a"})
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPresets(t *testing.T) {
+	var names []string
+	for _, p := range Presets() {
+		names = append(names, p.Name)
+	}
+	if want := []string{"alb", "cloudfront", "s3"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", names, want)
+	}
+	s3, ok := LookupPreset("s3")
+	if !ok {
+		t.Fatal("s3 is not registered")
+	}
+	latest := s3.Latest()
+	if latest.Version != 5 || !reflect.DeepEqual(latest.Added, []string{"acl_required"}) || len(latest.Fields) != 28 {
+		t.Errorf("\ngot:\n%v %v %v\nwant:\n%v %v %v\n", latest.Version, latest.Added, len(latest.Fields), 5, []string{"acl_required"}, 28)
+	}
+	if v := s3.Versions[3]; !reflect.DeepEqual(v.Added, []string{"access_point_arn"}) || v.DocDate != "2019-12" {
+		t.Errorf("\ngot:\n%v %v\nwant:\n%v %v\n", v.Added, v.DocDate, []string{"access_point_arn"}, "2019-12")
+	}
+	alb, _ := LookupPreset("alb")
+	want := [][]string{
+		nil,
+		{"domain_name", "chosen_cert_arn"},
+		{"matched_rule_priority", "request_creation_time", "actions_executed", "redirect_url", "error_reason"},
+		{"target_port_list", "target_status_code_list"},
+		{"classification", "classification_reason"},
+		{"conn_trace_id"},
+	}
+	for i, v := range alb.Versions[1:] {
+		if !reflect.DeepEqual(v.Added, want[i+1]) {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", v.Added, want[i+1])
+		}
+	}
+	if _, ok := LookupPreset("unknown"); ok {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", ok, false)
+	}
+}
+
+func TestOption_OnPresetWarning(t *testing.T) {
+	old := `http 2018-07-02T22:23:00.186641Z app/my-loadbalancer/50dc6c495c0c9188 192.168.131.39:2817 10.0.0.1:80 0.000 0.001 0.000 200 200 34 366 "GET http://www.example.com:80/ HTTP/1.1" "curl/7.46.0" - - arn:aws:elasticloadbalancing:us-east-2:123456789012:targetgroup/my-targets/73e2d6bc24d8a067 "Root=1-58337262-36d228ad5d99923122bbe354" "-" "-" 0 2018-07-02T22:22:48.364000Z "forward" "-" "-" "10.0.0.1:80" "200" "-" "-"`
+	latest := old + " TID_1234abcd"
+	tests := []struct {
+		name  string
+		input []string
+		opt   Option
+		want  []PresetWarning
+	}{
+		{
+			name:  "old version",
+			input: []string{latest, old, old},
+			want:  []PresetWarning{{Preset: "alb", Version: 5, Latest: 6, Missing: []string{"conn_trace_id"}, Line: old}},
+		},
+		{
+			name:  "latest version",
+			input: []string{latest},
+		},
+		{
+			name:  "lazy decode",
+			input: []string{old},
+			opt:   Option{LazyDecode: true, Labels: []string{"elb"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []PresetWarning
+			tt.opt.OnPresetWarning = func(w PresetWarning) {
+				got = append(got, w)
+			}
+			if _, err := NewALBRegexParser(context.Background(), io.Discard, tt.opt).ParseString(strings.Join(tt.input, "\n")); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
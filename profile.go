@@ -0,0 +1,213 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// defaultProfileLines is the number of lines sampled by ProfileLines by default.
+const defaultProfileLines = 1000
+
+// profileConsistency is the share of the sampled lines that must agree for a format to be suggested.
+const profileConsistency = 0.9
+
+// formats suggested by ProfileLines
+const (
+	FormatJSON       = "json"
+	FormatLTSV       = "ltsv"
+	FormatTSV        = "tsv"
+	FormatCSV        = "csv"
+	FormatFixedWidth = "fixed-width"
+	FormatRegex      = "regex"
+)
+
+// profileDelimiters are the delimiters whose tokens are profiled, in order of preference on ties.
+var profileDelimiters = []string{"\t", ",", "|", ";", " "}
+
+// Profile is the layout of the lines of an unknown format, reported by ProfileLines to help deciding between
+// RegexParser, CSVParser, LTSVParser and FixedWidthParser.
+type Profile struct {
+	Lines      int                `json:"lines"`      // Number of lines sampled.
+	MinLength  int                `json:"minLength"`  // Length in bytes of the shortest line.
+	MaxLength  int                `json:"maxLength"`  // Length in bytes of the longest line.
+	Lengths    map[int]int        `json:"lengths"`    // Number of lines by length in bytes.
+	Delimiters []DelimiterProfile `json:"delimiters"` // Delimiters splitting some of the lines into more than one token, the most consistent first.
+	Columns    []FixedColumn      `json:"columns"`    // Fixed-width columns separated by bytes that are spaces in every line, labeled column1, column2, and so on.
+	Format     string             `json:"format"`     // Suggested format, one of "json", "ltsv", "tsv", "csv", "fixed-width" and "regex".
+}
+
+// DelimiterProfile is the number and width of the tokens the sampled lines are split into by a delimiter.
+type DelimiterProfile struct {
+	Delimiter   string            `json:"delimiter"`   // Delimiter, such as "\t" or ",".
+	Tokens      map[int]int       `json:"tokens"`      // Number of lines by number of tokens.
+	Consistency float64           `json:"consistency"` // Share of the lines with the most common number of tokens.
+	Positions   []PositionProfile `json:"positions"`   // Widths of the tokens at each position.
+}
+
+// PositionProfile is the distribution of the widths of the tokens at a position of the lines.
+type PositionProfile struct {
+	Position int         `json:"position"` // Position of the token, starting at 1.
+	Count    int         `json:"count"`    // Number of lines with a token at the position.
+	MinWidth int         `json:"minWidth"` // Width in bytes of the narrowest token.
+	MaxWidth int         `json:"maxWidth"` // Width in bytes of the widest token.
+	Widths   map[int]int `json:"widths"`   // Number of tokens by width in bytes.
+}
+
+// ProfileLines reads up to n lines from r (0 means 1000) and reports the token counts and width distributions
+// per position for the common delimiters, the fixed-width columns the lines line up in, and a suggested format.
+// The suggestion is a heuristic: lines starting with "{" suggest JSON, tab-separated "label:value" tokens LTSV,
+// and a delimiter splitting at least 90% of the lines into the same number of tokens TSV or CSV. Otherwise,
+// lines of the same length in at least 90% of the cases with two or more columns suggest fixed-width, and the
+// rest regex. Empty lines are not sampled.
+func ProfileLines(r io.Reader, n int) (*Profile, error) {
+	if n <= 0 {
+		n = defaultProfileLines
+	}
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, initialBufferSize), maxLineSize(0))
+	for len(lines) < n && scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", profileError, err)
+	}
+	p := &Profile{Lines: len(lines), Lengths: make(map[int]int)}
+	for i, line := range lines {
+		if i == 0 || len(line) < p.MinLength {
+			p.MinLength = len(line)
+		}
+		p.MaxLength = max(p.MaxLength, len(line))
+		p.Lengths[len(line)]++
+	}
+	for _, d := range profileDelimiters {
+		if dp := profileDelimiter(lines, d); dp != nil {
+			p.Delimiters = append(p.Delimiters, *dp)
+		}
+	}
+	slices.SortStableFunc(p.Delimiters, func(a, b DelimiterProfile) int {
+		switch {
+		case a.Consistency > b.Consistency:
+			return -1
+		case a.Consistency < b.Consistency:
+			return 1
+		}
+		return 0
+	})
+	p.Columns = profileColumns(lines, p.MaxLength)
+	p.Format = p.suggest(lines)
+	return p, nil
+}
+
+// profileDelimiter returns the profile of the tokens of the lines split by the delimiter, or nil if no line
+// is split into more than one token.
+func profileDelimiter(lines []string, delimiter string) *DelimiterProfile {
+	dp := &DelimiterProfile{Delimiter: delimiter, Tokens: make(map[int]int)}
+	split := false
+	for _, line := range lines {
+		tokens := strings.Split(line, delimiter)
+		dp.Tokens[len(tokens)]++
+		split = split || len(tokens) > 1
+		for i, token := range tokens {
+			if i == len(dp.Positions) {
+				dp.Positions = append(dp.Positions, PositionProfile{Position: i + 1, MinWidth: len(token), Widths: make(map[int]int)})
+			}
+			pp := &dp.Positions[i]
+			pp.Count++
+			pp.MinWidth = min(pp.MinWidth, len(token))
+			pp.MaxWidth = max(pp.MaxWidth, len(token))
+			pp.Widths[len(token)]++
+		}
+	}
+	if !split {
+		return nil
+	}
+	_, mode := modeOf(dp.Tokens)
+	dp.Consistency = float64(mode) / float64(len(lines))
+	return dp
+}
+
+// profileColumns returns the columns of the lines separated by runs of bytes that are spaces, or beyond the end,
+// in every line. Each column spans up to the start of the next, so that values padded to the right fit in it,
+// and the last column extends to the end of the line. It returns nil if the lines have fewer than two columns.
+func profileColumns(lines []string, width int) []FixedColumn {
+	if len(lines) == 0 {
+		return nil
+	}
+	blank := make([]bool, width)
+	for pos := range blank {
+		blank[pos] = true
+		for _, line := range lines {
+			if pos < len(line) && line[pos] != ' ' {
+				blank[pos] = false
+				break
+			}
+		}
+	}
+	var starts []int
+	for pos := range blank {
+		if !blank[pos] && (pos == 0 || blank[pos-1]) {
+			starts = append(starts, pos)
+		}
+	}
+	if len(starts) < 2 {
+		return nil
+	}
+	columns := make([]FixedColumn, len(starts))
+	for i, start := range starts {
+		columns[i] = FixedColumn{Label: fmt.Sprintf("column%d", i+1), Start: start}
+		if i > 0 {
+			columns[i-1].End = start
+		}
+	}
+	columns[0].Start = 0
+	return columns
+}
+
+// suggest returns the format suggested for the lines.
+func (p *Profile) suggest(lines []string) string {
+	if len(lines) == 0 {
+		return FormatRegex
+	}
+	if !slices.ContainsFunc(lines, func(line string) bool { return !strings.HasPrefix(strings.TrimSpace(line), "{") }) {
+		return FormatJSON
+	}
+	if !slices.ContainsFunc(lines, func(line string) bool {
+		return !strings.Contains(line, "\t") || slices.ContainsFunc(strings.Split(line, "\t"), func(token string) bool {
+			return !strings.Contains(token, ":")
+		})
+	}) {
+		return FormatLTSV
+	}
+	for _, dp := range p.Delimiters {
+		if dp.Consistency < profileConsistency {
+			break
+		}
+		switch dp.Delimiter {
+		case "\t":
+			return FormatTSV
+		case ",":
+			return FormatCSV
+		}
+	}
+	if _, mode := modeOf(p.Lengths); len(p.Columns) >= 2 && float64(mode) >= profileConsistency*float64(len(lines)) {
+		return FormatFixedWidth
+	}
+	return FormatRegex
+}
+
+// modeOf returns the most common key of the counts and its count, the smallest key on ties.
+func modeOf(counts map[int]int) (int, int) {
+	key, count := 0, 0
+	for k, c := range counts {
+		if c > count || c == count && k < key {
+			key, count = k, c
+		}
+	}
+	return key, count
+}
@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProfileLines(t *testing.T) {
+	fixed := "2024-01-02 alice      1024 /index.html\n" +
+		"2024-01-02 bob          12 /about.html\n" +
+		"2024-01-03 carol       512 /login.html\n"
+	tests := []struct {
+		name        string
+		input       string
+		want        string
+		wantColumns []FixedColumn
+	}{
+		{
+			name:  "json",
+			input: `{"a":1}` + "\n" + `{"a":2, "b":"x"}` + "\n",
+			want:  FormatJSON,
+		},
+		{
+			name:  "ltsv",
+			input: ltsvAllMatchInput,
+			want:  FormatLTSV,
+		},
+		{
+			name:  "tsv",
+			input: "a\tb\tc\n1\t2\t3\n4\t5\t6\n",
+			want:  FormatTSV,
+		},
+		{
+			name:  "csv",
+			input: "a,b,c\n1,2,3\n\n4,5,6\n",
+			want:  FormatCSV,
+		},
+		{
+			name:  "fixed-width",
+			input: fixed,
+			want:  FormatFixedWidth,
+			wantColumns: []FixedColumn{
+				{Label: "column1", Start: 0, End: 11},
+				{Label: "column2", Start: 11, End: 22},
+				{Label: "column3", Start: 22, End: 27},
+				{Label: "column4", Start: 27},
+			},
+		},
+		{
+			name:  "regex",
+			input: regexAllMatchInput,
+			want:  FormatRegex,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ProfileLines(strings.NewReader(tt.input), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Format != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Format, tt.want)
+			}
+			if tt.wantColumns != nil && !reflect.DeepEqual(got.Columns, tt.wantColumns) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Columns, tt.wantColumns)
+			}
+		})
+	}
+
+	// The columns found are usable as is by FixedWidthParser.
+	p, err := ProfileLines(strings.NewReader(fixed), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Lines != 2 || p.MinLength != 38 || p.MaxLength != 38 || p.Lengths[38] != 2 {
+		t.Errorf("\ngot:\n%v %v %v %v\nwant:\n%v %v %v %v\n", p.Lines, p.MinLength, p.MaxLength, p.Lengths, 2, 38, 38, map[int]int{38: 2})
+	}
+	space := p.Delimiters[len(p.Delimiters)-1]
+	if space.Delimiter != " " || space.Positions[0].MinWidth != 10 || space.Positions[0].Widths[10] != 2 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", space, "widths of the dates")
+	}
+	buf := &bytes.Buffer{}
+	fp, err := NewFixedWidthParser(context.Background(), buf, p.Columns, Option{Labels: []string{"column2", "column3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fp.ParseString(fixed); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"column2":"alice","column3":"1024"}` + "\n" + `{"column2":"bob","column3":"12"}` + "\n" + `{"column2":"carol","column3":"512"}` + "\n"
+	if buf.String() != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", buf.String(), want)
+	}
+}
@@ -0,0 +1,719 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// queryError is the error message prefix for queries that cannot be parsed.
+const queryError = "invalid query"
+
+// aggregate functions supported by Query
+var queryAggregates = []string{"count", "sum", "avg", "min", "max"}
+
+// queryOperators maps the comparison operators of queries to those of filter expressions.
+var queryOperators = map[string]string{
+	"=": "==", "==": "==", "!=": "!=", "<>": "!=",
+	"<": "<", "<=": "<=", ">": ">", ">=": ">=",
+	"=~": "=~", "!~": "!~",
+}
+
+// Source runs a parse for Query with the writer and option given, for example:
+//
+//	func(ctx context.Context, w io.Writer, opt Option) (*Result, error) {
+//		return NewALBRegexParser(ctx, w, opt).ParseFile("alb.log")
+//	}
+//
+// Set fields such as Enrichers in opt before passing it on, but keep its LineHandler, which collects the records.
+type Source func(ctx context.Context, w io.Writer, opt Option) (*Result, error)
+
+// QueryResult is the result of Query.
+type QueryResult struct {
+	Columns []string   // names of the selected columns, or their aliases
+	Rows    [][]string // values of the rows, in the order of Columns
+	Result  *Result    // result of the parse the query ran over
+	Spills  int        // number of times the groups were spilled to disk
+}
+
+// Write writes the rows to w, one per line, converted with the handler such as JSONLineHandler.
+func (q *QueryResult) Write(w io.Writer, handler LineHandler) error {
+	for i, row := range q.Rows {
+		line, err := handler(q.Columns, row, i == 0)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Query runs the parse of the source and answers a query over its records in a single pass, so that one-off
+// questions do not require exporting the output to another tool. It supports a small subset of SQL:
+//
+//	SELECT <columns> FROM <any name> [WHERE <conditions>] [GROUP BY <labels>] [ORDER BY <keys>] [LIMIT <n>]
+//
+// Columns are "*", labels or the aggregates count(*), count(label), sum(label), avg(label), min(label) and
+// max(label), each optionally followed by AS and an alias. Conditions are comparisons of a label with a value
+// joined by AND, using the operators =, !=, <>, <, <=, >, >=, =~ and !~ with the semantics of filter expressions,
+// where values are numbers, words or strings quoted with ' such as '^/api/'; records lacking the label do not match. Keys of ORDER BY are column names, aliases or 1-based positions,
+// optionally followed by ASC or DESC. Values are compared numerically when both are numbers.
+//
+// For example, the ten S3 buckets with the most server errors:
+//
+//	Query(ctx, source, "SELECT bucket, count(*) FROM log WHERE http_status >= 500 GROUP BY bucket ORDER BY 2 DESC LIMIT 10")
+func Query(ctx context.Context, source Source, query string) (*QueryResult, error) {
+	return QueryWith(ctx, source, query, QueryConfig{})
+}
+
+// QueryConfig defines the resource limits of QueryWith.
+type QueryConfig struct {
+	MemoryBudget int64  // estimated bytes of groups kept in memory before they are spilled to disk (zero means unlimited)
+	SpillDir     string // directory of the spill files (empty means os.TempDir)
+}
+
+// QueryWith runs Query within the limits of cfg. When the groups of GROUP BY exceed cfg.MemoryBudget, as with
+// high-cardinality labels such as request_uri, they are spilled to temporary files as sorted partial aggregates
+// and merged at the end. With ORDER BY and LIMIT, only the top rows are kept in memory during the merge.
+func QueryWith(ctx context.Context, source Source, query string, cfg QueryConfig) (*QueryResult, error) {
+	q, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	q.budget, q.spillDir = cfg.MemoryBudget, cfg.SpillDir
+	defer q.removeRuns()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var mu sync.Mutex
+	done := false
+	handler := func(labels, values []string, _ bool) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if done {
+			return "", nil
+		}
+		q.observe(Record{Labels: labels, Values: values})
+		if q.err != nil {
+			return "", q.err
+		}
+		if q.streaming() && q.limit >= 0 && len(q.rows) >= q.limit {
+			done = true
+			cancel()
+		}
+		return "", nil
+	}
+	r, err := source(ctx, io.Discard, Option{LineHandler: handler})
+	if err != nil && !(done && errors.Is(err, context.Canceled)) {
+		return nil, err
+	}
+	rows, err := q.finish()
+	if err != nil {
+		return nil, err
+	}
+	return &QueryResult{Columns: q.columns(), Rows: rows, Result: r, Spills: len(q.runs)}, nil
+}
+
+// queryItem is a selected column.
+type queryItem struct {
+	agg   string // aggregate function, or empty for a label
+	arg   string // label, or "*" for count(*)
+	alias string
+}
+
+// name returns the name of the column.
+func (it queryItem) name() string {
+	switch {
+	case it.alias != "":
+		return it.alias
+	case it.agg != "":
+		return it.agg + "(" + it.arg + ")"
+	default:
+		return it.arg
+	}
+}
+
+// queryCondition is a comparison in the WHERE clause.
+type queryCondition struct {
+	label  string
+	filter lineFilter
+}
+
+// queryOrder is a key of the ORDER BY clause, resolved to a column position when the columns are known.
+type queryOrder struct {
+	key   string
+	index int
+	desc  bool
+}
+
+// queryAggregator accumulates the values of an aggregate column in a group.
+type queryAggregator struct {
+	count    int
+	sum      float64
+	numeric  int
+	min, max string
+}
+
+// queryGroup is a group of records with the same values of the GROUP BY labels.
+type queryGroup struct {
+	keys []string
+	aggs []queryAggregator
+	seq  int // order of first appearance, kept across spills
+}
+
+// sqlQuery is a parsed query and the state of its evaluation.
+type sqlQuery struct {
+	items    []queryItem
+	star     bool
+	where    []queryCondition
+	groupBy  []string
+	orderBy  []queryOrder
+	limit    int
+	labels   []string // labels of the first record, for "*"
+	rows     [][]string
+	groups   map[string]*queryGroup
+	order    []string // keys of groups in order of appearance
+	seq      int      // number of groups created
+	budget   int64    // memory budget of groups, or zero for unlimited
+	mem      int64    // estimated memory of groups
+	spillDir string
+	runs     []string // spill files of sorted partial aggregates
+	err      error    // failure to spill, which stops the parse
+}
+
+// aggregated reports whether the query aggregates records.
+func (q *sqlQuery) aggregated() bool {
+	if len(q.groupBy) > 0 {
+		return true
+	}
+	for _, it := range q.items {
+		if it.agg != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// streaming reports whether rows are final as soon as they are observed, so that the parse can stop at the limit.
+func (q *sqlQuery) streaming() bool {
+	return !q.aggregated() && len(q.orderBy) == 0
+}
+
+// columns returns the names of the columns.
+func (q *sqlQuery) columns() []string {
+	if q.star {
+		return q.labels
+	}
+	cols := make([]string, len(q.items))
+	for i, it := range q.items {
+		cols[i] = it.name()
+	}
+	return cols
+}
+
+// observe evaluates a record. Values that cannot be compared, such as "-" with a numeric operator, do not match.
+func (q *sqlQuery) observe(r Record) {
+	for _, c := range q.where {
+		v, ok := r.Get(c.label)
+		if !ok {
+			return
+		}
+		if f, err := c.filter(v); err != nil || !f {
+			return
+		}
+	}
+	if q.star {
+		if q.labels == nil {
+			q.labels = r.Labels
+		}
+		row := make([]string, len(q.labels))
+		for i, label := range q.labels {
+			row[i], _ = r.Get(label)
+		}
+		q.rows = append(q.rows, row)
+		return
+	}
+	if !q.aggregated() {
+		row := make([]string, len(q.items))
+		for i, it := range q.items {
+			row[i], _ = r.Get(it.arg)
+		}
+		q.rows = append(q.rows, row)
+		return
+	}
+	keys := make([]string, len(q.groupBy))
+	for i, label := range q.groupBy {
+		keys[i], _ = r.Get(label)
+	}
+	k := strings.Join(keys, "\x00")
+	g, ok := q.groups[k]
+	if !ok {
+		g = &queryGroup{keys: keys, aggs: make([]queryAggregator, len(q.items)), seq: q.seq}
+		q.groups[k] = g
+		q.order = append(q.order, k)
+		q.seq++
+		q.mem += groupSize(k, len(q.items))
+	}
+	for i, it := range q.items {
+		if it.agg == "" {
+			continue
+		}
+		a := &g.aggs[i]
+		if it.arg == "*" {
+			a.count++
+			continue
+		}
+		v, ok := r.Get(it.arg)
+		if !ok || isNullValue(v) {
+			continue
+		}
+		a.count++
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			a.sum += f
+			a.numeric++
+		}
+		if a.count == 1 || compareQueryValues(v, a.min) < 0 {
+			a.min = v
+		}
+		if a.count == 1 || compareQueryValues(v, a.max) > 0 {
+			a.max = v
+		}
+	}
+	if q.budget > 0 && q.mem > q.budget && len(q.groupBy) > 0 {
+		q.err = q.spill()
+	}
+}
+
+// finish returns the rows, aggregated, sorted and limited.
+func (q *sqlQuery) finish() ([][]string, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	cols := q.columns()
+	for i, o := range q.orderBy {
+		if n, err := strconv.Atoi(o.key); err == nil {
+			if n < 1 || n > len(cols) {
+				return nil, fmt.Errorf("%s: ORDER BY position %d is out of range", queryError, n)
+			}
+			q.orderBy[i].index = n - 1
+			continue
+		}
+		j := slices.Index(cols, o.key)
+		if j < 0 {
+			return nil, fmt.Errorf("%s: ORDER BY %q is not a selected column", queryError, o.key)
+		}
+		q.orderBy[i].index = j
+	}
+	rows := q.rows
+	switch {
+	case q.aggregated() && len(q.runs) > 0:
+		var err error
+		if rows, err = q.merge(); err != nil {
+			return nil, err
+		}
+	case q.aggregated():
+		if len(q.groupBy) == 0 && len(q.order) == 0 {
+			q.groups[""] = &queryGroup{aggs: make([]queryAggregator, len(q.items))}
+			q.order = append(q.order, "")
+		}
+		rows = make([][]string, 0, len(q.order))
+		for _, k := range q.order {
+			rows = append(rows, q.row(q.groups[k]))
+		}
+	}
+	if len(q.orderBy) > 0 {
+		sort.SliceStable(rows, func(i, j int) bool {
+			return q.less(rows[i], rows[j])
+		})
+	}
+	if q.limit >= 0 && q.limit < len(rows) {
+		rows = rows[:q.limit]
+	}
+	return rows, nil
+}
+
+// row returns the row of the group.
+func (q *sqlQuery) row(g *queryGroup) []string {
+	row := make([]string, len(q.items))
+	for i, it := range q.items {
+		if it.agg == "" {
+			row[i] = g.keys[slices.Index(q.groupBy, it.arg)]
+			continue
+		}
+		row[i] = g.aggs[i].value(it.agg)
+	}
+	return row
+}
+
+// less reports whether the row a sorts before the row b by the keys of ORDER BY.
+func (q *sqlQuery) less(a, b []string) bool {
+	for _, o := range q.orderBy {
+		c := compareQueryValues(a[o.index], b[o.index])
+		if c == 0 {
+			continue
+		}
+		return c < 0 != o.desc
+	}
+	return false
+}
+
+// value returns the value of the aggregate function.
+func (a queryAggregator) value(agg string) string {
+	switch agg {
+	case "count":
+		return strconv.Itoa(a.count)
+	case "sum":
+		return strconv.FormatFloat(a.sum, 'f', -1, 64)
+	case "avg":
+		if a.numeric == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(a.sum/float64(a.numeric), 'f', -1, 64)
+	case "min":
+		return a.min
+	default:
+		return a.max
+	}
+}
+
+// compareQueryValues compares the values numerically if both are numbers, otherwise as strings.
+func compareQueryValues(a, b string) int {
+	x, errx := strconv.ParseFloat(a, 64)
+	y, erry := strconv.ParseFloat(b, 64)
+	if errx == nil && erry == nil {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		default:
+			return 0
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// parseQuery parses the query.
+func parseQuery(query string) (*sqlQuery, error) {
+	tokens, err := tokenizeQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens}
+	q := &sqlQuery{limit: -1, groups: map[string]*queryGroup{}}
+	if err := p.expectKeyword("select"); err != nil {
+		return nil, err
+	}
+	if err := p.parseItems(q); err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("from"); err != nil {
+		return nil, err
+	}
+	if _, err := p.ident(); err != nil {
+		return nil, err
+	}
+	if p.keyword("where") {
+		if err := p.parseWhere(q); err != nil {
+			return nil, err
+		}
+	}
+	if p.keyword("group") {
+		if err := p.expectKeyword("by"); err != nil {
+			return nil, err
+		}
+		for {
+			label, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			q.groupBy = append(q.groupBy, label)
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+	if p.keyword("order") {
+		if err := p.expectKeyword("by"); err != nil {
+			return nil, err
+		}
+		for {
+			key, err := p.orderKey()
+			if err != nil {
+				return nil, err
+			}
+			o := queryOrder{key: key}
+			if p.keyword("desc") {
+				o.desc = true
+			} else {
+				p.keyword("asc")
+			}
+			q.orderBy = append(q.orderBy, o)
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+	if p.keyword("limit") {
+		tok := p.next()
+		n, err := strconv.Atoi(tok)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%s: LIMIT %q is not a non-negative integer", queryError, tok)
+		}
+		q.limit = n
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("%s: unexpected %q", queryError, p.peek())
+	}
+	if q.aggregated() {
+		if q.star {
+			return nil, fmt.Errorf("%s: * cannot be selected with aggregates or GROUP BY", queryError)
+		}
+		for _, it := range q.items {
+			if it.agg == "" && slices.Index(q.groupBy, it.arg) < 0 {
+				return nil, fmt.Errorf("%s: %q must appear in GROUP BY or be used in an aggregate", queryError, it.arg)
+			}
+		}
+	}
+	return q, nil
+}
+
+// queryParser is a recursive descent parser of query tokens.
+type queryParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *queryParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *queryParser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() string {
+	tok := p.peek()
+	if !p.done() {
+		p.pos++
+	}
+	return tok
+}
+
+// keyword consumes the keyword if it is next.
+func (p *queryParser) keyword(kw string) bool {
+	if strings.EqualFold(p.peek(), kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// symbol consumes the symbol if it is next.
+func (p *queryParser) symbol(s string) bool {
+	if p.peek() == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) expectKeyword(kw string) error {
+	if !p.keyword(kw) {
+		return fmt.Errorf("%s: expected %s, got %q", queryError, strings.ToUpper(kw), p.peek())
+	}
+	return nil
+}
+
+func (p *queryParser) expectSymbol(s string) error {
+	if !p.symbol(s) {
+		return fmt.Errorf("%s: expected %q, got %q", queryError, s, p.peek())
+	}
+	return nil
+}
+
+// ident consumes an identifier.
+func (p *queryParser) ident() (string, error) {
+	tok := p.peek()
+	if tok == "" || !isQueryIdentStart(rune(tok[0])) || isQueryKeyword(tok) {
+		return "", fmt.Errorf("%s: expected identifier, got %q", queryError, tok)
+	}
+	p.pos++
+	return tok, nil
+}
+
+// parseItems parses the selected columns.
+func (p *queryParser) parseItems(q *sqlQuery) error {
+	if p.symbol("*") {
+		q.star = true
+		return nil
+	}
+	for {
+		it, err := p.item()
+		if err != nil {
+			return err
+		}
+		if p.keyword("as") {
+			if it.alias, err = p.ident(); err != nil {
+				return err
+			}
+		}
+		q.items = append(q.items, it)
+		if !p.symbol(",") {
+			return nil
+		}
+	}
+}
+
+// item parses a label or an aggregate.
+func (p *queryParser) item() (queryItem, error) {
+	name, err := p.ident()
+	if err != nil {
+		return queryItem{}, err
+	}
+	if !p.symbol("(") {
+		return queryItem{arg: name}, nil
+	}
+	agg := strings.ToLower(name)
+	if slices.Index(queryAggregates, agg) < 0 {
+		return queryItem{}, fmt.Errorf("%s: unknown function %q", queryError, name)
+	}
+	var arg string
+	if agg == "count" && p.symbol("*") {
+		arg = "*"
+	} else if arg, err = p.ident(); err != nil {
+		return queryItem{}, err
+	}
+	if err := p.expectSymbol(")"); err != nil {
+		return queryItem{}, err
+	}
+	return queryItem{agg: agg, arg: arg}, nil
+}
+
+// orderKey parses a key of ORDER BY, returning the column name or position.
+func (p *queryParser) orderKey() (string, error) {
+	if _, err := strconv.Atoi(p.peek()); err == nil {
+		return p.next(), nil
+	}
+	it, err := p.item()
+	if err != nil {
+		return "", err
+	}
+	return it.name(), nil
+}
+
+// parseWhere parses the conditions joined by AND.
+func (p *queryParser) parseWhere(q *sqlQuery) error {
+	for {
+		label, err := p.ident()
+		if err != nil {
+			return err
+		}
+		tok := p.next()
+		op, ok := queryOperators[tok]
+		if !ok {
+			return fmt.Errorf("%s: unknown operator %q", queryError, tok)
+		}
+		value := p.next()
+		if value == "" {
+			return fmt.Errorf("%s: missing value after %s %s", queryError, label, tok)
+		}
+		if strings.HasPrefix(value, "'") {
+			value = strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+		}
+		m, err := getFilter([]string{label}, []string{label + " " + op + " " + value})
+		if err != nil {
+			return fmt.Errorf("%s: %w", queryError, err)
+		}
+		q.where = append(q.where, queryCondition{label: label, filter: m[label]})
+		if !p.keyword("and") {
+			return nil
+		}
+	}
+}
+
+// isQueryKeyword reports whether the token is a reserved word of queries.
+func isQueryKeyword(tok string) bool {
+	switch strings.ToLower(tok) {
+	case "select", "from", "where", "and", "group", "order", "by", "limit", "as", "asc", "desc":
+		return true
+	}
+	return false
+}
+
+func isQueryIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isQueryIdentPart(r rune) bool {
+	return r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// tokenizeQuery splits the query into identifiers, numbers, quoted strings and symbols.
+// Quoted strings keep their quotes, so that they are never taken as keywords.
+func tokenizeQuery(query string) ([]string, error) {
+	var tokens []string
+	rs := []rune(query)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'':
+			j := i + 1
+			for ; j < len(rs); j++ {
+				if rs[j] != '\'' {
+					continue
+				}
+				if j+1 < len(rs) && rs[j+1] == '\'' {
+					j++
+					continue
+				}
+				break
+			}
+			if j >= len(rs) {
+				return nil, fmt.Errorf("%s: unterminated string", queryError)
+			}
+			tokens = append(tokens, string(rs[i:j+1]))
+			i = j + 1
+		case isQueryIdentStart(r):
+			j := i + 1
+			for j < len(rs) && isQueryIdentPart(rs[j]) {
+				j++
+			}
+			tokens = append(tokens, string(rs[i:j]))
+			i = j
+		case unicode.IsDigit(r) || r == '-' && i+1 < len(rs) && unicode.IsDigit(rs[i+1]):
+			j := i + 1
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, string(rs[i:j]))
+			i = j
+		case strings.ContainsRune(",()*", r):
+			tokens = append(tokens, string(r))
+			i++
+		case strings.ContainsRune("=!<>~", r):
+			j := i + 1
+			for j < len(rs) && strings.ContainsRune("=!<>~", rs[j]) {
+				j++
+			}
+			tokens = append(tokens, string(rs[i:j]))
+			i = j
+		default:
+			return nil, fmt.Errorf("%s: unexpected character %q", queryError, r)
+		}
+	}
+	return tokens, nil
+}
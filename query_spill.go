@@ -0,0 +1,236 @@
+package parser
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// querySpillError is the error message prefix for failures to spill the groups of a query.
+const querySpillError = "cannot spill query groups"
+
+// spilledGroup is a group written to a spill file.
+type spilledGroup struct {
+	Key  string
+	Seq  int
+	Keys []string
+	Aggs []spilledAggregator
+}
+
+// spilledAggregator is the partial aggregate of a column in a spilled group.
+type spilledAggregator struct {
+	Count    int
+	Sum      float64
+	Numeric  int
+	Min, Max string
+}
+
+// groupSize returns the estimated memory of a group with the key and the number of columns: the key is held
+// both in the map and in the order of appearance, and each column has an aggregator.
+func groupSize(key string, columns int) int64 {
+	return int64(2*len(key) + 96 + 64*columns)
+}
+
+// spill writes the groups in memory to a new spill file in the order of their keys, and clears them.
+func (q *sqlQuery) spill() error {
+	f, err := os.CreateTemp(q.spillDir, "query-spill-*")
+	if err != nil {
+		return fmt.Errorf("%s: %w", querySpillError, err)
+	}
+	q.runs = append(q.runs, f.Name())
+	keys := make([]string, len(q.order))
+	copy(keys, q.order)
+	sort.Strings(keys)
+	bw := bufio.NewWriter(f)
+	enc := gob.NewEncoder(bw)
+	for _, k := range keys {
+		g := q.groups[k]
+		sg := spilledGroup{Key: k, Seq: g.seq, Keys: g.keys, Aggs: make([]spilledAggregator, len(g.aggs))}
+		for i, a := range g.aggs {
+			sg.Aggs[i] = spilledAggregator{Count: a.count, Sum: a.sum, Numeric: a.numeric, Min: a.min, Max: a.max}
+		}
+		if err := enc.Encode(sg); err != nil {
+			f.Close()
+			return fmt.Errorf("%s: %w", querySpillError, err)
+		}
+	}
+	if err := errors.Join(bw.Flush(), f.Close()); err != nil {
+		return fmt.Errorf("%s: %w", querySpillError, err)
+	}
+	q.groups = map[string]*queryGroup{}
+	q.order = nil
+	q.mem = 0
+	return nil
+}
+
+// removeRuns removes the spill files.
+func (q *sqlQuery) removeRuns() {
+	for _, path := range q.runs {
+		os.Remove(path)
+	}
+}
+
+// spillRun is a spill file being merged, with the group read last.
+type spillRun struct {
+	dec *gob.Decoder
+	g   spilledGroup
+}
+
+// spillHeap orders the runs by the key of their current group.
+type spillHeap []*spillRun
+
+func (h spillHeap) Len() int           { return len(h) }
+func (h spillHeap) Less(i, j int) bool { return h[i].g.Key < h[j].g.Key }
+func (h spillHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *spillHeap) Push(x any)        { *h = append(*h, x.(*spillRun)) }
+func (h *spillHeap) Pop() any {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
+
+// advance reads the next group of the first run, and drops the run at its end.
+func (h *spillHeap) advance() error {
+	ok, err := (*h)[0].next()
+	if err != nil {
+		return err
+	}
+	if ok {
+		heap.Fix(h, 0)
+	} else {
+		heap.Pop(h)
+	}
+	return nil
+}
+
+// seqRow is a row with the order of first appearance of its group.
+type seqRow struct {
+	seq int
+	row []string
+}
+
+// merge spills the groups left in memory and merges the spill files into rows, combining the partial aggregates
+// of the same group. The rows are returned in the order of first appearance of their groups, and with ORDER BY
+// and LIMIT, only the rows that may be in the top are kept.
+func (q *sqlQuery) merge() ([][]string, error) {
+	if len(q.order) > 0 {
+		if err := q.spill(); err != nil {
+			return nil, err
+		}
+	}
+	h := make(spillHeap, 0, len(q.runs))
+	for _, path := range q.runs {
+		f, err := os.Open(filepath.Clean(path))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", querySpillError, err)
+		}
+		defer f.Close()
+		r := &spillRun{dec: gob.NewDecoder(bufio.NewReader(f))}
+		if ok, err := r.next(); err != nil {
+			return nil, err
+		} else if ok {
+			h = append(h, r)
+		}
+	}
+	heap.Init(&h)
+	keep := -1
+	if q.limit >= 0 && len(q.orderBy) > 0 {
+		keep = q.limit
+	}
+	var rows []seqRow
+	for h.Len() > 0 {
+		g := h[0].g
+		if err := h.advance(); err != nil {
+			return nil, err
+		}
+		for h.Len() > 0 && h[0].g.Key == g.Key {
+			g = combineSpilled(g, h[0].g)
+			if err := h.advance(); err != nil {
+				return nil, err
+			}
+		}
+		rows = append(rows, seqRow{seq: g.Seq, row: q.row(g.group())})
+		if keep >= 0 && len(rows) >= 2*keep+1024 {
+			rows = q.top(rows, keep)
+		}
+	}
+	if keep >= 0 {
+		rows = q.top(rows, keep)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].seq < rows[j].seq })
+	out := make([][]string, len(rows))
+	for i, r := range rows {
+		out[i] = r.row
+	}
+	return out, nil
+}
+
+// top returns the first n rows by the keys of ORDER BY, with ties in the order of first appearance.
+func (q *sqlQuery) top(rows []seqRow, n int) []seqRow {
+	sort.Slice(rows, func(i, j int) bool {
+		if q.less(rows[i].row, rows[j].row) {
+			return true
+		}
+		if q.less(rows[j].row, rows[i].row) {
+			return false
+		}
+		return rows[i].seq < rows[j].seq
+	})
+	if n < len(rows) {
+		rows = rows[:n]
+	}
+	return rows
+}
+
+// next reads the next group of the run, and reports whether there is one.
+func (r *spillRun) next() (bool, error) {
+	r.g = spilledGroup{}
+	if err := r.dec.Decode(&r.g); err != nil {
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		return false, fmt.Errorf("%s: %w", querySpillError, err)
+	}
+	return true, nil
+}
+
+// group returns the spilled group as a group in memory.
+func (g spilledGroup) group() *queryGroup {
+	qg := &queryGroup{keys: g.Keys, aggs: make([]queryAggregator, len(g.Aggs)), seq: g.Seq}
+	for i, a := range g.Aggs {
+		qg.aggs[i] = queryAggregator{count: a.Count, sum: a.Sum, numeric: a.Numeric, min: a.Min, max: a.Max}
+	}
+	return qg
+}
+
+// combineSpilled combines the partial aggregates of the same group from two spill files.
+func combineSpilled(a, b spilledGroup) spilledGroup {
+	aggs := make([]spilledAggregator, len(a.Aggs))
+	for i := range a.Aggs {
+		x, y := a.Aggs[i], b.Aggs[i]
+		switch {
+		case y.Count == 0:
+			aggs[i] = x
+			continue
+		case x.Count == 0:
+			aggs[i] = y
+			continue
+		}
+		c := spilledAggregator{Count: x.Count + y.Count, Sum: x.Sum + y.Sum, Numeric: x.Numeric + y.Numeric, Min: x.Min, Max: x.Max}
+		if compareQueryValues(y.Min, c.Min) < 0 {
+			c.Min = y.Min
+		}
+		if compareQueryValues(y.Max, c.Max) > 0 {
+			c.Max = y.Max
+		}
+		aggs[i] = c
+	}
+	return spilledGroup{Key: a.Key, Seq: min(a.Seq, b.Seq), Keys: a.Keys, Aggs: aggs}
+}
@@ -0,0 +1,190 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	input := strings.Join([]string{
+		"host:a\tstatus:200\tsize:100\turi:/",
+		"host:b\tstatus:500\tsize:10\turi:/api/users",
+		"host:a\tstatus:503\tsize:20\turi:/api/items",
+		"host:c\tstatus:404\tsize:-\turi:/favicon.ico",
+		"host:b\tstatus:502\tsize:30\turi:/api/users",
+		"host:b\tstatus:200\tsize:900\turi:/",
+	}, "\n")
+	source := func(ctx context.Context, w io.Writer, opt Option) (*Result, error) {
+		return NewLTSVParser(ctx, w, opt).ParseString(input)
+	}
+	tests := []struct {
+		name    string
+		query   string
+		columns []string
+		rows    [][]string
+	}{
+		{
+			name:    "group by with order and limit",
+			query:   "SELECT host, count(*) FROM log WHERE status >= 500 GROUP BY host ORDER BY 2 DESC LIMIT 1",
+			columns: []string{"host", "count(*)"},
+			rows:    [][]string{{"b", "2"}},
+		},
+		{
+			name:    "aggregates with aliases",
+			query:   "select host, sum(size) as bytes, avg(size) as mean, min(status), max(status), count(size) from log group by host order by bytes desc, host",
+			columns: []string{"host", "bytes", "mean", "min(status)", "max(status)", "count(size)"},
+			rows: [][]string{
+				{"b", "940", "313.3333333333333", "200", "502", "3"},
+				{"a", "120", "60", "200", "503", "2"},
+				{"c", "0", "", "404", "404", "0"},
+			},
+		},
+		{
+			name:    "aggregate without group by",
+			query:   "SELECT count(*) AS n FROM log WHERE uri =~ '^/api/' AND host != a",
+			columns: []string{"n"},
+			rows:    [][]string{{"2"}},
+		},
+		{
+			name:    "plain rows with limit",
+			query:   "SELECT uri, status FROM log WHERE status = 200 LIMIT 1",
+			columns: []string{"uri", "status"},
+			rows:    [][]string{{"/", "200"}},
+		},
+		{
+			name:    "star ordered by column",
+			query:   "SELECT * FROM log WHERE size > 25 ORDER BY size",
+			columns: []string{"host", "status", "size", "uri"},
+			rows: [][]string{
+				{"b", "502", "30", "/api/users"},
+				{"a", "200", "100", "/"},
+				{"b", "200", "900", "/"},
+			},
+		},
+		{
+			name:    "missing label does not match",
+			query:   "SELECT count(*) FROM log WHERE country = JP",
+			columns: []string{"count(*)"},
+			rows:    [][]string{{"0"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Query(context.Background(), source, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Columns, tt.columns) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Columns, tt.columns)
+			}
+			if !reflect.DeepEqual(got.Rows, tt.rows) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Rows, tt.rows)
+			}
+		})
+	}
+}
+
+func TestQuery_limitStopsParse(t *testing.T) {
+	input := strings.Repeat("a:1\n", 1000)
+	source := func(ctx context.Context, w io.Writer, opt Option) (*Result, error) {
+		return NewLTSVParser(ctx, w, opt).ParseString(input)
+	}
+	got, err := Query(context.Background(), source, "SELECT a FROM log LIMIT 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Rows) != 3 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", len(got.Rows), 3)
+	}
+	if !got.Result.Cancelled || got.Result.Total >= 1000 {
+		t.Errorf("\ngot:\n%v %v\nwant:\n%v %v\n", got.Result.Cancelled, got.Result.Total, true, "< 1000")
+	}
+}
+
+func TestQueryWith_spill(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&b, "uri:/items/%d\tstatus:%d\tsize:%d\n", i%97, 200+i%3*100, i)
+	}
+	input := b.String()
+	source := func(ctx context.Context, w io.Writer, opt Option) (*Result, error) {
+		return NewLTSVParser(ctx, w, opt).ParseString(input)
+	}
+	queries := []string{
+		"SELECT uri, count(*), sum(size), min(status), max(status) FROM log GROUP BY uri",
+		"SELECT uri, count(*) AS n, max(size) FROM log WHERE status >= 300 GROUP BY uri ORDER BY n DESC, 3 LIMIT 5",
+		"SELECT count(*), avg(size) FROM log",
+	}
+	for _, query := range queries {
+		t.Run(query, func(t *testing.T) {
+			want, err := Query(context.Background(), source, query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := t.TempDir()
+			got, err := QueryWith(context.Background(), source, query, QueryConfig{MemoryBudget: 2048, SpillDir: dir})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Rows, want.Rows) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Rows, want.Rows)
+			}
+			if strings.Contains(query, "GROUP BY") && got.Spills == 0 {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got.Spills, "> 0")
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", len(entries), 0)
+			}
+		})
+	}
+}
+
+func TestQuery_error(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{name: "no select", query: "count(*) FROM log", want: "expected SELECT"},
+		{name: "no from", query: "SELECT a", want: "expected FROM"},
+		{name: "unknown function", query: "SELECT median(a) FROM log", want: `unknown function "median"`},
+		{name: "unknown operator", query: "SELECT a FROM log WHERE a ~ b", want: `unknown operator "~"`},
+		{name: "invalid regex", query: "SELECT a FROM log WHERE a =~ '('", want: "invalid query"},
+		{name: "not grouped", query: "SELECT a, count(*) FROM log", want: `"a" must appear in GROUP BY`},
+		{name: "star with aggregate", query: "SELECT * FROM log GROUP BY a", want: "* cannot be selected"},
+		{name: "bad limit", query: "SELECT a FROM log LIMIT x", want: "LIMIT"},
+		{name: "unknown order key", query: "SELECT a FROM log ORDER BY b", want: `ORDER BY "b"`},
+		{name: "order position", query: "SELECT a FROM log ORDER BY 2", want: "out of range"},
+		{name: "trailing tokens", query: "SELECT a FROM log LIMIT 1 2", want: `unexpected "2"`},
+		{name: "unterminated string", query: "SELECT a FROM log WHERE a = 'x", want: "unterminated"},
+	}
+	source := func(ctx context.Context, w io.Writer, opt Option) (*Result, error) {
+		return NewLTSVParser(ctx, w, opt).ParseString("a:1")
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Query(context.Background(), source, tt.query)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryResult_Write(t *testing.T) {
+	q := &QueryResult{Columns: []string{"host", "n"}, Rows: [][]string{{"a", "1"}, {"b", "2"}}}
+	buf := &bytes.Buffer{}
+	if err := q.Write(buf, JSONLineHandler); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"host":"a","n":"1"}` + "\n" + `{"host":"b","n":"2"}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
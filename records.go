@@ -0,0 +1,37 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"slices"
+)
+
+// errStopRecords stops the parse when the consumer of a record iterator stops iterating.
+var errStopRecords = errors.New("record iteration stopped")
+
+// RecordSeq is an iterator over decoded records, with the same shape as iter.Seq2[Record, error] so that it can
+// be ranged over from Go 1.23. An error, if any, is yielded last with an empty record.
+type RecordSeq func(yield func(Record, error) bool)
+
+// records returns an iterator over the records that the line handler would receive, that is, after filters,
+// enrichers and label selection. Unmatched lines are not yielded, and the output, routes and heartbeats are
+// not used. The input is parsed as the iterator is consumed, and stopping the iteration stops the parse.
+// This function is used as an internal process of the Records method.
+func records(ctx context.Context, input io.Reader, patterns []*regexp.Regexp, decoder lineDecoder, opt Option) RecordSeq {
+	return func(yield func(Record, error) bool) {
+		opt.LineHandler = func(labels, values []string, _ bool) (string, error) {
+			if !yield(Record{Labels: slices.Clone(labels), Values: slices.Clone(values)}, nil) {
+				return "", errStopRecords
+			}
+			return "", nil
+		}
+		opt.Routes = nil
+		opt.Heartbeat = 0
+		_, err := parser(ctx, input, io.Discard, patterns, decoder, opt)
+		if err != nil && !errors.Is(err, errStopRecords) {
+			yield(Record{}, err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+//go:build go1.23
+
+package parser
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"iter"
+)
+
+// EntrySource is a zip entry yielded by Entries. It is valid until the iteration moves on to the next entry.
+type EntrySource struct {
+	Name  string // name of the entry, decoded with Option.ZipNameEncoding if not flagged as UTF-8
+	file  *zip.File
+	lines func(ctx context.Context, r io.Reader) iter.Seq2[Record, error]
+	err   error
+}
+
+// Err returns the error that stopped the listing of the entries, such as a zip file that cannot be opened,
+// in which case it is the only entry yielded and has no name.
+func (e EntrySource) Err() error {
+	return e.err
+}
+
+// Open opens the entry for reading.
+func (e EntrySource) Open() (io.ReadCloser, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	rc, err := e.file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", openFileError, err)
+	}
+	return rc, nil
+}
+
+// Lines returns an iterator over the records decoded from the entry, as the Lines method of the parser.
+func (e EntrySource) Lines(ctx context.Context) iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		rc, err := e.Open()
+		if err != nil {
+			yield(Record{}, err)
+			return
+		}
+		defer rc.Close()
+		for rec, err := range e.lines(ctx, rc) {
+			if !yield(rec, err) {
+				return
+			}
+		}
+	}
+}
+
+// entries returns an iterator over the entries of the zip file matching the glob pattern, keeping the file open
+// during the iteration. This function is used as an internal process of the Entries method.
+func entries(zipPath, globPattern string, opt Option, lines func(ctx context.Context, r io.Reader) iter.Seq2[Record, error]) iter.Seq[EntrySource] {
+	return func(yield func(EntrySource) bool) {
+		files, cleanup, err := openZipEntries(zipPath, globPattern, opt.ZipNameEncoding)
+		if err != nil {
+			yield(EntrySource{err: err})
+			return
+		}
+		defer cleanup()
+		for _, f := range files {
+			if !yield(EntrySource{Name: f.Name, file: f, lines: lines}) {
+				return
+			}
+		}
+	}
+}
+
+// Lines returns an iterator over the records decoded from r, as Records does, to be ranged over with
+// `for rec, err := range p.Lines(ctx, r)`. Breaking out of the loop stops the parse.
+func (p *RegexParser) Lines(ctx context.Context, r io.Reader) iter.Seq2[Record, error] {
+	return iter.Seq2[Record, error](records(ctx, r, p.patterns, p.lineDecoder, p.opt))
+}
+
+// Entries returns an iterator over the entries of the zip file matching the glob pattern, whose records are
+// ranged over with the Lines method of each entry.
+func (p *RegexParser) Entries(zipPath, globPattern string) iter.Seq[EntrySource] {
+	return entries(zipPath, globPattern, p.opt, p.Lines)
+}
+
+// Lines returns an iterator over the records decoded from r, as Records does, to be ranged over with
+// `for rec, err := range p.Lines(ctx, r)`. Breaking out of the loop stops the parse.
+func (p *LTSVParser) Lines(ctx context.Context, r io.Reader) iter.Seq2[Record, error] {
+	return iter.Seq2[Record, error](records(ctx, r, nil, p.lineDecoder, p.opt))
+}
+
+// Entries returns an iterator over the entries of the zip file matching the glob pattern, whose records are
+// ranged over with the Lines method of each entry.
+func (p *LTSVParser) Entries(zipPath, globPattern string) iter.Seq[EntrySource] {
+	return entries(zipPath, globPattern, p.opt, p.Lines)
+}
+
+// Lines returns an iterator over the records decoded from r, as Records does, to be ranged over with
+// `for rec, err := range p.Lines(ctx, r)`. Breaking out of the loop stops the parse.
+func (p *CSVParser) Lines(ctx context.Context, r io.Reader) iter.Seq2[Record, error] {
+	return iter.Seq2[Record, error](records(ctx, r, nil, p.lineDecoder, p.opt))
+}
+
+// Entries returns an iterator over the entries of the zip file matching the glob pattern, whose records are
+// ranged over with the Lines method of each entry.
+func (p *CSVParser) Entries(zipPath, globPattern string) iter.Seq[EntrySource] {
+	return entries(zipPath, globPattern, p.opt, p.Lines)
+}
+
+// Lines returns an iterator over the records decoded from r, as Records does, to be ranged over with
+// `for rec, err := range p.Lines(ctx, r)`. Breaking out of the loop stops the parse.
+func (p *JSONParser) Lines(ctx context.Context, r io.Reader) iter.Seq2[Record, error] {
+	return iter.Seq2[Record, error](records(ctx, r, nil, p.lineDecoder, p.opt))
+}
+
+// Entries returns an iterator over the entries of the zip file matching the glob pattern, whose records are
+// ranged over with the Lines method of each entry.
+func (p *JSONParser) Entries(zipPath, globPattern string) iter.Seq[EntrySource] {
+	return entries(zipPath, globPattern, p.opt, p.Lines)
+}
@@ -0,0 +1,75 @@
+//go:build go1.23
+
+package parser
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLTSVParser_Lines(t *testing.T) {
+	input := "host:a\tstatus:200\nbroken line\nhost:b\tstatus:500\nhost:c\tstatus:404\n"
+	p := NewLTSVParser(context.Background(), nil, Option{Filters: []string{"status >= 400"}})
+	var got []string
+	for rec, err := range p.Lines(context.Background(), strings.NewReader(input)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, rec.Values[0])
+	}
+	if want := []string{"b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+
+	got = nil
+	for rec := range NewLTSVParser(context.Background(), nil, Option{}).Lines(context.Background(), strings.NewReader(input)) {
+		got = append(got, rec.Values[0])
+		break
+	}
+	if want := []string{"a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+
+	bad := NewLTSVParser(context.Background(), nil, Option{Filters: []string{"status"}})
+	for _, err := range bad.Lines(context.Background(), strings.NewReader(input)) {
+		if err == nil || !strings.Contains(err.Error(), "invalid syntax") {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "invalid syntax")
+		}
+	}
+}
+
+func TestRegexParser_Entries(t *testing.T) {
+	zipPath := writeZip(t, []string{"a.log", "b.log", "c.txt"}, []string{"GET /a\nGET /b\n", "POST /c\n", "GET /d\n"})
+	p := NewRegexParser(context.Background(), nil, Option{})
+	if err := p.AddPattern(`^(?P<method>\S+) (?P<path>\S+)$`); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for e := range p.Entries(zipPath, "*.log") {
+		if e.Err() != nil {
+			t.Fatal(e.Err())
+		}
+		for rec, err := range e.Lines(context.Background()) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, e.Name+":"+rec.Values[1])
+		}
+	}
+	if want := []string{"a.log:/a", "a.log:/b", "b.log:/c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+
+	for e := range p.Entries("missing.zip", "*") {
+		if e.Err() == nil {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", e.Err(), "error")
+		}
+		for _, err := range e.Lines(context.Background()) {
+			if err == nil {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "error")
+			}
+		}
+	}
+}
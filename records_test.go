@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLTSVParser_Records(t *testing.T) {
+	input := strings.Join([]string{
+		"host:a\tstatus:200\tpath:/x",
+		"broken line",
+		"host:b\tstatus:500\tpath:/y",
+		"host:c\tstatus:404\tpath:/z",
+	}, "\n")
+	tests := []struct {
+		name    string
+		opt     Option
+		stop    int
+		want    []Record
+		wantErr string
+	}{
+		{
+			name: "all",
+			opt:  Option{},
+			want: []Record{
+				{Labels: []string{"host", "status", "path"}, Values: []string{"a", "200", "/x"}},
+				{Labels: []string{"host", "status", "path"}, Values: []string{"b", "500", "/y"}},
+				{Labels: []string{"host", "status", "path"}, Values: []string{"c", "404", "/z"}},
+			},
+		},
+		{
+			name: "filters and labels",
+			opt:  Option{Filters: []string{"status >= 400"}, Labels: []string{"path"}, LineNumber: true},
+			want: []Record{
+				{Labels: []string{"no", "path"}, Values: []string{"3", "/y"}},
+				{Labels: []string{"no", "path"}, Values: []string{"4", "/z"}},
+			},
+		},
+		{
+			name: "stop",
+			opt:  Option{},
+			stop: 1,
+			want: []Record{
+				{Labels: []string{"host", "status", "path"}, Values: []string{"a", "200", "/x"}},
+			},
+		},
+		{
+			name:    "error",
+			opt:     Option{Filters: []string{"status"}},
+			wantErr: "invalid syntax",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewLTSVParser(context.Background(), nil, tt.opt)
+			var got []Record
+			var err error
+			p.Records(strings.NewReader(input))(func(r Record, e error) bool {
+				if e != nil {
+					err = e
+					return false
+				}
+				got = append(got, r)
+				return tt.stop == 0 || len(got) < tt.stop
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegexParser_Records(t *testing.T) {
+	p := NewRegexParser(context.Background(), nil, Option{})
+	if err := p.AddPattern(`^(?P<method>\S+) (?P<path>\S+)$`); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	p.Records(strings.NewReader("GET /a\nPOST /b\n"))(func(r Record, err error) bool {
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, _ := r.Get("path")
+		got = append(got, v)
+		return true
+	})
+	if want := []string{"/a", "/b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"regexp"
+	"sync/atomic"
+	"syscall"
+)
+
+// ReloadConfig is a configuration applied to a running parser by Reloader. Nil fields keep the current settings.
+type ReloadConfig struct {
+	Filters     []string    // replaces Option.Filters
+	Labels      []string    // replaces Option.Labels
+	Patterns    []string    // replaces the patterns of RegexParser (ignored by parsers without patterns)
+	LineHandler LineHandler // replaces Option.LineHandler
+}
+
+// Reload stores information about a configuration applied while parsing.
+type Reload struct {
+	LineNumber int         `json:"lineNumber"` // Line number of the first line parsed with the configuration.
+	Transforms []Transform `json:"transforms"` // Steps applied to the records from the line.
+}
+
+// Reloader delivers configuration changes to a long-running streaming parser, which applies them at
+// the next line boundary without dropping the input stream, and records the switch in Result.Reloads.
+// Set it to Option.Reloader. It is meant for Parse; in batch parsing of zip entries, a change is applied
+// to only one of the entries.
+type Reloader struct {
+	pending atomic.Pointer[reloadRequest]
+}
+
+// reloadRequest is a validated configuration waiting for the next line boundary.
+type reloadRequest struct {
+	cfg      ReloadConfig
+	patterns []*regexp.Regexp
+}
+
+// NewReloader creates a Reloader.
+func NewReloader() *Reloader {
+	return &Reloader{}
+}
+
+// Reload validates the configuration and schedules it to be applied at the next line boundary.
+// An invalid configuration is rejected with an error, and the parser keeps the current settings.
+// If called again before the change is applied, the latest configuration wins. Since the label "no" of filters
+// is the line number only with Option.LineNumber, which is not known here, filters valid on either are accepted,
+// and a filter invalid for the parser stops it when applied.
+func (r *Reloader) Reload(cfg ReloadConfig) error {
+	if err := validateOptionFilters(cfg.Filters, true); err != nil && validateOptionFilters(cfg.Filters, false) != nil {
+		return err
+	}
+	req := &reloadRequest{cfg: cfg}
+	if cfg.Patterns != nil {
+		req.patterns = make([]*regexp.Regexp, 0, len(cfg.Patterns))
+		for _, pattern := range cfg.Patterns {
+			ptn, err := compilePattern(pattern)
+			if err != nil {
+				return err
+			}
+			req.patterns = append(req.patterns, ptn)
+		}
+	}
+	r.pending.Store(req)
+	return nil
+}
+
+// ReloadOnSignal calls load and reloads the configuration it returns each time the process receives SIGHUP,
+// until ctx is done or the returned function is called. Errors from load or Reload are passed to onError
+// if it is not nil, and the parser keeps the current settings.
+func (r *Reloader) ReloadOnSignal(ctx context.Context, load func() (ReloadConfig, error), onError func(error)) func() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ch:
+				cfg, err := load()
+				if err == nil {
+					err = r.Reload(cfg)
+				}
+				if err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		cancel()
+		<-done
+	}
+}
+
+// take returns the pending configuration, if any, and clears it. A nil Reloader has nothing pending.
+func (r *Reloader) take() *reloadRequest {
+	if r == nil {
+		return nil
+	}
+	return r.pending.Swap(nil)
+}
+
+// apply returns the option and patterns with the configuration applied.
+func (req *reloadRequest) apply(opt Option, patterns []*regexp.Regexp) (Option, []*regexp.Regexp) {
+	if req.cfg.Filters != nil {
+		opt.Filters = req.cfg.Filters
+	}
+	if req.cfg.Labels != nil {
+		opt.Labels = req.cfg.Labels
+	}
+	if req.cfg.LineHandler != nil {
+		opt.LineHandler = req.cfg.LineHandler
+	}
+	if req.patterns != nil && len(patterns) > 0 {
+		patterns = req.patterns
+	}
+	return opt, patterns
+}
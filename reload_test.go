@@ -0,0 +1,103 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReloader_Reload(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ReloadConfig
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			cfg:     ReloadConfig{Filters: []string{"status == 200"}, Patterns: []string{`^(?P<status>\d+)$`}},
+			wantErr: false,
+		},
+		{
+			name:    "invalid filter",
+			cfg:     ReloadConfig{Filters: []string{"status"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid pattern",
+			cfg:     ReloadConfig{Patterns: []string{`^(\d+)$`}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReloader()
+			err := r.Reload(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if got := r.take() != nil; got == tt.wantErr {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, !tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReloader_parse(t *testing.T) {
+	reloader := NewReloader()
+	handler := func(labels, values []string, isFirst bool) (string, error) {
+		if err := reloader.Reload(ReloadConfig{
+			Filters:     []string{"status != 500"},
+			Labels:      []string{"status"},
+			Patterns:    []string{`^(?P<host>\S+) (?P<status>\d+)$`},
+			LineHandler: KeyValuePairLineHandler,
+		}); err != nil {
+			return "", err
+		}
+		return JSONLineHandler(labels, values, isFirst)
+	}
+	buf := &bytes.Buffer{}
+	p := NewRegexParser(context.Background(), buf, Option{
+		Filters:     []string{"host == a"},
+		LineHandler: handler,
+		Reloader:    reloader,
+	})
+	if err := p.AddPattern(`^(?P<host>\S+) (?P<status>\d+) (?P<size>\d+)$`); err != nil {
+		t.Fatal(err)
+	}
+	input := strings.Join([]string{"a 200 10", "b 200", "c 500", "d 404 5"}, "\n")
+	r, err := p.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		`{"host":"a","status":"200","size":"10"}`,
+		`status="200"`,
+	}, "\n") + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+	if len(r.Reloads) != 1 || r.Reloads[0].LineNumber != 2 {
+		t.Fatalf("switch must be noted at the line boundary: %+v", r.Reloads)
+	}
+	var steps []string
+	for _, x := range r.Reloads[0].Transforms {
+		steps = append(steps, x.Step+":"+x.Config)
+	}
+	wantSteps := []string{
+		`decode:["^(?P<host>\\S+) (?P<status>\\d+)$"]`,
+		`filter:["status != 500"]`,
+		`select:["status"]`,
+		`handler:"github.com/nekrassov01/access-log-parser.KeyValuePairLineHandler"`,
+	}
+	if !reflect.DeepEqual(steps, wantSteps) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", steps, wantSteps)
+	}
+	if r.Matched != 2 || r.Unmatched != 1 || r.Excluded != 1 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", []int{r.Matched, r.Unmatched, r.Excluded}, []int{2, 1, 1})
+	}
+	if !strings.Contains(r.String(), "Configuration reloaded from line 2") {
+		t.Errorf("switch must be noted in the summary:\n%s", r.String())
+	}
+}
@@ -0,0 +1,38 @@
+//go:build unix
+
+package parser
+
+import (
+	"context"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReloader_ReloadOnSignal(t *testing.T) {
+	r := NewReloader()
+	loaded := make(chan struct{}, 1)
+	stop := r.ReloadOnSignal(context.Background(), func() (ReloadConfig, error) {
+		defer func() { loaded <- struct{}{} }()
+		return ReloadConfig{Filters: []string{"status == 200"}}, nil
+	}, nil)
+	defer stop()
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-loaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("configuration not loaded on SIGHUP")
+	}
+	// Reload is called right after load returns
+	deadline := time.Now().Add(5 * time.Second)
+	for r.pending.Load() == nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	req := r.take()
+	if req == nil || !reflect.DeepEqual(req.cfg.Filters, []string{"status == 200"}) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", req, []string{"status == 200"})
+	}
+}
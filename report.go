@@ -0,0 +1,159 @@
+package parser
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ReportFormat represents the format of the report written by Result.WriteReport.
+type ReportFormat int
+
+const (
+	ReportJSON       ReportFormat = iota // single JSON object (default)
+	ReportYAML                           // YAML mapping
+	ReportPrometheus                     // Prometheus text exposition format for the textfile collector of node_exporter
+)
+
+// String returns the name of the report format.
+func (f ReportFormat) String() string {
+	switch f {
+	case ReportYAML:
+		return "yaml"
+	case ReportPrometheus:
+		return "prometheus-textfile"
+	default:
+		return "json"
+	}
+}
+
+// reportMetricPrefix is the prefix of the names of the metrics in Prometheus reports.
+const reportMetricPrefix = "access_log_parser_"
+
+// Report summarizes a parsing result for machines, such as monitoring of scheduled parses, without the
+// unmatched lines themselves.
+type Report struct {
+	Source         string  `json:"source"`         // Source of the log data.
+	Total          int     `json:"total"`          // Total number of processed lines.
+	Matched        int     `json:"matched"`        // Count of lines that matched the patterns.
+	Unmatched      int     `json:"unmatched"`      // Count of lines that did not match any patterns.
+	Excluded       int     `json:"excluded"`       // Count of lines excluded based on keyword search.
+	Skipped        int     `json:"skipped"`        // Count of lines skipped explicitly.
+	MatchRate      float64 `json:"matchRate"`      // Ratio of matched lines to processed lines, excluding skipped lines.
+	ElapsedSeconds float64 `json:"elapsedSeconds"` // Processing time in seconds.
+	ZipEntries     int     `json:"zipEntries"`     // Number of processed zip entries.
+	Abandoned      int     `json:"abandoned"`      // Number of sources given up before the end.
+	Cancelled      bool    `json:"cancelled"`      // Whether parsing was cancelled before the end of input.
+}
+
+// Report returns the summary of the result.
+func (r *Result) Report() Report {
+	return Report{
+		Source:         r.Source,
+		Total:          r.Total,
+		Matched:        r.Matched,
+		Unmatched:      r.Unmatched,
+		Excluded:       r.Excluded,
+		Skipped:        r.Skipped,
+		MatchRate:      matchRate(r),
+		ElapsedSeconds: r.ElapsedTime.Seconds(),
+		ZipEntries:     len(r.ZipEntries),
+		Abandoned:      len(r.Abandoned),
+		Cancelled:      r.Cancelled,
+	}
+}
+
+// WriteReport writes the summary of the result to w in the format, for cron-driven parses to leave a report
+// for other tools. With ReportPrometheus, the metrics are gauges labeled with the source; write each source to
+// its own file in the directory of the textfile collector, through a temporary file renamed into place so
+// that node_exporter never reads a partial file.
+func (r *Result) WriteReport(w io.Writer, format ReportFormat) error {
+	rp := r.Report()
+	bw := bufio.NewWriter(w)
+	switch format {
+	case ReportJSON:
+		b, err := json.Marshal(rp)
+		if err != nil {
+			return fmt.Errorf("%s: %w", reportError, err)
+		}
+		bw.Write(b)
+		bw.WriteByte('\n')
+	case ReportYAML:
+		rp.writeYAML(bw)
+	case ReportPrometheus:
+		rp.writePrometheus(bw)
+	default:
+		return fmt.Errorf("%s: unknown report format %d", reportError, format)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("%s: %w", reportError, err)
+	}
+	return nil
+}
+
+// writeYAML writes the report as a YAML mapping keyed by the JSON names of the fields.
+func (rp Report) writeYAML(w *bufio.Writer) {
+	v := reflect.ValueOf(rp)
+	for i := 0; i < v.NumField(); i++ {
+		w.WriteString(v.Type().Field(i).Tag.Get("json"))
+		w.WriteString(": ")
+		switch f := v.Field(i); f.Kind() {
+		case reflect.String:
+			// A JSON string is also a valid double-quoted YAML scalar.
+			b, _ := json.Marshal(f.String())
+			w.Write(b)
+		case reflect.Int:
+			w.WriteString(strconv.FormatInt(f.Int(), 10))
+		case reflect.Float64:
+			w.WriteString(strconv.FormatFloat(f.Float(), 'g', -1, 64))
+		case reflect.Bool:
+			w.WriteString(strconv.FormatBool(f.Bool()))
+		}
+		w.WriteByte('\n')
+	}
+}
+
+// writePrometheus writes the report in the Prometheus text exposition format.
+func (rp Report) writePrometheus(w *bufio.Writer) {
+	source := `source="` + escapeLabelValue(rp.Source) + `"`
+	help := func(name, text string) {
+		fmt.Fprintf(w, "# HELP %s%s %s\n# TYPE %s%s gauge\n", reportMetricPrefix, name, text, reportMetricPrefix, name)
+	}
+	sample := func(name, labels, value string) {
+		fmt.Fprintf(w, "%s%s{%s} %s\n", reportMetricPrefix, name, labels, value)
+	}
+	help("lines", "Number of log lines of the last run, by kind.")
+	for _, x := range []struct {
+		kind string
+		n    int
+	}{
+		{"total", rp.Total},
+		{"matched", rp.Matched},
+		{"unmatched", rp.Unmatched},
+		{"excluded", rp.Excluded},
+		{"skipped", rp.Skipped},
+	} {
+		sample("lines", source+`,kind="`+x.kind+`"`, strconv.Itoa(x.n))
+	}
+	help("match_ratio", "Ratio of matched lines to processed lines of the last run, excluding skipped lines.")
+	sample("match_ratio", source, strconv.FormatFloat(rp.MatchRate, 'g', -1, 64))
+	help("elapsed_seconds", "Processing time of the last run in seconds.")
+	sample("elapsed_seconds", source, strconv.FormatFloat(rp.ElapsedSeconds, 'g', -1, 64))
+	help("abandoned_sources", "Number of sources given up before the end in the last run.")
+	sample("abandoned_sources", source, strconv.Itoa(rp.Abandoned))
+	cancelled := "0"
+	if rp.Cancelled {
+		cancelled = "1"
+	}
+	help("cancelled", "Whether the last run was cancelled before the end of input.")
+	sample("cancelled", source, cancelled)
+}
+
+// escapeLabelValue escapes a label value of the Prometheus text exposition format.
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestResult_WriteReport(t *testing.T) {
+	r := &Result{
+		Total:       10,
+		Matched:     6,
+		Unmatched:   2,
+		Excluded:    1,
+		Skipped:     2,
+		ElapsedTime: 1500 * time.Millisecond,
+		Source:      `app "a".log`,
+		Errors:      []Errors{{LineNumber: 3, Line: "foo"}, {LineNumber: 4, Line: "bar"}},
+		Abandoned:   []Abandoned{{Entry: "b.log", Reason: "timeout"}},
+	}
+	tests := []struct {
+		name    string
+		format  ReportFormat
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "json",
+			format: ReportJSON,
+			want:   `{"source":"app \"a\".log","total":10,"matched":6,"unmatched":2,"excluded":1,"skipped":2,"matchRate":0.75,"elapsedSeconds":1.5,"zipEntries":0,"abandoned":1,"cancelled":false}` + "\n",
+		},
+		{
+			name:   "yaml",
+			format: ReportYAML,
+			want: `source: "app \"a\".log"
+total: 10
+matched: 6
+unmatched: 2
+excluded: 1
+skipped: 2
+matchRate: 0.75
+elapsedSeconds: 1.5
+zipEntries: 0
+abandoned: 1
+cancelled: false
+`,
+		},
+		{
+			name:   "prometheus",
+			format: ReportPrometheus,
+			want: `# HELP access_log_parser_lines Number of log lines of the last run, by kind.
+# TYPE access_log_parser_lines gauge
+access_log_parser_lines{source="app \"a\".log",kind="total"} 10
+access_log_parser_lines{source="app \"a\".log",kind="matched"} 6
+access_log_parser_lines{source="app \"a\".log",kind="unmatched"} 2
+access_log_parser_lines{source="app \"a\".log",kind="excluded"} 1
+access_log_parser_lines{source="app \"a\".log",kind="skipped"} 2
+# HELP access_log_parser_match_ratio Ratio of matched lines to processed lines of the last run, excluding skipped lines.
+# TYPE access_log_parser_match_ratio gauge
+access_log_parser_match_ratio{source="app \"a\".log"} 0.75
+# HELP access_log_parser_elapsed_seconds Processing time of the last run in seconds.
+# TYPE access_log_parser_elapsed_seconds gauge
+access_log_parser_elapsed_seconds{source="app \"a\".log"} 1.5
+# HELP access_log_parser_abandoned_sources Number of sources given up before the end in the last run.
+# TYPE access_log_parser_abandoned_sources gauge
+access_log_parser_abandoned_sources{source="app \"a\".log"} 1
+# HELP access_log_parser_cancelled Whether the last run was cancelled before the end of input.
+# TYPE access_log_parser_cancelled gauge
+access_log_parser_cancelled{source="app \"a\".log"} 0
+`,
+		},
+		{
+			name:    "unknown format",
+			format:  ReportFormat(99),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			err := r.WriteReport(buf, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", buf.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"strings"
+)
+
+// labels of the fields added by WithRequestLine
+const (
+	requestMethodLabel   = "method"
+	requestPathLabel     = "path"
+	requestQueryLabel    = "query"
+	requestProtocolLabel = "protocol"
+	requestParamPrefix   = "q_"
+)
+
+// defaultRequestFields are the fields holding request lines or URIs, covering the labels of the built-in parsers.
+var defaultRequestFields = []string{"request", "request_uri"}
+
+// RequestLineConfig defines the fields split by WithRequestLine.
+type RequestLineConfig struct {
+	Fields      []string // fields holding request lines such as "GET /a?b=c HTTP/1.1", or URIs (nil means request and request_uri)
+	ExpandQuery bool     // whether to add the query parameters as fields named "q_" followed by the name, or not
+}
+
+// RequestLine is a request line split into its parts by SplitRequestLine. Parts not in the line are "-".
+type RequestLine struct {
+	Method   string // method, such as "GET"
+	Path     string // path, without the scheme and host of absolute targets
+	Query    string // query without "?"
+	Protocol string // protocol, such as "HTTP/1.1"
+}
+
+// SplitRequestLine splits a request line, such as "GET /a?b=c HTTP/1.1" of the Apache "%r" format, into the
+// method, path, query and protocol. A value without spaces is taken as a URI, such as the request_uri of NGINX.
+// Absolute targets such as "http://example.com:80/a", as written by ALB, are reduced to the path.
+func SplitRequestLine(s string) RequestLine {
+	rl := RequestLine{Method: "-", Path: "-", Query: "-", Protocol: "-"}
+	target := s
+	if fields := strings.Fields(s); len(fields) == 3 {
+		rl.Method, target, rl.Protocol = fields[0], fields[1], fields[2]
+	} else if len(fields) == 2 {
+		rl.Method, target = fields[0], fields[1]
+	} else if len(fields) != 1 || s == "-" {
+		return rl
+	}
+	if i := strings.Index(target, "://"); i > 0 && !strings.ContainsAny(target[:i], "/?") {
+		target = target[i+3:]
+		if j := strings.IndexAny(target, "/?"); j >= 0 {
+			target = target[j:]
+		} else {
+			target = "/"
+		}
+	}
+	path, query, ok := strings.Cut(target, "?")
+	if path == "" {
+		path = "/"
+	}
+	rl.Path = path
+	if ok {
+		rl.Query = query
+	}
+	return rl
+}
+
+// WithRequestLine returns an Enricher that splits the request line of the record into the fields "method",
+// "path", "query" and "protocol", for formats such as LTSV and the Apache and NGINX formats, where the request
+// line is captured as a single field. Existing fields of the same names are replaced. With ExpandQuery, each
+// query parameter is added as a field such as "q_page", percent-decoded, with repeated parameters joined by
+// commas. Since the parameters differ between records, expanded fields are best selected with Option.Labels
+// for TSV and CSV output. Records without a request line field are returned as is.
+func WithRequestLine(cfg RequestLineConfig) Enricher {
+	if cfg.Fields == nil {
+		cfg.Fields = defaultRequestFields
+	}
+	return func(r Record) (Record, error) {
+		for _, field := range cfg.Fields {
+			v, ok := r.Get(field)
+			if !ok {
+				continue
+			}
+			rl := SplitRequestLine(v)
+			r = r.With(requestMethodLabel, rl.Method).
+				With(requestPathLabel, rl.Path).
+				With(requestQueryLabel, rl.Query).
+				With(requestProtocolLabel, rl.Protocol)
+			if cfg.ExpandQuery && rl.Query != "-" {
+				r = expandQuery(r, rl.Query)
+			}
+			return r, nil
+		}
+		return r, nil
+	}
+}
+
+// expandQuery adds the parameters of the query to the record, in the order they first appear.
+func expandQuery(r Record, query string) Record {
+	var names []string
+	params := make(map[string][]string)
+	for _, param := range strings.Split(query, "&") {
+		name, value, _ := strings.Cut(param, "=")
+		name = percentDecode(strings.ReplaceAll(name, "+", " "))
+		if name == "" {
+			continue
+		}
+		if _, ok := params[name]; !ok {
+			names = append(names, name)
+		}
+		params[name] = append(params[name], percentDecode(strings.ReplaceAll(value, "+", " ")))
+	}
+	for _, name := range names {
+		r = r.With(requestParamPrefix+name, strings.Join(params[name], ","))
+	}
+	return r
+}
@@ -0,0 +1,66 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestSplitRequestLine(t *testing.T) {
+	tests := []struct {
+		s    string
+		want RequestLine
+	}{
+		{s: "GET /index.html?a=1&b=2 HTTP/1.1", want: RequestLine{Method: "GET", Path: "/index.html", Query: "a=1&b=2", Protocol: "HTTP/1.1"}},
+		{s: "GET http://www.example.com:80/a/b? HTTP/1.1", want: RequestLine{Method: "GET", Path: "/a/b", Query: "", Protocol: "HTTP/1.1"}},
+		{s: "GET https://www.example.com:443 HTTP/2.0", want: RequestLine{Method: "GET", Path: "/", Query: "-", Protocol: "HTTP/2.0"}},
+		{s: "GET /", want: RequestLine{Method: "GET", Path: "/", Query: "-", Protocol: "-"}},
+		{s: "/search?q=a+b", want: RequestLine{Method: "-", Path: "/search", Query: "q=a+b", Protocol: "-"}},
+		{s: "/redirect?to=http://example.com/", want: RequestLine{Method: "-", Path: "/redirect", Query: "to=http://example.com/", Protocol: "-"}},
+		{s: "-", want: RequestLine{Method: "-", Path: "-", Query: "-", Protocol: "-"}},
+		{s: "\x16\x03\x01 garbage with spaces", want: RequestLine{Method: "-", Path: "-", Query: "-", Protocol: "-"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			if got := SplitRequestLine(tt.s); got != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithRequestLine(t *testing.T) {
+	input := "request:GET /search?q=a+b%21&page=2&tag=x&tag=y&=z HTTP/1.1\tstatus:200\nrequest_uri:/a\tstatus:404\nstatus:500\n"
+	tests := []struct {
+		name string
+		cfg  RequestLineConfig
+		want string
+	}{
+		{
+			name: "default",
+			cfg:  RequestLineConfig{},
+			want: `{"request":"GET /search?q=a+b%21&page=2&tag=x&tag=y&=z HTTP/1.1","status":"200","method":"GET","path":"/search","query":"q=a+b%21&page=2&tag=x&tag=y&=z","protocol":"HTTP/1.1"}` + "\n" +
+				`{"request_uri":"/a","status":"404","method":"-","path":"/a","query":"-","protocol":"-"}` + "\n" +
+				`{"status":"500"}` + "\n",
+		},
+		{
+			name: "expand query",
+			cfg:  RequestLineConfig{Fields: []string{"request"}, ExpandQuery: true},
+			want: `{"request":"GET /search?q=a+b%21&page=2&tag=x&tag=y&=z HTTP/1.1","status":"200","method":"GET","path":"/search","query":"q=a+b%21&page=2&tag=x&tag=y&=z","protocol":"HTTP/1.1","q_q":"a b!","q_page":"2","q_tag":"x,y"}` + "\n" +
+				`{"request_uri":"/a","status":"404"}` + "\n" +
+				`{"status":"500"}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			opt := Option{Enrichers: []Enricher{WithRequestLine(tt.cfg)}}
+			if _, err := NewLTSVParser(context.Background(), buf, opt).ParseString(input); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", buf.String(), tt.want)
+			}
+		})
+	}
+}
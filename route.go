@@ -0,0 +1,99 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Rule routes the records satisfying its conditions to additional writers, such as an alerting sink,
+// while every record still flows to the output of the parser. Rules are set to Option.Routes and
+// evaluated per record after filtering, on the record before Labels selection, as in:
+//
+//	When("status >= 500").To(alertSink).Also(archiveSink)
+//
+// The line written is the output of the LineHandler without the prefix. Writes are serialized per rule
+// and flushed as written when the writer implements Flush, so that routed records are not held in buffers.
+// With Option.Concurrency, records of different zip entries may be routed out of entry order.
+type Rule struct {
+	mu      sync.Mutex
+	filters []string
+	writers []io.Writer
+	err     error
+}
+
+// When creates a rule matching the records that satisfy all the filter expressions, in the syntax of
+// Option.Filters. A rule without filters matches every record. Records lacking a field referred to by
+// the filters do not match.
+func When(filters ...string) *Rule {
+	return &Rule{filters: filters, err: validateFilters(filters)}
+}
+
+// To adds writers the matched records are routed to.
+func (r *Rule) To(w ...io.Writer) *Rule {
+	r.writers = append(r.writers, w...)
+	return r
+}
+
+// Also adds more writers the matched records are routed to. It is the same as To, to read naturally in a chain.
+func (r *Rule) Also(w ...io.Writer) *Rule {
+	return r.To(w...)
+}
+
+// String returns the conditions of the rule.
+func (r *Rule) String() string {
+	return strings.Join(r.filters, " && ")
+}
+
+// validate reports an invalid filter expression or a rule without writers.
+func (r *Rule) validate() error {
+	if r.err != nil {
+		return fmt.Errorf("%s: route %q: %w", optionError, r, r.err)
+	}
+	if len(r.writers) == 0 || slices.Contains(r.writers, nil) {
+		return fmt.Errorf("%s: route %q: no writer", optionError, r)
+	}
+	return nil
+}
+
+// match reports whether the record satisfies the conditions of the rule.
+func (r *Rule) match(labels, values []string) (bool, error) {
+	for _, filter := range r.filters {
+		if !slices.Contains(labels, strings.SplitN(filter, " ", 2)[0]) {
+			return false, nil
+		}
+	}
+	return applyFilter(labels, values, r.filters)
+}
+
+// write writes the line to the writers of the rule.
+func (r *Rule) write(line string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, w := range r.writers {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+		if err := flushOutput(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchRoutes returns the rules the record matches.
+func matchRoutes(rules []*Rule, labels, values []string) ([]*Rule, error) {
+	var matched []*Rule
+	for _, rule := range rules {
+		ok, err := rule.match(labels, values)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, rule)
+		}
+	}
+	return matched, nil
+}
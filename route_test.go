@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRule_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    *Rule
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			rule:    When("status >= 500").To(io.Discard),
+			wantErr: false,
+		},
+		{
+			name:    "match all",
+			rule:    When().To(io.Discard),
+			wantErr: false,
+		},
+		{
+			name:    "invalid filter",
+			rule:    When("status").To(io.Discard),
+			wantErr: true,
+		},
+		{
+			name:    "no writer",
+			rule:    When("status >= 500"),
+			wantErr: true,
+		},
+		{
+			name:    "nil writer",
+			rule:    When("status >= 500").To(nil),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rule.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	input := strings.Join([]string{
+		"host:a\tstatus:200",
+		"host:b\tstatus:503",
+		"host:c",
+		"host:d\tstatus:500",
+	}, "\n")
+	main, alert, archive, hosts := &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+	buffered := bufio.NewWriter(alert)
+	p := NewLTSVParser(context.Background(), main, Option{
+		Labels: []string{"host"},
+		Routes: []*Rule{
+			When("status >= 500").To(buffered).Also(archive),
+			When("host =~ ^[ab]$").To(hosts),
+		},
+	})
+	if _, err := p.ParseString(input); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		got  string
+		want []string
+	}{
+		{
+			name: "main",
+			got:  main.String(),
+			want: []string{`{"host":"a"}`, `{"host":"b"}`, `{"host":"c"}`, `{"host":"d"}`},
+		},
+		{
+			name: "alert",
+			got:  alert.String(),
+			want: []string{`{"host":"b"}`, `{"host":"d"}`},
+		},
+		{
+			name: "archive",
+			got:  archive.String(),
+			want: []string{`{"host":"b"}`, `{"host":"d"}`},
+		},
+		{
+			name: "hosts",
+			got:  hosts.String(),
+			want: []string{`{"host":"a"}`, `{"host":"b"}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if want := strings.Join(tt.want, "\n") + "\n"; tt.got != want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", tt.got, want)
+			}
+		})
+	}
+}
+
+func TestRoutes_invalid(t *testing.T) {
+	p := NewLTSVParser(context.Background(), io.Discard, Option{Routes: []*Rule{When("status")}})
+	if _, err := p.ParseString("status:200"); err == nil {
+		t.Error("invalid route must be rejected")
+	}
+}
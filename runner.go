@@ -0,0 +1,48 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// contextParser is implemented by the parsers of this package, whose context can be replaced for a run.
+type contextParser interface {
+	withContext(wrap func(ctx context.Context) context.Context) Parser
+}
+
+// RunUntilSignal parses the reader with p until the input ends or the process receives SIGINT or SIGTERM,
+// then writes the summary of the result to summary if it is not nil. A cancellation by signal and a broken
+// pipe on the output (e.g. when piping into head) are treated as a normal termination, so only real failures
+// are returned. It replaces the boilerplate that streaming applications usually write in main().
+// While it runs, SIGTERM cancels the parse in addition to SIGINT, and SIGPIPE is caught so that a broken pipe
+// on the standard output surfaces as a write error instead of terminating the process; the previous handling
+// of both is restored when it returns.
+func RunUntilSignal(p Parser, reader io.Reader, summary io.Writer) error {
+	stop := func() {}
+	if cp, ok := p.(contextParser); ok {
+		p = cp.withContext(func(ctx context.Context) context.Context {
+			ctx, stop = signal.NotifyContext(ctx, syscall.SIGTERM)
+			return ctx
+		})
+	}
+	defer stop()
+	pipe := make(chan os.Signal, 1)
+	signal.Notify(pipe, syscall.SIGPIPE)
+	defer signal.Stop(pipe)
+	r, err := p.Parse(reader)
+	if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, syscall.EPIPE) {
+		return err
+	}
+	if r == nil || summary == nil {
+		return nil
+	}
+	if _, err := fmt.Fprintln(summary, r.String()); err != nil && !errors.Is(err, syscall.EPIPE) {
+		return err
+	}
+	return nil
+}
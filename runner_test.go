@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/signal"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+type errWriter struct {
+	err error
+}
+
+func (w *errWriter) Write(_ []byte) (int, error) {
+	return 0, w.err
+}
+
+func TestRunUntilSignal(t *testing.T) {
+	tests := []struct {
+		name        string
+		writerErr   error
+		ctx         func() (context.Context, context.CancelFunc)
+		wantSummary bool
+		wantErr     bool
+	}{
+		{
+			name:        "end of input",
+			ctx:         func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
+			wantSummary: true,
+			wantErr:     false,
+		},
+		{
+			name: "cancelled",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+			wantSummary: true,
+			wantErr:     false,
+		},
+		{
+			name:        "broken pipe",
+			writerErr:   syscall.EPIPE,
+			ctx:         func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
+			wantSummary: true,
+			wantErr:     false,
+		},
+		{
+			name:        "write failure",
+			writerErr:   errors.New("disk full"),
+			ctx:         func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
+			wantSummary: false,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			output := &bytes.Buffer{}
+			var p Parser
+			if tt.writerErr != nil {
+				p = NewLTSVParser(ctx, &errWriter{err: tt.writerErr}, Option{})
+			} else {
+				p = NewLTSVParser(ctx, output, Option{})
+			}
+			summary := &bytes.Buffer{}
+			err := RunUntilSignal(p, strings.NewReader(ltsvAllMatchInput), summary)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if got := strings.Contains(summary.String(), "/* SUMMARY */"); got != tt.wantSummary {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.wantSummary)
+			}
+			if signal.Ignored(syscall.SIGPIPE) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", "SIGPIPE ignored", "handling restored")
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+//go:build unix
+
+package parser
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// termReader sends SIGTERM to the process on the first read, then returns lines until the deadline.
+type termReader struct {
+	sent     bool
+	deadline time.Time
+}
+
+func (r *termReader) Read(p []byte) (int, error) {
+	if !r.sent {
+		r.sent = true
+		if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+			return 0, err
+		}
+	}
+	if time.Now().After(r.deadline) {
+		return 0, io.EOF
+	}
+	return copy(p, "host:192.0.2.1\n"), nil
+}
+
+func TestRunUntilSignal_SIGTERM(t *testing.T) {
+	// keeps the process alive if SIGTERM is not caught by RunUntilSignal
+	term := make(chan os.Signal, 1)
+	signal.Notify(term, syscall.SIGTERM)
+	defer signal.Stop(term)
+	p := NewLTSVParser(context.Background(), &bytes.Buffer{}, Option{})
+	summary := &bytes.Buffer{}
+	if err := RunUntilSignal(p, &termReader{deadline: time.Now().Add(5 * time.Second)}, summary); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(summary.String(), "Cancelled :") {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", summary.String(), "cancelled by SIGTERM")
+	}
+}
@@ -0,0 +1,422 @@
+package parser
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// FieldType represents the data type of a field value.
+type FieldType int
+
+const (
+	FieldTypeString FieldType = iota // arbitrary text
+	FieldTypeInt                     // integer number
+	FieldTypeFloat                   // floating point number
+	FieldTypeBool                    // "true" or "false"
+	FieldTypeTime                    // timestamp in one of the supported layouts
+)
+
+// String returns the name of the field type.
+func (t FieldType) String() string {
+	switch t {
+	case FieldTypeInt:
+		return "int"
+	case FieldTypeFloat:
+		return "float"
+	case FieldTypeBool:
+		return "bool"
+	case FieldTypeTime:
+		return "time"
+	default:
+		return "string"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler so that field types are encoded by name.
+func (t FieldType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// NumberFormat defines how the value of a numeric field is written, such as bytes as integers or a duration in
+// milliseconds as seconds with three decimals. The zero value writes the value rounded to an integer.
+type NumberFormat struct {
+	Scale     float64 // factor the value is multiplied by, such as 0.001 for milliseconds to seconds (0 means 1)
+	Precision int     // number of digits after the decimal point (negative means as many as needed)
+}
+
+// format returns the value formatted, or the value as is if it is not a number, such as "-".
+func (f NumberFormat) format(v string) string {
+	x, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return v
+	}
+	if f.Scale != 0 {
+		x *= f.Scale
+	}
+	if f.Precision == 0 {
+		return strconv.FormatInt(int64(math.Round(x)), 10)
+	}
+	return strconv.FormatFloat(x, 'f', max(f.Precision, -1), 64)
+}
+
+// timeLayouts lists the timestamp layouts recognized when detecting FieldTypeTime.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"[02/Jan/2006:15:04:05 -0700]",
+	"02/Jan/2006:15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006/01/02 15:04:05",
+}
+
+// ParseTime parses the value in one of the layouts recognized as FieldTypeTime, such as RFC 3339 and the
+// timestamp of Apache CLF, and reports whether one of them fits.
+func ParseTime(v string) (time.Time, bool) {
+	return parseTime(v)
+}
+
+// detectFieldType returns the narrowest FieldType that can represent the value.
+func detectFieldType(v string) FieldType {
+	if v == "true" || v == "false" {
+		return FieldTypeBool
+	}
+	if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return FieldTypeInt
+	}
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return FieldTypeFloat
+	}
+	if _, ok := parseTime(v); ok {
+		return FieldTypeTime
+	}
+	return FieldTypeString
+}
+
+// isNullValue reports whether the value represents a missing value in access logs.
+func isNullValue(v string) bool {
+	return v == "" || v == "-"
+}
+
+// SchemaField describes a field inferred from the observed records.
+type SchemaField struct {
+	Name     string    `json:"name"`     // Label of the field.
+	Type     FieldType `json:"type"`     // Narrowest type that fits all non-null values.
+	Nullable bool      `json:"nullable"` // Whether an empty or "-" value, or a record without the field, was observed.
+}
+
+// Schema infers the labels and types of parsed records. Feed it with Observe, or wrap a LineHandler
+// with Schema.LineHandler to infer the schema of the output while parsing.
+type Schema struct {
+	fields  []SchemaField
+	typed   []bool
+	index   map[string]int
+	records int
+	seen    []int
+	stats   []columnStats
+	types   map[string]FieldType
+}
+
+// ColumnStats holds per-column statistics of the observed records, which can be used by
+// query planners to prune partitions or files on time and status columns.
+type ColumnStats struct {
+	Name      string    `json:"name"`          // Label of the column.
+	Type      FieldType `json:"type"`          // Inferred type of the column.
+	Count     int       `json:"count"`         // Number of records containing a non-null value.
+	NullCount int       `json:"nullCount"`     // Number of records with an empty or "-" value, or without the column.
+	Min       string    `json:"min,omitempty"` // Smallest value, compared according to the type.
+	Max       string    `json:"max,omitempty"` // Largest value, compared according to the type.
+}
+
+// columnStats tracks minimum and maximum values for every type a column may end up with.
+type columnStats struct {
+	count    int
+	nulls    int
+	hasNum   bool
+	minNum   float64
+	maxNum   float64
+	minNumS  string
+	maxNumS  string
+	hasTime  bool
+	minTime  time.Time
+	maxTime  time.Time
+	minTimeS string
+	maxTimeS string
+	hasStr   bool
+	minStr   string
+	maxStr   string
+}
+
+// NewSchema initializes an empty Schema.
+func NewSchema() *Schema {
+	return &Schema{index: make(map[string]int)}
+}
+
+// Observe updates the schema with a single record. Labels are kept in order of first appearance.
+func (s *Schema) Observe(labels, values []string) {
+	s.records++
+	for i, label := range labels {
+		if i >= len(values) {
+			break
+		}
+		j, ok := s.index[label]
+		if !ok {
+			j = len(s.fields)
+			s.index[label] = j
+			s.fields = append(s.fields, SchemaField{Name: label, Nullable: s.records > 1})
+			s.typed = append(s.typed, false)
+			s.seen = append(s.seen, 0)
+			s.stats = append(s.stats, columnStats{})
+		}
+		s.seen[j]++
+		v := values[i]
+		if isNullValue(v) {
+			s.fields[j].Nullable = true
+			s.stats[j].nulls++
+			continue
+		}
+		t := detectFieldType(v)
+		s.stats[j].observe(v, t)
+		if !s.typed[j] {
+			s.fields[j].Type = t
+			s.typed[j] = true
+			continue
+		}
+		s.fields[j].Type = mergeFieldType(s.fields[j].Type, t)
+	}
+}
+
+// observe updates the statistics with a non-null value of the detected type.
+func (c *columnStats) observe(v string, t FieldType) {
+	c.count++
+	if !c.hasStr || v < c.minStr {
+		c.minStr = v
+	}
+	if !c.hasStr || v > c.maxStr {
+		c.maxStr = v
+	}
+	c.hasStr = true
+	switch t {
+	case FieldTypeInt, FieldTypeFloat:
+		f, _ := strconv.ParseFloat(v, 64)
+		if !c.hasNum || f < c.minNum {
+			c.minNum, c.minNumS = f, v
+		}
+		if !c.hasNum || f > c.maxNum {
+			c.maxNum, c.maxNumS = f, v
+		}
+		c.hasNum = true
+	case FieldTypeTime:
+		tm, ok := parseTime(v)
+		if !ok {
+			break
+		}
+		if !c.hasTime || tm.Before(c.minTime) {
+			c.minTime, c.minTimeS = tm, v
+		}
+		if !c.hasTime || tm.After(c.maxTime) {
+			c.maxTime, c.maxTimeS = tm, v
+		}
+		c.hasTime = true
+	}
+}
+
+// mergeFieldType returns the type that can represent values of both types.
+func mergeFieldType(a, b FieldType) FieldType {
+	switch {
+	case a == b:
+		return a
+	case (a == FieldTypeInt && b == FieldTypeFloat) || (a == FieldTypeFloat && b == FieldTypeInt):
+		return FieldTypeFloat
+	default:
+		return FieldTypeString
+	}
+}
+
+// LineHandler returns a LineHandler that observes each record before passing it to next.
+func (s *Schema) LineHandler(next LineHandler) LineHandler {
+	return func(labels, values []string, isFirst bool) (string, error) {
+		s.Observe(labels, values)
+		return next(labels, values, isFirst)
+	}
+}
+
+// Fields returns the inferred fields in order of first appearance.
+// Fields missing from some records are reported as nullable.
+func (s *Schema) Fields() []SchemaField {
+	fields := make([]SchemaField, len(s.fields))
+	copy(fields, s.fields)
+	for i := range fields {
+		if s.seen[i] < s.records {
+			fields[i].Nullable = true
+		}
+	}
+	return fields
+}
+
+// Stats returns the statistics of each column in order of first appearance.
+// Minimum and maximum are compared numerically or chronologically when the column has such a type.
+func (s *Schema) Stats() []ColumnStats {
+	fields := s.Fields()
+	stats := make([]ColumnStats, len(fields))
+	for i, f := range fields {
+		c := s.stats[i]
+		st := ColumnStats{
+			Name:      f.Name,
+			Type:      f.Type,
+			Count:     c.count,
+			NullCount: c.nulls + s.records - s.seen[i],
+		}
+		switch f.Type {
+		case FieldTypeInt, FieldTypeFloat:
+			st.Min, st.Max = c.minNumS, c.maxNumS
+		case FieldTypeTime:
+			st.Min, st.Max = c.minTimeS, c.maxTimeS
+		default:
+			st.Min, st.Max = c.minStr, c.maxStr
+		}
+		stats[i] = st
+	}
+	return stats
+}
+
+// WriteStats writes the column statistics to w as an indented JSON array, to be stored alongside the output.
+func (s *Schema) WriteStats(w io.Writer) error {
+	b, err := json.MarshalIndent(s.Stats(), "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	_, err = w.Write(b)
+	return err
+}
+
+// SetTypes sets the types of the fields written as typed values, as with Option.Types or JSONOption.Types, for
+// JSONSchema and GlueTable to describe the output as written. Other fields are written as strings by
+// JSONLineHandler, whatever their inferred type.
+func (s *Schema) SetTypes(types map[string]FieldType) {
+	s.types = types
+}
+
+// JSONSchema renders the schema of the output as a JSON Schema (draft 2020-12) document describing a single
+// record written by JSONLineHandler, or by the handler of NewJSONLineHandler with the types set by SetTypes.
+// Fields are strings unless typed; typed fields are nullable if empty or "-" values were observed, and may also
+// be strings if some values cannot be converted. Time fields are strings. Fields missing in some records are
+// not required.
+func (s *Schema) JSONSchema() ([]byte, error) {
+	type property struct {
+		Type any `json:"type"`
+	}
+	type document struct {
+		Schema     string              `json:"$schema"`
+		Type       string              `json:"type"`
+		Properties map[string]property `json:"properties"`
+		Required   []string            `json:"required"`
+	}
+	doc := document{
+		Schema:     "https://json-schema.org/draft/2020-12/schema",
+		Type:       "object",
+		Properties: make(map[string]property, len(s.fields)),
+		Required:   make([]string, 0, len(s.fields)),
+	}
+	for i, f := range s.Fields() {
+		t, typed := s.types[f.Name]
+		var types []string
+		switch {
+		case !typed:
+		case t == FieldTypeInt:
+			types = append(types, "integer")
+		case t == FieldTypeFloat:
+			types = append(types, "number")
+		case t == FieldTypeBool:
+			types = append(types, "boolean")
+		}
+		if len(types) == 0 || !s.fits(i, t) {
+			types = append(types, "string")
+		}
+		if typed && t != FieldTypeString && s.stats[i].nulls > 0 {
+			types = append(types, "null")
+		}
+		if len(types) == 1 {
+			doc.Properties[f.Name] = property{Type: types[0]}
+		} else {
+			doc.Properties[f.Name] = property{Type: types}
+		}
+		if s.seen[i] == s.records {
+			doc.Required = append(doc.Required, f.Name)
+		}
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// outputType returns the type of the field as written: the type set by SetTypes if every non-null value observed
+// can be converted to it, or else FieldTypeString. Time fields are written as strings.
+func (s *Schema) outputType(i int) FieldType {
+	t, ok := s.types[s.fields[i].Name]
+	if !ok || t == FieldTypeTime || !s.fits(i, t) {
+		return FieldTypeString
+	}
+	return t
+}
+
+// fits reports whether every non-null value observed in the field can be converted to the type.
+func (s *Schema) fits(i int, t FieldType) bool {
+	if !s.typed[i] {
+		return true
+	}
+	inferred := s.fields[i].Type
+	return inferred == t || t == FieldTypeFloat && inferred == FieldTypeInt || t == FieldTypeString
+}
+
+// GlueTable renders the schema of the output as an AWS Glue TableInput for NDJSON output, suitable for
+// "aws glue create-table --table-input". As with JSONSchema, only the fields set by SetTypes whose values can
+// all be converted are typed, the others being strings. Time fields are mapped to string, since Glue timestamps
+// require a specific layout.
+func (s *Schema) GlueTable(name, location string) ([]byte, error) {
+	type column struct {
+		Name string `json:"Name"`
+		Type string `json:"Type"`
+	}
+	type serdeInfo struct {
+		SerializationLibrary string `json:"SerializationLibrary"`
+	}
+	type storageDescriptor struct {
+		Columns      []column  `json:"Columns"`
+		Location     string    `json:"Location,omitempty"`
+		InputFormat  string    `json:"InputFormat"`
+		OutputFormat string    `json:"OutputFormat"`
+		SerdeInfo    serdeInfo `json:"SerdeInfo"`
+	}
+	type tableInput struct {
+		Name              string            `json:"Name"`
+		TableType         string            `json:"TableType"`
+		StorageDescriptor storageDescriptor `json:"StorageDescriptor"`
+	}
+	columns := make([]column, 0, len(s.fields))
+	for i, f := range s.Fields() {
+		var t string
+		switch s.outputType(i) {
+		case FieldTypeInt:
+			t = "bigint"
+		case FieldTypeFloat:
+			t = "double"
+		case FieldTypeBool:
+			t = "boolean"
+		default:
+			t = "string"
+		}
+		columns = append(columns, column{Name: f.Name, Type: t})
+	}
+	return json.MarshalIndent(tableInput{
+		Name:      name,
+		TableType: "EXTERNAL_TABLE",
+		StorageDescriptor: storageDescriptor{
+			Columns:      columns,
+			Location:     location,
+			InputFormat:  "org.apache.hadoop.mapred.TextInputFormat",
+			OutputFormat: "org.apache.hadoop.hive.ql.io.HiveIgnoreKeyTextOutputFormat",
+			SerdeInfo:    serdeInfo{SerializationLibrary: "org.openx.data.jsonserde.JsonSerDe"},
+		},
+	}, "", "  ")
+}
@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// structTag is the key of the struct tags read by SchemaFromStruct.
+const structTag = "log"
+
+// StructSchema is the pattern and the output schema generated from an annotated struct by SchemaFromStruct,
+// so that both are kept in one place.
+type StructSchema struct {
+	Pattern string               // anchored regular expression with a named capture group for each field, for AddPattern
+	Labels  []string             // labels of the fields in order, for Option.Labels or the labels expected in LTSV input
+	Types   map[string]FieldType // types of the fields other than strings, for Option.Types
+}
+
+// SchemaFromStruct generates a StructSchema from the exported fields of a struct, or a pointer to one, tagged
+// as `log:"name,regex"`. The fields appear in the line in the order declared, separated by sep. The regex,
+// which may contain commas, is what the value matches; if omitted, the value extends up to the next separator.
+// Literal characters around the name are expected around the value in the line, such as `log:"[time]"` for
+// "[10/Oct/2000:13:55:36 -0700]" or `log:"\"request\""` for a quoted request. Fields tagged "-" or without the
+// tag are skipped. The types are derived from the field types: integers, floats, bool and time.Time.
+// As tag values are quoted strings, backslashes in the regex are doubled.
+//
+//	type S3Log struct {
+//		Owner  string `log:"bucket_owner,[!-~]+"`
+//		Bucket string `log:"bucket,[!-~]+"`
+//		Time   string `log:"[time],[^\\]]+"`
+//	}
+func SchemaFromStruct(v any, sep string) (*StructSchema, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%s: not a struct: %T", structSchemaError, v)
+	}
+	type part struct {
+		name, before, after, regex string
+	}
+	var parts []part
+	s := &StructSchema{Types: map[string]FieldType{}}
+	seen := map[string]struct{}{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup(structTag)
+		if !ok || tag == "-" || !f.IsExported() {
+			continue
+		}
+		spec, regex, _ := strings.Cut(tag, ",")
+		j := strings.IndexFunc(spec, isStructNameRune)
+		k := strings.LastIndexFunc(spec, isStructNameRune)
+		if j < 0 || !isFormatName(spec[j:k+1]) {
+			return nil, fmt.Errorf("%s: invalid name of field %s: %q", structSchemaError, f.Name, spec)
+		}
+		p := part{name: spec[j : k+1], before: spec[:j], after: spec[k+1:], regex: regex}
+		if _, ok := seen[p.name]; ok {
+			return nil, fmt.Errorf("%s: duplicate name %q", structSchemaError, p.name)
+		}
+		seen[p.name] = struct{}{}
+		ft, err := structFieldType(f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s: field %s: %w", structSchemaError, f.Name, err)
+		}
+		if ft != FieldTypeString {
+			s.Types[p.name] = ft
+		}
+		s.Labels = append(s.Labels, p.name)
+		parts = append(parts, p)
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("%s: no field tagged with %q", structSchemaError, structTag)
+	}
+	b := &strings.Builder{}
+	b.WriteString("^")
+	for i, p := range parts {
+		if i > 0 {
+			b.WriteString(regexp.QuoteMeta(sep))
+		}
+		regex := p.regex
+		if regex == "" {
+			switch {
+			case p.after != "":
+				regex = "[^" + regexp.QuoteMeta(p.after[:1]) + "]*"
+			case i < len(parts)-1 && sep != "":
+				regex = "[^" + regexp.QuoteMeta(sep[:1]) + "]*"
+			default:
+				regex = ".*"
+			}
+		}
+		fmt.Fprintf(b, "%s(?P<%s>%s)%s", regexp.QuoteMeta(p.before), p.name, regex, regexp.QuoteMeta(p.after))
+	}
+	b.WriteString("$")
+	if _, err := compilePattern(b.String()); err != nil {
+		return nil, fmt.Errorf("%s: %w", structSchemaError, err)
+	}
+	s.Pattern = b.String()
+	return s, nil
+}
+
+// isStructNameRune reports whether r may appear in the name of a field.
+func isStructNameRune(r rune) bool {
+	return r < 0x80 && isFormatNameByte(byte(r))
+}
+
+// structFieldType returns the FieldType of the Go type of a struct field.
+func structFieldType(t reflect.Type) (FieldType, error) {
+	if t == reflect.TypeOf(time.Time{}) {
+		return FieldTypeTime, nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return FieldTypeString, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return FieldTypeInt, nil
+	case reflect.Float32, reflect.Float64:
+		return FieldTypeFloat, nil
+	case reflect.Bool:
+		return FieldTypeBool, nil
+	default:
+		return FieldTypeString, fmt.Errorf("unsupported type %s", t)
+	}
+}
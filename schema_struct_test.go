@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSchemaFromStruct(t *testing.T) {
+	type accessLog struct {
+		Owner   string    `log:"bucket_owner,[!-~]+"`
+		Bucket  string    `log:"bucket,[!-~]+"`
+		Time    time.Time `log:"[time],[^\\]]+"`
+		Request string    `log:"\"request\""`
+		Status  int       `log:"http_status,\\d{3}|-"`
+		Ratio   float64   `log:"ratio"`
+		Cached  bool      `log:"cached"`
+		Note    string    `log:"-"`
+		private string    `log:"private"`
+	}
+	tests := []struct {
+		name    string
+		v       any
+		sep     string
+		want    *StructSchema
+		wantErr bool
+	}{
+		{
+			name: "basic",
+			v:    &accessLog{},
+			sep:  " ",
+			want: &StructSchema{
+				Pattern: `^(?P<bucket_owner>[!-~]+) (?P<bucket>[!-~]+) \[(?P<time>[^\]]+)\] "(?P<request>[^"]*)" (?P<http_status>\d{3}|-) (?P<ratio>[^ ]*) (?P<cached>.*)$`,
+				Labels:  []string{"bucket_owner", "bucket", "time", "request", "http_status", "ratio", "cached"},
+				Types:   map[string]FieldType{"time": FieldTypeTime, "http_status": FieldTypeInt, "ratio": FieldTypeFloat, "cached": FieldTypeBool},
+			},
+		},
+		{
+			name: "tab separated",
+			v: struct {
+				Host string `log:"host"`
+				Path string `log:"path"`
+			}{},
+			sep: "\t",
+			want: &StructSchema{
+				Pattern: "^(?P<host>[^\t]*)\t(?P<path>.*)$",
+				Labels:  []string{"host", "path"},
+				Types:   map[string]FieldType{},
+			},
+		},
+		{
+			name:    "not a struct",
+			v:       "string",
+			wantErr: true,
+		},
+		{
+			name:    "no tagged field",
+			v:       struct{ A string }{},
+			wantErr: true,
+		},
+		{
+			name: "duplicate name",
+			v: struct {
+				A string `log:"a"`
+				B string `log:"[a]"`
+			}{},
+			wantErr: true,
+		},
+		{
+			name: "invalid name",
+			v: struct {
+				A string `log:"a-b"`
+			}{},
+			wantErr: true,
+		},
+		{
+			name: "unsupported type",
+			v: struct {
+				A []string `log:"a"`
+			}{},
+			wantErr: true,
+		},
+		{
+			name: "invalid regex",
+			v: struct {
+				A string `log:"a,[0-9"`
+			}{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SchemaFromStruct(tt.v, tt.sep)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%#v\nwant:\n%#v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaFromStruct_parse(t *testing.T) {
+	type accessLog struct {
+		Host    string `log:"host"`
+		Time    string `log:"[time]"`
+		Request string `log:"\"request\""`
+		Status  int    `log:"status,\\d{3}"`
+	}
+	s, err := SchemaFromStruct(accessLog{}, " ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	p := NewRegexParser(context.Background(), buf, Option{Labels: s.Labels[2:], Types: s.Types})
+	if err := p.AddPattern(s.Pattern); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.ParseString(`192.0.2.1 [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.1" 200`); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"request":"GET / HTTP/1.1","status":200}` + "\n"; buf.String() != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", buf.String(), want)
+	}
+}
@@ -0,0 +1,294 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_detectFieldType(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  FieldType
+	}{
+		{name: "int", value: "200", want: FieldTypeInt},
+		{name: "negative int", value: "-1", want: FieldTypeInt},
+		{name: "float", value: "0.001", want: FieldTypeFloat},
+		{name: "bool", value: "true", want: FieldTypeBool},
+		{name: "rfc3339", value: "2015-05-13T23:39:43.945958Z", want: FieldTypeTime},
+		{name: "clf", value: "[16/Feb/2019:11:23:45 +0000]", want: FieldTypeTime},
+		{name: "string", value: "GET", want: FieldTypeString},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectFieldType(tt.value); got != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchema_Fields(t *testing.T) {
+	type record struct {
+		labels []string
+		values []string
+	}
+	tests := []struct {
+		name    string
+		records []record
+		want    []SchemaField
+	}{
+		{
+			name: "basic",
+			records: []record{
+				{labels: []string{"time", "status", "size", "method"}, values: []string{"[16/Feb/2019:11:23:45 +0000]", "200", "1024", "GET"}},
+				{labels: []string{"time", "status", "size", "method"}, values: []string{"[16/Feb/2019:11:23:46 +0000]", "404", "-", "POST"}},
+			},
+			want: []SchemaField{
+				{Name: "time", Type: FieldTypeTime},
+				{Name: "status", Type: FieldTypeInt},
+				{Name: "size", Type: FieldTypeInt, Nullable: true},
+				{Name: "method", Type: FieldTypeString},
+			},
+		},
+		{
+			name: "widened types",
+			records: []record{
+				{labels: []string{"a", "b"}, values: []string{"1", "1"}},
+				{labels: []string{"a", "b"}, values: []string{"1.5", "x"}},
+			},
+			want: []SchemaField{
+				{Name: "a", Type: FieldTypeFloat},
+				{Name: "b", Type: FieldTypeString},
+			},
+		},
+		{
+			name: "varying labels",
+			records: []record{
+				{labels: []string{"a"}, values: []string{"1"}},
+				{labels: []string{"a", "b"}, values: []string{"2", "true"}},
+			},
+			want: []SchemaField{
+				{Name: "a", Type: FieldTypeInt},
+				{Name: "b", Type: FieldTypeBool, Nullable: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSchema()
+			for _, r := range tt.records {
+				s.Observe(r.labels, r.values)
+			}
+			if got := s.Fields(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchema_LineHandler(t *testing.T) {
+	input := "host:192.0.2.1\tstatus:200\tsize:10\nhost:192.0.2.2\tstatus:-\tsize:x\nhost:192.0.2.3\tstatus:404"
+	tests := []struct {
+		name           string
+		types          map[string]FieldType
+		want           string
+		wantJSONSchema map[string]any
+		wantRequired   []string
+		wantGlue       []string
+	}{
+		{
+			name: "strings",
+			want: `{"host":"192.0.2.1","status":"200","size":"10"}` + "\n" +
+				`{"host":"192.0.2.2","status":"-","size":"x"}` + "\n" +
+				`{"host":"192.0.2.3","status":"404"}` + "\n",
+			wantJSONSchema: map[string]any{"host": "string", "status": "string", "size": "string"},
+			wantRequired:   []string{"host", "status"},
+			wantGlue:       []string{"string", "string", "string"},
+		},
+		{
+			name:  "typed",
+			types: map[string]FieldType{"status": FieldTypeInt, "size": FieldTypeInt},
+			want: `{"host":"192.0.2.1","status":200,"size":10}` + "\n" +
+				`{"host":"192.0.2.2","status":null,"size":"x"}` + "\n" +
+				`{"host":"192.0.2.3","status":404}` + "\n",
+			wantJSONSchema: map[string]any{"host": "string", "status": []any{"integer", "null"}, "size": []any{"integer", "string"}},
+			wantRequired:   []string{"host", "status"},
+			wantGlue:       []string{"string", "bigint", "string"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSchema()
+			s.SetTypes(tt.types)
+			w := &bytes.Buffer{}
+			p := NewLTSVParser(context.Background(), w, Option{
+				LineHandler: s.LineHandler(NewJSONLineHandler(JSONOption{Types: tt.types})),
+			})
+			if _, err := p.ParseString(input); err != nil {
+				t.Fatal(err)
+			}
+			if got := w.String(); got != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+			b, err := s.JSONSchema()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var doc struct {
+				Properties map[string]struct {
+					Type any `json:"type"`
+				} `json:"properties"`
+				Required []string `json:"required"`
+			}
+			if err := json.Unmarshal(b, &doc); err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]any{}
+			for name, prop := range doc.Properties {
+				got[name] = prop.Type
+			}
+			if !reflect.DeepEqual(got, tt.wantJSONSchema) || !reflect.DeepEqual(doc.Required, tt.wantRequired) {
+				t.Errorf("\ngot:\n%v %v\nwant:\n%v %v\n", got, doc.Required, tt.wantJSONSchema, tt.wantRequired)
+			}
+			// every record written must be valid against the schema
+			for _, line := range strings.Split(strings.TrimSpace(w.String()), "\n") {
+				var rec map[string]any
+				if err := json.Unmarshal([]byte(line), &rec); err != nil {
+					t.Fatal(err)
+				}
+				for _, name := range doc.Required {
+					if _, ok := rec[name]; !ok {
+						t.Errorf("required field %q missing in %s", name, line)
+					}
+				}
+				for name, v := range rec {
+					if !matchJSONType(doc.Properties[name].Type, v) {
+						t.Errorf("field %q of %s does not match %v", name, line, doc.Properties[name].Type)
+					}
+				}
+			}
+			b, err = s.GlueTable("access_logs", "s3://bucket/prefix/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var table struct {
+				StorageDescriptor struct {
+					Columns []struct {
+						Type string `json:"Type"`
+					} `json:"Columns"`
+				} `json:"StorageDescriptor"`
+			}
+			if err := json.Unmarshal(b, &table); err != nil {
+				t.Fatal(err)
+			}
+			var glue []string
+			for _, c := range table.StorageDescriptor.Columns {
+				glue = append(glue, c.Type)
+			}
+			if !reflect.DeepEqual(glue, tt.wantGlue) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", glue, tt.wantGlue)
+			}
+		})
+	}
+}
+
+// matchJSONType reports whether the decoded JSON value is of the type, or one of the types, of a JSON Schema.
+func matchJSONType(typ, v any) bool {
+	if types, ok := typ.([]any); ok {
+		for _, t := range types {
+			if matchJSONType(t, v) {
+				return true
+			}
+		}
+		return false
+	}
+	switch v := v.(type) {
+	case nil:
+		return typ == "null"
+	case string:
+		return typ == "string"
+	case bool:
+		return typ == "boolean"
+	case float64:
+		return typ == "number" || typ == "integer" && v == math.Trunc(v)
+	}
+	return false
+}
+
+func TestSchema_Stats(t *testing.T) {
+	s := NewSchema()
+	s.Observe([]string{"time", "status", "method"}, []string{"[16/Feb/2019:11:23:45 +0000]", "200", "GET"})
+	s.Observe([]string{"time", "status", "method"}, []string{"[15/Feb/2019:11:23:45 +0000]", "1000", "POST"})
+	s.Observe([]string{"time", "status"}, []string{"[17/Feb/2019:11:23:45 +0000]", "-"})
+	want := []ColumnStats{
+		{Name: "time", Type: FieldTypeTime, Count: 3, NullCount: 0, Min: "[15/Feb/2019:11:23:45 +0000]", Max: "[17/Feb/2019:11:23:45 +0000]"},
+		{Name: "status", Type: FieldTypeInt, Count: 2, NullCount: 1, Min: "200", Max: "1000"},
+		{Name: "method", Type: FieldTypeString, Count: 2, NullCount: 1, Min: "GET", Max: "POST"},
+	}
+	if got := s.Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+	w := &bytes.Buffer{}
+	if err := s.WriteStats(w); err != nil {
+		t.Fatal(err)
+	}
+	wantJSON := `[
+  {
+    "name": "time",
+    "type": "time",
+    "count": 3,
+    "nullCount": 0,
+    "min": "[15/Feb/2019:11:23:45 +0000]",
+    "max": "[17/Feb/2019:11:23:45 +0000]"
+  },
+  {
+    "name": "status",
+    "type": "int",
+    "count": 2,
+    "nullCount": 1,
+    "min": "200",
+    "max": "1000"
+  },
+  {
+    "name": "method",
+    "type": "string",
+    "count": 2,
+    "nullCount": 1,
+    "min": "GET",
+    "max": "POST"
+  }
+]
+`
+	if got := w.String(); got != wantJSON {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, wantJSON)
+	}
+}
+
+func TestOption_NumberFormats(t *testing.T) {
+	input := "bytes_sent:1024.4\ttotal_time:1534\tstatus:200.0\nbytes_sent:-\ttotal_time:7\tstatus:404\n"
+	buf := &bytes.Buffer{}
+	opt := Option{
+		NumberFormats: map[string]NumberFormat{
+			"bytes_sent": {},
+			"total_time": {Scale: 0.001, Precision: 3},
+			"status":     {},
+		},
+		Types: map[string]FieldType{"bytes_sent": FieldTypeInt, "total_time": FieldTypeFloat, "status": FieldTypeInt},
+	}
+	if _, err := NewLTSVParser(context.Background(), buf, opt).ParseString(input); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"bytes_sent":1024,"total_time":1.534,"status":200}` + "\n" + `{"bytes_sent":null,"total_time":0.007,"status":404}` + "\n"
+	if buf.String() != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", buf.String(), want)
+	}
+	if got := (NumberFormat{Scale: 1.0 / 3, Precision: -1}).format("1"); got != "0.3333333333333333" {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, "0.3333333333333333")
+	}
+}
@@ -0,0 +1,167 @@
+package parser
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// seenFilterMagic identifies a persisted seen filter file.
+const seenFilterMagic = "ALPSEEN1"
+
+// SeenFilter is a bloom filter of values of a key field, such as request_id, that have been seen.
+// It is persisted between runs so that repeated ingestion of overlapping inputs can cheaply drop
+// records already processed. As a bloom filter, it may drop a small fraction of unseen records
+// (false positives), but never passes a record seen before.
+type SeenFilter struct {
+	mu    sync.Mutex
+	path  string
+	label string
+	k     uint32
+	bits  []uint64
+}
+
+// OpenSeenFilter loads the seen filter persisted at path, or creates a new one sized for capacity values
+// with the false positive rate fpRate if the file does not exist. Records are identified by the value of label.
+func OpenSeenFilter(path, label string, capacity int, fpRate float64) (*SeenFilter, error) {
+	if path == "" {
+		return nil, fmt.Errorf(emptyPathError)
+	}
+	if label == "" {
+		return nil, fmt.Errorf("%s: empty label", seenFilterError)
+	}
+	f, err := os.Open(filepath.Clean(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return newSeenFilter(path, label, capacity, fpRate)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", openFileError, err)
+	}
+	defer f.Close()
+	s := &SeenFilter{path: path, label: label}
+	if err := s.read(f); err != nil {
+		return nil, fmt.Errorf("%s: %w", seenFilterError, err)
+	}
+	return s, nil
+}
+
+// newSeenFilter creates an empty seen filter with the optimal number of bits and hash functions.
+func newSeenFilter(path, label string, capacity int, fpRate float64) (*SeenFilter, error) {
+	if capacity <= 0 || fpRate <= 0 || fpRate >= 1 {
+		return nil, fmt.Errorf("%s: capacity must be positive and false positive rate must be between 0 and 1", seenFilterError)
+	}
+	m := math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	k := math.Max(1, math.Round(m/float64(capacity)*math.Ln2))
+	return &SeenFilter{
+		path:  path,
+		label: label,
+		k:     uint32(k),
+		bits:  make([]uint64, (uint64(m)+63)/64),
+	}, nil
+}
+
+// Label returns the label of the key field.
+func (s *SeenFilter) Label() string {
+	return s.label
+}
+
+// TestAndAdd reports whether the value has been seen, and adds it to the filter.
+func (s *SeenFilter) TestAndAdd(v string) bool {
+	h := fnv.New64a()
+	h.Write([]byte(v))
+	sum := h.Sum64()
+	h1, h2 := sum&math.MaxUint32, sum>>32|1
+	m := uint64(len(s.bits)) * 64
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := true
+	for i := uint64(0); i < uint64(s.k); i++ {
+		n := (h1 + i*h2) % m
+		mask := uint64(1) << (n % 64)
+		if s.bits[n/64]&mask == 0 {
+			seen = false
+			s.bits[n/64] |= mask
+		}
+	}
+	return seen
+}
+
+// seen reports whether the record identified by the label has been seen, and adds it to the filter.
+// Records without the label are never regarded as seen.
+func (s *SeenFilter) seen(labels, values []string) bool {
+	for i, label := range labels {
+		if label == s.label {
+			return s.TestAndAdd(values[i])
+		}
+	}
+	return false
+}
+
+// Save persists the filter to its path, so that the next run can load it with OpenSeenFilter.
+// The file is replaced atomically.
+func (s *SeenFilter) Save() error {
+	tmp := s.path + ".tmp"
+	f, err := os.Create(filepath.Clean(tmp))
+	if err != nil {
+		return fmt.Errorf("%s: %w", openFileError, err)
+	}
+	if err := s.write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// write encodes the filter as the magic, the number of hash functions, the number of words and the words.
+func (s *SeenFilter) write(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(seenFilterMagic); err != nil {
+		return err
+	}
+	if err := binary.Write(bw, binary.LittleEndian, s.k); err != nil {
+		return err
+	}
+	if err := binary.Write(bw, binary.LittleEndian, uint64(len(s.bits))); err != nil {
+		return err
+	}
+	if err := binary.Write(bw, binary.LittleEndian, s.bits); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// read decodes the filter written by write.
+func (s *SeenFilter) read(r io.Reader) error {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(seenFilterMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return err
+	}
+	if string(magic) != seenFilterMagic {
+		return errors.New("unknown file format")
+	}
+	if err := binary.Read(br, binary.LittleEndian, &s.k); err != nil {
+		return err
+	}
+	var n uint64
+	if err := binary.Read(br, binary.LittleEndian, &n); err != nil {
+		return err
+	}
+	if s.k == 0 || n == 0 {
+		return errors.New("empty filter")
+	}
+	s.bits = make([]uint64, n)
+	return binary.Read(br, binary.LittleEndian, s.bits)
+}
@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenSeenFilter(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.bf")
+	if err := os.WriteFile(invalid, []byte("invalid"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	type args struct {
+		path     string
+		label    string
+		capacity int
+		fpRate   float64
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name:    "new",
+			args:    args{path: filepath.Join(dir, "new.bf"), label: "request_id", capacity: 1000, fpRate: 0.01},
+			wantErr: false,
+		},
+		{
+			name:    "empty path",
+			args:    args{path: "", label: "request_id", capacity: 1000, fpRate: 0.01},
+			wantErr: true,
+		},
+		{
+			name:    "empty label",
+			args:    args{path: filepath.Join(dir, "new.bf"), label: "", capacity: 1000, fpRate: 0.01},
+			wantErr: true,
+		},
+		{
+			name:    "invalid capacity",
+			args:    args{path: filepath.Join(dir, "new.bf"), label: "request_id", capacity: 0, fpRate: 0.01},
+			wantErr: true,
+		},
+		{
+			name:    "invalid false positive rate",
+			args:    args{path: filepath.Join(dir, "new.bf"), label: "request_id", capacity: 1000, fpRate: 1},
+			wantErr: true,
+		},
+		{
+			name:    "invalid file",
+			args:    args{path: invalid, label: "request_id", capacity: 1000, fpRate: 0.01},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := OpenSeenFilter(tt.args.path, tt.args.label, tt.args.capacity, tt.args.fpRate)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSeenFilter_TestAndAdd(t *testing.T) {
+	s, err := OpenSeenFilter(filepath.Join(t.TempDir(), "seen.bf"), "id", 100, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.TestAndAdd("a") {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", true, false)
+	}
+	if !s.TestAndAdd("a") {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", false, true)
+	}
+	if s.TestAndAdd("b") {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", true, false)
+	}
+}
+
+func TestSeenFilter_Save(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seen.bf")
+	run := func() *Result {
+		s, err := OpenSeenFilter(path, "request_id", 1000, 0.001)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := NewS3RegexParser(context.Background(), io.Discard, Option{SeenFilter: s})
+		r, err := p.ParseFile(filepath.Join("testdata", "sample_s3_all_match.log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Save(); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	// the sample contains a duplicate request_id
+	first := run()
+	if first.Excluded != 1 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", first.Excluded, 1)
+	}
+	second := run()
+	if second.Matched != 0 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", second.Matched, 0)
+	}
+	if second.Excluded != first.Total {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", second.Excluded, first.Total)
+	}
+}
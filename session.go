@@ -0,0 +1,240 @@
+package parser
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultSessionGap is the default inactivity gap that ends a session.
+const defaultSessionGap = 30 * time.Minute
+
+// default fields read by Sessionizer, covering the labels used by the built-in parsers
+var (
+	defaultTimeFields  = []string{"time", "datetime"}
+	defaultPathFields  = []string{"request_uri", "cs_uri_stem"}
+	defaultBytesFields = []string{"size", "bytes_sent", "sent_bytes", "sc_bytes"}
+)
+
+// SessionConfig defines how requests are grouped into sessions.
+type SessionConfig struct {
+	Gap         time.Duration // inactivity gap that ends a session (zero means 30 minutes)
+	TimeFields  []string      // fields holding the request time in one of the layouts recognized by Schema (nil means time and datetime)
+	IPFields    []string      // fields holding client addresses (nil means the labels of the built-in parsers)
+	UAFields    []string      // fields holding user agents (nil means user_agent and cs_user_agent)
+	PathFields  []string      // fields holding request paths (nil means request_uri and cs_uri_stem)
+	BytesFields []string      // fields holding response sizes (nil means the labels of the built-in parsers)
+}
+
+// Session is a summary of the requests from a client, identified by its address and user agent,
+// with no gap between consecutive requests longer than SessionConfig.Gap.
+type Session struct {
+	Client    string    `json:"client"`    // Address of the client.
+	UserAgent string    `json:"userAgent"` // User agent of the client.
+	Start     time.Time `json:"start"`     // Time of the first request.
+	End       time.Time `json:"end"`       // Time of the last request.
+	Duration  float64   `json:"duration"`  // Seconds between the first and the last request.
+	EntryPage string    `json:"entryPage"` // Path of the first request.
+	ExitPage  string    `json:"exitPage"`  // Path of the last request.
+	Requests  int       `json:"requests"`  // Number of requests.
+	Bytes     int64     `json:"bytes"`     // Total size of the responses.
+}
+
+// sessionKey identifies the client of a session.
+type sessionKey struct {
+	client string
+	agent  string
+}
+
+// sessionTracker groups records into sessions by the time of the records, so that logs can be analyzed offline.
+// Sessions are closed when a record later than the end of the session by more than the gap is observed.
+type sessionTracker struct {
+	cfg    SessionConfig
+	open   map[sessionKey]*Session
+	latest time.Time // latest time observed
+	swept  time.Time // time of the latest sweep of open sessions
+}
+
+// newSessionTracker creates a sessionTracker with the defaults of the configuration applied.
+func newSessionTracker(cfg SessionConfig) *sessionTracker {
+	if cfg.Gap <= 0 {
+		cfg.Gap = defaultSessionGap
+	}
+	if cfg.TimeFields == nil {
+		cfg.TimeFields = defaultTimeFields
+	}
+	if cfg.IPFields == nil {
+		cfg.IPFields = defaultClientIPFields
+	}
+	if cfg.UAFields == nil {
+		cfg.UAFields = defaultUserAgentFields
+	}
+	if cfg.PathFields == nil {
+		cfg.PathFields = defaultPathFields
+	}
+	if cfg.BytesFields == nil {
+		cfg.BytesFields = defaultBytesFields
+	}
+	return &sessionTracker{cfg: cfg, open: map[sessionKey]*Session{}}
+}
+
+// observe adds the record to its session, and returns the session, the path of the previous request in the
+// session (empty for the first request), and the sessions closed meanwhile. Records without a valid time or
+// client are ignored, in which case the returned session is nil.
+func (t *sessionTracker) observe(r Record) (s *Session, prev string, closed []Session) {
+	tm, ok := parseTime(firstValue(r, t.cfg.TimeFields))
+	if !ok {
+		return nil, "", nil
+	}
+	client := firstValue(r, t.cfg.IPFields)
+	if a, ok := parseClientAddr(client); ok {
+		client = a.String()
+	}
+	if isNullValue(client) {
+		return nil, "", nil
+	}
+	key := sessionKey{client: client, agent: firstValue(r, t.cfg.UAFields)}
+	path := firstValue(r, t.cfg.PathFields)
+	if tm.After(t.latest) {
+		t.latest = tm
+	}
+	if t.latest.Sub(t.swept) > t.cfg.Gap {
+		closed = t.expire(t.latest)
+		t.swept = t.latest
+	}
+	s, ok = t.open[key]
+	if ok && tm.Sub(s.End) > t.cfg.Gap {
+		closed = append(closed, t.close(key))
+		ok = false
+	}
+	if !ok {
+		s = &Session{Client: key.client, UserAgent: key.agent, Start: tm, End: tm, EntryPage: path}
+		t.open[key] = s
+	} else {
+		prev = s.ExitPage
+	}
+	switch {
+	case tm.Before(s.Start):
+		s.Start, s.EntryPage = tm, path
+	case !tm.Before(s.End):
+		s.End, s.ExitPage = tm, path
+	}
+	if s.ExitPage == "" {
+		s.ExitPage = path
+	}
+	s.Requests++
+	if n, err := strconv.ParseInt(firstValue(r, t.cfg.BytesFields), 10, 64); err == nil {
+		s.Bytes += n
+	}
+	return s, prev, closed
+}
+
+// expire closes the sessions ended more than the gap before now, in order of start.
+func (t *sessionTracker) expire(now time.Time) []Session {
+	var closed []Session
+	for key, s := range t.open {
+		if now.Sub(s.End) > t.cfg.Gap {
+			closed = append(closed, t.close(key))
+		}
+	}
+	sortSessions(closed)
+	return closed
+}
+
+// flush closes all open sessions, in order of start.
+func (t *sessionTracker) flush() []Session {
+	closed := make([]Session, 0, len(t.open))
+	for key := range t.open {
+		closed = append(closed, t.close(key))
+	}
+	sortSessions(closed)
+	return closed
+}
+
+// close removes the session of the key from the open sessions and returns it.
+func (t *sessionTracker) close(key sessionKey) Session {
+	s := t.open[key]
+	delete(t.open, key)
+	s.Duration = s.End.Sub(s.Start).Seconds()
+	return *s
+}
+
+// sortSessions sorts sessions by start, then by client and user agent.
+func sortSessions(sessions []Session) {
+	sort.Slice(sessions, func(i, j int) bool {
+		a, b := sessions[i], sessions[j]
+		if !a.Start.Equal(b.Start) {
+			return a.Start.Before(b.Start)
+		}
+		if a.Client != b.Client {
+			return a.Client < b.Client
+		}
+		return a.UserAgent < b.UserAgent
+	})
+}
+
+// firstValue returns the value of the first of the fields the record has, or empty if it has none.
+func firstValue(r Record, fields []string) string {
+	for _, field := range fields {
+		if v, ok := r.Get(field); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// Sessionizer reconstructs sessions from parsed records and writes a summary of each session to w as NDJSON,
+// once the session is over. Time is taken from the records, so that archived logs can be analyzed offline;
+// records should be roughly in time order, as a session is closed when a record later than its end by more than
+// the gap is observed. Feed it with Observe, or wrap a LineHandler with Sessionizer.LineHandler, then call Close
+// to write the sessions still open. When Option.Labels is set, it must include the fields read.
+type Sessionizer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	tracker *sessionTracker
+}
+
+// NewSessionizer creates a Sessionizer writing session summaries to w.
+func NewSessionizer(w io.Writer, cfg SessionConfig) *Sessionizer {
+	return &Sessionizer{w: w, tracker: newSessionTracker(cfg)}
+}
+
+// Observe adds a record to its session, and writes the sessions closed meanwhile.
+func (s *Sessionizer) Observe(labels, values []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, _, closed := s.tracker.observe(Record{Labels: labels, Values: values})
+	return s.write(closed)
+}
+
+// LineHandler returns a LineHandler that observes each record before passing it to next.
+func (s *Sessionizer) LineHandler(next LineHandler) LineHandler {
+	return func(labels, values []string, isFirst bool) (string, error) {
+		if err := s.Observe(labels, values); err != nil {
+			return "", err
+		}
+		return next(labels, values, isFirst)
+	}
+}
+
+// Close writes the sessions still open.
+func (s *Sessionizer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(s.tracker.flush())
+}
+
+// write writes the sessions as NDJSON.
+func (s *Sessionizer) write(sessions []Session) error {
+	enc := json.NewEncoder(s.w)
+	enc.SetEscapeHTML(false)
+	for _, session := range sessions {
+		if err := enc.Encode(session); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionizer(t *testing.T) {
+	input := strings.Join([]string{
+		`192.0.2.1 - - [10/Oct/2000:13:00:00 -0700] "GET /index.html HTTP/1.1" 200 100 "-" "Mozilla/5.0"`,
+		`192.0.2.2 - - [10/Oct/2000:13:01:00 -0700] "GET /about HTTP/1.1" 200 50 "-" "curl/8.0"`,
+		`192.0.2.1 - - [10/Oct/2000:13:05:00 -0700] "GET /products HTTP/1.1" 200 200 "-" "Mozilla/5.0"`,
+		`192.0.2.1 - - [10/Oct/2000:13:10:00 -0700] "GET /cart HTTP/1.1" 200 - "-" "Mozilla/5.0"`,
+		`192.0.2.1 - - [10/Oct/2000:13:20:00 -0700] "GET /index.html HTTP/1.1" 200 100 "-" "Other/1.0"`,
+		`192.0.2.1 - - [10/Oct/2000:14:00:00 -0700] "GET /index.html HTTP/1.1" 200 100 "-" "Mozilla/5.0"`,
+	}, "\n")
+	tests := []struct {
+		name string
+		cfg  SessionConfig
+		want []string
+	}{
+		{
+			name: "default gap",
+			cfg:  SessionConfig{},
+			want: []string{
+				`{"client":"192.0.2.1","userAgent":"Mozilla/5.0","start":"2000-10-10T13:00:00-07:00","end":"2000-10-10T13:10:00-07:00","duration":600,"entryPage":"/index.html","exitPage":"/cart","requests":3,"bytes":300}`,
+				`{"client":"192.0.2.2","userAgent":"curl/8.0","start":"2000-10-10T13:01:00-07:00","end":"2000-10-10T13:01:00-07:00","duration":0,"entryPage":"/about","exitPage":"/about","requests":1,"bytes":50}`,
+				`{"client":"192.0.2.1","userAgent":"Other/1.0","start":"2000-10-10T13:20:00-07:00","end":"2000-10-10T13:20:00-07:00","duration":0,"entryPage":"/index.html","exitPage":"/index.html","requests":1,"bytes":100}`,
+				`{"client":"192.0.2.1","userAgent":"Mozilla/5.0","start":"2000-10-10T14:00:00-07:00","end":"2000-10-10T14:00:00-07:00","duration":0,"entryPage":"/index.html","exitPage":"/index.html","requests":1,"bytes":100}`,
+			},
+		},
+		{
+			name: "short gap",
+			cfg:  SessionConfig{Gap: 4 * time.Minute},
+			want: []string{
+				`{"client":"192.0.2.1","userAgent":"Mozilla/5.0","start":"2000-10-10T13:00:00-07:00","end":"2000-10-10T13:00:00-07:00","duration":0,"entryPage":"/index.html","exitPage":"/index.html","requests":1,"bytes":100}`,
+				`{"client":"192.0.2.2","userAgent":"curl/8.0","start":"2000-10-10T13:01:00-07:00","end":"2000-10-10T13:01:00-07:00","duration":0,"entryPage":"/about","exitPage":"/about","requests":1,"bytes":50}`,
+				`{"client":"192.0.2.1","userAgent":"Mozilla/5.0","start":"2000-10-10T13:05:00-07:00","end":"2000-10-10T13:05:00-07:00","duration":0,"entryPage":"/products","exitPage":"/products","requests":1,"bytes":200}`,
+				`{"client":"192.0.2.1","userAgent":"Mozilla/5.0","start":"2000-10-10T13:10:00-07:00","end":"2000-10-10T13:10:00-07:00","duration":0,"entryPage":"/cart","exitPage":"/cart","requests":1,"bytes":0}`,
+				`{"client":"192.0.2.1","userAgent":"Other/1.0","start":"2000-10-10T13:20:00-07:00","end":"2000-10-10T13:20:00-07:00","duration":0,"entryPage":"/index.html","exitPage":"/index.html","requests":1,"bytes":100}`,
+				`{"client":"192.0.2.1","userAgent":"Mozilla/5.0","start":"2000-10-10T14:00:00-07:00","end":"2000-10-10T14:00:00-07:00","duration":0,"entryPage":"/index.html","exitPage":"/index.html","requests":1,"bytes":100}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			s := NewSessionizer(buf, tt.cfg)
+			p := NewApacheCLFRegexParser(context.Background(), &bytes.Buffer{}, Option{
+				LineHandler: s.LineHandler(JSONLineHandler),
+			})
+			if _, err := p.ParseString(input); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+			want := strings.Join(tt.want, "\n") + "\n"
+			if got := buf.String(); got != want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+			}
+		})
+	}
+}
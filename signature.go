@@ -0,0 +1,162 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// labels of the fields added by WithSignatures
+const (
+	attackLabel      = "attack"
+	attackRulesLabel = "attack_rules"
+)
+
+// signatureError is the error message prefix for invalid signature sets.
+const signatureError = "invalid signature"
+
+// defaultSignatureFields are the fields signatures are evaluated against unless specified.
+var defaultSignatureFields = []string{"request_uri", "user_agent", "referer"}
+
+// defaultSignatures is a small built-in set of attack signatures in the format read by LoadSignatures,
+// modeled after the OWASP Core Rule Set. It catches common probes, and is not meant to replace a WAF.
+const defaultSignatures = `# id category fields pattern
+930100 path-traversal request_uri (?:\.\.[/\\]){2,}|(?:%2e%2e|\.%2e|%2e\.)(?:%2f|%5c|/)
+930120 lfi request_uri (?i)/(?:etc/(?:passwd|shadow|hosts)|proc/self/environ|windows/win\.ini)
+932100 rce request_uri,user_agent (?i)(?:;|\|\||&&|\$\(|` + "`" + `)\s*(?:cat|curl|wget|nc|bash|sh|id|uname)\b
+941100 xss request_uri,referer,user_agent (?i)<\s*script\b|javascript:|\bon(?:error|load|mouseover)\s*=
+942100 sqli request_uri,referer (?i)\bunion\b.{1,100}?\bselect\b|\bor\b\s+\d+\s*=\s*\d+|'\s*or\s*'|\bsleep\s*\(\s*\d+\s*\)|\binformation_schema\b
+944100 java request_uri,user_agent,referer (?i)\$\{\s*jndi\s*:
+`
+
+// Signature is an attack signature evaluated against the values of fields.
+type Signature struct {
+	ID       string         // rule id, such as "942100"
+	Category string         // attack category, such as "sqli"
+	Fields   []string       // labels of the fields evaluated
+	Pattern  *regexp.Regexp // pattern matching attack payloads
+}
+
+// SignatureSet is a set of attack signatures evaluated by WithSignatures.
+type SignatureSet struct {
+	signatures []Signature
+	guards     map[string]*regexp.Regexp // alternation of the patterns per field, to reject clean values in one pass
+}
+
+// DefaultSignatures returns the built-in signature set detecting common SQL injection, XSS, path traversal,
+// local file inclusion, command injection and JNDI injection probes in request_uri, user_agent and referer.
+func DefaultSignatures() *SignatureSet {
+	set, err := LoadSignatures(strings.NewReader(defaultSignatures))
+	if err != nil {
+		panic(err)
+	}
+	return set
+}
+
+// LoadSignatures reads a signature set, one signature per line in the form "<id> <category> <fields> <pattern>",
+// where fields are comma-separated labels, or "-" for request_uri, user_agent and referer. Blank lines and
+// lines starting with "#" are ignored.
+func LoadSignatures(r io.Reader) (*SignatureSet, error) {
+	var signatures []Signature
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		token := strings.SplitN(line, " ", 4)
+		if len(token) < 4 {
+			return nil, fmt.Errorf("%s: line %d: invalid syntax", signatureError, n)
+		}
+		ptn, err := regexp.Compile(strings.TrimSpace(token[3]))
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: %w", signatureError, n, err)
+		}
+		fields := defaultSignatureFields
+		if token[2] != "-" {
+			fields = strings.Split(token[2], ",")
+		}
+		signatures = append(signatures, Signature{ID: token[0], Category: token[1], Fields: fields, Pattern: ptn})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", signatureError, err)
+	}
+	return NewSignatureSet(signatures)
+}
+
+// NewSignatureSet creates a signature set.
+func NewSignatureSet(signatures []Signature) (*SignatureSet, error) {
+	alternatives := map[string][]string{}
+	for _, s := range signatures {
+		if s.ID == "" || s.Pattern == nil || len(s.Fields) == 0 {
+			return nil, fmt.Errorf("%s: %q: id, pattern and fields are required", signatureError, s.ID)
+		}
+		for _, field := range s.Fields {
+			alternatives[field] = append(alternatives[field], "(?:"+s.Pattern.String()+")")
+		}
+	}
+	guards := make(map[string]*regexp.Regexp, len(alternatives))
+	for field, alts := range alternatives {
+		guard, err := regexp.Compile(strings.Join(alts, "|"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", signatureError, err)
+		}
+		guards[field] = guard
+	}
+	return &SignatureSet{signatures: signatures, guards: guards}, nil
+}
+
+// Match returns the signatures matching the record. Values are evaluated both as is and percent-decoded,
+// as payloads in URIs are usually encoded.
+func (set *SignatureSet) Match(r Record) []Signature {
+	var matched []Signature
+	values := map[string][]string{}
+	for field, guard := range set.guards {
+		v, ok := r.Get(field)
+		if !ok {
+			continue
+		}
+		candidates := []string{v}
+		if d, err := url.QueryUnescape(v); err == nil && d != v {
+			candidates = append(candidates, d)
+		}
+		if slices.ContainsFunc(candidates, guard.MatchString) {
+			values[field] = candidates
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	for _, s := range set.signatures {
+		for _, field := range s.Fields {
+			if slices.ContainsFunc(values[field], s.Pattern.MatchString) {
+				matched = append(matched, s)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// WithSignatures returns an Enricher that tags records matching the signatures with the fields "attack",
+// the comma-separated categories, and "attack_rules", the comma-separated rule ids. Both are empty for
+// records matching none, so that attacks can be picked with PostFilters or Routes such as
+// When("attack =~ .").To(alertSink).
+func WithSignatures(set *SignatureSet) Enricher {
+	return func(r Record) (Record, error) {
+		var categories, ids []string
+		for _, s := range set.Match(r) {
+			if !slices.Contains(categories, s.Category) {
+				categories = append(categories, s.Category)
+			}
+			ids = append(ids, s.ID)
+		}
+		return r.With(attackLabel, strings.Join(categories, ",")).With(attackRulesLabel, strings.Join(ids, ",")), nil
+	}
+}
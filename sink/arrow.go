@@ -0,0 +1,312 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ArrowFormat represents the container format of the Arrow IPC output.
+type ArrowFormat int
+
+const (
+	ArrowStream ArrowFormat = iota // Arrow IPC streaming format, read with pyarrow.ipc.open_stream or polars.read_ipc_stream
+	ArrowFile                      // Arrow IPC file format, also known as Feather V2, read with pandas.read_feather or polars.read_ipc
+)
+
+// default settings of ArrowConfig
+const defaultArrowBatchSize = 1024
+
+// Arrow IPC constants
+const (
+	arrowMetadataV5     = 4 // MetadataVersion.V5
+	arrowHeaderSchema   = 1 // MessageHeader.Schema
+	arrowHeaderBatch    = 3 // MessageHeader.RecordBatch
+	arrowTypeUtf8       = 5 // Type.Utf8
+	arrowContinuation   = 0xFFFFFFFF
+	arrowAlignment      = 8
+	arrowMagic          = "ARROW1"
+	arrowMagicPadding   = 2
+	arrowBufferPerField = 3 // validity bitmap, offsets and data of a Utf8 column
+)
+
+// ArrowConfig defines the settings of an ArrowWriter.
+type ArrowConfig struct {
+	Format    ArrowFormat // container format of the output
+	Fields    []string    // columns to write (empty means the fields of the first record)
+	BatchSize int         // number of rows in a record batch (0 means 1024)
+}
+
+// ArrowWriter is an io.Writer that converts NDJSON records, such as the output of parser.JSONLineHandler,
+// into Arrow IPC record batches, so that parsed logs can be handed to pandas, polars or other Arrow consumers
+// without parsing on their side. Every column is a nullable Utf8 column, and the fields missing in a record
+// are null. Fields not in the columns are dropped. Close must be called to terminate the output.
+type ArrowWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	cfg     ArrowConfig
+	fields  []string
+	columns [][]string
+	valid   [][]bool
+	rows    int
+	partial []byte
+	offset  int64
+	blocks  [][]byte
+	started bool
+	closed  bool
+}
+
+// NewArrowWriter creates an ArrowWriter writing to w.
+func NewArrowWriter(w io.Writer, cfg ArrowConfig) (*ArrowWriter, error) {
+	if w == nil {
+		return nil, errors.New("nil writer")
+	}
+	if cfg.Format != ArrowStream && cfg.Format != ArrowFile {
+		return nil, fmt.Errorf("unknown arrow format: %d", cfg.Format)
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultArrowBatchSize
+	}
+	a := &ArrowWriter{w: w, cfg: cfg}
+	if len(cfg.Fields) > 0 {
+		a.setFields(cfg.Fields)
+	}
+	return a, nil
+}
+
+// Write converts the lines in p as records, writing a record batch each time BatchSize rows are buffered.
+// A line without a trailing newline is kept until the rest of it is written.
+func (a *ArrowWriter) Write(p []byte) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return 0, errors.New("arrow writer already closed")
+	}
+	data := append(a.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		if err := a.add(data[:i]); err != nil {
+			return len(p), err
+		}
+		data = data[i+1:]
+	}
+	a.partial = bytes.Clone(data)
+	return len(p), nil
+}
+
+// Flush writes the buffered rows as a record batch, and flushes w if it implements Flush.
+func (a *ArrowWriter) Flush() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.writeBatch(); err != nil {
+		return err
+	}
+	if f, ok := a.w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Close writes the buffered rows, including a line without a trailing newline, and terminates the output
+// with the end-of-stream marker, followed by the footer in the file format. It does not close w.
+func (a *ArrowWriter) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	if err := a.add(a.partial); err != nil {
+		return err
+	}
+	a.partial = nil
+	if err := a.writeBatch(); err != nil {
+		return err
+	}
+	if err := a.start(); err != nil {
+		return err
+	}
+	eos := binary.LittleEndian.AppendUint32(nil, arrowContinuation)
+	eos = binary.LittleEndian.AppendUint32(eos, 0)
+	if err := a.write(eos); err != nil {
+		return err
+	}
+	if a.cfg.Format != ArrowFile {
+		return nil
+	}
+	b := &fbBuilder{}
+	footer := b.finish(fbTable(
+		fbInt16(arrowMetadataV5),
+		fbRef(a.schema()),
+		fbRef(fbStructs()),
+		fbRef(fbStructs(a.blocks...)),
+	))
+	footer = binary.LittleEndian.AppendUint32(footer, uint32(len(footer)))
+	return a.write(append(footer, arrowMagic...))
+}
+
+// setFields sets the columns of the output.
+func (a *ArrowWriter) setFields(fields []string) {
+	a.fields = fields
+	a.columns = make([][]string, len(fields))
+	a.valid = make([][]bool, len(fields))
+}
+
+// add buffers the record in the line. Empty lines are skipped.
+func (a *ArrowWriter) add(line []byte) error {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return nil
+	}
+	labels, values, err := decodeRecord(line)
+	if err != nil {
+		return err
+	}
+	if a.fields == nil {
+		a.setFields(labels)
+	}
+	for i, field := range a.fields {
+		v, ok := "", false
+		for j, label := range labels {
+			if label == field {
+				v, ok = values[j], true
+				break
+			}
+		}
+		a.columns[i] = append(a.columns[i], v)
+		a.valid[i] = append(a.valid[i], ok)
+	}
+	a.rows++
+	if a.rows >= a.cfg.BatchSize {
+		return a.writeBatch()
+	}
+	return nil
+}
+
+// start writes the magic number in the file format and the schema message, if not yet written.
+func (a *ArrowWriter) start() error {
+	if a.started {
+		return nil
+	}
+	a.started = true
+	if a.cfg.Format == ArrowFile {
+		if err := a.write(append([]byte(arrowMagic), make([]byte, arrowMagicPadding)...)); err != nil {
+			return err
+		}
+	}
+	_, err := a.writeMessage(arrowHeaderSchema, a.schema(), nil)
+	return err
+}
+
+// schema returns the Schema table of the columns.
+func (a *ArrowWriter) schema() fbObject {
+	fields := make([]fbObject, len(a.fields))
+	for i, name := range a.fields {
+		fields[i] = fbTable(
+			fbRef(fbString(name)),
+			fbUint8(1),
+			fbUint8(arrowTypeUtf8),
+			fbRef(fbTable()),
+			fbField{},
+			fbRef(fbVector()),
+		)
+	}
+	return fbTable(fbInt16(0), fbRef(fbVector(fields...)))
+}
+
+// writeBatch writes the buffered rows as a record batch message.
+func (a *ArrowWriter) writeBatch() error {
+	if a.rows == 0 {
+		return nil
+	}
+	if err := a.start(); err != nil {
+		return err
+	}
+	var body []byte
+	nodes := make([][]byte, len(a.fields))
+	buffers := make([][]byte, 0, arrowBufferPerField*len(a.fields))
+	appendBuffer := func(b []byte) {
+		buffers = append(buffers, arrowStruct(int64(len(body)), int64(len(b))))
+		body = append(body, b...)
+		for len(body)%arrowAlignment != 0 {
+			body = append(body, 0)
+		}
+	}
+	for i := range a.fields {
+		bitmap := make([]byte, (a.rows+7)/8)
+		offsets := make([]byte, 0, 4*(a.rows+1))
+		offsets = binary.LittleEndian.AppendUint32(offsets, 0)
+		var data []byte
+		nulls := 0
+		for j, v := range a.columns[i] {
+			if a.valid[i][j] {
+				bitmap[j/8] |= 1 << (j % 8)
+				data = append(data, v...)
+			} else {
+				nulls++
+			}
+			offsets = binary.LittleEndian.AppendUint32(offsets, uint32(len(data)))
+		}
+		nodes[i] = arrowStruct(int64(a.rows), int64(nulls))
+		appendBuffer(bitmap)
+		appendBuffer(offsets)
+		appendBuffer(data)
+	}
+	batch := fbTable(
+		fbInt64(int64(a.rows)),
+		fbRef(fbStructs(nodes...)),
+		fbRef(fbStructs(buffers...)),
+	)
+	offset, err := a.writeMessage(arrowHeaderBatch, batch, body)
+	if err != nil {
+		return err
+	}
+	if a.cfg.Format == ArrowFile {
+		block := binary.LittleEndian.AppendUint64(nil, uint64(offset))
+		block = binary.LittleEndian.AppendUint32(block, uint32(a.offset-offset-int64(len(body))))
+		block = append(block, 0, 0, 0, 0)
+		block = binary.LittleEndian.AppendUint64(block, uint64(len(body)))
+		a.blocks = append(a.blocks, block)
+	}
+	for i := range a.fields {
+		a.columns[i], a.valid[i] = a.columns[i][:0], a.valid[i][:0]
+	}
+	a.rows = 0
+	return nil
+}
+
+// writeMessage writes an encapsulated message with the header and body, and returns the offset at which it starts.
+func (a *ArrowWriter) writeMessage(typ uint8, header fbObject, body []byte) (int64, error) {
+	b := &fbBuilder{}
+	meta := b.finish(fbTable(
+		fbInt16(arrowMetadataV5),
+		fbUint8(typ),
+		fbRef(header),
+		fbInt64(int64(len(body))),
+	))
+	offset := a.offset
+	prefix := binary.LittleEndian.AppendUint32(nil, arrowContinuation)
+	prefix = binary.LittleEndian.AppendUint32(prefix, uint32(len(meta)))
+	if err := a.write(append(append(prefix, meta...), body...)); err != nil {
+		return 0, err
+	}
+	return offset, nil
+}
+
+// write writes b to w, tracking the offset in the output.
+func (a *ArrowWriter) write(b []byte) error {
+	n, err := a.w.Write(b)
+	a.offset += int64(n)
+	return err
+}
+
+// arrowStruct encodes a struct of two longs, such as FieldNode and Buffer.
+func arrowStruct(x, y int64) []byte {
+	b := binary.LittleEndian.AppendUint64(nil, uint64(x))
+	return binary.LittleEndian.AppendUint64(b, uint64(y))
+}
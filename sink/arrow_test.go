@@ -0,0 +1,227 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	parser "github.com/nekrassov01/access-log-parser"
+)
+
+// arrowMessage is an encapsulated message read back from the output.
+type arrowMessage struct {
+	offset int
+	meta   []byte
+	body   []byte
+}
+
+// readArrowStream splits the stream into messages up to the end-of-stream marker.
+func readArrowStream(t *testing.T, b []byte) []arrowMessage {
+	t.Helper()
+	var msgs []arrowMessage
+	pos := 0
+	for {
+		if pos+8 > len(b) || binary.LittleEndian.Uint32(b[pos:]) != arrowContinuation {
+			t.Fatalf("missing continuation marker at %d", pos)
+		}
+		n := int(binary.LittleEndian.Uint32(b[pos+4:]))
+		if n == 0 {
+			return msgs
+		}
+		if n%arrowAlignment != 0 {
+			t.Fatalf("metadata not padded at %d: %d", pos, n)
+		}
+		meta := b[pos+8 : pos+8+n]
+		// bodyLength is the last field of the message table
+		vt := int(binary.LittleEndian.Uint32(meta))
+		vtable := vt - int(int32(binary.LittleEndian.Uint32(meta[vt:])))
+		slot := int(binary.LittleEndian.Uint16(meta[vtable+4+2*3:]))
+		size := int(binary.LittleEndian.Uint64(meta[vt+slot:]))
+		msgs = append(msgs, arrowMessage{offset: pos, meta: meta, body: b[pos+8+n : pos+8+n+size]})
+		pos += 8 + n + size
+	}
+}
+
+// readArrowColumns reads back the Utf8 columns in the body of a record batch, with null as "<null>".
+func readArrowColumns(t *testing.T, body []byte, columns, rows int) [][]string {
+	t.Helper()
+	out := make([][]string, columns)
+	pad := func(n int) int { return (n + arrowAlignment - 1) / arrowAlignment * arrowAlignment }
+	pos := 0
+	for i := range out {
+		bitmap := body[pos:]
+		pos += pad((rows + 7) / 8)
+		offsets := body[pos:]
+		pos += pad(4 * (rows + 1))
+		data := body[pos:]
+		for r := 0; r < rows; r++ {
+			s, e := binary.LittleEndian.Uint32(offsets[4*r:]), binary.LittleEndian.Uint32(offsets[4*r+4:])
+			v := string(data[s:e])
+			if bitmap[r/8]&(1<<(r%8)) == 0 {
+				v = "<null>"
+			}
+			out[i] = append(out[i], v)
+		}
+		pos += pad(int(binary.LittleEndian.Uint32(offsets[4*rows:])))
+	}
+	return out
+}
+
+func TestNewArrowWriter(t *testing.T) {
+	tests := []struct {
+		name    string
+		w       io.Writer
+		cfg     ArrowConfig
+		wantErr bool
+	}{
+		{
+			name:    "stream",
+			w:       &bytes.Buffer{},
+			cfg:     ArrowConfig{Format: ArrowStream},
+			wantErr: false,
+		},
+		{
+			name:    "file",
+			w:       &bytes.Buffer{},
+			cfg:     ArrowConfig{Format: ArrowFile},
+			wantErr: false,
+		},
+		{
+			name:    "nil writer",
+			w:       nil,
+			cfg:     ArrowConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "unknown format",
+			w:       &bytes.Buffer{},
+			cfg:     ArrowConfig{Format: ArrowFormat(9)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewArrowWriter(tt.w, tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestArrowWriter(t *testing.T) {
+	input := "{\"a\":\"x\",\"b\":\"1\"}\n{\"a\":\"yy\"}\n{\"b\":2,\"c\":3}\n\n{\"a\":\"\"}"
+	tests := []struct {
+		name    string
+		cfg     ArrowConfig
+		input   string
+		want    [][][]string
+		wantErr bool
+	}{
+		{
+			name:  "batches",
+			cfg:   ArrowConfig{BatchSize: 2},
+			input: input,
+			want: [][][]string{
+				{{"x", "yy"}, {"1", "<null>"}},
+				{{"<null>", ""}, {"2", "<null>"}},
+			},
+		},
+		{
+			name:  "fields",
+			cfg:   ArrowConfig{Fields: []string{"c", "a"}},
+			input: input,
+			want: [][][]string{
+				{{"<null>", "<null>", "3", "<null>"}, {"x", "yy", "<null>", ""}},
+			},
+		},
+		{
+			name:  "empty",
+			cfg:   ArrowConfig{},
+			input: "",
+			want:  nil,
+		},
+		{
+			name:    "invalid record",
+			cfg:     ArrowConfig{},
+			input:   "[1]\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		for _, format := range []ArrowFormat{ArrowStream, ArrowFile} {
+			t.Run(tt.name, func(t *testing.T) {
+				buf := &bytes.Buffer{}
+				tt.cfg.Format = format
+				a, err := NewArrowWriter(buf, tt.cfg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = a.Write([]byte(tt.input))
+				if err == nil {
+					err = a.Close()
+				}
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+				}
+				if tt.wantErr {
+					return
+				}
+				b := buf.Bytes()
+				if format == ArrowFile {
+					if !bytes.HasPrefix(b, []byte("ARROW1\x00\x00")) || !bytes.HasSuffix(b, []byte("ARROW1")) {
+						t.Fatalf("missing magic number: %q", b)
+					}
+					n := int(binary.LittleEndian.Uint32(b[len(b)-10:]))
+					b = b[8 : len(b)-10-n]
+				}
+				msgs := readArrowStream(t, b)
+				if len(msgs) != len(tt.want)+1 {
+					t.Fatalf("\ngot:\n%v\nwant:\n%v\n", len(msgs), len(tt.want)+1)
+				}
+				var got [][][]string
+				for i, msg := range msgs[1:] {
+					got = append(got, readArrowColumns(t, msg.body, len(tt.want[i]), len(tt.want[i][0])))
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+				}
+				if format == ArrowFile {
+					for _, block := range a.blocks {
+						offset := int(binary.LittleEndian.Uint64(block))
+						if !bytes.HasPrefix(buf.Bytes()[offset:], binary.LittleEndian.AppendUint32(nil, arrowContinuation)) {
+							t.Errorf("block does not point to a message: %d", offset)
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestArrowWriter_parser(t *testing.T) {
+	buf := &bytes.Buffer{}
+	a, err := NewArrowWriter(buf, ArrowConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := parser.NewLTSVParser(context.Background(), a, parser.Option{})
+	if _, err := p.ParseString(strings.Join([]string{"id:1\thost:a", "id:2\thost:b"}, "\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	msgs := readArrowStream(t, buf.Bytes())
+	if len(msgs) != 2 {
+		t.Fatalf("\ngot:\n%v\nwant:\n%v\n", len(msgs), 2)
+	}
+	got := readArrowColumns(t, msgs[1].body, 2, 2)
+	want := [][]string{{"1", "2"}, {"a", "b"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
@@ -0,0 +1,89 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ClickHouseConfig defines the settings of delivery to ClickHouse over the HTTP interface.
+type ClickHouseConfig struct {
+	URL               string            // HTTP interface endpoint, such as http://localhost:8123
+	Database          string            // database of the table (empty means the default database of the user)
+	Table             string            // table to insert records into
+	User              string            // user to authenticate as (empty means the default user)
+	Password          string            // password of the user
+	SkipUnknownFields bool              // whether to ignore record fields without a corresponding column or not
+	Settings          map[string]string // additional settings sent as query parameters, such as async_insert
+	Client            *http.Client      // client to send requests with (nil means http.DefaultClient)
+}
+
+// NewClickHouseDeliverer returns a DeliverFunc that inserts batches of JSON records, such as the output of
+// parser.JSONLineHandler, into a ClickHouse table in the JSONEachRow format. Pass it to Open for batching
+// and spooling of batches that failed to be inserted. Batches rejected with a client error, such as for
+// records not matching the table, are moved to the dead-letter directory of the spool instead of retried.
+func NewClickHouseDeliverer(cfg ClickHouseConfig) (DeliverFunc, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("empty ClickHouse URL")
+	}
+	table, err := clickHouseTable(cfg.Database, cfg.Table)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ClickHouse URL: %w", err)
+	}
+	q := u.Query()
+	for k, v := range cfg.Settings {
+		q.Set(k, v)
+	}
+	if cfg.SkipUnknownFields {
+		q.Set("input_format_skip_unknown_fields", "1")
+	}
+	q.Set("query", fmt.Sprintf("INSERT INTO %s FORMAT JSONEachRow", table))
+	u.RawQuery = q.Encode()
+	endpoint := u.String()
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return func(ctx context.Context, batch [][]byte) error {
+		body := append(bytes.Join(batch, []byte("\n")), '\n')
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		if cfg.User != "" {
+			req.Header.Set("X-ClickHouse-User", cfg.User)
+			req.Header.Set("X-ClickHouse-Key", cfg.Password)
+		}
+		resp, err := cfg.Client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		return responseError("clickhouse insert failed", resp)
+	}, nil
+}
+
+// clickHouseTable returns the quoted name of the table, qualified with the database if given.
+func clickHouseTable(database, table string) (string, error) {
+	if table == "" {
+		return "", errors.New("empty ClickHouse table")
+	}
+	name := quoteClickHouseIdentifier(table)
+	if database != "" {
+		name = quoteClickHouseIdentifier(database) + "." + name
+	}
+	return name, nil
+}
+
+// quoteClickHouseIdentifier quotes the identifier with backticks, escaping backslashes and backticks in it.
+func quoteClickHouseIdentifier(s string) string {
+	return "`" + strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(s) + "`"
+}
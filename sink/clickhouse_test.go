@@ -0,0 +1,135 @@
+package sink
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	parser "github.com/nekrassov01/access-log-parser"
+)
+
+func TestNewClickHouseDeliverer(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ClickHouseConfig
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			cfg:     ClickHouseConfig{URL: "http://localhost:8123", Table: "access_logs"},
+			wantErr: false,
+		},
+		{
+			name:    "empty url",
+			cfg:     ClickHouseConfig{Table: "access_logs"},
+			wantErr: true,
+		},
+		{
+			name:    "empty table",
+			cfg:     ClickHouseConfig{URL: "http://localhost:8123"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid url",
+			cfg:     ClickHouseConfig{URL: "http://[::1", Table: "access_logs"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClickHouseDeliverer(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClickHouse(t *testing.T) {
+	var queries, bodies []string
+	var user, key string
+	fail := 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail > 0 {
+			fail--
+			http.Error(w, "Code: 241. DB::Exception: Memory limit exceeded", http.StatusInternalServerError)
+			return
+		}
+		user, key = r.Header.Get("X-ClickHouse-User"), r.Header.Get("X-ClickHouse-Key")
+		queries = append(queries, r.URL.Query().Get("query")+" skip="+r.URL.Query().Get("input_format_skip_unknown_fields")+" async="+r.URL.Query().Get("async_insert"))
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+	}))
+	defer srv.Close()
+	deliver, err := NewClickHouseDeliverer(ClickHouseConfig{
+		URL:               srv.URL,
+		Database:          "logs",
+		Table:             "access`logs",
+		User:              "writer",
+		Password:          "secret",
+		SkipUnknownFields: true,
+		Settings:          map[string]string{"async_insert": "1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Open(context.Background(), Config{Dir: t.TempDir(), BatchSize: 2, Backoff: time.Millisecond}, deliver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := "status:200\tpath:/a\nstatus:404\tpath:/b\nstatus:200\tpath:/c"
+	if _, err := parser.NewLTSVParser(context.Background(), s, parser.Option{}).ParseString(input); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wantQuery := "INSERT INTO `logs`.`access\\`logs` FORMAT JSONEachRow skip=1 async=1"
+	if len(queries) != 2 || queries[0] != wantQuery {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", queries, wantQuery)
+	}
+	wantBodies := []string{
+		"{\"status\":\"200\",\"path\":\"/a\"}\n{\"status\":\"404\",\"path\":\"/b\"}\n",
+		"{\"status\":\"200\",\"path\":\"/c\"}\n",
+	}
+	for i, want := range wantBodies {
+		if i >= len(bodies) || bodies[i] != want {
+			t.Errorf("\ngot:\n%q\nwant:\n%q\n", bodies, wantBodies)
+			break
+		}
+	}
+	if user != "writer" || key != "secret" {
+		t.Errorf("\ngot:\n%v:%v\nwant:\n%v:%v\n", user, key, "writer", "secret")
+	}
+}
+
+func TestClickHouse_permanent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "Code: 117. DB::Exception: Unknown field found while parsing JSONEachRow format", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+	deliver, err := NewClickHouseDeliverer(ClickHouseConfig{URL: srv.URL, Table: "access_logs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Open(context.Background(), Config{Dir: t.TempDir(), BatchSize: 10, Backoff: time.Millisecond}, deliver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.NewLTSVParser(context.Background(), s, parser.Option{}).ParseString("status:200\tpath:/a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.Rejected()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", n, 1)
+	}
+}
@@ -0,0 +1,193 @@
+// Package duckdb appends the output of the parser to a DuckDB table through the appender API of the
+// DuckDB driver. It is a separate module so that the parent module stays free of cgo and of the driver.
+package duckdb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	goduckdb "github.com/marcboeker/go-duckdb"
+)
+
+// Config defines the settings of a Writer.
+type Config struct {
+	Path   string   // path of the database file, created if not exists (empty means an in-memory database)
+	Table  string   // table to append records to, created with VARCHAR columns if not exists
+	Fields []string // columns to append (empty means the fields of the first record)
+}
+
+// Writer is an io.Writer that appends NDJSON records, such as the output of parser.JSONLineHandler,
+// to a DuckDB table through the appender API of the DuckDB driver, without an intermediate file.
+// Fields missing in a record are appended as NULL, and fields not in the columns are dropped.
+// The driver is built with cgo.
+type Writer struct {
+	mu        sync.Mutex
+	ctx       context.Context
+	cfg       Config
+	connector *goduckdb.Connector
+	db        *sql.DB
+	conn      driver.Conn
+	appender  *goduckdb.Appender
+	fields    []string
+	partial   []byte
+}
+
+// NewWriter opens the database of the config. The table is created once the columns are known,
+// that is, immediately if Fields is set, or on the first record otherwise.
+func NewWriter(ctx context.Context, cfg Config) (*Writer, error) {
+	if cfg.Table == "" {
+		return nil, errors.New("empty table name")
+	}
+	connector, err := goduckdb.NewConnector(cfg.Path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open duckdb database: %w", err)
+	}
+	d := &Writer{ctx: ctx, cfg: cfg, connector: connector, db: sql.OpenDB(connector)}
+	if len(cfg.Fields) > 0 {
+		if err := d.start(cfg.Fields); err != nil {
+			return nil, errors.Join(err, d.close())
+		}
+	}
+	return d, nil
+}
+
+// Write appends the lines in p as records.
+// A line without a trailing newline is kept until the rest of it is written.
+func (d *Writer) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data := append(d.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		if err := d.append(data[:i]); err != nil {
+			return len(p), err
+		}
+		data = data[i+1:]
+	}
+	d.partial = bytes.Clone(data)
+	return len(p), nil
+}
+
+// Flush writes the rows buffered in the appender to the table.
+func (d *Writer) Flush() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.appender == nil {
+		return nil
+	}
+	return d.appender.Flush()
+}
+
+// Close appends a line without a trailing newline, flushes the appender and closes the database.
+func (d *Writer) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	err := d.append(d.partial)
+	d.partial = nil
+	return errors.Join(err, d.close())
+}
+
+// start creates the table with the columns if not exists, and the appender to it.
+func (d *Writer) start(fields []string) error {
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = quoteIdentifier(field) + " VARCHAR"
+	}
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", quoteIdentifier(d.cfg.Table), strings.Join(columns, ", "))
+	if _, err := d.db.ExecContext(d.ctx, query); err != nil {
+		return fmt.Errorf("cannot create table: %w", err)
+	}
+	conn, err := d.connector.Connect(d.ctx)
+	if err != nil {
+		return fmt.Errorf("cannot connect to duckdb database: %w", err)
+	}
+	appender, err := goduckdb.NewAppenderFromConn(conn, "", d.cfg.Table)
+	if err != nil {
+		return errors.Join(fmt.Errorf("cannot create appender: %w", err), conn.Close())
+	}
+	d.conn, d.appender, d.fields = conn, appender, fields
+	return nil
+}
+
+// append appends the record in the line. Empty lines are skipped.
+func (d *Writer) append(line []byte) error {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return nil
+	}
+	labels, values, err := decodeRecord(line)
+	if err != nil {
+		return err
+	}
+	if d.appender == nil {
+		if err := d.start(labels); err != nil {
+			return err
+		}
+	}
+	row := make([]driver.Value, len(d.fields))
+	for i, field := range d.fields {
+		for j, label := range labels {
+			if label == field {
+				row[i] = values[j]
+				break
+			}
+		}
+	}
+	return d.appender.AppendRow(row...)
+}
+
+// close closes the appender, the connection and the database in this order.
+func (d *Writer) close() error {
+	var err error
+	if d.appender != nil {
+		err = errors.Join(err, d.appender.Close())
+	}
+	if d.conn != nil {
+		err = errors.Join(err, d.conn.Close())
+	}
+	d.appender, d.conn = nil, nil
+	return errors.Join(err, d.db.Close(), d.connector.Close())
+}
+
+// quoteIdentifier quotes an identifier with double quotes, doubling the double quotes in it.
+func quoteIdentifier(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// decodeRecord decodes a JSON object into labels and values, keeping the order of the fields.
+// Values other than strings are kept as their JSON representation, as with the deliverers of the sink package.
+func decodeRecord(b []byte) ([]string, []string, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil, nil, errors.New("record is not a JSON object")
+	}
+	var labels, values []string
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key, _ := t.(string)
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, nil, err
+		}
+		value := string(raw)
+		if len(raw) > 0 && raw[0] == '"' {
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return nil, nil, err
+			}
+		}
+		labels, values = append(labels, key), append(values, value)
+	}
+	return labels, values, nil
+}
@@ -0,0 +1,55 @@
+package duckdb
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	parser "github.com/nekrassov01/access-log-parser"
+)
+
+func TestWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.duckdb")
+	d, err := NewWriter(context.Background(), Config{Path: path, Table: "access log"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := parser.NewLTSVParser(context.Background(), d, parser.Option{})
+	if _, err := p.ParseString(strings.Join([]string{"id:1\thost:a", "id:2", "id:3\thost:c\textra:x"}, "\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("duckdb", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.Query(`SELECT id, coalesce(host, '<null>') FROM "access log" ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got [][]string
+	for rows.Next() {
+		var id, host string
+		if err := rows.Scan(&id, &host); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, []string{id, host})
+	}
+	want := [][]string{{"1", "a"}, {"2", "<null>"}, {"3", "c"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func Test_quoteIdentifier(t *testing.T) {
+	if got, want := quoteIdentifier(`a"b`), `"a""b"`; got != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
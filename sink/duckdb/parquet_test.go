@@ -0,0 +1,86 @@
+package duckdb
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nekrassov01/access-log-parser/sink"
+)
+
+// TestParquetStatistics reads the statistics written by sink.ParquetWriter back with DuckDB, which uses them to
+// skip row groups.
+func TestParquetStatistics(t *testing.T) {
+	input := strings.Join([]string{
+		`{"host":"b","status":"200","ratio":"0.5","cached":"true"}`,
+		`{"host":"a","status":"-","ratio":"-1.5","cached":"true"}`,
+		`{"host":"c","status":"503","ratio":"-","cached":"false"}`,
+		`{"host":"c","status":"404"}`,
+		`{"host":"d","status":"-","ratio":"2"}`,
+	}, "\n")
+	path := filepath.Join(t.TempDir(), "logs.parquet")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := sink.NewParquetWriter(f, sink.ParquetConfig{RowGroupSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(input)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.Query(`SELECT row_group_id, stats_min_value, stats_max_value, stats_null_count
+		FROM parquet_metadata(?) ORDER BY row_group_id, column_id`, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got [][]string
+	for rows.Next() {
+		var group int64
+		var min, max sql.NullString
+		var nulls int64
+		if err := rows.Scan(&group, &min, &max, &nulls); err != nil {
+			t.Fatal(err)
+		}
+		for int(group) >= len(got) {
+			got = append(got, nil)
+		}
+		got[group] = append(got[group], fmt.Sprintf("%s..%s/%d", nullString(min), nullString(max), nulls))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"a..b/0", "200..200/1", "-1.5..0.5/0", "true..true/0"},
+		{"c..c/0", "404..503/0", "<none>..<none>/2", "false..false/1"},
+		{"d..d/0", "<none>..<none>/1", "2.0..2.0/0", "<none>..<none>/1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+// nullString returns the string, or "<none>" if it is null.
+func nullString(s sql.NullString) string {
+	if !s.Valid {
+		return "<none>"
+	}
+	return s.String
+}
@@ -0,0 +1,145 @@
+package sink
+
+import "encoding/binary"
+
+// fbBuilder is a minimal FlatBuffers encoder, sufficient for the Arrow IPC metadata written by ArrowWriter.
+// Unlike the official builder, objects are laid out front to back: a table or vector is written first and the
+// objects it refers to are appended after it, so that every offset points forward as the format requires.
+type fbBuilder struct {
+	buf []byte
+}
+
+// fbObject writes an object into the builder and returns its position.
+type fbObject func(b *fbBuilder) int
+
+// fbField is a field of a table: either an inline scalar, or a reference to an object written after the table.
+// A zero fbField is an absent field.
+type fbField struct {
+	scalar []byte
+	ref    fbObject
+}
+
+// fbUint8 returns an inline ubyte field, used for bools, enums of that width and union types.
+func fbUint8(v uint8) fbField {
+	return fbField{scalar: []byte{v}}
+}
+
+// fbInt16 returns an inline short field.
+func fbInt16(v int16) fbField {
+	return fbField{scalar: binary.LittleEndian.AppendUint16(nil, uint16(v))}
+}
+
+// fbInt64 returns an inline long field.
+func fbInt64(v int64) fbField {
+	return fbField{scalar: binary.LittleEndian.AppendUint64(nil, uint64(v))}
+}
+
+// fbRef returns a field referring to an object.
+func fbRef(obj fbObject) fbField {
+	return fbField{ref: obj}
+}
+
+// finish writes the root object and returns the buffer padded to 8 bytes.
+func (b *fbBuilder) finish(root fbObject) []byte {
+	b.buf = append(b.buf[:0], 0, 0, 0, 0)
+	b.patch(0, root(b))
+	b.pad(8)
+	return b.buf
+}
+
+// pad appends zeros until the length of the buffer is a multiple of n.
+func (b *fbBuilder) pad(n int) {
+	for len(b.buf)%n != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+// patch sets the offset at pos to refer to the object at target.
+func (b *fbBuilder) patch(pos, target int) {
+	binary.LittleEndian.PutUint32(b.buf[pos:], uint32(target-pos))
+}
+
+// fbTable returns an object writing a table with the fields in the order of their ids.
+func fbTable(fields ...fbField) fbObject {
+	return func(b *fbBuilder) int {
+		// Lay out the inline fields after the offset to the vtable, each aligned to its size.
+		slots := make([]int, len(fields))
+		size := 4
+		for i, f := range fields {
+			n := len(f.scalar)
+			if f.ref != nil {
+				n = 4
+			}
+			if n == 0 {
+				continue
+			}
+			size = (size + n - 1) / n * n
+			slots[i] = size
+			size += n
+		}
+		// The vtable precedes the table, which starts at a multiple of 8 so that the inline fields are aligned.
+		vtsize := 4 + 2*len(fields)
+		for (len(b.buf)+vtsize)%8 != 0 {
+			b.buf = append(b.buf, 0)
+		}
+		b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(vtsize))
+		b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(size))
+		for _, slot := range slots {
+			b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(slot))
+		}
+		pos := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(vtsize))
+		b.buf = append(b.buf, make([]byte, size-4)...)
+		for i, f := range fields {
+			if f.scalar != nil {
+				copy(b.buf[pos+slots[i]:], f.scalar)
+			}
+		}
+		for i, f := range fields {
+			if f.ref != nil {
+				b.patch(pos+slots[i], f.ref(b))
+			}
+		}
+		return pos
+	}
+}
+
+// fbString returns an object writing a string.
+func fbString(s string) fbObject {
+	return func(b *fbBuilder) int {
+		b.pad(4)
+		pos := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(s)))
+		b.buf = append(append(b.buf, s...), 0)
+		return pos
+	}
+}
+
+// fbVector returns an object writing a vector of tables or other objects.
+func fbVector(elems ...fbObject) fbObject {
+	return func(b *fbBuilder) int {
+		b.pad(4)
+		pos := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(elems)))
+		b.buf = append(b.buf, make([]byte, 4*len(elems))...)
+		for i, elem := range elems {
+			b.patch(pos+4+4*i, elem(b))
+		}
+		return pos
+	}
+}
+
+// fbStructs returns an object writing a vector of structs consisting of 8-byte aligned encoded elements.
+func fbStructs(elems ...[]byte) fbObject {
+	return func(b *fbBuilder) int {
+		for (len(b.buf)+4)%8 != 0 {
+			b.buf = append(b.buf, 0)
+		}
+		pos := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(elems)))
+		for _, elem := range elems {
+			b.buf = append(b.buf, elem...)
+		}
+		return pos
+	}
+}
@@ -0,0 +1,200 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	parser "github.com/nekrassov01/access-log-parser"
+)
+
+// LokiConfig defines the settings of delivery to the Grafana Loki push API.
+type LokiConfig struct {
+	URL        string            // push API endpoint, such as http://localhost:3100/loki/api/v1/push
+	Labels     []string          // record fields mapped to stream labels, which should be of low cardinality such as method and status
+	Static     map[string]string // labels added to every stream, such as job
+	TimeField  string            // record field holding the timestamp of the entry (empty means the delivery time)
+	TimeLayout string            // layout of the timestamp (empty means RFC 3339)
+	TenantID   string            // tenant sent as the X-Scope-OrgID header (empty means not sent)
+	Client     *http.Client      // client to send requests with (nil means http.DefaultClient)
+}
+
+// lokiPush is the JSON payload of the push API.
+type lokiPush struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+// lokiStream is a stream of entries sharing the same labels.
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// NewLokiDeliverer returns a DeliverFunc that pushes batches of JSON records, such as the output of
+// parser.JSONLineHandler, to Grafana Loki. The fields in cfg.Labels become stream labels, and the
+// rest of the fields form the log line in logfmt. Pass it to Open for batching and retries. Batches
+// rejected by Loki with a client error, such as for entries too far behind, are not retried but
+// moved to the dead-letter directory of the spool.
+func NewLokiDeliverer(cfg LokiConfig) (DeliverFunc, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("empty Loki push URL")
+	}
+	if cfg.TimeLayout == "" {
+		cfg.TimeLayout = time.RFC3339Nano
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return func(ctx context.Context, batch [][]byte) error {
+		body, err := lokiPayload(cfg, batch, time.Now())
+		if err != nil {
+			return Permanent(err)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if cfg.TenantID != "" {
+			req.Header.Set("X-Scope-OrgID", cfg.TenantID)
+		}
+		resp, err := cfg.Client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		return responseError("loki push failed", resp)
+	}, nil
+}
+
+// responseError returns an error for a response other than 2xx, read from its body. Client errors other than
+// timeouts and rate limiting are marked as permanent, since retrying the same batch would fail again.
+func responseError(msg string, resp *http.Response) error {
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err := fmt.Errorf("%s: %s: %s", msg, resp.Status, strings.TrimSpace(string(body)))
+	if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+	return err
+}
+
+// lokiPayload groups the records into streams by their labels, and encodes them as a push request.
+func lokiPayload(cfg LokiConfig, batch [][]byte, now time.Time) ([]byte, error) {
+	streams := map[string]*lokiStream{}
+	keys := make([]string, 0)
+	for _, b := range batch {
+		if len(bytes.TrimSpace(b)) == 0 {
+			continue
+		}
+		labels, values, err := decodeRecord(b)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode record for loki: %w", err)
+		}
+		stream := make(map[string]string, len(cfg.Static)+len(cfg.Labels))
+		for k, v := range cfg.Static {
+			stream[lokiLabelName(k)] = v
+		}
+		ts := now
+		var ls, vs []string
+		for i, label := range labels {
+			switch {
+			case slices.Contains(cfg.Labels, label):
+				stream[lokiLabelName(label)] = values[i]
+			case label == cfg.TimeField:
+				if t, err := time.Parse(cfg.TimeLayout, values[i]); err == nil {
+					ts = t
+				}
+				ls, vs = append(ls, label), append(vs, values[i])
+			default:
+				ls, vs = append(ls, label), append(vs, values[i])
+			}
+		}
+		line, err := parser.LogfmtLineHandler(ls, vs, false)
+		if err != nil {
+			return nil, err
+		}
+		key := streamKey(stream)
+		s, ok := streams[key]
+		if !ok {
+			s = &lokiStream{Stream: stream}
+			streams[key] = s
+			keys = append(keys, key)
+		}
+		s.Values = append(s.Values, [2]string{strconv.FormatInt(ts.UnixNano(), 10), line})
+	}
+	push := lokiPush{Streams: make([]lokiStream, 0, len(keys))}
+	for _, key := range keys {
+		push.Streams = append(push.Streams, *streams[key])
+	}
+	return json.Marshal(push)
+}
+
+// decodeRecord decodes a JSON object into labels and values, keeping the order of the fields.
+// Values other than strings are kept as their JSON representation.
+func decodeRecord(b []byte) ([]string, []string, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil, nil, errors.New("record is not a JSON object")
+	}
+	var labels, values []string
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key, _ := t.(string)
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, nil, err
+		}
+		value := string(raw)
+		if len(raw) > 0 && raw[0] == '"' {
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return nil, nil, err
+			}
+		}
+		labels, values = append(labels, key), append(values, value)
+	}
+	return labels, values, nil
+}
+
+// lokiLabelName replaces the characters not allowed in Loki label names with underscores.
+func lokiLabelName(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9' {
+			continue
+		}
+		b[i] = '_'
+	}
+	return string(b)
+}
+
+// streamKey returns a key identifying the label set of a stream.
+func streamKey(stream map[string]string) string {
+	keys := make([]string, 0, len(stream))
+	for k := range stream {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b := &strings.Builder{}
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte(0)
+		b.WriteString(stream[k])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
@@ -0,0 +1,170 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	parser "github.com/nekrassov01/access-log-parser"
+)
+
+func TestNewLokiDeliverer(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     LokiConfig
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			cfg:     LokiConfig{URL: "http://localhost:3100/loki/api/v1/push"},
+			wantErr: false,
+		},
+		{
+			name:    "empty url",
+			cfg:     LokiConfig{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLokiDeliverer(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_lokiPayload(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	batch := [][]byte{
+		[]byte(`{"time":"2024-01-01T00:00:00Z","method":"GET","status":"200","path":"/a b"}`),
+		[]byte(`{"time":"2024-01-01T00:00:01Z","method":"POST","status":"500","path":"/b"}`),
+		[]byte(`{"time":"invalid","method":"GET","status":"200","path":"/c","size":123}`),
+	}
+	cfg := LokiConfig{
+		Labels:     []string{"method", "status"},
+		Static:     map[string]string{"job": "access-log", "app-name": "web"},
+		TimeField:  "time",
+		TimeLayout: time.RFC3339,
+	}
+	b, err := lokiPayload(cfg, batch, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got lokiPush
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := lokiPush{
+		Streams: []lokiStream{
+			{
+				Stream: map[string]string{"job": "access-log", "app_name": "web", "method": "GET", "status": "200"},
+				Values: [][2]string{
+					{"1704067200000000000", `time=2024-01-01T00:00:00Z path="/a b"`},
+					{"1700000000000000000", `time=invalid path=/c size=123`},
+				},
+			},
+			{
+				Stream: map[string]string{"job": "access-log", "app_name": "web", "method": "POST", "status": "500"},
+				Values: [][2]string{
+					{"1704067201000000000", `time=2024-01-01T00:00:01Z path=/b`},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+	if _, err := lokiPayload(cfg, [][]byte{[]byte("not json")}, now); err == nil {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "error")
+	}
+}
+
+func TestLoki(t *testing.T) {
+	var bodies []lokiPush
+	var tenant string
+	fail := 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail > 0 {
+			fail--
+			http.Error(w, "ingester unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		tenant = r.Header.Get("X-Scope-OrgID")
+		b, _ := io.ReadAll(r.Body)
+		var p lokiPush
+		if err := json.Unmarshal(b, &p); err != nil {
+			t.Error(err)
+		}
+		bodies = append(bodies, p)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	deliver, err := NewLokiDeliverer(LokiConfig{URL: srv.URL, Labels: []string{"status"}, TenantID: "tenant1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Open(context.Background(), Config{Dir: t.TempDir(), BatchSize: 10, Backoff: time.Millisecond}, deliver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := "status:200\tpath:/a\nstatus:404\tpath:/b\nstatus:200\tpath:/c"
+	if _, err := parser.NewLTSVParser(context.Background(), s, parser.Option{}).ParseString(input); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 1 || len(bodies[0].Streams) != 2 {
+		t.Fatalf("\ngot:\n%v\nwant:\n%v\n", bodies, "a push of 2 streams")
+	}
+	var lines []string
+	for _, v := range bodies[0].Streams[0].Values {
+		lines = append(lines, v[1])
+	}
+	if got, want := strings.Join(lines, ","), "path=/a,path=/c"; got != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+	if tenant != "tenant1" {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", tenant, "tenant1")
+	}
+}
+
+func TestLoki_permanent(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		want   bool
+	}{
+		{name: "bad request", status: http.StatusBadRequest, want: true},
+		{name: "too many requests", status: http.StatusTooManyRequests, want: false},
+		{name: "server error", status: http.StatusInternalServerError, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				http.Error(w, "entry too far behind", tt.status)
+			}))
+			defer srv.Close()
+			deliver, err := NewLokiDeliverer(LokiConfig{URL: srv.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = deliver(context.Background(), [][]byte{[]byte(`{"path":"/a"}`)})
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if got := errors.Is(err, ErrPermanent); got != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,540 @@
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"sync"
+
+	parser "github.com/nekrassov01/access-log-parser"
+)
+
+// default settings of ParquetConfig
+const defaultParquetRowGroupSize = 10000
+
+// Parquet constants
+const (
+	parquetMagic           = "PAR1"
+	parquetTypeBoolean     = 0  // Type.BOOLEAN
+	parquetTypeInt64       = 2  // Type.INT64
+	parquetTypeDouble      = 5  // Type.DOUBLE
+	parquetTypeByteArray   = 6  // Type.BYTE_ARRAY
+	parquetOptional        = 1  // FieldRepetitionType.OPTIONAL
+	parquetConvertedUTF8   = 0  // ConvertedType.UTF8
+	parquetConvertedMicros = 10 // ConvertedType.TIMESTAMP_MICROS
+	parquetEncodingPlain   = 0  // Encoding.PLAIN
+	parquetEncodingRLE     = 3  // Encoding.RLE
+	parquetCodecNone       = 0  // CompressionCodec.UNCOMPRESSED
+	parquetCodecGzip       = 2  // CompressionCodec.GZIP
+	parquetPageData        = 0  // PageType.DATA_PAGE
+	parquetFormatVersion   = 1
+)
+
+// ParquetConfig defines the settings of a ParquetWriter.
+type ParquetConfig struct {
+	Fields       []string                    // columns to write (empty means the fields of the first record)
+	Types        map[string]parser.FieldType // types of the columns (columns not in it are inferred from the first row group, and later values must fit them)
+	RowGroupSize int                         // number of rows in a row group (0 means 10000)
+	Gzip         bool                        // whether to compress the pages with gzip or not
+}
+
+// ParquetWriter is an io.Writer that converts NDJSON records, such as the output of parser.JSONLineHandler,
+// into an Apache Parquet file, so that parsed logs can be queried with Athena, DuckDB or Spark without the
+// overhead of JSON. Every column is optional; integer, float and boolean columns are written as INT64, DOUBLE
+// and BOOLEAN, timestamps as INT64 timestamps in microseconds adjusted to UTC, and the rest as UTF-8 strings.
+// Each column chunk carries the number of nulls and the minimum and maximum values in its statistics, so that
+// query engines can skip the row groups out of the range of a predicate.
+// Empty, "-" and null values of typed columns and the fields missing in a record are null. Since the row groups
+// are written as they fill up, the types inferred from the first row group cannot be widened afterwards: a later
+// value that cannot be converted to the type of its column fails the write of its row group instead of being
+// dropped, and the type of such columns must be set in ParquetConfig.Types. Fields not in the columns are dropped.
+// Close must be called to write the footer.
+type ParquetWriter struct {
+	mu        sync.Mutex
+	w         io.Writer
+	cfg       ParquetConfig
+	fields    []string
+	types     []parser.FieldType
+	columns   [][]string
+	valid     [][]bool
+	rows      int
+	partial   []byte
+	offset    int64
+	rowGroups []parquetRowGroup
+	started   bool
+	closed    bool
+}
+
+// parquetRowGroup is the metadata of a row group written.
+type parquetRowGroup struct {
+	chunks []parquetChunk
+	size   int64
+	rows   int64
+}
+
+// parquetChunk is the metadata of a column chunk written.
+type parquetChunk struct {
+	offset       int64
+	uncompressed int64
+	compressed   int64
+	values       int64
+	stats        parquetStats
+}
+
+// parquetStats is the statistics of a column chunk, with the minimum and maximum values plain encoded.
+type parquetStats struct {
+	nulls    int64
+	min, max []byte
+	less     func(a, b []byte) bool
+}
+
+// newParquetStats returns the statistics of a column chunk of the field type, ordering the values as query
+// engines do: numerically for numbers and timestamps, false before true, and byte-wise for strings.
+func newParquetStats(t parser.FieldType) parquetStats {
+	s := parquetStats{}
+	switch t {
+	case parser.FieldTypeInt, parser.FieldTypeTime:
+		s.less = func(a, b []byte) bool {
+			return int64(binary.LittleEndian.Uint64(a)) < int64(binary.LittleEndian.Uint64(b))
+		}
+	case parser.FieldTypeFloat:
+		s.less = func(a, b []byte) bool {
+			return math.Float64frombits(binary.LittleEndian.Uint64(a)) < math.Float64frombits(binary.LittleEndian.Uint64(b))
+		}
+	default:
+		s.less = func(a, b []byte) bool { return bytes.Compare(a, b) < 0 }
+	}
+	return s
+}
+
+// observe updates the minimum and maximum with the plain encoding of a non-null value.
+func (s *parquetStats) observe(v []byte) {
+	if s.min == nil || s.less(v, s.min) {
+		s.min = v
+	}
+	if s.max == nil || s.less(s.max, v) {
+		s.max = v
+	}
+}
+
+// NewParquetWriter creates a ParquetWriter writing to w.
+func NewParquetWriter(w io.Writer, cfg ParquetConfig) (*ParquetWriter, error) {
+	if w == nil {
+		return nil, errors.New("nil writer")
+	}
+	for name, t := range cfg.Types {
+		if t < parser.FieldTypeString || t > parser.FieldTypeTime {
+			return nil, fmt.Errorf("unknown type of column %q: %d", name, t)
+		}
+	}
+	if cfg.RowGroupSize <= 0 {
+		cfg.RowGroupSize = defaultParquetRowGroupSize
+	}
+	p := &ParquetWriter{w: w, cfg: cfg}
+	if len(cfg.Fields) > 0 {
+		p.setFields(cfg.Fields)
+	}
+	return p, nil
+}
+
+// Write converts the lines in p as records, writing a row group each time RowGroupSize rows are buffered.
+// A line without a trailing newline is kept until the rest of it is written.
+func (p *ParquetWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return 0, errors.New("parquet writer already closed")
+	}
+	data := append(p.partial, b...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		if err := p.add(data[:i]); err != nil {
+			return len(b), err
+		}
+		data = data[i+1:]
+	}
+	p.partial = bytes.Clone(data)
+	return len(b), nil
+}
+
+// Flush writes the buffered rows as a row group, and flushes w if it implements Flush. Since the file is not
+// readable until Close writes the footer, Flush only bounds the rows held in memory.
+func (p *ParquetWriter) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.writeRowGroup(); err != nil {
+		return err
+	}
+	if f, ok := p.w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Close writes the buffered rows, including a line without a trailing newline, and the footer with the schema
+// and the row groups. It does not close w.
+func (p *ParquetWriter) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	if err := p.add(p.partial); err != nil {
+		return err
+	}
+	p.partial = nil
+	if err := p.writeRowGroup(); err != nil {
+		return err
+	}
+	if err := p.start(); err != nil {
+		return err
+	}
+	p.inferTypes()
+	footer := p.footer()
+	footer = binary.LittleEndian.AppendUint32(footer, uint32(len(footer)))
+	return p.write(append(footer, parquetMagic...))
+}
+
+// setFields sets the columns of the output.
+func (p *ParquetWriter) setFields(fields []string) {
+	p.fields = fields
+	p.columns = make([][]string, len(fields))
+	p.valid = make([][]bool, len(fields))
+}
+
+// add buffers the record in the line. Empty lines are skipped.
+func (p *ParquetWriter) add(line []byte) error {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return nil
+	}
+	labels, values, err := decodeRecord(line)
+	if err != nil {
+		return err
+	}
+	if p.fields == nil {
+		p.setFields(labels)
+	}
+	for i, field := range p.fields {
+		v, ok := "", false
+		for j, label := range labels {
+			if label == field {
+				v, ok = values[j], true
+				break
+			}
+		}
+		p.columns[i] = append(p.columns[i], v)
+		p.valid[i] = append(p.valid[i], ok)
+	}
+	p.rows++
+	if p.rows >= p.cfg.RowGroupSize {
+		return p.writeRowGroup()
+	}
+	return nil
+}
+
+// inferTypes fixes the types of the columns, from ParquetConfig.Types or else from the buffered rows.
+func (p *ParquetWriter) inferTypes() {
+	if p.types != nil {
+		return
+	}
+	schema := parser.NewSchema()
+	for r := 0; r < p.rows; r++ {
+		labels := make([]string, 0, len(p.fields))
+		values := make([]string, 0, len(p.fields))
+		for i, field := range p.fields {
+			if p.valid[i][r] && p.columns[i][r] != "null" {
+				labels, values = append(labels, field), append(values, p.columns[i][r])
+			}
+		}
+		schema.Observe(labels, values)
+	}
+	inferred := map[string]parser.FieldType{}
+	for _, f := range schema.Fields() {
+		inferred[f.Name] = f.Type
+	}
+	p.types = make([]parser.FieldType, len(p.fields))
+	for i, field := range p.fields {
+		t, ok := p.cfg.Types[field]
+		if !ok {
+			t = inferred[field]
+		}
+		p.types[i] = t
+	}
+}
+
+// start writes the magic number, if not yet written.
+func (p *ParquetWriter) start() error {
+	if p.started {
+		return nil
+	}
+	p.started = true
+	return p.write([]byte(parquetMagic))
+}
+
+// writeRowGroup writes the buffered rows as a row group with a data page for each column.
+func (p *ParquetWriter) writeRowGroup() error {
+	if p.rows == 0 {
+		return nil
+	}
+	if err := p.start(); err != nil {
+		return err
+	}
+	p.inferTypes()
+	pages := make([][]byte, len(p.fields))
+	stats := make([]parquetStats, len(p.fields))
+	for i := range p.fields {
+		levels, values, err := p.encodeColumn(i, &stats[i])
+		if err != nil {
+			return err
+		}
+		data := binary.LittleEndian.AppendUint32(nil, uint32(len(levels)))
+		pages[i] = append(append(data, levels...), values...)
+	}
+	g := parquetRowGroup{rows: int64(p.rows)}
+	for i, data := range pages {
+		compressed := data
+		if p.cfg.Gzip {
+			buf := &bytes.Buffer{}
+			zw := gzip.NewWriter(buf)
+			if _, err := zw.Write(data); err != nil {
+				return err
+			}
+			if err := zw.Close(); err != nil {
+				return err
+			}
+			compressed = buf.Bytes()
+		}
+		t := &thriftWriter{}
+		t.i32(1, parquetPageData)
+		t.i32(2, int32(len(data)))
+		t.i32(3, int32(len(compressed)))
+		t.beginStruct(5)
+		t.i32(1, int32(p.rows))
+		t.i32(2, parquetEncodingPlain)
+		t.i32(3, parquetEncodingRLE)
+		t.i32(4, parquetEncodingRLE)
+		t.endStruct()
+		header := t.finish()
+		c := parquetChunk{
+			offset:       p.offset,
+			uncompressed: int64(len(header) + len(data)),
+			compressed:   int64(len(header) + len(compressed)),
+			values:       int64(p.rows),
+			stats:        stats[i],
+		}
+		if err := p.write(append(header, compressed...)); err != nil {
+			return err
+		}
+		g.chunks = append(g.chunks, c)
+		g.size += c.uncompressed
+	}
+	p.rowGroups = append(p.rowGroups, g)
+	for i := range p.fields {
+		p.columns[i], p.valid[i] = p.columns[i][:0], p.valid[i][:0]
+	}
+	p.rows = 0
+	return nil
+}
+
+// encodeColumn returns the definition levels, in the RLE/bit-packing hybrid encoding, and the plain encoded
+// values of the non-null rows of the column, collecting their statistics into stats. It fails if a value cannot
+// be converted to the type of the column.
+func (p *ParquetWriter) encodeColumn(i int, stats *parquetStats) ([]byte, []byte, error) {
+	*stats = newParquetStats(p.types[i])
+	defined := make([]bool, p.rows)
+	var values []byte
+	var bits []bool
+	for r, v := range p.columns[i] {
+		if !p.valid[i][r] || p.types[i] != parser.FieldTypeString && isParquetNull(v) {
+			stats.nulls++
+			continue
+		}
+		var err error
+		switch p.types[i] {
+		case parser.FieldTypeInt:
+			var x int64
+			if x, err = strconv.ParseInt(v, 10, 64); err == nil {
+				values = binary.LittleEndian.AppendUint64(values, uint64(x))
+				stats.observe(values[len(values)-8:])
+			}
+		case parser.FieldTypeFloat:
+			var f float64
+			if f, err = strconv.ParseFloat(v, 64); err == nil {
+				values = binary.LittleEndian.AppendUint64(values, math.Float64bits(f))
+				if !math.IsNaN(f) {
+					stats.observe(values[len(values)-8:])
+				}
+			}
+		case parser.FieldTypeBool:
+			var b bool
+			if b, err = strconv.ParseBool(v); err == nil {
+				bits = append(bits, b)
+				stats.observe(packBits([]bool{b}))
+			}
+		case parser.FieldTypeTime:
+			tm, ok := parser.ParseTime(v)
+			if !ok {
+				err = errors.New("unknown time format")
+				break
+			}
+			values = binary.LittleEndian.AppendUint64(values, uint64(tm.UnixMicro()))
+			stats.observe(values[len(values)-8:])
+		default:
+			values = binary.LittleEndian.AppendUint32(values, uint32(len(v)))
+			values = append(values, v...)
+			stats.observe([]byte(v))
+		}
+		if err != nil {
+			return nil, nil, p.typeError(i, v)
+		}
+		defined[r] = true
+	}
+	if p.types[i] == parser.FieldTypeBool {
+		values = packBits(bits)
+	}
+	levels := binary.AppendUvarint(nil, uint64((p.rows+7)/8)<<1|1)
+	return append(levels, packBits(defined)...), values, nil
+}
+
+// typeError returns the error of a value that cannot be converted to the type of the column.
+func (p *ParquetWriter) typeError(i int, v string) error {
+	if _, ok := p.cfg.Types[p.fields[i]]; ok {
+		return fmt.Errorf("value %q of column %q is not of type %s", v, p.fields[i], p.types[i])
+	}
+	return fmt.Errorf("value %q of column %q is not of type %s inferred from the first row group, set the type in ParquetConfig.Types", v, p.fields[i], p.types[i])
+}
+
+// isParquetNull reports whether the value of a typed column is written as null.
+func isParquetNull(v string) bool {
+	return v == "" || v == "-" || v == "null"
+}
+
+// packBits packs the bits from the least significant bit of each byte.
+func packBits(bits []bool) []byte {
+	b := make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		if bit {
+			b[i/8] |= 1 << (i % 8)
+		}
+	}
+	return b
+}
+
+// footer returns the FileMetaData of the file.
+func (p *ParquetWriter) footer() []byte {
+	t := &thriftWriter{}
+	t.i32(1, parquetFormatVersion)
+	t.list(2, thriftStruct, len(p.fields)+1)
+	t.beginElem()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(p.fields)))
+	t.endStruct()
+	for i, field := range p.fields {
+		t.beginElem()
+		t.i32(1, parquetPhysicalType(p.types[i]))
+		t.i32(3, parquetOptional)
+		t.binary(4, field)
+		switch p.types[i] {
+		case parser.FieldTypeTime:
+			t.i32(6, parquetConvertedMicros)
+			t.beginStruct(10)
+			t.beginStruct(8)
+			t.boolean(1, true)
+			t.beginStruct(2)
+			t.beginStruct(2)
+			t.endStruct()
+			t.endStruct()
+			t.endStruct()
+			t.endStruct()
+		case parser.FieldTypeString:
+			t.i32(6, parquetConvertedUTF8)
+			t.beginStruct(10)
+			t.beginStruct(1)
+			t.endStruct()
+			t.endStruct()
+		}
+		t.endStruct()
+	}
+	var rows int64
+	for _, g := range p.rowGroups {
+		rows += g.rows
+	}
+	t.i64(3, rows)
+	t.list(4, thriftStruct, len(p.rowGroups))
+	codec := int32(parquetCodecNone)
+	if p.cfg.Gzip {
+		codec = parquetCodecGzip
+	}
+	for _, g := range p.rowGroups {
+		t.beginElem()
+		t.list(1, thriftStruct, len(g.chunks))
+		for i, c := range g.chunks {
+			t.beginElem()
+			t.i64(2, c.offset)
+			t.beginStruct(3)
+			t.i32(1, parquetPhysicalType(p.types[i]))
+			t.list(2, thriftI32, 2)
+			t.elemI32(parquetEncodingPlain)
+			t.elemI32(parquetEncodingRLE)
+			t.list(3, thriftBinary, 1)
+			t.elemBinary(p.fields[i])
+			t.i32(4, codec)
+			t.i64(5, c.values)
+			t.i64(6, c.uncompressed)
+			t.i64(7, c.compressed)
+			t.i64(9, c.offset)
+			t.beginStruct(12)
+			t.i64(3, c.stats.nulls)
+			if c.stats.min != nil {
+				t.binary(5, string(c.stats.max))
+				t.binary(6, string(c.stats.min))
+			}
+			t.endStruct()
+			t.endStruct()
+			t.endStruct()
+		}
+		t.i64(2, g.size)
+		t.i64(3, g.rows)
+		t.endStruct()
+	}
+	t.binary(6, "access-log-parser version "+parser.Version)
+	// the statistics are written as min_value and max_value, which readers use only with the column orders
+	t.list(7, thriftStruct, len(p.fields))
+	for range p.fields {
+		t.beginElem()
+		t.beginStruct(1)
+		t.endStruct()
+		t.endStruct()
+	}
+	return t.finish()
+}
+
+// parquetPhysicalType returns the physical type of the field type.
+func parquetPhysicalType(t parser.FieldType) int32 {
+	switch t {
+	case parser.FieldTypeInt, parser.FieldTypeTime:
+		return parquetTypeInt64
+	case parser.FieldTypeFloat:
+		return parquetTypeDouble
+	case parser.FieldTypeBool:
+		return parquetTypeBoolean
+	default:
+		return parquetTypeByteArray
+	}
+}
+
+// write writes b to w, tracking the offset in the output.
+func (p *ParquetWriter) write(b []byte) error {
+	n, err := p.w.Write(b)
+	p.offset += int64(n)
+	return err
+}
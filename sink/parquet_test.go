@@ -0,0 +1,304 @@
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	parser "github.com/nekrassov01/access-log-parser"
+)
+
+// readThrift decodes a struct of the Thrift compact protocol into a map of field ids to values: bool, int64,
+// string, []any or map[int16]any. It returns the number of bytes read.
+func readThrift(t *testing.T, b []byte) (map[int16]any, int) {
+	t.Helper()
+	pos := 0
+	var value func(typ byte) any
+	var read func() map[int16]any
+	value = func(typ byte) any {
+		switch typ {
+		case thriftTrue:
+			return true
+		case thriftFalse:
+			return false
+		case thriftI32, thriftI64:
+			v, n := binary.Varint(b[pos:])
+			pos += n
+			return v
+		case thriftBinary:
+			l, n := binary.Uvarint(b[pos:])
+			pos += n
+			s := string(b[pos : pos+int(l)])
+			pos += int(l)
+			return s
+		case thriftList:
+			h := b[pos]
+			pos++
+			size, elem := int(h>>4), h&0x0f
+			if size == 15 {
+				l, n := binary.Uvarint(b[pos:])
+				pos += n
+				size = int(l)
+			}
+			list := make([]any, size)
+			for i := range list {
+				list[i] = value(elem)
+			}
+			return list
+		case thriftStruct:
+			return read()
+		}
+		t.Fatalf("unexpected thrift type %d at %d", typ, pos)
+		return nil
+	}
+	read = func() map[int16]any {
+		m := map[int16]any{}
+		var last int16
+		for {
+			h := b[pos]
+			pos++
+			if h == 0 {
+				return m
+			}
+			id := last + int16(h>>4)
+			if h>>4 == 0 {
+				v, n := binary.Varint(b[pos:])
+				pos += n
+				id = int16(v)
+			}
+			m[id] = value(h & 0x0f)
+			last = id
+		}
+	}
+	m := read()
+	return m, pos
+}
+
+// readParquet reads back the file into the schema elements and the columns of each row, formatted with %v
+// and null as "<null>".
+func readParquet(t *testing.T, b []byte) ([]map[int16]any, [][]string) {
+	t.Helper()
+	if !bytes.HasPrefix(b, []byte(parquetMagic)) || !bytes.HasSuffix(b, []byte(parquetMagic)) {
+		t.Fatal("missing magic number")
+	}
+	size := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	meta, _ := readThrift(t, b[len(b)-8-size:len(b)-8])
+	var schema []map[int16]any
+	for _, e := range meta[2].([]any) {
+		schema = append(schema, e.(map[int16]any))
+	}
+	if orders, _ := meta[7].([]any); len(orders) != len(schema)-1 {
+		t.Errorf("got %d column orders for %d columns", len(orders), len(schema)-1)
+	}
+	columns := make([][]string, len(schema)-1)
+	for _, g := range meta[4].([]any) {
+		for i, c := range g.(map[int16]any)[1].([]any) {
+			cm := c.(map[int16]any)[3].(map[int16]any)
+			pos := int(cm[9].(int64))
+			header, n := readThrift(t, b[pos:])
+			data := b[pos+n : pos+n+int(header[3].(int64))]
+			if cm[4].(int64) == parquetCodecGzip {
+				zr, err := gzip.NewReader(bytes.NewReader(data))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if data, err = io.ReadAll(zr); err != nil {
+					t.Fatal(err)
+				}
+			}
+			rows := int(header[5].(map[int16]any)[1].(int64))
+			l := int(binary.LittleEndian.Uint32(data))
+			levels, values := data[4:4+l], data[4+l:]
+			_, hn := binary.Uvarint(levels)
+			bits := levels[hn:]
+			k := 0
+			for r := 0; r < rows; r++ {
+				if bits[r/8]&(1<<(r%8)) == 0 {
+					columns[i] = append(columns[i], "<null>")
+					continue
+				}
+				var v string
+				switch schema[i+1][1].(int64) {
+				case parquetTypeBoolean:
+					v = fmt.Sprint(values[k/8]&(1<<(k%8)) != 0)
+				case parquetTypeInt64:
+					x := int64(binary.LittleEndian.Uint64(values))
+					values = values[8:]
+					v = fmt.Sprint(x)
+					if _, ok := schema[i+1][10]; ok {
+						v = time.UnixMicro(x).UTC().Format(time.RFC3339Nano)
+					}
+				case parquetTypeDouble:
+					v = fmt.Sprint(math.Float64frombits(binary.LittleEndian.Uint64(values)))
+					values = values[8:]
+				case parquetTypeByteArray:
+					l := int(binary.LittleEndian.Uint32(values))
+					v = string(values[4 : 4+l])
+					values = values[4+l:]
+				}
+				k++
+				columns[i] = append(columns[i], v)
+			}
+		}
+	}
+	return schema, columns
+}
+
+func TestParquetWriter(t *testing.T) {
+	input := strings.Join([]string{
+		`{"host":"a","status":"200","size":"100","ratio":"0.5","cached":"true","time":"10/Oct/2000:13:55:36 -0700"}`,
+		`{"host":"b","status":"404","size":"-","ratio":"1.25","cached":"false","time":"2000-10-10T21:00:00Z"}`,
+		`{"host":"c","status":"500","ratio":"2","cached":"true","time":"2000-10-10T21:00:01.5Z","extra":"x"}`,
+	}, "\n")
+	tests := []struct {
+		name  string
+		cfg   ParquetConfig
+		types []int64
+		want  [][]string
+	}{
+		{
+			name:  "inferred",
+			cfg:   ParquetConfig{RowGroupSize: 2},
+			types: []int64{parquetTypeByteArray, parquetTypeInt64, parquetTypeInt64, parquetTypeDouble, parquetTypeBoolean, parquetTypeInt64},
+			want: [][]string{
+				{"a", "b", "c"},
+				{"200", "404", "500"},
+				{"100", "<null>", "<null>"},
+				{"0.5", "1.25", "2"},
+				{"true", "false", "true"},
+				{"2000-10-10T20:55:36Z", "2000-10-10T21:00:00Z", "2000-10-10T21:00:01.5Z"},
+			},
+		},
+		{
+			name:  "supplied with gzip",
+			cfg:   ParquetConfig{Fields: []string{"host", "status", "extra"}, Types: map[string]parser.FieldType{"status": parser.FieldTypeString}, Gzip: true},
+			types: []int64{parquetTypeByteArray, parquetTypeByteArray, parquetTypeByteArray},
+			want: [][]string{
+				{"a", "b", "c"},
+				{"200", "404", "500"},
+				{"<null>", "<null>", "x"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			w, err := NewParquetWriter(buf, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write([]byte(input)); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			schema, got := readParquet(t, buf.Bytes())
+			if n := schema[0][5].(int64); n != int64(len(tt.types)) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", n, len(tt.types))
+			}
+			var types []int64
+			for _, e := range schema[1:] {
+				types = append(types, e[1].(int64))
+			}
+			if !reflect.DeepEqual(types, tt.types) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", types, tt.types)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParquetWriter_parser(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, err := NewParquetWriter(buf, ParquetConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := parser.Option{Types: map[string]parser.FieldType{"status": parser.FieldTypeInt}}
+	if _, err := parser.NewLTSVParser(context.Background(), w, opt).ParseString("path:/a\tstatus:200\npath:/b\tstatus:-\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_, got := readParquet(t, buf.Bytes())
+	if want := [][]string{{"/a", "/b"}, {"200", "<null>"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+	if _, err := w.Write([]byte("{}\n")); err == nil {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "closed")
+	}
+	if _, err := NewParquetWriter(nil, ParquetConfig{}); err == nil {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "nil writer")
+	}
+	if _, err := NewParquetWriter(buf, ParquetConfig{Types: map[string]parser.FieldType{"a": 9}}); err == nil {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "unknown type")
+	}
+}
+
+func TestParquetWriter_typeChange(t *testing.T) {
+	input := `{"path":"/a","bytes":"1"}` + "\n" + `{"path":"/b","bytes":"-"}` + "\n" + `{"path":"/c","bytes":"3.5"}` + "\n"
+	tests := []struct {
+		name    string
+		cfg     ParquetConfig
+		want    [][]string
+		wantErr bool
+	}{
+		{
+			name: "within the first row group",
+			cfg:  ParquetConfig{},
+			want: [][]string{{"/a", "/b", "/c"}, {"1", "<null>", "3.5"}},
+		},
+		{
+			name:    "inferred from a previous row group",
+			cfg:     ParquetConfig{RowGroupSize: 2},
+			wantErr: true,
+		},
+		{
+			name:    "supplied",
+			cfg:     ParquetConfig{Types: map[string]parser.FieldType{"bytes": parser.FieldTypeInt}},
+			wantErr: true,
+		},
+		{
+			name: "supplied wider",
+			cfg:  ParquetConfig{RowGroupSize: 2, Types: map[string]parser.FieldType{"bytes": parser.FieldTypeFloat}},
+			want: [][]string{{"/a", "/b", "/c"}, {"1", "<null>", "3.5"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			w, err := NewParquetWriter(buf, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = w.Write([]byte(input))
+			if err == nil {
+				err = w.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !strings.Contains(err.Error(), `"3.5"`) {
+					t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "the value in the error")
+				}
+				return
+			}
+			if _, got := readParquet(t, buf.Bytes()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	parser "github.com/nekrassov01/access-log-parser"
+)
+
+// default settings of PartitionConfig
+const (
+	defaultPartitionReorder  = 1000
+	defaultPartitionFallback = "unknown"
+)
+
+// PartitionConfig defines the settings of a Partitioner.
+type PartitionConfig struct {
+	Dir        string         // directory to write the partitions in, as dt=YYYY-MM-DD/<Prefix>.json
+	Prefix     string         // prefix of the file names (empty means "part")
+	TimeField  string         // record field holding the log timestamp the partition is chosen by
+	TimeLayout string         // layout of the timestamp (empty means the layouts recognized by parser.ParseTime)
+	Location   *time.Location // zone the days are bounded in (nil means UTC)
+	Reorder    int            // number of records held to write those out of order near midnight in time order (0 means 1000, negative means none)
+	Fallback   string         // partition of records without a valid timestamp (empty means "unknown")
+}
+
+// Partition describes a partition written by a Partitioner.
+type Partition struct {
+	Name string `json:"name"` // File name relative to the directory, such as "dt=2019-02-16/part.json".
+	Day  string `json:"day"`  // Day of the records, or the fallback for records without a valid timestamp.
+	Rows int    `json:"rows"` // Number of records in the partition.
+	Late int    `json:"late"` // Number of records that arrived after the buffer had passed the end of their day, appended to the closed partition.
+}
+
+// Partitioner is an io.Writer that writes NDJSON records, such as the output of parser.JSONLineHandler, into
+// daily partitions by the timestamp of the log rather than the time of processing, so that a record logged
+// just before midnight lands in the partition of its own day even if it arrives after records of the next
+// day. Records are held in a small buffer and written in time order, and a partition is closed once records
+// later than its day have passed the buffer. A record arriving later than that is still appended to its own
+// partition and counted as late.
+type Partitioner struct {
+	mu      sync.Mutex
+	cfg     PartitionConfig
+	pending partitionHeap
+	partial []byte
+	seq     uint64
+	open    map[string]*partitionFile
+	parts   map[string]*Partition
+	closed  string // latest day closed, whose records and earlier ones are late
+}
+
+// partitionFile is the open file of a partition.
+type partitionFile struct {
+	file *os.File
+	bw   *bufio.Writer
+}
+
+// partitionRecord is a record held in the buffer.
+type partitionRecord struct {
+	time   time.Time
+	seq    uint64
+	day    string
+	record []byte
+}
+
+// partitionHeap orders the held records by time, keeping the order of arrival among records of the same time.
+type partitionHeap []partitionRecord
+
+func (h partitionHeap) Len() int { return len(h) }
+func (h partitionHeap) Less(i, j int) bool {
+	if !h[i].time.Equal(h[j].time) {
+		return h[i].time.Before(h[j].time)
+	}
+	return h[i].seq < h[j].seq
+}
+func (h partitionHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *partitionHeap) Push(x any)   { *h = append(*h, x.(partitionRecord)) }
+func (h *partitionHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// NewPartitioner creates a Partitioner writing into the directory of the config.
+func NewPartitioner(cfg PartitionConfig) (*Partitioner, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("empty partition directory")
+	}
+	if cfg.TimeField == "" {
+		return nil, errors.New("empty partition time field")
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "part"
+	}
+	if cfg.Location == nil {
+		cfg.Location = time.UTC
+	}
+	if cfg.Reorder == 0 {
+		cfg.Reorder = defaultPartitionReorder
+	}
+	if cfg.Fallback == "" {
+		cfg.Fallback = defaultPartitionFallback
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("cannot create partition directory: %w", err)
+	}
+	return &Partitioner{cfg: cfg, open: map[string]*partitionFile{}, parts: map[string]*Partition{}}, nil
+}
+
+// Write writes the lines in p as records. A line without a trailing newline is kept until the rest of it is written.
+func (p *Partitioner) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	data := append(p.partial, b...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		if err := p.add(data[:i+1]); err != nil {
+			return len(b), err
+		}
+		data = data[i+1:]
+	}
+	p.partial = bytes.Clone(data)
+	return len(b), nil
+}
+
+// Flush writes the buffered records of the open partitions to their files. Records held for reordering are
+// kept, as writing them now could put them out of order.
+func (p *Partitioner) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var err error
+	for _, f := range p.open {
+		err = errors.Join(err, f.bw.Flush())
+	}
+	return err
+}
+
+// Close writes the held records, including a line without a trailing newline, and closes the partitions.
+func (p *Partitioner) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(bytes.TrimSpace(p.partial)) > 0 {
+		if err := p.add(append(p.partial, '\n')); err != nil {
+			return err
+		}
+	}
+	p.partial = nil
+	for p.pending.Len() > 0 {
+		if err := p.write(heap.Pop(&p.pending).(partitionRecord)); err != nil {
+			return err
+		}
+	}
+	var err error
+	for day := range p.open {
+		err = errors.Join(err, p.closeDay(day))
+	}
+	return err
+}
+
+// Partitions returns the partitions written so far, in order of their days.
+func (p *Partitioner) Partitions() []Partition {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	parts := make([]Partition, 0, len(p.parts))
+	for _, part := range p.parts {
+		parts = append(parts, *part)
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Day < parts[j].Day })
+	return parts
+}
+
+// add holds a record terminated by a newline, writing the earliest held record once the buffer is full.
+// Records without a valid timestamp are written to the fallback partition at once.
+func (p *Partitioner) add(record []byte) error {
+	if len(bytes.TrimSpace(record)) == 0 {
+		return nil
+	}
+	t, ok := p.recordTime(record)
+	if !ok {
+		return p.write(partitionRecord{day: p.cfg.Fallback, record: bytes.Clone(record)})
+	}
+	p.seq++
+	rec := partitionRecord{time: t, seq: p.seq, day: t.In(p.cfg.Location).Format(time.DateOnly), record: bytes.Clone(record)}
+	if p.cfg.Reorder < 0 {
+		return p.write(rec)
+	}
+	heap.Push(&p.pending, rec)
+	if p.pending.Len() > p.cfg.Reorder {
+		return p.write(heap.Pop(&p.pending).(partitionRecord))
+	}
+	return nil
+}
+
+// recordTime returns the timestamp of the record, and whether it has a valid one.
+func (p *Partitioner) recordTime(record []byte) (time.Time, bool) {
+	labels, values, err := decodeRecord(record)
+	if err != nil {
+		return time.Time{}, false
+	}
+	for i, label := range labels {
+		if label != p.cfg.TimeField {
+			continue
+		}
+		if p.cfg.TimeLayout == "" {
+			return parser.ParseTime(values[i])
+		}
+		t, err := time.Parse(p.cfg.TimeLayout, values[i])
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+// write writes the record to the file of its partition. Partitions of days before that of a timed record are
+// closed, since the buffer has passed their end.
+func (p *Partitioner) write(rec partitionRecord) error {
+	timed := !rec.time.IsZero()
+	if timed {
+		for day := range p.open {
+			if day < rec.day && day != p.cfg.Fallback {
+				if err := p.closeDay(day); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	f, ok := p.open[rec.day]
+	if !ok {
+		var err error
+		if f, err = p.openDay(rec.day); err != nil {
+			return err
+		}
+	}
+	if _, err := f.bw.Write(rec.record); err != nil {
+		return err
+	}
+	part := p.parts[rec.day]
+	part.Rows++
+	if timed && rec.day <= p.closed {
+		part.Late++
+	}
+	return nil
+}
+
+// openDay opens the file of the partition of the day for appending, creating it if needed.
+func (p *Partitioner) openDay(day string) (*partitionFile, error) {
+	name := filepath.Join("dt="+day, p.cfg.Prefix+".json")
+	path := filepath.Clean(filepath.Join(p.cfg.Dir, name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("cannot create partition: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create partition: %w", err)
+	}
+	f := &partitionFile{file: file, bw: bufio.NewWriter(file)}
+	p.open[day] = f
+	if _, ok := p.parts[day]; !ok {
+		p.parts[day] = &Partition{Name: filepath.ToSlash(name), Day: day}
+	}
+	return f, nil
+}
+
+// closeDay flushes and closes the file of the partition of the day.
+func (p *Partitioner) closeDay(day string) error {
+	f := p.open[day]
+	delete(p.open, day)
+	if day != p.cfg.Fallback && day > p.closed {
+		p.closed = day
+	}
+	return errors.Join(f.bw.Flush(), f.file.Close())
+}
@@ -0,0 +1,135 @@
+package sink
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	parser "github.com/nekrassov01/access-log-parser"
+)
+
+func TestNewPartitioner(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     PartitionConfig
+		wantErr bool
+	}{
+		{
+			name:    "basic",
+			cfg:     PartitionConfig{Dir: t.TempDir(), TimeField: "time"},
+			wantErr: false,
+		},
+		{
+			name:    "empty dir",
+			cfg:     PartitionConfig{TimeField: "time"},
+			wantErr: true,
+		},
+		{
+			name:    "empty time field",
+			cfg:     PartitionConfig{Dir: t.TempDir()},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewPartitioner(tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPartitioner(t *testing.T) {
+	input := "{\"time\":\"2019-02-16T23:59:58Z\",\"n\":1}\n" +
+		"{\"time\":\"2019-02-17T00:00:01Z\",\"n\":2}\n" +
+		"{\"time\":\"2019-02-16T23:59:59Z\",\"n\":3}\n" +
+		"{\"time\":\"-\",\"n\":4}\n" +
+		"{\"time\":\"2019-02-17T00:00:02Z\",\"n\":5}\n" +
+		"{\"time\":\"2019-02-17T00:00:03Z\",\"n\":6}\n" +
+		"{\"time\":\"2019-02-16T23:59:57Z\",\"n\":7}\n" +
+		"{\"time\":\"2019-02-17T15:00:00Z\",\"n\":8}"
+	tests := []struct {
+		name      string
+		cfg       PartitionConfig
+		wantFiles map[string]string
+		want      []Partition
+	}{
+		{
+			name: "reordered",
+			cfg:  PartitionConfig{TimeField: "time", Reorder: 2},
+			wantFiles: map[string]string{
+				"dt=2019-02-16/part.json": "{\"time\":\"2019-02-16T23:59:58Z\",\"n\":1}\n{\"time\":\"2019-02-16T23:59:59Z\",\"n\":3}\n{\"time\":\"2019-02-16T23:59:57Z\",\"n\":7}\n",
+				"dt=2019-02-17/part.json": "{\"time\":\"2019-02-17T00:00:01Z\",\"n\":2}\n{\"time\":\"2019-02-17T00:00:02Z\",\"n\":5}\n{\"time\":\"2019-02-17T00:00:03Z\",\"n\":6}\n{\"time\":\"2019-02-17T15:00:00Z\",\"n\":8}\n",
+				"dt=unknown/part.json":    "{\"time\":\"-\",\"n\":4}\n",
+			},
+			want: []Partition{
+				{Name: "dt=2019-02-16/part.json", Day: "2019-02-16", Rows: 3, Late: 1},
+				{Name: "dt=2019-02-17/part.json", Day: "2019-02-17", Rows: 4},
+				{Name: "dt=unknown/part.json", Day: "unknown", Rows: 1},
+			},
+		},
+		{
+			name: "location",
+			cfg:  PartitionConfig{TimeField: "time", Prefix: "access", Location: time.FixedZone("JST", 9*60*60), Fallback: "none"},
+			wantFiles: map[string]string{
+				"dt=2019-02-17/access.json": "{\"time\":\"2019-02-16T23:59:57Z\",\"n\":7}\n{\"time\":\"2019-02-16T23:59:58Z\",\"n\":1}\n{\"time\":\"2019-02-16T23:59:59Z\",\"n\":3}\n{\"time\":\"2019-02-17T00:00:01Z\",\"n\":2}\n{\"time\":\"2019-02-17T00:00:02Z\",\"n\":5}\n{\"time\":\"2019-02-17T00:00:03Z\",\"n\":6}\n",
+				"dt=2019-02-18/access.json": "{\"time\":\"2019-02-17T15:00:00Z\",\"n\":8}\n",
+				"dt=none/access.json":       "{\"time\":\"-\",\"n\":4}\n",
+			},
+			want: []Partition{
+				{Name: "dt=2019-02-17/access.json", Day: "2019-02-17", Rows: 6},
+				{Name: "dt=2019-02-18/access.json", Day: "2019-02-18", Rows: 1},
+				{Name: "dt=none/access.json", Day: "none", Rows: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Dir = t.TempDir()
+			p, err := NewPartitioner(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := p.Write([]byte(input)); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Close(); err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.wantFiles {
+				b, err := os.ReadFile(filepath.Join(tt.cfg.Dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != want {
+					t.Errorf("%s\ngot:\n%v\nwant:\n%v\n", name, string(b), want)
+				}
+			}
+			if got := p.Partitions(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartitioner_parser(t *testing.T) {
+	dir := t.TempDir()
+	p, err := NewPartitioner(PartitionConfig{Dir: dir, TimeField: "time"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := "time:[16/Feb/2019:23:59:59 +0000]\tpath:/a\ntime:[17/Feb/2019:08:00:00 +0900]\tpath:/b\n"
+	if _, err := parser.NewLTSVParser(context.Background(), p, parser.Option{}).ParseString(input); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := []Partition{{Name: "dt=2019-02-16/part.json", Day: "2019-02-16", Rows: 2}}
+	if got := p.Partitions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
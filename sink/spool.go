@@ -0,0 +1,262 @@
+// Package sink provides output writers for delivering parsed records to downstream systems.
+// Spool gives at-least-once delivery semantics to network sinks such as HTTP endpoints or
+// message brokers: records are batched, persisted to an on-disk spool before delivery, and
+// removed only after the delivery is acknowledged, so that a conversion survives downstream
+// outages and restarts without data loss. Records may be delivered more than once.
+// Deliverers for well-known destinations, such as Grafana Loki, are provided to be used with Spool.
+package sink
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// default settings of Config
+const (
+	defaultBatchSize  = 500
+	defaultMaxRetries = 3
+	defaultBackoff    = 100 * time.Millisecond
+)
+
+// batchExt is the file extension of persisted batches in the spool directory.
+const batchExt = ".batch"
+
+// rejectedDir is the directory in the spool directory that permanently rejected batches are moved to by default.
+const rejectedDir = "rejected"
+
+// ErrPermanent is wrapped by the errors returned by Permanent.
+var ErrPermanent = errors.New("permanent delivery failure")
+
+// DeliverFunc is a function type that sends a batch of records to the downstream system.
+// Returning nil acknowledges the batch, which is then removed from the spool. Returning an error made by
+// Permanent rejects the batch, which is then moved to the dead-letter directory without retries.
+type DeliverFunc func(ctx context.Context, batch [][]byte) error
+
+// Permanent marks the error of a delivery as permanent, such as when the downstream system rejects the batch
+// as malformed, so that the batch is not retried. Other errors are treated as transient.
+func Permanent(err error) error {
+	return fmt.Errorf("%w: %w", ErrPermanent, err)
+}
+
+// Config defines the spool settings.
+type Config struct {
+	Dir           string        // directory to persist batches in
+	DeadLetterDir string        // directory to move permanently rejected batches to (empty means "rejected" in Dir)
+	BatchSize     int           // number of records in a batch (0 means 500)
+	MaxRetries    int           // number of delivery attempts for a batch before giving up (0 means 3)
+	Backoff       time.Duration // wait before the first retry, doubled on each retry (0 means 100ms)
+}
+
+// Spool is an io.Writer that delivers each line written to it as a record with at-least-once semantics.
+// It implements Flush, so the parsers of this module flush pending records at the end of parsing.
+// Batches that could not be delivered stay in the spool directory and are replayed by Open on the next run,
+// while batches rejected permanently are moved to the dead-letter directory to be inspected.
+type Spool struct {
+	mu      sync.Mutex
+	ctx     context.Context
+	cfg     Config
+	deliver DeliverFunc
+	pending [][]byte
+	partial []byte
+	seq     uint64
+}
+
+// Open initializes a Spool in the directory of the config, and replays batches left there by a previous run.
+func Open(ctx context.Context, cfg Config, deliver DeliverFunc) (*Spool, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("empty spool directory")
+	}
+	if deliver == nil {
+		return nil, errors.New("nil deliver function")
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = defaultMaxRetries
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = defaultBackoff
+	}
+	if cfg.DeadLetterDir == "" {
+		cfg.DeadLetterDir = filepath.Join(cfg.Dir, rejectedDir)
+	}
+	for _, dir := range []string{cfg.Dir, cfg.DeadLetterDir} {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, fmt.Errorf("cannot create spool directory: %w", err)
+		}
+	}
+	s := &Spool{ctx: ctx, cfg: cfg, deliver: deliver}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Write buffers the lines in p as records, and delivers a batch each time it is full.
+// A line without a trailing newline is kept until the rest of it is written.
+func (s *Spool) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data := append(s.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		s.pending = append(s.pending, bytes.Clone(data[:i]))
+		data = data[i+1:]
+		if len(s.pending) >= s.cfg.BatchSize {
+			if err := s.flush(); err != nil {
+				s.partial = bytes.Clone(data)
+				return len(p), err
+			}
+		}
+	}
+	s.partial = bytes.Clone(data)
+	return len(p), nil
+}
+
+// Flush delivers the pending records as a batch.
+func (s *Spool) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
+
+// Close delivers the pending records including a line without a trailing newline.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.partial) > 0 {
+		s.pending = append(s.pending, s.partial)
+		s.partial = nil
+	}
+	return s.flush()
+}
+
+// Pending returns the number of batches in the spool directory that are not acknowledged yet.
+func (s *Spool) Pending() (int, error) {
+	files, err := batches(s.cfg.Dir)
+	return len(files), err
+}
+
+// Rejected returns the number of batches in the dead-letter directory.
+func (s *Spool) Rejected() (int, error) {
+	files, err := batches(s.cfg.DeadLetterDir)
+	return len(files), err
+}
+
+// flush persists the pending records as a batch file before delivering it.
+func (s *Spool) flush() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	s.seq++
+	name := filepath.Join(s.cfg.Dir, fmt.Sprintf("%020d-%06d%s", time.Now().UnixNano(), s.seq, batchExt))
+	tmp := name + ".tmp"
+	if err := writeFileSync(tmp, append(bytes.Join(s.pending, []byte("\n")), '\n')); err != nil {
+		return fmt.Errorf("cannot persist batch: %w", err)
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return fmt.Errorf("cannot persist batch: %w", err)
+	}
+	batch := s.pending
+	s.pending = nil
+	return s.send(name, batch)
+}
+
+// replay delivers the batches left in the spool directory in the order they were persisted.
+func (s *Spool) replay() error {
+	files, err := batches(s.cfg.Dir)
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		b, err := os.ReadFile(filepath.Clean(name))
+		if err != nil {
+			return fmt.Errorf("cannot read batch: %w", err)
+		}
+		if err := s.send(name, bytes.Split(bytes.TrimSuffix(b, []byte("\n")), []byte("\n"))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send delivers the batch with retries and exponential backoff, and removes the batch file when acknowledged,
+// or moves it to the dead-letter directory when rejected permanently.
+func (s *Spool) send(name string, batch [][]byte) error {
+	backoff := s.cfg.Backoff
+	var err error
+	for i := 0; i < s.cfg.MaxRetries; i++ {
+		if i > 0 {
+			t := time.NewTimer(backoff)
+			select {
+			case <-s.ctx.Done():
+				t.Stop()
+				return fmt.Errorf("batch %s left in spool: %w", filepath.Base(name), s.ctx.Err())
+			case <-t.C:
+			}
+			backoff *= 2
+		}
+		err = s.deliver(s.ctx, batch)
+		if err == nil {
+			return os.Remove(name)
+		}
+		if errors.Is(err, ErrPermanent) {
+			return s.reject(name)
+		}
+	}
+	return fmt.Errorf("batch %s left in spool after %d attempts: %w", filepath.Base(name), s.cfg.MaxRetries, err)
+}
+
+// reject moves the batch file to the dead-letter directory.
+func (s *Spool) reject(name string) error {
+	if err := os.Rename(name, filepath.Join(s.cfg.DeadLetterDir, filepath.Base(name))); err != nil {
+		return fmt.Errorf("cannot move rejected batch: %w", err)
+	}
+	return nil
+}
+
+// writeFileSync writes the data to the file and syncs it to the disk before closing it, so that the file is
+// complete once renamed.
+func writeFileSync(name string, data []byte) error {
+	f, err := os.OpenFile(filepath.Clean(name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		return errors.Join(err, f.Close())
+	}
+	if err := f.Sync(); err != nil {
+		return errors.Join(err, f.Close())
+	}
+	return f.Close()
+}
+
+// batches returns the batch files in the directory sorted in the order they were persisted,
+// which is the lexical order of the zero-padded file names.
+func batches(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read spool directory: %w", err)
+	}
+	files := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), batchExt) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, e.Name()))
+	}
+	sort.Strings(files)
+	return files, nil
+}
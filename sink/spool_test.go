@@ -0,0 +1,184 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	parser "github.com/nekrassov01/access-log-parser"
+)
+
+type recorder struct {
+	batches [][]string
+	fails   int
+	reject  string
+}
+
+func (r *recorder) deliver(_ context.Context, batch [][]byte) error {
+	if r.fails > 0 {
+		r.fails--
+		return errors.New("downstream unavailable")
+	}
+	for _, b := range batch {
+		if string(b) == r.reject {
+			return Permanent(errors.New("malformed record"))
+		}
+	}
+	s := make([]string, len(batch))
+	for i, b := range batch {
+		s[i] = string(b)
+	}
+	r.batches = append(r.batches, s)
+	return nil
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		deliver DeliverFunc
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			cfg:     Config{Dir: t.TempDir()},
+			deliver: (&recorder{}).deliver,
+			wantErr: false,
+		},
+		{
+			name:    "empty dir",
+			cfg:     Config{},
+			deliver: (&recorder{}).deliver,
+			wantErr: true,
+		},
+		{
+			name:    "nil deliver",
+			cfg:     Config{Dir: t.TempDir()},
+			deliver: nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Open(context.Background(), tt.cfg, tt.deliver); (err != nil) != tt.wantErr {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSpool_Write(t *testing.T) {
+	rec := &recorder{}
+	s, err := Open(context.Background(), Config{Dir: t.TempDir(), BatchSize: 2}, rec.deliver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"a\nb", "\nc\n", "d"} {
+		if _, err := s.Write([]byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"a", "b"}, {"c", "d"}}
+	if !reflect.DeepEqual(rec.batches, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", rec.batches, want)
+	}
+	if n, err := s.Pending(); err != nil || n != 0 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", n, 0)
+	}
+}
+
+func TestSpool_replay(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{Dir: dir, BatchSize: 10, MaxRetries: 2, Backoff: time.Millisecond}
+
+	// the downstream is down during the first run
+	down := &recorder{fails: 100}
+	s, err := Open(context.Background(), cfg, down.deliver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Write([]byte("a\nb\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err == nil {
+		t.Fatal("delivery must fail while the downstream is down")
+	}
+	if n, _ := s.Pending(); n != 1 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", n, 1)
+	}
+
+	// the batch left in the spool is delivered by the next run, recovering from a transient failure
+	up := &recorder{fails: 1}
+	s, err = Open(context.Background(), cfg, up.deliver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"a", "b"}}
+	if !reflect.DeepEqual(up.batches, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", up.batches, want)
+	}
+	if n, _ := s.Pending(); n != 0 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", n, 0)
+	}
+}
+
+func TestSpool_reject(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{Dir: dir, BatchSize: 10, MaxRetries: 5, Backoff: time.Millisecond}
+
+	// a batch rejected by the downstream is left by a previous run
+	if err := os.WriteFile(filepath.Join(dir, "00000000000000000001-000001"+batchExt), []byte("bad\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rec := &recorder{reject: "bad"}
+	s, err := Open(context.Background(), cfg, rec.deliver)
+	if err != nil {
+		t.Fatalf("a rejected batch must not fail the replay: %v", err)
+	}
+	for _, p := range []string{"a\nbad\n", "b\n"} {
+		if _, err := s.Write([]byte(p)); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := [][]string{{"b"}}
+	if !reflect.DeepEqual(rec.batches, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", rec.batches, want)
+	}
+	if n, _ := s.Pending(); n != 0 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", n, 0)
+	}
+	if n, _ := s.Rejected(); n != 2 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", n, 2)
+	}
+}
+
+func TestSpool_parser(t *testing.T) {
+	rec := &recorder{}
+	s, err := Open(context.Background(), Config{Dir: t.TempDir(), BatchSize: 100}, rec.deliver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := make([]string, 5)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("id:%d", i)
+	}
+	p := parser.NewLTSVParser(context.Background(), s, parser.Option{})
+	if _, err := p.ParseString(strings.Join(lines, "\n")); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.batches) != 1 || len(rec.batches[0]) != len(lines) {
+		t.Errorf("pending records must be flushed at the end of parsing: %v", rec.batches)
+	}
+}
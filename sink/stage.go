@@ -0,0 +1,263 @@
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Warehouse represents the data warehouse whose loading layout a Stager follows.
+type Warehouse int
+
+const (
+	BigQuery  Warehouse = iota // Google BigQuery load jobs from Cloud Storage
+	Snowflake                  // Snowflake COPY INTO from a stage
+)
+
+// default chunk sizes in uncompressed bytes, which keep compressed chunks within the limits and recommendations
+const (
+	defaultBigQueryChunkSize  = 4 << 30   // BigQuery rejects compressed NDJSON files larger than 4 GB
+	defaultSnowflakeChunkSize = 250 << 20 // Snowflake recommends files of 100-250 MB for parallel loading
+)
+
+// manifestName is the name of the manifest file written by Stager.Close.
+const manifestName = "manifest.json"
+
+// String returns the name of the warehouse.
+func (w Warehouse) String() string {
+	switch w {
+	case BigQuery:
+		return "bigquery"
+	case Snowflake:
+		return "snowflake"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler so that warehouses are encoded by name.
+func (w Warehouse) MarshalText() ([]byte, error) {
+	return []byte(w.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler so that manifests can be read back.
+func (w *Warehouse) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "bigquery":
+		*w = BigQuery
+	case "snowflake":
+		*w = Snowflake
+	default:
+		return fmt.Errorf("unknown warehouse: %q", b)
+	}
+	return nil
+}
+
+// StageConfig defines the settings of a Stager.
+type StageConfig struct {
+	Dir       string    // directory to write chunks and the manifest in, to be uploaded to the bucket or stage
+	Prefix    string    // prefix of the chunk file names (empty means "part")
+	Warehouse Warehouse // warehouse whose layout the chunks follow
+	ChunkSize int64     // maximum uncompressed bytes of a chunk (0 means the default of the warehouse)
+	Gzip      bool      // whether to compress chunks with gzip or not
+}
+
+// StageManifest describes the chunks written by a Stager, so that the load can be verified and the
+// files can be listed explicitly, such as in the FILES option of COPY INTO or the source URIs of a load job.
+type StageManifest struct {
+	Warehouse Warehouse    `json:"warehouse"` // Warehouse whose layout the chunks follow.
+	Format    string       `json:"format"`    // Source format to specify when loading.
+	Gzip      bool         `json:"gzip"`      // Whether the chunks are compressed with gzip.
+	Rows      int          `json:"rows"`      // Total number of records.
+	Files     []StagedFile `json:"files"`     // Chunks in the order written.
+}
+
+// StagedFile describes a chunk written by a Stager.
+type StagedFile struct {
+	Name  string `json:"name"`  // File name relative to the directory.
+	Rows  int    `json:"rows"`  // Number of records in the chunk.
+	Bytes int64  `json:"bytes"` // Uncompressed size of the chunk.
+}
+
+// Stager is an io.Writer that writes NDJSON records, such as the output of parser.JSONLineHandler, into
+// chunk files in the layout expected by warehouse load jobs: UTF-8 newline-delimited JSON, split into
+// chunks within the size limits, optionally compressed with gzip. Close writes a manifest of the chunks.
+type Stager struct {
+	mu       sync.Mutex
+	cfg      StageConfig
+	manifest StageManifest
+	partial  []byte
+	file     *os.File
+	gz       *gzip.Writer
+	bw       *bufio.Writer
+}
+
+// NewStager creates a Stager writing into the directory of the config.
+func NewStager(cfg StageConfig) (*Stager, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("empty stage directory")
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "part"
+	}
+	if cfg.ChunkSize <= 0 {
+		switch cfg.Warehouse {
+		case BigQuery:
+			cfg.ChunkSize = defaultBigQueryChunkSize
+		case Snowflake:
+			cfg.ChunkSize = defaultSnowflakeChunkSize
+		default:
+			return nil, fmt.Errorf("unknown warehouse: %d", cfg.Warehouse)
+		}
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("cannot create stage directory: %w", err)
+	}
+	format := "NEWLINE_DELIMITED_JSON"
+	if cfg.Warehouse == Snowflake {
+		format = "JSON"
+	}
+	return &Stager{
+		cfg:      cfg,
+		manifest: StageManifest{Warehouse: cfg.Warehouse, Format: format, Gzip: cfg.Gzip, Files: []StagedFile{}},
+	}, nil
+}
+
+// Write writes the lines in p as records, starting a new chunk when a record would exceed the chunk size.
+// A line without a trailing newline is kept until the rest of it is written.
+func (s *Stager) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data := append(s.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		if err := s.write(data[:i+1]); err != nil {
+			return len(p), err
+		}
+		data = data[i+1:]
+	}
+	s.partial = bytes.Clone(data)
+	return len(p), nil
+}
+
+// Flush writes the buffered records of the current chunk to the file.
+func (s *Stager) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bw == nil {
+		return nil
+	}
+	if err := s.bw.Flush(); err != nil {
+		return err
+	}
+	if s.gz != nil {
+		return s.gz.Flush()
+	}
+	return nil
+}
+
+// Close finishes the current chunk, including a line without a trailing newline, and writes the manifest.
+func (s *Stager) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(bytes.TrimSpace(s.partial)) > 0 {
+		if err := s.write(append(s.partial, '\n')); err != nil {
+			return err
+		}
+	}
+	s.partial = nil
+	if err := s.closeChunk(); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(s.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.cfg.Dir, manifestName), append(b, '\n'), 0o600)
+}
+
+// Manifest returns the manifest of the chunks written so far.
+func (s *Stager) Manifest() StageManifest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.manifest
+	m.Files = append([]StagedFile{}, s.manifest.Files...)
+	return m
+}
+
+// write writes a record terminated by a newline, rotating the chunk if needed. Empty lines are skipped,
+// since warehouses reject them as invalid JSON.
+func (s *Stager) write(record []byte) error {
+	if len(bytes.TrimSpace(record)) == 0 {
+		return nil
+	}
+	n := int64(len(record))
+	if s.bw != nil && s.current().Bytes+n > s.cfg.ChunkSize {
+		if err := s.closeChunk(); err != nil {
+			return err
+		}
+	}
+	if s.bw == nil {
+		if err := s.openChunk(); err != nil {
+			return err
+		}
+	}
+	if _, err := s.bw.Write(record); err != nil {
+		return err
+	}
+	f := s.current()
+	f.Rows++
+	f.Bytes += n
+	s.manifest.Rows++
+	return nil
+}
+
+// current returns the manifest entry of the current chunk.
+func (s *Stager) current() *StagedFile {
+	return &s.manifest.Files[len(s.manifest.Files)-1]
+}
+
+// openChunk creates the file of the next chunk.
+func (s *Stager) openChunk() error {
+	name := fmt.Sprintf("%s-%06d.json", s.cfg.Prefix, len(s.manifest.Files))
+	if s.cfg.Gzip {
+		name += ".gz"
+	}
+	f, err := os.Create(filepath.Clean(filepath.Join(s.cfg.Dir, name)))
+	if err != nil {
+		return fmt.Errorf("cannot create chunk: %w", err)
+	}
+	var w io.Writer = f
+	if s.cfg.Gzip {
+		s.gz = gzip.NewWriter(f)
+		w = s.gz
+	}
+	s.file = f
+	s.bw = bufio.NewWriter(w)
+	s.manifest.Files = append(s.manifest.Files, StagedFile{Name: name})
+	return nil
+}
+
+// closeChunk flushes and closes the file of the current chunk, if any.
+func (s *Stager) closeChunk() error {
+	if s.bw == nil {
+		return nil
+	}
+	err := s.bw.Flush()
+	if s.gz != nil {
+		err = errors.Join(err, s.gz.Close())
+	}
+	err = errors.Join(err, s.file.Close())
+	s.file, s.gz, s.bw = nil, nil, nil
+	return err
+}
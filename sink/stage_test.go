@@ -0,0 +1,206 @@
+package sink
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	parser "github.com/nekrassov01/access-log-parser"
+)
+
+func TestNewStager(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     StageConfig
+		wantErr bool
+	}{
+		{
+			name:    "bigquery",
+			cfg:     StageConfig{Dir: t.TempDir(), Warehouse: BigQuery},
+			wantErr: false,
+		},
+		{
+			name:    "snowflake",
+			cfg:     StageConfig{Dir: t.TempDir(), Warehouse: Snowflake},
+			wantErr: false,
+		},
+		{
+			name:    "empty dir",
+			cfg:     StageConfig{Warehouse: BigQuery},
+			wantErr: true,
+		},
+		{
+			name:    "unknown warehouse",
+			cfg:     StageConfig{Dir: t.TempDir(), Warehouse: Warehouse(9)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewStager(tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStager(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       StageConfig
+		inputs    []string
+		wantFiles map[string]string
+		want      StageManifest
+	}{
+		{
+			name:   "chunked",
+			cfg:    StageConfig{Warehouse: BigQuery, ChunkSize: 12},
+			inputs: []string{"{\"a\":1}\n{\"a\":2", "}\n\n{\"a\":3}"},
+			wantFiles: map[string]string{
+				"part-000000.json": "{\"a\":1}\n",
+				"part-000001.json": "{\"a\":2}\n",
+				"part-000002.json": "{\"a\":3}\n",
+			},
+			want: StageManifest{
+				Warehouse: BigQuery,
+				Format:    "NEWLINE_DELIMITED_JSON",
+				Rows:      3,
+				Files: []StagedFile{
+					{Name: "part-000000.json", Rows: 1, Bytes: 8},
+					{Name: "part-000001.json", Rows: 1, Bytes: 8},
+					{Name: "part-000002.json", Rows: 1, Bytes: 8},
+				},
+			},
+		},
+		{
+			name:   "gzip",
+			cfg:    StageConfig{Warehouse: Snowflake, Prefix: "logs", Gzip: true},
+			inputs: []string{"{\"a\":1}\n{\"a\":2}\n"},
+			wantFiles: map[string]string{
+				"logs-000000.json.gz": "{\"a\":1}\n{\"a\":2}\n",
+			},
+			want: StageManifest{
+				Warehouse: Snowflake,
+				Format:    "JSON",
+				Gzip:      true,
+				Rows:      2,
+				Files: []StagedFile{
+					{Name: "logs-000000.json.gz", Rows: 2, Bytes: 16},
+				},
+			},
+		},
+		{
+			name:      "empty",
+			cfg:       StageConfig{Warehouse: BigQuery},
+			inputs:    nil,
+			wantFiles: map[string]string{},
+			want: StageManifest{
+				Warehouse: BigQuery,
+				Format:    "NEWLINE_DELIMITED_JSON",
+				Files:     []StagedFile{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Dir = t.TempDir()
+			s, err := NewStager(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, p := range tt.inputs {
+				if _, err := s.Write([]byte(p)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Manifest(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+			b, err := os.ReadFile(filepath.Join(tt.cfg.Dir, manifestName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got StageManifest
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+			if !strings.Contains(string(b), `"warehouse": "`+tt.cfg.Warehouse.String()+`"`) {
+				t.Errorf("warehouse must be encoded by name: %s", b)
+			}
+			files := map[string]string{}
+			for _, f := range got.Files {
+				files[f.Name] = readChunk(t, filepath.Join(tt.cfg.Dir, f.Name), tt.cfg.Gzip)
+			}
+			if !reflect.DeepEqual(files, tt.wantFiles) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", files, tt.wantFiles)
+			}
+		})
+	}
+}
+
+func TestStager_parser(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStager(StageConfig{Dir: dir, Warehouse: BigQuery, ChunkSize: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := make([]string, 10)
+	for i := range lines {
+		lines[i] = "id:" + strings.Repeat("x", i)
+	}
+	p := parser.NewLTSVParser(context.Background(), s, parser.Option{})
+	if _, err := p.ParseString(strings.Join(lines, "\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	m := s.Manifest()
+	if m.Rows != len(lines) || len(m.Files) < 2 {
+		t.Fatalf("records must be split into chunks: %+v", m)
+	}
+	for _, f := range m.Files {
+		if f.Bytes > 64 {
+			t.Errorf("chunk exceeds the chunk size: %+v", f)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(readChunk(t, filepath.Join(dir, f.Name), false)), "\n") {
+			if !json.Valid([]byte(line)) {
+				t.Errorf("invalid record: %q", line)
+			}
+		}
+	}
+}
+
+func readChunk(t *testing.T, path string, gz bool) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if gz {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// Snapshot is the state of the counters of a parse at a point in time, taken periodically with Option.Snapshot
+// so that long-running streams can be monitored before they end.
+type Snapshot struct {
+	Time          time.Time     // time the snapshot was taken
+	Elapsed       time.Duration // time since the parse started
+	Total         int           // number of lines read so far
+	Matched       int           // number of lines matched so far
+	Unmatched     int           // number of lines unmatched so far
+	Excluded      int           // number of lines excluded so far
+	Skipped       int           // number of lines skipped so far
+	Errors        int           // number of unmatched lines kept in Result.Errors
+	DroppedErrors int           // number of unmatched lines not kept in Result.Errors
+	HeapAlloc     uint64        // bytes of allocated heap objects of the process
+}
+
+// SnapshotFunc is a function type called with each snapshot taken with Option.Snapshot. It returns a line to be
+// written to the output, or an empty string to write nothing.
+type SnapshotFunc func(s Snapshot) (string, error)
+
+// snapshotter takes snapshots of a parse at the interval of Option.Snapshot. Snapshots are taken by the parsing
+// loop as lines arrive rather than by a separate goroutine, so that the counters are read without locks.
+type snapshotter struct {
+	ticker *time.Ticker
+	start  time.Time
+	fn     SnapshotFunc
+}
+
+// newSnapshotter initializes a snapshotter with the interval and hook from the option, or returns nil if
+// snapshots are disabled. If no hook is set, a summary record with the label "snapshot" and the counters is
+// emitted through the line handler.
+func newSnapshotter(start time.Time, opt Option) *snapshotter {
+	if opt.Snapshot <= 0 {
+		return nil
+	}
+	s := &snapshotter{ticker: time.NewTicker(opt.Snapshot), start: start, fn: opt.OnSnapshot}
+	if s.fn == nil {
+		s.fn = func(s Snapshot) (string, error) {
+			return opt.LineHandler(
+				[]string{"snapshot", "elapsed", "total", "matched", "unmatched", "excluded", "skipped", "dropped_errors"},
+				[]string{
+					s.Time.Format(time.RFC3339),
+					s.Elapsed.Round(time.Millisecond).String(),
+					strconv.Itoa(s.Total),
+					strconv.Itoa(s.Matched),
+					strconv.Itoa(s.Unmatched),
+					strconv.Itoa(s.Excluded),
+					strconv.Itoa(s.Skipped),
+					strconv.Itoa(s.DroppedErrors),
+				},
+				false,
+			)
+		}
+	}
+	return s
+}
+
+// take returns the line of a snapshot of the result with n lines read, if the interval has passed since the
+// last one, or an empty string otherwise.
+func (s *snapshotter) take(r *Result, n int) (string, error) {
+	if s == nil {
+		return "", nil
+	}
+	select {
+	case now := <-s.ticker.C:
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		return s.fn(Snapshot{
+			Time:          now,
+			Elapsed:       now.Sub(s.start),
+			Total:         n,
+			Matched:       r.Matched,
+			Unmatched:     r.Unmatched,
+			Excluded:      r.Excluded,
+			Skipped:       r.Skipped,
+			Errors:        len(r.Errors),
+			DroppedErrors: r.DroppedErrors,
+			HeapAlloc:     m.HeapAlloc,
+		})
+	default:
+		return "", nil
+	}
+}
+
+// stop stops the ticker of the snapshotter, if any.
+func (s *snapshotter) stop() {
+	if s != nil {
+		s.ticker.Stop()
+	}
+}
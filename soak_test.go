@@ -0,0 +1,92 @@
+//go:build soak
+
+package parser
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nekrassov01/access-log-parser/loggen"
+)
+
+var soakDuration = flag.Duration("soak.duration", time.Minute, "duration of TestSoak")
+
+// soakSlack is the growth of the live heap tolerated after the warm-up, for the noise of the runtime.
+const soakSlack = 8 << 20
+
+// TestSoak streams generated lines, a tenth of them unmatched and unique, through a parser for the duration
+// and fails if the live heap keeps growing after a warm-up of a fifth of the duration.
+//
+//	go test -tags soak -run TestSoak -timeout 0 . -soak.duration 168h
+func TestSoak(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), *soakDuration)
+	defer cancel()
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		g := loggen.New(loggen.Config{Format: loggen.FormatS3, Seed: 1})
+		for i := 0; ctx.Err() == nil; i++ {
+			line := g.Line()
+			if i%10 == 0 {
+				line = fmt.Sprintf("unmatched line %d", i)
+			}
+			if _, err := fmt.Fprintln(pw, line); err != nil {
+				return
+			}
+		}
+		pw.Close()
+	}()
+	var snapshots atomic.Int64
+	opt := Option{
+		MaxErrors: 1000,
+		Snapshot:  time.Second,
+		OnSnapshot: func(s Snapshot) (string, error) {
+			snapshots.Add(1)
+			if s.Errors > 1000 {
+				t.Errorf("\ngot:\n%v\nwant:\n<= %v\n", s.Errors, 1000)
+			}
+			return "", nil
+		},
+	}
+	done := make(chan struct{})
+	var r *Result
+	var err error
+	go func() {
+		defer close(done)
+		r, err = NewS3RegexParser(ctx, io.Discard, opt).Parse(pr)
+	}()
+
+	live := func() uint64 {
+		runtime.GC()
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		return m.HeapAlloc
+	}
+	time.Sleep(*soakDuration / 5)
+	baseline := live()
+	ticker := time.NewTicker(max(*soakDuration/100, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			if err != nil && ctx.Err() == nil {
+				t.Fatal(err)
+			}
+			if r.Total == 0 || len(r.Errors) != 1000 || r.DroppedErrors == 0 || snapshots.Load() == 0 {
+				t.Errorf("\ngot:\n%v %v %v %v\nwant:\n%v\n", r.Total, len(r.Errors), r.DroppedErrors, snapshots.Load(), "lines, 1000 errors, dropped errors and snapshots")
+			}
+			t.Logf("%d lines, %d snapshots, live heap %d bytes after warm-up", r.Total, snapshots.Load(), baseline)
+			return
+		case <-ticker.C:
+			if heap := live(); heap > baseline+soakSlack {
+				t.Fatalf("\ngot:\n%v\nwant:\n<= %v\n", heap, baseline+soakSlack)
+			}
+		}
+	}
+}
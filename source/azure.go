@@ -0,0 +1,171 @@
+package source
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// azureError is the error message prefix for failures of AzureBlob.
+const azureError = "azure blob"
+
+// azureAPIVersion is the version of the Blob service REST API requested.
+const azureAPIVersion = "2021-08-06"
+
+// AzureBlobConfig defines the container of Azure Blob Storage to read from.
+type AzureBlobConfig struct {
+	Account   string       // storage account name
+	Container string       // container name
+	SAS       string       // shared access signature token with read and list permissions (empty means anonymous access)
+	Endpoint  string       // base URL of the blob service, such as that of Azurite (empty means https://<account>.blob.core.windows.net)
+	Client    *http.Client // client to send requests with, which may add authorization such as bearer tokens (nil means http.DefaultClient)
+}
+
+// AzureBlob is a Remote that lists and streams blobs of an Azure Blob Storage container. Blobs are pinned to
+// the ETag seen when listed, so that a blob overwritten in between is reported instead of read half-changed.
+type AzureBlob struct {
+	cfg   AzureBlobConfig
+	base  string
+	query url.Values
+	mu    sync.Mutex
+	etags map[string]string
+}
+
+// azureListResult is the response of the List Blobs operation.
+type azureListResult struct {
+	Blobs []struct {
+		Name       string `xml:"Name"`
+		Properties struct {
+			ETag string `xml:"Etag"`
+		} `xml:"Properties"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// NewAzureBlob returns a Remote reading the container in cfg.
+func NewAzureBlob(cfg AzureBlobConfig) (*AzureBlob, error) {
+	if cfg.Container == "" {
+		return nil, fmt.Errorf("%s: empty container name", azureError)
+	}
+	if cfg.Endpoint == "" {
+		if cfg.Account == "" {
+			return nil, fmt.Errorf("%s: empty account name", azureError)
+		}
+		cfg.Endpoint = "https://" + cfg.Account + ".blob.core.windows.net"
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	query, err := url.ParseQuery(strings.TrimPrefix(cfg.SAS, "?"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid SAS token: %w", azureError, err)
+	}
+	return &AzureBlob{
+		cfg:   cfg,
+		base:  strings.TrimSuffix(cfg.Endpoint, "/") + "/" + url.PathEscape(cfg.Container),
+		query: query,
+		etags: map[string]string{},
+	}, nil
+}
+
+// List returns the names of the blobs matching the glob pattern, such as "AWSLogs/2024/*/*.log.gz". The blobs
+// are listed by the literal prefix of the pattern, and wildcards do not match "/".
+func (a *AzureBlob) List(ctx context.Context, pattern string) ([]string, error) {
+	var names []string
+	etags := map[string]string{}
+	marker := ""
+	for {
+		q := a.params()
+		q.Set("restype", "container")
+		q.Set("comp", "list")
+		if prefix := globPrefix(pattern); prefix != "" {
+			q.Set("prefix", prefix)
+		}
+		if marker != "" {
+			q.Set("marker", marker)
+		}
+		body, err := httpGet(ctx, a.cfg.Client, azureError, a.base+"?"+q.Encode(), a.header())
+		if err != nil {
+			return nil, err
+		}
+		var res azureListResult
+		err = xml.NewDecoder(body).Decode(&res)
+		body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: cannot decode blob list: %w", azureError, err)
+		}
+		for _, b := range res.Blobs {
+			names = append(names, b.Name)
+			etags[b.Name] = b.Properties.ETag
+		}
+		if res.NextMarker == "" {
+			break
+		}
+		marker = res.NextMarker
+	}
+	matched, err := matchObjects(pattern, names)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", azureError, err)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, name := range matched {
+		a.etags[name] = etags[name]
+	}
+	return matched, nil
+}
+
+// Open opens the named blob, pinned to the ETag seen by List if it has been listed.
+func (a *AzureBlob) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	a.mu.Lock()
+	etag := a.etags[name]
+	a.mu.Unlock()
+	header := a.header()
+	if etag != "" {
+		header.Set("If-Match", etag)
+	}
+	rc, err := httpGet(ctx, a.cfg.Client, azureError, a.blobURL(name, a.params()), header)
+	if etag != "" && hasStatus(err, http.StatusPreconditionFailed) {
+		return nil, fmt.Errorf("%s: %s: %w", azureError, name, ErrChangedSinceListed)
+	}
+	return rc, err
+}
+
+// OpenVersion opens the given version of the named blob, for containers with blob versioning enabled.
+func (a *AzureBlob) OpenVersion(ctx context.Context, name, versionID string) (io.ReadCloser, error) {
+	q := a.params()
+	q.Set("versionid", versionID)
+	return httpGet(ctx, a.cfg.Client, azureError, a.blobURL(name, q), a.header())
+}
+
+// blobURL returns the URL of the named blob with the query parameters.
+func (a *AzureBlob) blobURL(name string, q url.Values) string {
+	segments := strings.Split(name, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	u := a.base + "/" + strings.Join(segments, "/")
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	return u
+}
+
+// params returns a copy of the query parameters of the SAS token.
+func (a *AzureBlob) params() url.Values {
+	q := make(url.Values, len(a.query)+4)
+	for k, v := range a.query {
+		q[k] = v
+	}
+	return q
+}
+
+// header returns the headers sent with every request.
+func (a *AzureBlob) header() http.Header {
+	return http.Header{"X-Ms-Version": []string{azureAPIVersion}}
+}
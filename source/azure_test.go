@@ -0,0 +1,141 @@
+package source
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	parser "github.com/nekrassov01/access-log-parser"
+)
+
+// gzipBytes returns s compressed with gzip.
+func gzipBytes(t *testing.T, s string) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	zw := gzip.NewWriter(buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// fakeAzure serves List Blobs in pages of one blob and Get Blob with If-Match and versionid.
+func fakeAzure(t *testing.T, blobs map[string][]byte, etags map[string]string) *httptest.Server {
+	t.Helper()
+	names := []string{"logs/2024/01/a.log", "logs/2024/01/b.log.gz", "logs/2024/01/sub/c.log", "other/d.log"}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sig") != "secret" || r.Header.Get("X-Ms-Version") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path == "/logs-container" && r.URL.Query().Get("comp") == "list" {
+			i := 0
+			fmt.Sscan(r.URL.Query().Get("marker"), &i)
+			prefix := r.URL.Query().Get("prefix")
+			for i < len(names) && !strings.HasPrefix(names[i], prefix) {
+				i++
+			}
+			fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs>`)
+			if i < len(names) {
+				fmt.Fprintf(w, `<Blob><Name>%s</Name><Properties><Etag>%s</Etag></Properties></Blob>`, names[i], etags[names[i]])
+			}
+			fmt.Fprint(w, `</Blobs><NextMarker>`)
+			if i+1 < len(names) {
+				fmt.Fprint(w, i+1)
+			}
+			fmt.Fprint(w, `</NextMarker></EnumerationResults>`)
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, "/logs-container/")
+		if v := r.URL.Query().Get("versionid"); v != "" {
+			name += "@" + v
+		}
+		b, ok := blobs[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if m := r.Header.Get("If-Match"); m != "" && m != etags[name] {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		w.Write(b)
+	}))
+}
+
+func TestAzureBlob(t *testing.T) {
+	blobs := map[string][]byte{
+		"logs/2024/01/a.log":    []byte("GET /a 200\n"),
+		"logs/2024/01/b.log.gz": gzipBytes(t, "GET /b 500\n"),
+		"logs/2024/01/a.log@v1": []byte("GET /old 200\n"),
+	}
+	etags := map[string]string{"logs/2024/01/a.log": `"0x1"`, "logs/2024/01/b.log.gz": `"0x2"`}
+	srv := fakeAzure(t, blobs, etags)
+	defer srv.Close()
+
+	a, err := NewAzureBlob(AzureBlobConfig{Container: "logs-container", SAS: "?sig=secret", Endpoint: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	got, err := a.List(ctx, "logs/2024/*/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"logs/2024/01/a.log", "logs/2024/01/b.log.gz"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+
+	out := &bytes.Buffer{}
+	p := parser.NewRegexParser(ctx, out, parser.Option{LineHandler: parser.LTSVLineHandler})
+	if err := p.AddPattern(`^(?P<method>\S+) (?P<path>\S+) (?P<status>\d+)$`); err != nil {
+		t.Fatal(err)
+	}
+	if err := Parse(ctx, p, a, "logs/2024/*/*", nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := "method:GET\tpath:/a\tstatus:200\nmethod:GET\tpath:/b\tstatus:500\n"; out.String() != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", out.String(), want)
+	}
+
+	rc, err := a.OpenVersion(ctx, "logs/2024/01/a.log", "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(b) != "GET /old 200\n" {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", string(b), "GET /old 200\n")
+	}
+
+	etags["logs/2024/01/a.log"] = `"0x3"`
+	if _, err := a.Open(ctx, "logs/2024/01/a.log"); !errors.Is(err, ErrChangedSinceListed) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, ErrChangedSinceListed)
+	}
+	if _, err := a.Open(ctx, "logs/missing.log"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "404")
+	}
+
+	denied, err := NewAzureBlob(AzureBlobConfig{Container: "logs-container", Endpoint: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := denied.List(ctx, "*"); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "403")
+	}
+	if _, err := NewAzureBlob(AzureBlobConfig{Container: "logs"}); err == nil {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "empty account name")
+	}
+}
@@ -0,0 +1,119 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	parser "github.com/nekrassov01/access-log-parser"
+)
+
+// fakeGCS serves objects.list in pages of one object and objects.get with generation.
+func fakeGCS(objects map[string][]byte, generations map[string]string) *httptest.Server {
+	names := []string{"logs/2024-01/a.json", "logs/2024-01/b.json.gz", "logs/2024-02/c.txt"}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const base = "/storage/v1/b/my-bucket/o"
+		if r.URL.Path == base {
+			type item struct {
+				Name       string `json:"name"`
+				Generation string `json:"generation"`
+			}
+			res := struct {
+				Items         []item `json:"items"`
+				NextPageToken string `json:"nextPageToken,omitempty"`
+			}{}
+			var rest []string
+			for _, name := range names {
+				if strings.HasPrefix(name, r.URL.Query().Get("prefix")) && name > r.URL.Query().Get("pageToken") {
+					rest = append(rest, name)
+				}
+			}
+			if len(rest) > 0 {
+				res.Items = []item{{Name: rest[0], Generation: generations[rest[0]]}}
+			}
+			if len(rest) > 1 {
+				res.NextPageToken = rest[0]
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, base+"/")
+		if r.URL.Query().Get("alt") != "media" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if g := r.URL.Query().Get("generation"); g != "" && g != generations[name] {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		b, ok := objects[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(b)
+	}))
+}
+
+func TestGCS(t *testing.T) {
+	objects := map[string][]byte{
+		"logs/2024-01/a.json":    []byte(`{"path":"/a"}` + "\n"),
+		"logs/2024-01/b.json.gz": gzipBytes(t, `{"path":"/b"}`+"\n"),
+	}
+	generations := map[string]string{"logs/2024-01/a.json": "1", "logs/2024-01/b.json.gz": "2"}
+	srv := fakeGCS(objects, generations)
+	defer srv.Close()
+
+	g, err := NewGCS(GCSConfig{Bucket: "my-bucket", Endpoint: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	got, err := g.List(ctx, "logs/2024-01/*.json*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"logs/2024-01/a.json", "logs/2024-01/b.json.gz"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+
+	out := &bytes.Buffer{}
+	p := parser.NewJSONParser(ctx, out, parser.Option{})
+	var sources []string
+	err = Parse(ctx, p, g, "logs/2024-01/*.json*", func(name string, r *parser.Result, err error) error {
+		sources = append(sources, r.Source)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\"path\":\"/a\"}\n{\"path\":\"/b\"}\n"; out.String() != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", out.String(), want)
+	}
+	if want := []string{"a.json", "b.json.gz"}; !reflect.DeepEqual(sources, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", sources, want)
+	}
+
+	generations["logs/2024-01/a.json"] = "3"
+	if _, err := g.Open(ctx, "logs/2024-01/a.json"); !errors.Is(err, ErrChangedSinceListed) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, ErrChangedSinceListed)
+	}
+	rc, err := g.OpenVersion(ctx, "logs/2024-01/a.json", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc.Close()
+	if _, err := g.List(ctx, "logs/["); err == nil {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "bad pattern")
+	}
+	if _, err := NewGCS(GCSConfig{}); err == nil {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "empty bucket name")
+	}
+}
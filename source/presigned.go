@@ -0,0 +1,320 @@
+package source
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// presignedError is the error message prefix for failures of Presigned.
+const presignedError = "presigned url"
+
+// defaults of PresignedConfig
+const (
+	defaultPresignedConcurrency = 4
+	defaultPresignedRetries     = 3
+	defaultPresignedBackoff     = time.Second
+)
+
+// PresignedConfig defines how Presigned downloads the objects.
+type PresignedConfig struct {
+	Concurrency int           // number of downloads at a time (zero means 4)
+	Window      int           // number of objects downloaded ahead but not yet read, including those in progress (zero means Concurrency)
+	Retries     int           // number of times a failed download is resumed (zero means 3, negative means none)
+	Backoff     time.Duration // wait before the first retry, doubled for each further retry (zero means 1 second)
+	Dir         string        // directory of the temporary files holding the downloads (empty means os.TempDir)
+	Client      *http.Client  // client to send requests with (nil means http.DefaultClient)
+}
+
+// Presigned is a Remote of objects behind pre-signed URLs, such as those of S3, GCS or Azure SAS, for teams
+// without direct access to the bucket. The objects listed are downloaded in the background to temporary files,
+// up to PresignedConfig.Concurrency at a time, and a download interrupted midway is resumed from where it
+// stopped with a range request. The downloads stay up to PresignedConfig.Window objects ahead of those read,
+// so that the temporary files do not pile up when parsing is slower than downloading. Names are the URLs
+// themselves.
+type Presigned struct {
+	cfg       PresignedConfig
+	urls      []string
+	mu        sync.Mutex
+	downloads map[string]*download
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// download is the state of the download of an object.
+type download struct {
+	done    chan struct{}
+	path    string
+	err     error
+	release func() // frees the slot of the download window taken by the download, if any
+}
+
+// ReadURLs reads URLs from r, such as a file or os.Stdin, one per line. Blank lines and lines starting with "#"
+// are ignored.
+func ReadURLs(r io.Reader) ([]string, error) {
+	var urls []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", presignedError, err)
+	}
+	return urls, nil
+}
+
+// NewPresigned returns a Remote of the objects behind urls. Close it to remove the downloads not opened.
+func NewPresigned(urls []string, cfg PresignedConfig) (*Presigned, error) {
+	for _, s := range urls {
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", presignedError, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("%s: unsupported scheme: %q", presignedError, baseName(s))
+		}
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = defaultPresignedConcurrency
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = cfg.Concurrency
+	}
+	if cfg.Retries == 0 {
+		cfg.Retries = defaultPresignedRetries
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = defaultPresignedBackoff
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &Presigned{cfg: cfg, urls: urls, downloads: map[string]*download{}, cancel: func() {}}, nil
+}
+
+// List returns the URLs whose object name, the last element of the path, matches the glob pattern, such as
+// "*.log.gz", in the order given. An empty pattern matches all. The downloads of the URLs start in the
+// background, in the same order, as the objects downloaded before are read, and stop when ctx is done.
+func (p *Presigned) List(ctx context.Context, pattern string) ([]string, error) {
+	if pattern == "" {
+		pattern = "*"
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("%s: %w: %q", presignedError, err, pattern)
+	}
+	var matched []string
+	for _, u := range p.urls {
+		if ok, _ := path.Match(pattern, baseName(u)); ok {
+			matched = append(matched, u)
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	p.mu.Lock()
+	prev := p.cancel
+	p.cancel = func() { prev(); cancel() }
+	p.mu.Unlock()
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		window := make(chan struct{}, p.cfg.Window)
+		sem := make(chan struct{}, p.cfg.Concurrency)
+		for _, u := range matched {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			d, ok := p.claim(u, func() { <-window })
+			if !ok {
+				<-sem
+				<-window
+				continue
+			}
+			p.wg.Add(1)
+			go func(u string) {
+				defer p.wg.Done()
+				defer func() { <-sem }()
+				p.run(ctx, u, d)
+			}(u)
+		}
+	}()
+	return matched, nil
+}
+
+// Open waits for the download of the URL, starting it if it has not been listed, and opens the downloaded file.
+// The file is removed when closed.
+func (p *Presigned) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	d := p.start(ctx, name)
+	select {
+	case <-d.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	f, err := os.Open(filepath.Clean(d.path))
+	if err != nil {
+		d.release()
+		return nil, fmt.Errorf("%s: %w", presignedError, err)
+	}
+	return &tempFile{File: f, release: d.release}, nil
+}
+
+// Close stops the downloads in progress and removes the downloaded files not opened.
+func (p *Presigned) Close() error {
+	p.mu.Lock()
+	p.cancel()
+	p.mu.Unlock()
+	p.wg.Wait()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var errs []error
+	for u, d := range p.downloads {
+		if d.path != "" {
+			if err := os.Remove(d.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+		delete(p.downloads, u)
+	}
+	return errors.Join(errs...)
+}
+
+// start returns the download of the URL, running it in the calling goroutine if it has not been started.
+func (p *Presigned) start(ctx context.Context, u string) *download {
+	d, ok := p.claim(u, func() {})
+	if ok {
+		p.run(ctx, u, d)
+	}
+	return d
+}
+
+// claim returns the download of the URL, reporting whether it is new. For a new download, release is called
+// once when its file is read and closed, or when it fails.
+func (p *Presigned) claim(u string, release func()) (*download, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if d, ok := p.downloads[u]; ok {
+		return d, false
+	}
+	var once sync.Once
+	d := &download{done: make(chan struct{}), release: func() { once.Do(release) }}
+	p.downloads[u] = d
+	return d, true
+}
+
+// run runs the download.
+func (p *Presigned) run(ctx context.Context, u string, d *download) {
+	d.path, d.err = p.fetch(ctx, u)
+	if d.err != nil {
+		d.release()
+	}
+	close(d.done)
+}
+
+// fetch downloads the object to a temporary file, resuming the download on failures.
+func (p *Presigned) fetch(ctx context.Context, u string) (string, error) {
+	f, err := os.CreateTemp(p.cfg.Dir, "presigned-*")
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", presignedError, err)
+	}
+	defer f.Close()
+	backoff := p.cfg.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := p.fetchFrom(ctx, u, f)
+		if err == nil {
+			return f.Name(), nil
+		}
+		if !retry || attempt >= p.cfg.Retries {
+			os.Remove(f.Name())
+			return "", fmt.Errorf("%s: %s: %w", presignedError, baseName(u), err)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			os.Remove(f.Name())
+			return "", ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// fetchFrom requests the object from the size of f onwards and appends it to f. It reports whether a failure
+// is worth retrying, which is the case for network errors and server-side or throttling responses.
+func (p *Presigned) fetchFrom(ctx context.Context, u string, f *os.File) (bool, error) {
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept-Encoding", "identity") // keep offsets in stored bytes, compressed objects are detected by Parse
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+	resp, err := p.cfg.Client.Do(req)
+	if err != nil {
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			err = ue.Err // the URL carries the signature, which should not leak into logs
+		}
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		return false, nil // the previous attempt ended exactly at the end
+	case resp.StatusCode == http.StatusOK:
+		if err := f.Truncate(0); err != nil {
+			return false, err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout
+		return retry, &statusError{code: resp.StatusCode, status: resp.Status, msg: strings.TrimSpace(string(msg))}
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		return ctx.Err() == nil, err
+	}
+	return false, nil
+}
+
+// tempFile is a downloaded file removed when closed.
+type tempFile struct {
+	*os.File
+	release func()
+}
+
+// Close closes and removes the file, letting the next download start.
+func (t *tempFile) Close() error {
+	defer t.release()
+	return errors.Join(t.File.Close(), os.Remove(t.File.Name()))
+}
@@ -0,0 +1,175 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	parser "github.com/nekrassov01/access-log-parser"
+)
+
+func TestReadURLs(t *testing.T) {
+	got, err := ReadURLs(strings.NewReader("# backfill\nhttps://a.example.com/x.log?sig=1\n\n  https://a.example.com/y.log?sig=2  \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"https://a.example.com/x.log?sig=1", "https://a.example.com/y.log?sig=2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+}
+
+func TestPresigned(t *testing.T) {
+	content := map[string]string{
+		"/logs/a.log":    strings.Repeat("GET /a 200\n", 100),
+		"/logs/b.log.gz": string(gzipBytes(t, "GET /b 500\n")),
+		"/logs/c.txt":    "GET /c 200\n",
+	}
+	var mu sync.Mutex
+	requests := map[string][]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("X-Amz-Signature") != "ok" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		requests[r.URL.Path] = append(requests[r.URL.Path], r.Header.Get("Range"))
+		n := len(requests[r.URL.Path])
+		mu.Unlock()
+		body, ok := content[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Path == "/logs/a.log" && n == 1 {
+			w.Header().Set("Content-Length", "1100")
+			w.Write([]byte(body[:500]))
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler) // drop the connection midway
+		}
+		http.ServeContent(w, r, r.URL.Path, time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	urls := []string{
+		srv.URL + "/logs/a.log?X-Amz-Signature=ok",
+		srv.URL + "/logs/b.log.gz?X-Amz-Signature=ok",
+		srv.URL + "/logs/c.txt?X-Amz-Signature=ok",
+	}
+	dir := t.TempDir()
+	remote, err := NewPresigned(urls, PresignedConfig{Concurrency: 2, Backoff: time.Millisecond, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+
+	ctx := context.Background()
+	out := &bytes.Buffer{}
+	p := parser.NewRegexParser(ctx, out, parser.Option{})
+	if err := p.AddPattern(`^(?P<method>\S+) (?P<path>\S+) (?P<status>\d+)$`); err != nil {
+		t.Fatal(err)
+	}
+	var sources []string
+	var matched int
+	err = Parse(ctx, p, remote, "*.log*", func(name string, r *parser.Result, err error) error {
+		if err != nil {
+			return err
+		}
+		sources = append(sources, r.Source)
+		matched += r.Matched
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.log", "b.log.gz"}; !reflect.DeepEqual(sources, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", sources, want)
+	}
+	if matched != 101 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", matched, 101)
+	}
+	if want := []string{"", "bytes=500-"}; !reflect.DeepEqual(requests["/logs/a.log"], want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", requests["/logs/a.log"], want)
+	}
+	if _, ok := requests["/logs/c.txt"]; ok {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", "c.txt downloaded", "not downloaded")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", len(entries), 0)
+	}
+
+	expired, err := NewPresigned([]string{srv.URL + "/logs/a.log?X-Amz-Signature=expired"}, PresignedConfig{Backoff: time.Millisecond, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer expired.Close()
+	_, err = expired.Open(ctx, srv.URL+"/logs/a.log?X-Amz-Signature=expired")
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, "403")
+	}
+	if strings.Contains(err.Error(), "Signature") {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "error without the signature")
+	}
+	if _, err := NewPresigned([]string{"s3://bucket/key"}, PresignedConfig{}); err == nil {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "unsupported scheme")
+	}
+}
+
+func TestPresigned_window(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+		w.Write([]byte("GET /a 200\n"))
+	}))
+	defer srv.Close()
+	var urls []string
+	for _, name := range []string{"a", "b", "c", "d"} {
+		urls = append(urls, srv.URL+"/logs/"+name+".log")
+	}
+	remote, err := NewPresigned(urls, PresignedConfig{Concurrency: 2, Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+	ctx := context.Background()
+	if _, err := remote.List(ctx, "*.log"); err != nil {
+		t.Fatal(err)
+	}
+	count := func(want int) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			mu.Lock()
+			n := len(requested)
+			mu.Unlock()
+			if n >= want {
+				break
+			}
+		}
+		time.Sleep(100 * time.Millisecond) // no more downloads are to start
+		mu.Lock()
+		defer mu.Unlock()
+		if len(requested) != want {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", len(requested), want)
+		}
+	}
+	count(2)
+	rc, err := remote.Open(ctx, urls[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	count(2)
+	if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	count(3)
+}
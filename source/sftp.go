@@ -0,0 +1,469 @@
+package source
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sftpError is the error message prefix for failures of SFTP.
+const sftpError = "sftp"
+
+// SFTP protocol version 3 packet types, as used by OpenSSH.
+const (
+	sftpInit    = 1
+	sftpVersion = 2
+	sftpOpen    = 3
+	sftpClose   = 4
+	sftpRead    = 5
+	sftpOpendir = 11
+	sftpReaddir = 12
+	sftpStatus  = 101
+	sftpHandle  = 102
+	sftpData    = 103
+	sftpName    = 104
+)
+
+// SFTP protocol status codes and flags.
+const (
+	sftpOK  = 0
+	sftpEOF = 1
+
+	sftpFlagRead = 0x1
+
+	sftpAttrSize        = 0x1
+	sftpAttrUIDGID      = 0x2
+	sftpAttrPermissions = 0x4
+	sftpAttrACModTime   = 0x8
+	sftpAttrExtended    = 0x80000000
+)
+
+// sftpChunkSize is the size of data requested per read, which all servers are required to support.
+const sftpChunkSize = 32 * 1024
+
+// sftpMaxPacket is the largest packet accepted from the server.
+const sftpMaxPacket = 256 * 1024
+
+// sftpMinEntrySize is the size of a directory entry with an empty name, long name and attributes.
+const sftpMinEntrySize = 12
+
+// SFTP is a Remote that lists and streams files over the SFTP protocol (version 3), such as logs on
+// appliances and bastion hosts reachable over SSH. Requests are sent one at a time, so they cannot be
+// cancelled individually: when the context of List or Open is done, the session is closed.
+type SFTP struct {
+	mu        sync.Mutex
+	r         io.Reader
+	w         io.WriteCloser
+	id        uint32
+	closer    func() error
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// DialSFTP connects to the SSH server at addr, such as "host:22", and starts an SFTP session.
+// Closing the returned SFTP also closes the SSH connection.
+func DialSFTP(addr string, config *ssh.ClientConfig) (*SFTP, error) {
+	client, err := ssh.Dial("tcp", addr, config)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", sftpError, err)
+	}
+	s, err := NewSFTP(client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	closeSession := s.closer
+	s.closer = func() error {
+		return errors.Join(closeSession(), client.Close())
+	}
+	return s, nil
+}
+
+// NewSFTP starts an SFTP session on an established SSH connection, such as one tunneled through a bastion.
+// Closing the returned SFTP closes the session but leaves the connection open.
+func NewSFTP(client *ssh.Client) (*SFTP, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", sftpError, err)
+	}
+	w, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, fmt.Errorf("%s: %w", sftpError, err)
+	}
+	r, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, fmt.Errorf("%s: %w", sftpError, err)
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("%s: %w", sftpError, err)
+	}
+	s, err := newSFTP(r, w, session.Close)
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// newSFTP negotiates the protocol version over the streams of an SFTP subsystem.
+func newSFTP(r io.Reader, w io.WriteCloser, closer func() error) (*SFTP, error) {
+	s := &SFTP{r: r, w: w, closer: closer}
+	if err := s.send(sftpInit, binary.BigEndian.AppendUint32(nil, 3)); err != nil {
+		return nil, err
+	}
+	typ, _, err := s.recv()
+	if err != nil {
+		return nil, err
+	}
+	if typ != sftpVersion {
+		return nil, fmt.Errorf("%s: unexpected packet type %d in handshake", sftpError, typ)
+	}
+	return s, nil
+}
+
+// Close ends the SFTP session. It may be called while a request is waiting for the response, which then fails.
+func (s *SFTP) Close() error {
+	s.closeOnce.Do(func() {
+		s.w.Close()
+		if s.closer != nil {
+			s.closeErr = s.closer()
+		}
+	})
+	return s.closeErr
+}
+
+// watch closes the session when ctx is done, to stop a request waiting for the response. The returned
+// function stops watching.
+func (s *SFTP) watch(ctx context.Context) func() bool {
+	return context.AfterFunc(ctx, func() {
+		s.Close()
+	})
+}
+
+// ctxError returns the error of ctx if it is done, which caused err by closing the session, or err otherwise.
+func ctxError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// List returns the paths of the regular files matching the pattern, which may contain wildcards of
+// path.Match in the last element only, such as "/var/log/nginx/access.log*".
+func (s *SFTP) List(ctx context.Context, pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("%s: %w: %q", sftpError, err, pattern)
+	}
+	dir, base := path.Split(pattern)
+	if dir == "" {
+		dir = "."
+	}
+	defer s.watch(ctx)()
+	handle, err := s.openHandle(sftpOpendir, sftpString(nil, dir))
+	if err != nil {
+		return nil, ctxError(ctx, err)
+	}
+	defer s.closeHandle(handle)
+	var names []string
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		entries, err := s.readdir(handle)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, ctxError(ctx, err)
+		}
+		for _, e := range entries {
+			if e.name == "." || e.name == ".." || !e.regular {
+				continue
+			}
+			if ok, _ := path.Match(base, e.name); ok {
+				names = append(names, path.Join(dir, e.name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Open opens the file at name for reading. The file is read under ctx until it is closed.
+func (s *SFTP) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	payload := sftpString(nil, name)
+	payload = binary.BigEndian.AppendUint32(payload, sftpFlagRead)
+	payload = binary.BigEndian.AppendUint32(payload, 0)
+	stop := s.watch(ctx)
+	handle, err := s.openHandle(sftpOpen, payload)
+	if err != nil {
+		stop()
+		return nil, ctxError(ctx, err)
+	}
+	return &sftpFile{s: s, ctx: ctx, stop: stop, handle: handle, name: name}, nil
+}
+
+// sftpEntry is a directory entry returned by the server.
+type sftpEntry struct {
+	name    string
+	regular bool
+}
+
+// sftpFile streams a remote file by reading it in chunks.
+type sftpFile struct {
+	s      *SFTP
+	ctx    context.Context
+	stop   func() bool
+	handle string
+	name   string
+	offset uint64
+	buf    []byte
+	eof    bool
+}
+
+// Read reads from the buffered chunk, requesting the next one when it is exhausted.
+func (f *sftpFile) Read(p []byte) (int, error) {
+	for len(f.buf) == 0 {
+		if f.eof {
+			return 0, io.EOF
+		}
+		payload := sftpString(nil, f.handle)
+		payload = binary.BigEndian.AppendUint64(payload, f.offset)
+		payload = binary.BigEndian.AppendUint32(payload, sftpChunkSize)
+		typ, resp, err := f.s.call(sftpRead, payload)
+		if err != nil {
+			return 0, ctxError(f.ctx, err)
+		}
+		switch typ {
+		case sftpData:
+			data, _, err := sftpReadString(resp)
+			if err != nil {
+				return 0, err
+			}
+			f.buf = []byte(data)
+			f.offset += uint64(len(data))
+		case sftpStatus:
+			if err := sftpStatusError(resp, f.name); err != nil {
+				return 0, err
+			}
+			f.eof = true
+		default:
+			return 0, fmt.Errorf("%s: unexpected packet type %d for read", sftpError, typ)
+		}
+	}
+	n := copy(p, f.buf)
+	f.buf = f.buf[n:]
+	return n, nil
+}
+
+// Close releases the remote file handle.
+func (f *sftpFile) Close() error {
+	f.stop()
+	return f.s.closeHandle(f.handle)
+}
+
+// openHandle sends a request that opens a file or directory and returns the handle.
+func (s *SFTP) openHandle(typ byte, payload []byte) (string, error) {
+	rtyp, resp, err := s.call(typ, payload)
+	if err != nil {
+		return "", err
+	}
+	switch rtyp {
+	case sftpHandle:
+		handle, _, err := sftpReadString(resp)
+		return handle, err
+	case sftpStatus:
+		name, _, _ := sftpReadString(payload)
+		if err := sftpStatusError(resp, name); err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("%s: unexpected packet type %d for open", sftpError, rtyp)
+}
+
+// closeHandle releases the handle of a file or directory.
+func (s *SFTP) closeHandle(handle string) error {
+	typ, resp, err := s.call(sftpClose, sftpString(nil, handle))
+	if err != nil {
+		return err
+	}
+	if typ != sftpStatus {
+		return fmt.Errorf("%s: unexpected packet type %d for close", sftpError, typ)
+	}
+	return sftpStatusError(resp, "")
+}
+
+// readdir reads the next entries of a directory, returning io.EOF at the end of it.
+func (s *SFTP) readdir(handle string) ([]sftpEntry, error) {
+	typ, resp, err := s.call(sftpReaddir, sftpString(nil, handle))
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case sftpName:
+	case sftpStatus:
+		if err := sftpStatusError(resp, ""); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	default:
+		return nil, fmt.Errorf("%s: unexpected packet type %d for readdir", sftpError, typ)
+	}
+	if len(resp) < 4 {
+		return nil, fmt.Errorf("%s: short packet", sftpError)
+	}
+	n := binary.BigEndian.Uint32(resp)
+	resp = resp[4:]
+	entries := make([]sftpEntry, 0, min(n, uint32(len(resp)/sftpMinEntrySize)))
+	for i := uint32(0); i < n; i++ {
+		var e sftpEntry
+		if e.name, resp, err = sftpReadString(resp); err != nil {
+			return nil, err
+		}
+		if _, resp, err = sftpReadString(resp); err != nil { // long name
+			return nil, err
+		}
+		var mode uint32
+		if mode, resp, err = sftpReadAttrs(resp); err != nil {
+			return nil, err
+		}
+		e.regular = mode&0o170000 == 0 || mode&0o170000 == 0o100000 // regular if the type is unknown
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// call sends a request and receives the response to it, returning the response without the request id.
+func (s *SFTP) call(typ byte, payload []byte) (byte, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.id++
+	id := s.id
+	if err := s.send(typ, append(binary.BigEndian.AppendUint32(nil, id), payload...)); err != nil {
+		return 0, nil, err
+	}
+	rtyp, resp, err := s.recv()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(resp) < 4 || binary.BigEndian.Uint32(resp) != id {
+		return 0, nil, fmt.Errorf("%s: response id does not match request id %d", sftpError, id)
+	}
+	return rtyp, resp[4:], nil
+}
+
+// send writes a packet.
+func (s *SFTP) send(typ byte, payload []byte) error {
+	b := binary.BigEndian.AppendUint32(make([]byte, 0, 5+len(payload)), uint32(1+len(payload)))
+	b = append(b, typ)
+	b = append(b, payload...)
+	if _, err := s.w.Write(b); err != nil {
+		return fmt.Errorf("%s: %w", sftpError, err)
+	}
+	return nil
+}
+
+// recv reads a packet.
+func (s *SFTP) recv() (byte, []byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(s.r, hdr[:]); err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", sftpError, err)
+	}
+	n := binary.BigEndian.Uint32(hdr[:4])
+	if n < 1 || n > sftpMaxPacket {
+		return 0, nil, fmt.Errorf("%s: invalid packet length %d", sftpError, n)
+	}
+	b := make([]byte, n-1)
+	if _, err := io.ReadFull(s.r, b); err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", sftpError, err)
+	}
+	return hdr[4], b, nil
+}
+
+// sftpStatusError returns the error of a status response, or nil if it reports success or end of file.
+func sftpStatusError(resp []byte, name string) error {
+	if len(resp) < 4 {
+		return fmt.Errorf("%s: short packet", sftpError)
+	}
+	code := binary.BigEndian.Uint32(resp)
+	if code == sftpOK || code == sftpEOF {
+		return nil
+	}
+	msg, _, _ := sftpReadString(resp[4:])
+	if name != "" {
+		return fmt.Errorf("%s: %s: %s (code %d)", sftpError, name, msg, code)
+	}
+	return fmt.Errorf("%s: %s (code %d)", sftpError, msg, code)
+}
+
+// sftpString appends a length-prefixed string to b.
+func sftpString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// sftpReadString reads a length-prefixed string from b and returns the rest.
+func sftpReadString(b []byte) (string, []byte, error) {
+	if len(b) < 4 {
+		return "", nil, fmt.Errorf("%s: short packet", sftpError)
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint32(len(b)-4) < n {
+		return "", nil, fmt.Errorf("%s: short packet", sftpError)
+	}
+	return string(b[4 : 4+n]), b[4+n:], nil
+}
+
+// sftpReadAttrs reads file attributes from b, returning the permissions (zero if absent) and the rest.
+func sftpReadAttrs(b []byte) (uint32, []byte, error) {
+	if len(b) < 4 {
+		return 0, nil, fmt.Errorf("%s: short packet", sftpError)
+	}
+	flags := binary.BigEndian.Uint32(b)
+	b = b[4:]
+	var mode uint32
+	for _, f := range []struct {
+		flag uint32
+		n    int
+	}{
+		{sftpAttrSize, 8},
+		{sftpAttrUIDGID, 8},
+		{sftpAttrPermissions, 4},
+		{sftpAttrACModTime, 8},
+	} {
+		if flags&f.flag == 0 {
+			continue
+		}
+		if len(b) < f.n {
+			return 0, nil, fmt.Errorf("%s: short packet", sftpError)
+		}
+		if f.flag == sftpAttrPermissions {
+			mode = binary.BigEndian.Uint32(b)
+		}
+		b = b[f.n:]
+	}
+	if flags&sftpAttrExtended != 0 {
+		if len(b) < 4 {
+			return 0, nil, fmt.Errorf("%s: short packet", sftpError)
+		}
+		n := binary.BigEndian.Uint32(b)
+		b = b[4:]
+		var err error
+		for i := uint32(0); i < 2*n; i++ {
+			if _, b, err = sftpReadString(b); err != nil {
+				return 0, nil, err
+			}
+		}
+	}
+	return mode, b, nil
+}
@@ -0,0 +1,315 @@
+package source
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	parser "github.com/nekrassov01/access-log-parser"
+	"golang.org/x/crypto/ssh"
+)
+
+// serveSFTP serves the subset of SFTP used by the client from the local file system, for a single session.
+func serveSFTP(rw io.ReadWriter) {
+	handles := map[string]*os.File{}
+	dirs := map[string]bool{}
+	var next int
+	reply := func(typ byte, id uint32, payload []byte) {
+		b := binary.BigEndian.AppendUint32(nil, uint32(5+len(payload)))
+		b = append(b, typ)
+		b = binary.BigEndian.AppendUint32(b, id)
+		rw.Write(append(b, payload...))
+	}
+	status := func(id, code uint32) {
+		p := binary.BigEndian.AppendUint32(nil, code)
+		p = sftpString(sftpString(p, "status"), "")
+		reply(sftpStatus, id, p)
+	}
+	for {
+		var hdr [5]byte
+		if _, err := io.ReadFull(rw, hdr[:]); err != nil {
+			return
+		}
+		b := make([]byte, binary.BigEndian.Uint32(hdr[:4])-1)
+		if _, err := io.ReadFull(rw, b); err != nil {
+			return
+		}
+		if hdr[4] == sftpInit {
+			p := binary.BigEndian.AppendUint32(nil, 5)
+			p = append(p, sftpVersion)
+			rw.Write(binary.BigEndian.AppendUint32(p, 3))
+			continue
+		}
+		id := binary.BigEndian.Uint32(b)
+		arg, rest, _ := sftpReadString(b[4:])
+		switch hdr[4] {
+		case sftpOpen, sftpOpendir:
+			f, err := os.Open(arg)
+			if err != nil {
+				status(id, 2)
+				continue
+			}
+			next++
+			h := string(rune('a' + next))
+			handles[h] = f
+			reply(sftpHandle, id, sftpString(nil, h))
+		case sftpReaddir:
+			if dirs[arg] {
+				status(id, sftpEOF)
+				continue
+			}
+			dirs[arg] = true
+			entries, _ := handles[arg].ReadDir(-1)
+			p := binary.BigEndian.AppendUint32(nil, uint32(len(entries)+1))
+			p = sftpString(sftpString(p, ".."), "..")
+			p = binary.BigEndian.AppendUint32(p, sftpAttrPermissions)
+			p = binary.BigEndian.AppendUint32(p, 0o40755)
+			for _, e := range entries {
+				fi, _ := e.Info()
+				p = sftpString(sftpString(p, e.Name()), e.Name())
+				p = binary.BigEndian.AppendUint32(p, sftpAttrSize|sftpAttrPermissions)
+				p = binary.BigEndian.AppendUint64(p, uint64(fi.Size()))
+				mode := uint32(0o100644)
+				if e.IsDir() {
+					mode = 0o40755
+				}
+				p = binary.BigEndian.AppendUint32(p, mode)
+			}
+			reply(sftpName, id, p)
+		case sftpRead:
+			off := binary.BigEndian.Uint64(rest)
+			buf := make([]byte, 7) // small chunks to exercise repeated reads
+			n, err := handles[arg].ReadAt(buf, int64(off))
+			if n == 0 && err != nil {
+				status(id, sftpEOF)
+				continue
+			}
+			reply(sftpData, id, sftpString(nil, string(buf[:n])))
+		case sftpClose:
+			handles[arg].Close()
+			delete(handles, arg)
+			status(id, sftpOK)
+		default:
+			status(id, 8)
+		}
+	}
+}
+
+// startSSHServer starts an SSH server accepting any client and serving the sftp subsystem.
+func startSSHServer(t *testing.T) string {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for nc := range chans {
+					ch, reqs, err := nc.Accept()
+					if err != nil {
+						return
+					}
+					go func() {
+						for req := range reqs {
+							ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+							req.Reply(ok, nil)
+							if ok {
+								go func() {
+									serveSFTP(ch)
+									ch.Close()
+								}()
+							}
+						}
+					}()
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestSFTP(t *testing.T) {
+	dir := t.TempDir()
+	lines := "GET /a 200\nGET /b 404\n"
+	gz := &bytes.Buffer{}
+	zw := gzip.NewWriter(gz)
+	zw.Write([]byte("GET /c 500\n"))
+	zw.Close()
+	for name, content := range map[string][]byte{
+		"access.log":      []byte(lines),
+		"access.log.1.gz": gz.Bytes(),
+		"error.log":       []byte("error\n"),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "access.log.d"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := DialSFTP(startSSHServer(t), &ssh.ClientConfig{
+		User:            "test",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	got, err := s.List(ctx, filepath.ToSlash(dir)+"/access.log*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.ToSlash(dir) + "/access.log", filepath.ToSlash(dir) + "/access.log.1.gz"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+
+	rc, err := s.Open(ctx, want[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != lines {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", string(b), lines)
+	}
+
+	if _, err := s.Open(ctx, filepath.ToSlash(dir)+"/missing.log"); err == nil || !strings.Contains(err.Error(), "missing.log") {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "missing.log")
+	}
+	if _, err := s.List(ctx, "["); err == nil {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "bad pattern")
+	}
+
+	out := &bytes.Buffer{}
+	p := parser.NewRegexParser(ctx, out, parser.Option{})
+	if err := p.AddPattern(`^(?P<method>\S+) (?P<path>\S+) (?P<status>\d+)$`); err != nil {
+		t.Fatal(err)
+	}
+	var sources []string
+	var matched int
+	err = Parse(ctx, p, s, filepath.ToSlash(dir)+"/access.log*", func(name string, r *parser.Result, err error) error {
+		if err != nil {
+			return err
+		}
+		sources = append(sources, r.Source)
+		matched += r.Matched
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sources, []string{"access.log", "access.log.1.gz"}) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", sources, []string{"access.log", "access.log.1.gz"})
+	}
+	if matched != 3 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", matched, 3)
+	}
+	if !strings.Contains(out.String(), `"path":"/c"`) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", out.String(), `"path":"/c"`)
+	}
+}
+
+// pipeSFTP starts a session with a server answering requests with reply, which sends nothing if it returns
+// false.
+func pipeSFTP(t *testing.T, reply func(typ byte, payload []byte) (byte, []byte, bool)) *SFTP {
+	t.Helper()
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		for {
+			var hdr [5]byte
+			if _, err := io.ReadFull(server, hdr[:]); err != nil {
+				return
+			}
+			b := make([]byte, binary.BigEndian.Uint32(hdr[:4])-1)
+			if _, err := io.ReadFull(server, b); err != nil {
+				return
+			}
+			if hdr[4] == sftpInit {
+				server.Write([]byte{0, 0, 0, 5, sftpVersion, 0, 0, 0, 3})
+				continue
+			}
+			typ, payload, ok := reply(hdr[4], b[4:])
+			if !ok {
+				continue
+			}
+			resp := binary.BigEndian.AppendUint32(nil, uint32(5+len(payload)))
+			resp = append(resp, typ)
+			resp = append(resp, b[:4]...)
+			server.Write(append(resp, payload...))
+		}
+	}()
+	s, err := newSFTP(client, client, client.Close)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSFTP_readdir_shortPacket(t *testing.T) {
+	s := pipeSFTP(t, func(typ byte, _ []byte) (byte, []byte, bool) {
+		if typ == sftpReaddir {
+			return sftpName, binary.BigEndian.AppendUint32(nil, 1<<31), true
+		}
+		return sftpHandle, sftpString(nil, "h"), true
+	})
+	_, err := s.List(context.Background(), "/var/log/*")
+	if err == nil || !strings.Contains(err.Error(), "short packet") {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "short packet")
+	}
+}
+
+func TestSFTP_Open_context(t *testing.T) {
+	s := pipeSFTP(t, func(byte, []byte) (byte, []byte, bool) {
+		return 0, nil, false
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := s.Open(ctx, "/var/log/access.log")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, context.DeadlineExceeded)
+	}
+}
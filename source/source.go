@@ -0,0 +1,156 @@
+// Package source provides remote log sources, such as SFTP servers, Azure Blob Storage and Google Cloud Storage,
+// that list and stream files to a parser without copying them to the local disk first.
+package source
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	parser "github.com/nekrassov01/access-log-parser"
+)
+
+// ErrChangedSinceListed is returned when an object has been overwritten after it was listed, so that it is
+// not read in a state other than the one listed.
+var ErrChangedSinceListed = errors.New("object changed since it was listed")
+
+// Remote is a remote location of log files.
+type Remote interface {
+	// List returns the names of the files matching the glob pattern, in the order to parse them, which is
+	// lexical for directories and buckets.
+	List(ctx context.Context, pattern string) ([]string, error)
+
+	// Open opens the named file for streaming.
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+// ResultFunc is called with the outcome of parsing each file. Returning an error stops Parse.
+type ResultFunc func(name string, r *parser.Result, err error) error
+
+// Parse lists the files of remote matching the glob pattern and parses each in order with p, streaming the
+// records to the output of p. Gzip-compressed files are detected by the magic number and decompressed
+// transparently. fn is called after each file is parsed; if fn is nil, Parse stops at the first failure.
+func Parse(ctx context.Context, p parser.Parser, remote Remote, pattern string, fn ResultFunc) error {
+	if fn == nil {
+		fn = func(_ string, _ *parser.Result, err error) error { return err }
+	}
+	names, err := remote.List(ctx, pattern)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		r, err := parseRemote(ctx, p, remote, name)
+		if err := fn(name, r, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseRemote parses the named file of remote with p.
+func parseRemote(ctx context.Context, p parser.Parser, remote Remote, name string) (*parser.Result, error) {
+	rc, err := remote.Open(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	br := bufio.NewReader(rc)
+	var input io.Reader = br
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open gzip stream: %s: %w", name, err)
+		}
+		defer gz.Close()
+		input = gz
+	}
+	r, err := p.Parse(input)
+	if r != nil {
+		r.Source = baseName(name)
+	}
+	return r, err
+}
+
+// baseName returns the last element of the name, which is the path of a URL without the query string, such as
+// the signature of a pre-signed URL.
+func baseName(name string) string {
+	if u, err := url.Parse(name); err == nil && u.Scheme != "" && u.Host != "" {
+		name = u.Path
+	}
+	return path.Base(name)
+}
+
+// globPrefix returns the literal part of the glob pattern before the first wildcard, used to narrow listings
+// of object stores.
+func globPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+// matchObjects filters the object names by the glob pattern, whose wildcards do not match "/" as in path.Match.
+func matchObjects(pattern string, names []string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("%w: %q", err, pattern)
+	}
+	matched := make([]string, 0, len(names))
+	for _, name := range names {
+		if ok, _ := path.Match(pattern, name); ok {
+			matched = append(matched, name)
+		}
+	}
+	sort.Strings(matched)
+	return matched, nil
+}
+
+// httpGet sends a GET request and returns the body of a successful response. Other responses are reported
+// with the status and the beginning of the body, prefixed with prefix.
+func httpGet(ctx context.Context, client *http.Client, prefix, url string, header http.Header) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", prefix, err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", prefix, err)
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s: %w", prefix, &statusError{code: resp.StatusCode, status: resp.Status, msg: strings.TrimSpace(string(msg))})
+	}
+	return resp.Body, nil
+}
+
+// statusError is an unsuccessful response of an object store.
+type statusError struct {
+	code   int
+	status string
+	msg    string
+}
+
+// Error returns the status and the message of the response.
+func (e *statusError) Error() string {
+	return e.status + ": " + e.msg
+}
+
+// hasStatus reports whether err is an unsuccessful response with the status code.
+func hasStatus(err error, code int) bool {
+	var e *statusError
+	return errors.As(err, &e) && e.code == code
+}
@@ -0,0 +1,254 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// tailError is the error message prefix for failures of Tail.
+const tailError = "cannot tail file"
+
+// defaultTailPoll is the default interval of Tail to check the file at.
+const defaultTailPoll = 250 * time.Millisecond
+
+// tailChunkSize is the size of the blocks read backwards to find the end of the last complete line.
+const tailChunkSize = 4096
+
+// TailConfig defines how Tail follows a file.
+type TailConfig struct {
+	Poll      time.Duration              // interval to check the file for new lines, truncation and rotation at (zero means 250 milliseconds)
+	FromStart bool                       // whether to parse the lines already in the file when it is first opened, rather than only those appended, or not
+	StateFile string                     // path of the file recording the file followed and the position parsed up to, to resume from across restarts (empty means not recorded)
+	OnResult  func(r *Result, err error) // called after each batch of new lines is parsed (nil means ignored)
+}
+
+// tailState records the file followed by Tail by identity, and the position parsed up to.
+type tailState struct {
+	ID     string    `json:"id"`     // Identity of the file, which is the device and inode number where available.
+	Name   string    `json:"name"`   // Name of the file.
+	Offset int64     `json:"offset"` // Position up to which the file has been parsed.
+	Saved  time.Time `json:"saved"`  // Time the state was saved.
+}
+
+// tailer follows a file and parses the lines appended to it.
+type tailer struct {
+	p      Parser
+	path   string
+	cfg    TailConfig
+	state  *tailState // state loaded from the state file, used once when the file is first opened
+	file   *os.File
+	id     string
+	offset int64
+	opened bool
+	result *Result
+}
+
+// Tail follows the file at filePath like tail -F, parsing the lines appended to it with p and streaming the
+// records to the output of p until ctx is done, then returns the result of all the lines parsed. Only complete
+// lines are parsed; a line being written is parsed once its newline is written, or when the file is rotated.
+//
+// The file does not need to exist yet. When it is renamed or removed for rotation, the rest of it is parsed and
+// the new file at filePath is followed from the beginning once it appears. When it is truncated in place, which
+// is noticed once it is shorter than the position parsed up to, it is followed from the beginning again. With
+// TailConfig.StateFile, the position parsed up to is recorded after each batch of lines, so that a restart
+// resumes from there as long as the file has not been rotated meanwhile.
+//
+// Each batch of lines is parsed as a separate source, so use Option.HeaderOnce to write the header of TSV or
+// CSV output only once.
+func Tail(ctx context.Context, p Parser, filePath string, cfg TailConfig) (*Result, error) {
+	if cfg.Poll <= 0 {
+		cfg.Poll = defaultTailPoll
+	}
+	state, err := loadTailState(cfg.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	t := &tailer{
+		p:      p,
+		path:   filePath,
+		cfg:    cfg,
+		state:  state,
+		result: &Result{Errors: make([]Errors, 0), Source: filepath.Base(filePath), inputType: inputTypeFile},
+	}
+	defer t.close()
+	ticker := time.NewTicker(cfg.Poll)
+	defer ticker.Stop()
+	for {
+		if err := t.step(ctx); err != nil {
+			return t.result, err
+		}
+		select {
+		case <-ctx.Done():
+			return t.result, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// step opens the file if needed, follows it across rotation and truncation, and parses the lines appended.
+func (t *tailer) step(ctx context.Context) error {
+	if t.file == nil {
+		if err := t.open(); err != nil || t.file == nil {
+			return err
+		}
+	}
+	fi, err := os.Stat(t.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s: %w", tailError, err)
+	}
+	if err != nil || fileID(fi, filepath.Base(t.path)) != t.id {
+		// The file has been rotated: parse the rest of it, including a last line without a newline.
+		if err := t.read(ctx, true); err != nil {
+			return err
+		}
+		t.close()
+		if err := t.open(); err != nil || t.file == nil {
+			return err
+		}
+	}
+	cur, err := t.file.Stat()
+	if err != nil {
+		return fmt.Errorf("%s: %w", tailError, err)
+	}
+	if cur.Size() < t.offset {
+		t.offset = 0
+	}
+	return t.read(ctx, false)
+}
+
+// open opens the file, if it exists, at the position to follow it from: the position recorded in the state
+// file for the same file, the end of the file if it exists when Tail starts unless TailConfig.FromStart is set,
+// and the beginning of a file that appeared later.
+func (t *tailer) open() error {
+	f, err := os.Open(filepath.Clean(t.path))
+	if errors.Is(err, os.ErrNotExist) {
+		// A file appearing later is followed from the beginning.
+		t.opened = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", tailError, err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %w", tailError, err)
+	}
+	t.file, t.id, t.offset = f, fileID(fi, filepath.Base(t.path)), 0
+	switch {
+	case t.state != nil && t.state.ID == t.id && t.state.Offset <= fi.Size():
+		t.offset = t.state.Offset
+	case !t.opened && !t.cfg.FromStart:
+		t.offset = fi.Size()
+	}
+	t.state, t.opened = nil, true
+	return nil
+}
+
+// close closes the file followed, if any.
+func (t *tailer) close() {
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+}
+
+// read parses the complete lines appended since the position parsed up to, or all the rest of the file if all
+// is true, and records the new position.
+func (t *tailer) read(ctx context.Context, all bool) error {
+	fi, err := t.file.Stat()
+	if err != nil {
+		return fmt.Errorf("%s: %w", tailError, err)
+	}
+	end := fi.Size()
+	if !all {
+		if end, err = lastLineEnd(t.file, t.offset, end); err != nil {
+			return fmt.Errorf("%s: %w", tailError, err)
+		}
+	}
+	if end <= t.offset {
+		return nil
+	}
+	r, err := t.p.Parse(io.NewSectionReader(t.file, t.offset, end-t.offset))
+	if r != nil {
+		r.Source = filepath.Base(t.path)
+		t.add(r)
+	}
+	if t.cfg.OnResult != nil {
+		t.cfg.OnResult(r, err)
+	}
+	if errors.Is(err, context.Canceled) && ctx.Err() != nil {
+		// The lines are parsed again on the next run, as with WatchDir.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	t.offset = end
+	if err := saveStateFile(t.cfg.StateFile, &tailState{ID: t.id, Name: filepath.Base(t.path), Offset: end, Saved: time.Now()}); err != nil {
+		return fmt.Errorf("%s: %w", tailError, err)
+	}
+	return nil
+}
+
+// add adds the counts and errors of a batch of lines to the result of all the lines parsed.
+func (t *tailer) add(r *Result) {
+	for _, e := range r.Errors {
+		e.LineNumber += t.result.Total
+		t.result.Errors = append(t.result.Errors, e)
+	}
+	t.result.Total += r.Total
+	t.result.Matched += r.Matched
+	t.result.Unmatched += r.Unmatched
+	t.result.Excluded += r.Excluded
+	t.result.Skipped += r.Skipped
+	t.result.ElapsedTime += r.ElapsedTime
+	t.result.DroppedErrors += r.DroppedErrors
+	t.result.MaxLineLen = max(t.result.MaxLineLen, r.MaxLineLen)
+	t.result.Normalized += r.Normalized
+	t.result.PeakMemory = max(t.result.PeakMemory, r.PeakMemory)
+	t.result.Transforms = r.Transforms
+}
+
+// lastLineEnd returns the position just after the last newline between from and to, or from if there is none.
+func lastLineEnd(r io.ReaderAt, from, to int64) (int64, error) {
+	buf := make([]byte, tailChunkSize)
+	for end := to; end > from; {
+		start := max(end-tailChunkSize, from)
+		b := buf[:end-start]
+		if _, err := r.ReadAt(b, start); err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+		if i := bytes.LastIndexByte(b, '\n'); i >= 0 {
+			return start + int64(i) + 1, nil
+		}
+		end = start
+	}
+	return from, nil
+}
+
+// loadTailState loads the state saved at path, or returns nil if path is empty or does not exist.
+func loadTailState(path string) (*tailState, error) {
+	if path == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", tailError, err)
+	}
+	state := &tailState{}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, fmt.Errorf("%s: invalid state file: %w", tailError, err)
+	}
+	return state, nil
+}
@@ -0,0 +1,171 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe to read while a parse in another goroutine writes to it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// tailRun runs Tail on path, waits for the output to be wants[i] after steps[i], and returns the result.
+func tailRun(t *testing.T, path string, cfg TailConfig, steps []func(), wants []string) (*Result, error) {
+	t.Helper()
+	buf := &syncBuffer{}
+	p := NewLTSVParser(context.Background(), buf, Option{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg.Poll = 10 * time.Millisecond
+	type outcome struct {
+		r   *Result
+		err error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		r, err := Tail(ctx, p, path, cfg)
+		done <- outcome{r, err}
+	}()
+	for i, step := range steps {
+		step()
+		deadline := time.Now().Add(5 * time.Second)
+		for buf.String() != wants[i] {
+			if time.Now().After(deadline) {
+				t.Fatalf("step %d\ngot:\n%v\nwant:\n%v\n", i, buf.String(), wants[i])
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	cancel()
+	o := <-done
+	return o.r, o.err
+}
+
+func TestTail(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	state := filepath.Join(dir, "state.json")
+	write := func(flag int, content string) func() {
+		return func() {
+			f, err := os.OpenFile(path, flag|os.O_WRONLY|os.O_CREATE, 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if _, err := f.WriteString(content); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	appendLine := func(content string) func() { return write(os.O_APPEND, content) }
+	rotate := func(content string) func() {
+		return func() {
+			appendLine("a:partial")()
+			if err := os.Rename(path, path+".1"); err != nil {
+				t.Fatal(err)
+			}
+			write(os.O_TRUNC, content)()
+		}
+	}
+
+	// A line is parsed once its newline is written, and the rest of a rotated file is parsed.
+	appendLine("a:1\n")()
+	r, err := tailRun(t, path, TailConfig{FromStart: true, StateFile: state}, []func(){
+		appendLine("a:"),
+		appendLine("2\n"),
+		rotate("a:30\n"),
+		write(os.O_TRUNC, "a:4\n"),
+	}, []string{
+		`{"a":"1"}` + "\n",
+		`{"a":"1"}` + "\n" + `{"a":"2"}` + "\n",
+		`{"a":"1"}` + "\n" + `{"a":"2"}` + "\n" + `{"a":"partial"}` + "\n" + `{"a":"30"}` + "\n",
+		`{"a":"1"}` + "\n" + `{"a":"2"}` + "\n" + `{"a":"partial"}` + "\n" + `{"a":"30"}` + "\n" + `{"a":"4"}` + "\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Total != 5 || r.Matched != 5 || r.Source != "access.log" {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", r, "5 lines of access.log")
+	}
+
+	// A restart resumes from the position recorded in the state file.
+	appendLine("a:5\nfoo\n")()
+	r, err = tailRun(t, path, TailConfig{StateFile: state}, []func(){
+		appendLine("a:6\n"),
+	}, []string{
+		`{"a":"5"}` + "\n" + `{"a":"6"}` + "\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Total != 3 || r.Unmatched != 1 || len(r.Errors) != 1 || r.Errors[0].LineNumber != 2 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", r, "1 unmatched line at line 2")
+	}
+}
+
+func TestTail_start(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string // content of the file when Tail starts (empty means no file)
+		want   string
+	}{
+		{
+			name:   "existing lines skipped",
+			before: "a:old\n",
+			want:   `{"a":"1"}` + "\n",
+		},
+		{
+			name: "file created later",
+			want: `{"a":"1"}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "access.log")
+			if tt.before != "" {
+				if err := os.WriteFile(path, []byte(tt.before), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			buf := &bytes.Buffer{}
+			tl := &tailer{p: NewLTSVParser(context.Background(), buf, Option{}), path: path, result: &Result{}}
+			defer tl.close()
+			if err := tl.step(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := f.WriteString("a:1\n"); err != nil {
+				t.Fatal(err)
+			}
+			f.Close()
+			if err := tl.step(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", buf.String(), tt.want)
+			}
+		})
+	}
+}
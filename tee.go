@@ -0,0 +1,99 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Tee is an io.Writer duplicating the output to several writers, such as stdout and a file, each with its own
+// failure policy. A required writer failing fails the write, which stops the parse with the error. A best-effort
+// writer failing is detached, so that the output continues to the others, and its error is reported by Err.
+// Writes are flushed to the writers implementing Flush when the parser flushes the output.
+//
+//	NewTee(os.Stdout).BestEffort(file)
+type Tee struct {
+	mu      sync.Mutex
+	outputs []teeOutput
+	errs    []error
+}
+
+// teeOutput is a writer of a Tee with its failure policy.
+type teeOutput struct {
+	w          io.Writer
+	bestEffort bool
+	failed     bool
+}
+
+// NewTee creates a Tee writing to the writers as required writers.
+func NewTee(w ...io.Writer) *Tee {
+	t := &Tee{}
+	for _, x := range w {
+		t.outputs = append(t.outputs, teeOutput{w: x})
+	}
+	return t
+}
+
+// BestEffort adds writers whose failures do not stop the parse.
+func (t *Tee) BestEffort(w ...io.Writer) *Tee {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, x := range w {
+		t.outputs = append(t.outputs, teeOutput{w: x, bestEffort: true})
+	}
+	return t
+}
+
+// Write writes p to the writers that have not failed.
+func (t *Tee) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(p), t.each(func(w io.Writer) error {
+		_, err := w.Write(p)
+		return err
+	})
+}
+
+// Flush flushes the writers that have not failed, if they buffer writes.
+func (t *Tee) Flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.each(flushOutput)
+}
+
+// Err returns the errors of the best-effort writers detached after failing, or nil if none failed.
+func (t *Tee) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return errors.Join(t.errs...)
+}
+
+// each calls fn with the writers that have not failed, and returns the error of the first required writer failing.
+func (t *Tee) each(fn func(w io.Writer) error) error {
+	for i := range t.outputs {
+		o := &t.outputs[i]
+		if o.failed {
+			continue
+		}
+		err := fn(o.w)
+		if err == nil {
+			continue
+		}
+		if !o.bestEffort {
+			return err
+		}
+		o.failed = true
+		t.errs = append(t.errs, fmt.Errorf("best-effort writer %d: %w", i, err))
+	}
+	return nil
+}
+
+// tee returns the writer for the output of a parser: the writer itself if it is the only one, or a Tee of
+// them as required writers. A Tee given as one of several writers is treated as a writer, keeping its policies.
+func tee(w []io.Writer) io.Writer {
+	if len(w) == 1 {
+		return w[0]
+	}
+	return NewTee(w...)
+}
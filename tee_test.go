@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// failWriter fails every write after the first n bytes.
+type failWriter struct {
+	n int
+}
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	if w.n -= len(p); w.n < 0 {
+		return 0, errors.New("disk full")
+	}
+	return len(p), nil
+}
+
+func TestTee(t *testing.T) {
+	input := "host:a\nhost:b\nhost:c\n"
+	want := "{\"host\":\"a\"}\n{\"host\":\"b\"}\n{\"host\":\"c\"}\n"
+	tests := []struct {
+		name    string
+		setup   func(p *LTSVParser, a, b io.Writer) *Tee
+		wantErr string
+		wantTee string
+	}{
+		{
+			name: "required",
+			setup: func(p *LTSVParser, a, b io.Writer) *Tee {
+				p.SetWriters(a, b)
+				return nil
+			},
+		},
+		{
+			name: "best effort failing",
+			setup: func(p *LTSVParser, a, b io.Writer) *Tee {
+				tee := NewTee(a, b).BestEffort(&failWriter{n: 15})
+				p.SetWriters(tee)
+				return tee
+			},
+			wantTee: "best-effort writer 2: disk full",
+		},
+		{
+			name: "required failing",
+			setup: func(p *LTSVParser, a, b io.Writer) *Tee {
+				p.SetWriters(a, &failWriter{n: 15})
+				return nil
+			},
+			wantErr: "disk full",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := &bytes.Buffer{}, &bytes.Buffer{}
+			bw := bufio.NewWriter(b)
+			p := NewLTSVParser(context.Background(), nil, Option{})
+			tee := tt.setup(p, a, bw)
+			_, err := p.ParseString(input)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a.String() != want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", a.String(), want)
+			}
+			if b.String() != want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", b.String(), want)
+			}
+			if tee != nil {
+				if err := tee.Err(); err == nil || err.Error() != tt.wantTee {
+					t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantTee)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// threatFeedLabel is the label of the field added by WithThreatIntel.
+const threatFeedLabel = "threat_feed"
+
+// threatIntelError is the error message prefix for feeds that cannot be loaded.
+const threatIntelError = "cannot load threat feed"
+
+// default fields holding client addresses and user agents, covering the labels used by the built-in parsers
+var (
+	defaultClientIPFields  = []string{"remote_host", "remote_ip", "client_port", "c_ip", "client"}
+	defaultUserAgentFields = []string{"user_agent", "cs_user_agent"}
+)
+
+// stixComparison matches a comparison in a STIX pattern, such as [ipv4-addr:value = '198.51.100.1'].
+var stixComparison = regexp.MustCompile(`\[\s*([a-z0-9-]+):([^=\]]+?)\s*=\s*'((?:[^'\\]|\\.)*)'\s*\]`)
+
+// ThreatFeed is a denylist of IP addresses and user agents.
+//
+// The list is either plain text or STIX-lite. Plain text has one entry per line: an IP address or CIDR
+// prefix, or otherwise a user agent substring matched case-insensitively. Blank lines and lines starting
+// with "#" are ignored. STIX-lite is a JSON bundle whose "objects" contain indicators with patterns such as
+// "[ipv4-addr:value = '198.51.100.0/24'] OR [ipv6-addr:value = '2001:db8::1']" or comparisons of a path
+// ending with User-Agent; other comparisons are ignored.
+type ThreatFeed struct {
+	Name string                        // name of the feed, output in the threat_feed field
+	Open func() (io.ReadCloser, error) // opens the list, called on each refresh
+}
+
+// FileFeed returns a ThreatFeed reading the list from the file.
+func FileFeed(name, path string) ThreatFeed {
+	return ThreatFeed{
+		Name: name,
+		Open: func() (io.ReadCloser, error) { return os.Open(filepath.Clean(path)) },
+	}
+}
+
+// ThreatIntelConfig defines the feeds and the fields evaluated by ThreatIntel.
+type ThreatIntelConfig struct {
+	Feeds    []ThreatFeed // feeds to load
+	IPFields []string     // fields holding client addresses, such as "192.0.2.1" or "192.0.2.1:443" (nil means the labels of the built-in parsers)
+	UAFields []string     // fields holding user agents (nil means user_agent and cs_user_agent)
+}
+
+// ThreatIntel matches records against threat intelligence feeds. Feeds can be refreshed while parsing,
+// and lookups always see a complete set of entries.
+type ThreatIntel struct {
+	cfg     ThreatIntelConfig
+	mu      sync.Mutex
+	entries []*threatEntries // entries of each feed, in the order of the feeds
+	index   atomic.Pointer[threatIndex]
+}
+
+// threatEntries are the entries loaded from a feed.
+type threatEntries struct {
+	addrs    []netip.Addr
+	prefixes []netip.Prefix
+	agents   []string // lowercased
+}
+
+// threatIndex is the lookup structure built from the entries of all feeds.
+type threatIndex struct {
+	feeds    []string
+	addrs    map[netip.Addr][]int // feed positions by address
+	prefixes []threatPrefix
+	agents   []threatAgent
+}
+
+type threatPrefix struct {
+	prefix netip.Prefix
+	feed   int
+}
+
+type threatAgent struct {
+	agent string
+	feed  int
+}
+
+// NewThreatIntel creates a ThreatIntel and loads the feeds.
+func NewThreatIntel(cfg ThreatIntelConfig) (*ThreatIntel, error) {
+	for _, feed := range cfg.Feeds {
+		if feed.Name == "" || feed.Open == nil {
+			return nil, fmt.Errorf("%s: %q: name and open function are required", threatIntelError, feed.Name)
+		}
+	}
+	if cfg.IPFields == nil {
+		cfg.IPFields = defaultClientIPFields
+	}
+	if cfg.UAFields == nil {
+		cfg.UAFields = defaultUserAgentFields
+	}
+	t := &ThreatIntel{cfg: cfg, entries: make([]*threatEntries, len(cfg.Feeds))}
+	if err := t.Refresh(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Refresh reloads the feeds. A feed that fails to load keeps its previous entries,
+// and the errors are joined into the returned error.
+func (t *ThreatIntel) Refresh() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var errs []error
+	for i, feed := range t.cfg.Feeds {
+		entries, err := loadThreatFeed(feed)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %q: %w", threatIntelError, feed.Name, err))
+			continue
+		}
+		t.entries[i] = entries
+	}
+	t.index.Store(t.build())
+	return errors.Join(errs...)
+}
+
+// RefreshEvery refreshes the feeds at the interval until ctx is done or the returned function is called.
+// Errors are passed to onError if it is not nil.
+func (t *ThreatIntel) RefreshEvery(ctx context.Context, interval time.Duration, onError func(error)) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := t.Refresh(); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// Lookup returns the names of the feeds the record hits, in the order of the feeds.
+func (t *ThreatIntel) Lookup(r Record) []string {
+	idx := t.index.Load()
+	hit := make([]bool, len(idx.feeds))
+	for _, field := range t.cfg.IPFields {
+		v, ok := r.Get(field)
+		if !ok {
+			continue
+		}
+		addr, ok := parseClientAddr(v)
+		if !ok {
+			continue
+		}
+		for _, i := range idx.addrs[addr] {
+			hit[i] = true
+		}
+		for _, p := range idx.prefixes {
+			if p.prefix.Contains(addr) {
+				hit[p.feed] = true
+			}
+		}
+	}
+	for _, field := range t.cfg.UAFields {
+		v, ok := r.Get(field)
+		if !ok || v == "" || v == "-" {
+			continue
+		}
+		v = strings.ToLower(v)
+		for _, a := range idx.agents {
+			if !hit[a.feed] && strings.Contains(v, a.agent) {
+				hit[a.feed] = true
+			}
+		}
+	}
+	var feeds []string
+	for i, ok := range hit {
+		if ok {
+			feeds = append(feeds, idx.feeds[i])
+		}
+	}
+	return feeds
+}
+
+// build creates the index from the entries of the feeds loaded so far.
+func (t *ThreatIntel) build() *threatIndex {
+	idx := &threatIndex{addrs: map[netip.Addr][]int{}}
+	for i, feed := range t.cfg.Feeds {
+		idx.feeds = append(idx.feeds, feed.Name)
+		entries := t.entries[i]
+		if entries == nil {
+			continue
+		}
+		for _, addr := range entries.addrs {
+			if !slices.Contains(idx.addrs[addr], i) {
+				idx.addrs[addr] = append(idx.addrs[addr], i)
+			}
+		}
+		for _, prefix := range entries.prefixes {
+			idx.prefixes = append(idx.prefixes, threatPrefix{prefix: prefix, feed: i})
+		}
+		for _, agent := range entries.agents {
+			idx.agents = append(idx.agents, threatAgent{agent: agent, feed: i})
+		}
+	}
+	return idx
+}
+
+// loadThreatFeed reads the entries of the feed, detecting STIX-lite by a leading "{".
+func loadThreatFeed(feed ThreatFeed) (*threatEntries, error) {
+	rc, err := feed.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	entries := &threatEntries{}
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) {
+		return entries, entries.loadSTIX(b)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries.add(line, true)
+	}
+	return entries, scanner.Err()
+}
+
+// loadSTIX reads the entries from the indicators of a STIX bundle.
+func (e *threatEntries) loadSTIX(b []byte) error {
+	var bundle struct {
+		Objects []struct {
+			Type    string `json:"type"`
+			Pattern string `json:"pattern"`
+		} `json:"objects"`
+	}
+	if err := json.Unmarshal(b, &bundle); err != nil {
+		return err
+	}
+	for _, obj := range bundle.Objects {
+		if obj.Type != "indicator" {
+			continue
+		}
+		for _, m := range stixComparison.FindAllStringSubmatch(obj.Pattern, -1) {
+			value := strings.NewReplacer(`\'`, `'`, `\\`, `\`).Replace(m[3])
+			switch {
+			case m[1] == "ipv4-addr" || m[1] == "ipv6-addr":
+				e.add(value, false)
+			case strings.HasSuffix(strings.ToLower(strings.TrimRight(m[2], "'")), "user-agent"):
+				e.agents = append(e.agents, strings.ToLower(value))
+			}
+		}
+	}
+	return nil
+}
+
+// add adds an address or prefix entry, or a user agent entry if it is neither and agent is true.
+func (e *threatEntries) add(s string, agent bool) {
+	if prefix, err := netip.ParsePrefix(s); err == nil {
+		e.prefixes = append(e.prefixes, prefix.Masked())
+		return
+	}
+	if addr, err := netip.ParseAddr(s); err == nil {
+		e.addrs = append(e.addrs, addr.Unmap())
+		return
+	}
+	if agent {
+		e.agents = append(e.agents, strings.ToLower(s))
+	}
+}
+
+// parseClientAddr parses a client address, with or without a port.
+func parseClientAddr(s string) (netip.Addr, bool) {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap(), true
+	}
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr().Unmap(), true
+	}
+	return netip.Addr{}, false
+}
+
+// WithThreatIntel returns an Enricher that adds the field "threat_feed", the comma-separated names of the feeds
+// the record hits, or empty if none. Hits can be kept with PostFilters such as "threat_feed =~ .", dropped
+// with "threat_feed !~ .", or sent elsewhere with Routes, e.g. When("threat_feed =~ .").To(alertSink).
+func WithThreatIntel(t *ThreatIntel) Enricher {
+	return func(r Record) (Record, error) {
+		return r.With(threatFeedLabel, strings.Join(t.Lookup(r), ",")), nil
+	}
+}
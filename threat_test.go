@@ -0,0 +1,177 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func stringFeed(name, s string) ThreatFeed {
+	return ThreatFeed{
+		Name: name,
+		Open: func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader(s)), nil },
+	}
+}
+
+func TestThreatIntel_Lookup(t *testing.T) {
+	stix := `{
+  "type": "bundle",
+  "objects": [
+    {"type": "indicator", "pattern": "[ipv4-addr:value = '203.0.113.0/24'] OR [ipv6-addr:value = '2001:db8::1']"},
+    {"type": "indicator", "pattern": "[network-traffic:extensions.'http-request-ext'.request_header.'User-Agent' = 'EvilBot']"},
+    {"type": "malware", "name": "ignored"}
+  ]
+}`
+	ti, err := NewThreatIntel(ThreatIntelConfig{
+		Feeds: []ThreatFeed{
+			stringFeed("blocklist", "# comment\n\n192.0.2.1\n198.51.100.0/28\nsqlmap\n"),
+			stringFeed("stix", stix),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		labels []string
+		values []string
+		want   []string
+	}{
+		{
+			name:   "clean",
+			labels: []string{"remote_host", "user_agent"},
+			values: []string{"192.0.2.2", "Mozilla/5.0"},
+			want:   nil,
+		},
+		{
+			name:   "address",
+			labels: []string{"remote_host"},
+			values: []string{"192.0.2.1"},
+			want:   []string{"blocklist"},
+		},
+		{
+			name:   "prefix with port",
+			labels: []string{"client_port"},
+			values: []string{"203.0.113.10:443"},
+			want:   []string{"stix"},
+		},
+		{
+			name:   "ipv6",
+			labels: []string{"c_ip"},
+			values: []string{"2001:db8::1"},
+			want:   []string{"stix"},
+		},
+		{
+			name:   "user agent in both feeds",
+			labels: []string{"remote_host", "user_agent"},
+			values: []string{"198.51.100.3", "evilbot/2.0"},
+			want:   []string{"blocklist", "stix"},
+		},
+		{
+			name:   "user agent substring",
+			labels: []string{"user_agent"},
+			values: []string{"sqlmap/1.7#stable"},
+			want:   []string{"blocklist"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ti.Lookup(Record{Labels: tt.labels, Values: tt.values})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThreatIntel_Refresh(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feed.txt")
+	if err := os.WriteFile(path, []byte("192.0.2.1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ti, err := NewThreatIntel(ThreatIntelConfig{Feeds: []ThreatFeed{FileFeed("file", path)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := Record{Labels: []string{"remote_host"}, Values: []string{"192.0.2.9"}}
+	if got := ti.Lookup(r); got != nil {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, nil)
+	}
+	if err := os.WriteFile(path, []byte("192.0.2.9\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ti.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if got := ti.Lookup(r); !reflect.DeepEqual(got, []string{"file"}) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, []string{"file"})
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := ti.Refresh(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, os.ErrNotExist)
+	}
+	if got := ti.Lookup(r); !reflect.DeepEqual(got, []string{"file"}) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, []string{"file"})
+	}
+}
+
+func TestNewThreatIntel_error(t *testing.T) {
+	tests := []struct {
+		name  string
+		feeds []ThreatFeed
+	}{
+		{
+			name:  "no name",
+			feeds: []ThreatFeed{stringFeed("", "192.0.2.1")},
+		},
+		{
+			name:  "invalid stix",
+			feeds: []ThreatFeed{stringFeed("stix", "{")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewThreatIntel(ThreatIntelConfig{Feeds: tt.feeds}); err == nil {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "error")
+			}
+		})
+	}
+}
+
+func TestWithThreatIntel(t *testing.T) {
+	ti, err := NewThreatIntel(ThreatIntelConfig{Feeds: []ThreatFeed{stringFeed("scanners", "192.0.2.2\nzgrab\n")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := strings.Join([]string{
+		`192.0.2.1 - - [10/Oct/2000:13:55:36 -0700] "GET /index.html HTTP/1.1" 200 2326 "-" "Mozilla/5.0"`,
+		`192.0.2.2 - - [10/Oct/2000:13:55:37 -0700] "GET / HTTP/1.1" 200 2326 "-" "Mozilla/5.0"`,
+		`192.0.2.3 - - [10/Oct/2000:13:55:38 -0700] "GET / HTTP/1.1" 404 0 "-" "Mozilla/5.0 zgrab/0.x"`,
+	}, "\n")
+	buf := &bytes.Buffer{}
+	p := NewApacheCLFRegexParser(context.Background(), buf, Option{
+		Labels:      []string{"remote_host", "threat_feed"},
+		Enrichers:   []Enricher{WithThreatIntel(ti)},
+		PostFilters: []string{"threat_feed =~ ."},
+	})
+	result, err := p.ParseString(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"remote_host":"192.0.2.2","threat_feed":"scanners"}` + "\n" +
+		`{"remote_host":"192.0.2.3","threat_feed":"scanners"}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+	}
+	if result.Excluded != 1 {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", result.Excluded, 1)
+	}
+}
@@ -0,0 +1,223 @@
+package parser
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// clfTimeLen is the length of a CLF timestamp without brackets, such as "16/Feb/2019:11:23:45 +0000".
+const clfTimeLen = len("02/Jan/2006:15:04:05 -0700")
+
+// clfZones caches the fixed zones of the UTC offsets seen in CLF timestamps, so that they are not created per line.
+var clfZones sync.Map
+
+// clfTime holds the fields of a CLF timestamp.
+type clfTime struct {
+	year, month, day, hour, min, sec int
+	offset                           int // minutes east of UTC
+}
+
+// parseCLFTime parses a CLF timestamp, with or without brackets, by its fixed positions instead of a layout.
+// The month names are English whatever the locale. It reports false for anything time.Parse might handle
+// differently, so that callers can fall back to it.
+func parseCLFTime(v string) (clfTime, bool) {
+	if len(v) == clfTimeLen+2 && v[0] == '[' && v[len(v)-1] == ']' {
+		v = v[1 : len(v)-1]
+	}
+	if len(v) != clfTimeLen || v[2] != '/' || v[6] != '/' || v[11] != ':' || v[14] != ':' || v[17] != ':' || v[20] != ' ' {
+		return clfTime{}, false
+	}
+	ok := true
+	digits := func(s string) int {
+		n, valid := atoiFixed(s)
+		ok = ok && valid
+		return n
+	}
+	c := clfTime{
+		year:  digits(v[7:11]),
+		month: clfMonth(v[3:6]),
+		day:   digits(v[0:2]),
+		hour:  digits(v[12:14]),
+		min:   digits(v[15:17]),
+		sec:   digits(v[18:20]),
+	}
+	zh, zm := digits(v[22:24]), digits(v[24:26])
+	if !ok || c.month == 0 || c.day < 1 || c.day > daysIn(c.month, c.year) ||
+		c.hour > 23 || c.min > 59 || c.sec > 59 || zh > 23 || zm > 59 {
+		return clfTime{}, false
+	}
+	c.offset = zh*60 + zm
+	switch v[21] {
+	case '+':
+	case '-':
+		c.offset = -c.offset
+	default:
+		return clfTime{}, false
+	}
+	return c, true
+}
+
+// atoiFixed parses the digits of s, and reports whether all of them are digits.
+func atoiFixed(s string) (int, bool) {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+		n = n*10 + int(s[i]-'0')
+	}
+	return n, true
+}
+
+// clfMonth returns the number of the English month abbreviation, or 0 if it is not one.
+func clfMonth(s string) int {
+	switch s {
+	case "Jan":
+		return 1
+	case "Feb":
+		return 2
+	case "Mar":
+		return 3
+	case "Apr":
+		return 4
+	case "May":
+		return 5
+	case "Jun":
+		return 6
+	case "Jul":
+		return 7
+	case "Aug":
+		return 8
+	case "Sep":
+		return 9
+	case "Oct":
+		return 10
+	case "Nov":
+		return 11
+	case "Dec":
+		return 12
+	}
+	return 0
+}
+
+// daysIn returns the number of days in the month of the year.
+func daysIn(month, year int) int {
+	switch month {
+	case 2:
+		if year%4 == 0 && (year%100 != 0 || year%400 == 0) {
+			return 29
+		}
+		return 28
+	case 4, 6, 9, 11:
+		return 30
+	}
+	return 31
+}
+
+// time returns the timestamp as a time.Time in the zone of its offset.
+func (c clfTime) time() time.Time {
+	loc := time.UTC
+	if c.offset != 0 {
+		z, ok := clfZones.Load(c.offset)
+		if !ok {
+			z, _ = clfZones.LoadOrStore(c.offset, time.FixedZone("", c.offset*60))
+		}
+		loc = z.(*time.Location)
+	}
+	return time.Date(c.year, time.Month(c.month), c.day, c.hour, c.min, c.sec, 0, loc)
+}
+
+// appendRFC3339 appends the timestamp in RFC 3339 as time.RFC3339 formats it, with "Z" for UTC.
+func (c clfTime) appendRFC3339(b []byte) []byte {
+	b = appendDigits(b, c.year, 4)
+	b = append(b, '-')
+	b = appendDigits(b, c.month, 2)
+	b = append(b, '-')
+	b = appendDigits(b, c.day, 2)
+	b = append(b, 'T')
+	b = appendDigits(b, c.hour, 2)
+	b = append(b, ':')
+	b = appendDigits(b, c.min, 2)
+	b = append(b, ':')
+	b = appendDigits(b, c.sec, 2)
+	if c.offset == 0 {
+		return append(b, 'Z')
+	}
+	offset := c.offset
+	if offset < 0 {
+		b = append(b, '-')
+		offset = -offset
+	} else {
+		b = append(b, '+')
+	}
+	b = appendDigits(b, offset/60, 2)
+	b = append(b, ':')
+	return appendDigits(b, offset%60, 2)
+}
+
+// appendDigits appends n zero-padded to width digits.
+func appendDigits(b []byte, n, width int) []byte {
+	var d [4]byte
+	for i := width - 1; i >= 0; i-- {
+		d[i] = byte('0' + n%10)
+		n /= 10
+	}
+	return append(b, d[:width]...)
+}
+
+// ReformatCLFTime reformats a CLF timestamp such as "[16/Feb/2019:11:23:45 +0000]", with or without brackets,
+// into RFC 3339 such as "2019-02-16T11:23:45Z", and reports whether the value is one. Unlike time.Parse, it
+// reads the digits at their fixed positions without a layout and does not depend on the locale, as the
+// timestamp is a well-known hotspot of converting access logs.
+func ReformatCLFTime(v string) (string, bool) {
+	c, ok := parseCLFTime(v)
+	if !ok {
+		return v, false
+	}
+	var b [len("2006-01-02T15:04:05-07:00")]byte
+	return string(c.appendRFC3339(b[:0])), true
+}
+
+// loadTimezone returns the zone of the IANA name, or nil for "" to keep the offsets of the logs.
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return nil, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %q: %w", timezoneError, name, err)
+	}
+	return loc, nil
+}
+
+// formatTime returns the timestamp in RFC 3339 in the zone of loc, or with its own offset if loc is nil,
+// and reports whether the value is a timestamp.
+func formatTime(v string, loc *time.Location) (string, bool) {
+	if loc == nil {
+		if s, ok := ReformatCLFTime(v); ok {
+			return s, true
+		}
+	}
+	t, ok := parseTime(v)
+	if !ok {
+		return v, false
+	}
+	if loc != nil {
+		t = t.In(loc)
+	}
+	return t.Format(time.RFC3339Nano), true
+}
+
+// formatTimes returns the values with those of the fields in labels formatted as RFC 3339 timestamps in the
+// zone of loc. Values that are not timestamps, such as "-", are left as is.
+func formatTimes(labels, values, timeFields []string, loc *time.Location) []string {
+	vs := slices.Clone(values)
+	for i, label := range labels {
+		if i < len(vs) && slices.Contains(timeFields, label) {
+			vs[i], _ = formatTime(vs[i], loc)
+		}
+	}
+	return vs
+}
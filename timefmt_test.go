@@ -0,0 +1,151 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReformatCLFTime(t *testing.T) {
+	tests := []struct {
+		name   string
+		v      string
+		want   string
+		wantOK bool
+	}{
+		{
+			name:   "bracketed utc",
+			v:      "[16/Feb/2019:11:23:45 +0000]",
+			want:   "2019-02-16T11:23:45Z",
+			wantOK: true,
+		},
+		{
+			name:   "negative offset",
+			v:      "10/Oct/2000:13:55:36 -0700",
+			want:   "2000-10-10T13:55:36-07:00",
+			wantOK: true,
+		},
+		{
+			name:   "positive offset with minutes",
+			v:      "[31/Dec/2023:23:59:59 +0530]",
+			want:   "2023-12-31T23:59:59+05:30",
+			wantOK: true,
+		},
+		{
+			name:   "leap day",
+			v:      "29/Feb/2024:00:00:00 +0900",
+			want:   "2024-02-29T00:00:00+09:00",
+			wantOK: true,
+		},
+		{
+			name: "not a leap year",
+			v:    "29/Feb/2023:00:00:00 +0900",
+			want: "29/Feb/2023:00:00:00 +0900",
+		},
+		{
+			name: "localized month",
+			v:    "16/Fév/2019:11:23:45 +0000",
+			want: "16/Fév/2019:11:23:45 +0000",
+		},
+		{
+			name: "hour out of range",
+			v:    "16/Feb/2019:24:00:00 +0000",
+			want: "16/Feb/2019:24:00:00 +0000",
+		},
+		{
+			name: "unbalanced bracket",
+			v:    "[16/Feb/2019:11:23:45 +0000",
+			want: "[16/Feb/2019:11:23:45 +0000",
+		},
+		{
+			name: "rfc3339",
+			v:    "2019-02-16T11:23:45Z",
+			want: "2019-02-16T11:23:45Z",
+		},
+		{
+			name: "null",
+			v:    "-",
+			want: "-",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ReformatCLFTime(tt.v)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("\ngot:\n%v %v\nwant:\n%v %v\n", got, ok, tt.want, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			// The fast path must agree with time.Parse.
+			layout := "02/Jan/2006:15:04:05 -0700"
+			if tt.v[0] == '[' {
+				layout = "[" + layout + "]"
+			}
+			tm, err := time.Parse(layout, tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := tm.Format(time.RFC3339); got != want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, want)
+			}
+			if parsed, _ := parseTime(tt.v); !parsed.Equal(tm) || parsed.Format(time.RFC3339) != got {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", parsed, tm)
+			}
+		})
+	}
+}
+
+func TestOption_TimeFields(t *testing.T) {
+	input := "time:[16/Feb/2019:11:23:45 +0900]\tend:2019-02-16 12:00:00\tstart:-\n"
+	tests := []struct {
+		name    string
+		opt     Option
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "as is",
+			opt:  Option{LineHandler: KeyValuePairLineHandler},
+			want: `time="[16/Feb/2019:11:23:45 +0900]" end="2019-02-16 12:00:00" start="-"` + "\n",
+		},
+		{
+			name: "rfc3339",
+			opt:  Option{LineHandler: KeyValuePairLineHandler, TimeFields: []string{"time", "end", "start"}},
+			want: `time="2019-02-16T11:23:45+09:00" end="2019-02-16T12:00:00Z" start="-"` + "\n",
+		},
+		{
+			name: "typed json",
+			opt:  Option{Types: map[string]FieldType{"time": FieldTypeTime, "start": FieldTypeTime}},
+			want: `{"time":"2019-02-16T11:23:45+09:00","end":"2019-02-16 12:00:00","start":null}` + "\n",
+		},
+		{
+			name: "output timezone",
+			opt:  Option{LineHandler: KeyValuePairLineHandler, TimeFields: []string{"time", "end", "start"}, OutputTimezone: "UTC"},
+			want: `time="2019-02-16T02:23:45Z" end="2019-02-16T12:00:00Z" start="-"` + "\n",
+		},
+		{
+			name: "typed json with output timezone",
+			opt:  Option{Types: map[string]FieldType{"time": FieldTypeTime, "end": FieldTypeTime}, OutputTimezone: "Asia/Tokyo"},
+			want: `{"time":"2019-02-16T11:23:45+09:00","end":"2019-02-16T21:00:00+09:00","start":"-"}` + "\n",
+		},
+		{
+			name:    "unknown timezone",
+			opt:     Option{TimeFields: []string{"time"}, OutputTimezone: "Asia/Nowhere"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			_, err := NewLTSVParser(context.Background(), buf, tt.opt).ParseString(input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("\ngot:\n%v\nwant:\n%v\n", err, tt.wantErr)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", buf.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package parser
+
+// stages of the pipeline reported to the function of a Tracer
+const (
+	TraceRead      = "read"      // the line was read
+	TraceSkip      = "skip"      // the line was skipped by SkipLines, SkipRanges or Offset
+	TraceExclude   = "exclude"   // the line or record was excluded, with the reason in RecordContext.Reason
+	TraceUnmatch   = "unmatch"   // the line matched no pattern, with the error in RecordContext.Err
+	TraceDecode    = "decode"    // the line was decoded into the labels and values
+	TraceEnrich    = "enrich"    // the record was enriched by Enrichers
+	TraceAggregate = "aggregate" // the record was added to the groups of GroupBy and Aggregates
+	TraceWrite     = "write"     // the record was written, with the line in RecordContext.Output
+)
+
+// reasons of TraceExclude
+const (
+	traceLineNumber = "line number filter"
+	tracePushdown   = "pushdown"
+	traceLineFilter = "line filter"
+	traceFilter     = "filter"
+	traceWindow     = "time range"
+	tracePostFilter = "post filter"
+	traceSeen       = "seen"
+)
+
+// RecordContext is the state of a sampled line at a stage of the pipeline, passed to the function of a Tracer.
+// Labels and Values are those of the record at the stage, and must not be modified.
+type RecordContext struct {
+	LineNumber int      // line number in the source
+	Offset     int64    // byte offset at which the line starts in the (decompressed) source
+	Line       string   // original line
+	Labels     []string // labels of the record, nil before TraceDecode
+	Values     []string // values of the record, nil before TraceDecode
+	Reason     string   // filter the record was excluded by, such as "filter" or "post filter", on TraceExclude
+	Output     string   // line written, on TraceWrite
+	Err        error    // error of the decoder, on TraceUnmatch
+}
+
+// Tracer reports the stages of the pipeline sampled lines go through, set to Option.Trace, so that questions
+// such as why a line was excluded can be answered in production without reprocessing everything in debug
+// mode. Lines are sampled by their line number, starting from the first one, as in:
+//
+//	WithTrace(func(stage string, rec RecordContext) { log.Println(stage, rec.LineNumber, rec.Reason) }).Every(10000)
+//
+// With Option.Concurrency, the function is called concurrently for the lines of different zip entries.
+type Tracer struct {
+	fn    func(stage string, rec RecordContext)
+	every int
+}
+
+// WithTrace creates a Tracer calling fn at each stage of the pipeline for every line, unless sampled with Every.
+func WithTrace(fn func(stage string, rec RecordContext)) *Tracer {
+	return &Tracer{fn: fn, every: 1}
+}
+
+// Every samples 1 in n lines, those whose line number minus one is a multiple of n. n below 1 means every line.
+func (t *Tracer) Every(n int) *Tracer {
+	t.every = max(n, 1)
+	return t
+}
+
+// begin returns the trace of the line and reports TraceRead if the line is sampled, or returns nil otherwise.
+func (t *Tracer) begin(no int, offset int64, line []byte) *trace {
+	if t == nil || t.fn == nil || (no-1)%t.every != 0 {
+		return nil
+	}
+	tr := &trace{fn: t.fn, rec: RecordContext{LineNumber: no, Offset: offset, Line: string(line)}}
+	tr.emit(TraceRead)
+	return tr
+}
+
+// trace is the state of a sampled line. Its methods do nothing on a nil trace, so that lines not sampled
+// cost no more than a nil check at each stage.
+type trace struct {
+	fn  func(stage string, rec RecordContext)
+	rec RecordContext
+}
+
+// emit reports the stage with the current state of the line.
+func (tr *trace) emit(stage string) {
+	if tr != nil {
+		tr.fn(stage, tr.rec)
+	}
+}
+
+// record reports the stage with the labels and values of the record.
+func (tr *trace) record(stage string, labels, values []string) {
+	if tr != nil {
+		tr.rec.Labels, tr.rec.Values = labels, values
+		tr.emit(stage)
+	}
+}
+
+// exclude reports TraceExclude with the reason and the labels and values of the record, if decoded.
+func (tr *trace) exclude(reason string, labels, values []string) {
+	if tr != nil {
+		tr.rec.Reason = reason
+		tr.record(TraceExclude, labels, values)
+		tr.rec.Reason = ""
+	}
+}
+
+// unmatch reports TraceUnmatch with the error of the decoder.
+func (tr *trace) unmatch(err error) {
+	if tr != nil {
+		tr.rec.Err = err
+		tr.emit(TraceUnmatch)
+	}
+}
+
+// write reports TraceWrite with the record and the line written.
+func (tr *trace) write(labels, values []string, line string) {
+	if tr != nil {
+		tr.rec.Output = line
+		tr.record(TraceWrite, labels, values)
+		tr.rec.Output = ""
+	}
+}
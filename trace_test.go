@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOption_Trace(t *testing.T) {
+	input := "a:1\tb:x\nbroken\na:2\tb:y\na:3\tb:z\na:4\tb:w\n"
+	tests := []struct {
+		name  string
+		opt   Option
+		every int
+		want  []string
+	}{
+		{
+			name: "all lines",
+			opt:  Option{Filters: []string{"b != y"}, SkipLines: []int{4}},
+			want: []string{
+				"1 read", "1 decode [x]", "1 write [x] {\"a\":\"1\",\"b\":\"x\"}",
+				"2 read", "2 unmatch",
+				"3 read", "3 decode [y]", "3 exclude [y] filter",
+				"4 read", "4 skip",
+				"5 read", "5 decode [w]", "5 write [w] {\"a\":\"4\",\"b\":\"w\"}",
+			},
+		},
+		{
+			name:  "sampled",
+			opt:   Option{LineFilters: []string{"line !~ a:4"}},
+			every: 2,
+			want:  []string{"1 read", "1 decode [x]", "1 write [x] {\"a\":\"1\",\"b\":\"x\"}", "3 read", "3 decode [y]", "3 write [y] {\"a\":\"2\",\"b\":\"y\"}", "5 read", "5 exclude line filter"},
+		},
+		{
+			name:  "enriched and post filtered",
+			opt:   Option{Enrichers: []Enricher{func(r Record) (Record, error) { return r.With("b", r.Values[0]), nil }}, PostFilters: []string{"b != 3"}, Labels: []string{"b"}},
+			every: 3,
+			want:  []string{"1 read", "1 decode [x]", "1 enrich [1]", "1 write [1] {\"b\":\"1\"}", "4 read", "4 decode [z]", "4 enrich [3]", "4 exclude [3] post filter"},
+		},
+		{
+			name:  "aggregated",
+			opt:   Option{Aggregates: []string{"count"}},
+			every: 4,
+			want:  []string{"1 read", "1 decode [x]", "1 aggregate [x]", "5 read", "5 decode [w]", "5 aggregate [w]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			var offsets []int64
+			tt.opt.Trace = WithTrace(func(stage string, rec RecordContext) {
+				s := fmt.Sprintf("%d %s", rec.LineNumber, stage)
+				if len(rec.Values) > 0 {
+					s += fmt.Sprintf(" [%s]", rec.Values[len(rec.Values)-1])
+				}
+				if rec.Reason != "" {
+					s += " " + rec.Reason
+				}
+				if rec.Output != "" {
+					s += " " + rec.Output
+				}
+				if stage == TraceRead {
+					offsets = append(offsets, rec.Offset)
+				}
+				if (stage == TraceUnmatch) != (rec.Err != nil) || !strings.HasSuffix(input[:rec.Offset+int64(len(rec.Line))+1], rec.Line+"\n") {
+					t.Errorf("\ngot:\n%v %v %v\nwant:\n%v\n", stage, rec.Err, rec.Offset, "error on unmatch and offset of the line")
+				}
+				got = append(got, s)
+			}).Every(tt.every)
+			tt.opt.LineHandler = JSONLineHandler
+			if _, err := NewLTSVParser(context.Background(), io.Discard, tt.opt).ParseString(input); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("\ngot:\n%q\nwant:\n%q\n", got, tt.want)
+			}
+			if offsets[0] != 0 {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", offsets[0], 0)
+			}
+		})
+	}
+}
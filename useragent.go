@@ -0,0 +1,159 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// labels of the fields added by WithUserAgent
+const (
+	uaBrowserLabel = "ua_browser"
+	uaOSLabel      = "ua_os"
+	uaDeviceLabel  = "ua_device"
+	uaBotLabel     = "bot"
+)
+
+// devices output by WithUserAgent
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+	DeviceOther   = "other"
+)
+
+// uaOther is the browser or OS of user agents not recognized.
+const uaOther = "Other"
+
+// uaCacheSize is the number of user agents whose results are cached by WithUserAgent. The cache is cleared
+// when full, so that streams with unbounded distinct user agents do not grow memory.
+const uaCacheSize = 4096
+
+// uaRule maps user agents matching the pattern to a name.
+type uaRule struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// uaBrowsers are the browsers recognized, in the order tried, since most browsers also claim to be others.
+var uaBrowsers = []uaRule{
+	{"Edge", regexp.MustCompile(`\bEdg(?:e|A|iOS)?/`)},
+	{"Opera", regexp.MustCompile(`\bOPR/|\bOPiOS/|^Opera/`)},
+	{"Samsung Internet", regexp.MustCompile(`\bSamsungBrowser/`)},
+	{"Firefox", regexp.MustCompile(`\b(?:Firefox|FxiOS)/`)},
+	{"Chrome", regexp.MustCompile(`\b(?:Chrome|CriOS|Chromium)/`)},
+	{"Safari", regexp.MustCompile(`\bVersion/[\d.]+.*\bSafari/`)},
+	{"Internet Explorer", regexp.MustCompile(`\bMSIE |\bTrident/`)},
+}
+
+// uaOSes are the operating systems recognized, in the order tried.
+var uaOSes = []uaRule{
+	{"Windows", regexp.MustCompile(`\bWindows\b`)},
+	{"iOS", regexp.MustCompile(`\b(?:iPhone|iPad|iPod)\b`)},
+	{"Android", regexp.MustCompile(`\bAndroid\b`)},
+	{"ChromeOS", regexp.MustCompile(`\bCrOS\b`)},
+	{"macOS", regexp.MustCompile(`\bMac OS X\b|\bMacintosh\b`)},
+	{"Linux", regexp.MustCompile(`\bLinux\b`)},
+}
+
+// uaKnownBots are the crawlers named by ParseUserAgent.
+var uaKnownBots = DefaultKnownBots()
+
+// uaTablet and uaMobile match user agents of tablets and of other mobile devices. Android tablets are those
+// without "Mobile", which Android phones have.
+var (
+	uaTablet = regexp.MustCompile(`\biPad\b|\bTablet\b`)
+	uaMobile = regexp.MustCompile(`\bMobi|\biPhone\b|\biPod\b`)
+)
+
+// UserAgent is the browser, operating system and device derived from a user agent by ParseUserAgent.
+type UserAgent struct {
+	Browser string // browser, the name of the crawler for bots, or "Other"
+	OS      string // operating system, or "Other"
+	Device  string // one of "desktop", "mobile", "tablet", "bot" and "other"
+	Bot     bool   // whether the client is automated, identified in the same way as by ClientClassifier
+}
+
+// ParseUserAgent derives the browser, operating system and device from a user agent. Well-known crawlers are
+// named after DefaultKnownBots, and other automated clients after the first product of the user agent, such as
+// "curl". Percent-encoded user agents, as written by CloudFront, are decoded first.
+func ParseUserAgent(s string) UserAgent {
+	s = percentDecode(s)
+	ua := UserAgent{Browser: uaOther, OS: uaOther, Device: DeviceOther}
+	for _, rule := range uaOSes {
+		if rule.pattern.MatchString(s) {
+			ua.OS = rule.name
+			break
+		}
+	}
+	for _, bot := range uaKnownBots {
+		if bot.Agent.MatchString(s) {
+			ua.Browser, ua.Device, ua.Bot = bot.Name, DeviceBot, true
+			return ua
+		}
+	}
+	if defaultBotAgent.MatchString(s) {
+		ua.Device, ua.Bot = DeviceBot, true
+		if product, _, _ := strings.Cut(s, "/"); product != "" && product != "-" && product != "Mozilla" && !strings.Contains(product, " ") {
+			ua.Browser = product
+		}
+		return ua
+	}
+	for _, rule := range uaBrowsers {
+		if rule.pattern.MatchString(s) {
+			ua.Browser = rule.name
+			break
+		}
+	}
+	switch {
+	case uaTablet.MatchString(s) || ua.OS == "Android" && !strings.Contains(s, "Mobile"):
+		ua.Device = DeviceTablet
+	case uaMobile.MatchString(s) || ua.OS == "Android":
+		ua.Device = DeviceMobile
+	case ua.OS != uaOther:
+		ua.Device = DeviceDesktop
+	}
+	return ua
+}
+
+// WithUserAgent returns an Enricher that parses the user agent of the record with ParseUserAgent and adds the
+// fields "ua_browser", "ua_os", "ua_device" and "bot" ("true" or "false"), so that traffic can be broken down
+// without decoding the output again, e.g. with GroupBy "ua_browser" or the PostFilter "bot == false". Records
+// without a user agent field get "Other", "Other", "other" and "false". Results are cached by user agent.
+func WithUserAgent() Enricher {
+	var mu sync.Mutex
+	cache := make(map[string]UserAgent)
+	return func(r Record) (Record, error) {
+		var ua UserAgent
+		for _, field := range defaultUserAgentFields {
+			v, ok := r.Get(field)
+			if !ok {
+				continue
+			}
+			mu.Lock()
+			cached, ok := cache[v]
+			mu.Unlock()
+			if ok {
+				ua = cached
+				break
+			}
+			ua = ParseUserAgent(v)
+			mu.Lock()
+			if len(cache) >= uaCacheSize {
+				clear(cache)
+			}
+			cache[v] = ua
+			mu.Unlock()
+			break
+		}
+		if ua.Browser == "" {
+			ua = UserAgent{Browser: uaOther, OS: uaOther, Device: DeviceOther}
+		}
+		return r.With(uaBrowserLabel, ua.Browser).
+			With(uaOSLabel, ua.OS).
+			With(uaDeviceLabel, ua.Device).
+			With(uaBotLabel, strconv.FormatBool(ua.Bot)), nil
+	}
+}
@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		ua   string
+		want UserAgent
+	}{
+		{
+			ua:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			want: UserAgent{Browser: "Chrome", OS: "Windows", Device: DeviceDesktop},
+		},
+		{
+			ua:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91",
+			want: UserAgent{Browser: "Edge", OS: "Windows", Device: DeviceDesktop},
+		},
+		{
+			ua:   "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15",
+			want: UserAgent{Browser: "Safari", OS: "macOS", Device: DeviceDesktop},
+		},
+		{
+			ua:   "Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+			want: UserAgent{Browser: "Safari", OS: "iOS", Device: DeviceMobile},
+		},
+		{
+			ua:   "Mozilla/5.0 (iPad; CPU OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/120.0.6099.119 Mobile/15E148 Safari/604.1",
+			want: UserAgent{Browser: "Chrome", OS: "iOS", Device: DeviceTablet},
+		},
+		{
+			ua:   "Mozilla/5.0 (Linux; Android 14; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Mobile Safari/537.36",
+			want: UserAgent{Browser: "Samsung Internet", OS: "Android", Device: DeviceMobile},
+		},
+		{
+			ua:   "Mozilla/5.0 (Linux; Android 13; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			want: UserAgent{Browser: "Chrome", OS: "Android", Device: DeviceTablet},
+		},
+		{
+			ua:   "Mozilla/5.0%20(X11;%20Linux%20x86_64;%20rv:121.0)%20Gecko/20100101%20Firefox/121.0",
+			want: UserAgent{Browser: "Firefox", OS: "Linux", Device: DeviceDesktop},
+		},
+		{
+			ua:   "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			want: UserAgent{Browser: "Googlebot", OS: "Other", Device: DeviceBot, Bot: true},
+		},
+		{
+			ua:   "curl/8.4.0",
+			want: UserAgent{Browser: "curl", OS: "Other", Device: DeviceBot, Bot: true},
+		},
+		{
+			ua:   "-",
+			want: UserAgent{Browser: "Other", OS: "Other", Device: DeviceBot, Bot: true},
+		},
+		{
+			ua:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64)",
+			want: UserAgent{Browser: "Other", OS: "Windows", Device: DeviceDesktop},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ua, func(t *testing.T) {
+			if got := ParseUserAgent(tt.ua); got != tt.want {
+				t.Errorf("\ngot:\n%v\nwant:\n%v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithUserAgent(t *testing.T) {
+	input := "user_agent:curl/8.4.0\tstatus:200\nuser_agent:Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) Version/17.2 Mobile/15E148 Safari/604.1\tstatus:200\nstatus:404\n"
+	buf := &bytes.Buffer{}
+	opt := Option{
+		Enrichers:   []Enricher{WithUserAgent()},
+		PostFilters: []string{"bot == false"},
+		Labels:      []string{"status", "ua_browser", "ua_os", "ua_device", "bot"},
+		Types:       map[string]FieldType{"bot": FieldTypeBool},
+	}
+	if _, err := NewLTSVParser(context.Background(), buf, opt).ParseString(input); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"status":"200","ua_browser":"Safari","ua_os":"iOS","ua_device":"mobile","bot":false}` + "\n" +
+		`{"status":"404","ua_browser":"Other","ua_os":"Other","ua_device":"other","bot":false}` + "\n"
+	if buf.String() != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", buf.String(), want)
+	}
+}
@@ -0,0 +1,343 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchError is the error message prefix for failures of WatchDir.
+const watchError = "cannot watch directory"
+
+// defaults of WatchConfig
+const (
+	defaultSettle = time.Second
+	defaultPoll   = 2 * time.Second
+)
+
+// WatchConfig defines how WatchDir picks up and tracks files.
+type WatchConfig struct {
+	StateFile string                                  // path of the file recording processed files across restarts (empty means kept in memory)
+	Settle    time.Duration                           // time a file must stay unchanged before it is parsed (zero means 1 second)
+	Poll      time.Duration                           // interval to poll the directory at instead of using file system events (zero means events)
+	OnResult  func(path string, r *Result, err error) // called after each file is parsed (nil means ignored)
+}
+
+// watchState records the processed files by file identity, which is the device and inode number where available,
+// so that rotated files renamed in the directory are not parsed again.
+type watchState struct {
+	Files map[string]watchedFile `json:"files"`
+}
+
+// watchedFile is a processed file in watchState.
+type watchedFile struct {
+	Name      string    `json:"name"`      // Name of the file when processed.
+	Size      int64     `json:"size"`      // Size of the file when processed.
+	Offset    int64     `json:"offset"`    // Position up to which the file has been parsed.
+	Processed time.Time `json:"processed"` // Time the file was processed.
+}
+
+// fileSnapshot is the size and modification time of a file, compared to detect changes when polling.
+type fileSnapshot struct {
+	size    int64
+	modTime time.Time
+}
+
+// dirWatcher tracks the files of a directory and parses each once it has settled.
+type dirWatcher struct {
+	p       Parser
+	dir     string
+	glob    string
+	cfg     WatchConfig
+	state   *watchState
+	pending map[string]time.Time    // last time a change was seen, by path
+	seen    map[string]fileSnapshot // last snapshot of the files, by path, when polling
+}
+
+// WatchDir parses the files in dir whose names match glob, then keeps picking up files that appear or change
+// in the directory, such as rotated logs, and parses each exactly once with p, until ctx is done. Records are
+// streamed to the output of p, and gzip-compressed files are detected by the ".gz" extension. A file is parsed
+// once it has stayed unchanged for WatchConfig.Settle.
+//
+// Processed files are tracked by identity in the state file, if any, together with the position parsed up to,
+// so that renamed files are not parsed again, files appended to are parsed from where they were left, and
+// restarts pick up where they left off. A file truncated in place is parsed from the beginning, and compressed
+// files are parsed once. A file whose parse fails is reported to WatchConfig.OnResult and not retried, while
+// a parse interrupted by ctx is repeated on the next run.
+//
+// Changes are detected by file system events where available. Set WatchConfig.Poll to poll the directory
+// instead, on file systems where events are unreliable such as NFS or some container volumes. Polling is
+// also used when file system events cannot be set up.
+func WatchDir(ctx context.Context, p Parser, dir, glob string, cfg WatchConfig) error {
+	if _, err := filepath.Match(glob, ""); err != nil {
+		return fmt.Errorf("%s: %w", watchError, err)
+	}
+	if cfg.Settle <= 0 {
+		cfg.Settle = defaultSettle
+	}
+	state, err := loadWatchState(cfg.StateFile)
+	if err != nil {
+		return err
+	}
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if cfg.Poll <= 0 {
+		fw, err := newFSWatcher(dir)
+		if err != nil {
+			cfg.Poll = defaultPoll
+		} else {
+			defer fw.Close()
+			events, errs = fw.Events, fw.Errors
+		}
+	}
+	w := &dirWatcher{
+		p:       p,
+		dir:     dir,
+		glob:    glob,
+		cfg:     cfg,
+		state:   state,
+		pending: map[string]time.Time{},
+		seen:    map[string]fileSnapshot{},
+	}
+	if err := w.scan(time.Now()); err != nil {
+		return err
+	}
+	var poll <-chan time.Time
+	if cfg.Poll > 0 {
+		ticker := time.NewTicker(cfg.Poll)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+	ticker := time.NewTicker(max(cfg.Settle/2, 10*time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-poll:
+			if err := w.poll(now); err != nil {
+				return err
+			}
+		case ev, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if ev.Has(fsnotify.Create) || ev.Has(fsnotify.Write) {
+				w.touch(ev.Name, time.Now())
+			}
+		case err, ok := <-errs:
+			if !ok {
+				return nil
+			}
+			return fmt.Errorf("%s: %w", watchError, err)
+		case now := <-ticker.C:
+			if err := w.flush(ctx, now); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// newFSWatcher returns a watcher of file system events in the directory.
+func newFSWatcher(dir string) (*fsnotify.Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := fw.Add(dir); err != nil {
+		fw.Close()
+		return nil, err
+	}
+	return fw, nil
+}
+
+// poll marks the matching files whose size or modification time has changed since the last poll as changed.
+func (w *dirWatcher) poll(now time.Time) error {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return fmt.Errorf("%s: %w", watchError, err)
+	}
+	for _, e := range entries {
+		fi, err := e.Info()
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		path := filepath.Join(w.dir, e.Name())
+		snap := fileSnapshot{size: fi.Size(), modTime: fi.ModTime()}
+		if w.seen[path] != snap {
+			w.seen[path] = snap
+			w.touch(path, now)
+		}
+	}
+	return nil
+}
+
+// scan marks all matching files in the directory as changed, and drops files no longer present from the state.
+func (w *dirWatcher) scan(now time.Time) error {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return fmt.Errorf("%s: %w", watchError, err)
+	}
+	present := map[string]struct{}{}
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		path := filepath.Join(w.dir, e.Name())
+		if fi, err := e.Info(); err == nil {
+			present[fileID(fi, e.Name())] = struct{}{}
+			w.seen[path] = fileSnapshot{size: fi.Size(), modTime: fi.ModTime()}
+		}
+		w.touch(path, now)
+	}
+	for id := range w.state.Files {
+		if _, ok := present[id]; !ok {
+			delete(w.state.Files, id)
+		}
+	}
+	return nil
+}
+
+// touch records a change of the file if its name matches.
+func (w *dirWatcher) touch(path string, now time.Time) {
+	if ok, _ := filepath.Match(w.glob, filepath.Base(path)); ok {
+		w.pending[path] = now
+	}
+}
+
+// flush parses the pending files that have settled, in order of name.
+func (w *dirWatcher) flush(ctx context.Context, now time.Time) error {
+	var settled []string
+	for path, t := range w.pending {
+		if now.Sub(t) >= w.cfg.Settle {
+			settled = append(settled, path)
+		}
+	}
+	sort.Strings(settled)
+	for _, path := range settled {
+		if ctx.Err() != nil {
+			return nil
+		}
+		delete(w.pending, path)
+		if err := w.process(ctx, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// process parses the file from the position parsed up to, if it has grown, and records it in the state.
+func (w *dirWatcher) process(ctx context.Context, path string) error {
+	fi, err := os.Stat(path)
+	if err != nil || !fi.Mode().IsRegular() {
+		return nil
+	}
+	id := fileID(fi, filepath.Base(path))
+	f, ok := w.state.Files[id]
+	gzipped := strings.HasSuffix(path, ".gz")
+	if ok && (gzipped || fi.Size() == f.Offset) {
+		return nil
+	}
+	var offset int64
+	if ok && fi.Size() > f.Offset {
+		offset = f.Offset
+	}
+	var r *Result
+	if gzipped {
+		r, err = w.p.ParseGzip(path)
+	} else {
+		r, err = w.parseFrom(path, offset, fi.Size())
+	}
+	if w.cfg.OnResult != nil {
+		w.cfg.OnResult(path, r, err)
+	}
+	if ctx.Err() != nil && errors.Is(err, context.Canceled) {
+		return nil
+	}
+	w.state.Files[id] = watchedFile{Name: filepath.Base(path), Size: fi.Size(), Offset: fi.Size(), Processed: time.Now()}
+	return saveWatchState(w.cfg.StateFile, w.state)
+}
+
+// parseFrom parses the file between the offsets, leaving data appended meanwhile to the next change.
+func (w *dirWatcher) parseFrom(path string, from, to int64) (*Result, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(from, io.SeekStart); err != nil {
+		return nil, err
+	}
+	r, err := w.p.Parse(io.LimitReader(f, to-from))
+	if r != nil {
+		r.Source = filepath.Base(path)
+	}
+	return r, err
+}
+
+// loadWatchState loads the state saved at path, or returns an empty state if path is empty or does not exist.
+func loadWatchState(path string) (*watchState, error) {
+	state := &watchState{Files: map[string]watchedFile{}}
+	if path == "" {
+		return state, nil
+	}
+	b, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", watchError, err)
+	}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, fmt.Errorf("%s: invalid state file: %w", watchError, err)
+	}
+	if state.Files == nil {
+		state.Files = map[string]watchedFile{}
+	}
+	for id, f := range state.Files {
+		// States saved before positions were recorded hold fully parsed files.
+		if f.Offset == 0 {
+			f.Offset = f.Size
+			state.Files[id] = f
+		}
+	}
+	return state, nil
+}
+
+// saveWatchState writes the state to path, if not empty. The file is replaced atomically.
+func saveWatchState(path string, state *watchState) error {
+	if err := saveStateFile(path, state); err != nil {
+		return fmt.Errorf("%s: %w", watchError, err)
+	}
+	return nil
+}
+
+// saveStateFile writes v as JSON to path, if not empty. The file is replaced atomically.
+func saveStateFile(path string, v any) error {
+	if path == "" {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b)
+}
+
+// writeFileAtomic writes b to path through a temporary file renamed into place.
+func writeFileAtomic(path string, b []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(filepath.Clean(tmp), b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package parser
+
+import "os"
+
+// fileID returns the identity of the file by name, as inode numbers are not available on this platform.
+func fileID(_ os.FileInfo, name string) string {
+	return "name:" + name
+}
@@ -0,0 +1,155 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestWatchDir(t *testing.T) {
+	dir := t.TempDir()
+	state := filepath.Join(t.TempDir(), "state.json")
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := func(n int, during func()) (string, []string) {
+		t.Helper()
+		return watchRun(t, dir, WatchConfig{StateFile: state, Settle: 50 * time.Millisecond}, n, during)
+	}
+
+	write("a.log", "a:1\n")
+	write("ignored.txt", "x:1\n")
+	out, names := run(2, func() {
+		write("b.log", "b:1\n")
+		gz := &bytes.Buffer{}
+		zw := gzip.NewWriter(gz)
+		zw.Write([]byte("c:1\n"))
+		zw.Close()
+		write("c.log.gz", gz.String())
+	})
+	if want := []string{"a.log", "b.log", "c.log.gz"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", names, want)
+	}
+	for _, line := range []string{`{"a":"1"}`, `{"b":"1"}`, `{"c":"1"}`} {
+		if !bytes.Contains([]byte(out), []byte(line)) {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", out, line)
+		}
+	}
+
+	// A rotated file keeps its identity and is not parsed again, even after a restart.
+	if err := os.Rename(filepath.Join(dir, "a.log"), filepath.Join(dir, "a.log.1")); err != nil {
+		t.Fatal(err)
+	}
+	out, names = run(1, func() {
+		write("a.log", "a:2\n")
+	})
+	if want := []string{"a.log"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", names, want)
+	}
+	if want := `{"a":"2"}` + "\n"; out != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", out, want)
+	}
+}
+
+func TestWatchDir_poll(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.log")
+	cfg := WatchConfig{
+		StateFile: filepath.Join(t.TempDir(), "state.json"),
+		Settle:    50 * time.Millisecond,
+		Poll:      20 * time.Millisecond,
+	}
+	if err := os.WriteFile(path, []byte("a:1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out, _ := watchRun(t, dir, cfg, 2, func() {
+		time.Sleep(150 * time.Millisecond)
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString("a:2\n"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if want := `{"a":"1"}` + "\n" + `{"a":"2"}` + "\n"; out != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", out, want)
+	}
+
+	// After a restart, only changes are parsed, and a file truncated in place is parsed from the beginning.
+	out, _ = watchRun(t, dir, cfg, 1, func() {
+		time.Sleep(150 * time.Millisecond)
+		if err := os.WriteFile(path, []byte("a:3\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if want := `{"a":"3"}` + "\n"; out != want {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", out, want)
+	}
+}
+
+// watchRun watches the directory until n files have been parsed, then stops after another settle period,
+// and returns the output and the names of the parsed files.
+func watchRun(t *testing.T, dir string, cfg WatchConfig, n int, during func()) (string, []string) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	buf := &bytes.Buffer{}
+	p := NewLTSVParser(context.Background(), buf, Option{})
+	parsed := make(chan string, 10)
+	cfg.OnResult = func(path string, r *Result, err error) {
+		if err != nil {
+			t.Error(err)
+		}
+		parsed <- filepath.Base(path)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- WatchDir(ctx, p, dir, "*.log*", cfg)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if during != nil {
+		during()
+	}
+	var names []string
+	timeout := time.After(5 * time.Second)
+	for len(names) < n {
+		select {
+		case name := <-parsed:
+			names = append(names, name)
+		case <-timeout:
+			t.Fatalf("\ngot:\n%v\nwant:\n%v\n", names, n)
+		}
+	}
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	close(parsed)
+	for name := range parsed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return buf.String(), names
+}
+
+func TestWatchDir_error(t *testing.T) {
+	p := NewLTSVParser(context.Background(), &bytes.Buffer{}, Option{})
+	if err := WatchDir(context.Background(), p, t.TempDir(), "[", WatchConfig{}); err == nil {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "error")
+	}
+	if err := WatchDir(context.Background(), p, filepath.Join(t.TempDir(), "missing"), "*", WatchConfig{}); err == nil {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", err, "error")
+	}
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package parser
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// fileID returns the identity of the file by device and inode number, which survives renames.
+func fileID(fi os.FileInfo, name string) string {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return fmt.Sprintf("%d:%d", st.Dev, st.Ino)
+	}
+	return "name:" + name
+}